@@ -0,0 +1,143 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/rest"
+	"golang.org/x/net/context"
+)
+
+func (api *APIClient) ExportUsers(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := api.cli.Get(ctx, "/admin/users/export", nil, nil)
+	return resp.Body, err
+}
+
+func (api *APIClient) ImportUsers(ctx context.Context, content io.Reader, replace bool) (*types.UserImportResult, error) {
+	var query url.Values
+	if replace {
+		query = url.Values{"mode": []string{"replace"}}
+	}
+
+	var result types.UserImportResult
+	headers := map[string][]string{"Content-Type": {"application/json"}}
+	resp, err := api.cli.PostRaw(ctx, "/admin/users/import", query, content, headers)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// GetUserQuota returns the quota and resource usage of a user.
+func (api *APIClient) GetUserQuota(ctx context.Context, name string) (*types.QuotaUsage, error) {
+	var result types.QuotaUsage
+	resp, err := api.cli.Get(ctx, "/admin/users/"+name+"/quota", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// SetUserQuota changes the quota of a user, or restores the default quota
+// if the quota is nil.
+func (api *APIClient) SetUserQuota(ctx context.Context, name string, quota *types.Quota) error {
+	var resp *rest.ServerResponse
+	var err error
+	if quota == nil {
+		resp, err = api.cli.Delete(ctx, "/admin/users/"+name+"/quota", nil, nil)
+	} else {
+		resp, err = api.cli.Put(ctx, "/admin/users/"+name+"/quota", nil, quota, nil)
+	}
+	resp.EnsureClosed()
+	return err
+}
+
+// ListUsers returns all users registered in the platform.
+func (api *APIClient) ListUsers(ctx context.Context) ([]*types.UserInfo, error) {
+	var users []*types.UserInfo
+	resp, err := api.cli.Get(ctx, "/admin/users/", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&users)
+		resp.EnsureClosed()
+	}
+	return users, err
+}
+
+// GetUser returns information of a user.
+func (api *APIClient) GetUser(ctx context.Context, name string) (*types.UserInfo, error) {
+	var user types.UserInfo
+	resp, err := api.cli.Get(ctx, "/admin/users/"+name, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&user)
+		resp.EnsureClosed()
+	}
+	return &user, err
+}
+
+// CreateUser creates a new user.
+func (api *APIClient) CreateUser(ctx context.Context, req types.CreateUser) (*types.UserInfo, error) {
+	var user types.UserInfo
+	resp, err := api.cli.Post(ctx, "/admin/users/", nil, &req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&user)
+		resp.EnsureClosed()
+	}
+	return &user, err
+}
+
+// RemoveUser removes a user along with all applications of the user.
+func (api *APIClient) RemoveUser(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/admin/users/"+name, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetUserActive enables or disables a user.
+func (api *APIClient) SetUserActive(ctx context.Context, name string, active bool) error {
+	action := "/disable"
+	if active {
+		action = "/enable"
+	}
+	resp, err := api.cli.Post(ctx, "/admin/users/"+name+action, nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResetPassword changes the password of a user.
+func (api *APIClient) ResetPassword(ctx context.Context, name, password string) error {
+	req := types.ResetPassword{Password: password}
+	resp, err := api.cli.Put(ctx, "/admin/users/"+name+"/password", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// SetUserNamespace changes the namespace of a user without applications.
+func (api *APIClient) SetUserNamespace(ctx context.Context, name, namespace string) error {
+	query := url.Values{}
+	query.Set("namespace", namespace)
+
+	resp, err := api.cli.Put(ctx, "/admin/users/"+name+"/namespace", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// CollectGarbage removes orphaned builders, unused images and stale temporary
+// files on the server. Garbage is only reported if dryRun is true.
+func (api *APIClient) CollectGarbage(ctx context.Context, dryRun bool) (*types.GCResult, error) {
+	var query url.Values
+	if dryRun {
+		query = url.Values{"dry-run": []string{""}}
+	}
+
+	var result types.GCResult
+	resp, err := api.cli.Post(ctx, "/admin/gc", query, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
@@ -1,11 +1,16 @@
 package client
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/url"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/rest"
 	"github.com/cloudway/platform/pkg/serverlog"
 	"golang.org/x/net/context"
 )
@@ -42,6 +47,20 @@ func (api *APIClient) CreateApplication(ctx context.Context, opts types.CreateAp
 	return &info, err
 }
 
+// CloneApplication creates a new application with the same plugins and
+// environment as an existing application.
+func (api *APIClient) CloneApplication(ctx context.Context, name string, opts types.CloneApplication, dstout, dsterr io.Writer) (*types.ApplicationInfo, error) {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/clone", nil, &opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.ApplicationInfo
+	err = serverlog.Drain(resp.Body, dstout, dsterr, &info)
+	resp.Body.Close()
+	return &info, err
+}
+
 func (api *APIClient) RemoveApplication(ctx context.Context, name string) error {
 	resp, err := api.cli.Delete(ctx, "/applications/"+name, nil, nil)
 	resp.EnsureClosed()
@@ -93,6 +112,38 @@ func (api *APIClient) RestartApplication(ctx context.Context, name string, dstou
 	return err
 }
 
+// IdleApplication stops all containers of the application to save resources
+// until the application is resumed.
+func (api *APIClient) IdleApplication(ctx context.Context, name string) error {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/idle", nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResumeApplication starts all containers of the idle application.
+func (api *APIClient) ResumeApplication(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/resume", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) BulkOperation(ctx context.Context, opts types.BulkOptions, dstout, dsterr io.Writer) ([]*types.BulkResult, error) {
+	resp, err := api.cli.Post(ctx, "/applications/bulk/", nil, &opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*types.BulkResult
+	err = serverlog.Drain(resp.Body, dstout, dsterr, &results)
+	resp.Body.Close()
+	return results, err
+}
+
 func (api *APIClient) GetApplicationStatus(ctx context.Context, name string) (status []*types.ContainerStatus, err error) {
 	resp, err := api.cli.Get(ctx, "/applications/"+name+"/status", nil, nil)
 	if err == nil {
@@ -120,18 +171,158 @@ func (api *APIClient) GetApplicationProcesses(ctx context.Context, name string)
 	return
 }
 
+func (api *APIClient) GetApplicationVolumes(ctx context.Context, name string) (volumes []*types.ContainerVolumes, err error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/volumes", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&volumes)
+		resp.EnsureClosed()
+	}
+	return
+}
+
 func (api *APIClient) GetApplicationStats(ctx context.Context, name string) (io.ReadCloser, error) {
 	resp, err := api.cli.Get(ctx, "/applications/"+name+"/stats", nil, nil)
 	return resp.Body, err
 }
 
-func (api *APIClient) DeployApplication(ctx context.Context, name, branch string, dstout, dsterr io.Writer) error {
+func (api *APIClient) GetApplicationStatsSnapshot(ctx context.Context, name string) (*types.ApplicationStats, error) {
+	var stats types.ApplicationStats
+	query := url.Values{"stream": []string{"false"}}
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/stats", query, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&stats)
+		resp.EnsureClosed()
+	}
+	return &stats, err
+}
+
+func (api *APIClient) ApplicationLogs(ctx context.Context, name string, opts types.LogsOptions, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/logs", logsQuery(opts), nil)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func logsQuery(opts types.LogsOptions) url.Values {
+	query := url.Values{}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	if opts.Tail != "" {
+		query.Set("tail", opts.Tail)
+	}
+	if opts.Since != "" {
+		query.Set("since", opts.Since)
+	}
+	return query
+}
+
+// SearchLogs searches the aggregated logs of the application, the most
+// recent entries last.
+func (api *APIClient) SearchLogs(ctx context.Context, name string, opts types.LogSearchOptions) ([]*types.LogEntry, error) {
+	query := url.Values{}
+	if opts.Query != "" {
+		query.Set("q", opts.Query)
+	}
+	if opts.Since != "" {
+		query.Set("since", opts.Since)
+	}
+	if opts.Until != "" {
+		query.Set("until", opts.Until)
+	}
+	if opts.Severity != "" {
+		query.Set("severity", opts.Severity)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var entries []*types.LogEntry
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/logs/search", query, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.EnsureClosed()
+	}
+	return entries, err
+}
+
+// ExecApplication executes a shell or command in an application container.
+// The returned connection is attached to the standard streams of the command.
+// The output is multiplexed by stdcopy if TTY is not allocated.
+func (api *APIClient) ExecApplication(ctx context.Context, name string, opts types.ExecOptions) (*rest.HijackedResponse, string, error) {
+	query := url.Values{}
+	if opts.Service != "" {
+		query.Set("service", opts.Service)
+	}
+	if opts.Container != "" {
+		query.Set("container", opts.Container)
+	}
+	if opts.Tty {
+		query.Set("tty", "1")
+		query.Set("term", opts.Term)
+		query.Set("w", strconv.Itoa(opts.Width))
+		query.Set("h", strconv.Itoa(opts.Height))
+	}
+	query["cmd"] = opts.Cmd
+
+	resp, err := api.cli.PostHijacked(ctx, "/applications/"+name+"/exec", query, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, resp.Header.Get("X-Exec-Id"), nil
+}
+
+// ResizeExec changes the TTY size of the running command.
+func (api *APIClient) ResizeExec(ctx context.Context, name, execId string, width, height int) error {
+	query := url.Values{}
+	query.Set("w", strconv.Itoa(width))
+	query.Set("h", strconv.Itoa(height))
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/exec/"+execId+"/resize", query, nil, nil)
+	if err == nil {
+		resp.EnsureClosed()
+	}
+	return err
+}
+
+func (api *APIClient) InspectExec(ctx context.Context, name, execId string) (*types.ExecInspect, error) {
+	var inspect types.ExecInspect
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/exec/"+execId, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&inspect)
+		resp.EnsureClosed()
+	}
+	return &inspect, err
+}
+
+// DeployApplication deploys a branch, tag or commit of the application.
+// The current deployment branch is deployed if ref is empty.
+func (api *APIClient) DeployApplication(ctx context.Context, name, ref string, opts types.DeployOptions, dstout, dsterr io.Writer) error {
+	query := deployQuery(opts)
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/deploy", query, nil, deployHeaders(opts, nil))
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) Rollback(ctx context.Context, name string, version int, dstout, dsterr io.Writer) error {
 	var query url.Values
-	if branch != "" {
-		query = url.Values{"branch": []string{branch}}
+	if version > 0 {
+		query = url.Values{"version": []string{strconv.Itoa(version)}}
 	}
 
-	resp, err := api.cli.Post(ctx, "/applications/"+name+"/deploy", query, nil, nil)
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/rollback", query, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -141,6 +332,187 @@ func (api *APIClient) DeployApplication(ctx context.Context, name, branch string
 	return err
 }
 
+func (api *APIClient) PromoteCanary(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	return api.finishCanary(ctx, name, "promote", dstout, dsterr)
+}
+
+func (api *APIClient) AbortCanary(ctx context.Context, name string, dstout, dsterr io.Writer) error {
+	return api.finishCanary(ctx, name, "abort", dstout, dsterr)
+}
+
+func (api *APIClient) finishCanary(ctx context.Context, name, action string, dstout, dsterr io.Writer) error {
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/canary/"+action, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) GetDeploymentHistory(ctx context.Context, name string) ([]*types.DeploymentVersion, error) {
+	var versions []*types.DeploymentVersion
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/history", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&versions)
+		resp.EnsureClosed()
+	}
+	return versions, err
+}
+
+func (api *APIClient) BackupApplication(ctx context.Context, name string) (*types.Backup, error) {
+	var backup types.Backup
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/backup", nil, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&backup)
+		resp.EnsureClosed()
+	}
+	return &backup, err
+}
+
+func (api *APIClient) GetApplicationBackups(ctx context.Context, name string) ([]*types.Backup, error) {
+	var backups []*types.Backup
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/backups", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&backups)
+		resp.EnsureClosed()
+	}
+	return backups, err
+}
+
+func (api *APIClient) RestoreApplication(ctx context.Context, name, backup string, dstout, dsterr io.Writer) error {
+	var query url.Values
+	if backup != "" {
+		query = url.Values{"backup": []string{backup}}
+	}
+
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/restore", query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+func (api *APIClient) GetApplicationBuilds(ctx context.Context, name string, offset, limit int) ([]*types.Build, error) {
+	query := url.Values{}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var builds []*types.Build
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/builds", query, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&builds)
+		resp.EnsureClosed()
+	}
+	return builds, err
+}
+
+func (api *APIClient) CancelBuild(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/builds/current", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) ClearBuildCache(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/builds/cache", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetBuildLock(ctx context.Context, name string) (*types.BuildLock, error) {
+	var lock types.BuildLock
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/builds/lock", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&lock)
+		resp.EnsureClosed()
+	}
+	return &lock, err
+}
+
+func (api *APIClient) GetBuildLog(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := api.cli.Get(ctx, "/builds/"+id+"/log", nil, nil)
+	return resp.Body, err
+}
+
+func (api *APIClient) GetCronRuns(ctx context.Context, name string) ([]*types.CronRun, error) {
+	var runs []*types.CronRun
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/cron/runs", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&runs)
+		resp.EnsureClosed()
+	}
+	return runs, err
+}
+
+func deployQuery(opts types.DeployOptions) url.Values {
+	query := url.Values{}
+	if opts.Condition != "" {
+		query.Set("condition", opts.Condition)
+	}
+	if opts.Strategy != "" {
+		query.Set("strategy", opts.Strategy)
+	}
+	if opts.BatchSize > 0 {
+		query.Set("batch", strconv.Itoa(opts.BatchSize))
+	}
+	if opts.FailureThreshold > 0 {
+		query.Set("max-failures", strconv.Itoa(opts.FailureThreshold))
+	}
+	if opts.Retries > 0 {
+		query.Set("retries", strconv.Itoa(opts.Retries))
+	}
+	if opts.OnFailure != "" {
+		query.Set("on-failure", opts.OnFailure)
+	}
+	if opts.CanaryPercent > 0 {
+		query.Set("canary", strconv.Itoa(opts.CanaryPercent))
+	}
+	if opts.CanaryWeight > 0 {
+		query.Set("weight", strconv.Itoa(opts.CanaryWeight))
+	}
+	if opts.Delta {
+		query.Set("delta", "true")
+	}
+	if opts.PullEnv {
+		query.Set("pull-env", "true")
+	}
+	return query
+}
+
+// deployHeaders adds build variables to request headers, since secret
+// values must not be sent in URL query.
+func deployHeaders(opts types.DeployOptions, headers map[string][]string) map[string][]string {
+	env := url.Values{}
+	addBuildEnv(env, "build-env", opts.BuildEnv)
+	addBuildEnv(env, "build-secret", opts.BuildSecrets)
+	if len(env) != 0 {
+		if headers == nil {
+			headers = make(map[string][]string)
+		}
+		headers[types.BuildEnvHeader] = []string{env.Encode()}
+	}
+	return headers
+}
+
+func addBuildEnv(query url.Values, key string, env map[string]string) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		query.Add(key, name+"="+env[name])
+	}
+}
+
 func (api *APIClient) GetApplicationDeployments(ctx context.Context, name string) (*types.Deployments, error) {
 	var deployments types.Deployments
 	resp, err := api.cli.Get(ctx, "/applications/"+name+"/deploy", nil, nil)
@@ -157,14 +529,13 @@ func (api *APIClient) Download(ctx context.Context, name string) (io.ReadCloser,
 	return resp.Body, err
 }
 
-func (api *APIClient) Upload(ctx context.Context, name string, content io.Reader, binary bool, dstout, dsterr io.Writer) error {
-	var query url.Values
+func (api *APIClient) Upload(ctx context.Context, name string, content io.Reader, binary bool, opts types.DeployOptions, dstout, dsterr io.Writer) error {
+	query := deployQuery(opts)
 	if binary {
-		query = url.Values{}
 		query.Set("binary", "true")
 	}
 
-	headers := map[string][]string{"Content-Type": {"application/tar+gzip"}}
+	headers := deployHeaders(opts, map[string][]string{"Content-Type": {"application/tar+gzip"}})
 	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/repo", query, content, headers)
 	if err != nil {
 		return err
@@ -175,6 +546,54 @@ func (api *APIClient) Upload(ctx context.Context, name string, content io.Reader
 	return err
 }
 
+// SubmitUpload uploads the application repository and returns the queued
+// deployment immediately. The returned deployment is the latest one if it
+// has the same content.
+func (api *APIClient) SubmitUpload(ctx context.Context, name string, content io.Reader, binary bool, opts types.DeployOptions) (*types.Build, error) {
+	query := deployQuery(opts)
+	query.Set("async", "true")
+	if binary {
+		query.Set("binary", "true")
+	}
+
+	var build types.Build
+	headers := deployHeaders(opts, map[string][]string{"Content-Type": {"application/tar+gzip"}})
+	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/repo", query, content, headers)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&build)
+		resp.EnsureClosed()
+	}
+	return &build, err
+}
+
+// GetDeployment returns the status of a deployment.
+func (api *APIClient) GetDeployment(ctx context.Context, id string) (*types.Build, error) {
+	var build types.Build
+	resp, err := api.cli.Get(ctx, "/deployments/"+id, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&build)
+		resp.EnsureClosed()
+	}
+	return &build, err
+}
+
+// WaitDeployment polls the status of a deployment in the given interval
+// until the deployment is finished or the context is done.
+func (api *APIClient) WaitDeployment(ctx context.Context, id string, interval time.Duration) (*types.Build, error) {
+	for {
+		build, err := api.GetDeployment(ctx, id)
+		if err != nil || build.Status == "succeeded" || build.Status == "failed" {
+			return build, err
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return build, ctx.Err()
+		}
+	}
+}
+
 func (api *APIClient) Dump(ctx context.Context, name string) (io.ReadCloser, error) {
 	headers := map[string][]string{"Accept": {"application/tar+gzip"}}
 	resp, err := api.cli.Get(ctx, "/applications/"+name+"/data", nil, headers)
@@ -188,8 +607,69 @@ func (api *APIClient) Restore(ctx context.Context, name string, content io.Reade
 	return err
 }
 
+func fileQuery(service, root, path string) url.Values {
+	query := url.Values{}
+	if service != "" {
+		query.Set("service", service)
+	}
+	if root != "" {
+		query.Set("root", root)
+	}
+	query.Set("path", path)
+	return query
+}
+
+// ListFiles lists the directory contents in an application container.
+// The path is relative to the root directory, which is data or repo.
+func (api *APIClient) ListFiles(ctx context.Context, name, service, root, path string) ([]*types.FileInfo, error) {
+	var files []*types.FileInfo
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/files", fileQuery(service, root, path), nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&files)
+		resp.EnsureClosed()
+	}
+	return files, err
+}
+
+// DownloadFiles returns a tar archive of the file or directory in an
+// application container, along with the file information.
+func (api *APIClient) DownloadFiles(ctx context.Context, name, service, root, path string) (io.ReadCloser, *types.FileInfo, error) {
+	headers := map[string][]string{"Accept": {"application/x-tar"}}
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/files/archive", fileQuery(service, root, path), headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stat types.FileInfo
+	data, err := base64.StdEncoding.DecodeString(resp.Header.Get(types.FileStatHeader))
+	if err == nil {
+		err = json.Unmarshal(data, &stat)
+	}
+	if err != nil {
+		resp.EnsureClosed()
+		return nil, nil, err
+	}
+	return resp.Body, &stat, nil
+}
+
+// UploadFiles extracts a tar archive into the directory in an application
+// container.
+func (api *APIClient) UploadFiles(ctx context.Context, name, service, root, path string, content io.Reader) error {
+	headers := map[string][]string{"Content-Type": {"application/x-tar"}}
+	resp, err := api.cli.PutRaw(ctx, "/applications/"+name+"/files/archive", fileQuery(service, root, path), content, headers)
+	resp.EnsureClosed()
+	return err
+}
+
 func (api *APIClient) ScaleApplication(ctx context.Context, name, scaling string, dstout, dsterr io.Writer) error {
+	return api.ScaleService(ctx, name, "", scaling, dstout, dsterr)
+}
+
+func (api *APIClient) ScaleService(ctx context.Context, name, service, scaling string, dstout, dsterr io.Writer) error {
 	query := url.Values{"scale": []string{scaling}}
+	if service != "" {
+		query.Set("service", service)
+	}
 	resp, err := api.cli.Post(ctx, "/applications/"+name+"/scale", query, nil, nil)
 	if err != nil {
 		return err
@@ -200,6 +680,96 @@ func (api *APIClient) ScaleApplication(ctx context.Context, name, scaling string
 	return err
 }
 
+// GetResources returns resource limits of the application containers for
+// each service.
+func (api *APIClient) GetResources(ctx context.Context, name string) ([]*types.Resources, error) {
+	var resources []*types.Resources
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/resources", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&resources)
+		resp.EnsureClosed()
+	}
+	return resources, err
+}
+
+// SetResources changes resource limits of the application containers of
+// a service, or the framework containers if the service name is empty.
+func (api *APIClient) SetResources(ctx context.Context, name string, resources *types.Resources) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/resources", nil, resources, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetLogConfig returns the log driver and options of the application
+// containers.
+func (api *APIClient) GetLogConfig(ctx context.Context, name string) (*types.LogConfig, error) {
+	var logging types.LogConfig
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/logging", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&logging)
+		resp.EnsureClosed()
+	}
+	return &logging, err
+}
+
+// SetLogConfig overrides the log driver and options of the application
+// containers, an empty driver restores the default configuration.
+func (api *APIClient) SetLogConfig(ctx context.Context, name string, logging *types.LogConfig) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/logging", nil, logging, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetNetworkGrants returns network access grants of the application.
+func (api *APIClient) GetNetworkGrants(ctx context.Context, name string) (*types.NetworkGrants, error) {
+	var grants types.NetworkGrants
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/network", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&grants)
+		resp.EnsureClosed()
+	}
+	return &grants, err
+}
+
+// GrantNetworkAccess grants another application access to services of the
+// application.
+func (api *APIClient) GrantNetworkAccess(ctx context.Context, name, grantee string) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/network/grants/"+grantee, nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// RevokeNetworkAccess revokes network access to services of the application
+// granted to another application.
+func (api *APIClient) RevokeNetworkAccess(ctx context.Context, name, grantee string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/network/grants/"+grantee, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) GetenvAll(ctx context.Context, name string) (map[string]string, error) {
+	var env map[string]string
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/env", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&env)
+		resp.EnsureClosed()
+	}
+	return env, err
+}
+
+func (api *APIClient) SetenvAll(ctx context.Context, name string, env map[string]string) error {
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/env", nil, env, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) Unsetenv(ctx context.Context, name string, keys ...string) error {
+	query := url.Values{"key": keys}
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/env", query, nil)
+	resp.EnsureClosed()
+	return err
+}
+
 func envpath(name, service string) string {
 	if service == "" {
 		service = "_"
@@ -250,3 +820,105 @@ func (api *APIClient) ApplicationUnsetenv(ctx context.Context, name, service str
 	resp.EnsureClosed()
 	return err
 }
+
+func (api *APIClient) ListDomains(ctx context.Context, name string) ([]*types.Domain, error) {
+	var domains []*types.Domain
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/domains", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&domains)
+		resp.EnsureClosed()
+	}
+	return domains, err
+}
+
+// AddDomain attaches a custom domain to the application. The server
+// verifies the domain ownership through DNS before the domain is added.
+func (api *APIClient) AddDomain(ctx context.Context, name, host string) (*types.Domain, error) {
+	var domain types.Domain
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/domains", nil, &types.Domain{Name: host}, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&domain)
+		resp.EnsureClosed()
+	}
+	return &domain, err
+}
+
+func (api *APIClient) RemoveDomain(ctx context.Context, name, host string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/domains/"+host, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// UploadCertificate installs a custom TLS certificate for the domain. Both
+// the certificate chain and private key are in PEM format.
+func (api *APIClient) UploadCertificate(ctx context.Context, name, host string, cert, key []byte) (*types.Certificate, error) {
+	var result types.Certificate
+	req := &types.Certificate{Certificate: string(cert), PrivateKey: string(key)}
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/domains/"+host+"/certificate", nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// RemoveCertificate removes the TLS certificate of the domain.
+func (api *APIClient) RemoveCertificate(ctx context.Context, name, host string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/domains/"+host+"/certificate", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ListSecrets returns secrets of the application without values.
+func (api *APIClient) ListSecrets(ctx context.Context, name string) ([]*types.Secret, error) {
+	var secrets []*types.Secret
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/secrets", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&secrets)
+		resp.EnsureClosed()
+	}
+	return secrets, err
+}
+
+// SetSecret creates or updates a secret of the application. The mount is
+// "file" or "env", defaults to "file".
+func (api *APIClient) SetSecret(ctx context.Context, name, key, value, mount string) (*types.Secret, error) {
+	var result types.Secret
+	req := &types.Secret{Value: value, Mount: mount}
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/secrets/"+key, nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// RotateSecret replaces the value of the secret, a random value is
+// generated if the value is empty.
+func (api *APIClient) RotateSecret(ctx context.Context, name, key, value string) (*types.Secret, error) {
+	var result types.Secret
+	req := &types.Secret{Value: value}
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/secrets/"+key+"/rotate", nil, req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+func (api *APIClient) RemoveSecret(ctx context.Context, name, key string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/secrets/"+key, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetSecretAudit returns recent operations on secrets of the application.
+func (api *APIClient) GetSecretAudit(ctx context.Context, name string) ([]*types.SecretAccess, error) {
+	var audit []*types.SecretAccess
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/secrets/audit", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&audit)
+		resp.EnsureClosed()
+	}
+	return audit, err
+}
@@ -3,13 +3,34 @@ package client
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"net/url"
+
 	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
 )
 
+// ErrTwoFactorRequired is returned by AuthenticateWithCode if the user
+// enabled two-factor authentication and the code is missing.
+var ErrTwoFactorRequired = errors.New("Two-factor authentication code required")
+
 func (api *APIClient) Authenticate(ctx context.Context, username, password string) (token string, err error) {
+	return api.AuthenticateWithCode(ctx, username, password, "")
+}
+
+// AuthenticateWithCode authenticates the user with password and the code
+// of two-factor authentication, which can be empty if not enabled.
+func (api *APIClient) AuthenticateWithCode(ctx context.Context, username, password, code string) (token string, err error) {
 	auth := string(base64.StdEncoding.EncodeToString([]byte(username + ":" + password)))
 	headers := map[string][]string{"Authorization": {"Basic " + auth}}
+	if code != "" {
+		headers[types.TwoFactorHeader] = []string{code}
+	}
 	resp, err := api.cli.Post(ctx, "/auth", nil, nil, headers)
+	if ErrorCode(err) == types.ErrCodeTwoFactorRequired {
+		return "", ErrTwoFactorRequired
+	}
 	if err == nil {
 		var tokenJson map[string]string
 		err = json.NewDecoder(resp.Body).Decode(&tokenJson)
@@ -19,6 +40,51 @@ func (api *APIClient) Authenticate(ctx context.Context, username, password strin
 	return token, err
 }
 
+// Register creates a new user pending for email verification.
+func (api *APIClient) Register(ctx context.Context, req types.Register) error {
+	resp, err := api.cli.Post(ctx, "/auth/register", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ConfirmRegistration verifies the email address of a registered user
+// with the token sent in the verification email.
+func (api *APIClient) ConfirmRegistration(ctx context.Context, token string) (*types.Confirmation, error) {
+	var result types.Confirmation
+	query := url.Values{"token": {token}}
+	resp, err := api.cli.Post(ctx, "/auth/confirm", query, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// RequestPasswordReset requests a password reset token sent to the email
+// address of the user.
+func (api *APIClient) RequestPasswordReset(ctx context.Context, email string) error {
+	req := types.ForgotPassword{Email: email}
+	resp, err := api.cli.Post(ctx, "/auth/password/forgot", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ResetPasswordWithToken sets a new password with the password reset token.
+func (api *APIClient) ResetPasswordWithToken(ctx context.Context, token, password string) error {
+	req := types.PasswordReset{Token: token, Password: password}
+	resp, err := api.cli.Post(ctx, "/auth/password/reset", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// ChangePassword changes the password of the logged in user.
+func (api *APIClient) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	req := types.ChangePassword{OldPassword: oldPassword, NewPassword: newPassword}
+	resp, err := api.cli.Put(ctx, "/users/self/password", nil, &req, nil)
+	resp.EnsureClosed()
+	return err
+}
+
 func (api *APIClient) SetToken(token string) {
 	if token != "" {
 		api.cli.AddCustomHeader("Authorization", "Bearer "+token)
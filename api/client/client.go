@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/cloudway/platform/pkg/rest"
+	"github.com/cloudway/platform/pkg/serverlog"
 )
 
 type APIClient struct {
@@ -11,9 +12,23 @@ type APIClient struct {
 }
 
 func NewAPIClient(host, version string, client *http.Client, httpHeaders map[string]string) (*APIClient, error) {
-	cli, err := rest.NewClient(host, version, client, httpHeaders)
+	// accept progress records of long running operations
+	headers := map[string]string{serverlog.ProgressHeader: "1"}
+	for k, v := range httpHeaders {
+		headers[k] = v
+	}
+
+	cli, err := rest.NewClient(host, version, client, headers)
 	if err != nil {
 		return nil, err
 	}
 	return &APIClient{cli}, nil
 }
+
+// SetRetryPolicy sets the policy to retry requests failed due to connection
+// failures, temporary server unavailability or rate limiting. Requests are
+// not retried by default. Use rest.DefaultRetryPolicy for a reasonable
+// policy.
+func (api *APIClient) SetRetryPolicy(policy *rest.RetryPolicy) {
+	api.cli.SetRetryPolicy(policy)
+}
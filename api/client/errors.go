@@ -0,0 +1,24 @@
+package client
+
+import (
+	"github.com/cloudway/platform/pkg/rest"
+)
+
+// ErrorCode returns the error code of an error response from API server,
+// which is one of the ErrCode constants in the types package or an error
+// specific code. Returns an empty string if the error is not returned
+// from API server.
+func ErrorCode(err error) string {
+	if se, ok := err.(rest.ServerError); ok {
+		return se.Code()
+	}
+	return ""
+}
+
+// ErrorDetails returns the details of an error response from API server.
+func ErrorDetails(err error) map[string]interface{} {
+	if se, ok := err.(rest.ServerError); ok {
+		return se.Details()
+	}
+	return nil
+}
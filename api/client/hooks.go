@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/rest"
+	"golang.org/x/net/context"
+)
+
+func (api *APIClient) ListHooks(ctx context.Context, name string) ([]*types.Webhook, error) {
+	var hooks []*types.Webhook
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/hooks", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&hooks)
+		resp.EnsureClosed()
+	}
+	return hooks, err
+}
+
+func (api *APIClient) CreateHook(ctx context.Context, name string, opts types.CreateWebhook) (*types.Webhook, error) {
+	var hook types.Webhook
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/hooks", nil, &opts, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&hook)
+		resp.EnsureClosed()
+	}
+	return &hook, err
+}
+
+func (api *APIClient) RemoveHook(ctx context.Context, name, id string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/hooks/"+id, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) HookDeliveries(ctx context.Context, name, id string) ([]*types.WebhookDelivery, error) {
+	var deliveries []*types.WebhookDelivery
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/hooks/"+id+"/deliveries", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&deliveries)
+		resp.EnsureClosed()
+	}
+	return deliveries, err
+}
+
+func (api *APIClient) RedeliverHook(ctx context.Context, name, id, delivery string) (*types.WebhookDelivery, error) {
+	var result types.WebhookDelivery
+	resp, err := api.cli.Post(ctx, "/applications/"+name+"/hooks/"+id+"/deliveries/"+delivery+"/redeliver", nil, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// GetPushHook returns the webhook receiving push events from Git hosting
+// services. The secret is regenerated if requested.
+func (api *APIClient) GetPushHook(ctx context.Context, name string, regenerate bool) (*types.PushHook, error) {
+	var resp *rest.ServerResponse
+	var err error
+	if regenerate {
+		resp, err = api.cli.Post(ctx, "/applications/"+name+"/push-hook", nil, nil, nil)
+	} else {
+		resp, err = api.cli.Get(ctx, "/applications/"+name+"/push-hook", nil, nil)
+	}
+
+	var hook types.PushHook
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&hook)
+		resp.EnsureClosed()
+	}
+	return &hook, err
+}
+
+// GetRemote returns the external repository bound to the application, or
+// nil if the application is deployed from the platform repository.
+func (api *APIClient) GetRemote(ctx context.Context, name string) (*types.RemoteRepository, error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/remote", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.EnsureClosed()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	var repo types.RemoteRepository
+	err = json.NewDecoder(resp.Body).Decode(&repo)
+	return &repo, err
+}
+
+func (api *APIClient) BindRemote(ctx context.Context, name string, opts types.BindRepository) (*types.RemoteRepository, error) {
+	var repo types.RemoteRepository
+	resp, err := api.cli.Put(ctx, "/applications/"+name+"/remote", nil, &opts, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&repo)
+		resp.EnsureClosed()
+	}
+	return &repo, err
+}
+
+func (api *APIClient) UnbindRemote(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/applications/"+name+"/remote", nil, nil)
+	resp.EnsureClosed()
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"golang.org/x/net/context"
 	"net/url"
+
+	"github.com/cloudway/platform/api/types"
 )
 
 func (api *APIClient) GetNamespace(ctx context.Context) (namespace string, err error) {
@@ -37,3 +39,76 @@ func (api *APIClient) RemoveNamespace(ctx context.Context, force bool) error {
 	resp.EnsureClosed()
 	return err
 }
+
+// UseNamespace makes subsequent application requests operate on the
+// namespace shared by another user. An empty namespace selects the
+// namespace of the authenticated user.
+func (api *APIClient) UseNamespace(namespace string) {
+	if namespace == "" {
+		api.cli.RemoveCustomHeader(types.NamespaceHeader)
+	} else {
+		api.cli.AddCustomHeader(types.NamespaceHeader, namespace)
+	}
+}
+
+func (api *APIClient) GetMembers(ctx context.Context) ([]*types.TeamMember, error) {
+	var members []*types.TeamMember
+	resp, err := api.cli.Get(ctx, "/namespace/members", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&members)
+		resp.EnsureClosed()
+	}
+	return members, err
+}
+
+// AddMember invites a user to the namespace, or changes the role of an
+// existing member.
+func (api *APIClient) AddMember(ctx context.Context, name, role string) error {
+	resp, err := api.cli.Put(ctx, "/namespace/members/"+name, nil, &types.TeamMember{Role: role}, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) RemoveMember(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/namespace/members/"+name, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetTeams returns namespaces shared with the user by other users.
+func (api *APIClient) GetTeams(ctx context.Context) ([]*types.Team, error) {
+	var teams []*types.Team
+	resp, err := api.cli.Get(ctx, "/namespace/teams", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&teams)
+		resp.EnsureClosed()
+	}
+	return teams, err
+}
+
+// GetNamespaceEnv returns environment variables shared by applications in
+// the namespace.
+func (api *APIClient) GetNamespaceEnv(ctx context.Context) (map[string]string, error) {
+	var env map[string]string
+	resp, err := api.cli.Get(ctx, "/namespace/env", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&env)
+		resp.EnsureClosed()
+	}
+	return env, err
+}
+
+// SetNamespaceEnv sets environment variables inherited by all applications
+// in the namespace.
+func (api *APIClient) SetNamespaceEnv(ctx context.Context, env map[string]string) error {
+	resp, err := api.cli.Put(ctx, "/namespace/env", nil, env, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+func (api *APIClient) UnsetNamespaceEnv(ctx context.Context, keys ...string) error {
+	query := url.Values{"key": keys}
+	resp, err := api.cli.Delete(ctx, "/namespace/env", query, nil)
+	resp.EnsureClosed()
+	return err
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"io"
 	"net/url"
+	"strconv"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
 	"golang.org/x/net/context"
 )
 
@@ -35,6 +38,49 @@ func (api *APIClient) listPlugins(ctx context.Context, category manifest.Categor
 	return plugins, err
 }
 
+// SearchPlugins searches installed plugins, returns matched plugins and
+// the total number of matched plugins before pagination.
+func (api *APIClient) SearchPlugins(ctx context.Context, q types.PluginQuery) ([]*manifest.Plugin, int, error) {
+	query := url.Values{}
+	if q.User {
+		query.Set("user", "1")
+	}
+	if q.Category != "" {
+		query.Set("category", q.Category)
+	}
+	if q.Text != "" {
+		query.Set("q", q.Text)
+	}
+	if q.Version != "" {
+		query.Set("version", q.Version)
+	}
+	if q.Sort != "" {
+		query.Set("sort", q.Sort)
+	}
+	if q.Offset > 0 {
+		query.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	resp, err := api.cli.Get(ctx, "/plugins/", query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.EnsureClosed()
+
+	var plugins []*manifest.Plugin
+	if err = json.NewDecoder(resp.Body).Decode(&plugins); err != nil {
+		return nil, 0, err
+	}
+	total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	if err != nil {
+		total = len(plugins)
+	}
+	return plugins, total, nil
+}
+
 func (api *APIClient) GetPluginInfo(ctx context.Context, tag string) (*manifest.Plugin, error) {
 	resp, err := api.cli.Get(ctx, "/plugins/"+tag, nil, nil)
 	if err != nil {
@@ -47,15 +93,155 @@ func (api *APIClient) GetPluginInfo(ctx context.Context, tag string) (*manifest.
 	return plugin, err
 }
 
-func (api *APIClient) InstallPlugin(ctx context.Context, body io.Reader) error {
-	headers := map[string][]string{"Content-Type": {"application/tar"}}
-	resp, err := api.cli.PostRaw(ctx, "/plugins/", nil, body, headers)
+// GetPluginScan returns vulnerabilities found in the plugin image.
+func (api *APIClient) GetPluginScan(ctx context.Context, tag string) (*types.ImageScan, error) {
+	resp, err := api.cli.Get(ctx, "/plugins/"+tag+"/scan", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var scan types.ImageScan
+	err = json.NewDecoder(resp.Body).Decode(&scan)
 	resp.EnsureClosed()
+	return &scan, err
+}
+
+// InstallPlugin installs a user defined plugin from the plugin archive. If
+// the plugin ships with a Dockerfile, the base image is built with the build
+// arguments and the build output is written to dstout and dsterr.
+func (api *APIClient) InstallPlugin(ctx context.Context, body io.Reader, buildArgs map[string]string, dstout, dsterr io.Writer) error {
+	query := url.Values{}
+	addBuildEnv(query, "build-arg", buildArgs)
+
+	headers := map[string][]string{"Content-Type": {"application/tar"}}
+	resp, err := api.cli.PostRaw(ctx, "/plugins/", query, body, headers)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
 	return err
 }
 
+// ValidatePlugin checks the plugin archive on the server before installation.
+func (api *APIClient) ValidatePlugin(ctx context.Context, body io.Reader) (*types.PluginValidation, error) {
+	headers := map[string][]string{"Content-Type": {"application/tar"}}
+	resp, err := api.cli.PostRaw(ctx, "/plugins/validate", nil, body, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var result types.PluginValidation
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.EnsureClosed()
+	return &result, err
+}
+
 func (api *APIClient) RemovePlugin(ctx context.Context, tag string) error {
 	resp, err := api.cli.Delete(ctx, "/plugins/"+tag, nil, nil)
 	resp.EnsureClosed()
 	return err
 }
+
+// ReloadPlugin replaces the manifest of a user defined plugin and reloads
+// it in running applications without rebuilding containers.
+func (api *APIClient) ReloadPlugin(ctx context.Context, tag string, body io.Reader, dstout, dsterr io.Writer) error {
+	headers := map[string][]string{"Content-Type": {"application/x-yaml"}}
+	resp, err := api.cli.PutRaw(ctx, "/plugins/"+tag+"/manifest", nil, body, headers)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// GetPluginAccess returns access control of a user defined plugin.
+func (api *APIClient) GetPluginAccess(ctx context.Context, name string) (*types.PluginAccess, error) {
+	resp, err := api.cli.Get(ctx, "/plugins/"+name+"/access", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var access types.PluginAccess
+	err = json.NewDecoder(resp.Body).Decode(&access)
+	resp.EnsureClosed()
+	return &access, err
+}
+
+// SetPluginVisibility changes visibility of a user defined plugin to
+// "private", "shared" or "public".
+func (api *APIClient) SetPluginVisibility(ctx context.Context, name, visibility string) error {
+	query := url.Values{}
+	query.Set("visibility", visibility)
+	resp, err := api.cli.Post(ctx, "/plugins/"+name+"/access", query, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GrantPluginAccess grants another namespace access to a user defined plugin.
+func (api *APIClient) GrantPluginAccess(ctx context.Context, name, namespace string) error {
+	resp, err := api.cli.Put(ctx, "/plugins/"+name+"/access/"+namespace, nil, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// RevokePluginAccess revokes access of another namespace to a user defined
+// plugin.
+func (api *APIClient) RevokePluginAccess(ctx context.Context, name, namespace string) error {
+	resp, err := api.cli.Delete(ctx, "/plugins/"+name+"/access/"+namespace, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// UpgradePlugin upgrades applications to the given version of the plugin.
+// If an application name is given then only the application is upgraded.
+func (api *APIClient) UpgradePlugin(ctx context.Context, tag, app string, dstout, dsterr io.Writer) error {
+	query := url.Values{}
+	if app != "" {
+		query.Set("app", app)
+	}
+	resp, err := api.cli.Post(ctx, "/plugins/"+tag+"/upgrade", query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	err = serverlog.Drain(resp.Body, dstout, dsterr, nil)
+	resp.Body.Close()
+	return err
+}
+
+// GetTemplates returns application templates saved in the user's namespace.
+func (api *APIClient) GetTemplates(ctx context.Context) ([]*types.Template, error) {
+	resp, err := api.cli.Get(ctx, "/templates/", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []*types.Template
+	err = json.NewDecoder(resp.Body).Decode(&templates)
+	resp.EnsureClosed()
+	return templates, err
+}
+
+// SaveTemplate saves the application as a template to create new applications.
+func (api *APIClient) SaveTemplate(ctx context.Context, opts types.SaveTemplate) (*types.Template, error) {
+	resp, err := api.cli.Post(ctx, "/templates/", nil, &opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var template types.Template
+	err = json.NewDecoder(resp.Body).Decode(&template)
+	resp.EnsureClosed()
+	return &template, err
+}
+
+// RemoveTemplate removes the application template.
+func (api *APIClient) RemoveTemplate(ctx context.Context, name string) error {
+	resp, err := api.cli.Delete(ctx, "/templates/"+name, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
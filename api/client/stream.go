@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// OpenApplicationLogs returns a reader of the application logs, with the
+// standard output and error of containers merged into a single stream. The
+// stream is ended when the context is cancelled or the reader is closed if
+// the logs are followed.
+func (api *APIClient) OpenApplicationLogs(ctx context.Context, name string, opts types.LogsOptions) (io.ReadCloser, error) {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/logs", logsQuery(opts), nil)
+	if err != nil {
+		return nil, err
+	}
+	return drainPipe(resp.Body), nil
+}
+
+// StreamApplicationStats calls the function with statistics of application
+// containers every time the server sends an update, until the context is
+// cancelled or the function returns an error.
+func (api *APIClient) StreamApplicationStats(ctx context.Context, name string, fn func([]*types.ContainerStats) error) error {
+	resp, err := api.cli.Get(ctx, "/applications/"+name+"/stats", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var stats []*types.ContainerStats
+		err = dec.Decode(&stats)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(stats); err != nil {
+			return err
+		}
+	}
+}
+
+// drainPipe demultiplexes the server log stream into a pipe, the error sent
+// by server is returned from the reader.
+func drainPipe(in io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		err := serverlog.Drain(in, pw, pw, nil)
+		in.Close()
+		pw.CloseWithError(err)
+	}()
+	return &pipeReader{pr, in}
+}
+
+// pipeReader closes the underlying response body when closed, to stop
+// streaming from server.
+type pipeReader struct {
+	*io.PipeReader
+	body io.Closer
+}
+
+func (r *pipeReader) Close() error {
+	r.body.Close()
+	return r.PipeReader.Close()
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/cloudway/platform/api/types"
+	"golang.org/x/net/context"
+)
+
+func (api *APIClient) ListTokens(ctx context.Context) ([]*types.AccessToken, error) {
+	var tokens []*types.AccessToken
+	resp, err := api.cli.Get(ctx, "/users/self/tokens", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&tokens)
+		resp.EnsureClosed()
+	}
+	return tokens, err
+}
+
+func (api *APIClient) CreateToken(ctx context.Context, opts types.CreateToken) (*types.AccessToken, error) {
+	var token types.AccessToken
+	resp, err := api.cli.Post(ctx, "/users/self/tokens", nil, &opts, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&token)
+		resp.EnsureClosed()
+	}
+	return &token, err
+}
+
+func (api *APIClient) RevokeToken(ctx context.Context, id string) error {
+	resp, err := api.cli.Delete(ctx, "/users/self/tokens/"+id, nil, nil)
+	resp.EnsureClosed()
+	return err
+}
+
+// GetQuota returns the quota and resource usage of the current user.
+func (api *APIClient) GetQuota(ctx context.Context) (*types.QuotaUsage, error) {
+	var result types.QuotaUsage
+	resp, err := api.cli.Get(ctx, "/users/self/quota", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// GetTwoFactor returns the two-factor authentication status of the current user.
+func (api *APIClient) GetTwoFactor(ctx context.Context) (*types.TwoFactorStatus, error) {
+	var status types.TwoFactorStatus
+	resp, err := api.cli.Get(ctx, "/users/self/twofactor", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.EnsureClosed()
+	}
+	return &status, err
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the current user.
+func (api *APIClient) EnrollTwoFactor(ctx context.Context) (*types.TwoFactorEnrollment, error) {
+	var result types.TwoFactorEnrollment
+	resp, err := api.cli.Post(ctx, "/users/self/twofactor", nil, nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return &result, err
+}
+
+// EnableTwoFactor enables two-factor authentication with a code generated
+// from the enrolled secret. Returns the recovery codes.
+func (api *APIClient) EnableTwoFactor(ctx context.Context, code string) ([]string, error) {
+	var result types.RecoveryCodes
+	req := types.TwoFactorCode{Code: code}
+	resp, err := api.cli.Put(ctx, "/users/self/twofactor", nil, &req, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.EnsureClosed()
+	}
+	return result.Codes, err
+}
+
+// DisableTwoFactor disables two-factor authentication with a code or a
+// recovery code.
+func (api *APIClient) DisableTwoFactor(ctx context.Context, code string) error {
+	query := url.Values{"code": {code}}
+	resp, err := api.cli.Delete(ctx, "/users/self/twofactor", query, nil)
+	resp.EnsureClosed()
+	return err
+}
@@ -17,6 +17,17 @@ func (api *APIClient) ServerVersion(ctx context.Context) (types.Version, error)
 	return server, err
 }
 
+// GetAPISpec returns the OpenAPI specification of the server API.
+func (api *APIClient) GetAPISpec(ctx context.Context) (json.RawMessage, error) {
+	var spec json.RawMessage
+	resp, err := api.cli.Get(ctx, "/spec", nil, nil)
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&spec)
+		resp.EnsureClosed()
+	}
+	return spec, err
+}
+
 func (cli *APIClient) ClientVersion() string {
 	return cli.cli.ClientVersion()
 }
@@ -1,11 +1,14 @@
 package httputils
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/api/types"
 )
 
 // httpStatusError is an interface that errors with custom status codes
@@ -21,6 +24,62 @@ type inputValidationError interface {
 	IsValidationError() bool
 }
 
+// errorCoder is an interface that errors implement to provide a machine
+// readable error code in the response.
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// errorDetailer is an interface that errors implement to provide details
+// in the response.
+type errorDetailer interface {
+	ErrorDetails() map[string]interface{}
+}
+
+// APIError is an error with HTTP status, error code and details, returned
+// by handlers that need to report errors not defined by other packages.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+// NewAPIError returns an APIError with the formatted message.
+func NewAPIError(status int, code string, format string, args ...interface{}) *APIError {
+	return &APIError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// BadParameter returns an APIError reports an invalid request parameter.
+func BadParameter(format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusBadRequest, types.ErrCodeInvalidParameter, format, args...)
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func (e *APIError) HTTPErrorStatusCode() int {
+	return e.Status
+}
+
+func (e *APIError) ErrorCode() string {
+	return e.Code
+}
+
+func (e *APIError) ErrorDetails() map[string]interface{} {
+	return e.Details
+}
+
+// WithDetails sets a detail of the error.
+func (e *APIError) WithDetails(key string, value interface{}) *APIError {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
 type statusError int
 
 func (se statusError) Error() string {
@@ -51,6 +110,9 @@ func GetHTTPErrorStatusCode(err error) int {
 		statusCode = e.HTTPErrorStatusCode()
 	case inputValidationError:
 		statusCode = http.StatusBadRequest
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		// malformed request body
+		statusCode = http.StatusBadRequest
 	default:
 		// FIXME: this is brittle and should not be necessary, but we still
 		// need to identify if there are errors failling back into this logic.
@@ -83,7 +145,38 @@ func GetHTTPErrorStatusCode(err error) int {
 	return statusCode
 }
 
-// WriteError decodes a specific error and sends it in the response.
+// GetErrorCode returns the error code of the error, or a generic code
+// derived from the HTTP status code.
+func GetErrorCode(err error, statusCode int) string {
+	if e, ok := err.(errorCoder); ok {
+		if code := e.ErrorCode(); code != "" {
+			return code
+		}
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return types.ErrCodeUnauthorized
+	case statusCode == http.StatusForbidden:
+		return types.ErrCodeForbidden
+	case statusCode == http.StatusNotFound:
+		return types.ErrCodeNotFound
+	case statusCode == http.StatusConflict:
+		return types.ErrCodeConflict
+	case statusCode == http.StatusTooManyRequests:
+		return types.ErrCodeTooManyRequests
+	case statusCode == http.StatusServiceUnavailable:
+		return types.ErrCodeUnavailable
+	case statusCode >= 500:
+		return types.ErrCodeInternal
+	default:
+		return types.ErrCodeBadRequest
+	}
+}
+
+// WriteError decodes a specific error and sends it in the response as a
+// JSON encoded ErrorResponse. The message of internal errors is not sent
+// to the client, except that the service is temporarily unavailable.
 func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	if err == nil || w == nil {
 		logrus.WithError(err).Error("unexpected HTTP error handling")
@@ -93,11 +186,17 @@ func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	statusCode := GetHTTPErrorStatusCode(err)
 	serverError := fmt.Sprintf("Handler for %s %s returned error: %v", r.Method, r.URL.Path, err)
 
-	if statusCode >= 500 {
+	resp := types.ErrorResponse{Code: GetErrorCode(err, statusCode)}
+	if statusCode >= 500 && statusCode != http.StatusServiceUnavailable {
 		logrus.Error(serverError)
-		http.Error(w, "Internal server error", statusCode)
+		resp.Message = "Internal server error"
 	} else {
 		logrus.Debug(serverError)
-		http.Error(w, err.Error(), statusCode)
+		resp.Message = err.Error()
+		if e, ok := err.(errorDetailer); ok {
+			resp.Details = e.ErrorDetails()
+		}
 	}
+
+	WriteJSON(w, statusCode, &resp)
 }
@@ -0,0 +1,102 @@
+package httputils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+type codedError struct{}
+
+func (codedError) Error() string            { return "quota exceeded" }
+func (codedError) HTTPErrorStatusCode() int { return http.StatusForbidden }
+func (codedError) ErrorCode() string        { return types.ErrCodeQuotaExceeded }
+func (codedError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{"limit": 2}
+}
+
+func writeError(t *testing.T, err error) (int, *types.ErrorResponse) {
+	req, _ := http.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	WriteError(w, req, err)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var resp types.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("malformed error response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, &resp
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{NewStatusError(http.StatusNotFound), http.StatusNotFound, types.ErrCodeNotFound, "Not Found"},
+		{BadParameter("invalid %s", "name"), http.StatusBadRequest, types.ErrCodeInvalidParameter, "invalid name"},
+		{NewAPIError(http.StatusServiceUnavailable, types.ErrCodeUnavailable, "shutting down"),
+			http.StatusServiceUnavailable, types.ErrCodeUnavailable, "shutting down"},
+		{errors.New("no such container"), http.StatusNotFound, types.ErrCodeNotFound, "no such container"},
+		{errors.New("conflict with existing"), http.StatusConflict, types.ErrCodeConflict, "conflict with existing"},
+		{errors.New("secret database password"), http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error"},
+	}
+
+	for _, test := range tests {
+		status, resp := writeError(t, test.err)
+		if status != test.status || resp.Code != test.code || resp.Message != test.message {
+			t.Errorf("WriteError(%q): found %d %+v, expected %d %q %q",
+				test.err, status, resp, test.status, test.code, test.message)
+		}
+		if resp.Details != nil {
+			t.Errorf("WriteError(%q): unexpected details %v", test.err, resp.Details)
+		}
+	}
+}
+
+func TestWriteErrorDetails(t *testing.T) {
+	status, resp := writeError(t, codedError{})
+	if status != http.StatusForbidden || resp.Code != types.ErrCodeQuotaExceeded {
+		t.Fatalf("found %d %q, expected %d %q", status, resp.Code, http.StatusForbidden, types.ErrCodeQuotaExceeded)
+	}
+	if resp.Details["limit"] != float64(2) {
+		t.Errorf("unexpected details %v", resp.Details)
+	}
+
+	status, resp = writeError(t, BadParameter("invalid size").WithDetails("param", "size"))
+	if status != http.StatusBadRequest || resp.Details["param"] != "size" {
+		t.Errorf("found %d %v, expected %d with param detail", status, resp.Details, http.StatusBadRequest)
+	}
+}
+
+func TestGetErrorCode(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusBadRequest, types.ErrCodeBadRequest},
+		{http.StatusUnauthorized, types.ErrCodeUnauthorized},
+		{http.StatusForbidden, types.ErrCodeForbidden},
+		{http.StatusMethodNotAllowed, types.ErrCodeBadRequest},
+		{http.StatusTooManyRequests, types.ErrCodeTooManyRequests},
+		{http.StatusBadGateway, types.ErrCodeInternal},
+	}
+	for _, test := range tests {
+		if code := GetErrorCode(NewStatusError(test.status), test.status); code != test.code {
+			t.Errorf("GetErrorCode(%d): found %q, expected %q", test.status, code, test.code)
+		}
+	}
+
+	// specific code takes precedence over status
+	if code := GetErrorCode(codedError{}, http.StatusForbidden); code != types.ErrCodeQuotaExceeded {
+		t.Errorf("GetErrorCode: found %q, expected %q", code, types.ErrCodeQuotaExceeded)
+	}
+}
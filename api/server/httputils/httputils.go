@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"mime"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
 	"golang.org/x/net/context"
 )
 
@@ -22,6 +25,9 @@ const APIVersionKey key = 0
 // UseKey is the key for userdb.User values in Contexts.
 const UserKey key = 1
 
+// ScopeKey is the key for the permission required by the requested route.
+const ScopeKey key = 2
+
 // APIFunc is an adapter to allow the use of ordinary functions as API endpoints.
 // Any function that has the appropriate signature can be registered as a API endpoint.
 type APIFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error
@@ -95,3 +101,44 @@ func UserFromContext(ctx context.Context) (user *userdb.BasicUser) {
 	}
 	return val.(*userdb.BasicUser)
 }
+
+// ScopeFromContext returns the permission required by the requested route.
+func ScopeFromContext(ctx context.Context) (scope userdb.Scope) {
+	if ctx == nil {
+		return
+	}
+	val := ctx.Value(ScopeKey)
+	if val == nil {
+		return
+	}
+	return val.(userdb.Scope)
+}
+
+// CheckScope makes sure that the authenticated user is granted the given permission.
+func CheckScope(ctx context.Context, scope userdb.Scope) error {
+	if user := UserFromContext(ctx); user == nil || !user.HasScope(scope) {
+		return NewStatusError(http.StatusForbidden)
+	}
+	return nil
+}
+
+// CheckAdmin makes sure that the authenticated user is an administrator.
+func CheckAdmin(ctx context.Context) error {
+	return CheckScope(ctx, userdb.ScopeAdmin)
+}
+
+// ClientAddr returns the client address of the request. The address in the
+// X-Forwarded-For header is used if ratelimit.trust_proxy is enabled when
+// the API server is behind a reverse proxy.
+func ClientAddr(r *http.Request) string {
+	if ok, _ := strconv.ParseBool(config.Get("ratelimit.trust_proxy")); ok {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/broker"
 	"golang.org/x/net/context"
 )
@@ -15,8 +16,20 @@ type authMiddleware struct {
 	noAuthPattern *regexp.Regexp
 }
 
+// publicPaths matches the API paths that can be accessed without
+// authentication, relative to the context root and API version.
+const publicPaths = "/(version|auth|swagger.json|spec|scm/hooks/|\\.well-known/acme-challenge/)"
+
+var publicPathPattern = regexp.MustCompile("^" + publicPaths)
+
+// IsPublicPath returns true if the route path can be accessed without
+// authentication.
+func IsPublicPath(path string) bool {
+	return publicPathPattern.MatchString(path)
+}
+
 func NewAuthMiddleware(broker *broker.Broker, contextRoot string) authMiddleware {
-	pattern := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/(version|auth|swagger.json)")
+	pattern := regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?" + publicPaths)
 	return authMiddleware{broker, pattern}
 }
 
@@ -26,14 +39,21 @@ func (m authMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc
 			return handler(ctx, w, r, vars)
 		}
 
-		user, err := m.Authz.Verify(r)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return nil
+		// the user may be verified by the rate limit middleware
+		if user := httputils.UserFromContext(ctx); user == nil {
+			user, err := m.Authz.Verify(r)
+			if err != nil {
+				return httputils.NewAPIError(http.StatusUnauthorized, types.ErrCodeUnauthorized, "Invalid or expired access token")
+			}
+
+			logrus.Debugf("Logged in user: %s", user)
+			ctx = context.WithValue(ctx, httputils.UserKey, user)
 		}
 
-		logrus.Debugf("Logged in user: %s", user)
-		ctx = context.WithValue(ctx, httputils.UserKey, user)
+		// Make sure the user is permitted to access the route
+		if err := httputils.CheckScope(ctx, httputils.ScopeFromContext(ctx)); err != nil {
+			return err
+		}
 		return handler(ctx, w, r, vars)
 	}
 }
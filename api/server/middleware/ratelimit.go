@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/ratelimit"
+)
+
+// RateLimitMiddleware limits the request rate of authenticated users and
+// client addresses. The authentication endpoints have stricter limits to
+// prevent brute forcing passwords. It must be registered after the auth
+// middleware so requests are limited before authentication, and requests
+// with invalid credentials are limited by client address. The verified user
+// is passed to the auth middleware in the context.
+type RateLimitMiddleware struct {
+	*broker.Broker
+	authPattern *regexp.Regexp
+	perIP       *ratelimit.TokenBucket
+	perUser     *ratelimit.TokenBucket
+	auth        *ratelimit.TokenBucket
+}
+
+// NewRateLimitMiddleware creates a new RateLimitMiddleware. The limits are
+// configured in requests per minute by ratelimit.per_ip, ratelimit.per_user
+// and ratelimit.auth, and a zero limit disables the rate limiting. The
+// limits are changed when the configuration is reloaded.
+func NewRateLimitMiddleware(broker *broker.Broker, contextRoot string) RateLimitMiddleware {
+	m := RateLimitMiddleware{
+		Broker:      broker,
+		authPattern: regexp.MustCompile("^" + contextRoot + "(/v[0-9.]+)?/auth(/|$)"),
+		perIP:       ratelimit.NewTokenBucket(0, time.Minute),
+		perUser:     ratelimit.NewTokenBucket(0, time.Minute),
+		auth:        ratelimit.NewTokenBucket(0, time.Minute),
+	}
+	m.configure()
+	config.OnReload(m.configure)
+	return m
+}
+
+// configure applies the rate limit configurations.
+func (m RateLimitMiddleware) configure() {
+	setLimit(m.perIP, "ratelimit.per_ip", 600)
+	setLimit(m.perUser, "ratelimit.per_user", 1200)
+	setLimit(m.auth, "ratelimit.auth", 10)
+}
+
+func setLimit(tb *ratelimit.TokenBucket, key string, def int) {
+	limit, err := strconv.Atoi(config.GetOrDefault(key, strconv.Itoa(def)))
+	if err != nil {
+		logrus.WithError(err).Warnf("Invalid %s setting", key)
+		limit = def
+	}
+	tb.SetLimit(limit)
+}
+
+// WrapHandler returns a new handler function wrapping the previous one in the request chain
+func (m RateLimitMiddleware) WrapHandler(handler httputils.APIFunc) httputils.APIFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		var limiter *ratelimit.TokenBucket
+		var key string
+
+		if m.authPattern.MatchString(r.URL.Path) {
+			limiter, key = m.auth, httputils.ClientAddr(r)
+		} else if user, err := m.Authz.Verify(r); err == nil {
+			ctx = context.WithValue(ctx, httputils.UserKey, user)
+			limiter, key = m.perUser, user.Name
+		} else {
+			limiter, key = m.perIP, httputils.ClientAddr(r)
+		}
+
+		ok, remaining, retryAfter := limiter.Take(key)
+		if limit := limiter.Limit(); limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		}
+		if !ok {
+			logrus.Debugf("Rate limit exceeded: %s %s from %s", r.Method, r.URL.Path, key)
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			return httputils.NewAPIError(http.StatusTooManyRequests, types.ErrCodeTooManyRequests,
+				"Too many requests, please try again later").WithDetails("RetryAfter", seconds)
+		}
+		return handler(ctx, w, r, vars)
+	}
+}
@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+	"golang.org/x/net/context"
+)
+
+type adminRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &adminRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.WithScope(router.WithDoc(router.NewGetRoute("/admin/users/", r.listUsers), router.Doc{
+			Summary:  "List users",
+			Response: []*types.UserInfo{},
+		}), userdb.ScopeAdmin),
+		router.WithScope(router.WithDoc(router.NewPostRoute("/admin/users/", r.createUser), router.Doc{
+			Summary:  "Create a user",
+			Body:     types.CreateUser{},
+			Response: types.UserInfo{},
+			Status:   http.StatusCreated,
+		}), userdb.ScopeAdmin),
+		router.WithScope(router.NewGetRoute("/admin/users/export", r.exportUsers), userdb.ScopeAdmin),
+		router.WithScope(router.NewPostRoute("/admin/users/import", r.importUsers), userdb.ScopeAdmin),
+		router.WithScope(router.WithDoc(router.NewGetRoute("/admin/users/{name}", r.getUser), router.Doc{
+			Summary:  "Get user information",
+			Response: types.UserInfo{},
+		}), userdb.ScopeAdmin),
+		router.WithScope(router.NewDeleteRoute("/admin/users/{name}", r.removeUser), userdb.ScopeAdmin),
+		router.WithScope(router.NewPostRoute("/admin/users/{name}/disable", r.disableUser), userdb.ScopeAdmin),
+		router.WithScope(router.NewPostRoute("/admin/users/{name}/enable", r.enableUser), userdb.ScopeAdmin),
+		router.WithScope(router.WithDoc(router.NewPutRoute("/admin/users/{name}/password", r.resetPassword), router.Doc{
+			Summary: "Reset the password of a user",
+			Body:    types.ResetPassword{},
+		}), userdb.ScopeAdmin),
+		router.WithScope(router.NewPutRoute("/admin/users/{name}/namespace", r.setNamespace), userdb.ScopeAdmin),
+		router.WithScope(router.NewGetRoute("/admin/users/{name}/quota", r.getQuota), userdb.ScopeAdmin),
+		router.WithScope(router.NewPutRoute("/admin/users/{name}/quota", r.setQuota), userdb.ScopeAdmin),
+		router.WithScope(router.NewDeleteRoute("/admin/users/{name}/quota", r.resetQuota), userdb.ScopeAdmin),
+		router.WithScope(router.WithDoc(router.NewPostRoute("/admin/gc", r.collectGarbage), router.Doc{
+			Summary:  "Remove orphaned builders, unused images and stale temporary files",
+			Response: types.GCResult{},
+		}), userdb.ScopeAdmin),
+	}
+
+	return r
+}
+
+func (ar *adminRouter) Routes() []router.Route {
+	return ar.routes
+}
+
+func (ar *adminRouter) exportUsers(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=users.json")
+	return ar.Users.Export(w)
+}
+
+func (ar *adminRouter) importUsers(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	var opts userdb.ImportOptions
+	switch r.FormValue("mode") {
+	case "", "merge":
+		opts.Mode = userdb.ImportMerge
+	case "replace":
+		opts.Mode = userdb.ImportReplace
+	default:
+		return httputils.BadParameter("Invalid import mode: %s", r.FormValue("mode"))
+	}
+
+	result, err := ar.Users.Import(r.Body, opts)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.UserImportResult{
+		Imported: result.Imported,
+		Skipped:  result.Skipped,
+	})
+}
+
+func (ar *adminRouter) getQuota(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	quota, usage, err := ar.GetQuota(vars["name"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertQuota(quota, usage))
+}
+
+func (ar *adminRouter) setQuota(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	var req types.Quota
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	quota := &userdb.Quota{
+		Applications: req.Applications,
+		Containers:   req.Containers,
+		Memory:       req.Memory,
+		CPUs:         req.CPUs,
+	}
+	if err := ar.SetQuota(vars["name"], quota); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) resetQuota(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	if err := ar.SetQuota(vars["name"], nil); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertQuota(quota *userdb.Quota, usage *broker.Usage) *types.QuotaUsage {
+	return &types.QuotaUsage{
+		Quota: types.Quota{
+			Applications: quota.Applications,
+			Containers:   quota.Containers,
+			Memory:       quota.Memory,
+			CPUs:         quota.CPUs,
+		},
+		Usage: types.Quota{
+			Applications: usage.Applications,
+			Containers:   usage.Containers,
+			Memory:       usage.Memory,
+			CPUs:         usage.CPUs,
+		},
+	}
+}
+
+func (ar *adminRouter) collectGarbage(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	_, dryRun := r.Form["dry-run"]
+	res, err := ar.DockerClient.CollectGarbage(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.GCResult{
+		DryRun:    res.DryRun,
+		Builders:  res.Builders,
+		Images:    res.Images,
+		TempFiles: res.TempFiles,
+		Errors:    res.Errors,
+	})
+}
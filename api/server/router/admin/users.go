@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+func (ar *adminRouter) listUsers(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	users, err := ar.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.UserInfo, len(users))
+	for i, user := range users {
+		result[i] = convertUser(user)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ar *adminRouter) createUser(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	var req types.CreateUser
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	user := &userdb.BasicUser{Name: req.Name, Namespace: req.Namespace}
+	if req.Role != "" {
+		role, err := userdb.ParseRole(req.Role)
+		if err != nil {
+			return err
+		}
+		user.Role = role
+	}
+
+	if err := ar.CreateUser(user, req.Password); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertUser(user))
+}
+
+func (ar *adminRouter) getUser(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	var user userdb.BasicUser
+	if err := ar.Users.Find(vars["name"], &user); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertUser(&user))
+}
+
+func (ar *adminRouter) removeUser(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	if err := ar.RemoveUser(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) disableUser(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ar.setUserActive(ctx, w, vars["name"], false)
+}
+
+func (ar *adminRouter) enableUser(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ar.setUserActive(ctx, w, vars["name"], true)
+}
+
+func (ar *adminRouter) setUserActive(ctx context.Context, w http.ResponseWriter, name string, active bool) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	if err := ar.SetUserActive(name, active); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) resetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+
+	var req types.ResetPassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := ar.Users.SetPassword(vars["name"], req.Password); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *adminRouter) setNamespace(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckAdmin(ctx); err != nil {
+		return err
+	}
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	if err := ar.SetUserNamespace(vars["name"], r.FormValue("namespace")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertUser(user *userdb.BasicUser) *types.UserInfo {
+	apps := make([]string, 0, len(user.Applications))
+	for name := range user.Applications {
+		apps = append(apps, name)
+	}
+	sort.Strings(apps)
+
+	info := &types.UserInfo{
+		Name:         user.Name,
+		Namespace:    user.Namespace,
+		Role:         string(user.GetRole()),
+		Inactive:     user.Inactive,
+		Applications: apps,
+	}
+	if reg := user.Registration; reg != nil && user.Inactive {
+		if reg.Verified {
+			info.Pending = "approval"
+		} else {
+			info.Pending = "verification"
+		}
+	}
+	return info
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/buildlog"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/container"
@@ -25,6 +26,8 @@ import (
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/serverlog"
 	"github.com/cloudway/platform/scm"
+	"github.com/cloudway/platform/scm/remote"
+	"github.com/cloudway/platform/webhook"
 	"golang.org/x/net/context"
 )
 
@@ -40,34 +43,196 @@ func NewRouter(broker *broker.Broker) router.Router {
 	r := &applicationsRouter{Broker: broker}
 
 	r.routes = []router.Route{
-		router.NewGetRoute("/applications/", r.list),
-		router.NewPostRoute("/applications/", r.create),
-		router.NewGetRoute(appPath, r.info),
+		router.WithDoc(router.NewGetRoute("/applications/", r.list), router.Doc{
+			Summary:  "List application names",
+			Response: []string{},
+		}),
+		router.WithDoc(router.NewPostRoute("/applications/", r.create), router.Doc{
+			Summary:     "Create an application",
+			Description: "Create an application and stream the creation log as plain text",
+			Body:        types.CreateApplication{},
+		}),
+		router.WithDoc(router.NewGetRoute(appPath, r.info), router.Doc{
+			Summary:  "Get application information",
+			Response: types.ApplicationInfo{},
+		}),
 		router.NewDeleteRoute(appPath, r.delete),
+		router.WithDoc(router.NewPostRoute(appPath+"/clone", r.clone), router.Doc{
+			Summary:     "Clone an application",
+			Description: "Create an application with the same plugins and environment except secrets, optionally copy the deployed repository, and stream the creation log as plain text",
+			Body:        types.CloneApplication{},
+		}),
 		router.NewPostRoute(appPath+"/start", r.start),
 		router.NewPostRoute(appPath+"/stop", r.stop),
 		router.NewPostRoute(appPath+"/restart", r.restart),
-		router.NewGetRoute(appPath+"/status", r.status),
+		router.WithDoc(router.NewPostRoute(appPath+"/idle", r.idle), router.Doc{
+			Summary:     "Idle an application",
+			Description: "Stop all containers of a running application to save resources until resumed",
+		}),
+		router.WithDoc(router.NewPostRoute(appPath+"/resume", r.resume), router.Doc{
+			Summary:     "Resume an idle application",
+			Description: "Start all containers of an idle application in dependency order and stream the start log as plain text",
+		}),
+		router.WithDoc(router.NewGetRoute(appPath+"/status", r.status), router.Doc{
+			Summary:  "Get status of application containers",
+			Response: []*types.ContainerStatus{},
+		}),
 		router.NewGetRoute("/applications/status/", r.allStatus),
+		router.NewPostRoute("/applications/bulk/", r.bulk),
 		router.NewGetRoute(appPath+"/procs", r.procs),
 		router.NewGetRoute(appPath+"/stats", r.stats),
-		router.NewPostRoute(appPath+"/deploy", r.deploy),
+		router.NewGetRoute(appPath+"/volumes", r.volumes),
+		router.Cancellable(router.NewGetRoute(appPath+"/logs", r.logs)),
+		router.WithDoc(router.NewGetRoute(appPath+"/logs/search", r.searchLogs), router.Doc{
+			Summary:     "Search application logs",
+			Description: "Search logs of the application aggregated in the log store, the most recent last.",
+			Params: []router.Param{
+				router.QueryParam("q", "Terms contained in log messages"),
+				router.QueryParam("since", "Show logs since timestamp or relative time (e.g. 10m)"),
+				router.QueryParam("until", "Show logs until timestamp or relative time"),
+				router.QueryParam("severity", "The minimum severity: debug, info, warning, error or fatal"),
+				router.QueryParam("limit", "The maximum number of most recent entries"),
+			},
+			Response: []types.LogEntry{},
+		}),
+		router.NewPostRoute(appPath+"/exec", r.exec),
+		router.NewGetRoute(appPath+"/exec/{id}", r.inspectExec),
+		router.NewPostRoute(appPath+"/exec/{id}/resize", r.resizeExec),
+		router.Cancellable(router.NewPostRoute(appPath+"/deploy", r.deploy)),
 		router.NewGetRoute(appPath+"/deploy", r.getDeployments),
-		router.NewGetRoute(appPath+"/repo", r.download),
-		router.NewPutRoute(appPath+"/repo", r.upload),
-		router.NewGetRoute(appPath+"/data", r.dump),
+		router.NewPostRoute(appPath+"/rollback", r.rollback),
+		router.NewPostRoute(appPath+"/canary/promote", r.promoteCanary),
+		router.NewPostRoute(appPath+"/canary/abort", r.abortCanary),
+		router.NewGetRoute(appPath+"/history", r.getHistory),
+		router.NewGetRoute(appPath+"/builds", r.getBuilds),
+		router.NewDeleteRoute(appPath+"/builds/current", r.cancelBuild),
+		router.NewDeleteRoute(appPath+"/builds/cache", r.clearBuildCache),
+		router.NewGetRoute(appPath+"/builds/lock", r.getBuildLock),
+		router.NewGetRoute("/builds/{id}/log", r.getBuildLog),
+		router.WithDoc(router.NewGetRoute("/deployments/{id}", r.getDeployment), router.Doc{
+			Summary:     "Get deployment status",
+			Description: "Get the status of a deployment submitted by uploading repository asynchronously",
+			Response:    types.Build{},
+		}),
+		router.WithScope(router.NewGetRoute(appPath+"/repo", r.download), userdb.ScopeWrite),
+		router.WithDoc(router.Cancellable(router.NewPutRoute(appPath+"/repo", r.upload)), router.Doc{
+			Summary: "Upload and deploy application repository",
+			Description: "Deploy the uploaded repository archive and stream the deployment log, " +
+				"or queue the deployment and return the build immediately if async is specified. " +
+				"Nothing is deployed if the latest deployment has the same content.",
+			Params: []router.Param{
+				router.QueryParam("binary", "The archive contains prebuilt repository"),
+				router.QueryParam("async", "Queue the deployment and return immediately"),
+			},
+			Response: types.Build{},
+		}),
+		router.WithScope(router.NewGetRoute(appPath+"/data", r.dump), userdb.ScopeWrite),
 		router.NewPutRoute(appPath+"/data", r.restore),
+		router.NewGetRoute(appPath+"/files", r.listFiles),
+		router.WithScope(router.NewGetRoute(appPath+"/files/archive", r.downloadFiles), userdb.ScopeWrite),
+		router.Cancellable(router.NewPutRoute(appPath+"/files/archive", r.uploadFiles)),
+		router.NewPostRoute(appPath+"/backup", r.backup),
+		router.NewGetRoute(appPath+"/backups", r.listBackups),
+		router.NewPostRoute(appPath+"/restore", r.restoreBackup),
+		router.NewGetRoute(appPath+"/cron/runs", r.cronRuns),
 		router.NewPostRoute(appPath+"/scale", r.scale),
+		router.NewGetRoute(appPath+"/resources", r.getResources),
+		router.NewPutRoute(appPath+"/resources", r.setResources),
+		router.NewGetRoute(appPath+"/logging", r.getLogConfig),
+		router.WithDoc(router.NewPutRoute(appPath+"/logging", r.setLogConfig), router.Doc{
+			Summary: "Change log driver of an application",
+			Description: "Override the log driver and options of the application containers, " +
+				"applied to containers created later. An empty driver restores the default configuration.",
+			Body: types.LogConfig{},
+		}),
+		router.NewGetRoute(appPath+"/network", r.getNetworkGrants),
+		router.WithDoc(router.NewPutRoute(appPath+"/network/grants/{app}", r.grantNetworkAccess), router.Doc{
+			Summary: "Grant network access to application services",
+			Description: "Connect framework containers of another application in the namespace to the " +
+				"application network, so services of the application are reachable from them.",
+		}),
+		router.NewDeleteRoute(appPath+"/network/grants/{app}", r.revokeNetworkAccess),
+		router.WithScope(router.NewGetRoute(appPath+"/hooks", r.listHooks), userdb.ScopeWrite),
+		router.NewPostRoute(appPath+"/hooks", r.createHook),
+		router.NewDeleteRoute(appPath+"/hooks/{id}", r.removeHook),
+		router.WithScope(router.NewGetRoute(appPath+"/hooks/{id}/deliveries", r.hookDeliveries), userdb.ScopeWrite),
+		router.NewPostRoute(appPath+"/hooks/{id}/deliveries/{delivery}/redeliver", r.redeliverHook),
+		router.WithScope(router.NewGetRoute(appPath+"/push-hook", r.getPushHook), userdb.ScopeWrite),
+		router.NewPostRoute(appPath+"/push-hook", r.regeneratePushHook),
+		router.NewGetRoute(appPath+"/remote", r.getRemote),
+		router.NewPutRoute(appPath+"/remote", r.bindRemote),
+		router.NewDeleteRoute(appPath+"/remote", r.unbindRemote),
+		router.NewGetRoute(appPath+"/domains", r.listDomains),
+		router.NewPostRoute(appPath+"/domains", r.addDomain),
+		router.NewDeleteRoute(appPath+"/domains/{domain}", r.removeDomain),
+		router.NewPutRoute(appPath+"/domains/{domain}/certificate", r.uploadCertificate),
+		router.NewDeleteRoute(appPath+"/domains/{domain}/certificate", r.removeCertificate),
+		router.NewGetRoute(appPath+"/secrets", r.listSecrets),
+		router.NewGetRoute(appPath+"/secrets/audit", r.secretAudit),
+		router.NewPutRoute(appPath+"/secrets/{key}", r.setSecret),
+		router.NewPostRoute(appPath+"/secrets/{key}/rotate", r.rotateSecret),
+		router.NewDeleteRoute(appPath+"/secrets/{key}", r.removeSecret),
+		router.WithScope(router.NewGetRoute(appPath+"/env", r.getenvAll), userdb.ScopeWrite),
+		router.NewPutRoute(appPath+"/env", r.setenvAll),
+		router.NewDeleteRoute(appPath+"/env", r.unsetenv),
 		router.NewPostRoute(appPath+"/services/", r.createService),
 		router.NewDeleteRoute(servicePath, r.removeService),
-		router.NewGetRoute(servicePath+"/env/", r.environ),
+		router.WithScope(router.NewGetRoute(servicePath+"/env/", r.environ), userdb.ScopeWrite),
 		router.NewPostRoute(servicePath+"/env/", r.setenv),
-		router.NewGetRoute(servicePath+"/env/{key:.*}", r.getenv),
+		router.WithScope(router.NewGetRoute(servicePath+"/env/{key:.*}", r.getenv), userdb.ScopeWrite),
+	}
+
+	for i, route := range r.routes {
+		r.routes[i] = router.WithHandler(route, r.team(teamRole(route), route.Handler()))
 	}
 
 	return r
 }
 
+// deployRoutes are the routes permitted to team members with the deployer
+// role. Other routes require the owner role unless they're read-only.
+var deployRoutes = map[string]bool{
+	"POST " + appPath + "/start":            true,
+	"POST " + appPath + "/stop":             true,
+	"POST " + appPath + "/restart":          true,
+	"POST " + appPath + "/deploy":           true,
+	"POST " + appPath + "/rollback":         true,
+	"POST " + appPath + "/canary/promote":   true,
+	"POST " + appPath + "/canary/abort":     true,
+	"POST " + appPath + "/scale":            true,
+	"PUT " + appPath + "/repo":              true,
+	"DELETE " + appPath + "/builds/current": true,
+	"POST /applications/bulk/":              true,
+}
+
+// teamRole returns the role required for team members to access the route.
+func teamRole(route router.Route) userdb.TeamRole {
+	switch {
+	case route.Scope() == userdb.ScopeRead:
+		return userdb.TeamViewer
+	case deployRoutes[route.Method()+" "+route.Path()]:
+		return userdb.TeamDeployer
+	default:
+		return userdb.TeamOwner
+	}
+}
+
+// team wraps the handler to operate on applications in the namespace shared
+// by another user if requested by the namespace header. The handler sees
+// the namespace owner as the authenticated user.
+func (ar *applicationsRouter) team(role userdb.TeamRole, handler httputils.APIFunc) httputils.APIFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		if namespace := r.Header.Get(types.NamespaceHeader); namespace != "" {
+			owner, err := ar.ResolveNamespace(httputils.UserFromContext(ctx), namespace, role)
+			if err != nil {
+				return err
+			}
+			ctx = context.WithValue(ctx, httputils.UserKey, owner)
+		}
+		return handler(ctx, w, r, vars)
+	}
+}
+
 func (ar *applicationsRouter) Routes() []router.Route {
 	return ar.routes
 }
@@ -109,8 +274,30 @@ func (ar *applicationsRouter) info(ctx context.Context, w http.ResponseWriter, r
 		return err
 	}
 
-	cs, _ := ar.FindApplications(ctx, name, user.Namespace)
-	info.Scaling = len(cs)
+	info.State = string(app.CurrentState())
+	info.StateChanged = app.StateChanged
+
+	cs, _ := ar.FindAll(ctx, name, user.Namespace)
+	info.Status = ar.containerStatus(ctx, cs)
+	for _, c := range cs {
+		if c.Category().IsFramework() {
+			info.Scaling++
+		}
+		if c.IsCrashLooping() {
+			info.Degraded = true
+		}
+		if h := container.HealthOf(c.ID); h != nil {
+			ch := &types.ContainerHealth{
+				Status:    h.Status,
+				Failures:  h.Failures,
+				Restarts:  h.Restarts,
+				LastCheck: h.LastCheck,
+				Error:     h.Error,
+			}
+			ar.initContainerJSON(c, &ch.ContainerJSONBase)
+			info.Health = append(info.Health, ch)
+		}
+	}
 
 	return httputils.WriteJSON(w, http.StatusOK, &info)
 }
@@ -121,6 +308,8 @@ func (ar *applicationsRouter) getInfo(name, namespace string, app *userdb.Applic
 		Namespace: namespace,
 		CreatedAt: app.CreatedAt,
 		Scaling:   1,
+		TimeZone:  app.TimeZone,
+		Locale:    app.Locale,
 	}
 
 	base, err := url.Parse(config.GetOrDefault("console.url", "http://api."+defaults.Domain()))
@@ -177,27 +366,80 @@ func (ar *applicationsRouter) create(ctx context.Context, w http.ResponseWriter,
 	}
 
 	opts := container.CreateOptions{
-		Name:    req.Name,
-		Repo:    req.Repo,
-		Scaling: 1,
-		Log:     serverlog.New(w),
+		Name:     req.Name,
+		Repo:     req.Repo,
+		TimeZone: req.TimeZone,
+		Locale:   req.Locale,
+		Scaling:  1,
+		Log:      serverlog.NewResponse(w, r),
 	}
 
 	if !namePattern.MatchString(opts.Name) {
-		msg := "The application name can only contains lower case letters, digits or underscores."
-		http.Error(w, msg, http.StatusBadRequest)
+		return httputils.BadParameter("The application name can only contains lower case letters, digits or underscores.")
+	}
+
+	if req.Framework == "" && req.Template == "" {
+		return httputils.BadParameter("The application framework cannot be empty.")
+	}
+
+	if err := container.ValidateTimeZone(req.TimeZone); err != nil {
+		return err
+	}
+	if err := container.ValidateLocale(req.Locale); err != nil {
+		return err
+	}
+
+	var app *userdb.Application
+	var cs []*container.Container
+	var err error
+	if req.Template != "" {
+		app, cs, err = br.CreateFromTemplate(opts, req.Template)
+	} else {
+		tags := append([]string{req.Framework}, req.Services...)
+		app, cs, err = br.CreateApplication(opts, tags)
+	}
+	if err != nil {
+		serverlog.SendError(w, err)
 		return nil
 	}
 
-	if req.Framework == "" {
-		msg := "The application framework cannot be empty."
-		http.Error(w, msg, http.StatusBadRequest)
+	if err = br.StartContainers(ctx, cs, opts.Log); err != nil {
+		serverlog.SendError(w, err)
 		return nil
 	}
 
-	tags := append([]string{req.Framework}, req.Services...)
+	if info, err := ar.getInfo(req.Name, user.Namespace, app); err != nil {
+		serverlog.SendError(w, err)
+	} else {
+		serverlog.SendObject(w, info)
+	}
+
+	return nil
+}
+
+func (ar *applicationsRouter) clone(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	var req types.CloneApplication
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if !namePattern.MatchString(req.Name) {
+		return httputils.BadParameter("The application name can only contains lower case letters, digits or underscores.")
+	}
+
+	opts := container.CreateOptions{
+		Name: req.Name,
+		Log:  serverlog.NewResponse(w, r),
+	}
 
-	app, cs, err := br.CreateApplication(opts, tags)
+	app, cs, err := br.CloneApplication(vars["name"], opts, req.Repo)
 	if err != nil {
 		serverlog.SendError(w, err)
 		return nil
@@ -244,7 +486,7 @@ func (ar *applicationsRouter) createService(ctx context.Context, w http.Response
 
 	opts := container.CreateOptions{
 		Name: vars["name"],
-		Log:  serverlog.New(w),
+		Log:  serverlog.NewResponse(w, r),
 	}
 
 	cs, err := br.CreateServices(opts, tags)
@@ -276,7 +518,7 @@ func (ar *applicationsRouter) removeService(ctx context.Context, w http.Response
 
 func (ar *applicationsRouter) start(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	err := ar.NewUserBroker(user, ctx).StartApplication(vars["name"], serverlog.New(w))
+	err := ar.NewUserBroker(user, ctx).StartApplication(vars["name"], serverlog.NewResponse(w, r))
 	if err != nil {
 		serverlog.SendError(w, err)
 	}
@@ -290,7 +532,21 @@ func (ar *applicationsRouter) stop(ctx context.Context, w http.ResponseWriter, r
 
 func (ar *applicationsRouter) restart(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	err := ar.NewUserBroker(user, ctx).RestartApplication(vars["name"], serverlog.New(w))
+	err := ar.NewUserBroker(user, ctx).RestartApplication(vars["name"], serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) idle(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).IdleApplication(vars["name"])
+}
+
+func (ar *applicationsRouter) resume(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	err := ar.NewUserBroker(user, ctx).ResumeApplication(vars["name"], serverlog.NewResponse(w, r))
 	if err != nil {
 		serverlog.SendError(w, err)
 	}
@@ -345,6 +601,34 @@ func (ar *applicationsRouter) allStatus(ctx context.Context, w http.ResponseWrit
 	return httputils.WriteJSON(w, http.StatusOK, status)
 }
 
+func (ar *applicationsRouter) bulk(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.BulkOptions
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	sel := broker.Selector{
+		Namespace: req.Namespace,
+		Category:  req.Category,
+		Labels:    req.Labels,
+	}
+
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	results, err := br.BulkOperation(sel, req.Operation, req.Concurrency, serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
+	} else {
+		serverlog.SendObject(w, results)
+	}
+	return nil
+}
+
 func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace string) ([]*types.ContainerStatus, error) {
 	cs, err := ar.FindAll(ctx, name, namespace)
 	if err != nil {
@@ -353,7 +637,10 @@ func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace str
 	if len(cs) == 0 {
 		return nil, broker.ApplicationNotFoundError(name)
 	}
+	return ar.containerStatus(ctx, cs), nil
+}
 
+func (ar *applicationsRouter) containerStatus(ctx context.Context, cs []*container.Container) []*types.ContainerStatus {
 	status := make([]*types.ContainerStatus, len(cs))
 	for i, c := range cs {
 		st := &types.ContainerStatus{}
@@ -361,7 +648,12 @@ func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace str
 		status[i] = st
 
 		st.IPAddress = c.IP()
+		st.IPv6Address = c.IPv6()
 		st.State = c.ActiveState(ctx)
+		st.Restarts = c.RestartCount
+		if e := container.LastExitOf(c.ID); e != nil {
+			st.LastExit = &types.ContainerExit{ExitCode: e.ExitCode, OOMKilled: e.OOMKilled, Time: e.Time}
+		}
 		if plugin != nil {
 			st.Ports = plugin.GetPrivatePorts()
 		}
@@ -371,7 +663,7 @@ func (ar *applicationsRouter) getStatus(ctx context.Context, name, namespace str
 			st.Uptime = int64(time.Now().UTC().Sub(started))
 		}
 	}
-	return status, nil
+	return status
 }
 
 func (ar *applicationsRouter) initContainerJSON(c *container.Container, data *types.ContainerJSONBase) *manifest.Plugin {
@@ -418,12 +710,35 @@ func (ar *applicationsRouter) procs(ctx context.Context, w http.ResponseWriter,
 
 	procs := make([]*types.ProcessList, 0, len(cs))
 	for _, c := range cs {
-		if top, err := c.ContainerTop(ctx, c.ID, nil); err == nil {
-			proc := &types.ProcessList{}
-			ar.initContainerJSON(c, &proc.ContainerJSONBase)
-			proc.Processes = top.Processes
-			proc.Headers = top.Titles
-			procs = append(procs, proc)
+		proc := &types.ProcessList{}
+		ar.initContainerJSON(c, &proc.ContainerJSONBase)
+		procs = append(procs, proc)
+
+		top, err := c.ContainerTop(ctx, c.ID, nil)
+		if err != nil {
+			proc.Error = err.Error()
+			continue
+		}
+		proc.Headers = top.Titles
+		proc.Processes = top.Processes
+
+		pl, err := container.ParseProcessList(top)
+		if err != nil {
+			proc.Error = err.Error()
+			continue
+		}
+		proc.Parsed = make([]*types.Process, len(pl.Processes))
+		for i, p := range pl.Processes {
+			proc.Parsed[i] = &types.Process{
+				User:    p.User,
+				PID:     p.PID,
+				PPID:    p.PPID,
+				CPU:     p.CPU,
+				Start:   p.Start,
+				TTY:     p.TTY,
+				Time:    p.Time,
+				Command: p.Command,
+			}
 		}
 	}
 	return httputils.WriteJSON(w, http.StatusOK, procs)
@@ -436,6 +751,14 @@ func (ar *applicationsRouter) stats(ctx context.Context, w http.ResponseWriter,
 		name = vars["name"]
 	)
 
+	if stream := r.FormValue("stream"); stream == "false" || stream == "0" {
+		stats, err := br.StatsSnapshot(ctx, name)
+		if err != nil {
+			return err
+		}
+		return httputils.WriteJSON(w, http.StatusOK, stats)
+	}
+
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	err := br.Stats(ctx, name, w)
 	if err != nil {
@@ -445,11 +768,83 @@ func (ar *applicationsRouter) stats(ctx context.Context, w http.ResponseWriter,
 	return nil
 }
 
+func (ar *applicationsRouter) logs(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	opts := types.LogsOptions{
+		Follow: r.FormValue("follow") == "true" || r.FormValue("follow") == "1",
+		Tail:   r.FormValue("tail"),
+		Since:  r.FormValue("since"),
+	}
+
+	err := ar.NewUserBroker(user, ctx).Logs(vars["name"], opts, serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) volumes(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var (
+		user = httputils.UserFromContext(ctx)
+		br   = ar.NewUserBroker(user, ctx)
+		name = vars["name"]
+	)
+
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	cs, err := ar.FindAll(ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	if len(cs) == 0 {
+		return broker.ApplicationNotFoundError(name)
+	}
+
+	result := make([]*types.ContainerVolumes, 0, len(cs))
+	for _, c := range cs {
+		volumes, err := c.Volumes(ctx)
+		if err != nil {
+			return err
+		}
+		if len(volumes) == 0 {
+			continue
+		}
+
+		vols := &types.ContainerVolumes{}
+		ar.initContainerJSON(c, &vols.ContainerJSONBase)
+		vols.Volumes = make([]*types.Volume, len(volumes))
+		for i, v := range volumes {
+			vols.Volumes[i] = &types.Volume{
+				Name:       v.Name,
+				VolumeName: v.VolumeName,
+				MountPath:  v.MountPath,
+				Driver:     v.Driver,
+				Size:       v.Size,
+				Used:       v.Used,
+			}
+		}
+		result = append(result, vols)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
 func (ar *applicationsRouter) deploy(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	name, branch := vars["name"], r.FormValue("branch")
+	name, ref := vars["name"], r.FormValue("ref")
+	if ref == "" {
+		ref = r.FormValue("branch")
+	}
+	opts, err := container.ParseDeployOptions(r.Form)
+	if err != nil {
+		return err
+	}
+	if err = opts.ParseBuildEnv(r.Header.Get(types.BuildEnvHeader)); err != nil {
+		return err
+	}
 
-	err := ar.SCM.Deploy(user.Namespace, name, branch, serverlog.New(w))
+	err = ar.NewUserBroker(user, ctx).Deploy(name, ref, opts, serverlog.NewResponse(w, r))
 	if err != nil {
 		serverlog.SendError(w, err)
 	}
@@ -460,6 +855,10 @@ func (ar *applicationsRouter) getDeployments(ctx context.Context, w http.Respons
 	user := httputils.UserFromContext(ctx)
 	name := vars["name"]
 
+	if err := ar.NewUserBroker(user, ctx).Refresh(); err != nil {
+		return err
+	}
+
 	current, err := ar.SCM.GetDeploymentBranch(user.Namespace, name)
 	if err != nil {
 		return err
@@ -477,120 +876,693 @@ func (ar *applicationsRouter) getDeployments(ctx context.Context, w http.Respons
 	return httputils.WriteJSON(w, http.StatusOK, &resp)
 }
 
-func convertBranchJson(br *scm.Branch) *types.Branch {
-	return &types.Branch{
-		Id:        br.Id,
-		DisplayId: br.DisplayId,
-		Type:      br.Type,
+func (ar *applicationsRouter) rollback(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var version int
+	if v := r.FormValue("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version <= 0 {
+			return httputils.BadParameter("Invalid version: %s", v)
+		}
 	}
-}
 
-func convertBranchesJson(branches []*scm.Branch) []*types.Branch {
-	result := make([]*types.Branch, len(branches))
-	for i := range branches {
-		result[i] = convertBranchJson(branches[i])
+	_, err := ar.NewUserBroker(user, ctx).Rollback(vars["name"], version, serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
 	}
-	return result
+	return nil
 }
 
-func (ar *applicationsRouter) download(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (ar *applicationsRouter) promoteCanary(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
 
-	tr, err := ar.NewUserBroker(user, ctx).Download(vars["name"])
+	err := ar.NewUserBroker(user, ctx).PromoteCanary(vars["name"], serverlog.NewResponse(w, r))
 	if err != nil {
-		return err
+		serverlog.SendError(w, err)
 	}
-	defer tr.Close()
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/tar+gzip") // TODO: parse Accept header
-	w.WriteHeader(http.StatusOK)
+func (ar *applicationsRouter) abortCanary(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
 
-	zw := gzip.NewWriter(w)
-	if _, err = io.Copy(zw, tr); err == nil {
-		err = zw.Close()
+	err := ar.NewUserBroker(user, ctx).AbortCanary(vars["name"], serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
 	}
-	return err
+	return nil
 }
 
-func (ar *applicationsRouter) upload(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	if err := httputils.ParseForm(r); err != nil {
+func (ar *applicationsRouter) getHistory(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	versions, current, err := ar.NewUserBroker(user, ctx).GetDeploymentHistory(vars["name"])
+	if err != nil {
 		return err
 	}
 
+	resp := make([]*types.DeploymentVersion, len(versions))
+	for i, v := range versions {
+		resp[i] = &types.DeploymentVersion{
+			Version: v.Version,
+			Created: v.Created,
+			Size:    v.Size,
+			Current: v.Version == current,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) getBuilds(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	_, binary := r.Form["binary"]
 
-	err := ar.NewUserBroker(user, ctx).Upload(vars["name"], r.Body, binary, serverlog.New(w))
+	var offset, limit int
+	if v := r.FormValue("offset"); v != "" {
+		var err error
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return httputils.BadParameter("Invalid offset: %s", v)
+		}
+	}
+	if v := r.FormValue("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return httputils.BadParameter("Invalid limit: %s", v)
+		}
+	}
+
+	builds, err := ar.NewUserBroker(user, ctx).GetBuilds(vars["name"])
 	if err != nil {
-		serverlog.SendError(w, err)
+		return err
 	}
-	return nil
+	if offset > len(builds) {
+		offset = len(builds)
+	}
+	builds = builds[offset:]
+	if limit > 0 && limit < len(builds) {
+		builds = builds[:limit]
+	}
+
+	resp := make([]*types.Build, len(builds))
+	for i, b := range builds {
+		resp[i] = convertBuildJson("", b)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
 }
 
-func (ar *applicationsRouter) dump(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (ar *applicationsRouter) getDeployment(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
 
-	tr, err := ar.NewUserBroker(user, ctx).Dump(vars["name"])
+	name, build, err := ar.NewUserBroker(user, ctx).GetDeployment(vars["id"])
 	if err != nil {
 		return err
 	}
-	defer tr.Close()
-
-	w.Header().Set("Content-Type", "application/tar+gzip")
-	w.WriteHeader(http.StatusOK)
+	return httputils.WriteJSON(w, http.StatusOK, convertBuildJson(name, build))
+}
 
-	zw := gzip.NewWriter(w)
-	if _, err = io.Copy(zw, tr); err == nil {
-		err = zw.Close()
-	}
-	return err
+func convertBuildJson(name string, b *buildlog.Build) *types.Build {
+	build := &types.Build{
+		ID:          b.ID,
+		Application: name,
+		Strategy:    b.Strategy,
+		Started:     b.Started,
+		Finished:    b.Finished,
+		Status:      b.Status,
+		Error:       b.Error,
+		Digest:      b.Digest,
+	}
+	for _, c := range b.Containers {
+		build.Containers = append(build.Containers, &types.ContainerDeployment{
+			ID:      c.ID,
+			Event:   c.Event,
+			Bytes:   c.Bytes,
+			Error:   c.Error,
+			Updated: c.Updated,
+		})
+	}
+	return build
 }
 
-func (ar *applicationsRouter) restore(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (ar *applicationsRouter) cancelBuild(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	return ar.NewUserBroker(user, ctx).Restore(vars["name"], r.Body)
+
+	if err := ar.NewUserBroker(user, ctx).CancelDeployment(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
-func (ar *applicationsRouter) scale(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (ar *applicationsRouter) clearBuildCache(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	name := vars["name"]
-	scaling := r.FormValue("scale")
 
-	var up, down bool
-	if strings.HasPrefix(scaling, "+") {
-		up = true
-		scaling = scaling[1:]
-	} else if strings.HasPrefix(scaling, "-") {
-		down = true
-		scaling = scaling[1:]
+	if err := ar.NewUserBroker(user, ctx).ClearBuildCache(vars["name"]); err != nil {
+		return err
 	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
 
-	num, err := strconv.Atoi(scaling)
+func (ar *applicationsRouter) getBuildLock(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	lock, err := ar.NewUserBroker(user, ctx).GetBuildLock(vars["name"])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return nil
+		return err
 	}
 
-	if up || down {
-		cs, err := ar.FindApplications(ctx, name, user.Namespace)
-		if err != nil {
-			return err
-		}
-		if up {
-			num = len(cs) + num
-		} else {
-			num = len(cs) - num
+	var resp types.BuildLock
+	if lock != nil {
+		resp = types.BuildLock{
+			Locked:    true,
+			Operation: lock.Operation,
+			User:      lock.User,
+			Acquired:  lock.Acquired,
+			Waiting:   lock.Waiting,
 		}
 	}
+	return httputils.WriteJSON(w, http.StatusOK, &resp)
+}
 
-	br := ar.NewUserBroker(user, ctx)
-	cs, err := br.ScaleApplication(name, num)
+func (ar *applicationsRouter) getBuildLog(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	log, err := ar.NewUserBroker(user, ctx).OpenBuildLog(vars["id"])
 	if err != nil {
 		return err
 	}
+	defer log.Close()
 
-	err = br.StartContainers(ctx, cs, serverlog.New(w))
-	if err != nil {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, log)
+	return err
+}
+
+func (ar *applicationsRouter) backup(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	b, err := ar.NewUserBroker(user, ctx).Backup(vars["name"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, &types.Backup{ID: b.ID, Created: b.Created, Size: b.Size})
+}
+
+func (ar *applicationsRouter) listBackups(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	backups, err := ar.NewUserBroker(user, ctx).ListBackups(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Backup, len(backups))
+	for i, b := range backups {
+		resp[i] = &types.Backup{ID: b.ID, Created: b.Created, Size: b.Size}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) restoreBackup(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	_, err := ar.NewUserBroker(user, ctx).RestoreBackup(vars["name"], r.FormValue("backup"), serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) cronRuns(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	runs, err := ar.NewUserBroker(user, ctx).GetCronRuns(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.CronRun, len(runs))
+	for i, run := range runs {
+		resp[i] = &types.CronRun{
+			ID:        run.ID,
+			Job:       run.Job,
+			Service:   run.Service,
+			Container: run.Container,
+			Schedule:  run.Schedule,
+			Command:   run.Command,
+			Started:   run.Started,
+			Finished:  run.Finished,
+			ExitCode:  run.ExitCode,
+			Error:     run.Error,
+			Output:    run.Output,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) listHooks(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	hooks, err := ar.NewUserBroker(user, ctx).ListHooks(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Webhook, len(hooks))
+	for i, h := range hooks {
+		resp[i] = convertHookJson(h)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) createHook(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.CreateWebhook
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	hook, err := ar.NewUserBroker(user, ctx).AddHook(vars["name"], req.URL, req.Events, req.Secret)
+	if err != nil {
+		return err
+	}
+
+	resp := convertHookJson(hook)
+	resp.Secret = hook.Secret
+	return httputils.WriteJSON(w, http.StatusCreated, resp)
+}
+
+func (ar *applicationsRouter) removeHook(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).RemoveHook(vars["name"], vars["id"])
+}
+
+func (ar *applicationsRouter) hookDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	deliveries, err := ar.NewUserBroker(user, ctx).HookDeliveries(vars["name"], vars["id"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		resp[i] = convertDeliveryJson(d)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) redeliverHook(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	d, err := ar.NewUserBroker(user, ctx).RedeliverHook(vars["name"], vars["id"], vars["delivery"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertDeliveryJson(d))
+}
+
+func (ar *applicationsRouter) getPushHook(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ar.pushHook(ctx, w, vars, false)
+}
+
+func (ar *applicationsRouter) regeneratePushHook(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return ar.pushHook(ctx, w, vars, true)
+}
+
+func (ar *applicationsRouter) pushHook(ctx context.Context, w http.ResponseWriter, vars map[string]string, regenerate bool) error {
+	user := httputils.UserFromContext(ctx)
+
+	secret, err := ar.NewUserBroker(user, ctx).GetPushSecret(vars["name"], regenerate)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.PushHook{
+		Path:   "/scm/hooks/" + user.Namespace + "/" + vars["name"],
+		Secret: secret,
+	})
+}
+
+func (ar *applicationsRouter) getRemote(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	repo, err := ar.NewUserBroker(user, ctx).GetRepository(vars["name"])
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return writeRemoteRepository(w, repo)
+}
+
+func (ar *applicationsRouter) bindRemote(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.BindRepository
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	repo, err := ar.NewUserBroker(user, ctx).BindRepository(vars["name"], req.Driver, req.Repo, req.Token)
+	if err != nil {
+		return err
+	}
+	return writeRemoteRepository(w, repo)
+}
+
+func (ar *applicationsRouter) unbindRemote(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).UnbindRepository(vars["name"])
+}
+
+// writeRemoteRepository writes the repository binding without the private
+// key and API token.
+func writeRemoteRepository(w http.ResponseWriter, repo *userdb.RemoteRepository) error {
+	publicKey, err := remote.PublicKey(repo.PrivateKey)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.RemoteRepository{
+		Driver:        repo.Driver,
+		Repo:          repo.Repo,
+		URL:           repo.URL,
+		PublicKey:     publicKey,
+		KeyRegistered: repo.KeyID != "",
+	})
+}
+
+func (ar *applicationsRouter) listDomains(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	br := ar.NewUserBroker(user, ctx)
+	hosts, err := br.GetDomains(vars["name"])
+	if err != nil {
+		return err
+	}
+	certs, err := br.GetCertificates(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Domain, len(hosts))
+	for i, host := range hosts {
+		resp[i] = &types.Domain{Name: host}
+		for _, c := range certs {
+			if c.Domain == host {
+				resp[i].Certificate = &types.Certificate{NotAfter: c.NotAfter, ACME: c.ACME}
+			}
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) addDomain(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.Domain
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	host, err := ar.NewUserBroker(user, ctx).AddDomain(vars["name"], req.Name)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, &types.Domain{Name: host})
+}
+
+func (ar *applicationsRouter) removeDomain(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).RemoveDomain(vars["name"], vars["domain"])
+}
+
+func (ar *applicationsRouter) uploadCertificate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.Certificate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	cert, err := ar.NewUserBroker(user, ctx).UploadCertificate(vars["name"], vars["domain"], []byte(req.Certificate), []byte(req.PrivateKey))
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.Certificate{NotAfter: cert.NotAfter, ACME: cert.ACME})
+}
+
+func (ar *applicationsRouter) removeCertificate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).RemoveCertificate(vars["name"], vars["domain"])
+}
+
+func (ar *applicationsRouter) listSecrets(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	secrets, err := ar.NewUserBroker(user, ctx).ListSecrets(vars["name"])
+	if err != nil {
+		return err
+	}
+	resp := make([]*types.Secret, len(secrets))
+	for i, s := range secrets {
+		resp[i] = convertSecretJson(s)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) setSecret(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.Secret
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	secret, err := ar.NewUserBroker(user, ctx).SetSecret(vars["name"], vars["key"], req.Value, req.Mount)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertSecretJson(secret))
+}
+
+func (ar *applicationsRouter) rotateSecret(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.Secret
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	secret, err := ar.NewUserBroker(user, ctx).RotateSecret(vars["name"], vars["key"], req.Value)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, convertSecretJson(secret))
+}
+
+func (ar *applicationsRouter) removeSecret(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).RemoveSecret(vars["name"], vars["key"])
+}
+
+func (ar *applicationsRouter) secretAudit(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	audit, err := ar.NewUserBroker(user, ctx).GetSecretAudit(vars["name"])
+	if err != nil {
+		return err
+	}
+	resp := make([]*types.SecretAccess, len(audit))
+	for i, a := range audit {
+		resp[i] = &types.SecretAccess{
+			Time:      a.Time,
+			User:      a.User,
+			Container: a.Container,
+			Action:    a.Action,
+			Secret:    a.Secret,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func convertSecretJson(s *userdb.AppSecret) *types.Secret {
+	return &types.Secret{
+		Name:    s.Name,
+		Mount:   s.Mount,
+		Version: s.Version,
+		Created: s.Created,
+		Updated: s.Updated,
+	}
+}
+
+func convertHookJson(h *webhook.Hook) *types.Webhook {
+	return &types.Webhook{
+		ID:      h.ID,
+		URL:     h.URL,
+		Events:  h.Events,
+		Created: h.Created,
+	}
+}
+
+func convertDeliveryJson(d *webhook.Delivery) *types.WebhookDelivery {
+	return &types.WebhookDelivery{
+		ID:         d.ID,
+		Event:      d.Event,
+		Created:    d.Created,
+		Attempts:   d.Attempts,
+		StatusCode: d.StatusCode,
+		Error:      d.Error,
+		Delivered:  d.Delivered,
+		Payload:    d.Payload,
+	}
+}
+
+func convertBranchJson(br *scm.Branch) *types.Branch {
+	return &types.Branch{
+		Id:        br.Id,
+		DisplayId: br.DisplayId,
+		Type:      br.Type,
+	}
+}
+
+func convertBranchesJson(branches []*scm.Branch) []*types.Branch {
+	result := make([]*types.Branch, len(branches))
+	for i := range branches {
+		result[i] = convertBranchJson(branches[i])
+	}
+	return result
+}
+
+func (ar *applicationsRouter) download(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	tr, err := ar.NewUserBroker(user, ctx).Download(vars["name"])
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	w.Header().Set("Content-Type", "application/tar+gzip") // TODO: parse Accept header
+	w.WriteHeader(http.StatusOK)
+
+	zw := gzip.NewWriter(w)
+	if _, err = io.Copy(zw, tr); err == nil {
+		err = zw.Close()
+	}
+	return err
+}
+
+func (ar *applicationsRouter) upload(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	// reject oversized uploads before reading the content
+	if limit := container.MaxRepoSize(); r.ContentLength > limit {
+		return container.RepoTooLargeError{Limit: limit}
+	}
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	_, binary := r.Form["binary"]
+	opts, err := container.ParseDeployOptions(r.Form)
+	if err != nil {
+		return err
+	}
+	if err = opts.ParseBuildEnv(r.Header.Get(types.BuildEnvHeader)); err != nil {
+		return err
+	}
+
+	if _, async := r.Form["async"]; async {
+		build, queued, err := ar.NewUserBroker(user, ctx).SubmitUpload(vars["name"], r.Body, binary, opts)
+		if err != nil {
+			return err
+		}
+		status := http.StatusOK
+		if queued {
+			status = http.StatusAccepted
+		}
+		return httputils.WriteJSON(w, status, convertBuildJson(vars["name"], build))
+	}
+
+	err = ar.NewUserBroker(user, ctx).Upload(vars["name"], r.Body, binary, opts, serverlog.NewResponse(w, r))
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (ar *applicationsRouter) dump(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	tr, err := ar.NewUserBroker(user, ctx).Dump(vars["name"])
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	w.Header().Set("Content-Type", "application/tar+gzip")
+	w.WriteHeader(http.StatusOK)
+
+	zw := gzip.NewWriter(w)
+	if _, err = io.Copy(zw, tr); err == nil {
+		err = zw.Close()
+	}
+	return err
+}
+
+func (ar *applicationsRouter) restore(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ar.NewUserBroker(user, ctx).Restore(vars["name"], r.Body)
+}
+
+func (ar *applicationsRouter) scale(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	name, service := vars["name"], r.FormValue("service")
+	scaling := r.FormValue("scale")
+
+	var up, down bool
+	if strings.HasPrefix(scaling, "+") {
+		up = true
+		scaling = scaling[1:]
+	} else if strings.HasPrefix(scaling, "-") {
+		down = true
+		scaling = scaling[1:]
+	}
+
+	num, err := strconv.Atoi(scaling)
+	if err != nil {
+		return httputils.BadParameter("Invalid scaling: %s", scaling)
+	}
+
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	if up || down {
+		cs, err := ar.FindApplications(ctx, name, user.Namespace)
+		if err != nil {
+			return err
+		}
+		if up {
+			num = len(cs) + num
+		} else {
+			num = len(cs) - num
+		}
+	}
+
+	cs, err := br.ScaleService(name, service, num)
+	if err != nil {
+		return err
+	}
+
+	err = br.StartContainers(ctx, cs, serverlog.NewResponse(w, r))
+	if err != nil {
 		serverlog.SendError(w, err)
 	}
 	return nil
@@ -627,6 +1599,11 @@ func (ar *applicationsRouter) environ(ctx context.Context, w http.ResponseWriter
 	}
 
 	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
 	container, err := ar.getContainer(ctx, user.Namespace, vars)
 	if err != nil {
 		return err
@@ -639,12 +1616,17 @@ func (ar *applicationsRouter) environ(ctx context.Context, w http.ResponseWriter
 	if info, err := container.GetInfo(ctx, opt); err != nil {
 		return err
 	} else {
+		br.MaskSecrets(vars["name"], info.Env)
 		return httputils.WriteJSON(w, http.StatusOK, info.Env)
 	}
 }
 
 func (ar *applicationsRouter) getenv(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
 
 	container, err := ar.getContainer(ctx, user.Namespace, vars)
 	if err != nil {
@@ -654,8 +1636,9 @@ func (ar *applicationsRouter) getenv(ctx context.Context, w http.ResponseWriter,
 		return err
 	} else {
 		key := vars["key"]
-		val := info.Env[key]
-		return httputils.WriteJSON(w, http.StatusOK, map[string]string{key: val})
+		env := map[string]string{key: info.Env[key]}
+		br.MaskSecrets(vars["name"], env)
+		return httputils.WriteJSON(w, http.StatusOK, env)
 	}
 }
 
@@ -676,6 +1659,9 @@ func (ar *applicationsRouter) setenv(ctx context.Context, w http.ResponseWriter,
 	}
 
 	user := httputils.UserFromContext(ctx)
+	if err := ar.NewUserBroker(user, ctx).Refresh(); err != nil {
+		return err
+	}
 
 	cs, err := ar.getContainers(ctx, user.Namespace, vars)
 	if err != nil {
@@ -692,8 +1678,7 @@ func (ar *applicationsRouter) setenv(ctx context.Context, w http.ResponseWriter,
 		args = append(args, "--export")
 		for k, v := range env {
 			if !validEnvKey.MatchString(k) {
-				http.Error(w, k+": Invalid environment variable key", http.StatusBadRequest)
-				return nil
+				return httputils.BadParameter("%s: Invalid environment variable key", k)
 			}
 			args = append(args, k+"="+v)
 		}
@@ -707,3 +1692,86 @@ func (ar *applicationsRouter) setenv(ctx context.Context, w http.ResponseWriter,
 
 	return nil
 }
+
+func (ar *applicationsRouter) getenvAll(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	container, err := ar.getContainer(ctx, user.Namespace, vars)
+	if err != nil {
+		return err
+	}
+
+	env, err := container.GetenvAll(ctx)
+	if err != nil {
+		return err
+	}
+	br.MaskSecrets(vars["name"], env)
+	return httputils.WriteJSON(w, http.StatusOK, env)
+}
+
+func (ar *applicationsRouter) setenvAll(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var env map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		return err
+	}
+	for k := range env {
+		if !validEnvKey.MatchString(k) {
+			return httputils.BadParameter("%s: Invalid environment variable key", k)
+		}
+	}
+
+	user := httputils.UserFromContext(ctx)
+	if err := ar.NewUserBroker(user, ctx).Refresh(); err != nil {
+		return err
+	}
+
+	cs, err := ar.getContainers(ctx, user.Namespace, vars)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if err = c.SetenvAll(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ar *applicationsRouter) unsetenv(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	keys := r.Form["key"]
+	for _, k := range keys {
+		if !validEnvKey.MatchString(k) {
+			return httputils.BadParameter("%s: Invalid environment variable key", k)
+		}
+	}
+
+	user := httputils.UserFromContext(ctx)
+	if err := ar.NewUserBroker(user, ctx).Refresh(); err != nil {
+		return err
+	}
+
+	cs, err := ar.getContainers(ctx, user.Namespace, vars)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if err = c.Unsetenv(ctx, keys...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package applications
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	dockertypes "github.com/docker/engine-api/types"
+)
+
+// exec runs a shell or command in an application container and attaches the
+// client to it. The connection is upgraded to a raw bidirectional stream
+// after the exec is created. With TTY the stream carries terminal data,
+// otherwise the output is multiplexed in the docker stdcopy format.
+func (ar *applicationsRouter) exec(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	c, err := ar.findExecContainer(ctx, vars["name"], br.Namespace(), r.FormValue("service"), r.FormValue("container"))
+	if err != nil {
+		return err
+	}
+
+	tty := r.FormValue("tty") == "1" || r.FormValue("tty") == "true"
+	cmd := []string{"/usr/bin/cwctl", "sh"}
+	if term := r.FormValue("term"); tty && term != "" {
+		cmd = append(cmd, "-e", "TERM="+term)
+	}
+	cmd = append(cmd, "cwsh")
+	if command := r.Form["cmd"]; len(command) != 0 {
+		cmd = append(cmd, "-c", strings.Join(command, " "))
+	}
+
+	execConfig := dockertypes.ExecConfig{
+		Tty:          tty,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	}
+
+	execResp, err := c.ContainerExecCreate(ctx, c.ID, execConfig)
+	if err != nil {
+		return err
+	}
+	execId := execResp.ID
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fmt.Errorf("The server does not support connection upgrade")
+	}
+
+	resp, err := c.ContainerExecAttach(ctx, execId, execConfig)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	if tty {
+		width, _ := strconv.Atoi(r.FormValue("w"))
+		height, _ := strconv.Atoi(r.FormValue("h"))
+		if width > 0 && height > 0 {
+			c.ContainerExecResize(ctx, execId, dockertypes.ResizeOptions{Width: width, Height: height})
+		}
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "HTTP/1.1 101 UPGRADED\r\n"+
+		"Content-Type: application/vnd.cloudway.raw-stream\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Upgrade: tcp\r\n"+
+		"X-Exec-Id: %s\r\n\r\n", execId)
+
+	// pipe the client connection to the container and vice-versa
+	go func() {
+		io.Copy(resp.Conn, buf)
+		resp.CloseWrite()
+		logrus.Debug("[hijack] End of stdin")
+	}()
+
+	io.Copy(conn, resp.Reader)
+	logrus.Debug("[hijack] End of stdout")
+	return nil
+}
+
+func (ar *applicationsRouter) resizeExec(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	width, err := strconv.Atoi(r.FormValue("w"))
+	if err != nil || width <= 0 {
+		return httputils.NewStatusError(http.StatusBadRequest)
+	}
+	height, err := strconv.Atoi(r.FormValue("h"))
+	if err != nil || height <= 0 {
+		return httputils.NewStatusError(http.StatusBadRequest)
+	}
+
+	c, err := ar.findExec(ctx, vars["name"], vars["id"])
+	if err != nil {
+		return err
+	}
+
+	err = c.ContainerExecResize(ctx, vars["id"], dockertypes.ResizeOptions{Width: width, Height: height})
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) inspectExec(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	c, err := ar.findExec(ctx, vars["name"], vars["id"])
+	if err != nil {
+		return err
+	}
+
+	inspect, err := c.ContainerExecInspect(ctx, vars["id"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.ExecInspect{
+		ID:          inspect.ExecID,
+		ContainerID: inspect.ContainerID,
+		Running:     inspect.Running,
+		ExitCode:    inspect.ExitCode,
+	})
+}
+
+// findExecContainer finds the container to execute command in the
+// application.
+func (ar *applicationsRouter) findExecContainer(ctx context.Context, name, namespace, service, id string) (*container.Container, error) {
+	cs, err := ar.FindAll(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, broker.ApplicationNotFoundError(name)
+	}
+
+	if c := selectExecContainer(cs, service, id); c != nil {
+		return c, nil
+	}
+	return nil, httputils.NewStatusError(http.StatusNotFound)
+}
+
+// selectExecContainer selects the container to execute command. The
+// container is specified by the service name or the container ID prefix,
+// and defaults to the first framework container of the application.
+func selectExecContainer(cs []*container.Container, service, id string) *container.Container {
+	for _, c := range cs {
+		switch {
+		case id != "":
+			if strings.HasPrefix(c.ID, id) {
+				return c
+			}
+		case service != "":
+			if c.ServiceName() == service {
+				return c
+			}
+		default:
+			if c.Category().IsFramework() {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// findExec returns the application container in which the exec is running.
+func (ar *applicationsRouter) findExec(ctx context.Context, name, execId string) (*container.Container, error) {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	cs, err := ar.FindAll(ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cs) == 0 {
+		return nil, broker.ApplicationNotFoundError(name)
+	}
+
+	inspect, err := cs[0].ContainerExecInspect(ctx, execId)
+	if err != nil {
+		return nil, httputils.NewStatusError(http.StatusNotFound)
+	}
+	for _, c := range cs {
+		if inspect.ContainerID == c.ID {
+			return c, nil
+		}
+	}
+	return nil, httputils.NewStatusError(http.StatusNotFound)
+}
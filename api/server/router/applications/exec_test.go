@@ -0,0 +1,65 @@
+package applications
+
+import (
+	"testing"
+
+	dockertypes "github.com/docker/engine-api/types"
+	dockercontainer "github.com/docker/engine-api/types/container"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+func testContainer(id string, category manifest.Category, service string) *container.Container {
+	return &container.Container{
+		ContainerJSON: &dockertypes.ContainerJSON{
+			ContainerJSONBase: &dockertypes.ContainerJSONBase{ID: id},
+			Config: &dockercontainer.Config{
+				Labels: map[string]string{
+					container.CATEGORY_KEY:     string(category),
+					container.SERVICE_NAME_KEY: service,
+				},
+			},
+		},
+	}
+}
+
+func TestSelectExecContainer(t *testing.T) {
+	cs := []*container.Container{
+		testContainer("a1b2c3", manifest.Service, "mysql"),
+		testContainer("d4e5f6", manifest.Framework, ""),
+		testContainer("d4e7f8", manifest.Framework, ""),
+		testContainer("a1c3e5", manifest.Service, "redis"),
+	}
+
+	tests := []struct {
+		service, id string
+		expected    string
+	}{
+		{"", "", "d4e5f6"},
+		{"redis", "", "a1c3e5"},
+		{"", "d4e7", "d4e7f8"},
+		{"", "a1", "a1b2c3"},
+		{"mysql", "d4e7", "d4e7f8"},
+		{"mongodb", "", ""},
+		{"", "ffff", ""},
+	}
+
+	for _, test := range tests {
+		c := selectExecContainer(cs, test.service, test.id)
+		var id string
+		if c != nil {
+			id = c.ID
+		}
+		if id != test.expected {
+			t.Errorf("selectExecContainer(%q, %q): found %q, expected %q", test.service, test.id, id, test.expected)
+		}
+	}
+}
+
+func TestSelectExecContainerWithoutFramework(t *testing.T) {
+	cs := []*container.Container{testContainer("a1b2c3", manifest.Service, "mysql")}
+	if c := selectExecContainer(cs, "", ""); c != nil {
+		t.Errorf("selectExecContainer: found %q, expected no container", c.ID)
+	}
+}
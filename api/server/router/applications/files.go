@@ -0,0 +1,74 @@
+package applications
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/broker"
+)
+
+func (ar *applicationsRouter) listFiles(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	files, err := br.ListFiles(vars["name"], r.FormValue("service"), r.FormValue("root"), r.FormValue("path"))
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.FileInfo, len(files))
+	for i, fi := range files {
+		resp[i] = convertFileInfo(fi)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) downloadFiles(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	tr, stat, err := br.DownloadFiles(vars["name"], r.FormValue("service"), r.FormValue("root"), r.FormValue("path"))
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	statJSON, err := json.Marshal(convertFileInfo(stat))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set(types.FileStatHeader, base64.StdEncoding.EncodeToString(statJSON))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, tr)
+	return err
+}
+
+func (ar *applicationsRouter) uploadFiles(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	err := br.UploadFiles(vars["name"], r.FormValue("service"), r.FormValue("root"), r.FormValue("path"), r.Body)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func convertFileInfo(fi *broker.FileInfo) *types.FileInfo {
+	return &types.FileInfo{
+		Name:       fi.Name,
+		Size:       fi.Size,
+		Mode:       fi.Mode,
+		ModTime:    fi.ModTime,
+		LinkTarget: fi.LinkTarget,
+	}
+}
@@ -0,0 +1,42 @@
+package applications
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/container"
+)
+
+func (ar *applicationsRouter) getLogConfig(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	logging, custom, err := ar.NewUserBroker(user, ctx).GetLogConfig(vars["name"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.LogConfig{
+		Driver:  logging.Driver,
+		Options: logging.Options,
+		Custom:  custom,
+	})
+}
+
+func (ar *applicationsRouter) setLogConfig(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.LogConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	logging := container.LogConfig{Driver: req.Driver, Options: req.Options}
+	if err := ar.NewUserBroker(user, ctx).SetLogConfig(vars["name"], logging); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
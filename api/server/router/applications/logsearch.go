@@ -0,0 +1,63 @@
+package applications
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/logstore"
+)
+
+func (ar *applicationsRouter) searchLogs(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	now := time.Now()
+
+	q := logstore.Query{
+		Text:     r.FormValue("q"),
+		Severity: r.FormValue("severity"),
+	}
+	var err error
+	if q.Since, err = parseLogTime(r.FormValue("since"), now); err != nil {
+		return httputils.BadParameter("Invalid since: %s", r.FormValue("since"))
+	}
+	if q.Until, err = parseLogTime(r.FormValue("until"), now); err != nil {
+		return httputils.BadParameter("Invalid until: %s", r.FormValue("until"))
+	}
+	if v := r.FormValue("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			return httputils.BadParameter("Invalid limit: %s", v)
+		}
+	}
+
+	entries, err := ar.NewUserBroker(user, ctx).SearchLogs(vars["name"], q)
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.LogEntry, len(entries))
+	for i, e := range entries {
+		resp[i] = &types.LogEntry{
+			Time:      e.Time,
+			Container: e.Container,
+			Stream:    e.Stream,
+			Severity:  e.Severity,
+			Message:   e.Message,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// parseLogTime parses a timestamp, or a duration relative to now.
+func parseLogTime(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
@@ -0,0 +1,45 @@
+package applications
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/container"
+)
+
+func (ar *applicationsRouter) getNetworkGrants(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	br := ar.NewUserBroker(user, ctx)
+
+	grants, granted, err := br.GetNetworkGrants(vars["name"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.NetworkGrants{
+		Network:   container.AppNetworkName(vars["name"], br.Namespace()),
+		Isolation: container.NetworkIsolation(),
+		Grants:    grants,
+		Granted:   granted,
+	})
+}
+
+func (ar *applicationsRouter) grantNetworkAccess(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	if err := ar.NewUserBroker(user, ctx).GrantNetworkAccess(vars["name"], vars["app"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (ar *applicationsRouter) revokeNetworkAccess(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	if err := ar.NewUserBroker(user, ctx).RevokeNetworkAccess(vars["name"], vars["app"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,48 @@
+package applications
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/container"
+)
+
+func (ar *applicationsRouter) getResources(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	limits, err := ar.NewUserBroker(user, ctx).GetResources(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Resources, len(limits))
+	for i, l := range limits {
+		resp[i] = &types.Resources{
+			Service:   l.Service,
+			Memory:    l.Memory,
+			CPUs:      l.CPUs,
+			PidsLimit: l.PidsLimit,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (ar *applicationsRouter) setResources(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+
+	var req types.Resources
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	limits := container.Resources{Memory: req.Memory, CPUs: req.CPUs, PidsLimit: req.PidsLimit}
+	if err := ar.NewUserBroker(user, ctx).SetResources(vars["name"], req.Service, limits); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
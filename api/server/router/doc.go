@@ -0,0 +1,53 @@
+package router
+
+// Doc describes an API route for the generated API specification.
+type Doc struct {
+	// A short summary of what the route does.
+	Summary string
+
+	// A verbose explanation of the route behavior.
+	Description string
+
+	// Tags group routes in the API specification.
+	Tags []string
+
+	// Params describes query, form or header parameters. Path parameters
+	// are derived from the route path unless described here.
+	Params []Param
+
+	// Body is a value of the JSON encoded request body type, nil if the
+	// route doesn't accept a JSON body.
+	Body interface{}
+
+	// Response is a value of the JSON encoded response body type, nil if
+	// the route doesn't respond with JSON.
+	Response interface{}
+
+	// Status is the HTTP status of successful response, defaults to 200.
+	Status int
+}
+
+// Param describes a parameter of an API route.
+type Param struct {
+	Name        string
+	In          string // "query", "formData", "header" or "path"
+	Type        string // "string", "integer", "number" or "boolean"
+	Description string
+	Required    bool
+}
+
+// QueryParam describes an optional string query parameter.
+func QueryParam(name, description string) Param {
+	return Param{Name: name, In: "query", Type: "string", Description: description}
+}
+
+// WithDoc makes new route described by the given documentation.
+func WithDoc(r Route, doc Doc) Route {
+	return localRoute{
+		method:  r.Method(),
+		path:    r.Path(),
+		handler: r.Handler(),
+		scope:   r.Scope(),
+		doc:     &doc,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/auth/userdb"
 )
 
 // localRoute defines an individual API route.
@@ -12,6 +13,8 @@ type localRoute struct {
 	method  string
 	path    string
 	handler httputils.APIFunc
+	scope   userdb.Scope
+	doc     *Doc
 }
 
 // Handler returns the APIFunc to let the server wrap it in middlewares.
@@ -29,9 +32,25 @@ func (l localRoute) Path() string {
 	return l.path
 }
 
-// NewRoute initializes a new local route for the router.
+// Scope returns the permission required to access the route.
+func (l localRoute) Scope() userdb.Scope {
+	return l.scope
+}
+
+// Doc returns the documentation of the route, nil if not documented.
+func (l localRoute) Doc() *Doc {
+	return l.doc
+}
+
+// NewRoute initializes a new local route for the router. The route
+// requires write permission unless it's a read-only http method.
 func NewRoute(method, path string, handler httputils.APIFunc) Route {
-	return localRoute{method, path, handler}
+	scope := userdb.ScopeWrite
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		scope = userdb.ScopeRead
+	}
+	return localRoute{method: method, path: path, handler: handler, scope: scope}
 }
 
 // NewGetRoute initialize a new route with the http method GET.
@@ -91,5 +110,29 @@ func Cancellable(r Route) Route {
 		method:  r.Method(),
 		path:    r.Path(),
 		handler: cancellableHandler(r.Handler()),
+		scope:   r.Scope(),
+		doc:     r.Doc(),
+	}
+}
+
+// WithHandler makes new route which handles requests by the given handler.
+func WithHandler(r Route, handler httputils.APIFunc) Route {
+	return localRoute{
+		method:  r.Method(),
+		path:    r.Path(),
+		handler: handler,
+		scope:   r.Scope(),
+		doc:     r.Doc(),
+	}
+}
+
+// WithScope makes new route which requires the given permission.
+func WithScope(r Route, scope userdb.Scope) Route {
+	return localRoute{
+		method:  r.Method(),
+		path:    r.Path(),
+		handler: r.Handler(),
+		scope:   scope,
+		doc:     r.Doc(),
 	}
 }
@@ -1,10 +1,15 @@
 package namespace
 
 import (
+	"encoding/json"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
 	"golang.org/x/net/context"
 )
@@ -21,6 +26,30 @@ func NewRouter(broker *broker.Broker) router.Router {
 		router.NewGetRoute("/namespace", r.get),
 		router.NewPostRoute("/namespace", r.set),
 		router.NewDeleteRoute("/namespace", r.delete),
+		router.WithDoc(router.NewGetRoute("/namespace/members", r.members), router.Doc{
+			Summary:  "List members sharing the namespace",
+			Response: []types.TeamMember{},
+		}),
+		router.WithDoc(router.NewPutRoute("/namespace/members/{user:[^/]+}", r.addMember), router.Doc{
+			Summary:     "Invite a user to the namespace",
+			Description: "Add the user as a member of the namespace, or change the role of an existing member",
+			Body:        types.TeamMember{},
+		}),
+		router.NewDeleteRoute("/namespace/members/{user:[^/]+}", r.removeMember),
+		router.WithScope(router.WithDoc(router.NewGetRoute("/namespace/env", r.getenv), router.Doc{
+			Summary:  "Get environment variables shared by applications in the namespace",
+			Response: map[string]string{},
+		}), userdb.ScopeWrite),
+		router.WithDoc(router.NewPutRoute("/namespace/env", r.setenv), router.Doc{
+			Summary:     "Set environment variables shared by applications in the namespace",
+			Description: "Set shared environment variables, which are inherited by all applications unless overridden by the application",
+			Body:        map[string]string{},
+		}),
+		router.NewDeleteRoute("/namespace/env", r.unsetenv),
+		router.WithDoc(router.NewGetRoute("/namespace/teams", r.teams), router.Doc{
+			Summary:  "List namespaces shared with the user and their applications",
+			Response: []types.Team{},
+		}),
 	}
 
 	return r
@@ -59,3 +88,136 @@ func (nr *namespaceRouter) delete(ctx context.Context, w http.ResponseWriter, r
 	_, force := r.Form["force"]
 	return br.RemoveNamespace(force)
 }
+
+// teamBroker returns the broker operating on the namespace selected by the
+// namespace header, which requires the given role if shared by other user.
+func (nr *namespaceRouter) teamBroker(ctx context.Context, r *http.Request, role userdb.TeamRole) (*broker.UserBroker, error) {
+	user, err := nr.ResolveNamespace(httputils.UserFromContext(ctx), r.Header.Get(types.NamespaceHeader), role)
+	if err != nil {
+		return nil, err
+	}
+	return nr.NewUserBroker(user, ctx), nil
+}
+
+func (nr *namespaceRouter) members(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br, err := nr.teamBroker(ctx, r, userdb.TeamViewer)
+	if err != nil {
+		return err
+	}
+	members, err := br.GetMembers()
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.TeamMember, len(members))
+	for i, m := range members {
+		resp[i] = &types.TeamMember{Name: m.Name, Role: string(m.Role)}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (nr *namespaceRouter) addMember(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.TeamMember
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	role, err := userdb.ParseTeamRole(req.Role)
+	if err != nil {
+		return err
+	}
+
+	br, err := nr.teamBroker(ctx, r, userdb.TeamOwner)
+	if err != nil {
+		return err
+	}
+	if err = br.AddMember(vars["user"], role); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (nr *namespaceRouter) removeMember(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	// members can leave the team by themselves
+	role := userdb.TeamOwner
+	if vars["user"] == httputils.UserFromContext(ctx).Name {
+		role = userdb.TeamViewer
+	}
+
+	br, err := nr.teamBroker(ctx, r, role)
+	if err != nil {
+		return err
+	}
+	if err = br.RemoveMember(vars["user"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+var validEnvKey = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+
+func (nr *namespaceRouter) getenv(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	br, err := nr.teamBroker(ctx, r, userdb.TeamDeployer)
+	if err != nil {
+		return err
+	}
+	env, err := br.GetNamespaceEnv()
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, env)
+}
+
+func (nr *namespaceRouter) setenv(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var env map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		return err
+	}
+	for k := range env {
+		if !validEnvKey.MatchString(k) || strings.HasPrefix(k, "CLOUDWAY_") {
+			return httputils.BadParameter("%s: Invalid environment variable key", k)
+		}
+	}
+
+	br, err := nr.teamBroker(ctx, r, userdb.TeamDeployer)
+	if err != nil {
+		return err
+	}
+	return br.SetNamespaceEnv(env)
+}
+
+func (nr *namespaceRouter) unsetenv(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	br, err := nr.teamBroker(ctx, r, userdb.TeamDeployer)
+	if err != nil {
+		return err
+	}
+	return br.UnsetNamespaceEnv(r.Form["key"]...)
+}
+
+func (nr *namespaceRouter) teams(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	teams, err := nr.NewUserBroker(user, ctx).GetTeams()
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Team, len(teams))
+	for i, t := range teams {
+		resp[i] = &types.Team{
+			Namespace:    t.Namespace,
+			Owner:        t.Owner,
+			Role:         string(t.Role),
+			Applications: t.Applications,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
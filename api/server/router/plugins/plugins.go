@@ -1,13 +1,22 @@
 package plugins
 
 import (
-	"golang.org/x/net/context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
 
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/hub"
 	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
 )
 
 type pluginsRouter struct {
@@ -19,10 +28,37 @@ func NewRouter(broker *broker.Broker) router.Router {
 	r := &pluginsRouter{Broker: broker}
 
 	r.routes = []router.Route{
+		router.NewGetRoute("/plugins/{name:[a-zA-Z_0-9]+}/access", r.access),
+		router.NewPostRoute("/plugins/{name:[a-zA-Z_0-9]+}/access", r.setVisibility),
+		router.NewPutRoute("/plugins/{name:[a-zA-Z_0-9]+}/access/{namespace}", r.grant),
+		router.NewDeleteRoute("/plugins/{name:[a-zA-Z_0-9]+}/access/{namespace}", r.revoke),
 		router.NewGetRoute("/plugins/", r.list),
+		router.WithDoc(router.NewGetRoute("/plugins/{tag:.*}/scan", r.scan), router.Doc{
+			Summary:     "Get vulnerabilities found in the plugin image",
+			Description: "Get the report of the vulnerability scan performed when the plugin was installed",
+			Response:    types.ImageScan{},
+		}),
 		router.NewGetRoute("/plugins/{tag:.*}", r.info),
 		router.NewPostRoute("/plugins/", r.create),
+		router.WithDoc(router.NewPostRoute("/plugins/validate", r.validate), router.Doc{
+			Summary:     "Validate a plugin archive",
+			Description: "Check the manifest, scripts and endpoints of the plugin archive before installation",
+			Response:    types.PluginValidation{},
+		}),
+		router.NewPostRoute("/plugins/{tag:.*}/upgrade", r.upgrade),
+		router.NewPutRoute("/plugins/{tag:.*}/manifest", r.reload),
 		router.NewDeleteRoute("/plugins/{tag:.*}", r.remove),
+		router.WithDoc(router.NewGetRoute("/templates/", r.templates), router.Doc{
+			Summary:  "List application templates saved in the user's namespace",
+			Response: []types.Template{},
+		}),
+		router.WithDoc(router.NewPostRoute("/templates/", r.saveTemplate), router.Doc{
+			Summary:     "Save an application as a template",
+			Description: "Save plugins and environment of the application, and optionally the deployed repository, as a template to create new applications",
+			Body:        types.SaveTemplate{},
+			Response:    types.Template{},
+		}),
+		router.NewDeleteRoute("/templates/{name:[a-z][a-z_0-9]*}", r.removeTemplate),
 	}
 
 	return r
@@ -37,21 +73,21 @@ func (pr *pluginsRouter) list(ctx context.Context, w http.ResponseWriter, r *htt
 		return err
 	}
 
-	category := manifest.Category(r.FormValue("category"))
+	query, err := hub.ParseQuery(r.Form)
+	if err != nil {
+		return err
+	}
 	_, userDefined := r.Form["user"]
 
 	user := httputils.UserFromContext(ctx)
-	br := pr.NewUserBroker(user, ctx)
-
-	var plugins []*manifest.Plugin
-	if userDefined {
-		plugins = br.GetUserPlugins(category)
-	} else {
-		plugins = br.GetInstalledPlugins(category)
+	plugins, total, err := pr.NewUserBroker(user, ctx).SearchPlugins(query, userDefined)
+	if err != nil {
+		return err
 	}
 	if plugins == nil {
 		plugins = make([]*manifest.Plugin, 0)
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	return httputils.WriteJSON(w, http.StatusOK, plugins)
 }
 
@@ -64,12 +100,192 @@ func (pr *pluginsRouter) info(ctx context.Context, w http.ResponseWriter, r *htt
 	return httputils.WriteJSON(w, http.StatusOK, plugin)
 }
 
+func (pr *pluginsRouter) scan(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	report, err := pr.NewUserBroker(user, ctx).GetPluginScan(vars["tag"])
+	if err != nil {
+		return err
+	}
+
+	resp := &types.ImageScan{
+		Image:           report.Image,
+		Scanner:         report.Scanner,
+		ScannedAt:       report.ScannedAt,
+		Summary:         report.Summary(),
+		Vulnerabilities: make([]*types.Vulnerability, len(report.Vulnerabilities)),
+	}
+	for i, v := range report.Vulnerabilities {
+		resp.Vulnerabilities[i] = &types.Vulnerability{
+			ID:           v.ID,
+			Package:      v.Package,
+			Version:      v.Version,
+			FixedVersion: v.FixedVersion,
+			Severity:     v.Severity,
+			Title:        v.Title,
+			Link:         v.Link,
+		}
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
 func (pr *pluginsRouter) create(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	buildArgs := make(map[string]string)
+	for _, v := range r.Form["build-arg"] {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return httputils.BadParameter("Invalid build argument: %s", v)
+		}
+		buildArgs[kv[0]] = kv[1]
+	}
+
+	user := httputils.UserFromContext(ctx)
+	log := serverlog.NewResponse(w, r)
+	if err := pr.NewUserBroker(user, ctx).InstallPlugin(r.Body, buildArgs, log); err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (pr *pluginsRouter) validate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
-	return pr.NewUserBroker(user, ctx).InstallPlugin(r.Body)
+	diags, err := pr.NewUserBroker(user, ctx).ValidatePlugin(r.Body)
+	if err != nil {
+		return err
+	}
+	if diags == nil {
+		diags = make(manifest.Diagnostics, 0)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.PluginValidation{
+		Valid:       !diags.HasErrors(),
+		Diagnostics: diags,
+	})
 }
 
 func (pr *pluginsRouter) remove(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	user := httputils.UserFromContext(ctx)
 	return pr.NewUserBroker(user, ctx).RemovePlugin(vars["tag"])
 }
+
+func (pr *pluginsRouter) upgrade(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	log := serverlog.NewResponse(w, r)
+
+	upgraded, err := pr.NewUserBroker(user, ctx).UpgradePlugin(vars["tag"], r.FormValue("app"), log)
+	if len(upgraded) != 0 {
+		fmt.Fprintf(log, "Upgraded applications: %s\n", strings.Join(upgraded, ", "))
+	} else if err == nil {
+		fmt.Fprintln(log, "No application to upgrade")
+	}
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (pr *pluginsRouter) reload(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	log := serverlog.NewResponse(w, r)
+
+	reloaded, err := pr.NewUserBroker(user, ctx).ReloadPlugin(vars["tag"], data, log)
+	if len(reloaded) != 0 {
+		fmt.Fprintf(log, "Reloaded applications: %s\n", strings.Join(reloaded, ", "))
+	} else if err == nil {
+		fmt.Fprintln(log, "No application to reload")
+	}
+	if err != nil {
+		serverlog.SendError(w, err)
+	}
+	return nil
+}
+
+func (pr *pluginsRouter) access(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	access, err := pr.NewUserBroker(user, ctx).GetPluginAccess(vars["name"])
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, access)
+}
+
+func (pr *pluginsRouter) setVisibility(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	visibility := hub.Visibility(r.FormValue("visibility"))
+	return pr.NewUserBroker(user, ctx).SetPluginVisibility(vars["name"], visibility)
+}
+
+func (pr *pluginsRouter) grant(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return pr.NewUserBroker(user, ctx).GrantPluginAccess(vars["name"], vars["namespace"])
+}
+
+func (pr *pluginsRouter) revoke(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return pr.NewUserBroker(user, ctx).RevokePluginAccess(vars["name"], vars["namespace"])
+}
+
+func (pr *pluginsRouter) templates(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	templates, err := pr.NewUserBroker(user, ctx).GetTemplates()
+	if err != nil {
+		return err
+	}
+
+	resp := make([]*types.Template, len(templates))
+	for i, t := range templates {
+		resp[i] = convertTemplateJson(t)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (pr *pluginsRouter) saveTemplate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.CheckForJSON(r); err != nil {
+		return err
+	}
+
+	var req types.SaveTemplate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	t, err := pr.NewUserBroker(user, ctx).SaveTemplate(req.Application, req.Name, req.Description, req.Repo)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, convertTemplateJson(t))
+}
+
+func (pr *pluginsRouter) removeTemplate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return pr.NewUserBroker(user, ctx).RemoveTemplate(vars["name"])
+}
+
+func convertTemplateJson(t *hub.Template) *types.Template {
+	return &types.Template{
+		Name:        t.Name,
+		Description: t.Description,
+		Created:     t.Created,
+		Plugins:     t.Plugins,
+		Env:         t.Env,
+		TimeZone:    t.TimeZone,
+		Locale:      t.Locale,
+		Repo:        t.Repo,
+	}
+}
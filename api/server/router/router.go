@@ -1,6 +1,9 @@
 package router
 
-import "github.com/cloudway/platform/api/server/httputils"
+import (
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/auth/userdb"
+)
 
 // Router defines an interface to specify a group of routes to add to the API server.
 type Router interface {
@@ -16,4 +19,8 @@ type Route interface {
 	Method() string
 	// Path returns the subpath where the route responds to.
 	Path() string
+	// Scope returns the permission required to access the route.
+	Scope() userdb.Scope
+	// Doc returns the documentation of the route, nil if not documented.
+	Doc() *Doc
 }
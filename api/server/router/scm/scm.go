@@ -0,0 +1,65 @@
+package scm
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/scm/push"
+)
+
+// The maximum size of webhook payloads.
+const maxPayloadSize = 10 * 1024 * 1024
+
+type scmRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+// NewRouter creates the router receiving webhooks from Git hosting
+// services. The routes don't require authentication, requests are
+// verified with the push secret of the application.
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &scmRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.NewPostRoute("/scm/hooks/{namespace}/{name}", r.receive),
+	}
+
+	return r
+}
+
+func (sr *scmRouter) Routes() []router.Route {
+	return sr.routes
+}
+
+func (sr *scmRouter) receive(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		return push.PayloadError(err.Error())
+	}
+
+	token := r.URL.Query().Get("token")
+	event, deploying, err := sr.ReceivePush(vars["namespace"], vars["name"], r.Header, token, body)
+	if err == push.ErrIgnored {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	status := http.StatusOK
+	if deploying {
+		status = http.StatusAccepted
+	}
+	return httputils.WriteJSON(w, status, map[string]interface{}{
+		"Ref":       event.Ref,
+		"Commit":    event.Commit,
+		"Deploying": deploying,
+	})
+}
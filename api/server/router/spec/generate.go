@@ -0,0 +1,302 @@
+// Package spec generates the OpenAPI (Swagger 2.0) specification of the
+// API server from route definitions.
+package spec
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+)
+
+// Document is the root object of the API specification.
+type Document struct {
+	Swagger             string                           `json:"swagger"`
+	Info                Info                             `json:"info"`
+	BasePath            string                           `json:"basePath"`
+	Consumes            []string                         `json:"consumes,omitempty"`
+	Produces            []string                         `json:"produces,omitempty"`
+	Paths               map[string]map[string]*Operation `json:"paths"`
+	SecurityDefinitions map[string]*SecurityScheme       `json:"securityDefinitions,omitempty"`
+	Definitions         map[string]*Schema               `json:"definitions,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	// The permission required to access the route.
+	Scope string `json:"x-scope,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Type        string  `json:"type,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is a JSON schema of request or response body.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// pathParam matches path variables with optional patterns, such as
+// {name:[^/]+}.
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generator generates API specification from routers.
+type Generator struct {
+	// Info is the metadata of the API.
+	Info Info
+
+	// BasePath is the path prefix of all routes.
+	BasePath string
+
+	// Public returns true if the route path can be accessed without
+	// authentication.
+	Public func(path string) bool
+
+	definitions map[string]*Schema
+}
+
+// Generate returns the API specification of routes of the routers. It's
+// safe to call Generate concurrently.
+func (g *Generator) Generate(routers []router.Router) *Document {
+	gen := *g
+	gen.definitions = make(map[string]*Schema)
+
+	doc := &Document{
+		Swagger:  "2.0",
+		Info:     gen.Info,
+		BasePath: gen.BasePath,
+		Consumes: []string{"application/json"},
+		Produces: []string{"application/json"},
+		Paths:    make(map[string]map[string]*Operation),
+		SecurityDefinitions: map[string]*SecurityScheme{
+			"basicAuth": {Type: "basic", Description: "HTTP Basic Authentication."},
+			"apiKey": {Type: "apiKey", In: "header", Name: "Authorization",
+				Description: "Bearer token returned by authentication or personal access token."},
+		},
+	}
+
+	for _, rt := range routers {
+		for _, r := range rt.Routes() {
+			path := pathParam.ReplaceAllString(r.Path(), "{$1}")
+			ops := doc.Paths[path]
+			if ops == nil {
+				ops = make(map[string]*Operation)
+				doc.Paths[path] = ops
+			}
+			ops[strings.ToLower(r.Method())] = gen.operation(r, path)
+		}
+	}
+
+	gen.schemaOf(reflect.TypeOf(types.ErrorResponse{}))
+	doc.Definitions = gen.definitions
+	return doc
+}
+
+func (g *Generator) operation(r router.Route, path string) *Operation {
+	op := &Operation{
+		OperationID: operationID(r.Method(), path),
+		Responses:   make(map[string]*Response),
+		Scope:       string(r.Scope()),
+	}
+
+	doc := r.Doc()
+	if doc == nil {
+		doc = &router.Doc{}
+	}
+	op.Summary = doc.Summary
+	op.Description = doc.Description
+	op.Tags = doc.Tags
+	if len(op.Tags) == 0 {
+		op.Tags = []string{strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]}
+	}
+
+	// path parameters
+	described := make(map[string]bool)
+	for _, p := range doc.Params {
+		described[p.Name] = true
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		if !described[m[1]] {
+			op.Parameters = append(op.Parameters, &Parameter{Name: m[1], In: "path", Required: true, Type: "string"})
+		}
+	}
+
+	for _, p := range doc.Params {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Type:        typ,
+		})
+	}
+
+	if doc.Body != nil {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     "body",
+			In:       "body",
+			Required: true,
+			Schema:   g.schemaOf(reflect.TypeOf(doc.Body)),
+		})
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status)}
+	if doc.Response != nil {
+		resp.Schema = g.schemaOf(reflect.TypeOf(doc.Response))
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+	op.Responses["default"] = &Response{
+		Description: "Error response",
+		Schema:      &Schema{Ref: "#/definitions/ErrorResponse"},
+	}
+
+	if g.Public == nil || !g.Public(r.Path()) {
+		op.Security = []map[string][]string{{"apiKey": {}}}
+	} else if r.Method() == "POST" && r.Path() == "/auth" {
+		op.Security = []map[string][]string{{"basicAuth": {}}}
+	}
+	return op
+}
+
+// operationID generates an identifier of the operation from the method
+// and path, such as getApplicationsNameStatus.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, s := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id += strings.ToUpper(s[:1]) + s[1:]
+	}
+	return id
+}
+
+// schemaOf returns the JSON schema of the Go type. Named struct types are
+// added to definitions and referenced.
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// reserve the name before generating recursive types
+			g.definitions[t.Name()] = &Schema{Type: "object"}
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/definitions/" + t.Name()}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		s.Properties[name] = g.schemaOf(f.Type)
+	}
+}
@@ -0,0 +1,88 @@
+package spec
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/router"
+)
+
+type testItem struct {
+	Name    string
+	Created time.Time
+	Tags    []string `json:",omitempty"`
+	Secret  string   `json:"-"`
+	Labels  map[string]string
+	Parent  *testItem `json:"parent,omitempty"`
+}
+
+type testRouter []router.Route
+
+func (r testRouter) Routes() []router.Route {
+	return r
+}
+
+func noop(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	routes := testRouter{
+		router.NewGetRoute("/version", noop),
+		router.WithDoc(router.NewPostRoute("/items/{name:[^/]+}", noop), router.Doc{
+			Summary:  "Create an item",
+			Params:   []router.Param{router.QueryParam("force", "Overwrite existing item")},
+			Body:     testItem{},
+			Response: []*testItem{},
+			Status:   http.StatusCreated,
+		}),
+	}
+
+	g := &Generator{
+		Info:     Info{Title: "Test", Version: "1.0"},
+		BasePath: "/api",
+		Public:   func(path string) bool { return path == "/version" },
+	}
+	doc := g.Generate([]router.Router{routes})
+
+	if op := doc.Paths["/version"]["get"]; op == nil || op.Security != nil {
+		t.Fatalf("expected public operation for /version, got %+v", op)
+	}
+
+	op := doc.Paths["/items/{name}"]["post"]
+	if op == nil {
+		t.Fatal("path parameter pattern should be removed from path")
+	}
+	if op.OperationID != "postItemsName" || op.Scope != "write" || op.Security == nil {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+	if len(op.Parameters) != 3 || op.Parameters[0].In != "path" || op.Parameters[1].Name != "force" || op.Parameters[2].In != "body" {
+		t.Errorf("unexpected parameters: %+v", op.Parameters)
+	}
+	if resp := op.Responses["201"]; resp == nil || resp.Schema.Type != "array" || resp.Schema.Items.Ref != "#/definitions/testItem" {
+		t.Errorf("unexpected response: %+v", op.Responses)
+	}
+
+	item := doc.Definitions["testItem"]
+	if item == nil {
+		t.Fatal("struct type should be added to definitions")
+	}
+	if _, ok := item.Properties["Secret"]; ok {
+		t.Error("ignored field should not be included")
+	}
+	if s := item.Properties["Created"]; s == nil || s.Format != "date-time" {
+		t.Errorf("unexpected time schema: %+v", s)
+	}
+	if s := item.Properties["Labels"]; s == nil || s.AdditionalProperties.Type != "string" {
+		t.Errorf("unexpected map schema: %+v", s)
+	}
+	if s := item.Properties["parent"]; s == nil || s.Ref != "#/definitions/testItem" {
+		t.Errorf("unexpected recursive schema: %+v", s)
+	}
+	if doc.Definitions["ErrorResponse"] == nil {
+		t.Error("error response should be defined")
+	}
+}
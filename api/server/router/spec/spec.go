@@ -0,0 +1,84 @@
+package spec
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api"
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/config"
+)
+
+type specRouter struct {
+	routes    []router.Route
+	generator *Generator
+	routers   func() []router.Router
+}
+
+// NewRouter creates a router serves the live API specification generated
+// from the routers, which are retrieved when the specification is requested
+// so that routers initialized later are included.
+func NewRouter(routers func() []router.Router, contextRoot string, public func(string) bool) router.Router {
+	r := &specRouter{
+		routers: routers,
+		generator: &Generator{
+			Info: Info{
+				Title:       "Cloudway API",
+				Description: "The Cloudway API exposes operations for managing applications.",
+				Version:     api.Version,
+			},
+			BasePath: contextRoot,
+			Public:   public,
+		},
+	}
+
+	r.routes = []router.Route{
+		router.WithDoc(router.NewGetRoute("/spec", r.getSpec), router.Doc{
+			Summary:     "API specification",
+			Description: "Get the OpenAPI specification generated from the API routes",
+			Tags:        []string{"system"},
+		}),
+		router.NewGetRoute("/spec/ui", r.getExplorer),
+	}
+
+	return r
+}
+
+func (s *specRouter) Routes() []router.Route {
+	return s.routes
+}
+
+func (s *specRouter) getSpec(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return httputils.WriteJSON(w, http.StatusOK, s.generator.Generate(s.routers()))
+}
+
+// The interactive API explorer page, which loads Swagger UI from the URL
+// configured by spec.ui_url.
+var explorerTemplate = template.Must(template.New("explorer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Cloudway API Explorer</title>
+  <link rel="stylesheet" href="{{.UI}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.UI}}/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({url: "{{.Spec}}", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`))
+
+func (s *specRouter) getExplorer(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return explorerTemplate.Execute(w, map[string]string{
+		"UI":   strings.TrimSuffix(config.GetOrDefault("spec.ui_url", "https://unpkg.com/swagger-ui-dist@3"), "/"),
+		"Spec": strings.TrimSuffix(r.URL.Path, "/ui"),
+	})
+}
@@ -0,0 +1,88 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/config"
+)
+
+// register creates a pending user and sends a verification email. The number
+// of registrations from a client address is limited.
+func (s *systemRouter) register(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if !s.registerLimiter.Allow(httputils.ClientAddr(r)) {
+		return httputils.NewAPIError(http.StatusTooManyRequests, types.ErrCodeTooManyRequests,
+			"Too many registrations, please try again later")
+	}
+
+	var req types.Register
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := s.Register(req.Email, req.Password, req.Namespace); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+		"Message": fmt.Sprintf("A verification email has been sent to %s", req.Email),
+	})
+}
+
+// confirm activates the registered user with the verification token. The
+// token is given by the link in verification email, or posted by client.
+func (s *systemRouter) confirm(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	name, activated, err := s.ConfirmRegistration(r.FormValue("token"))
+	if err != nil {
+		return err
+	}
+
+	if r.Method == "GET" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if activated {
+			fmt.Fprintf(w, "Your account %s has been activated.\n", name)
+		} else {
+			fmt.Fprintf(w, "Your email has been verified, the account %s is waiting for approval.\n", name)
+		}
+		return nil
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.Confirmation{Name: name, Activated: activated})
+}
+
+// forgotPassword sends a password reset token to the user by email. The
+// response doesn't reveal whether the user exists.
+func (s *systemRouter) forgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if !s.passwordLimiter.Allow(httputils.ClientAddr(r)) {
+		return httputils.NewAPIError(http.StatusTooManyRequests, types.ErrCodeTooManyRequests,
+			"Too many requests, please try again later")
+	}
+
+	var req types.ForgotPassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := s.RequestPasswordReset(req.Email, config.Get("password.reset_url")); err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+		"Message": "If the account exists, a password reset email has been sent",
+	})
+}
+
+// resetPassword sets a new password with the password reset token.
+func (s *systemRouter) resetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.PasswordReset
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	return s.ResetPassword(req.Token, req.Password)
+}
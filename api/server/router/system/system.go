@@ -3,6 +3,8 @@ package system
 import (
 	"net/http"
 	osruntime "runtime"
+	"strconv"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -11,26 +13,78 @@ import (
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/router"
 	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/metrics"
+	"github.com/cloudway/platform/pkg/ratelimit"
 )
 
 type systemRouter struct {
 	*broker.Broker
-	routes []router.Route
+	routes          []router.Route
+	registerLimiter *ratelimit.TokenBucket
+	passwordLimiter *ratelimit.TokenBucket
 }
 
 func NewRouter(broker *broker.Broker) router.Router {
 	r := &systemRouter{Broker: broker}
 
+	// limits the number of registrations and password reset requests per
+	// hour from a client address
+	r.registerLimiter = newHourlyLimiter("register.rate_limit", 5)
+	r.passwordLimiter = newHourlyLimiter("password.reset_rate_limit", 5)
+
 	r.routes = []router.Route{
-		router.NewGetRoute("/version", r.getVersion),
+		router.WithDoc(router.NewGetRoute("/version", r.getVersion), router.Doc{
+			Summary:  "Version information",
+			Response: types.Version{},
+		}),
 		router.NewGetRoute("/swagger.json", r.getSwaggerJson),
-		router.NewPostRoute("/auth", r.postAuth),
+		router.WithDoc(router.NewPostRoute("/auth", r.postAuth), router.Doc{
+			Summary:     "User authentication",
+			Description: "Authenticate with user name and password, returns an access token",
+			Params: []router.Param{
+				{Name: types.TwoFactorHeader, In: "header", Description: "The two-factor authentication code if enabled"},
+			},
+			Response: map[string]string{},
+		}),
+		router.WithDoc(router.NewPostRoute("/auth/register", r.register), router.Doc{
+			Summary: "Register a new user pending for email verification",
+			Body:    types.Register{},
+			Status:  http.StatusAccepted,
+		}),
+		router.NewGetRoute("/auth/confirm", r.confirm),
+		router.WithDoc(router.NewPostRoute("/auth/confirm", r.confirm), router.Doc{
+			Summary:  "Confirm a registration with the verification token",
+			Params:   []router.Param{{Name: "token", In: "query", Required: true}},
+			Response: types.Confirmation{},
+		}),
+		router.WithDoc(router.NewPostRoute("/auth/password/forgot", r.forgotPassword), router.Doc{
+			Summary: "Send a password reset email",
+			Body:    types.ForgotPassword{},
+			Status:  http.StatusAccepted,
+		}),
+		router.WithDoc(router.NewPostRoute("/auth/password/reset", r.resetPassword), router.Doc{
+			Summary: "Set a new password with the password reset token",
+			Body:    types.PasswordReset{},
+		}),
+		router.NewGetRoute("/.well-known/acme-challenge/{token}", r.getACMEChallenge),
+		router.WithScope(router.NewGetRoute("/metrics", r.getMetrics), userdb.ScopeAdmin),
 	}
 
 	return r
 }
 
+func newHourlyLimiter(key string, def int) *ratelimit.TokenBucket {
+	limit, err := strconv.Atoi(config.GetOrDefault(key, strconv.Itoa(def)))
+	if err != nil {
+		logrus.WithError(err).Warnf("Invalid %s setting", key)
+		limit = def
+	}
+	return ratelimit.NewTokenBucket(limit, time.Hour)
+}
+
 func (s *systemRouter) Routes() []router.Route {
 	return s.routes
 }
@@ -56,18 +110,42 @@ func (s *systemRouter) getVersion(ctx context.Context, w http.ResponseWriter, r
 func (s *systemRouter) postAuth(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		http.Error(w, "Requires username and password", http.StatusUnauthorized)
-		return nil
+		return httputils.NewAPIError(http.StatusUnauthorized, types.ErrCodeUnauthorized, "Requires username and password")
 	}
 
-	_, token, err := s.Authz.Authenticate(username, password)
+	code := r.Header.Get(types.TwoFactorHeader)
+	_, token, err := s.Authz.Authenticate(username, password, code)
+	if _, ok := err.(userdb.TwoFactorRequiredError); ok {
+		return err
+	}
 	if err != nil {
 		logrus.WithField("username", username).WithError(err).Debug("Login failed")
-		http.Error(w, "Login failed", http.StatusUnauthorized)
-		return nil
+		return httputils.NewAPIError(http.StatusUnauthorized, types.ErrCodeLoginFailed, "Login failed")
 	}
 
 	return httputils.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"Token": token,
 	})
 }
+
+func (s *systemRouter) getMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	metrics.Handler().ServeHTTP(w, r)
+	return nil
+}
+
+// getACMEChallenge serves HTTP-01 challenge responses of ACME server, which
+// are routed from custom domains by the proxy.
+func (s *systemRouter) getACMEChallenge(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if s.ACME == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	resp, ok := s.ACME.ServeChallenge(vars["token"])
+	if !ok {
+		http.NotFound(w, r)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, err := w.Write([]byte(resp))
+	return err
+}
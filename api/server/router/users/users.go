@@ -0,0 +1,223 @@
+package users
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cloudway/platform/api/server/httputils"
+	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"golang.org/x/net/context"
+)
+
+type usersRouter struct {
+	*broker.Broker
+	routes []router.Route
+}
+
+func NewRouter(broker *broker.Broker) router.Router {
+	r := &usersRouter{Broker: broker}
+
+	r.routes = []router.Route{
+		router.WithDoc(router.NewGetRoute("/users/self/tokens", r.listTokens), router.Doc{
+			Summary:  "List personal access tokens",
+			Response: []*types.AccessToken{},
+		}),
+		router.WithDoc(router.NewPostRoute("/users/self/tokens", r.createToken), router.Doc{
+			Summary:  "Create a personal access token",
+			Body:     types.CreateToken{},
+			Response: types.AccessToken{},
+			Status:   http.StatusCreated,
+		}),
+		router.WithDoc(router.NewDeleteRoute("/users/self/tokens/{id}", r.revokeToken), router.Doc{
+			Summary: "Revoke a personal access token",
+		}),
+		router.WithDoc(router.NewGetRoute("/users/self/quota", r.getQuota), router.Doc{
+			Summary:  "Get resource quota and usage",
+			Response: types.QuotaUsage{},
+		}),
+		router.WithDoc(router.NewPutRoute("/users/self/password", r.changePassword), router.Doc{
+			Summary: "Change password",
+			Body:    types.ChangePassword{},
+		}),
+		router.WithDoc(router.NewGetRoute("/users/self/twofactor", r.getTwoFactor), router.Doc{
+			Summary:  "Get two-factor authentication status",
+			Response: types.TwoFactorStatus{},
+		}),
+		router.WithDoc(router.NewPostRoute("/users/self/twofactor", r.enrollTwoFactor), router.Doc{
+			Summary:  "Generate a TOTP secret for two-factor authentication",
+			Response: types.TwoFactorEnrollment{},
+			Status:   http.StatusCreated,
+		}),
+		router.WithDoc(router.NewPutRoute("/users/self/twofactor", r.enableTwoFactor), router.Doc{
+			Summary:  "Enable two-factor authentication",
+			Body:     types.TwoFactorCode{},
+			Response: types.RecoveryCodes{},
+		}),
+		router.WithDoc(router.NewDeleteRoute("/users/self/twofactor", r.disableTwoFactor), router.Doc{
+			Summary: "Disable two-factor authentication",
+			Params:  []router.Param{{Name: "code", In: "query", Required: true, Description: "A TOTP code or recovery code"}},
+		}),
+	}
+
+	return r
+}
+
+func (ur *usersRouter) Routes() []router.Route {
+	return ur.routes
+}
+
+func (ur *usersRouter) listTokens(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	tokens, err := ur.Users.ListTokens(user.Name)
+	if err != nil {
+		return err
+	}
+
+	result := make([]*types.AccessToken, len(tokens))
+	for i, t := range tokens {
+		result[i] = convertToken(t)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, result)
+}
+
+func (ur *usersRouter) createToken(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.CreateToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	var expiry time.Duration
+	if req.ExpiresIn != "" {
+		var err error
+		if expiry, err = time.ParseDuration(req.ExpiresIn); err != nil || expiry <= 0 {
+			return httputils.BadParameter("Invalid token expiration: %s", req.ExpiresIn)
+		}
+	}
+
+	// a token cannot be granted more permissions than the requester has
+	scopes := make([]userdb.Scope, len(req.Scopes))
+	for i, s := range req.Scopes {
+		scopes[i] = userdb.Scope(s)
+		if err := httputils.CheckScope(ctx, scopes[i]); err != nil {
+			return err
+		}
+	}
+
+	user := httputils.UserFromContext(ctx)
+	if len(scopes) == 0 && user.Scopes != nil {
+		scopes = user.Scopes
+	}
+
+	t, token, err := ur.Users.CreateToken(user.Name, req.Name, scopes, expiry)
+	if err != nil {
+		return err
+	}
+
+	result := convertToken(t)
+	result.Token = token
+	return httputils.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (ur *usersRouter) revokeToken(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	return ur.Users.RevokeToken(user.Name, vars["id"])
+}
+
+func (ur *usersRouter) changePassword(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.ChangePassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	return ur.Users.ChangePassword(user.Name, req.OldPassword, req.NewPassword)
+}
+
+func (ur *usersRouter) getTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var user userdb.BasicUser
+	if err := ur.Users.Find(httputils.UserFromContext(ctx).Name, &user); err != nil {
+		return err
+	}
+
+	var status types.TwoFactorStatus
+	if user.TwoFactorEnabled() {
+		status.Enabled = true
+		status.RecoveryCodes = len(user.TwoFactor.RecoveryCodes)
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &status)
+}
+
+func (ur *usersRouter) enrollTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	issuer := config.GetOrDefault("twofactor.issuer", "Cloudway")
+	secret, url, err := ur.Users.EnrollTwoFactor(user.Name, issuer)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusCreated, &types.TwoFactorEnrollment{Secret: secret, URL: url})
+}
+
+func (ur *usersRouter) enableTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var req types.TwoFactorCode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	codes, err := ur.Users.EnableTwoFactor(user.Name, req.Code)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, &types.RecoveryCodes{Codes: codes})
+}
+
+func (ur *usersRouter) disableTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	user := httputils.UserFromContext(ctx)
+	return ur.Users.DisableTwoFactor(user.Name, r.FormValue("code"))
+}
+
+func convertToken(t *userdb.AccessToken) *types.AccessToken {
+	scopes := make([]string, len(t.Scopes))
+	for i, s := range t.Scopes {
+		scopes[i] = string(s)
+	}
+	return &types.AccessToken{
+		ID:      t.ID,
+		Name:    t.Name,
+		Scopes:  scopes,
+		Created: t.Created,
+		Expires: t.Expires,
+	}
+}
+
+func (ur *usersRouter) getQuota(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	user := httputils.UserFromContext(ctx)
+	quota, usage, err := ur.GetQuota(user.Name)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, &types.QuotaUsage{
+		Quota: types.Quota{
+			Applications: quota.Applications,
+			Containers:   quota.Containers,
+			Memory:       quota.Memory,
+			CPUs:         quota.CPUs,
+		},
+		Usage: types.Quota{
+			Applications: usage.Applications,
+			Containers:   usage.Containers,
+			Memory:       usage.Memory,
+			CPUs:         usage.CPUs,
+		},
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
@@ -12,6 +14,9 @@ import (
 	"github.com/cloudway/platform/api/server/httputils"
 	"github.com/cloudway/platform/api/server/middleware"
 	"github.com/cloudway/platform/api/server/router"
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/metrics"
 )
 
 // versionMatcher defines a variable matcher to be parsed by the router
@@ -25,6 +30,11 @@ type Server struct {
 	routers     []router.Router
 	contextRoot string
 	Mux         *mux.Router
+
+	// tracks in-flight requests to be drained on shutdown
+	mu       sync.Mutex
+	inflight sync.WaitGroup
+	draining bool
 }
 
 // New returns a new instance of the server based on the specified configuration.
@@ -50,21 +60,106 @@ func (s *Server) Accept(addr string, listener net.Listener) {
 	s.servers = append(s.servers, httpServer)
 }
 
-// Close closes servers and thus stop receiving requests
+// AcceptRedirect sets a plain HTTP listener that redirects all requests to
+// the HTTPS port.
+func (s *Server) AcceptRedirect(addr string, listener net.Listener, httpsPort string) {
+	httpServer := &HTTPServer{
+		srv: &http.Server{
+			Addr:    addr,
+			Handler: redirectHTTPS(httpsPort),
+		},
+		l: listener,
+	}
+	s.servers = append(s.servers, httpServer)
+}
+
+// redirectHTTPS returns a handler redirects requests to the same URL on the
+// HTTPS port. The request method is preserved for non-GET requests.
+func redirectHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		u := *r.URL
+		u.Scheme, u.Host = "https", host
+
+		code := http.StatusPermanentRedirect
+		if r.Method == "GET" || r.Method == "HEAD" {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, u.String(), code)
+	})
+}
+
+// Close closes servers and thus stop receiving requests. Requests sent on
+// existing connections are rejected, while in-flight requests continue to
+// be served until drained.
 func (s *Server) Close() {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
 	for _, srv := range s.servers {
+		srv.srv.SetKeepAlivesEnabled(false)
 		if err := srv.Close(); err != nil {
 			logrus.Error(err)
 		}
 	}
 }
 
+// Drain waits for in-flight requests to finish within the timeout after
+// the server is closed. Returns false if the timeout expired.
+func (s *Server) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// trackRequests counts in-flight requests, and rejects new requests when
+// the server is shutting down.
+func (s *Server) trackRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		draining := s.draining
+		if !draining {
+			s.inflight.Add(1)
+		}
+		s.mu.Unlock()
+
+		if draining {
+			w.Header().Set("Connection", "close")
+			httputils.WriteError(w, r, httputils.NewAPIError(http.StatusServiceUnavailable,
+				types.ErrCodeUnavailable, "The server is shutting down, please try again later"))
+			return
+		}
+
+		defer s.inflight.Done()
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // serveAPI loops through all initialized servers and spawns goroutine
 // with Server method for each. It sets createMux() as Handler also.
 func (s *Server) serveAPI() error {
 	var chErrors = make(chan error, len(s.servers))
 	for _, srv := range s.servers {
-		srv.srv.Handler = s.Mux
+		if srv.srv.Handler == nil {
+			srv.srv.Handler = s.trackRequests(s.Mux)
+		}
 		go func(srv *HTTPServer) {
 			var err error
 			logrus.Infof("API server listen on %s", srv.l.Addr())
@@ -101,7 +196,7 @@ func (s *HTTPServer) Close() error {
 	return s.l.Close()
 }
 
-func (s *Server) makeHTTPHandler(handler httputils.APIFunc) http.HandlerFunc {
+func (s *Server) makeHTTPHandler(route string, handler httputils.APIFunc, scope userdb.Scope) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Define the context that we'll pass around to share info
 		//
@@ -109,7 +204,7 @@ func (s *Server) makeHTTPHandler(handler httputils.APIFunc) http.HandlerFunc {
 		// apply to all requests. Data that is specific to the
 		// immediate function being called should still be passed
 		// as 'args' on the function call.
-		ctx := context.Background()
+		ctx := context.WithValue(context.Background(), httputils.ScopeKey, scope)
 		handlerFunc := s.handleWithGlobalMiddlewares(handler)
 
 		vars := mux.Vars(r)
@@ -118,6 +213,7 @@ func (s *Server) makeHTTPHandler(handler httputils.APIFunc) http.HandlerFunc {
 		}
 
 		if err := handlerFunc(ctx, w, r, vars); err != nil {
+			metrics.BrokerErrors.WithLabelValues(route).Inc()
 			httputils.WriteError(w, r, err)
 		}
 	}
@@ -131,6 +227,11 @@ func (s *Server) InitRouter(routers ...router.Router) {
 	s.Mux = s.createMux()
 }
 
+// Routers returns the list of routers of the server.
+func (s *Server) Routers() []router.Router {
+	return s.routers
+}
+
 // createMux initializes the main router the server uses.
 func (s *Server) createMux() *mux.Router {
 	m := mux.NewRouter()
@@ -138,7 +239,7 @@ func (s *Server) createMux() *mux.Router {
 	logrus.Debugf("Regitering routers")
 	for _, apiRouter := range s.routers {
 		for _, r := range apiRouter.Routes() {
-			f := s.makeHTTPHandler(r.Handler())
+			f := instrument(r.Method(), r.Path(), s.makeHTTPHandler(r.Path(), r.Handler(), r.Scope()))
 
 			logrus.Debugf("Registering %s %s", r.Method(), r.Path())
 			m.Path(s.contextRoot + versionMatcher + r.Path()).Methods(r.Method()).Handler(f)
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+)
+
+func TestDrainRequests(t *testing.T) {
+	s := &Server{}
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := s.trackRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// start an in-flight request before shutting down
+	inflight := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("POST", "/applications/test/deploy", nil)
+		handler.ServeHTTP(inflight, req)
+		close(finished)
+	}()
+	<-started
+
+	s.Close()
+
+	// new requests are rejected while draining
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("request while draining: found status %d, expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if w.Header().Get("Connection") != "close" {
+		t.Errorf("request while draining: connection is not closed")
+	}
+	var resp types.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != types.ErrCodeUnavailable {
+		t.Errorf("request while draining: found %q, expected %q code", w.Body.String(), types.ErrCodeUnavailable)
+	}
+
+	// the in-flight request is not drained until finished
+	if s.Drain(50 * time.Millisecond) {
+		t.Fatal("Drain returned before the in-flight request finished")
+	}
+
+	close(release)
+	if !s.Drain(5 * time.Second) {
+		t.Fatal("Drain timed out after the in-flight request finished")
+	}
+	<-finished
+	if inflight.Code != http.StatusNoContent {
+		t.Errorf("in-flight request: found status %d, expected %d", inflight.Code, http.StatusNoContent)
+	}
+}
+
+func TestDrainIdle(t *testing.T) {
+	s := &Server{}
+	s.Close()
+	if !s.Drain(time.Second) {
+		t.Fatal("Drain timed out without in-flight requests")
+	}
+}
@@ -0,0 +1,32 @@
+package types
+
+// ErrorResponse is the response body of remote API when a request failed.
+// The Code is a stable machine readable identifier of the error, which
+// scripts can branch on, and the Message is meant for humans. Details
+// contains additional error specific information.
+type ErrorResponse struct {
+	Code    string
+	Message string
+	Details map[string]interface{} `json:",omitempty"`
+}
+
+// Generic error codes derived from HTTP status when an error doesn't have
+// a more specific code.
+const (
+	ErrCodeBadRequest      = "bad_request"
+	ErrCodeUnauthorized    = "unauthorized"
+	ErrCodeForbidden       = "forbidden"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeConflict        = "conflict"
+	ErrCodeTooManyRequests = "too_many_requests"
+	ErrCodeInternal        = "internal_error"
+	ErrCodeUnavailable     = "service_unavailable"
+)
+
+// Specific error codes that clients usually handle.
+const (
+	ErrCodeInvalidParameter  = "invalid_parameter"
+	ErrCodeLoginFailed       = "login_failed"
+	ErrCodeTwoFactorRequired = "two_factor_required"
+	ErrCodeQuotaExceeded     = "quota_exceeded"
+)
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/json"
+	"os"
 	"time"
 
 	"github.com/cloudway/platform/pkg/manifest"
@@ -30,6 +32,18 @@ type ApplicationInfo struct {
 	Framework *manifest.Plugin
 	Services  []*manifest.Plugin
 	Scaling   int
+	TimeZone  string             `json:",omitempty"`
+	Locale    string             `json:",omitempty"`
+	Health    []*ContainerHealth `json:",omitempty"`
+	Status    []*ContainerStatus `json:",omitempty"`
+
+	// True if any container of the application is crash looping.
+	Degraded bool `json:",omitempty"`
+
+	// The lifecycle state of the application, "running", "stopped" or
+	// "idle", and the time when the state was changed.
+	State        string
+	StateChanged time.Time
 }
 
 // CreateApplication struct contains post options of remote API:
@@ -39,6 +53,66 @@ type CreateApplication struct {
 	Framework string
 	Services  []string
 	Repo      string
+	TimeZone  string `json:",omitempty"`
+	Locale    string `json:",omitempty"`
+
+	// Create the application from the template instead of the framework
+	// and services.
+	Template string `json:",omitempty"`
+}
+
+// CloneApplication contains post options of remote API:
+// POST "/applications/{name}/clone"
+type CloneApplication struct {
+	Name string
+
+	// Copy the deployed repository instead of populating the repository
+	// from the framework template.
+	Repo bool `json:",omitempty"`
+}
+
+// SaveTemplate contains post options of remote API:
+// POST "/templates/"
+type SaveTemplate struct {
+	// The application saved as the template.
+	Application string
+
+	Name        string
+	Description string `json:",omitempty"`
+
+	// Save the deployed repository with the template.
+	Repo bool `json:",omitempty"`
+}
+
+// Template contains response of remote API:
+// GET "/templates/"
+type Template struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Created     time.Time
+	Plugins     []string
+	Env         map[string]string `json:",omitempty"`
+	TimeZone    string            `json:",omitempty"`
+	Locale      string            `json:",omitempty"`
+	Repo        bool              `json:",omitempty"`
+}
+
+// BulkOptions contains post options of remote API:
+// POST "/applications/bulk/"
+type BulkOptions struct {
+	Operation   string
+	Namespace   string            `json:",omitempty"`
+	Category    manifest.Category `json:",omitempty"`
+	Labels      map[string]string `json:",omitempty"`
+	Concurrency int               `json:",omitempty"`
+}
+
+// BulkResult contains per application result of remote API:
+// POST "/applications/bulk/"
+type BulkResult struct {
+	Name      string
+	Namespace string
+	Error     string `json:",omitempty"`
 }
 
 // ContainerJSONBase identifies a container.
@@ -57,14 +131,118 @@ type ContainerStatus struct {
 	Ports     []string
 	Uptime    int64
 	State     manifest.ActiveState
+
+	// The global IPv6 address if IPv6 networking is enabled.
+	IPv6Address string `json:",omitempty"`
+
+	// The number of restarts performed by Docker with the restart policy.
+	Restarts int `json:",omitempty"`
+
+	// The last exit of the container observed by the server.
+	LastExit *ContainerExit `json:",omitempty"`
+}
+
+// ContainerExit describes the last exit of a container.
+type ContainerExit struct {
+	ExitCode  int
+	OOMKilled bool `json:",omitempty"`
+	Time      time.Time
+}
+
+// ExecOptions holds parameters to execute command in application container.
+type ExecOptions struct {
+	Service   string
+	Container string
+	Cmd       []string
+	Tty       bool
+	Term      string
+	Width     int
+	Height    int
+}
+
+// ExecInspect contains response of remote API:
+// GET "/applications/{name}/exec/{id}"
+type ExecInspect struct {
+	ID          string
+	ContainerID string
+	Running     bool
+	ExitCode    int
+}
+
+// FileInfo contains response of remote API:
+// GET "/applications/{name}/files"
+type FileInfo struct {
+	Name       string
+	Size       int64
+	Mode       os.FileMode
+	ModTime    time.Time
+	LinkTarget string `json:",omitempty"`
+}
+
+// FileStatHeader is the response header of remote API:
+// GET "/applications/{name}/files/archive"
+// It contains base64 encoded JSON of FileInfo of the downloaded file.
+const FileStatHeader = "X-Cloudway-File-Stat"
+
+// ContainerHealth describes the result of health checks performed on a
+// container with health check declared in plugin manifest.
+type ContainerHealth struct {
+	ContainerJSONBase
+
+	// The health status, "starting", "healthy" or "unhealthy".
+	Status string
+
+	// The number of consecutive failed checks.
+	Failures int `json:",omitempty"`
+
+	// The number of restarts performed to recover the container.
+	Restarts int `json:",omitempty"`
+
+	// The time of the last check.
+	LastCheck time.Time
+
+	// The error reported by the last failed check.
+	Error string `json:",omitempty"`
 }
 
 // ProcessList contains response of remote API:
 // Get "/applications/{name}/procs"
+// The Error is set if processes of the container cannot be listed.
 type ProcessList struct {
 	ContainerJSONBase
 	Headers   []string
 	Processes [][]string
+	Parsed    []*Process `json:",omitempty"`
+	Error     string     `json:",omitempty"`
+}
+
+// Process describes a process running in a container.
+type Process struct {
+	User    string
+	PID     int
+	PPID    int
+	CPU     string
+	Start   string
+	TTY     string
+	Time    string
+	Command string
+}
+
+// Volume describes a persistent volume mounted into a container.
+type Volume struct {
+	Name       string
+	VolumeName string
+	MountPath  string
+	Driver     string
+	Size       int64
+	Used       int64
+}
+
+// ContainerVolumes contains response of remote API:
+// Get "/applications/{name}/volumes"
+type ContainerVolumes struct {
+	ContainerJSONBase
+	Volumes []*Volume
 }
 
 // ContainerStats contains response of remote API:
@@ -84,6 +262,19 @@ type ContainerStats struct {
 	BlockWrite       uint64
 }
 
+// ApplicationStats contains response of remote API:
+// Get "/applications/{name}/stats?stream=false"
+type ApplicationStats struct {
+	// The application name.
+	Name string
+
+	// Resource usage statistics of each container.
+	Containers []*ContainerStats
+
+	// Resource usage aggregated over all containers.
+	Total ContainerStats
+}
+
 // Branch is a branch of deployment.
 type Branch struct {
 	// The branch identifier.
@@ -96,6 +287,431 @@ type Branch struct {
 	Type string
 }
 
+// DeployOptions contains options of remote API:
+// POST "/applications/{name}/deploy" and PUT "/applications/{name}/repo"
+type DeployOptions struct {
+	// Deploy only if the current application is "healthy" or "unhealthy".
+	Condition string
+
+	// The deploy strategy, "all-at-once", "rolling", "blue-green" or "canary".
+	Strategy string
+
+	// The number of containers updated at a time in rolling deployment.
+	BatchSize int
+
+	// The number of failed containers tolerated in rolling deployment.
+	FailureThreshold int
+
+	// The number of times to retry deploying to a failed container.
+	Retries int
+
+	// Proceed with other containers on failure ("continue"), or stop and
+	// roll back updated containers ("rollback").
+	OnFailure string
+
+	// The percentage of containers updated in canary deployment.
+	CanaryPercent int
+
+	// The percentage of traffic routed to canary containers.
+	CanaryWeight int
+
+	// Only transfer files changed since the previous deployment.
+	Delta bool
+
+	// The variables set in the environment of the build.
+	BuildEnv map[string]string
+
+	// The secret variables set in the environment of the build, which
+	// are masked in the build log.
+	BuildSecrets map[string]string
+
+	// Pull the environment of the application into the build.
+	PullEnv bool
+}
+
+// BuildEnvHeader is the request header of remote API:
+// POST "/applications/{name}/deploy" and PUT "/applications/{name}/repo"
+// It contains the build variables encoded as URL query values "build-env"
+// and "build-secret". Build variables are never sent in URL query, which
+// is usually logged.
+const BuildEnvHeader = "X-Cloudway-Build-Env"
+
+// DeploymentVersion is a previously deployed repository that can be
+// rolled back to.
+type DeploymentVersion struct {
+	// The version number.
+	Version int
+
+	// The time when the version was deployed.
+	Created time.Time
+
+	// The size of repository archive.
+	Size int64
+
+	// True if the version is currently deployed.
+	Current bool
+}
+
+// Build contains response of remote API:
+// GET "/applications/{name}/builds"
+// GET "/deployments/{id}"
+// PUT "/applications/{name}/repo?async"
+type Build struct {
+	// The build identifier, used to retrieve the build log.
+	ID string
+
+	// The name of deployed application, only available when getting
+	// a deployment by ID.
+	Application string `json:",omitempty"`
+
+	// The deploy strategy.
+	Strategy string
+
+	// The time when the build was started and finished.
+	Started  time.Time
+	Finished time.Time
+
+	// The build status, "queued", "running", "building", "distributing",
+	// "succeeded" or "failed".
+	Status string
+
+	// The error occurred when deploying the application.
+	Error string `json:",omitempty"`
+
+	// The digest of uploaded content, used to detect repeated uploads.
+	Digest string `json:",omitempty"`
+
+	// The deployment status of each container.
+	Containers []*ContainerDeployment `json:",omitempty"`
+}
+
+// ContainerDeployment describes the last event happened on a container
+// during the distribution of a deployment.
+type ContainerDeployment struct {
+	ID string
+
+	// The event happened on the container, "copying", "copied", "verified",
+	// "signaled", "restarted", "healthy", "unhealthy", "failed" or
+	// "rolled-back".
+	Event string
+
+	// The number of bytes copied to the container.
+	Bytes int64 `json:",omitempty"`
+
+	// The error occurred on the container.
+	Error string `json:",omitempty"`
+
+	// The time when the event happened.
+	Updated time.Time
+}
+
+// BuildLock contains response of remote API:
+// GET "/applications/{name}/builds/lock"
+type BuildLock struct {
+	// Whether the build lock is held by a deployment.
+	Locked bool
+
+	// The operation holding the lock, "deploy", "upload" or "rollback".
+	Operation string `json:",omitempty"`
+
+	// The user started the operation.
+	User string `json:",omitempty"`
+
+	// The time when the lock was acquired.
+	Acquired time.Time
+
+	// The number of deployments waiting for the lock.
+	Waiting int `json:",omitempty"`
+}
+
+// CronRun contains response of remote API:
+// GET "/applications/{name}/cron/runs"
+type CronRun struct {
+	// The run identifier.
+	ID string
+
+	// The name of the cron job.
+	Job string
+
+	// The service name if the job is declared by a service plugin.
+	Service string `json:",omitempty"`
+
+	// The container in which the job was executed.
+	Container string
+
+	// The schedule and command of the job.
+	Schedule string
+	Command  string
+
+	// The time when the job was started and finished.
+	Started  time.Time
+	Finished time.Time
+
+	// The exit code of the command, -1 if the command cannot be executed.
+	ExitCode int
+
+	// The error occurred when executing the command.
+	Error string `json:",omitempty"`
+
+	// The output of the command, truncated if too long.
+	Output string `json:",omitempty"`
+}
+
+// Backup contains response of remote API:
+// GET "/applications/{name}/backups" and POST "/applications/{name}/backup"
+type Backup struct {
+	// The backup identifier.
+	ID string
+
+	// The time when the backup was created.
+	Created time.Time
+
+	// The size of backup archive.
+	Size int64
+}
+
+// LogsOptions contains options of remote API:
+// GET "/applications/{name}/logs"
+type LogsOptions struct {
+	// Keep the stream open and send new log entries as they are written.
+	Follow bool
+
+	// The number of lines to show from the end of the logs, or "all".
+	Tail string
+
+	// Show logs since the given timestamp or relative duration.
+	Since string
+}
+
+// LogSearchOptions contains options of remote API:
+// GET "/applications/{name}/logs/search"
+type LogSearchOptions struct {
+	// Terms contained in log messages.
+	Query string
+
+	// Search logs in the range of timestamps or relative durations.
+	Since, Until string
+
+	// The minimum severity of log entries.
+	Severity string
+
+	// The maximum number of most recent entries.
+	Limit int
+}
+
+// LogEntry contains response of remote API:
+// GET "/applications/{name}/logs/search"
+type LogEntry struct {
+	Time      time.Time
+	Container string
+	Stream    string
+	Severity  string
+	Message   string
+}
+
+// CreateToken contains request body of remote API:
+// POST "/users/self/tokens"
+type CreateToken struct {
+	// The descriptive name of the token.
+	Name string
+
+	// The permissions granted to the token, "read", "write" or "admin".
+	// All permissions of the user are granted if not specified.
+	Scopes []string
+
+	// The duration after which the token expires, such as "720h".
+	// The token never expires if not specified.
+	ExpiresIn string
+}
+
+// AccessToken contains response of remote API:
+// GET "/users/self/tokens" and POST "/users/self/tokens"
+type AccessToken struct {
+	// The token identifier used to revoke the token.
+	ID string
+
+	// The descriptive name of the token.
+	Name string
+
+	// The permissions granted to the token.
+	Scopes []string
+
+	// The time when the token was created.
+	Created time.Time
+
+	// The time when the token expires, zero if never expires.
+	Expires time.Time
+
+	// The token string, only returned when the token is created.
+	Token string `json:",omitempty"`
+}
+
+// CreateWebhook contains request body of remote API:
+// POST "/applications/{name}/hooks"
+type CreateWebhook struct {
+	// The URL that receives event payloads.
+	URL string
+
+	// The events subscribed, "deploy.started", "deploy.succeeded",
+	// "deploy.failed" or "container.crashloop". All events are subscribed
+	// if not specified.
+	Events []string `json:",omitempty"`
+
+	// The secret used to sign payloads. A random secret is generated
+	// if not specified.
+	Secret string `json:",omitempty"`
+}
+
+// Webhook contains response of remote API:
+// GET "/applications/{name}/hooks" and POST "/applications/{name}/hooks"
+type Webhook struct {
+	// The webhook identifier.
+	ID string
+
+	// The URL that receives event payloads.
+	URL string
+
+	// The events subscribed by the webhook.
+	Events []string
+
+	// The time when the webhook was created.
+	Created time.Time
+
+	// The secret used to sign payloads, only returned when the webhook
+	// is created.
+	Secret string `json:",omitempty"`
+}
+
+// WebhookDelivery contains response of remote API:
+// GET "/applications/{name}/hooks/{id}/deliveries"
+type WebhookDelivery struct {
+	// The delivery identifier.
+	ID string
+
+	// The event delivered.
+	Event string
+
+	// The time when the delivery was created.
+	Created time.Time
+
+	// The number of delivery attempts.
+	Attempts int
+
+	// The HTTP status code responded by the webhook in the last attempt.
+	StatusCode int
+
+	// The error occurred in the last attempt.
+	Error string `json:",omitempty"`
+
+	// Whether the payload is successfully delivered.
+	Delivered bool
+
+	// The payload delivered.
+	Payload json.RawMessage
+}
+
+// PushHook contains response of remote API:
+// GET "/applications/{name}/push-hook" and POST "/applications/{name}/push-hook"
+type PushHook struct {
+	// The path of the webhook URL relative to the API root, which receives
+	// push events from GitHub, GitLab or Bitbucket.
+	Path string
+
+	// The secret configured in the Git hosting service to sign payloads.
+	// Bitbucket Cloud doesn't sign payloads, the secret is passed by the
+	// token query parameter instead.
+	Secret string
+}
+
+// BindRepository contains request of remote API:
+// PUT "/applications/{name}/remote"
+type BindRepository struct {
+	// The repository driver, "github", "gitlab" or "git".
+	Driver string
+
+	// The repository name, "owner/repo" for GitHub, the project path for
+	// GitLab, or the SSH URL for plain Git server.
+	Repo string
+
+	// The API token used to register the deploy key. The public key must
+	// be installed by user if no token is given.
+	Token string `json:",omitempty"`
+}
+
+// RemoteRepository contains response of remote API:
+// GET "/applications/{name}/remote" and PUT "/applications/{name}/remote"
+type RemoteRepository struct {
+	Driver string
+	Repo   string
+	URL    string
+
+	// The public deploy key of the application.
+	PublicKey string
+
+	// Whether the deploy key is registered in the Git hosting service.
+	KeyRegistered bool
+}
+
+// Domain contains request and response of remote API:
+// GET "/applications/{name}/domains" and POST "/applications/{name}/domains"
+type Domain struct {
+	// The custom domain name attached to the application.
+	Name string
+
+	// The TLS certificate of the domain, nil if no certificate installed.
+	Certificate *Certificate `json:",omitempty"`
+}
+
+// Certificate contains request and response of remote API:
+// PUT "/applications/{name}/domains/{domain}/certificate"
+type Certificate struct {
+	// The certificate chain and private key in PEM format, only present
+	// in the request.
+	Certificate string `json:",omitempty"`
+	PrivateKey  string `json:",omitempty"`
+
+	// The expiration time of the certificate.
+	NotAfter time.Time
+
+	// True if the certificate is issued by ACME and renewed automatically.
+	ACME bool
+}
+
+// Secret contains request and response of remote API:
+// GET "/applications/{name}/secrets" and PUT "/applications/{name}/secrets/{key}"
+type Secret struct {
+	Name string
+
+	// The value of the secret, only present in the request. Values of
+	// secrets are never returned.
+	Value string `json:",omitempty"`
+
+	// How the secret is injected into containers, "file" to write the
+	// secret to /run/secrets, or "env" to set an environment variable.
+	Mount string `json:",omitempty"`
+
+	// The version increased when the secret is updated or rotated.
+	Version int `json:",omitempty"`
+
+	Created time.Time
+	Updated time.Time
+}
+
+// SecretAccess contains response of remote API:
+// GET "/applications/{name}/secrets/audit"
+type SecretAccess struct {
+	Time time.Time
+
+	// The user performed the operation, or the container into which the
+	// secret is injected.
+	User      string `json:",omitempty"`
+	Container string `json:",omitempty"`
+
+	// The operation, "create", "update", "rotate", "remove" or "inject".
+	Action string
+	Secret string
+}
+
 // Deployments contains response of remote API:
 // GET "/applications/{name}/deploy"
 type Deployments struct {
@@ -105,3 +721,280 @@ type Deployments struct {
 	// All deployment branches
 	Branches []*Branch
 }
+
+// Quota contains request of remote API:
+// PUT "/admin/users/{name}/quota"
+// A zero value of a limit means unlimited.
+type Quota struct {
+	Applications int
+	Containers   int
+	Memory       int64
+	CPUs         float64
+}
+
+// QuotaUsage contains response of remote API:
+// GET "/users/self/quota" and GET "/admin/users/{name}/quota"
+type QuotaUsage struct {
+	Quota Quota
+	Usage Quota
+}
+
+// Resources contains request and response of remote API:
+// GET and PUT "/applications/{name}/resources"
+// The service name is empty for framework containers. A zero value of a
+// limit means unlimited in response, or unchanged in request.
+type Resources struct {
+	Service   string `json:",omitempty"`
+	Memory    int64
+	CPUs      float64
+	PidsLimit int64
+}
+
+// LogConfig contains request and response of remote API:
+// GET and PUT "/applications/{name}/logging"
+// Custom is true in response if the default configuration is overridden by
+// the application. An empty driver in request restores the default.
+type LogConfig struct {
+	Driver  string
+	Options map[string]string `json:",omitempty"`
+	Custom  bool              `json:",omitempty"`
+}
+
+// NetworkGrants contains response of remote API:
+// GET "/applications/{name}/network"
+// Grants contains applications granted access to services of the application,
+// and Granted contains applications whose services are accessible from the
+// application.
+type NetworkGrants struct {
+	Network   string
+	Isolation bool
+	Grants    []string `json:",omitempty"`
+	Granted   []string `json:",omitempty"`
+}
+
+// UserInfo contains response of remote API:
+// GET "/admin/users/" and GET "/admin/users/{name}"
+type UserInfo struct {
+	Name         string
+	Namespace    string `json:",omitempty"`
+	Role         string
+	Inactive     bool
+	Applications []string
+
+	// The registration state of an inactive user, "verification" or
+	// "approval".
+	Pending string `json:",omitempty"`
+}
+
+// CreateUser contains request of remote API:
+// POST "/admin/users/"
+type CreateUser struct {
+	Name      string
+	Password  string
+	Namespace string `json:",omitempty"`
+
+	// The user role, "admin", "developer" or "viewer", defaults to developer.
+	Role string `json:",omitempty"`
+}
+
+// Register contains request of remote API:
+// POST "/auth/register"
+type Register struct {
+	Email     string
+	Password  string
+	Namespace string `json:",omitempty"`
+}
+
+// Confirmation contains response of remote API:
+// POST "/auth/confirm"
+type Confirmation struct {
+	Name string
+
+	// False if the user is waiting for approval of an administrator.
+	Activated bool
+}
+
+// ResetPassword contains request of remote API:
+// PUT "/admin/users/{name}/password"
+type ResetPassword struct {
+	Password string
+}
+
+// ForgotPassword contains request of remote API:
+// POST "/auth/password/forgot"
+type ForgotPassword struct {
+	Email string
+}
+
+// PasswordReset contains request of remote API:
+// POST "/auth/password/reset"
+type PasswordReset struct {
+	Token    string
+	Password string
+}
+
+// TwoFactorHeader is the request header of remote API:
+// POST "/auth"
+// It contains the code of two-factor authentication if enabled.
+const TwoFactorHeader = "X-Cloudway-OTP"
+
+// TwoFactorStatus contains response of remote API:
+// GET "/users/self/twofactor"
+type TwoFactorStatus struct {
+	Enabled bool
+
+	// The number of unused recovery codes.
+	RecoveryCodes int
+}
+
+// TwoFactorEnrollment contains response of remote API:
+// POST "/users/self/twofactor"
+type TwoFactorEnrollment struct {
+	Secret string
+
+	// The otpauth URL to be added to authenticator apps.
+	URL string
+}
+
+// TwoFactorCode contains request of remote API:
+// PUT "/users/self/twofactor"
+type TwoFactorCode struct {
+	Code string
+}
+
+// RecoveryCodes contains response of remote API:
+// PUT "/users/self/twofactor"
+type RecoveryCodes struct {
+	Codes []string
+}
+
+// ChangePassword contains request of remote API:
+// PUT "/users/self/password"
+type ChangePassword struct {
+	OldPassword string
+	NewPassword string
+}
+
+// GCResult contains response of remote API:
+// POST "/admin/gc"
+type GCResult struct {
+	// True if garbage is only reported without removing
+	DryRun bool
+
+	// The builder containers not used by any build
+	Builders []string `json:",omitempty"`
+
+	// The images built for plugins and not used by any container
+	Images []string `json:",omitempty"`
+
+	// The temporary files left by uploads and deployments
+	TempFiles []string `json:",omitempty"`
+
+	// The errors occurred when removing garbage
+	Errors []string `json:",omitempty"`
+}
+
+// UserImportResult contains response of remote API:
+// POST "/admin/users/import"
+type UserImportResult struct {
+	// The users imported into the database
+	Imported []string
+
+	// The users skipped due to conflict with existing users
+	Skipped []string
+}
+
+// PluginQuery contains query parameters of remote API:
+// GET "/plugins/"
+type PluginQuery struct {
+	// Only search user defined plugins.
+	User bool
+
+	// The plugin category, "Framework" or "Service".
+	Category string
+
+	// The text searched in name, display name, description and vendor.
+	Text string
+
+	// Comma separated version constraints, e.g. ">=1.2,<2".
+	Version string
+
+	// The sort key, "name", "display-name", "vendor" or "version",
+	// prefixed with "-" for descending order.
+	Sort string
+
+	// The number of plugins skipped and the maximum number of plugins
+	// returned.
+	Offset int
+	Limit  int
+}
+
+// PluginAccess contains response of remote API:
+// GET "/plugins/{name}/access"
+type PluginAccess struct {
+	// The plugin visibility, "private", "shared" or "public".
+	Visibility string
+
+	// Namespaces granted access to the shared plugin.
+	Grants []string `json:",omitempty"`
+}
+
+// PluginValidation contains response of remote API:
+// POST "/plugins/validate"
+type PluginValidation struct {
+	// True if no error is found in the plugin.
+	Valid bool
+
+	// Problems found in the plugin, including errors and warnings.
+	Diagnostics manifest.Diagnostics
+}
+
+// ImageScan contains response of remote API:
+// GET "/plugins/{tag}/scan"
+type ImageScan struct {
+	Image     string
+	Scanner   string
+	ScannedAt time.Time
+
+	// The number of vulnerabilities of each severity.
+	Summary map[string]int
+
+	Vulnerabilities []*Vulnerability
+}
+
+// Vulnerability is a known vulnerability found in a package of the image.
+type Vulnerability struct {
+	ID           string
+	Package      string
+	Version      string
+	FixedVersion string `json:",omitempty"`
+	Severity     string
+	Title        string `json:",omitempty"`
+	Link         string `json:",omitempty"`
+}
+
+// NamespaceHeader is the request header of remote API:
+// "/applications/..." and "/namespace/members/..."
+// It selects the namespace shared by another user that the request
+// operates on, instead of the namespace of the authenticated user.
+const NamespaceHeader = "X-Cloudway-Namespace"
+
+// TeamMember contains response of remote API:
+// GET "/namespace/members"
+// and request of remote API:
+// PUT "/namespace/members/{user}"
+type TeamMember struct {
+	Name string `json:",omitempty"`
+
+	// The role of the member, "owner", "deployer" or "viewer".
+	Role string
+}
+
+// Team contains response of remote API:
+// GET "/namespace/teams"
+type Team struct {
+	Namespace    string
+	Owner        string
+	Role         string
+	Applications []string
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"crypto/rand"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -35,18 +36,26 @@ func NewAuthenticator(userdb *userdb.UserDatabase) (*Authenticator, error) {
 
 type customClaims struct {
 	*jwt.StandardClaims
-	Namespace string `json:"ns"`
+	Namespace string      `json:"ns"`
+	Admin     bool        `json:"adm,omitempty"`
+	Role      userdb.Role `json:"role,omitempty"`
 }
 
-// Authenticate user with name and password. Returns the User object
-// and a token.
-func (auth *Authenticator) Authenticate(username, password string) (*userdb.BasicUser, string, error) {
+// Authenticate user with name and password. The code of two-factor
+// authentication is required if enabled for the user. Returns the User
+// object and a token.
+func (auth *Authenticator) Authenticate(username, password, code string) (*userdb.BasicUser, string, error) {
 	// Authenticate user by user database
 	user, err := auth.userdb.Authenticate(username, password)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Verify the TOTP code or recovery code
+	if err = auth.userdb.VerifyTwoFactor(user, code); err != nil {
+		return nil, "", err
+	}
+
 	// Create a new token object, specifying singing method and the claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &customClaims{
 		&jwt.StandardClaims{
@@ -54,6 +63,8 @@ func (auth *Authenticator) Authenticate(username, password string) (*userdb.Basi
 			Subject:   user.Name,
 		},
 		user.Namespace,
+		user.Admin,
+		user.Role,
 	})
 
 	// Sign and get the complete encoded token as a string using the secret
@@ -62,10 +73,26 @@ func (auth *Authenticator) Authenticate(username, password string) (*userdb.Basi
 	return user, tokenString, err
 }
 
-// Verify the current http request is authorized.
+// Verify the current http request is authorized. The request can be
+// authorized by either a JWT token or a personal access token. Requests
+// without the Authorization header can be authorized by a verified client
+// certificate of machine users.
 func (auth *Authenticator) Verify(r *http.Request) (*userdb.BasicUser, error) {
 	var claims customClaims
 
+	// Verify client certificate, which is verified against the client CA
+	// by the TLS listener, the common name is the user name.
+	if r.Header.Get("Authorization") == "" && r.TLS != nil && len(r.TLS.VerifiedChains) != 0 {
+		return auth.verifyCertificate(r.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}
+
+	// Verify personal access token against user database
+	if tok, err := request.AuthorizationHeaderExtractor.ExtractToken(r); err == nil {
+		if strings.HasPrefix(tok, userdb.TokenPrefix) {
+			return auth.userdb.VerifyToken(tok)
+		}
+	}
+
 	// Get token from request
 	_, err := request.ParseFromRequestWithClaims(r, request.AuthorizationHeaderExtractor, &claims,
 		func(token *jwt.Token) (interface{}, error) {
@@ -77,5 +104,34 @@ func (auth *Authenticator) Verify(r *http.Request) (*userdb.BasicUser, error) {
 		return nil, err
 	}
 
-	return &userdb.BasicUser{Name: claims.Subject, Namespace: claims.Namespace}, nil
+	// The user may be disabled, removed or have the role changed after
+	// the token was issued, so the claims are checked against the user
+	// database.
+	return auth.findActiveUser(claims.Subject)
+}
+
+// verifyCertificate returns the user identified by the client certificate.
+func (auth *Authenticator) verifyCertificate(name string) (*userdb.BasicUser, error) {
+	user, err := auth.findActiveUser(name)
+	if err == nil {
+		logrus.Debugf("Authenticated user by client certificate: %v", name)
+	}
+	return user, err
+}
+
+// findActiveUser returns the user in the user database, which must not be
+// disabled.
+func (auth *Authenticator) findActiveUser(name string) (*userdb.BasicUser, error) {
+	if name == "" {
+		return nil, userdb.AuthenticationError(name)
+	}
+
+	var user userdb.BasicUser
+	if err := auth.userdb.Find(name, &user); err != nil {
+		return nil, err
+	}
+	if user.Inactive {
+		return nil, userdb.InactiveUserError(name)
+	}
+	return &user, nil
 }
@@ -1,20 +1,31 @@
 package auth_test
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudway/platform/auth"
 	"github.com/cloudway/platform/auth/userdb"
+	_ "github.com/cloudway/platform/auth/userdb/bolt"
+	_ "github.com/cloudway/platform/auth/userdb/memory"
 	_ "github.com/cloudway/platform/auth/userdb/mongodb"
+	_ "github.com/cloudway/platform/auth/userdb/postgres"
+	"github.com/cloudway/platform/pkg/totp"
 )
 
 func TestAuthenticator(t *testing.T) {
-	os.Setenv("CLOUDWAY_USERDB_URL", "mongodb://127.0.0.1:27017/authz_test")
+	// run against other user database backends by setting the URL
+	if os.Getenv("CLOUDWAY_USERDB_URL") == "" {
+		os.Setenv("CLOUDWAY_USERDB_URL", "memory://authz_test")
+	}
 
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Authenticator Suite")
@@ -53,18 +64,18 @@ var _ = Describe("Authenticator", func() {
 
 	Describe("Authenticate", func() {
 		It("should success with correct password", func() {
-			user, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD)
+			user, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(user.Name).To(Equal(TEST_USER))
 		})
 
 		It("should fail with incorrect password", func() {
-			_, _, err := authz.Authenticate(TEST_USER, "unknown")
+			_, _, err := authz.Authenticate(TEST_USER, "unknown", "")
 			Expect(err).To(HaveOccurred())
 		})
 
 		It("should fail when user does not exist", func() {
-			_, _, err := authz.Authenticate("nobody@example.com", "test")
+			_, _, err := authz.Authenticate("nobody@example.com", "test", "")
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -73,7 +84,7 @@ var _ = Describe("Authenticator", func() {
 		It("should success with correct token", func() {
 			var err error
 
-			_, token, err := authz.Authenticate(TEST_USER, TEST_PASSWORD)
+			_, token, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
 			Expect(err).NotTo(HaveOccurred())
 
 			// create fake request
@@ -112,5 +123,170 @@ var _ = Describe("Authenticator", func() {
 			_, err = authz.Verify(r)
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("should success with verified client certificate", func() {
+			r, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: TEST_USER}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			user, err := authz.Verify(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(TEST_USER))
+			Expect(user.Namespace).To(Equal(TEST_NAMESPACE))
+
+			cert.Subject.CommonName = "nobody@example.com"
+			_, err = authz.Verify(r)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail with unverified client certificate", func() {
+			r, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: TEST_USER}}
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			_, err = authz.Verify(r)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail for disabled user", func() {
+			_, token, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.Update(TEST_USER, userdb.Args{"inactive": true})).To(Succeed())
+
+			r, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			r.Header.Set("Authorization", "bearer "+token)
+			_, err = authz.Verify(r)
+			Expect(err).To(Equal(userdb.InactiveUserError(TEST_USER)))
+		})
+
+		It("should use the current role of user", func() {
+			_, token, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.Update(TEST_USER, userdb.Args{"role": userdb.RoleViewer})).To(Succeed())
+
+			r, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			r.Header.Set("Authorization", "bearer "+token)
+			user, err := authz.Verify(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.GetRole()).To(Equal(userdb.RoleViewer))
+		})
+
+		It("should carry user role in token", func() {
+			const VIEWER = "viewer@example.com"
+			viewer := userdb.BasicUser{Name: VIEWER, Role: userdb.RoleViewer}
+			Expect(db.Create(&viewer, TEST_PASSWORD)).To(Succeed())
+			defer db.Remove(VIEWER)
+
+			_, token, err := authz.Authenticate(VIEWER, TEST_PASSWORD, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			r, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			r.Header.Set("Authorization", "bearer "+token)
+			user, err := authz.Verify(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.GetRole()).To(Equal(userdb.RoleViewer))
+			Expect(user.HasScope(userdb.ScopeRead)).To(BeTrue())
+			Expect(user.HasScope(userdb.ScopeWrite)).To(BeFalse())
+		})
+	})
+
+	Describe("Two-factor authentication", func() {
+		var secret string
+		var recovery []string
+
+		code := func(offset int64) string {
+			c, err := totp.Code(secret, totp.Step(time.Now())+offset)
+			Expect(err).NotTo(HaveOccurred())
+			return c
+		}
+
+		BeforeEach(func() {
+			var err error
+			secret, _, err = db.EnrollTwoFactor(TEST_USER, "Cloudway")
+			Expect(err).NotTo(HaveOccurred())
+			recovery, err = db.EnableTwoFactor(TEST_USER, code(0))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recovery).To(HaveLen(10))
+		})
+
+		It("should require code when enabled", func() {
+			_, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
+			Expect(err).To(Equal(userdb.TwoFactorRequiredError(TEST_USER)))
+
+			_, _, err = authz.Authenticate(TEST_USER, TEST_PASSWORD, "000000x")
+			Expect(err).To(Equal(userdb.InvalidTwoFactorCodeError{}))
+		})
+
+		It("should success with a fresh code", func() {
+			_, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, code(1))
+			Expect(err).NotTo(HaveOccurred())
+
+			// the same code cannot be reused
+			_, _, err = authz.Authenticate(TEST_USER, TEST_PASSWORD, code(1))
+			Expect(err).To(Equal(userdb.InvalidTwoFactorCodeError{}))
+		})
+
+		It("should accept a recovery code only once", func() {
+			_, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, recovery[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, err = authz.Authenticate(TEST_USER, TEST_PASSWORD, recovery[0])
+			Expect(err).To(Equal(userdb.InvalidTwoFactorCodeError{}))
+		})
+
+		It("should not accept a code twice in concurrent logins", func() {
+			// both logins read the user before any code is verified
+			var first, second userdb.BasicUser
+			Expect(db.Find(TEST_USER, &first)).To(Succeed())
+			Expect(db.Find(TEST_USER, &second)).To(Succeed())
+
+			Expect(db.VerifyTwoFactor(&first, recovery[0])).To(Succeed())
+			Expect(db.VerifyTwoFactor(&second, recovery[0])).To(Equal(userdb.InvalidTwoFactorCodeError{}))
+
+			c := code(1)
+			Expect(db.Find(TEST_USER, &second)).To(Succeed())
+			Expect(db.VerifyTwoFactor(&first, c)).To(Succeed())
+			Expect(db.VerifyTwoFactor(&second, c)).To(Equal(userdb.InvalidTwoFactorCodeError{}))
+		})
+
+		It("should store the secret encrypted", func() {
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.TwoFactor.Secret).NotTo(ContainSubstring(secret))
+		})
+
+		It("should not require code after disabled", func() {
+			Expect(db.DisableTwoFactor(TEST_USER, recovery[1])).To(Succeed())
+			_, _, err := authz.Authenticate(TEST_USER, TEST_PASSWORD, "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Role", func() {
+		It("should default to developer role", func() {
+			user := userdb.BasicUser{Name: TEST_USER}
+			Expect(user.GetRole()).To(Equal(userdb.RoleDeveloper))
+			Expect(user.HasScope(userdb.ScopeWrite)).To(BeTrue())
+			Expect(user.HasScope(userdb.ScopeAdmin)).To(BeFalse())
+		})
+
+		It("should grant all scopes to administrator", func() {
+			user := userdb.BasicUser{Name: TEST_USER, Admin: true, Role: userdb.RoleViewer}
+			Expect(user.GetRole()).To(Equal(userdb.RoleAdmin))
+			Expect(user.HasScope(userdb.ScopeAdmin)).To(BeTrue())
+		})
+
+		It("should reject unknown role", func() {
+			_, err := userdb.ParseRole("superuser")
+			Expect(err).To(Equal(userdb.InvalidRoleError("superuser")))
+		})
 	})
 })
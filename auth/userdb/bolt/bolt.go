@@ -0,0 +1,154 @@
+// Package bolt provides a user database embedded in a BoltDB file, so small
+// installations don't need a database server. The user database is selected
+// by "bolt:///path/to/users.db" URL. The database file is locked by the
+// process that opened it, so user management commands cannot be run while
+// the API server is running. The database is shared by users in the same
+// process.
+package bolt
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/auth/userdb/docstore"
+)
+
+// The time to wait for the lock of the database file.
+const openTimeout = 5 * time.Second
+
+func init() {
+	prev := userdb.NewPlugin
+	userdb.NewPlugin = func(dbtype, dburl string) (userdb.Plugin, error) {
+		if dbtype != "bolt" {
+			return prev(dbtype, dburl)
+		}
+
+		u, err := url.Parse(dburl)
+		if err != nil {
+			return nil, err
+		}
+		if u.Path == "" {
+			return nil, errors.New("BoltDB database file not configured")
+		}
+
+		store, err := Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		return docstore.New(store), nil
+	}
+}
+
+// Store is a key-value store backed by a BoltDB file.
+type Store struct {
+	db   *bolt.DB
+	path string
+	refs int
+}
+
+// stores tracks opened stores, since the database file cannot be opened
+// more than once even in the same process.
+var stores = struct {
+	sync.Mutex
+	m map[string]*Store
+}{m: make(map[string]*Store)}
+
+// Open opens the BoltDB file, the file is created if it does not exist.
+func Open(path string) (*Store, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stores.Lock()
+	defer stores.Unlock()
+
+	if s := stores.m[path]; s != nil {
+		s.refs++
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, path: path, refs: 1}
+	stores.m[path] = s
+	return s, nil
+}
+
+func (s *Store) View(fn func(tx docstore.Tx) error) error {
+	return s.db.View(func(btx *bolt.Tx) error {
+		return fn(tx{btx})
+	})
+}
+
+func (s *Store) Update(fn func(tx docstore.Tx) error) error {
+	return s.db.Update(func(btx *bolt.Tx) error {
+		return fn(tx{btx})
+	})
+}
+
+// Close closes the database file when the last user of the store closed.
+func (s *Store) Close() error {
+	stores.Lock()
+	defer stores.Unlock()
+
+	if s.refs--; s.refs > 0 {
+		return nil
+	}
+	delete(stores.m, s.path)
+	return s.db.Close()
+}
+
+type tx struct {
+	*bolt.Tx
+}
+
+func (t tx) Get(bucket, key string) ([]byte, error) {
+	b := t.Bucket([]byte(bucket))
+	if b == nil {
+		return nil, nil
+	}
+	// the value is only valid in the transaction
+	if v := b.Get([]byte(key)); v != nil {
+		return append([]byte{}, v...), nil
+	}
+	return nil, nil
+}
+
+func (t tx) Put(bucket, key string, value []byte) error {
+	b, err := t.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), value)
+}
+
+func (t tx) Delete(bucket, key string) error {
+	b := t.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.Delete([]byte(key))
+}
+
+func (t tx) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	b := t.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		return fn(string(k), append([]byte{}, v...))
+	})
+}
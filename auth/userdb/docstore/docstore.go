@@ -0,0 +1,363 @@
+// Package docstore implements the user database plugin on top of a simple
+// transactional key-value store. Users are saved as BSON documents, so the
+// same filters and update fields accepted by the MongoDB plugin are
+// supported by backends without native document queries.
+package docstore
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// The buckets of the key-value store.
+const (
+	UsersBucket      = "users"      // user name to user document
+	NamespacesBucket = "namespaces" // namespace to user name
+	SecretsBucket    = "secrets"    // secret key to secret value
+)
+
+// Store is a transactional key-value store organized by buckets.
+type Store interface {
+	// View runs the function in a read-only transaction.
+	View(fn func(tx Tx) error) error
+
+	// Update runs the function in a read-write transaction, which is
+	// committed if the function returns nil, otherwise rolled back.
+	Update(fn func(tx Tx) error) error
+
+	// Close the store.
+	Close() error
+}
+
+// Tx is a transaction of the key-value store.
+type Tx interface {
+	// Get returns the value of the key in the bucket, or nil if the key
+	// does not exist.
+	Get(bucket, key string) ([]byte, error)
+
+	// Put sets the value of the key in the bucket.
+	Put(bucket, key string, value []byte) error
+
+	// Delete removes the key from the bucket.
+	Delete(bucket, key string) error
+
+	// ForEach calls the function for each key in the bucket in key order.
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+}
+
+type plugin struct {
+	store Store
+}
+
+// New returns a user database plugin backed by the key-value store.
+func New(store Store) userdb.Plugin {
+	return &plugin{store}
+}
+
+func (p *plugin) Create(user userdb.User) error {
+	basic := user.Basic()
+	data, err := bson.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return p.store.Update(func(tx Tx) error {
+		if v, err := tx.Get(UsersBucket, basic.Name); err != nil || v != nil {
+			if err == nil {
+				err = userdb.DuplicateUserError(basic.Name)
+			}
+			return err
+		}
+		if err := claimNamespace(tx, basic.Name, basic.Namespace); err != nil {
+			return err
+		}
+		return tx.Put(UsersBucket, basic.Name, data)
+	})
+}
+
+func (p *plugin) SetNamespace(username, namespace string) error {
+	return p.store.Update(func(tx Tx) error {
+		doc, err := getDoc(tx, username)
+		if err != nil {
+			return err
+		}
+		if err = reindex(tx, username, doc, namespace); err != nil {
+			return err
+		}
+		doc["namespace"] = namespace
+		return putDoc(tx, username, doc)
+	})
+}
+
+func (p *plugin) Find(name string, result userdb.User) error {
+	return p.store.View(func(tx Tx) error {
+		data, err := tx.Get(UsersBucket, name)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return userdb.UserNotFoundError(name)
+		}
+		return bson.Unmarshal(data, result)
+	})
+}
+
+func (p *plugin) Search(filter interface{}, result interface{}) error {
+	query, err := normalize(filter)
+	if err != nil {
+		return err
+	}
+
+	resultv := reflect.ValueOf(result)
+	all := resultv.Kind() == reflect.Ptr && resultv.Elem().Kind() == reflect.Slice
+	if all {
+		resultv.Elem().SetLen(0)
+	}
+
+	found := false
+	err = p.store.View(func(tx Tx) error {
+		return tx.ForEach(UsersBucket, func(_ string, data []byte) error {
+			if found && !all {
+				return nil
+			}
+
+			var doc bson.M
+			if err := bson.Unmarshal(data, &doc); err != nil {
+				return err
+			}
+			if !matches(doc, query) {
+				return nil
+			}
+
+			found = true
+			if !all {
+				return bson.Unmarshal(data, result)
+			}
+			slicev := resultv.Elem()
+			elemp := reflect.New(slicev.Type().Elem())
+			if err := bson.Unmarshal(data, elemp.Interface()); err != nil {
+				return err
+			}
+			slicev.Set(reflect.Append(slicev, elemp.Elem()))
+			return nil
+		})
+	})
+	if err == nil && !found && !all {
+		err = userdb.UserNotFoundError(fmt.Sprintf("%v", filter))
+	}
+	return err
+}
+
+func (p *plugin) Remove(name string) error {
+	return p.store.Update(func(tx Tx) error {
+		doc, err := getDoc(tx, name)
+		if err != nil {
+			return err
+		}
+		if ns, _ := doc["namespace"].(string); ns != "" {
+			if err = tx.Delete(NamespacesBucket, ns); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(UsersBucket, name)
+	})
+}
+
+func (p *plugin) Update(name string, fields interface{}) error {
+	return p.update(name, nil, fields)
+}
+
+func (p *plugin) UpdateIf(name string, revision int64, fields interface{}) error {
+	return p.update(name, &revision, fields)
+}
+
+// update sets fields of the user document. If the revision is not nil,
+// the document is updated only if it has the same revision.
+func (p *plugin) update(name string, revision *int64, fields interface{}) error {
+	set, err := normalize(fields)
+	if err != nil {
+		return err
+	}
+
+	return p.store.Update(func(tx Tx) error {
+		doc, err := getDoc(tx, name)
+		if err != nil {
+			return err
+		}
+		if revision != nil && revisionOf(doc) != *revision {
+			return userdb.ConflictError(name)
+		}
+		if ns, ok := set["namespace"]; ok {
+			newns, _ := ns.(string)
+			if err = reindex(tx, name, doc, newns); err != nil {
+				return err
+			}
+		}
+		for path, value := range set {
+			setPath(doc, strings.Split(path, "."), value)
+		}
+		return putDoc(tx, name, doc)
+	})
+}
+
+func (p *plugin) GetSecret(key string, gen func() []byte) (secret []byte, err error) {
+	err = p.store.Update(func(tx Tx) error {
+		if secret, err = tx.Get(SecretsBucket, key); err != nil || secret != nil {
+			return err
+		}
+		secret = gen()
+		return tx.Put(SecretsBucket, key, secret)
+	})
+	return secret, err
+}
+
+func (p *plugin) ListSecrets() (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	err := p.store.View(func(tx Tx) error {
+		return tx.ForEach(SecretsBucket, func(key string, value []byte) error {
+			secrets[key] = append([]byte(nil), value...)
+			return nil
+		})
+	})
+	return secrets, err
+}
+
+func (p *plugin) Close() error {
+	return p.store.Close()
+}
+
+func getDoc(tx Tx, name string) (bson.M, error) {
+	data, err := tx.Get(UsersBucket, name)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, userdb.UserNotFoundError(name)
+	}
+
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// putDoc saves the modified user document with an incremented revision.
+func putDoc(tx Tx, name string, doc bson.M) error {
+	doc["revision"] = revisionOf(doc) + 1
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return tx.Put(UsersBucket, name, data)
+}
+
+// revisionOf returns the revision of the user document, which is zero for
+// documents saved before revisions were introduced.
+func revisionOf(doc bson.M) int64 {
+	switch rev := doc["revision"].(type) {
+	case int64:
+		return rev
+	case int:
+		return int64(rev)
+	case float64:
+		return int64(rev)
+	default:
+		return 0
+	}
+}
+
+// claimNamespace assigns the namespace to the user if it's not used by
+// other users.
+func claimNamespace(tx Tx, username, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	owner, err := tx.Get(NamespacesBucket, namespace)
+	if err != nil {
+		return err
+	}
+	if owner != nil && string(owner) != username {
+		return userdb.DuplicateNamespaceError(namespace)
+	}
+	return tx.Put(NamespacesBucket, namespace, []byte(username))
+}
+
+// reindex moves the namespace index of the user document to the new
+// namespace.
+func reindex(tx Tx, username string, doc bson.M, namespace string) error {
+	old, _ := doc["namespace"].(string)
+	if old == namespace {
+		return nil
+	}
+	if err := claimNamespace(tx, username, namespace); err != nil {
+		return err
+	}
+	if old != "" {
+		return tx.Delete(NamespacesBucket, old)
+	}
+	return nil
+}
+
+// normalize converts filter or update fields to a BSON document, so that
+// values are comparable to values in user documents.
+func normalize(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// matches returns true if the document matches all fields in the query.
+// Keys in the query are dotted paths into the document, and match any
+// element of arrays along the path.
+func matches(doc bson.M, query bson.M) bool {
+	for path, want := range query {
+		if !match(doc, strings.Split(path, "."), want) {
+			return false
+		}
+	}
+	return true
+}
+
+func match(v interface{}, path []string, want interface{}) bool {
+	if arr, ok := v.([]interface{}); ok {
+		if len(path) == 0 && reflect.DeepEqual(v, want) {
+			return true
+		}
+		for _, elem := range arr {
+			if match(elem, path, want) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return reflect.DeepEqual(v, want)
+	}
+	if doc, ok := v.(bson.M); ok {
+		return match(doc[path[0]], path[1:], want)
+	}
+	return want == nil
+}
+
+// setPath sets the value at the dotted path in the document, creating
+// intermediate documents as necessary.
+func setPath(doc bson.M, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		sub, ok := doc[key].(bson.M)
+		if !ok {
+			sub = bson.M{}
+			doc[key] = sub
+		}
+		doc = sub
+	}
+	doc[path[len(path)-1]] = value
+}
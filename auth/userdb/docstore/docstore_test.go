@@ -0,0 +1,220 @@
+package docstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/auth/userdb/bolt"
+	"github.com/cloudway/platform/auth/userdb/docstore"
+	"github.com/cloudway/platform/auth/userdb/memory"
+)
+
+func TestDocStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DocStore Suite")
+}
+
+var _ = Describe("Memory store", func() {
+	var db userdb.Plugin
+
+	BeforeEach(func() {
+		// use a fresh store for each test
+		db = docstore.New(memory.Open(CurrentGinkgoTestDescription().FullTestText))
+	})
+
+	storeBehaviors(func() userdb.Plugin { return db })
+})
+
+var _ = Describe("Bolt store", func() {
+	var db userdb.Plugin
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "userdb")
+		Expect(err).NotTo(HaveOccurred())
+		store, err := bolt.Open(filepath.Join(dir, "users.db"))
+		Expect(err).NotTo(HaveOccurred())
+		db = docstore.New(store)
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	storeBehaviors(func() userdb.Plugin { return db })
+})
+
+func storeBehaviors(plugin func() userdb.Plugin) {
+	var db userdb.Plugin
+
+	BeforeEach(func() {
+		db = plugin()
+		Expect(db.Create(&userdb.BasicUser{Name: "test", Namespace: "ns"})).To(Succeed())
+	})
+
+	It("should reject duplicate users and namespaces", func() {
+		Expect(db.Create(&userdb.BasicUser{Name: "test"})).To(Equal(userdb.DuplicateUserError("test")))
+		Expect(db.Create(&userdb.BasicUser{Name: "other", Namespace: "ns"})).To(Equal(userdb.DuplicateNamespaceError("ns")))
+
+		// the failed transaction must not leave partial writes
+		var user userdb.BasicUser
+		Expect(db.Find("other", &user)).To(Equal(userdb.UserNotFoundError("other")))
+	})
+
+	It("should move namespace", func() {
+		Expect(db.SetNamespace("test", "ns2")).To(Succeed())
+		Expect(db.Create(&userdb.BasicUser{Name: "other", Namespace: "ns"})).To(Succeed())
+		Expect(db.SetNamespace("other", "ns2")).To(Equal(userdb.DuplicateNamespaceError("ns2")))
+
+		var user userdb.BasicUser
+		Expect(db.Search(userdb.Args{"namespace": "ns2"}, &user)).To(Succeed())
+		Expect(user.Name).To(Equal("test"))
+	})
+
+	It("should update and search nested fields", func() {
+		tokens := []*userdb.AccessToken{{ID: "1", Hash: "h1"}, {ID: "2", Hash: "h2"}}
+		Expect(db.Update("test", userdb.Args{"tokens": tokens})).To(Succeed())
+		Expect(db.Update("test", userdb.Args{"twofactor.laststep": int64(42)})).To(Succeed())
+
+		var user userdb.BasicUser
+		Expect(db.Search(userdb.Args{"tokens.hash": "h2"}, &user)).To(Succeed())
+		Expect(user.Name).To(Equal("test"))
+		Expect(user.Tokens).To(HaveLen(2))
+		Expect(user.TwoFactor.LastStep).To(Equal(int64(42)))
+
+		Expect(db.Search(userdb.Args{"tokens.hash": "h3"}, &user)).To(BeAssignableToTypeOf(userdb.UserNotFoundError("")))
+		Expect(db.Update("nobody", userdb.Args{"inactive": true})).To(Equal(userdb.UserNotFoundError("nobody")))
+	})
+
+	It("should clear fields with nil", func() {
+		Expect(db.Update("test", userdb.Args{"twofactor": &userdb.TwoFactor{Secret: "s"}})).To(Succeed())
+		Expect(db.Update("test", userdb.Args{"twofactor": nil})).To(Succeed())
+
+		var user userdb.BasicUser
+		Expect(db.Find("test", &user)).To(Succeed())
+		Expect(user.TwoFactor).To(BeNil())
+	})
+
+	It("should update only if not modified", func() {
+		var user userdb.BasicUser
+		Expect(db.Find("test", &user)).To(Succeed())
+		Expect(db.UpdateIf("test", user.Revision, userdb.Args{"admin": true})).To(Succeed())
+		Expect(db.UpdateIf("test", user.Revision, userdb.Args{"inactive": true})).To(Equal(userdb.ConflictError("test")))
+		Expect(db.UpdateIf("nobody", 0, userdb.Args{"inactive": true})).To(Equal(userdb.UserNotFoundError("nobody")))
+
+		Expect(db.Find("test", &user)).To(Succeed())
+		Expect(user.Admin).To(BeTrue())
+		Expect(user.Inactive).To(BeFalse())
+
+		Expect(db.Update("test", userdb.Args{"inactive": true})).To(Succeed())
+		Expect(db.UpdateIf("test", user.Revision, userdb.Args{"admin": false})).To(Equal(userdb.ConflictError("test")))
+		Expect(db.UpdateIf("test", user.Revision+1, userdb.Args{"admin": false})).To(Succeed())
+	})
+
+	It("should search all users", func() {
+		Expect(db.Create(&userdb.BasicUser{Name: "other"})).To(Succeed())
+
+		var users []userdb.BasicUser
+		Expect(db.Search(userdb.Args{}, &users)).To(Succeed())
+		Expect(users).To(HaveLen(2))
+		Expect(db.Search(userdb.Args{"inactive": true}, &users)).To(Succeed())
+		Expect(users).To(BeEmpty())
+	})
+
+	It("should release namespace on removal", func() {
+		Expect(db.Remove("test")).To(Succeed())
+		Expect(db.Remove("test")).To(Equal(userdb.UserNotFoundError("test")))
+		Expect(db.Create(&userdb.BasicUser{Name: "other", Namespace: "ns"})).To(Succeed())
+	})
+
+	It("should generate secret once", func() {
+		secret, err := db.GetSecret("jwt", func() []byte { return []byte("first") })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret).To(Equal([]byte("first")))
+
+		secret, err = db.GetSecret("jwt", func() []byte { return []byte("second") })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret).To(Equal([]byte("first")))
+	})
+	It("should list secrets", func() {
+		_, err := db.GetSecret("jwt", func() []byte { return []byte("jwt") })
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.GetSecret("acme", func() []byte { return []byte("acme") })
+		Expect(err).NotTo(HaveOccurred())
+
+		secrets, err := db.ListSecrets()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secrets).To(Equal(map[string][]byte{"jwt": []byte("jwt"), "acme": []byte("acme")}))
+	})
+}
+
+var _ = Describe("Migration", func() {
+	type customUser struct {
+		userdb.BasicUser `bson:",inline"`
+		Email            string
+	}
+
+	var src, dst userdb.Plugin
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "userdb")
+		Expect(err).NotTo(HaveOccurred())
+		store, err := bolt.Open(filepath.Join(dir, "users.db"))
+		Expect(err).NotTo(HaveOccurred())
+		dst = docstore.New(store)
+		src = docstore.New(memory.Open(CurrentGinkgoTestDescription().FullTestText))
+
+		user := &customUser{Email: "test@example.com"}
+		user.Name, user.Namespace = "test", "ns"
+		Expect(src.Create(user)).To(Succeed())
+		Expect(src.Create(&userdb.BasicUser{Name: "other", Namespace: "ns2"})).To(Succeed())
+		_, err = src.GetSecret("jwt", func() []byte { return []byte("secret") })
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		dst.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should copy users with custom fields and secrets", func() {
+		result, err := userdb.Migrate(src, dst, userdb.ImportOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Imported).To(Equal([]string{"other", "test"}))
+
+		var user customUser
+		Expect(dst.Find("test", &user)).To(Succeed())
+		Expect(user.Namespace).To(Equal("ns"))
+		Expect(user.Email).To(Equal("test@example.com"))
+
+		secrets, err := dst.ListSecrets()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secrets).To(Equal(map[string][]byte{"jwt": []byte("secret")}))
+	})
+
+	It("should skip or replace existing users", func() {
+		Expect(dst.Create(&userdb.BasicUser{Name: "test"})).To(Succeed())
+
+		result, err := userdb.Migrate(src, dst, userdb.ImportOptions{Mode: userdb.ImportMerge})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal([]string{"test"}))
+
+		result, err = userdb.Migrate(src, dst, userdb.ImportOptions{Mode: userdb.ImportReplace})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Imported).To(Equal([]string{"other", "test"}))
+
+		var user customUser
+		Expect(dst.Find("test", &user)).To(Succeed())
+		Expect(user.Email).To(Equal("test@example.com"))
+	})
+})
@@ -0,0 +1,287 @@
+package userdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The version of user database export format. Users are exported as BSON
+// documents since version 2, so custom fields of users are preserved.
+const ExportVersion = 2
+
+// The export file format of the user database.
+type exportFile struct {
+	Version  int
+	Checksum string
+	Users    json.RawMessage
+}
+
+// The rawUser type keeps fields of concrete user types unknown to the
+// BasicUser, so users are exported and migrated without losing any field.
+type rawUser struct {
+	BasicUser `bson:",inline"`
+	Extra     bson.M `bson:",inline"`
+}
+
+// ImportMode specifies how to handle users already exist in the database.
+type ImportMode int
+
+const (
+	// Keep existing users and skip the conflicting imported users.
+	ImportMerge ImportMode = iota
+
+	// Replace existing users with the imported users.
+	ImportReplace
+)
+
+// ImportOptions controls the behavior of user database import.
+type ImportOptions struct {
+	Mode ImportMode
+}
+
+// ImportResult reports the outcome of user database import.
+type ImportResult struct {
+	// The users imported into the database.
+	Imported []string
+
+	// The users skipped due to conflict with existing users.
+	Skipped []string
+}
+
+// The InvalidExportError indicates that the imported content is not a valid
+// user database export.
+type InvalidExportError string
+
+func (e InvalidExportError) Error() string {
+	return fmt.Sprintf("Invalid user database export: %s", string(e))
+}
+
+func (e InvalidExportError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Export writes all users in the database to w. The hashed passwords are
+// exported as is, plaintext passwords are never known to the database.
+func (db *UserDatabase) Export(w io.Writer) error {
+	users, err := readUsers(db.plugin)
+	if err != nil {
+		return err
+	}
+
+	docs := make([][]byte, len(users))
+	for i := range users {
+		if docs[i], err = bson.Marshal(&users[i]); err != nil {
+			return err
+		}
+	}
+
+	content, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(&exportFile{
+		Version:  ExportVersion,
+		Checksum: checksum(content),
+		Users:    content,
+	})
+}
+
+// Import reads users exported by Export and adds them to the database.
+// The content is verified before any user is imported.
+func (db *UserDatabase) Import(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	users, err := readExport(r)
+	if err != nil {
+		return nil, err
+	}
+	return importUsers(db.plugin, users, opts)
+}
+
+// readUsers returns all users in the database sorted by name, with fields
+// specific to the database removed.
+func readUsers(p Plugin) ([]rawUser, error) {
+	var users []rawUser
+	if err := p.Search(Args{}, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Revision = 0
+		delete(users[i].Extra, "_id")
+	}
+	sort.Sort(byUserName(users))
+	return users, nil
+}
+
+// importUsers adds users to the database. Existing users are skipped or
+// replaced as specified by the import options.
+func importUsers(p Plugin, users []rawUser, opts ImportOptions) (*ImportResult, error) {
+	result := &ImportResult{}
+	for i := range users {
+		user := &users[i]
+
+		var existing rawUser
+		err := p.Find(user.Name, &existing)
+		if err != nil && !IsUserNotFound(err) {
+			return result, err
+		}
+
+		if err == nil {
+			if opts.Mode != ImportReplace {
+				result.Skipped = append(result.Skipped, user.Name)
+				continue
+			}
+			err = replaceUser(p, &existing, user)
+		} else {
+			err = p.Create(user)
+		}
+
+		if _, dup := err.(DuplicateNamespaceError); dup {
+			result.Skipped = append(result.Skipped, user.Name)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Imported = append(result.Imported, user.Name)
+	}
+	return result, nil
+}
+
+// replaceUser overwrites the existing user with the imported user in a
+// single update, so the existing user is kept if the update failed. Fields
+// absent from the imported user are cleared.
+func replaceUser(p Plugin, existing, user *rawUser) error {
+	if user.Namespace != "" && user.Namespace != existing.Namespace {
+		var owner BasicUser
+		err := p.Search(Args{"namespace": user.Namespace}, &owner)
+		if err == nil {
+			return DuplicateNamespaceError(user.Namespace)
+		}
+		if !IsUserNotFound(err) {
+			return err
+		}
+	}
+
+	fields, err := toDocument(user)
+	if err != nil {
+		return err
+	}
+	old, err := toDocument(existing)
+	if err != nil {
+		return err
+	}
+	for key := range old {
+		if _, ok := fields[key]; !ok {
+			fields[key] = nil
+		}
+	}
+	for _, key := range []string{"_id", "name", "revision"} {
+		delete(fields, key)
+	}
+	return p.Update(user.Name, fields)
+}
+
+func toDocument(user *rawUser) (bson.M, error) {
+	data, err := bson.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func readExport(r io.Reader) ([]rawUser, error) {
+	var file exportFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, InvalidExportError(err.Error())
+	}
+	if file.Version != 1 && file.Version != ExportVersion {
+		return nil, InvalidExportError(fmt.Sprintf("unsupported version %d", file.Version))
+	}
+	if file.Checksum != checksum(file.Users) {
+		return nil, InvalidExportError("checksum mismatch")
+	}
+
+	users, err := decodeUsers(file.Version, file.Users)
+	if err != nil {
+		return nil, InvalidExportError(err.Error())
+	}
+
+	names := make(map[string]bool)
+	namespaces := make(map[string]bool)
+	for _, user := range users {
+		if user.Name == "" {
+			return nil, InvalidExportError("missing user name")
+		}
+		if names[user.Name] {
+			return nil, InvalidExportError("duplicate user " + user.Name)
+		}
+		names[user.Name] = true
+
+		if user.Namespace != "" {
+			if namespaces[user.Namespace] {
+				return nil, InvalidExportError("duplicate namespace " + user.Namespace)
+			}
+			namespaces[user.Namespace] = true
+		}
+
+		// users authenticated by external identity services may not
+		// have a local password
+		if len(user.Password) == 0 {
+			continue
+		}
+		if _, err := bcrypt.Cost(user.Password); err != nil {
+			return nil, InvalidExportError("invalid password hash for user " + user.Name)
+		}
+	}
+	return users, nil
+}
+
+// decodeUsers decodes users in the export content. Users are exported as
+// BSON documents since version 2, and as JSON objects of basic users in
+// version 1.
+func decodeUsers(version int, content []byte) ([]rawUser, error) {
+	if version == 1 {
+		var basics []BasicUser
+		if err := json.Unmarshal(content, &basics); err != nil {
+			return nil, err
+		}
+		users := make([]rawUser, len(basics))
+		for i := range basics {
+			users[i].BasicUser = basics[i]
+		}
+		return users, nil
+	}
+
+	var docs [][]byte
+	if err := json.Unmarshal(content, &docs); err != nil {
+		return nil, err
+	}
+	users := make([]rawUser, len(docs))
+	for i, doc := range docs {
+		if err := bson.Unmarshal(doc, &users[i]); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+type byUserName []rawUser
+
+func (a byUserName) Len() int           { return len(a) }
+func (a byUserName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byUserName) Less(i, j int) bool { return a[i].Name < a[j].Name }
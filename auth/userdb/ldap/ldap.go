@@ -0,0 +1,137 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/ldap.v2"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+)
+
+// Authentication provider that verifies user credentials by binding to
+// an LDAP directory. The user entry is first searched with the configured
+// filter, using the service account if configured, and then the found
+// entry is bound with the user's password.
+type provider struct {
+	url          *url.URL
+	startTLS     bool
+	bindDN       string
+	bindPassword string
+	baseDN       string
+	filter       string
+}
+
+func init() {
+	prev := userdb.NewProvider
+	userdb.NewProvider = func(plugin userdb.Plugin) (userdb.Provider, error) {
+		if config.Get("auth.provider") != "ldap" {
+			return prev(plugin)
+		}
+
+		rawurl := config.Get("auth.ldap.url")
+		if rawurl == "" {
+			return nil, errors.New("LDAP URL not configured")
+		}
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+			return nil, fmt.Errorf("Unsupported LDAP URL scheme: %s", u.Scheme)
+		}
+
+		baseDN := config.Get("auth.ldap.base_dn")
+		if baseDN == "" {
+			return nil, errors.New("LDAP base DN not configured")
+		}
+
+		startTLS, _ := strconv.ParseBool(config.Get("auth.ldap.starttls"))
+
+		return &provider{
+			url:          u,
+			startTLS:     startTLS,
+			bindDN:       config.Get("auth.ldap.bind_dn"),
+			bindPassword: config.Get("auth.ldap.bind_password"),
+			baseDN:       baseDN,
+			filter:       config.GetOrDefault("auth.ldap.filter", "(uid=%s)"),
+		}, nil
+	}
+}
+
+func (p *provider) dial() (*ldap.Conn, error) {
+	host, port, err := net.SplitHostPort(p.url.Host)
+	if err != nil {
+		host, port = p.url.Host, ""
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	if p.url.Scheme == "ldaps" {
+		if port == "" {
+			port = "636"
+		}
+		return ldap.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
+	}
+
+	if port == "" {
+		port = "389"
+	}
+	conn, err := ldap.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if p.startTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (p *provider) Authenticate(name, password string) error {
+	// an empty password results in an unauthenticated bind that
+	// always succeeds, so it must be rejected explicitly
+	if password == "" {
+		return userdb.AuthenticationError(name)
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if p.bindDN != "" {
+		if err = conn.Bind(p.bindDN, p.bindPassword); err != nil {
+			return err
+		}
+	}
+
+	req := ldap.NewSearchRequest(
+		p.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(p.filter, ldap.EscapeFilter(name)),
+		[]string{"dn"}, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return err
+	}
+	if len(res.Entries) != 1 {
+		return userdb.AuthenticationError(name)
+	}
+
+	err = conn.Bind(res.Entries[0].DN, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		err = userdb.AuthenticationError(name)
+	}
+	return err
+}
+
+func (p *provider) External() bool {
+	return true
+}
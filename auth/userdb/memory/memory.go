@@ -0,0 +1,158 @@
+// Package memory provides an in-memory user database for testing and
+// evaluation. The user database is selected by "memory://NAME" URL, and
+// databases of the same name are shared in the process. Users are lost
+// when the process exits.
+package memory
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/auth/userdb/docstore"
+)
+
+func init() {
+	prev := userdb.NewPlugin
+	userdb.NewPlugin = func(dbtype, dburl string) (userdb.Plugin, error) {
+		if dbtype != "memory" {
+			return prev(dbtype, dburl)
+		}
+
+		var name string
+		if u, err := url.Parse(dburl); err == nil {
+			name = u.Host + u.Path
+		}
+		return docstore.New(Open(name)), nil
+	}
+}
+
+var stores = struct {
+	sync.Mutex
+	m map[string]*Store
+}{m: make(map[string]*Store)}
+
+// Open returns the in-memory store of the given name, the store is created
+// if it does not exist.
+func Open(name string) *Store {
+	stores.Lock()
+	defer stores.Unlock()
+
+	s := stores.m[name]
+	if s == nil {
+		s = &Store{data: make(map[string]map[string][]byte)}
+		stores.m[name] = s
+	}
+	return s
+}
+
+// Store is an in-memory key-value store.
+type Store struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+}
+
+func (s *Store) View(fn func(tx docstore.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(&tx{store: s})
+}
+
+func (s *Store) Update(fn func(tx docstore.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &tx{store: s, writes: make(map[string]map[string][]byte)}
+	if err := fn(t); err != nil {
+		return err
+	}
+	t.commit()
+	return nil
+}
+
+// Close does nothing, the data is retained for other users of the store.
+func (s *Store) Close() error {
+	return nil
+}
+
+// tx records writes that are applied to the store on commit. A nil value
+// in writes indicates the key is deleted.
+type tx struct {
+	store  *Store
+	writes map[string]map[string][]byte
+}
+
+func (t *tx) Get(bucket, key string) ([]byte, error) {
+	if w, ok := t.writes[bucket][key]; ok {
+		return clone(w), nil
+	}
+	return clone(t.store.data[bucket][key]), nil
+}
+
+func (t *tx) Put(bucket, key string, value []byte) error {
+	t.write(bucket, key, clone(value))
+	return nil
+}
+
+func (t *tx) Delete(bucket, key string) error {
+	t.write(bucket, key, nil)
+	return nil
+}
+
+func (t *tx) write(bucket, key string, value []byte) {
+	if t.writes == nil {
+		panic("write in read-only transaction")
+	}
+	if t.writes[bucket] == nil {
+		t.writes[bucket] = make(map[string][]byte)
+	}
+	t.writes[bucket][key] = value
+}
+
+func (t *tx) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	var keys []string
+	for k := range t.store.data[bucket] {
+		if _, ok := t.writes[bucket][k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for k, v := range t.writes[bucket] {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, _ := t.Get(bucket, k)
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) commit() {
+	for bucket, writes := range t.writes {
+		data := t.store.data[bucket]
+		if data == nil {
+			data = make(map[string][]byte)
+			t.store.data[bucket] = data
+		}
+		for k, v := range writes {
+			if v == nil {
+				delete(data, k)
+			} else {
+				data[k] = v
+			}
+		}
+	}
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,30 @@
+package userdb
+
+// Migrate copies all users and secret keys from the source database to the
+// destination database, which may be backed by a different database type.
+// Users already exist in the destination database are handled as specified
+// by the import options. Secret keys already exist in the destination
+// database are kept.
+func Migrate(src, dst Plugin, opts ImportOptions) (*ImportResult, error) {
+	users, err := readUsers(src)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := importUsers(dst, users, opts)
+	if err != nil {
+		return result, err
+	}
+
+	secrets, err := src.ListSecrets()
+	if err != nil {
+		return result, err
+	}
+	for key, value := range secrets {
+		value := value
+		if _, err = dst.GetSecret(key, func() []byte { return value }); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -4,13 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/cloudway/platform/auth/userdb"
-	"github.com/cloudway/platform/config"
 )
 
 // User database backed by MongoDB database.
@@ -20,15 +18,9 @@ type mongodb struct {
 
 func init() {
 	prev := userdb.NewPlugin
-	userdb.NewPlugin = func() (userdb.Plugin, error) {
-		dbtype := config.Get("userdb.type")
-		dburl := config.Get("userdb.url")
-
-		if dbtype != "" && dbtype != "mongodb" {
-			return prev()
-		}
-		if dbtype == "" && !strings.HasPrefix(dburl, "mongodb://") {
-			return prev()
+	userdb.NewPlugin = func(dbtype, dburl string) (userdb.Plugin, error) {
+		if dbtype != "mongodb" {
+			return prev(dbtype, dburl)
 		}
 		if dburl == "" {
 			return nil, errors.New("MongoDB URL not configured")
@@ -113,7 +105,7 @@ func (db *mongodb) SetNamespace(username, namespace string) error {
 
 	return users.Update(
 		bson.M{"name": username},
-		bson.M{"$set": bson.M{"namespace": namespace}})
+		bson.M{"$set": bson.M{"namespace": namespace}, "$inc": bson.M{"revision": 1}})
 }
 
 func (db *mongodb) Find(name string, result userdb.User) error {
@@ -158,13 +150,37 @@ func (db *mongodb) Update(name string, fields interface{}) error {
 	users := db.acquire()
 	defer db.release(users)
 
-	err := users.Update(bson.M{"name": name}, bson.M{"$set": fields})
+	err := users.Update(bson.M{"name": name}, bson.M{"$set": fields, "$inc": bson.M{"revision": 1}})
 	if err == mgo.ErrNotFound {
 		err = userdb.UserNotFoundError(name)
 	}
 	return err
 }
 
+func (db *mongodb) UpdateIf(name string, revision int64, fields interface{}) error {
+	users := db.acquire()
+	defer db.release(users)
+
+	query := bson.M{"name": name, "revision": revision}
+	if revision == 0 {
+		// users saved before revisions were introduced have no revision
+		query["revision"] = bson.M{"$in": []interface{}{0, nil}}
+	}
+
+	err := users.Update(query, bson.M{"$set": fields, "$inc": bson.M{"revision": 1}})
+	if err == mgo.ErrNotFound {
+		var n int
+		if n, err = users.Find(bson.M{"name": name}).Count(); err == nil {
+			if n == 0 {
+				err = userdb.UserNotFoundError(name)
+			} else {
+				err = userdb.ConflictError(name)
+			}
+		}
+	}
+	return err
+}
+
 func (db *mongodb) GetSecret(key string, gen func() []byte) ([]byte, error) {
 	session := db.session.Copy()
 	c := session.DB("").C("secret")
@@ -182,6 +198,26 @@ func (db *mongodb) GetSecret(key string, gen func() []byte) ([]byte, error) {
 	return record.Secret, err
 }
 
+func (db *mongodb) ListSecrets() (map[string][]byte, error) {
+	session := db.session.Copy()
+	c := session.DB("").C("secret")
+	defer session.Close()
+
+	var records []struct {
+		Key    string `bson:"_id"`
+		Secret []byte
+	}
+	if err := c.Find(nil).All(&records); err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string][]byte, len(records))
+	for _, r := range records {
+		secrets[r.Key] = r.Secret
+	}
+	return secrets, nil
+}
+
 func (db *mongodb) Close() error {
 	db.session.Close()
 	return nil
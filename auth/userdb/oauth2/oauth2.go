@@ -0,0 +1,98 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+)
+
+// Authentication provider that verifies user credentials against an OAuth2
+// authorization server using the resource owner password credentials grant.
+// If an OpenID Connect userinfo endpoint is configured, the identity claim
+// returned by the endpoint must match the user name.
+type provider struct {
+	config      *oauth2.Config
+	userinfoURL string
+	claim       string
+}
+
+func init() {
+	prev := userdb.NewProvider
+	userdb.NewProvider = func(plugin userdb.Plugin) (userdb.Provider, error) {
+		typ := config.Get("auth.provider")
+		if typ != "oauth2" && typ != "oidc" {
+			return prev(plugin)
+		}
+
+		tokenURL := config.Get("auth.oauth2.token_url")
+		if tokenURL == "" {
+			return nil, errors.New("OAuth2 token URL not configured")
+		}
+
+		userinfoURL := config.Get("auth.oauth2.userinfo_url")
+		if typ == "oidc" && userinfoURL == "" {
+			return nil, errors.New("OpenID Connect userinfo URL not configured")
+		}
+
+		scopes := strings.FieldsFunc(config.GetOrDefault("auth.oauth2.scopes", "openid email"), func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+
+		return &provider{
+			config: &oauth2.Config{
+				ClientID:     config.Get("auth.oauth2.client_id"),
+				ClientSecret: config.Get("auth.oauth2.client_secret"),
+				Endpoint:     oauth2.Endpoint{TokenURL: tokenURL},
+				Scopes:       scopes,
+			},
+			userinfoURL: userinfoURL,
+			claim:       config.GetOrDefault("auth.oauth2.username_claim", "email"),
+		}, nil
+	}
+}
+
+func (p *provider) Authenticate(name, password string) error {
+	ctx := context.Background()
+
+	token, err := p.config.PasswordCredentialsToken(ctx, name, password)
+	if err != nil {
+		logrus.Debugf("OAuth2 authentication failed for %s: %v", name, err)
+		return userdb.AuthenticationError(name)
+	}
+
+	if p.userinfoURL == "" {
+		return nil
+	}
+
+	resp, err := p.config.Client(ctx, token).Get(p.userinfoURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to get user information: %s", resp.Status)
+	}
+
+	var info map[string]interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return err
+	}
+	if claim, _ := info[p.claim].(string); claim != name {
+		return userdb.AuthenticationError(name)
+	}
+	return nil
+}
+
+func (p *provider) External() bool {
+	return true
+}
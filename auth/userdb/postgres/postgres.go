@@ -0,0 +1,146 @@
+// Package postgres provides a user database backed by PostgreSQL. The user
+// database is selected by "postgres://" URL. Users are saved as documents
+// in a single table, which is created if it does not exist.
+package postgres
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/auth/userdb/docstore"
+)
+
+const createTable = `
+CREATE TABLE IF NOT EXISTS cloudway_userdb (
+	bucket TEXT NOT NULL,
+	key    TEXT NOT NULL,
+	value  BYTEA NOT NULL,
+	PRIMARY KEY (bucket, key)
+)`
+
+// The advisory lock serializes read-write transactions, so that uniqueness
+// of user names and namespaces is checked consistently.
+const writeLock = 0x636c6f7564776179 // "cloudway"
+
+func init() {
+	prev := userdb.NewPlugin
+	userdb.NewPlugin = func(dbtype, dburl string) (userdb.Plugin, error) {
+		if dbtype != "postgres" {
+			return prev(dbtype, dburl)
+		}
+
+		store, err := Open(dburl)
+		if err != nil {
+			return nil, err
+		}
+		return docstore.New(store), nil
+	}
+}
+
+// Store is a key-value store backed by a PostgreSQL table.
+type Store struct {
+	db *sql.DB
+}
+
+// Open connects to the PostgreSQL database and creates the table if
+// necessary.
+func Open(dburl string) (*Store, error) {
+	db, err := sql.Open("postgres", dburl)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+func (s *Store) View(fn func(tx docstore.Tx) error) error {
+	return s.run(false, fn)
+}
+
+func (s *Store) Update(fn func(tx docstore.Tx) error) error {
+	return s.run(true, fn)
+}
+
+func (s *Store) run(writable bool, fn func(tx docstore.Tx) error) error {
+	t, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if writable {
+		_, err = t.Exec("SELECT pg_advisory_xact_lock($1)", writeLock)
+	}
+	if err == nil {
+		err = fn(tx{t})
+	}
+	if err != nil {
+		t.Rollback()
+		return err
+	}
+	return t.Commit()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+type tx struct {
+	*sql.Tx
+}
+
+func (t tx) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := t.QueryRow("SELECT value FROM cloudway_userdb WHERE bucket = $1 AND key = $2", bucket, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (t tx) Put(bucket, key string, value []byte) error {
+	_, err := t.Exec(`INSERT INTO cloudway_userdb (bucket, key, value) VALUES ($1, $2, $3)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = EXCLUDED.value`, bucket, key, value)
+	return err
+}
+
+func (t tx) Delete(bucket, key string) error {
+	_, err := t.Exec("DELETE FROM cloudway_userdb WHERE bucket = $1 AND key = $2", bucket, key)
+	return err
+}
+
+func (t tx) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	rows, err := t.Query("SELECT key, value FROM cloudway_userdb WHERE bucket = $1 ORDER BY key", bucket)
+	if err != nil {
+		return err
+	}
+
+	// read all rows before calling the function, since other queries
+	// cannot be run on the transaction while rows are open
+	type row struct {
+		key   string
+		value []byte
+	}
+	var all []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.key, &r.value); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, r)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range all {
+		if err = fn(r.key, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudway/platform/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The Provider interface represents an authentication backend that verifies
+// user credentials. The user database always keeps the user's namespace and
+// applications, while the credentials can be verified by an external
+// identity service such as LDAP or an OAuth2 authorization server.
+type Provider interface {
+	// Authenticate verifies the user name and password.
+	Authenticate(name, password string) error
+
+	// External returns true if the credentials are managed by an external
+	// identity service. Users authenticated by an external provider are
+	// created in the user database on the first login.
+	External() bool
+}
+
+// NewProvider creates the authentication provider selected by the
+// "auth.provider" configuration. The user database plugin is used to
+// verify credentials if no provider is configured.
+var NewProvider = func(plugin Plugin) (Provider, error) {
+	switch typ := config.Get("auth.provider"); typ {
+	case "", "userdb":
+		return &localProvider{plugin}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported authentication provider: %s", typ)
+	}
+}
+
+// The AuthenticationError indicates that the user credentials are invalid.
+type AuthenticationError string
+
+func (e AuthenticationError) Error() string {
+	return fmt.Sprintf("Invalid user name or password: %s", string(e))
+}
+
+func (e AuthenticationError) HTTPErrorStatusCode() int {
+	return http.StatusUnauthorized
+}
+
+// ErrExternalPassword is returned when changing password of a user
+// authenticated by an external identity service.
+var ErrExternalPassword = errors.New("The password is managed by an external identity service")
+
+// The local provider verifies the password stored in the user database.
+type localProvider struct {
+	plugin Plugin
+}
+
+func (p *localProvider) Authenticate(name, password string) error {
+	var user BasicUser
+	if err := p.plugin.Find(name, &user); err != nil {
+		return err
+	}
+	if len(user.Password) == 0 {
+		return AuthenticationError(name)
+	}
+	err := bcrypt.CompareHashAndPassword(user.Password, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		err = AuthenticationError(name)
+	}
+	return err
+}
+
+func (p *localProvider) External() bool {
+	return false
+}
+
+// randomPassword generates an unguessable password for users created
+// on behalf of an external provider, so they cannot login locally.
+func randomPassword() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
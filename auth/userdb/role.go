@@ -0,0 +1,101 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Role determines the operations a user is permitted to perform.
+type Role string
+
+const (
+	// Administrators can perform any operation, including user management.
+	RoleAdmin Role = "admin"
+
+	// Developers can create, deploy and manage their own applications.
+	RoleDeveloper Role = "developer"
+
+	// Viewers can only inspect applications but not modify them.
+	RoleViewer Role = "viewer"
+)
+
+// Scope is a permission required to access an API route.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var roleScopes = map[Role][]Scope{
+	RoleAdmin:     {ScopeRead, ScopeWrite, ScopeAdmin},
+	RoleDeveloper: {ScopeRead, ScopeWrite},
+	RoleViewer:    {ScopeRead},
+}
+
+// The InvalidRoleError indicates that a role name is not recognized.
+type InvalidRoleError string
+
+func (e InvalidRoleError) Error() string {
+	return fmt.Sprintf("Invalid role: %s", string(e))
+}
+
+func (e InvalidRoleError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseRole returns the role from the given name.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := roleScopes[role]; !ok {
+		return "", InvalidRoleError(name)
+	}
+	return role, nil
+}
+
+// Scopes returns all permissions granted to the role.
+func (role Role) Scopes() []Scope {
+	return roleScopes[role]
+}
+
+// HasScope returns true if the role is granted the given permission.
+func (role Role) HasScope(scope Scope) bool {
+	for _, s := range roleScopes[role] {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRole returns the effective role of the user. An administrator always
+// has the admin role, and users without assigned role are developers.
+func (user *BasicUser) GetRole() Role {
+	switch {
+	case user.Admin:
+		return RoleAdmin
+	case user.Role == "":
+		return RoleDeveloper
+	default:
+		return user.Role
+	}
+}
+
+// HasScope returns true if the user is granted the given permission.
+// The permissions of user authenticated by an access token are further
+// restricted to the scopes granted to the token.
+func (user *BasicUser) HasScope(scope Scope) bool {
+	if !user.GetRole().HasScope(scope) {
+		return false
+	}
+	if user.Scopes == nil {
+		return true
+	}
+	for _, s := range user.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
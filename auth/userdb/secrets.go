@@ -0,0 +1,54 @@
+package userdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// Mounts of application secrets.
+const (
+	// The secret is set as an environment variable of the application,
+	// loaded from the tmpfs mounted on /run/secrets.
+	SecretEnv = "env"
+
+	// The secret is written to a file in the tmpfs mounted on /run/secrets,
+	// which is never saved to the container file system.
+	SecretFile = "file"
+)
+
+// AppSecret is a secret of an application. The value is encrypted by a
+// server key and never returned by the API. The version is increased when
+// the secret is rotated.
+type AppSecret struct {
+	Name    string
+	Value   string
+	Mount   string
+	Version int
+	Created time.Time
+	Updated time.Time
+}
+
+// SecretAccess is an audit record of an operation on application secrets.
+// The container is recorded when secrets are injected on start.
+type SecretAccess struct {
+	Time      time.Time
+	User      string `bson:",omitempty"`
+	Container string `bson:",omitempty"`
+	Action    string
+	Secret    string
+}
+
+// EncryptAppSecret encrypts the value of an application secret with a server
+// key dedicated to application secrets.
+func (db *UserDatabase) EncryptAppSecret(value string) (string, error) {
+	return db.encrypt("secrets", value)
+}
+
+// DecryptAppSecret decrypts the value of an application secret.
+func (db *UserDatabase) DecryptAppSecret(encrypted string) (string, error) {
+	value, err := db.decrypt("secrets", encrypted)
+	if err != nil {
+		return "", fmt.Errorf("Invalid encrypted secret: %v", err)
+	}
+	return value, nil
+}
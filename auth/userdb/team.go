@@ -0,0 +1,72 @@
+package userdb
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TeamRole determines the operations a member is permitted to perform on
+// applications in a namespace shared by its owner.
+type TeamRole string
+
+const (
+	// Owners can perform any operation in the namespace, including
+	// creating and removing applications and managing members.
+	TeamOwner TeamRole = "owner"
+
+	// Deployers can deploy, start, stop and scale applications, but
+	// cannot create, remove or reconfigure them.
+	TeamDeployer TeamRole = "deployer"
+
+	// Viewers can only inspect applications in the namespace.
+	TeamViewer TeamRole = "viewer"
+)
+
+var teamRoleRanks = map[TeamRole]int{
+	TeamViewer:   1,
+	TeamDeployer: 2,
+	TeamOwner:    3,
+}
+
+// Member is a user granted access to the namespace of another user.
+type Member struct {
+	Name string
+	Role TeamRole
+}
+
+// The InvalidTeamRoleError indicates that a team role name is not recognized.
+type InvalidTeamRoleError string
+
+func (e InvalidTeamRoleError) Error() string {
+	return fmt.Sprintf("Invalid team role: %s", string(e))
+}
+
+func (e InvalidTeamRoleError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseTeamRole returns the team role from the given name.
+func ParseTeamRole(name string) (TeamRole, error) {
+	role := TeamRole(name)
+	if _, ok := teamRoleRanks[role]; !ok {
+		return "", InvalidTeamRoleError(name)
+	}
+	return role, nil
+}
+
+// Allows returns true if the role permits operations that require the
+// given role.
+func (role TeamRole) Allows(required TeamRole) bool {
+	return teamRoleRanks[role] >= teamRoleRanks[required]
+}
+
+// GetMember returns the member of the user's namespace, or nil if the
+// named user is not a member.
+func (user *BasicUser) GetMember(name string) *Member {
+	for _, m := range user.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenPrefix distinguishes personal access tokens from JWT tokens.
+const TokenPrefix = "cwt_"
+
+// AccessToken is a long-lived personal access token used by automation
+// tools such as CI systems. Only the hash of the token is saved in the
+// database, the token itself is shown once when it's created.
+type AccessToken struct {
+	ID      string
+	Name    string
+	Hash    string
+	Scopes  []Scope
+	Created time.Time
+	Expires time.Time `bson:",omitempty"`
+}
+
+// Expired returns true if the token has an expiration time in the past.
+func (t *AccessToken) Expired() bool {
+	return !t.Expires.IsZero() && time.Now().After(t.Expires)
+}
+
+// The TokenNotFoundError indicates that an access token not found.
+type TokenNotFoundError string
+
+func (e TokenNotFoundError) Error() string {
+	return fmt.Sprintf("Access token not found: %s", string(e))
+}
+
+func (e TokenNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e TokenNotFoundError) ErrorCode() string {
+	return "token_not_found"
+}
+
+// The InvalidTokenError indicates that an access token is not valid
+// to authenticate user.
+type InvalidTokenError struct{}
+
+func (e InvalidTokenError) Error() string {
+	return "Invalid or expired access token"
+}
+
+func (e InvalidTokenError) HTTPErrorStatusCode() int {
+	return http.StatusUnauthorized
+}
+
+func (e InvalidTokenError) ErrorCode() string {
+	return "invalid_token"
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateToken creates a new access token for the user. The token is granted
+// the given scopes, which must be granted to the user's role. A zero expiry
+// duration creates a token that never expires. Returns the token metadata
+// and the token string.
+func (db *UserDatabase) CreateToken(username, name string, scopes []Scope, expiry time.Duration) (*AccessToken, string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := TokenPrefix + secret
+	t := &AccessToken{
+		ID:      id,
+		Name:    name,
+		Hash:    hashToken(token),
+		Created: time.Now().UTC(),
+	}
+	if expiry > 0 {
+		t.Expires = t.Created.Add(expiry)
+	}
+
+	// the token is added to the fresh tokens, so tokens created or revoked
+	// concurrently are never lost or restored
+	var user BasicUser
+	err = db.Modify(username, &user, func() (interface{}, error) {
+		t.Scopes = scopes
+		if len(t.Scopes) == 0 {
+			t.Scopes = user.GetRole().Scopes()
+		}
+		for _, scope := range t.Scopes {
+			if !user.HasScope(scope) {
+				return nil, fmt.Errorf("The scope '%s' is not granted to user %s", scope, username)
+			}
+		}
+		return Args{"tokens": append(user.Tokens, t)}, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return t, token, nil
+}
+
+// ListTokens returns all access tokens of the user.
+func (db *UserDatabase) ListTokens(username string) ([]*AccessToken, error) {
+	var user BasicUser
+	if err := db.plugin.Find(username, &user); err != nil {
+		return nil, err
+	}
+	return user.Tokens, nil
+}
+
+// RevokeToken removes the access token with the given id.
+func (db *UserDatabase) RevokeToken(username, id string) error {
+	var user BasicUser
+	return db.Modify(username, &user, func() (interface{}, error) {
+		for i, t := range user.Tokens {
+			if t.ID == id {
+				tokens := append(user.Tokens[:i], user.Tokens[i+1:]...)
+				return Args{"tokens": tokens}, nil
+			}
+		}
+		return nil, TokenNotFoundError(id)
+	})
+}
+
+// VerifyToken returns the user who owns the access token. The returned
+// user is restricted to the scopes granted to the token.
+func (db *UserDatabase) VerifyToken(token string) (*BasicUser, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, InvalidTokenError{}
+	}
+
+	hash := hashToken(token)
+
+	var user BasicUser
+	if err := db.plugin.Search(Args{"tokens.hash": hash}, &user); err != nil {
+		if IsUserNotFound(err) {
+			err = InvalidTokenError{}
+		}
+		return nil, err
+	}
+
+	if user.Inactive {
+		return nil, InactiveUserError(user.Name)
+	}
+
+	for _, t := range user.Tokens {
+		if t.Hash == hash {
+			if t.Expired() {
+				return nil, InvalidTokenError{}
+			}
+			user.Scopes = t.Scopes
+			return &user, nil
+		}
+	}
+	return nil, InvalidTokenError{}
+}
@@ -0,0 +1,288 @@
+package userdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/pkg/totp"
+)
+
+// The number of recovery codes generated when two-factor authentication
+// is enabled.
+const recoveryCodeCount = 10
+
+// TwoFactor contains the TOTP settings of a user. The TOTP secret is
+// encrypted by a server key, and only the hashes of recovery codes are
+// saved in the database.
+type TwoFactor struct {
+	Secret        string
+	Enabled       bool
+	RecoveryCodes []string `bson:",omitempty"`
+
+	// The time step of the last accepted code, to reject reused codes.
+	LastStep int64 `bson:",omitempty"`
+}
+
+// The TwoFactorRequiredError indicates that a code is required to login
+// a user with two-factor authentication enabled.
+type TwoFactorRequiredError string
+
+func (e TwoFactorRequiredError) Error() string {
+	return "Two-factor authentication code required"
+}
+
+func (e TwoFactorRequiredError) HTTPErrorStatusCode() int {
+	return http.StatusUnauthorized
+}
+
+// ErrorCode returns the code checked by clients to prompt for the code.
+func (e TwoFactorRequiredError) ErrorCode() string {
+	return "two_factor_required"
+}
+
+// The InvalidTwoFactorCodeError indicates that a two-factor authentication
+// code or recovery code is incorrect.
+type InvalidTwoFactorCodeError struct{}
+
+func (e InvalidTwoFactorCodeError) Error() string {
+	return "Invalid two-factor authentication code"
+}
+
+func (e InvalidTwoFactorCodeError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e InvalidTwoFactorCodeError) ErrorCode() string {
+	return "invalid_two_factor_code"
+}
+
+// TwoFactorEnabled returns true if two-factor authentication is enabled
+// for the user.
+func (user *BasicUser) TwoFactorEnabled() bool {
+	return user.TwoFactor != nil && user.TwoFactor.Enabled
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user. Two-factor
+// authentication is not enabled until a code generated from the secret
+// is verified by EnableTwoFactor. Returns the secret and the otpauth URL
+// to be added to authenticator apps.
+func (db *UserDatabase) EnrollTwoFactor(name, issuer string) (string, string, error) {
+	var user BasicUser
+	if err := db.plugin.Find(name, &user); err != nil {
+		return "", "", err
+	}
+	if user.TwoFactorEnabled() {
+		return "", "", fmt.Errorf("Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := db.encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = db.plugin.Update(name, Args{"twofactor": &TwoFactor{Secret: encrypted}})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totp.URL(issuer, name, secret), nil
+}
+
+// EnableTwoFactor enables two-factor authentication after the code of the
+// enrolled secret is verified. Returns the recovery codes, which are shown
+// once to the user and can be used if the authenticator is lost.
+func (db *UserDatabase) EnableTwoFactor(name, code string) ([]string, error) {
+	var user BasicUser
+	if err := db.plugin.Find(name, &user); err != nil {
+		return nil, err
+	}
+
+	tf := user.TwoFactor
+	if tf == nil || tf.Secret == "" {
+		return nil, fmt.Errorf("Two-factor authentication is not enrolled")
+	}
+	if tf.Enabled {
+		return nil, fmt.Errorf("Two-factor authentication is already enabled")
+	}
+
+	secret, err := db.decryptSecret(tf.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, InvalidTwoFactorCodeError{}
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = randomHex(5); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(codes[i])
+	}
+
+	tf.Enabled = true
+	tf.RecoveryCodes = hashes
+	tf.LastStep = step
+	if err = db.plugin.Update(name, Args{"twofactor": tf}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor disables two-factor authentication of the user after
+// the code or a recovery code is verified.
+func (db *UserDatabase) DisableTwoFactor(name, code string) error {
+	var user BasicUser
+	if err := db.plugin.Find(name, &user); err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil
+	}
+	if err := db.VerifyTwoFactor(&user, code); err != nil {
+		return err
+	}
+	return db.plugin.Update(name, Args{"twofactor": nil})
+}
+
+// VerifyTwoFactor verifies the TOTP code or a recovery code of the user
+// if two-factor authentication is enabled. A TOTP code or recovery code
+// can only be used once, even by concurrent logins.
+func (db *UserDatabase) VerifyTwoFactor(user *BasicUser, code string) error {
+	for i := 1; ; i++ {
+		err := db.verifyTwoFactor(user, code)
+		if !IsConflict(err) || i == maxModifyAttempts {
+			return err
+		}
+
+		// the user was modified concurrently, verify the code again with
+		// the fresh user, which rejects the code if used meanwhile
+		name := user.Name
+		*user = BasicUser{}
+		if err = db.plugin.Find(name, user); err != nil {
+			return err
+		}
+	}
+}
+
+// verifyTwoFactor verifies the code and saves the used code, only if the
+// user was not modified since it was read.
+func (db *UserDatabase) verifyTwoFactor(user *BasicUser, code string) error {
+	if !user.TwoFactorEnabled() {
+		return nil
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return TwoFactorRequiredError(user.Name)
+	}
+
+	tf := user.TwoFactor
+	secret, err := db.decryptSecret(tf.Secret)
+	if err != nil {
+		return err
+	}
+
+	var fields Args
+	if step, ok := totp.Validate(secret, code, time.Now()); ok {
+		if step <= tf.LastStep {
+			return InvalidTwoFactorCodeError{}
+		}
+		tf.LastStep = step
+		fields = Args{"twofactor.laststep": step}
+	} else {
+		hash := hashToken(strings.ToLower(code))
+		for i, h := range tf.RecoveryCodes {
+			if h == hash {
+				tf.RecoveryCodes = append(tf.RecoveryCodes[:i], tf.RecoveryCodes[i+1:]...)
+				fields = Args{"twofactor.recoverycodes": tf.RecoveryCodes}
+				break
+			}
+		}
+		if fields == nil {
+			return InvalidTwoFactorCodeError{}
+		}
+	}
+
+	if err = db.plugin.UpdateIf(user.Name, user.Revision, fields); err != nil {
+		return err
+	}
+	user.Revision++
+	return nil
+}
+
+// encryptSecret encrypts the TOTP secret with AES-GCM using a server key.
+func (db *UserDatabase) encryptSecret(secret string) (string, error) {
+	return db.encrypt("twofactor", secret)
+}
+
+func (db *UserDatabase) decryptSecret(encrypted string) (string, error) {
+	secret, err := db.decrypt("twofactor", encrypted)
+	if err != nil {
+		return "", fmt.Errorf("Invalid two-factor authentication secret")
+	}
+	return secret, nil
+}
+
+// encrypt encrypts the plain text with AES-GCM using the named server key.
+// The random nonce is prepended to the sealed text.
+func (db *UserDatabase) encrypt(key, plain string) (string, error) {
+	gcm, err := db.secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (db *UserDatabase) decrypt(key, encrypted string) (string, error) {
+	gcm, err := db.secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	n := gcm.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("sealed text too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (db *UserDatabase) secretCipher(name string) (cipher.AEAD, error) {
+	key, err := db.plugin.GetSecret(name, func() []byte {
+		key := make([]byte, 32)
+		rand.Read(key)
+		return key
+	})
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,6 +1,11 @@
 package userdb
 
-import "time"
+import (
+	"time"
+
+	"github.com/cloudway/platform/history"
+	"github.com/cloudway/platform/webhook"
+)
 
 // The User interface encapsulates a cloud user. The concret User type must
 // embedded a BasicUser struct that contains core information that used by
@@ -17,7 +22,35 @@ type BasicUser struct {
 	Namespace    string
 	Password     []byte
 	Inactive     bool
+	Admin        bool `bson:",omitempty"`
+	Role         Role `bson:",omitempty"`
 	Applications map[string]*Application
+	Tokens       []*AccessToken `bson:",omitempty"`
+
+	// Quota overrides the default resource quota of the user.
+	Quota *Quota `bson:",omitempty"`
+
+	// Registration tracks the self-service registration of an inactive
+	// user, which is removed once the user is activated.
+	Registration *Registration `bson:",omitempty"`
+
+	// TwoFactor contains the TOTP settings of two-factor authentication.
+	TwoFactor *TwoFactor `bson:",omitempty"`
+
+	// Members are other users sharing the user's namespace.
+	Members []*Member `bson:",omitempty"`
+
+	// Env contains environment variables shared by all applications in
+	// the user's namespace, unless overridden by the application.
+	Env map[string]string `bson:",omitempty"`
+
+	// Revision is incremented by every update of the user, so concurrent
+	// modifications are detected when updating the user.
+	Revision int64 `bson:",omitempty" json:"-"`
+
+	// Scopes restricts the permissions of the user authenticated by an
+	// access token. It's never saved to the database.
+	Scopes []Scope `bson:"-" json:"-"`
 }
 
 type Application struct {
@@ -25,6 +58,162 @@ type Application struct {
 	Plugins   []string
 	Hosts     []string `bson:",omitempty"`
 	Secret    string
+	TimeZone  string          `bson:",omitempty"`
+	Locale    string          `bson:",omitempty"`
+	Hooks     []*webhook.Hook `bson:",omitempty"`
+
+	// PushSecret verifies push webhooks posted by Git hosting services.
+	PushSecret string `bson:",omitempty"`
+
+	// Repository binds the application to an external repository, which
+	// is deployed instead of the repository in the platform SCM.
+	Repository *RemoteRepository `bson:",omitempty"`
+
+	// Certificates contains TLS certificates of custom domains.
+	Certificates []*Certificate `bson:",omitempty"`
+
+	// Resources overrides resource limits of the application containers
+	// declared in plugin manifests.
+	Resources []*ResourceLimits `bson:",omitempty"`
+
+	// Logging overrides the log driver and options of the application
+	// containers configured by the administrator.
+	Logging *LogConfig `bson:",omitempty"`
+
+	// Secrets contains encrypted secrets injected into framework containers.
+	Secrets []*AppSecret `bson:",omitempty"`
+
+	// SecretAudit contains recent operations on secrets, the most recent
+	// last.
+	SecretAudit []*SecretAccess `bson:",omitempty"`
+
+	// NetworkGrants contains names of other applications in the namespace
+	// granted access to services of the application, if network isolation
+	// is enabled.
+	NetworkGrants []string `bson:",omitempty"`
+
+	// State is the lifecycle state requested by the user, empty if the
+	// application was never stopped.
+	State AppState `bson:",omitempty"`
+
+	// StateChanged is the time when the state was last changed.
+	StateChanged time.Time `bson:",omitempty"`
+
+	// Deployments contains previously deployed versions kept in the
+	// deployment history, the most recent first.
+	Deployments []*history.Version `bson:",omitempty"`
+
+	// CurrentDeployment is the deployed version in the deployment history,
+	// zero if unknown.
+	CurrentDeployment int `bson:",omitempty"`
+}
+
+// AppState is the lifecycle state of an application.
+type AppState string
+
+const (
+	// All containers of the application are started.
+	AppRunning AppState = "running"
+
+	// All containers of the application are stopped by the user.
+	AppStopped AppState = "stopped"
+
+	// All containers of the application are stopped to save resources,
+	// and the application is resumed when needed.
+	AppIdle AppState = "idle"
+)
+
+// CurrentState returns the state of the application, which is running
+// unless stopped or idled.
+func (app *Application) CurrentState() AppState {
+	if app.State == "" {
+		return AppRunning
+	}
+	return app.State
+}
+
+// ResourceLimits overrides resource limits of containers of a service,
+// or the framework containers if the service name is empty. A zero value
+// of a limit means not overridden.
+type ResourceLimits struct {
+	Service   string  `bson:",omitempty"`
+	Memory    int64   `bson:",omitempty"`
+	CPUs      float64 `bson:",omitempty"`
+	PidsLimit int64   `bson:",omitempty"`
+}
+
+// LogConfig contains the log driver and options of containers.
+type LogConfig struct {
+	Driver  string
+	Options map[string]string `bson:",omitempty"`
+}
+
+// Registration is the state of a self-service registration. The email
+// address is verified by a token sent to the user, and the user may wait
+// for approval of an administrator after verified.
+type Registration struct {
+	TokenHash string `bson:",omitempty"`
+	Expires   time.Time
+	Verified  bool
+
+	// Namespace is the namespace requested by the user, which is assigned
+	// when the user is activated.
+	Namespace string `bson:",omitempty"`
+}
+
+// Quota limits resources consumed by applications of a user. A zero value
+// of a limit means unlimited.
+type Quota struct {
+	// The maximum number of applications.
+	Applications int `bson:",omitempty"`
+
+	// The maximum number of containers of all applications.
+	Containers int `bson:",omitempty"`
+
+	// The total memory in bytes allocated to all containers.
+	Memory int64 `bson:",omitempty"`
+
+	// The total number of CPUs allocated to all containers.
+	CPUs float64 `bson:",omitempty"`
+}
+
+// Certificate is a TLS certificate of a custom domain, either issued by
+// an ACME server or uploaded by user.
+type Certificate struct {
+	Domain string
+
+	// The certificate chain and private key in PEM format.
+	Certificate string
+	PrivateKey  string
+
+	// The expiration time of the certificate.
+	NotAfter time.Time
+
+	// True if the certificate is issued by ACME and renewed automatically.
+	ACME bool `bson:",omitempty"`
+}
+
+// RemoteRepository is an external Git repository accessed over SSH with
+// a deploy key generated for the application.
+type RemoteRepository struct {
+	// The SCM driver, "github", "gitlab" or "git".
+	Driver string
+
+	// The driver specific repository name, e.g. "owner/repo" for GitHub.
+	Repo string
+
+	// The SSH URL to clone the repository.
+	URL string
+
+	// The API token used to manage the deploy key, optional.
+	Token string `bson:",omitempty"`
+
+	// The private deploy key in PEM format.
+	PrivateKey string
+
+	// The deploy key identifier in the Git hosting service, empty if the
+	// key is installed by user.
+	KeyID string `bson:",omitempty"`
 }
 
 func (user *BasicUser) Basic() *BasicUser {
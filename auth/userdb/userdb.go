@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 
 	"github.com/cloudway/platform/config"
@@ -29,30 +30,33 @@ type Plugin interface {
 	// Remove the user from the database.
 	Remove(name string) error
 
-	// Update user with the new data.
+	// Update user with the new data. Every update increments the revision
+	// of the user.
 	Update(name string, fields interface{}) error
 
+	// UpdateIf updates the user only if the revision of the user equals to
+	// the given revision, that is, the user was not modified since it was
+	// read. Otherwise a ConflictError is returned. The check and update
+	// are performed atomically, so concurrent API servers never overwrite
+	// modifications of each other.
+	UpdateIf(name string, revision int64, fields interface{}) error
+
 	// GetSecret returns a secret key used to sign the JWT token. If the
 	// secret key does not exist in the database, a new key is generated
 	// and saved to the database.
 	GetSecret(key string, gen func() []byte) ([]byte, error)
 
+	// ListSecrets returns all secret keys saved in the database.
+	ListSecrets() (map[string][]byte, error)
+
 	// Close the user database.
 	Close() error
 }
 
-var NewPlugin = func() (Plugin, error) {
-	dbtype := config.Get("userdb.type")
-	dburl := config.Get("userdb.url")
-
-	if dbtype == "" && dburl != "" {
-		u, err := url.Parse(dburl)
-		if err != nil {
-			return nil, err
-		}
-		dbtype = u.Scheme
-	}
-
+// NewPlugin creates the user database plugin of the given database type
+// and URL. Plugins register themselves by chaining this function, and
+// delegate to the previous function for other database types.
+var NewPlugin = func(dbtype, dburl string) (Plugin, error) {
 	if dbtype == "" {
 		return nil, fmt.Errorf("The user database plugin does not configured")
 	} else {
@@ -85,6 +89,10 @@ func (e DuplicateUserError) HTTPErrorStatusCode() int {
 	return http.StatusConflict
 }
 
+func (e DuplicateUserError) ErrorCode() string {
+	return "user_exists"
+}
+
 func (e DuplicateNamespaceError) Error() string {
 	return fmt.Sprintf("Namespace already in use: %s", string(e))
 }
@@ -93,6 +101,10 @@ func (e DuplicateNamespaceError) HTTPErrorStatusCode() int {
 	return http.StatusConflict
 }
 
+func (e DuplicateNamespaceError) ErrorCode() string {
+	return "namespace_exists"
+}
+
 func (e UserNotFoundError) Error() string {
 	return fmt.Sprintf("User not found: %s", string(e))
 }
@@ -101,11 +113,36 @@ func (e UserNotFoundError) HTTPErrorStatusCode() int {
 	return http.StatusNotFound
 }
 
+func (e UserNotFoundError) ErrorCode() string {
+	return "user_not_found"
+}
+
 func IsUserNotFound(err error) bool {
 	_, ok := err.(UserNotFoundError)
 	return ok
 }
 
+// The ConflictError indicates that a user was modified by others since it
+// was read.
+type ConflictError string
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("User was modified concurrently, please try again: %s", string(e))
+}
+
+func (e ConflictError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e ConflictError) ErrorCode() string {
+	return "conflict"
+}
+
+func IsConflict(err error) bool {
+	_, ok := err.(ConflictError)
+	return ok
+}
+
 func (e InactiveUserError) Error() string {
 	return fmt.Sprintf("You cannot login using this identity: %s", string(e))
 }
@@ -114,17 +151,66 @@ func (e InactiveUserError) HTTPErrorStatusCode() int {
 	return http.StatusUnauthorized
 }
 
+func (e InactiveUserError) ErrorCode() string {
+	return "inactive_user"
+}
+
+// The IncorrectPasswordError indicates that the old password is incorrect
+// when changing password.
+type IncorrectPasswordError string
+
+func (e IncorrectPasswordError) Error() string {
+	return "The current password is incorrect"
+}
+
+func (e IncorrectPasswordError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e IncorrectPasswordError) ErrorCode() string {
+	return "incorrect_password"
+}
+
 // The UserDatabase type is the central point of user management.
 type UserDatabase struct {
-	plugin Plugin
+	plugin   Plugin
+	provider Provider
 }
 
+// Open the user database configured by "userdb.type" and "userdb.url".
 func Open() (*UserDatabase, error) {
-	plugin, err := NewPlugin()
+	return OpenURL(config.Get("userdb.type"), config.Get("userdb.url"))
+}
+
+// OpenURL opens the user database of the given type and URL. The database
+// type is derived from the URL scheme if it's empty.
+func OpenURL(dbtype, dburl string) (*UserDatabase, error) {
+	plugin, err := OpenPlugin(dbtype, dburl)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := NewProvider(plugin)
 	if err != nil {
+		plugin.Close()
 		return nil, err
 	}
-	return &UserDatabase{plugin}, nil
+	return &UserDatabase{plugin, provider}, nil
+}
+
+// OpenPlugin creates the user database plugin of the given type and URL.
+// The database type is derived from the URL scheme if it's empty.
+func OpenPlugin(dbtype, dburl string) (Plugin, error) {
+	if dbtype == "" && dburl != "" {
+		u, err := url.Parse(dburl)
+		if err != nil {
+			return nil, err
+		}
+		dbtype = u.Scheme
+	}
+	if dbtype == "postgresql" {
+		dbtype = "postgres"
+	}
+	return NewPlugin(dbtype, dburl)
 }
 
 func (db *UserDatabase) Create(user User, password string) error {
@@ -139,7 +225,8 @@ func (db *UserDatabase) Create(user User, password string) error {
 		return err
 	}
 
-	basic.Inactive = false
+	// users registered by themselves are inactive until confirmed
+	basic.Inactive = basic.Registration != nil
 	basic.Applications = nil
 	basic.Password = hashedPassword
 	return db.plugin.Create(user)
@@ -183,31 +270,93 @@ func (db *UserDatabase) Update(name string, fields interface{}) error {
 	return db.plugin.Update(name, fields)
 }
 
+// UpdateIf updates the user only if it was not modified since the given
+// revision was read, otherwise returns a ConflictError.
+func (db *UserDatabase) UpdateIf(name string, revision int64, fields interface{}) error {
+	return db.plugin.UpdateIf(name, revision, fields)
+}
+
+// The maximum number of attempts to modify a user modified concurrently.
+const maxModifyAttempts = 5
+
+// Modify reads the user into the result and updates the user with the
+// fields returned by the function, which is called again with the fresh
+// user if the user was modified concurrently. Nothing is updated if the
+// function returns nil fields or an error.
+func (db *UserDatabase) Modify(name string, result User, fn func() (interface{}, error)) error {
+	for i := 0; i < maxModifyAttempts; i++ {
+		reset(result)
+		if err := db.plugin.Find(name, result); err != nil {
+			return err
+		}
+
+		fields, err := fn()
+		if err != nil || fields == nil {
+			return err
+		}
+
+		basic := result.Basic()
+		err = db.plugin.UpdateIf(name, basic.Revision, fields)
+		if err == nil {
+			basic.Revision++
+		}
+		if !IsConflict(err) {
+			return err
+		}
+	}
+	return ConflictError(name)
+}
+
+// reset clears the user so fields removed from the database are not kept
+// when the user is read again.
+func reset(user User) {
+	v := reflect.ValueOf(user).Elem()
+	v.Set(reflect.Zero(v.Type()))
+}
+
 func (db *UserDatabase) Authenticate(name string, password string) (*BasicUser, error) {
-	var user BasicUser
-	if err := db.plugin.Find(name, &user); err != nil {
-		return nil, err
+	if name == "" || password == "" {
+		return nil, AuthenticationError(name)
 	}
 
-	if user.Inactive {
-		return nil, InactiveUserError(name)
+	if err := db.provider.Authenticate(name, password); err != nil {
+		return nil, err
 	}
 
-	err := bcrypt.CompareHashAndPassword(user.Password, []byte(password))
+	var user BasicUser
+	err := db.plugin.Find(name, &user)
+	if IsUserNotFound(err) && db.provider.External() {
+		// create the user authenticated by external identity service
+		user = BasicUser{Name: name}
+		if err = db.Create(&user, randomPassword()); err == nil {
+			err = db.plugin.Find(name, &user)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if user.Inactive {
+		return nil, InactiveUserError(name)
+	}
+
 	return &user, nil
 }
 
 func (db *UserDatabase) ChangePassword(name string, oldPassword, newPassword string) error {
+	if db.provider.External() {
+		return ErrExternalPassword
+	}
+
 	var user BasicUser
 	if err := db.plugin.Find(name, &user); err != nil {
 		return err
 	}
 
 	err := bcrypt.CompareHashAndPassword(user.Password, []byte(oldPassword))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return IncorrectPasswordError(name)
+	}
 	if err != nil {
 		return err
 	}
@@ -220,6 +369,23 @@ func (db *UserDatabase) ChangePassword(name string, oldPassword, newPassword str
 	return db.plugin.Update(name, Args{"password": hashedPassword})
 }
 
+// SetPassword changes the password of the user without verifying the old
+// password, used by administrators to reset a forgotten password.
+func (db *UserDatabase) SetPassword(name string, password string) error {
+	if db.provider.External() {
+		return ErrExternalPassword
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("Missing required parameters")
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return db.plugin.Update(name, Args{"password": hashedPassword})
+}
+
 // GetSecret returns a secret key used to sign the JWT token. If the
 // secret key does not exist in the database, a new key is generated
 // and saved to the database.
@@ -227,6 +393,11 @@ func (db *UserDatabase) GetSecret(key string, gen func() []byte) ([]byte, error)
 	return db.plugin.GetSecret(key, gen)
 }
 
+// ListSecrets returns all secret keys saved in the user database.
+func (db *UserDatabase) ListSecrets() (map[string][]byte, error) {
+	return db.plugin.ListSecrets()
+}
+
 func (db *UserDatabase) Close() error {
 	return db.plugin.Close()
 }
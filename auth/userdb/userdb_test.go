@@ -1,19 +1,32 @@
 package userdb_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/mgo.v2/bson"
 
 	"github.com/cloudway/platform/auth/userdb"
+	_ "github.com/cloudway/platform/auth/userdb/bolt"
 	. "github.com/cloudway/platform/auth/userdb/matchers"
+	_ "github.com/cloudway/platform/auth/userdb/memory"
 	_ "github.com/cloudway/platform/auth/userdb/mongodb"
+	_ "github.com/cloudway/platform/auth/userdb/postgres"
 )
 
 func TestUserDB(t *testing.T) {
-	os.Setenv("CLOUDWAY_USERDB_URL", "mongodb://127.0.0.1:27017/userdb_test")
+	// run against other user database backends by setting the URL
+	if os.Getenv("CLOUDWAY_USERDB_URL") == "" {
+		os.Setenv("CLOUDWAY_USERDB_URL", "memory://userdb_test")
+	}
 
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "UserDB Suite")
@@ -262,6 +275,33 @@ var _ = Describe("UserDB", func() {
 			Expect(user.Name).To(Equal(CUSTOM_USER))
 		})
 
+		It("should keep custom fields on export and import", func() {
+			var exported bytes.Buffer
+			Expect(db.Export(&exported)).To(Succeed())
+			Expect(db.Remove(CUSTOM_USER)).To(Succeed())
+
+			_, err := db.Import(&exported, userdb.ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			var user CustomUser
+			Expect(db.Find(CUSTOM_USER, &user)).To(Succeed())
+			assertCustomFields(&user)
+		})
+
+		It("should restore custom fields when replacing", func() {
+			var exported bytes.Buffer
+			Expect(db.Export(&exported)).To(Succeed())
+			Expect(db.Update(CUSTOM_USER, userdb.Args{"stringfield": "changed", "extrafield": "extra"})).To(Succeed())
+
+			_, err := db.Import(&exported, userdb.ImportOptions{Mode: userdb.ImportReplace})
+			Expect(err).NotTo(HaveOccurred())
+
+			var user CustomUser
+			Expect(db.Find(CUSTOM_USER, &user)).To(Succeed())
+			assertCustomFields(&user)
+			Expect(db.Search(userdb.Args{"extrafield": "extra"}, &userdb.BasicUser{})).To(BeUserNotFound(""))
+		})
+
 		Context("search for user with custom fields", func() {
 			It("should success if custom fields exists", func() {
 				var user CustomUser
@@ -290,4 +330,322 @@ var _ = Describe("UserDB", func() {
 			})
 		})
 	})
+
+	Describe("Concurrent modification", func() {
+		It("should reject update of stale user", func() {
+			var user userdb.BasicUser
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(db.Update(TEST_USER, userdb.Args{"admin": true})).To(Succeed())
+			Expect(db.UpdateIf(TEST_USER, user.Revision, userdb.Args{"inactive": true})).To(Equal(userdb.ConflictError(TEST_USER)))
+
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(db.UpdateIf(TEST_USER, user.Revision, userdb.Args{"inactive": true})).To(Succeed())
+		})
+
+		It("should retry modification with fresh user", func() {
+			var user userdb.BasicUser
+			attempts := 0
+			err := db.Modify(TEST_USER, &user, func() (interface{}, error) {
+				attempts++
+				if attempts == 1 {
+					// modified by others after the user was read
+					Expect(db.Update(TEST_USER, userdb.Args{"admin": true})).To(Succeed())
+				}
+				return userdb.Args{"env": map[string]string{"A": "1"}}, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(2))
+
+			Expect(db.Find(TEST_USER, &user)).To(Succeed())
+			Expect(user.Admin).To(BeTrue())
+			Expect(user.Env).To(HaveKeyWithValue("A", "1"))
+		})
+	})
+
+	Describe("Export and import", func() {
+		var exported bytes.Buffer
+
+		BeforeEach(func() {
+			exported.Reset()
+			Expect(db.Export(&exported)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			db.Remove(NEW_USER)
+		})
+
+		It("should round trip users with hashed passwords", func() {
+			Expect(db.Remove(TEST_USER)).To(Succeed())
+			Expect(db.Remove(OTHER_USER)).To(Succeed())
+
+			result, err := db.Import(bytes.NewReader(exported.Bytes()), userdb.ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Imported).To(ContainElement(TEST_USER))
+			Expect(result.Imported).To(ContainElement(OTHER_USER))
+
+			assertUserNamespace(TEST_USER, TEST_NAMESPACE)
+			assertUserNamespace(OTHER_USER, OTHER_NAMESPACE)
+
+			_, err = db.Authenticate(TEST_USER, "test")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = db.Authenticate(OTHER_USER, "other")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should import users without local password", func() {
+			var user userdb.BasicUser
+			Expect(db.Modify(OTHER_USER, &user, func() (interface{}, error) {
+				return userdb.Args{"password": []byte{}}, nil
+			})).To(Succeed())
+
+			exported.Reset()
+			Expect(db.Export(&exported)).To(Succeed())
+			Expect(db.Remove(OTHER_USER)).To(Succeed())
+
+			result, err := db.Import(bytes.NewReader(exported.Bytes()), userdb.ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Imported).To(ContainElement(OTHER_USER))
+			assertUserNamespace(OTHER_USER, OTHER_NAMESPACE)
+
+			_, err = db.Authenticate(OTHER_USER, "")
+			Expect(err).To(BeAssignableToTypeOf(userdb.AuthenticationError("")))
+		})
+
+		It("should never export plaintext passwords", func() {
+			var file struct{ Users [][]byte }
+			Expect(json.Unmarshal(exported.Bytes(), &file)).To(Succeed())
+			Expect(file.Users).NotTo(BeEmpty())
+			for _, doc := range file.Users {
+				var user userdb.BasicUser
+				Expect(bson.Unmarshal(doc, &user)).To(Succeed())
+				_, err := bcrypt.Cost(user.Password)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("should skip existing users when merging", func() {
+			Expect(db.ChangePassword(TEST_USER, "test", "changed")).To(Succeed())
+
+			result, err := db.Import(bytes.NewReader(exported.Bytes()), userdb.ImportOptions{Mode: userdb.ImportMerge})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Skipped).To(ContainElement(TEST_USER))
+			Expect(result.Imported).NotTo(ContainElement(TEST_USER))
+
+			_, err = db.Authenticate(TEST_USER, "changed")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should replace existing users when replacing", func() {
+			Expect(db.ChangePassword(TEST_USER, "test", "changed")).To(Succeed())
+
+			result, err := db.Import(bytes.NewReader(exported.Bytes()), userdb.ImportOptions{Mode: userdb.ImportReplace})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Imported).To(ContainElement(TEST_USER))
+
+			_, err = db.Authenticate(TEST_USER, "test")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should skip users with conflicting namespace", func() {
+			Expect(db.Remove(TEST_USER)).To(Succeed())
+			user := userdb.BasicUser{Name: NEW_USER, Namespace: TEST_NAMESPACE}
+			Expect(db.Create(&user, "new")).To(Succeed())
+
+			result, err := db.Import(bytes.NewReader(exported.Bytes()), userdb.ImportOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Skipped).To(ContainElement(TEST_USER))
+			Expect(db.Find(TEST_USER, &userdb.BasicUser{})).To(BeUserNotFound(TEST_USER))
+		})
+
+		It("should reject tampered content", func() {
+			var file map[string]interface{}
+			Expect(json.Unmarshal(exported.Bytes(), &file)).To(Succeed())
+			docs, _ := json.Marshal([][]byte{})
+			file["Users"] = json.RawMessage(docs)
+			tampered, _ := json.Marshal(file)
+			_, err := db.Import(bytes.NewReader(tampered), userdb.ImportOptions{})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidExportError("")))
+		})
+
+		It("should reject unsupported version", func() {
+			content := `{"Version":99,"Checksum":"","Users":[]}`
+			_, err := db.Import(strings.NewReader(content), userdb.ImportOptions{})
+			Expect(err).To(BeAssignableToTypeOf(userdb.InvalidExportError("")))
+		})
+	})
+
+	Describe("Access tokens", func() {
+		It("should verify created token", func() {
+			t, token, err := db.CreateToken(TEST_USER, "ci", nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(HavePrefix(userdb.TokenPrefix))
+			Expect(t.Hash).NotTo(ContainSubstring(token))
+			Expect(t.Scopes).To(ConsistOf(userdb.ScopeRead, userdb.ScopeWrite))
+
+			user, err := db.VerifyToken(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(TEST_USER))
+			Expect(user.Namespace).To(Equal(TEST_NAMESPACE))
+		})
+
+		It("should restrict user to token scopes", func() {
+			_, token, err := db.CreateToken(TEST_USER, "readonly", []userdb.Scope{userdb.ScopeRead}, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			user, err := db.VerifyToken(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.HasScope(userdb.ScopeRead)).To(BeTrue())
+			Expect(user.HasScope(userdb.ScopeWrite)).To(BeFalse())
+		})
+
+		It("should not grant scopes beyond user role", func() {
+			_, _, err := db.CreateToken(TEST_USER, "admin", []userdb.Scope{userdb.ScopeAdmin}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not verify revoked token", func() {
+			t, token, err := db.CreateToken(TEST_USER, "ci", nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(db.RevokeToken(TEST_USER, t.ID)).To(Succeed())
+
+			tokens, err := db.ListTokens(TEST_USER)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokens).To(BeEmpty())
+
+			_, err = db.VerifyToken(token)
+			Expect(err).To(Equal(userdb.InvalidTokenError{}))
+		})
+
+		It("should not restore token revoked concurrently", func() {
+			revoked, _, err := db.CreateToken(TEST_USER, "revoked", nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			var wg sync.WaitGroup
+			created := make(chan string, 2)
+			for _, name := range []string{"ci", "deploy"} {
+				wg.Add(1)
+				go func(name string) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					t, _, err := db.CreateToken(TEST_USER, name, nil, 0)
+					Expect(err).NotTo(HaveOccurred())
+					created <- t.ID
+				}(name)
+			}
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(db.RevokeToken(TEST_USER, revoked.ID)).To(Succeed())
+			}()
+			wg.Wait()
+			close(created)
+
+			var ids []string
+			for id := range created {
+				ids = append(ids, id)
+			}
+			tokens, err := db.ListTokens(TEST_USER)
+			Expect(err).NotTo(HaveOccurred())
+			var listed []string
+			for _, t := range tokens {
+				listed = append(listed, t.ID)
+			}
+			Expect(listed).To(ConsistOf(ids))
+		})
+
+		It("should report revoking unknown token", func() {
+			Expect(db.RevokeToken(TEST_USER, "unknown")).To(Equal(userdb.TokenNotFoundError("unknown")))
+		})
+
+		It("should not verify expired token", func() {
+			_, token, err := db.CreateToken(TEST_USER, "ci", nil, time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+			time.Sleep(10 * time.Millisecond)
+
+			_, err = db.VerifyToken(token)
+			Expect(err).To(Equal(userdb.InvalidTokenError{}))
+		})
+
+		It("should not verify unknown token", func() {
+			_, err := db.VerifyToken(userdb.TokenPrefix + "unknown")
+			Expect(err).To(Equal(userdb.InvalidTokenError{}))
+		})
+	})
+
+	Describe("Application secrets", func() {
+		It("should encrypt secret values", func() {
+			encrypted, err := db.EncryptAppSecret("s3cr3t")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encrypted).NotTo(ContainSubstring("s3cr3t"))
+
+			again, err := db.EncryptAppSecret("s3cr3t")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).NotTo(Equal(encrypted))
+
+			value, err := db.DecryptAppSecret(encrypted)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal("s3cr3t"))
+		})
+
+		It("should reject tampered secret values", func() {
+			_, err := db.DecryptAppSecret("dGFtcGVyZWQgc2VjcmV0IHZhbHVlIHRoYXQgaXMgbG9uZw==")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("External provider", func() {
+		var extdb *userdb.UserDatabase
+		var prev = userdb.NewProvider
+
+		BeforeEach(func() {
+			userdb.NewProvider = func(userdb.Plugin) (userdb.Provider, error) {
+				return fakeProvider{NEW_USER: "secret", TEST_USER: "external"}, nil
+			}
+
+			var err error
+			extdb, err = userdb.Open()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			userdb.NewProvider = prev
+			extdb.Remove(NEW_USER)
+			extdb.Close()
+		})
+
+		It("should authenticate existing user with external credentials", func() {
+			user, err := extdb.Authenticate(TEST_USER, "external")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Namespace).To(Equal(TEST_NAMESPACE))
+
+			_, err = extdb.Authenticate(TEST_USER, "test")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should create user on first login", func() {
+			user, err := extdb.Authenticate(NEW_USER, "secret")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(user.Name).To(Equal(NEW_USER))
+			Expect(extdb.Find(NEW_USER, &userdb.BasicUser{})).To(Succeed())
+		})
+
+		It("should not change password of external user", func() {
+			err := extdb.ChangePassword(TEST_USER, "test", "changed")
+			Expect(err).To(Equal(userdb.ErrExternalPassword))
+		})
+	})
 })
+
+type fakeProvider map[string]string
+
+func (p fakeProvider) Authenticate(name, password string) error {
+	if pass, ok := p[name]; !ok || pass != password {
+		return userdb.AuthenticationError(name)
+	}
+	return nil
+}
+
+func (p fakeProvider) External() bool {
+	return true
+}
@@ -0,0 +1,177 @@
+// Package backup keeps application backup archives in a configurable
+// storage backend.
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Backup describes a stored backup archive.
+type Backup struct {
+	ID      string
+	Created time.Time
+	Size    int64
+}
+
+// Storage saves and retrieves backup archives of applications.
+type Storage interface {
+	// Save the gzipped backup archive as a new backup.
+	Save(namespace, name string, archive io.Reader) (*Backup, error)
+
+	// List backups of the application, the most recent backup first.
+	List(namespace, name string) ([]*Backup, error)
+
+	// Open the archive of the given backup.
+	Open(namespace, name, id string) (io.ReadCloser, error)
+
+	// Remove the given backup.
+	Remove(namespace, name, id string) error
+}
+
+// NewStorage creates the storage configured by "backup.storage". Other
+// storage backends are registered by chaining this function.
+var NewStorage = func() (Storage, error) {
+	switch typ := config.Get("backup.storage"); typ {
+	case "", "file":
+		return NewFileStorage(config.GetOrDefault("backup.dir", "/var/lib/cloudway/backups"))
+	default:
+		return nil, fmt.Errorf("Unsupported backup storage: %s", typ)
+	}
+}
+
+type NotFoundError struct {
+	Name string
+	ID   string
+}
+
+func (e NotFoundError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("%s: no backup found", e.Name)
+	}
+	return fmt.Sprintf("%s: backup %s not found", e.Name, e.ID)
+}
+
+func (e NotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+const archiveSuffix = ".tar.gz"
+
+// NewID generates a backup identifier. Identifiers are sorted in the order
+// the backups are created.
+func NewID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405.000000Z") + "-" + hex.EncodeToString(b)
+}
+
+// ValidID returns true if the id is a well formed backup identifier.
+func ValidID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\") && id != "." && id != ".."
+}
+
+// ByCreated sorts backups with the most recent backup first.
+type ByCreated []*Backup
+
+func (a ByCreated) Len() int           { return len(a) }
+func (a ByCreated) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByCreated) Less(i, j int) bool { return a[i].ID > a[j].ID }
+
+type fileStorage struct {
+	dir string
+}
+
+// NewFileStorage creates a storage that keeps backup archives in the
+// local file system.
+func NewFileStorage(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStorage{dir}, nil
+}
+
+func (s *fileStorage) appDir(namespace, name string) string {
+	return filepath.Join(s.dir, namespace, name)
+}
+
+func (s *fileStorage) Save(namespace, name string, archive io.Reader) (*Backup, error) {
+	dir := s.appDir(namespace, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// write to a temporary file so an incomplete archive is never listed
+	f, err := ioutil.TempFile(dir, ".save")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(f, archive)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	id := NewID()
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, id+archiveSuffix))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &Backup{ID: id, Created: time.Now(), Size: size}, nil
+}
+
+func (s *fileStorage) List(namespace, name string) ([]*Backup, error) {
+	files, err := ioutil.ReadDir(s.appDir(namespace, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*Backup
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(fi.Name(), archiveSuffix) || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		id := strings.TrimSuffix(fi.Name(), archiveSuffix)
+		backups = append(backups, &Backup{ID: id, Created: fi.ModTime(), Size: fi.Size()})
+	}
+	sort.Sort(ByCreated(backups))
+	return backups, nil
+}
+
+func (s *fileStorage) Open(namespace, name, id string) (io.ReadCloser, error) {
+	if !ValidID(id) {
+		return nil, NotFoundError{name, id}
+	}
+	f, err := os.Open(filepath.Join(s.appDir(namespace, name), id+archiveSuffix))
+	if os.IsNotExist(err) {
+		return nil, NotFoundError{name, id}
+	}
+	return f, err
+}
+
+func (s *fileStorage) Remove(namespace, name, id string) error {
+	if !ValidID(id) {
+		return NotFoundError{name, id}
+	}
+	err := os.Remove(filepath.Join(s.appDir(namespace, name), id+archiveSuffix))
+	if os.IsNotExist(err) {
+		return NotFoundError{name, id}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBackup(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Backup Suite")
+}
+
+var _ = Describe("File storage", func() {
+	const (
+		NAMESPACE = "backup_test"
+		NAME      = "test"
+	)
+
+	var (
+		dir     string
+		storage Storage
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "backup")
+		Expect(err).NotTo(HaveOccurred())
+		storage, err = NewFileStorage(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	var read = func(id string) string {
+		r, err := storage.Open(NAMESPACE, NAME, id)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should save and open backups", func() {
+		b, err := storage.Save(NAMESPACE, NAME, strings.NewReader("backup1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Size).To(BeEquivalentTo(7))
+		Expect(read(b.ID)).To(Equal("backup1"))
+	})
+
+	It("should list backups with the most recent first", func() {
+		b1, err := storage.Save(NAMESPACE, NAME, strings.NewReader("backup1"))
+		Expect(err).NotTo(HaveOccurred())
+		b2, err := storage.Save(NAMESPACE, NAME, strings.NewReader("backup2"))
+		Expect(err).NotTo(HaveOccurred())
+
+		list, err := storage.List(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].ID).To(Equal(b2.ID))
+		Expect(list[1].ID).To(Equal(b1.ID))
+	})
+
+	It("should return empty list for unknown application", func() {
+		list, err := storage.List(NAMESPACE, "nonexist")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(BeEmpty())
+	})
+
+	It("should remove backups", func() {
+		b, err := storage.Save(NAMESPACE, NAME, strings.NewReader("backup1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(storage.Remove(NAMESPACE, NAME, b.ID)).To(Succeed())
+
+		_, err = storage.Open(NAMESPACE, NAME, b.ID)
+		Expect(err).To(BeAssignableToTypeOf(NotFoundError{}))
+		Expect(storage.Remove(NAMESPACE, NAME, b.ID)).To(BeAssignableToTypeOf(NotFoundError{}))
+	})
+
+	It("should reject malformed identifiers", func() {
+		_, err := storage.Open(NAMESPACE, NAME, "../../etc/passwd")
+		Expect(err).To(BeAssignableToTypeOf(NotFoundError{}))
+	})
+})
@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/cloudway/platform/backup"
+	"github.com/cloudway/platform/config"
+)
+
+// Backup storage that keeps backup archives in an Amazon S3 compatible
+// object storage. Archives are stored with the key prefix/namespace/name/id.tar.gz.
+type storage struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+const archiveSuffix = ".tar.gz"
+
+func init() {
+	prev := backup.NewStorage
+	backup.NewStorage = func() (backup.Storage, error) {
+		if config.Get("backup.storage") != "s3" {
+			return prev()
+		}
+
+		bucket := config.Get("backup.s3.bucket")
+		if bucket == "" {
+			return nil, errors.New("S3 backup bucket not configured")
+		}
+
+		cfg := aws.NewConfig()
+		if region := config.Get("backup.s3.region"); region != "" {
+			cfg = cfg.WithRegion(region)
+		}
+		if endpoint := config.Get("backup.s3.endpoint"); endpoint != "" {
+			cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+		}
+
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return &storage{
+			client:   s3.New(sess),
+			uploader: s3manager.NewUploader(sess),
+			bucket:   bucket,
+			prefix:   strings.Trim(config.Get("backup.s3.prefix"), "/"),
+		}, nil
+	}
+}
+
+func (s *storage) appKey(namespace, name string) string {
+	return path.Join(s.prefix, namespace, name) + "/"
+}
+
+func (s *storage) Save(namespace, name string, archive io.Reader) (*backup.Backup, error) {
+	id := backup.NewID()
+	counter := &countingReader{r: archive}
+	_, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.appKey(namespace, name) + id + archiveSuffix),
+		Body:   counter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &backup.Backup{ID: id, Created: time.Now(), Size: counter.n}, nil
+}
+
+func (s *storage) List(namespace, name string) ([]*backup.Backup, error) {
+	prefix := s.appKey(namespace, name)
+	input := &s3.ListObjectsInput{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+
+	var backups []*backup.Backup
+	err := s.client.ListObjectsPages(input, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, obj := range page.Contents {
+			key := strings.TrimPrefix(aws.StringValue(obj.Key), prefix)
+			if !strings.HasSuffix(key, archiveSuffix) {
+				continue
+			}
+			backups = append(backups, &backup.Backup{
+				ID:      strings.TrimSuffix(key, archiveSuffix),
+				Created: aws.TimeValue(obj.LastModified),
+				Size:    aws.Int64Value(obj.Size),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(backup.ByCreated(backups))
+	return backups, nil
+}
+
+func (s *storage) Open(namespace, name, id string) (io.ReadCloser, error) {
+	if !backup.ValidID(id) {
+		return nil, backup.NotFoundError{Name: name, ID: id}
+	}
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.appKey(namespace, name) + id + archiveSuffix),
+	})
+	if isNotFound(err) {
+		return nil, backup.NotFoundError{Name: name, ID: id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *storage) Remove(namespace, name, id string) error {
+	if !backup.ValidID(id) {
+		return backup.NotFoundError{Name: name, ID: id}
+	}
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.appKey(namespace, name) + id + archiveSuffix),
+	})
+	return err
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == s3.ErrCodeNoSuchKey
+	}
+	return false
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+	return
+}
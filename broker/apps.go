@@ -6,7 +6,6 @@ import (
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
-	errs "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -21,13 +20,19 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/metrics"
 	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/domain"
 	"github.com/cloudway/platform/pkg/errors"
 	"github.com/cloudway/platform/pkg/files"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/proxy"
+	"github.com/cloudway/platform/scanner"
 	"github.com/cloudway/platform/scm"
 )
 
@@ -86,6 +91,24 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 		return
 	}
 
+	// add plugins required by the specified plugins
+	required, err := hub.ResolveRequires(nil, plugins, br.GetPluginInfo)
+	if err != nil {
+		return
+	}
+	for _, p := range required {
+		names, plugins, tags = append(names, ""), append(plugins, p), append(tags, p.Tag)
+	}
+	for _, p := range plugins {
+		if err = scanner.Check(p); err != nil {
+			return
+		}
+	}
+
+	if err = br.checkQuota(requestedUsage(nil, opts, names, plugins, 1)); err != nil {
+		return
+	}
+
 	// Generate shared secret for application. The shared secret is a simple
 	// mechanism for a scalable application to communicate securely between
 	// containers, or used as a randomize seed to generate shared tokens.
@@ -137,7 +160,7 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 	}
 
 	// create all containers
-	containers, err = br.createContainers(opts, names, plugins)
+	containers, err = br.createContainers(nil, opts, names, plugins)
 	if err != nil {
 		return
 	}
@@ -162,9 +185,16 @@ func (br *UserBroker) CreateApplication(opts container.CreateOptions, tags []str
 		CreatedAt: time.Now(),
 		Plugins:   tags,
 		Secret:    opts.Secret,
+		TimeZone:  opts.TimeZone,
+		Locale:    opts.Locale,
 	}
-	apps[opts.Name] = app
-	err = br.Users.Update(user.Name, userdb.Args{"applications": apps})
+	err = br.modifyApplications(br.User, func(apps map[string]*userdb.Application) error {
+		if apps[opts.Name] != nil {
+			return ApplicationExistError{opts.Name, opts.Namespace}
+		}
+		apps[opts.Name] = app
+		return nil
+	})
 	if err != nil {
 		return
 	}
@@ -212,7 +242,7 @@ func populateFromTemplate(scm scm.SCM, opts *container.CreateOptions, template s
 }
 
 func deployRepo(scm scm.SCM, opts *container.CreateOptions, containers []*container.Container) error {
-	return scm.Deploy(opts.Namespace, opts.Name, "", opts.Log)
+	return scm.Deploy(context.Background(), opts.Namespace, opts.Name, "", container.DeployOptions{}, opts.Log)
 }
 
 func generateSharedSecret() (string, error) {
@@ -252,24 +282,75 @@ func (br *UserBroker) CreateServices(opts container.CreateOptions, tags []string
 		names[i], plugins[i], tags[i] = n, p, p.Tag
 	}
 
+	// add plugins required by the new services and not installed yet
+	var installed []*manifest.Plugin
+	for _, tag := range app.Plugins {
+		if p, err := br.GetPluginInfo(tag); err == nil {
+			installed = append(installed, p)
+		}
+	}
+	required, err := hub.ResolveRequires(installed, plugins, br.GetPluginInfo)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range required {
+		names, plugins, tags = append(names, ""), append(plugins, p), append(tags, p.Tag)
+	}
+	for _, p := range plugins {
+		if err = scanner.Check(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = br.checkQuota(requestedUsage(app, opts, names, plugins, 0)); err != nil {
+		return nil, err
+	}
+
 	opts.Namespace = user.Namespace
 	opts.Secret = app.Secret
 	opts.Hosts = app.Hosts
+	opts.TimeZone = app.TimeZone
+	opts.Locale = app.Locale
 
-	containers, err = br.createContainers(opts, names, plugins)
+	containers, err = br.createContainers(app, opts, names, plugins)
 	if err != nil {
 		return nil, err
 	}
 
-	app.Plugins = append(app.Plugins, tags...)
-	err = br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	err = br.modifyApplication(br.User, opts.Name, func(app *userdb.Application) error {
+		app.Plugins = append(app.Plugins, tags...)
+		return nil
+	})
 	return containers, err
 }
 
-func (br *UserBroker) createContainers(opts container.CreateOptions, serviceNames []string, plugins []*manifest.Plugin) (containers []*container.Container, err error) {
+// requestedUsage returns the resources allocated to create the number of
+// applications with plugins. The framework is created with the scaling number
+// of containers and each service is created in a single container.
+func requestedUsage(app *userdb.Application, opts container.CreateOptions, serviceNames []string, plugins []*manifest.Plugin, apps int) Usage {
+	usage := Usage{Applications: apps}
+	for i, plugin := range plugins {
+		n := 1
+		if plugin.IsFramework() {
+			n = opts.Scaling
+		}
+		usage.add(n, serviceResources(app, serviceNames[i], plugin, opts.Resources))
+	}
+	return usage
+}
+
+func (br *UserBroker) createContainers(app *userdb.Application, opts container.CreateOptions, serviceNames []string, plugins []*manifest.Plugin) (containers []*container.Container, err error) {
+	resources, env := opts.Resources, opts.Env
 	for i, plugin := range plugins {
 		opts.Plugin = plugin
+		opts.Env = env
+		if plugin.IsFramework() {
+			opts.Env = br.inheritEnv(env)
+			opts.Networks = grantedNetworks(br.User.Basic().Applications, opts.Name)
+		}
 		opts.ServiceName = serviceNames[i]
+		opts.Resources = serviceResources(app, serviceNames[i], plugin, resources)
+		opts.Logging = logConfigOf(app)
 		var cs []*container.Container
 		cs, err = br.Create(br.ctx, opts)
 		containers = append(containers, cs...)
@@ -305,12 +386,49 @@ func (br *UserBroker) RemoveApplication(name string) (err error) {
 		}
 	}
 
-	// remove application repository
-	errors.Add(br.SCM.RemoveRepo(user.Namespace, name))
+	// remove application network and network grants
+	errors.Add(br.removeAppNetwork(name, apps))
+
+	// remove deploy key from external repository
+	if repo := apps[name].Repository; repo != nil {
+		br.removeDeployKey(repo)
+	}
+
+	// remove certificates of custom domains from proxy
+	for _, cert := range apps[name].Certificates {
+		host := cert.Domain
+		errors.Add(withCertificateStore(func(store proxy.CertificateStore) error {
+			return store.RemoveCertificate(host)
+		}))
+	}
+
+	// remove aggregated logs
+	if br.LogStore != nil {
+		errors.Add(br.LogStore.Remove(user.Namespace, name))
+	}
+
+	// stop holding requests of the idle application
+	if apps[name].CurrentState() == userdb.AppIdle {
+		errors.Add(unparkApplication(user.Namespace, name))
+	}
 
-	// remove application from user database
-	delete(apps, name)
-	errors.Add(br.Users.Update(user.Name, userdb.Args{"applications": apps}))
+	// remove application repository, deployment history, webhook logs,
+	// cron job runs, build logs and build caches
+	errors.Add(br.SCM.RemoveRepo(user.Namespace, name))
+	errors.Add(br.History.Discard(user.Namespace, name, apps[name].Deployments))
+	errors.Add(br.Hooks.RemoveAll(user.Namespace, name))
+	errors.Add(br.Cron.Remove(user.Namespace, name))
+	errors.Add(br.Builds.Remove(user.Namespace, name))
+	errors.Add(br.RemoveBuildCache(br.ctx, name, user.Namespace))
+
+	// remove application and network grants to it from user database
+	errors.Add(br.modifyApplications(br.User, func(apps map[string]*userdb.Application) error {
+		delete(apps, name)
+		for _, app := range apps {
+			app.NetworkGrants = removeGrant(app.NetworkGrants, name)
+		}
+		return nil
+	}))
 
 	return errors.Err()
 }
@@ -338,19 +456,29 @@ func (br *UserBroker) RemoveService(name, service string) (err error) {
 		return fmt.Errorf("service '%s' not found in application '%s'", service, name)
 	}
 
+	var tags []string
 	for _, c := range containers {
 		errors.Add(c.Destroy(br.ctx))
+		tags = append(tags, c.PluginTag())
+	}
 
-		tag := c.PluginTag()
-		for i := range app.Plugins {
-			if tag == app.Plugins[i] {
-				app.Plugins = append(app.Plugins[:i], app.Plugins[i+1:]...)
-				break
-			}
-		}
+	// remove connection variables of the service from framework containers
+	if cs, err := br.FindApplications(br.ctx, name, user.Namespace); err == nil {
+		errors.Add(container.UnlinkService(br.ctx, cs, service))
 	}
 
-	errors.Add(br.Users.Update(user.Name, userdb.Args{"applications": user.Applications}))
+	errors.Add(br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		for _, tag := range tags {
+			for i := range app.Plugins {
+				if tag == app.Plugins[i] {
+					app.Plugins = append(app.Plugins[:i], app.Plugins[i+1:]...)
+					break
+				}
+			}
+		}
+		removeResourceLimits(app, service)
+		return nil
+	}))
 	return errors.Err()
 }
 
@@ -389,7 +517,12 @@ func (br *UserBroker) ScaleApplication(name string, num int) ([]*container.Conta
 	}
 
 	if len(cs) < num {
-		return br.scaleUp(cs[0], num, app.Secret, app.Hosts)
+		var requested Usage
+		requested.add(num-len(cs), cs[0].Resources())
+		if err = br.checkQuota(requested); err != nil {
+			return nil, err
+		}
+		return br.scaleUp(cs[0], num, app)
 	} else if len(cs) > num {
 		return nil, br.scaleDown(cs, len(cs)-num)
 	} else {
@@ -397,7 +530,54 @@ func (br *UserBroker) ScaleApplication(name string, num int) ([]*container.Conta
 	}
 }
 
-func (br *UserBroker) scaleUp(replica *container.Container, num int, secret string, hosts []string) (containers []*container.Container, err error) {
+type ServiceScalingError struct {
+	Name, Service string
+}
+
+func (e ServiceScalingError) Error() string {
+	return fmt.Sprintf("The service '%s' in application '%s' cannot be scaled", e.Service, e.Name)
+}
+
+func (e ServiceScalingError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Scale the containers of a service in the application. Only the framework
+// service, which is named after the framework plugin, can be scaled. Other
+// services such as databases always run in a single container.
+func (br *UserBroker) ScaleService(name, service string, num int) ([]*container.Container, error) {
+	if service == "" {
+		return br.ScaleApplication(name, num)
+	}
+
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	cs, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	_, _, framework, _, _ := hub.ParseTag(cs[0].PluginTag())
+	if service != framework {
+		services, err := br.FindService(br.ctx, name, br.Namespace(), service)
+		if err != nil {
+			return nil, err
+		}
+		if len(services) == 0 {
+			return nil, fmt.Errorf("Service '%s' not found in application '%s'", service, name)
+		}
+		return nil, ServiceScalingError{Name: name, Service: service}
+	}
+
+	return br.ScaleApplication(name, num)
+}
+
+func (br *UserBroker) scaleUp(replica *container.Container, num int, app *userdb.Application) (containers []*container.Container, err error) {
 	meta, err := br.Hub.GetPluginInfo(replica.PluginTag())
 	if err != nil {
 		return
@@ -406,12 +586,17 @@ func (br *UserBroker) scaleUp(replica *container.Container, num int, secret stri
 	opts := container.CreateOptions{
 		Name:      replica.Name,
 		Namespace: replica.Namespace,
-		Hosts:     hosts,
+		Hosts:     app.Hosts,
 		Plugin:    meta,
 		Home:      replica.Home(),
 		User:      replica.User(),
-		Secret:    secret,
+		Secret:    app.Secret,
+		TimeZone:  app.TimeZone,
+		Locale:    app.Locale,
 		Scaling:   num,
+		Resources: replica.Resources(),
+		Logging:   logConfigOf(app),
+		Networks:  grantedNetworks(br.User.Basic().Applications, replica.Name),
 	}
 
 	containers, err = br.Create(br.ctx, opts)
@@ -419,13 +604,20 @@ func (br *UserBroker) scaleUp(replica *container.Container, num int, secret stri
 		return
 	}
 
+	// new instances share environment variables with the replica
+	for _, c := range containers {
+		if err = container.CopyEnv(br.ctx, replica, c); err != nil {
+			return
+		}
+	}
+
 	repo, _, err := replica.CopyFromContainer(br.ctx, replica.ID, replica.RepoDir()+"/.")
 	if err != nil {
 		return
 	}
 	defer repo.Close()
 
-	err = br.DistributeRepo(br.ctx, containers, repo, true)
+	err = br.DistributeRepo(br.ctx, containers, repo, true, container.DeployOptions{}, nil)
 	return
 }
 
@@ -439,11 +631,12 @@ func (br *UserBroker) scaleDown(containers []*container.Container, num int) erro
 }
 
 func (br *UserBroker) AddHost(name, host string) error {
-	if host == "" || strings.HasSuffix(host, "."+defaults.Domain()) {
-		return errs.New("Invalid domain name")
+	host, err := domain.Normalize(host, defaults.Domain())
+	if err != nil {
+		return err
 	}
 
-	if err := br.Refresh(); err != nil {
+	if err = br.Refresh(); err != nil {
 		return err
 	}
 
@@ -472,8 +665,19 @@ func (br *UserBroker) AddHost(name, host string) error {
 		}
 	}
 
-	app.Hosts = append(app.Hosts, host)
-	return br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		for _, h := range app.Hosts {
+			if host == h {
+				return errUnchanged
+			}
+		}
+		app.Hosts = append(app.Hosts, host)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return br.updateEndpoints(cs)
 }
 
 func (br *UserBroker) RemoveHost(name, host string) error {
@@ -487,14 +691,13 @@ func (br *UserBroker) RemoveHost(name, host string) error {
 		return ApplicationNotFoundError(name)
 	}
 
-	var removed bool
-	for i, h := range app.Hosts {
+	var found bool
+	for _, h := range app.Hosts {
 		if host == h {
-			app.Hosts = append(app.Hosts[:i], app.Hosts[i+1:]...)
-			removed = true
+			found = true
 		}
 	}
-	if !removed {
+	if !found {
 		return nil
 	}
 
@@ -511,41 +714,50 @@ func (br *UserBroker) RemoveHost(name, host string) error {
 		}
 	}
 
-	return br.Users.Update(user.Name, userdb.Args{"applications": user.Applications})
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		hosts := app.Hosts[:0]
+		for _, h := range app.Hosts {
+			if host != h {
+				hosts = append(hosts, h)
+			}
+		}
+		app.Hosts = hosts
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return br.updateEndpoints(cs)
 }
 
+// StartApplication starts all containers of the application in dependency
+// order, and resumes the application if stopped or idled.
 func (br *UserBroker) StartApplication(name string, log *serverlog.ServerLog) error {
-	return br.startApplication(name, func(c *container.Container) error {
-		return c.Start(br.ctx, log)
+	return br.changeState(name, "start", userdb.AppRunning, func(cs []*container.Container) error {
+		return startContainers(cs, func(c *container.Container) error {
+			return c.Start(br.ctx, log)
+		})
 	})
 }
 
+// RestartApplication restarts all containers of the application in
+// dependency order.
 func (br *UserBroker) RestartApplication(name string, log *serverlog.ServerLog) error {
-	return br.startApplication(name, func(c *container.Container) error {
-		return c.Restart(br.ctx, log)
+	return br.changeState(name, "restart", userdb.AppRunning, func(cs []*container.Container) error {
+		return restartContainers(cs, func(c *container.Container) error {
+			return c.Restart(br.ctx, log)
+		})
 	})
 }
 
-func (br *UserBroker) startApplication(name string, fn func(*container.Container) error) error {
-	containers, err := br.FindAll(br.ctx, name, br.Namespace())
-	if err != nil {
-		return err
-	}
-	if len(containers) == 0 {
-		return ApplicationNotFoundError(name)
-	}
-	return startContainers(containers, fn)
-}
-
+// StopApplication stops all containers of the application in reverse
+// dependency order.
 func (br *UserBroker) StopApplication(name string) error {
-	containers, err := br.FindAll(br.ctx, name, br.Namespace())
-	if err != nil {
-		return err
-	}
-	if len(containers) == 0 {
-		return ApplicationNotFoundError(name)
-	}
-	return runParallel(err, containers, func(c *container.Container) error { return c.Stop(br.ctx) })
+	return br.changeState(name, "stop", userdb.AppStopped, func(cs []*container.Container) error {
+		return stopContainers(cs, func(c *container.Container) error {
+			return c.Stop(br.ctx)
+		})
+	})
 }
 
 func (br *Broker) StartContainers(ctx context.Context, containers []*container.Container, log *serverlog.ServerLog) error {
@@ -554,17 +766,22 @@ func (br *Broker) StartContainers(ctx context.Context, containers []*container.C
 	})
 }
 
+// startContainers starts services without dependencies in parallel, then
+// services depending on other services one by one, and frameworks last in
+// parallel. Remaining containers are skipped once any container failed.
 func startContainers(containers []*container.Container, fn func(*container.Container) error) error {
-	err := container.ResolveServiceDependencies(containers)
-	if err != nil {
-		return err
-	}
+	return runSchedule("start", containers, fn, false)
+}
 
-	sch := makeSchedule(containers)
-	err = runParallel(nil, sch.parallel, fn)
-	err = runSerial(err, sch.serial, fn)
-	err = runParallel(err, sch.final, fn)
-	return err
+func restartContainers(containers []*container.Container, fn func(*container.Container) error) error {
+	return runSchedule("restart", containers, fn, false)
+}
+
+// stopContainers stops containers in the reverse order of startContainers,
+// so frameworks are stopped before services they depend on. All containers
+// are stopped even if some of them failed.
+func stopContainers(containers []*container.Container, fn func(*container.Container) error) error {
+	return runSchedule("stop", containers, fn, true)
 }
 
 type schedule struct {
@@ -589,51 +806,170 @@ func makeSchedule(containers []*container.Container) *schedule {
 	return sch
 }
 
-func runParallel(err error, cs []*container.Container, fn func(*container.Container) error) error {
+// stages returns groups of containers operated one group after another,
+// containers in a group are operated in parallel.
+func (sch *schedule) stages(reverse bool) [][]*container.Container {
+	stages := [][]*container.Container{sch.parallel}
+	for _, c := range sch.serial {
+		stages = append(stages, []*container.Container{c})
+	}
+	stages = append(stages, sch.final)
+
+	if reverse {
+		for i, j := 0, len(stages)-1; i < j; i, j = i+1, j-1 {
+			stages[i], stages[j] = stages[j], stages[i]
+		}
+	}
+	return stages
+}
+
+// runSchedule runs the lifecycle operation on containers in dependency
+// order, and reports failed containers by a PartialFailureError.
+func runSchedule(op string, containers []*container.Container, fn func(*container.Container) error, stop bool) error {
+	err := container.ResolveServiceDependencies(containers)
 	if err != nil {
 		return err
 	}
-	if len(cs) == 0 {
+
+	var (
+		failed  []*ContainerFailure
+		skipped []*ContainerFailure
+		lock    sync.Mutex
+	)
+	record := func(c *container.Container) {
+		if err := fn(c); err != nil {
+			lock.Lock()
+			failed = append(failed, newContainerFailure(c, err))
+			lock.Unlock()
+		}
+	}
+
+	for _, stage := range makeSchedule(containers).stages(stop) {
+		if len(failed) != 0 && !stop {
+			for _, c := range stage {
+				skipped = append(skipped, newContainerFailure(c, nil))
+			}
+			continue
+		}
+		runParallel(stage, record)
+	}
+
+	if len(failed) == 0 {
 		return nil
 	}
-	if len(cs) == 1 {
-		return fn(cs[0])
+	return PartialFailureError{
+		Operation: op,
+		Total:     len(containers),
+		Failed:    failed,
+		Skipped:   skipped,
 	}
+}
 
+// runParallel runs the function on containers in parallel, and waits until
+// all containers are done.
+func runParallel(cs []*container.Container, fn func(*container.Container)) {
 	var wg sync.WaitGroup
 	wg.Add(len(cs))
-
-	var errors errors.Errors
-	var errLock sync.Mutex
-
 	for _, c := range cs {
-		go func(wg *sync.WaitGroup, c *container.Container) {
+		go func(c *container.Container) {
 			defer wg.Done()
-			if err := fn(c); err != nil {
-				errLock.Lock()
-				errors.Add(err)
-				errLock.Unlock()
-			}
-		}(&wg, c)
+			fn(c)
+		}(c)
 	}
-
 	wg.Wait()
-	return errors.Err()
 }
 
-func runSerial(err error, cs []*container.Container, fn func(*container.Container) error) error {
-	if err == nil {
-		for _, c := range cs {
-			if err = fn(c); err != nil {
-				break
-			}
-		}
+// Deploy the application from the given branch of the repository.
+func (br *UserBroker) Deploy(name, branch string, opts container.DeployOptions, log *serverlog.ServerLog) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return ApplicationNotFoundError(name)
 	}
+
+	release, err := connectTrafficSwitcher(&opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, done, err := br.startDeployment(name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := br.lockBuild(ctx, name, "deploy", log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	br.recordDeployment(name, &opts)
+	log, finish := br.recordBuild(name, &opts, log)
+	reconciled := br.applyManifest(name, &opts, log)
+	err = br.notifyDeploy(name, opts.Strategy, func() error {
+		return reconciled(br.SCM.Deploy(ctx, br.Namespace(), name, branch, opts, log))
+	})
+	finish(err)
 	return err
 }
 
+// Connects to the proxy to switch traffic for blue-green deployment, or
+// to split traffic for canary deployment. The returned function releases
+// the connection.
+func connectTrafficSwitcher(opts *container.DeployOptions) (release func(), err error) {
+	switch {
+	case opts.Strategy == container.DeployBlueGreen && opts.Switcher == nil:
+	case opts.Strategy == container.DeployCanary && opts.Splitter == nil:
+	default:
+		return func() {}, nil
+	}
+
+	px, err := proxy.New(config.Get("proxy.url"))
+	if err != nil {
+		return nil, err
+	}
+	opts.Switcher, opts.Splitter = px, px
+	return func() { px.Close() }, nil
+}
+
+// Promote the canary release of the application to all containers.
+func (br *UserBroker) PromoteCanary(name string, log *serverlog.ServerLog) error {
+	return br.finishCanary(name, log, br.DockerClient.PromoteCanary)
+}
+
+// Abort the canary deployment of the application and restore the stable
+// release to canary containers.
+func (br *UserBroker) AbortCanary(name string, log *serverlog.ServerLog) error {
+	return br.finishCanary(name, log, br.DockerClient.AbortCanary)
+}
+
+type canaryFunc func(ctx context.Context, name, namespace string, splitter container.TrafficSplitter, log *serverlog.ServerLog) error
+
+func (br *UserBroker) finishCanary(name string, log *serverlog.ServerLog, fn canaryFunc) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	px, err := proxy.New(config.Get("proxy.url"))
+	if err != nil {
+		return err
+	}
+	defer px.Close()
+
+	return fn(br.ctx, name, br.Namespace(), px, log)
+}
+
 // Download application repository as a archive file.
 func (br *UserBroker) Download(name string) (io.ReadCloser, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
 	containers, err := br.FindApplications(br.ctx, name, br.Namespace())
 	if err != nil {
 		return nil, err
@@ -646,23 +982,35 @@ func (br *UserBroker) Download(name string) (io.ReadCloser, error) {
 	return r, err
 }
 
-// Upload application repository from a archive file.
-func (br *UserBroker) Upload(name string, content io.Reader, binary bool, log *serverlog.ServerLog) error {
-	if binary {
-		containers, err := br.FindApplications(br.ctx, name, br.Namespace())
-		if err != nil {
-			return err
-		}
-		if len(containers) == 0 {
-			return ApplicationNotFoundError(name)
-		}
-		return br.DistributeRepo(br.ctx, containers, content, false)
-	} else {
-		return br.DeployRepo(br.ctx, name, br.Namespace(), content, log)
+// distributeBinary distributes a prebuilt repository to the application
+// containers if the deploy condition is satisfied.
+func (br *UserBroker) distributeBinary(ctx context.Context, containers []*container.Container, content io.Reader, opts container.DeployOptions, log *serverlog.ServerLog) (err error) {
+	defer metrics.ObserveDeployment(opts.Strategy.String(), time.Now(), &err)
+
+	if err = container.CheckDeployCondition(ctx, containers, opts.Condition, log); err != nil {
+		return err
 	}
+	return br.DistributeRepo(ctx, containers, content, false, opts, log)
+}
+
+// checkNoFramework returns an error reporting the application contains no
+// framework container, or the application not found.
+func (br *UserBroker) checkNoFramework(name string) error {
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	if len(cs) != 0 {
+		return container.NoFrameworkError(name)
+	}
+	return ApplicationNotFoundError(name)
 }
 
 func (br *UserBroker) Dump(name string) (io.ReadCloser, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
 	// find all containers
 	containers, err := br.FindAll(br.ctx, name, br.Namespace())
 	if err != nil {
@@ -714,6 +1062,10 @@ func (br *UserBroker) Dump(name string) (io.ReadCloser, error) {
 }
 
 func (br *UserBroker) Restore(name string, source io.Reader) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
 	// find all containers
 	containers, err := br.FindAll(br.ctx, name, br.Namespace())
 	if err != nil {
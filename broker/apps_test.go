@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
 	"github.com/cloudway/platform/container"
 	"golang.org/x/net/context"
 )
@@ -55,4 +56,77 @@ var _ = Describe("Applications", func() {
 			Expect(br.RemoveApplication("test")).To(Succeed())
 		})
 	})
+
+	Describe("Scale", func() {
+		BeforeEach(func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			opts := container.CreateOptions{Name: "scale"}
+			_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+			Expect(err).NotTo(HaveOccurred())
+
+			cs, err := broker.FindApplications(context.Background(), "scale", NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs[0].Setenv(context.Background(), "SCALE_TEST", "shared")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			ub.RemoveApplication("scale")
+		})
+
+		It("should scale framework service with copied environment", func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			cs, err := ub.ScaleService("scale", "mock", 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(HaveLen(2))
+			for _, c := range cs {
+				Expect(c.Getenv(context.Background(), "SCALE_TEST")).To(Equal("shared"))
+			}
+
+			_, err = ub.ScaleService("scale", "mock", 1)
+			Expect(err).NotTo(HaveOccurred())
+			cs, err = broker.FindApplications(context.Background(), "scale", NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(HaveLen(1))
+		})
+
+		It("should not scale backing services", func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			_, err := ub.ScaleService("scale", "mockdb", 2)
+			Expect(err).To(Equal(br.ServiceScalingError{Name: "scale", Service: "mockdb"}))
+		})
+
+		It("should fail for nonexistent service", func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			_, err := ub.ScaleService("scale", "nonexist", 2)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Upgrade plugin", func() {
+		BeforeEach(func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			opts := container.CreateOptions{Name: "upgrade"}
+			_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			ub.RemoveApplication("upgrade")
+		})
+
+		It("should not upgrade applications running the same version", func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			upgraded, err := ub.UpgradePlugin("mock", "upgrade", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(upgraded).To(BeEmpty())
+		})
+
+		It("should fail for nonexistent application", func() {
+			ub := broker.NewUserBroker(&user, context.Background())
+			_, err := ub.UpgradePlugin("mock", "nonexist", nil)
+			Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+		})
+	})
 })
@@ -0,0 +1,313 @@
+package broker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+
+	"github.com/cloudway/platform/backup"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// backupManifest describes the application saved in a backup archive.
+type backupManifest struct {
+	Name     string
+	Plugins  []string
+	Scaling  int
+	Hosts    []string `json:",omitempty"`
+	TimeZone string   `json:",omitempty"`
+	Locale   string   `json:",omitempty"`
+}
+
+// Backup saves the repository, environment and data of all application
+// containers into a single archive in the backup storage.
+//
+// The backup archive is a gzipped tar file with following layout:
+//
+//	manifest.json               the application description
+//	repo.tar                    the deployed repository
+//	env/app.tar                 the framework environment variables
+//	env/services/<name>.tar     the service environment variables
+//	data/app/data.tar           the framework data snapshot
+//	data/services/<name>.tar    the service data snapshots
+func (br *UserBroker) Backup(name string) (*backup.Backup, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	containers, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	container.ResolveServiceDependencies(containers)
+
+	tempdir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	m := backupManifest{
+		Name:     name,
+		Hosts:    app.Hosts,
+		TimeZone: app.TimeZone,
+		Locale:   app.Locale,
+	}
+
+	var framework *container.Container
+	for _, c := range containers {
+		switch {
+		case c.Category().IsFramework():
+			if framework == nil {
+				framework = c
+				m.Plugins = append(m.Plugins, c.PluginTag())
+				err = br.saveContainerDir(c, c.EnvDir(), filepath.Join(tempdir, "env", "app.tar"))
+				if err == nil {
+					err = br.saveContainerDir(c, c.RepoDir(), filepath.Join(tempdir, "repo.tar"))
+				}
+				if err == nil {
+					err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, "data", "app", "data.tar"))
+				}
+			}
+			m.Scaling++
+		case c.Category().IsService():
+			m.Plugins = append(m.Plugins, c.ServiceName()+"="+c.PluginTag())
+			err = br.saveContainerDir(c, c.EnvDir(), filepath.Join(tempdir, "env", "services", c.ServiceName()+".tar"))
+			if err == nil {
+				err = saveSnapshot(br.ctx, c, filepath.Join(tempdir, "data", "services", c.ServiceName()+".tar"))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if framework == nil {
+		return nil, container.NoFrameworkError(name)
+	}
+
+	data, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(filepath.Join(tempdir, "manifest.json"), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// stream the final archive to the backup storage
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		tw := tar.NewWriter(zw)
+		err := archive.CopyFileTree(tw, "", tempdir, nil, false)
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	return br.Backups.Save(br.Namespace(), name, pr)
+}
+
+// saveContainerDir saves the contents of a directory in the container into a tar file.
+func (br *UserBroker) saveContainerDir(c *container.Container, dir, filename string) error {
+	r, _, err := c.CopyFromContainer(br.ctx, c.ID, dir+"/.")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	os.MkdirAll(filepath.Dir(filename), 0755)
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// ListBackups returns backups of the application, the most recent first.
+// Backups are kept after the application is removed so they can be
+// restored later.
+func (br *UserBroker) ListBackups(name string) ([]*backup.Backup, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	return br.Backups.List(br.Namespace(), name)
+}
+
+// RestoreBackup recreates the application from a backup. The most recent
+// backup is restored if id is empty. The application must not exist.
+func (br *UserBroker) RestoreBackup(name, id string, log *serverlog.ServerLog) (*backup.Backup, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	user := br.User.Basic()
+	if user.Applications[name] != nil {
+		return nil, ApplicationExistError{name, user.Namespace}
+	}
+
+	b, err := br.findBackup(name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tempdir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempdir)
+
+	fmt.Fprintf(log, "Extracting backup %s\n", b.ID)
+	if err = br.extractBackup(name, b.ID, tempdir); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(tempdir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var m backupManifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(log, "Creating application %s\n", name)
+	opts := container.CreateOptions{
+		Name:     name,
+		Scaling:  m.Scaling,
+		TimeZone: m.TimeZone,
+		Locale:   m.Locale,
+		Repo:     "empty",
+		Log:      log,
+	}
+	_, containers, err := br.CreateApplication(opts, m.Plugins)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove the half-restored application, so the restore can be retried
+	if err = br.restoreApplication(name, tempdir, &m, containers, log); err != nil {
+		if rerr := br.RemoveApplication(name); rerr != nil {
+			logrus.WithError(rerr).Warnf("Failed to remove application %s after failed restore", name)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// restoreApplication restores hosts, environment, repository and data of the
+// created application from the extracted backup.
+func (br *UserBroker) restoreApplication(name, tempdir string, m *backupManifest, containers []*container.Container, log *serverlog.ServerLog) error {
+	for _, host := range m.Hosts {
+		if err := br.AddHost(name, host); err != nil {
+			logrus.WithError(err).Warnf("Failed to restore host %s", host)
+		}
+	}
+
+	// restore environment variables before deploying repository
+	var apps []*container.Container
+	for _, c := range containers {
+		var envfile string
+		if c.Category().IsFramework() {
+			envfile = filepath.Join(tempdir, "env", "app.tar")
+			apps = append(apps, c)
+		} else if c.Category().IsService() {
+			envfile = filepath.Join(tempdir, "env", "services", c.ServiceName()+".tar")
+		}
+		if envfile != "" {
+			if err := br.restoreContainerDir(c, c.EnvDir(), envfile); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintln(log, "Deploying repository")
+	repo, err := os.Open(filepath.Join(tempdir, "repo.tar"))
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	err = br.DistributeRepo(br.ctx, apps, repo, true, container.DeployOptions{}, log)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(log, "Restoring data")
+	for _, c := range containers {
+		var snapshot string
+		if c.Category().IsFramework() {
+			snapshot = filepath.Join(tempdir, "data", "app", "data.tar")
+		} else if c.Category().IsService() {
+			snapshot = filepath.Join(tempdir, "data", "services", c.ServiceName()+".tar")
+		} else {
+			continue
+		}
+		err = restoreSnapshot(br.ctx, c, snapshot)
+		if os.IsNotExist(err) {
+			continue // nothing backed up for the container
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to restore data of %s: %v", c.FQDN(), err)
+		}
+	}
+	return nil
+}
+
+func (br *UserBroker) findBackup(name, id string) (*backup.Backup, error) {
+	backups, err := br.Backups.List(br.Namespace(), name)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backups {
+		if id == "" || b.ID == id {
+			return b, nil
+		}
+	}
+	return nil, backup.NotFoundError{Name: name, ID: id}
+}
+
+func (br *UserBroker) extractBackup(name, id, dir string) error {
+	r, err := br.Backups.Open(br.Namespace(), name, id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	return archive.ExtractFiles(dir, zr)
+}
+
+func (br *UserBroker) restoreContainerDir(c *container.Container, dir, filename string) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.CopyToContainer(br.ctx, c.ID, dir, file, types.CopyToContainerOptions{})
+}
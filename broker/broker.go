@@ -5,13 +5,33 @@ import (
 
 	"github.com/cloudway/platform/auth"
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/backup"
+	"github.com/cloudway/platform/buildlog"
 	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/cron"
+	"github.com/cloudway/platform/history"
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/logstore"
+	"github.com/cloudway/platform/mailer"
+	"github.com/cloudway/platform/pkg/certs"
+	"github.com/cloudway/platform/scanner"
 	"github.com/cloudway/platform/scm"
+	"github.com/cloudway/platform/scm/remote"
+	"github.com/cloudway/platform/webhook"
 	"golang.org/x/net/context"
 
 	// Load all plugings
+	_ "github.com/cloudway/platform/auth/userdb/bolt"
+	_ "github.com/cloudway/platform/auth/userdb/ldap"
+	_ "github.com/cloudway/platform/auth/userdb/memory"
 	_ "github.com/cloudway/platform/auth/userdb/mongodb"
+	_ "github.com/cloudway/platform/auth/userdb/oauth2"
+	_ "github.com/cloudway/platform/auth/userdb/postgres"
+	_ "github.com/cloudway/platform/backup/s3"
+	_ "github.com/cloudway/platform/hub/registry"
+	_ "github.com/cloudway/platform/hub/s3"
+	_ "github.com/cloudway/platform/logstore/elastic"
+	_ "github.com/cloudway/platform/scanner/trivy"
 	_ "github.com/cloudway/platform/scm/bitbucket"
 	_ "github.com/cloudway/platform/scm/mock"
 )
@@ -19,10 +39,25 @@ import (
 // Broker maintains all external services.
 type Broker struct {
 	container.DockerClient
-	Users *userdb.UserDatabase
-	Authz *auth.Authenticator
-	SCM   scm.SCM
-	Hub   *hub.PluginHub
+	Users   *userdb.UserDatabase
+	Authz   *auth.Authenticator
+	SCM     scm.SCM
+	Hub     *hub.PluginHub
+	History *history.Store
+	Hooks   *webhook.Dispatcher
+	Backups backup.Storage
+	Cron    *cron.Store
+	Builds  *buildlog.Store
+	ACME    *certs.Issuer
+	Mail    mailer.Mailer
+
+	// LogStore keeps aggregated logs of applications, nil if log
+	// aggregation is not enabled.
+	LogStore logstore.Store
+
+	// Scanner scans plugin images for vulnerabilities, nil if image
+	// scanning is not enabled.
+	Scanner scanner.Scanner
 }
 
 // UserBroker performs user specific operations.
@@ -50,15 +85,83 @@ func New(cli container.DockerClient) (broker *Broker, err error) {
 	if err != nil {
 		return
 	}
+	broker.SCM = remote.Wrap(broker.SCM, broker.getRemoteRepository)
 
 	broker.Hub, err = hub.New()
 	if err != nil {
 		return
 	}
 
+	broker.Hooks, err = webhook.New()
+	if err != nil {
+		return
+	}
+
+	broker.Backups, err = backup.NewStorage()
+	if err != nil {
+		return
+	}
+
+	broker.History, err = history.New(broker.Backups)
+	if err != nil {
+		return
+	}
+
+	broker.Cron, err = cron.New()
+	if err != nil {
+		return
+	}
+
+	broker.Builds, err = buildlog.New()
+	if err != nil {
+		return
+	}
+
+	broker.ACME, err = newIssuer(broker.Users)
+	if err != nil {
+		return
+	}
+
+	broker.Mail, err = mailer.New()
+	if err != nil {
+		return
+	}
+
+	broker.LogStore, err = logstore.New()
+	if err != nil {
+		return
+	}
+
+	broker.Scanner, err = scanner.New()
+	if err != nil {
+		return
+	}
+
+	container.SetSecretProvider(broker.provideSecrets)
 	return broker, nil
 }
 
+// Close releases connections to external services.
+func (br *Broker) Close() error {
+	return br.Users.Close()
+}
+
+// getRemoteRepository returns the external repository bound to the
+// application, if any.
+func (br *Broker) getRemoteRepository(namespace, name string) (*userdb.RemoteRepository, error) {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	if app := user.Basic().Applications[name]; app != nil {
+		return app.Repository, nil
+	}
+	return nil, nil
+}
+
 func (br *Broker) NewUserBroker(user userdb.User, ctx context.Context) *UserBroker {
 	return &UserBroker{
 		Broker: br,
@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The default time to wait for the build lock of an application.
+const defaultBuildLockTimeout = 10 * time.Minute
+
+// BuildLock describes the holder of the build lock of an application.
+type BuildLock struct {
+	// The operation holding the lock, "deploy", "upload" or "rollback".
+	Operation string
+
+	// The user started the operation.
+	User string
+
+	// The time when the lock was acquired.
+	Acquired time.Time
+
+	// The number of operations waiting for the lock.
+	Waiting int
+}
+
+// buildLock serializes builds and deployments of an application. Waiters
+// are queued on the semaphore channel.
+type buildLock struct {
+	sem     chan struct{}
+	holder  *BuildLock
+	waiting int
+}
+
+var buildLocks = struct {
+	sync.Mutex
+	m map[string]*buildLock
+}{m: make(map[string]*buildLock)}
+
+// buildLockTimeout returns the maximum time to wait for the build lock,
+// configured by deploy.lock_timeout. Operations fail immediately if the
+// lock is held and the timeout is not positive.
+func buildLockTimeout() time.Duration {
+	d, err := time.ParseDuration(config.GetOrDefault("deploy.lock_timeout", defaultBuildLockTimeout.String()))
+	if err != nil {
+		logrus.Warnf("Invalid deploy.lock_timeout configuration: %s", config.Get("deploy.lock_timeout"))
+		d = defaultBuildLockTimeout
+	}
+	return d
+}
+
+// lockBuild acquires the build lock of the application, so concurrent
+// deployments of the same application never race on building and
+// distributing the repository. Waits in queue until the lock is released
+// by the previous holder, the timeout expired or the context is cancelled.
+// The returned function releases the lock.
+func (br *UserBroker) lockBuild(ctx context.Context, name, operation string, log *serverlog.ServerLog) (func(), error) {
+	key := br.Namespace() + "/" + name
+
+	buildLocks.Lock()
+	l := buildLocks.m[key]
+	if l == nil {
+		l = &buildLock{sem: make(chan struct{}, 1)}
+		buildLocks.m[key] = l
+	}
+	holder := l.holder
+	l.waiting++
+	buildLocks.Unlock()
+
+	acquired := false
+	select {
+	case l.sem <- struct{}{}:
+		acquired = true
+	default:
+	}
+
+	if !acquired {
+		timeout := buildLockTimeout()
+		if holder != nil {
+			fmt.Fprintf(log, "Waiting for the %s started by %s at %s\n",
+				holder.Operation, holder.User, holder.Acquired.Format(time.RFC3339))
+		}
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			select {
+			case l.sem <- struct{}{}:
+				acquired = true
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+		}
+	}
+
+	buildLocks.Lock()
+	defer buildLocks.Unlock()
+	l.waiting--
+	if !acquired {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, BuildLockedError{Name: name, Operation: lockOperation(l.holder)}
+	}
+
+	l.holder = &BuildLock{
+		Operation: operation,
+		User:      br.User.Basic().Name,
+		Acquired:  time.Now(),
+	}
+	return func() {
+		buildLocks.Lock()
+		l.holder = nil
+		if l.waiting == 0 {
+			delete(buildLocks.m, key)
+		}
+		buildLocks.Unlock()
+		<-l.sem
+	}, nil
+}
+
+func lockOperation(holder *BuildLock) string {
+	if holder == nil {
+		return "deployment"
+	}
+	return holder.Operation
+}
+
+// GetBuildLock returns the holder of the build lock of the application, or
+// nil if the lock is not held.
+func (br *UserBroker) GetBuildLock(name string) (*BuildLock, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	buildLocks.Lock()
+	defer buildLocks.Unlock()
+	l := buildLocks.m[br.Namespace()+"/"+name]
+	if l == nil || l.holder == nil {
+		return nil, nil
+	}
+	lock := *l.holder
+	lock.Waiting = l.waiting
+	return &lock, nil
+}
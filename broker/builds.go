@@ -0,0 +1,239 @@
+package broker
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/buildlog"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Keep the deployment output in a build log. Returns the server log that
+// writes to both the given log and the build log, and the function to
+// close the build log with the deployment result.
+func (br *UserBroker) recordBuild(name string, opts *container.DeployOptions, log *serverlog.ServerLog) (*serverlog.ServerLog, func(error)) {
+	if br.User.Basic().Applications[name] == nil {
+		return log, func(error) {}
+	}
+
+	blog, err := br.Builds.Create(br.Namespace(), name, opts.Strategy.String())
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to create build log for %s", name)
+		return log, func(error) {}
+	}
+	return teeBuildLog(blog, opts, log)
+}
+
+// teeBuildLog writes the deployment output to the build log, and reports
+// the deployment stages and events of containers as the build status.
+func teeBuildLog(blog *buildlog.Log, opts *container.DeployOptions, log *serverlog.ServerLog) (*serverlog.ServerLog, func(error)) {
+	if blog == nil {
+		return log, func(error) {}
+	}
+
+	opts.Stage = blog.SetStatus
+	if log == nil {
+		log = serverlog.Discard
+	}
+	tee := serverlog.Redirect(log, io.MultiWriter(blog, log.Stdout()), io.MultiWriter(blog, log.Stderr()))
+	tee = serverlog.Observe(tee, func(p *serverlog.Progress) {
+		if p.Container != "" && p.Event != "" {
+			blog.SetContainerStatus(p.Container, p.Event, p.Bytes, p.Error)
+		}
+	})
+	return tee, func(err error) {
+		if er := blog.Close(err); er != nil {
+			logrus.WithError(er).Warnf("Failed to save build log %s", blog.ID())
+		}
+	}
+}
+
+// Get recent builds of the application, the most recent first.
+func (br *UserBroker) GetBuilds(name string) ([]*buildlog.Build, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return br.Builds.Builds(br.Namespace(), name)
+}
+
+// GetDeployment returns the build of a deployment by ID, and the name of
+// the deployed application.
+func (br *UserBroker) GetDeployment(id string) (string, *buildlog.Build, error) {
+	if err := br.Refresh(); err != nil {
+		return "", nil, err
+	}
+	for name := range br.User.Basic().Applications {
+		build, err := br.Builds.Find(br.Namespace(), name, id)
+		if err == nil {
+			return name, build, nil
+		}
+		if _, ok := err.(buildlog.NotFoundError); !ok {
+			return "", nil, err
+		}
+	}
+	return "", nil, buildlog.NotFoundError(id)
+}
+
+// Open the build log by ID.
+func (br *UserBroker) OpenBuildLog(id string) (io.ReadCloser, error) {
+	return br.Builds.Open(br.Namespace(), id)
+}
+
+// deployment is an in-progress deployment that can be cancelled.
+type deployment struct {
+	cancel context.CancelFunc
+}
+
+// deployments keeps in-progress deployments of applications, and tracks
+// deployments to be drained when the server is shutting down.
+var deployments = struct {
+	sync.Mutex
+	m        map[string][]*deployment
+	jobs     sync.WaitGroup
+	draining bool
+}{m: make(map[string][]*deployment)}
+
+// The time to wait for cancelled deployments to clean up on shutdown.
+const cancelDrainTimeout = 30 * time.Second
+
+// addDeploymentJob registers a deployment to be drained on shutdown. The
+// job must be released by deployments.jobs.Done(). Returns an error if
+// the server is shutting down.
+func addDeploymentJob() error {
+	deployments.Lock()
+	defer deployments.Unlock()
+	if deployments.draining {
+		return ShuttingDownError{}
+	}
+	deployments.jobs.Add(1)
+	return nil
+}
+
+// startDeployment returns a context that is cancelled when the deployment
+// is cancelled by CancelDeployment or the request is done. The returned
+// function must be called when the deployment finished. New deployments
+// are rejected when the server is shutting down.
+func (br *UserBroker) startDeployment(name string) (context.Context, func(), error) {
+	if err := addDeploymentJob(); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(br.ctx)
+	key := br.Namespace() + "/" + name
+	d := &deployment{cancel}
+
+	deployments.Lock()
+	deployments.m[key] = append(deployments.m[key], d)
+	deployments.Unlock()
+
+	return ctx, func() {
+		cancel()
+
+		deployments.Lock()
+		defer deployments.Unlock()
+		ds := deployments.m[key]
+		for i := range ds {
+			if ds[i] == d {
+				ds = append(ds[:i], ds[i+1:]...)
+				break
+			}
+		}
+		if len(ds) == 0 {
+			delete(deployments.m, key)
+		} else {
+			deployments.m[key] = ds
+		}
+		deployments.jobs.Done()
+	}, nil
+}
+
+// DrainDeployments stops accepting new deployments and waits for in-progress
+// deployments to finish within the timeout. Deployments still running after
+// the timeout are cancelled. Queued deployments are not started and are
+// recorded as failed in the build log. Returns false if deployments are not
+// finished after cancelled.
+func (br *Broker) DrainDeployments(timeout time.Duration) bool {
+	deployments.Lock()
+	deployments.draining = true
+	deployments.Unlock()
+
+	if waitDeploymentJobs(timeout) {
+		return true
+	}
+
+	logrus.Warn("Cancelling in-progress deployments")
+	deployments.Lock()
+	for _, ds := range deployments.m {
+		for _, d := range ds {
+			d.cancel()
+		}
+	}
+	deployments.Unlock()
+	return waitDeploymentJobs(cancelDrainTimeout)
+}
+
+func waitDeploymentJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		deployments.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// ClearBuildCache removes build caches of the application, so the next
+// build starts from scratch. Caches cannot be cleared while a deployment
+// of the application is in progress.
+func (br *UserBroker) ClearBuildCache(name string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	deployments.Lock()
+	busy := len(deployments.m[br.Namespace()+"/"+name]) != 0
+	deployments.Unlock()
+	if busy {
+		return DeploymentInProgressError(name)
+	}
+	return br.RemoveBuildCache(br.ctx, name, br.Namespace())
+}
+
+// CancelDeployment aborts in-progress deployments of the application.
+// The builder container is killed immediately and removed.
+func (br *UserBroker) CancelDeployment(name string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return ApplicationNotFoundError(name)
+	}
+
+	deployments.Lock()
+	ds := deployments.m[br.Namespace()+"/"+name]
+	for _, d := range ds {
+		d.cancel()
+	}
+	deployments.Unlock()
+
+	if len(ds) == 0 {
+		return NoDeploymentError(name)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The operations can be applied to multiple applications.
+const (
+	BulkStart    = "start"
+	BulkStop     = "stop"
+	BulkRestart  = "restart"
+	BulkRedeploy = "redeploy"
+)
+
+// The default and maximum number of applications operated concurrently.
+const (
+	DefaultBulkConcurrency = 4
+	MaxBulkConcurrency     = 16
+)
+
+// Selector selects application containers for bulk operations. An empty
+// field matches everything.
+type Selector struct {
+	// The namespace of applications.
+	Namespace string
+
+	// The category of containers, only containers in the category are
+	// operated.
+	Category manifest.Category
+
+	// The container labels to match.
+	Labels map[string]string
+}
+
+// Match returns true if the container matches the selector.
+func (sel *Selector) Match(c *container.Container) bool {
+	if sel.Namespace != "" && sel.Namespace != c.Namespace {
+		return false
+	}
+	if sel.Category != "" && sel.Category != c.Category() {
+		return false
+	}
+	for k, v := range sel.Labels {
+		if actual, ok := c.Config.Labels[k]; !ok || (v != "" && v != actual) {
+			return false
+		}
+	}
+	return true
+}
+
+type InvalidBulkOperationError string
+
+func (e InvalidBulkOperationError) Error() string {
+	return fmt.Sprintf("Invalid bulk operation: %s", string(e))
+}
+
+func (e InvalidBulkOperationError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type bulkApp struct {
+	name, namespace string
+	containers      []*container.Container
+}
+
+// BulkOperation applies an operation to all applications matching the
+// selector. At most concurrency applications are operated at the same
+// time, limited to MaxBulkConcurrency. The operation continues past
+// individual failures, and the results of all operated applications are
+// returned.
+func (br *Broker) BulkOperation(ctx context.Context, sel Selector, op string, concurrency int, log *serverlog.ServerLog) ([]*types.BulkResult, error) {
+	// the log is written by all workers
+	log = serverlog.Synchronized(log)
+
+	fn, err := br.bulkFunc(ctx, sel, op, log)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, err := br.selectApplications(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+	if concurrency > MaxBulkConcurrency {
+		concurrency = MaxBulkConcurrency
+	}
+
+	var (
+		results = make([]*types.BulkResult, len(apps))
+		sem     = make(chan struct{}, concurrency)
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(len(apps))
+	for i, app := range apps {
+		sem <- struct{}{}
+		go func(i int, app *bulkApp) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res := &types.BulkResult{Name: app.name, Namespace: app.namespace}
+			if err := fn(app); err != nil {
+				res.Error = strings.TrimSpace(err.Error())
+			}
+			results[i] = res
+		}(i, app)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (br *Broker) bulkFunc(ctx context.Context, sel Selector, op string, log *serverlog.ServerLog) (func(*bulkApp) error, error) {
+	// the application state is changed only if all containers are selected
+	whole := sel.Category == "" && len(sel.Labels) == 0
+	withState := func(app *bulkApp, state userdb.AppState, err error) error {
+		if whole {
+			if serr := br.setAppState(app.namespace, app.name, state); err == nil {
+				err = serr
+			}
+		}
+		return err
+	}
+
+	switch op {
+	case BulkStart:
+		return func(app *bulkApp) error {
+			return withState(app, userdb.AppRunning, startContainers(app.containers, func(c *container.Container) error {
+				return c.Start(ctx, log)
+			}))
+		}, nil
+
+	case BulkRestart:
+		return func(app *bulkApp) error {
+			return withState(app, userdb.AppRunning, restartContainers(app.containers, func(c *container.Container) error {
+				return c.Restart(ctx, log)
+			}))
+		}, nil
+
+	case BulkStop:
+		return func(app *bulkApp) error {
+			return withState(app, userdb.AppStopped, stopContainers(app.containers, func(c *container.Container) error {
+				return c.Stop(ctx)
+			}))
+		}, nil
+
+	case BulkRedeploy:
+		return func(app *bulkApp) error {
+			return br.SCM.Deploy(ctx, app.namespace, app.name, "", container.DeployOptions{}, log)
+		}, nil
+
+	default:
+		return nil, InvalidBulkOperationError(op)
+	}
+}
+
+// selectApplications groups selected containers by applications, the
+// result is sorted by namespace and application name.
+func (br *Broker) selectApplications(ctx context.Context, sel Selector) ([]*bulkApp, error) {
+	cs, err := br.FindInNamespace(ctx, sel.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		apps  []*bulkApp
+		index = make(map[string]*bulkApp)
+	)
+	for _, c := range cs {
+		if !sel.Match(c) {
+			continue
+		}
+		key := c.Name + "-" + c.Namespace
+		app := index[key]
+		if app == nil {
+			app = &bulkApp{name: c.Name, namespace: c.Namespace}
+			index[key] = app
+			apps = append(apps, app)
+		}
+		app.containers = append(app.containers, c)
+	}
+
+	sort.Sort(byAppName(apps))
+	return apps, nil
+}
+
+type byAppName []*bulkApp
+
+func (a byAppName) Len() int      { return len(a) }
+func (a byAppName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool {
+	if a[i].namespace != a[j].namespace {
+		return a[i].namespace < a[j].namespace
+	}
+	return a[i].name < a[j].name
+}
+
+// BulkOperation applies an operation to all user applications matching the
+// selector. The selector is restricted to the user's namespace.
+func (br *UserBroker) BulkOperation(sel Selector, op string, concurrency int, log *serverlog.ServerLog) ([]*types.BulkResult, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	namespace := br.Namespace()
+	if namespace == "" {
+		return nil, NoNamespaceError(br.User.Basic().Name)
+	}
+	if sel.Namespace != "" && sel.Namespace != namespace {
+		return nil, NamespaceForbiddenError(sel.Namespace)
+	}
+
+	sel.Namespace = namespace
+	return br.Broker.BulkOperation(br.ctx, sel, op, concurrency, log)
+}
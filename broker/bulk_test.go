@@ -0,0 +1,119 @@
+package broker_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Bulk", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var apps = []string{"bulk1", "bulk2", "bulk3"}
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub := broker.NewUserBroker(&user, context.Background())
+		for _, name := range apps {
+			opts := container.CreateOptions{Name: name}
+			_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	AfterEach(func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		for _, name := range apps {
+			ub.RemoveApplication(name)
+		}
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	var names = func(results []*types.BulkResult) []string {
+		var names []string
+		for _, res := range results {
+			names = append(names, res.Name)
+		}
+		return names
+	}
+
+	It("should apply operation to all matching applications", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		results, err := ub.BulkOperation(br.Selector{}, br.BulkRestart, 2, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(results)).To(Equal(apps))
+		for _, res := range results {
+			Expect(res.Namespace).To(Equal(NAMESPACE))
+			Expect(res.Error).To(BeEmpty())
+		}
+
+		for _, name := range apps {
+			cs, err := broker.FindAll(context.Background(), name, NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cs).To(HaveLen(2))
+			for _, c := range cs {
+				Expect(c.ActiveState(context.Background())).To(Equal(manifest.StateRunning))
+			}
+		}
+
+		results, err = ub.BulkOperation(br.Selector{}, br.BulkStop, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(results)).To(Equal(apps))
+	})
+
+	It("should only operate containers in the selected category", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		sel := br.Selector{Category: manifest.Framework}
+		results, err := ub.BulkOperation(sel, br.BulkStart, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(results)).To(Equal(apps))
+
+		for _, name := range apps {
+			cs, err := broker.FindAll(context.Background(), name, NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			for _, c := range cs {
+				if c.Category().IsFramework() {
+					Expect(c.ActiveState(context.Background())).To(Equal(manifest.StateRunning))
+				} else {
+					Expect(c.ActiveState(context.Background())).NotTo(Equal(manifest.StateRunning))
+				}
+			}
+		}
+	})
+
+	It("should continue past failures and report them", func() {
+		Expect(os.RemoveAll(filepath.Join(REPOROOT, NAMESPACE, "bulk2"))).To(Succeed())
+
+		ub := broker.NewUserBroker(&user, context.Background())
+		results, err := ub.BulkOperation(br.Selector{}, br.BulkRedeploy, 0, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(results)).To(Equal(apps))
+		Expect(results[0].Error).To(BeEmpty())
+		Expect(results[1].Error).NotTo(BeEmpty())
+		Expect(results[2].Error).To(BeEmpty())
+	})
+
+	It("should reject invalid operation", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		_, err := ub.BulkOperation(br.Selector{}, "unknown", 0, nil)
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidBulkOperationError("")))
+	})
+
+	It("should not operate applications in other namespace", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		_, err := ub.BulkOperation(br.Selector{Namespace: "other"}, br.BulkRestart, 0, nil)
+		Expect(err).To(BeAssignableToTypeOf(br.NamespaceForbiddenError("")))
+	})
+})
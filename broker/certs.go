@@ -0,0 +1,318 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/pkg/certs"
+	"github.com/cloudway/platform/pkg/domain"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/proxy"
+)
+
+// The time limit to issue a certificate from ACME server.
+const issueTimeout = 5 * time.Minute
+
+// The interval to check certificates needing renewal.
+const renewInterval = 12 * time.Hour
+
+// newIssuer creates the ACME certificate issuer. ACME is enabled by the
+// acme.backend setting, which is the API server URL, including context
+// root, reachable from the proxy. HTTP-01 challenges of custom domains
+// are routed to the backend.
+func newIssuer(users *userdb.UserDatabase) (*certs.Issuer, error) {
+	if config.Get("acme.backend") == "" {
+		return nil, nil
+	}
+
+	var genErr error
+	keyPEM, err := users.GetSecret("acme", func() []byte {
+		key, err := certs.GenerateKey()
+		genErr = err
+		return key
+	})
+	if genErr != nil {
+		return nil, genErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := certs.ParseKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	directory := config.GetOrDefault("acme.directory", certs.LetsEncryptURL)
+	return certs.NewIssuer(directory, config.Get("acme.email"), key), nil
+}
+
+// GetCertificates returns TLS certificates of custom domains attached to
+// the application.
+func (br *UserBroker) GetCertificates(name string) ([]*userdb.Certificate, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	return app.Certificates, nil
+}
+
+// UploadCertificate installs a custom certificate for the domain attached
+// to the application. The certificate replaces the certificate issued by
+// ACME, and is not renewed automatically.
+func (br *UserBroker) UploadCertificate(name, host string, cert, key []byte) (*userdb.Certificate, error) {
+	host = normalizeHost(host)
+
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	if !hasHost(app, host) {
+		return nil, DomainNotFoundError(host)
+	}
+
+	leaf, err := certs.Parse(host, cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &userdb.Certificate{
+		Domain:      host,
+		Certificate: string(cert),
+		PrivateKey:  string(key),
+		NotAfter:    leaf.NotAfter,
+	}
+	return c, br.saveCertificate(br.Namespace(), name, c)
+}
+
+// RemoveCertificate removes the certificate of the domain. A new
+// certificate is issued if ACME is enabled.
+func (br *UserBroker) RemoveCertificate(name, host string) error {
+	host = normalizeHost(host)
+
+	app, err := br.getApplication(name)
+	if err != nil {
+		return err
+	}
+	if !hasHost(app, host) {
+		return DomainNotFoundError(host)
+	}
+
+	if err = br.deleteCertificate(br.Namespace(), name, host); err != nil {
+		return err
+	}
+	br.issueCertificateAsync(br.Namespace(), name, host)
+	return nil
+}
+
+func normalizeHost(host string) string {
+	if h, err := domain.Normalize(host, defaults.Domain()); err == nil {
+		return h
+	}
+	return host
+}
+
+func hasHost(app *userdb.Application, host string) bool {
+	for _, h := range app.Hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+func findCertificate(app *userdb.Application, host string) *userdb.Certificate {
+	for _, c := range app.Certificates {
+		if c.Domain == host {
+			return c
+		}
+	}
+	return nil
+}
+
+// saveCertificate stores the certificate in the database and installs
+// it in the proxy.
+func (br *Broker) saveCertificate(namespace, name string, cert *userdb.Certificate) error {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	err = br.modifyApplication(user, name, func(app *userdb.Application) error {
+		if old := findCertificate(app, cert.Domain); old != nil {
+			*old = *cert
+		} else {
+			app.Certificates = append(app.Certificates, cert)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return withCertificateStore(func(store proxy.CertificateStore) error {
+		return store.SetCertificate(cert.Domain, []byte(cert.Certificate), []byte(cert.PrivateKey))
+	})
+}
+
+// deleteCertificate removes the certificate from the database and proxy.
+func (br *Broker) deleteCertificate(namespace, name, host string) error {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	err = br.modifyApplication(user, name, func(app *userdb.Application) error {
+		for i, c := range app.Certificates {
+			if c.Domain == host {
+				app.Certificates = append(app.Certificates[:i], app.Certificates[i+1:]...)
+				return nil
+			}
+		}
+		return errUnchanged
+	})
+	if err != nil {
+		return err
+	}
+
+	return withCertificateStore(func(store proxy.CertificateStore) error {
+		return store.RemoveCertificate(host)
+	})
+}
+
+// withCertificateStore calls the function with the proxy if it stores
+// certificates. Nothing is done if the proxy is not configured.
+func withCertificateStore(fn func(proxy.CertificateStore) error) error {
+	proxyURL := config.Get("proxy.url")
+	if proxyURL == "" {
+		return nil
+	}
+
+	px, err := proxy.New(proxyURL)
+	if err != nil {
+		return err
+	}
+	defer px.Close()
+
+	if store, ok := px.(proxy.CertificateStore); ok {
+		return fn(store)
+	}
+	return nil
+}
+
+// issueCertificate obtains a certificate for the domain from ACME server.
+// The HTTP-01 challenge of the domain is routed to the API server while
+// the certificate is being issued.
+func (br *Broker) issueCertificate(ctx context.Context, namespace, name, host string) error {
+	if br.ACME == nil {
+		return errors.New("ACME is not enabled")
+	}
+
+	px, err := proxy.New(config.Get("proxy.url"))
+	if err != nil {
+		return err
+	}
+	defer px.Close()
+
+	path := strings.TrimSuffix(certs.ChallengePath, "/")
+	backend := strings.TrimSuffix(config.Get("acme.backend"), "/") + path
+	id := "acme-" + host
+	err = px.AddEndpoints(id, []*manifest.Endpoint{{
+		ProxyMappings: []*manifest.ProxyMapping{{
+			Frontend: host + path,
+			Backend:  backend,
+			Protocol: "http",
+		}},
+	}})
+	if err != nil {
+		return err
+	}
+	defer px.RemoveEndpoints(id)
+
+	certPEM, keyPEM, err := br.ACME.Issue(ctx, host)
+	if err != nil {
+		return err
+	}
+	leaf, err := certs.Parse(host, certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Issued certificate for %s, expires at %s", host, leaf.NotAfter)
+	return br.saveCertificate(namespace, name, &userdb.Certificate{
+		Domain:      host,
+		Certificate: string(certPEM),
+		PrivateKey:  string(keyPEM),
+		NotAfter:    leaf.NotAfter,
+		ACME:        true,
+	})
+}
+
+// issueCertificateAsync issues a certificate for the domain in background
+// if ACME is enabled. Certificates of wildcard domains can't be issued
+// with HTTP-01 challenge and must be uploaded by user.
+func (br *Broker) issueCertificateAsync(namespace, name, host string) {
+	if br.ACME == nil || strings.HasPrefix(host, "*.") {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), issueTimeout)
+		defer cancel()
+		if err := br.issueCertificate(ctx, namespace, name, host); err != nil {
+			logrus.WithError(err).Errorf("Failed to issue certificate for %s", host)
+		}
+	}()
+}
+
+// RunCertificateRenewal periodically issues certificates for custom
+// domains without a certificate, and renews ACME certificates about
+// to expire. Returns immediately if ACME is not enabled.
+func (br *Broker) RunCertificateRenewal(stop <-chan bool) {
+	if br.ACME == nil {
+		return
+	}
+	for {
+		br.renewCertificates()
+		select {
+		case <-stop:
+			return
+		case <-time.After(renewInterval):
+		}
+	}
+}
+
+func (br *Broker) renewCertificates() {
+	var users []userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		logrus.WithError(err).Error("Failed to find users for certificate renewal")
+		return
+	}
+
+	for _, user := range users {
+		for name, app := range user.Applications {
+			for _, host := range app.Hosts {
+				if strings.HasPrefix(host, "*.") {
+					continue
+				}
+				if c := findCertificate(app, host); c != nil && (!c.ACME || !certs.NeedsRenewal(c.NotAfter)) {
+					continue
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), issueTimeout)
+				err := br.issueCertificate(ctx, user.Namespace, name, host)
+				cancel()
+				if err != nil {
+					logrus.WithError(err).Errorf("Failed to renew certificate for %s", host)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package broker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/files"
+)
+
+// secretEnvPattern matches names of environment variables likely holding
+// credentials, which are not copied to cloned applications and templates.
+var secretEnvPattern = regexp.MustCompile(`(?i)(SECRET|PASSWORD|PASSWD|TOKEN|CREDENTIAL|PRIVATE|(^|_)KEY$)`)
+
+// CloneApplication creates a new application with the same plugins, scaling
+// and environment as an existing application. Platform variables and
+// variables holding secrets are not copied. The deployed repository is
+// copied if requested, otherwise the new application is populated as
+// specified by the create options.
+func (br *UserBroker) CloneApplication(name string, opts container.CreateOptions, copyRepo bool) (*userdb.Application, []*container.Container, error) {
+	t, framework, scaling, err := br.captureApplication(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if br.User.Basic().Applications[opts.Name] != nil {
+		return nil, nil, ApplicationExistError{opts.Name, br.Namespace()}
+	}
+
+	var repo *os.File
+	if copyRepo {
+		if repo, err = br.saveRepo(framework); err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			repo.Close()
+			os.Remove(repo.Name())
+		}()
+	}
+
+	opts.Scaling = scaling
+	return br.createFromTemplate(opts, t, repo)
+}
+
+// SaveTemplate saves the application as a template in the plugin hub, so
+// that new applications can be created with the same plugins and
+// environment. The deployed repository is saved with the template if
+// requested.
+func (br *UserBroker) SaveTemplate(name, template, description string, withRepo bool) (*hub.Template, error) {
+	t, framework, _, err := br.captureApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	t.Name = template
+	t.Description = description
+	t.Created = time.Now()
+
+	var repo io.Reader
+	if withRepo {
+		r, _, err := framework.CopyFromContainer(br.ctx, framework.ID, framework.RepoDir()+"/.")
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		repo = r
+	}
+
+	if err = br.Hub.SaveTemplate(br.Namespace(), t, repo); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetTemplates returns templates saved in the user's namespace.
+func (br *UserBroker) GetTemplates() ([]*hub.Template, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.Namespace() == "" {
+		return nil, nil
+	}
+	return br.Hub.ListTemplates(br.Namespace())
+}
+
+// RemoveTemplate removes the template from the user's namespace.
+func (br *UserBroker) RemoveTemplate(template string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+	if br.Namespace() == "" {
+		return hub.TemplateNotFoundError(template)
+	}
+	return br.Hub.RemoveTemplate(br.Namespace(), template)
+}
+
+// CreateFromTemplate creates a new application from the template saved in
+// the user's namespace. Templates saved in the system namespace by the
+// administrator are available to all users.
+func (br *UserBroker) CreateFromTemplate(opts container.CreateOptions, template string) (*userdb.Application, []*container.Container, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, nil, err
+	}
+
+	namespace := br.Namespace()
+	t, err := br.Hub.GetTemplate(namespace, template)
+	if _, ok := err.(hub.TemplateNotFoundError); ok && namespace != "" {
+		namespace = ""
+		t, err = br.Hub.GetTemplate(namespace, template)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var repo *os.File
+	if t.Repo {
+		if repo, err = br.Hub.OpenTemplateRepo(namespace, template); err != nil {
+			return nil, nil, err
+		}
+		defer repo.Close()
+	}
+
+	opts.Scaling = 1
+	return br.createFromTemplate(opts, t, repo)
+}
+
+// captureApplication returns the plugins, environment and scaling of the
+// application, and the framework container holding the deployed repository.
+func (br *UserBroker) captureApplication(name string) (*hub.Template, *container.Container, int, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	containers, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	container.ResolveServiceDependencies(containers)
+
+	t := &hub.Template{TimeZone: app.TimeZone, Locale: app.Locale}
+	var framework *container.Container
+	var scaling int
+	for _, c := range containers {
+		switch {
+		case c.Category().IsFramework():
+			if framework == nil {
+				framework = c
+				t.Plugins = append(t.Plugins, c.PluginTag())
+			}
+			scaling++
+		case c.Category().IsService():
+			t.Plugins = append(t.Plugins, c.ServiceName()+"="+c.PluginTag())
+		}
+	}
+	if framework == nil {
+		return nil, nil, 0, container.NoFrameworkError(name)
+	}
+
+	env, err := framework.GetenvAll(br.ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	t.Env = make(map[string]string)
+	for k, v := range env {
+		if !strings.HasPrefix(k, "CLOUDWAY_") && !secretEnvPattern.MatchString(k) && findSecret(app, k) == nil {
+			t.Env[k] = v
+		}
+	}
+
+	return t, framework, scaling, nil
+}
+
+// saveRepo saves the deployed repository of the container into a temporary
+// file, which must be removed by the caller.
+func (br *UserBroker) saveRepo(c *container.Container) (*os.File, error) {
+	r, _, err := c.CopyFromContainer(br.ctx, c.ID, c.RepoDir()+"/.")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, err := files.TempFile("", "repo", ".tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// createFromTemplate creates a new application with plugins and environment
+// of the template, and deploys the repository archive if not nil. The new
+// application is removed if failed to apply the template.
+func (br *UserBroker) createFromTemplate(opts container.CreateOptions, t *hub.Template, repo *os.File) (app *userdb.Application, containers []*container.Container, err error) {
+	if opts.TimeZone == "" {
+		opts.TimeZone = t.TimeZone
+	}
+	if opts.Locale == "" {
+		opts.Locale = t.Locale
+	}
+	if repo != nil {
+		opts.Repo = "empty"
+	}
+
+	tags := append([]string(nil), t.Plugins...)
+	app, containers, err = br.CreateApplication(opts, tags)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			br.RemoveApplication(opts.Name)
+			app, containers = nil, nil
+		}
+	}()
+
+	for _, c := range containers {
+		if c.Category().IsFramework() {
+			if err = c.SetenvAll(br.ctx, t.Env); err != nil {
+				return
+			}
+		}
+	}
+
+	if repo != nil {
+		fmt.Fprintln(opts.Log, "Deploying repository")
+		var size int64
+		if size, err = repo.Seek(0, os.SEEK_END); err != nil {
+			return
+		}
+		if _, err = repo.Seek(0, os.SEEK_SET); err != nil {
+			return
+		}
+		if err = br.SCM.Populate(br.Namespace(), opts.Name, repo, size); err != nil {
+			return
+		}
+		err = br.SCM.Deploy(br.ctx, br.Namespace(), opts.Name, "", container.DeployOptions{}, opts.Log)
+	}
+	return
+}
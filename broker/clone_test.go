@@ -0,0 +1,90 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Clone", func() {
+	var (
+		ctx  = context.Background()
+		user userdb.BasicUser
+		ub   *br.UserBroker
+	)
+
+	BeforeEach(func() {
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, ctx)
+
+		_, cs, err := ub.CreateApplication(container.CreateOptions{Name: "origin"}, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		for _, c := range cs {
+			if c.Category().IsFramework() {
+				Expect(c.Setenv(ctx, "APP_MODE", "production")).To(Succeed())
+				Expect(c.Setenv(ctx, "API_SECRET", "secret")).To(Succeed())
+			}
+		}
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("origin")
+		ub.RemoveApplication("copy")
+		ub.RemoveTemplate("origin")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	var framework = func(name string) *container.Container {
+		cs, err := broker.FindAll(ctx, name, NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(2))
+		for _, c := range cs {
+			if c.Category().IsFramework() {
+				return c
+			}
+		}
+		Fail("framework container not found")
+		return nil
+	}
+
+	It("should clone application without secrets", func() {
+		_, _, err := ub.CloneApplication("origin", container.CreateOptions{Name: "copy"}, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		c := framework("copy")
+		Expect(c.Getenv(ctx, "APP_MODE")).To(Equal("production"))
+		_, err = c.Getenv(ctx, "API_SECRET")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not clone to an existing application", func() {
+		_, _, err := ub.CloneApplication("origin", container.CreateOptions{Name: "origin"}, false)
+		Expect(err).To(Equal(br.ApplicationExistError{Name: "origin", Namespace: NAMESPACE}))
+	})
+
+	It("should create application from template", func() {
+		t, err := ub.SaveTemplate("origin", "origin", "test template", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Plugins).To(HaveLen(2))
+		Expect(t.Env).To(HaveKeyWithValue("APP_MODE", "production"))
+		Expect(t.Env).NotTo(HaveKey("API_SECRET"))
+
+		templates, err := ub.GetTemplates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templates).To(HaveLen(1))
+
+		_, _, err = ub.CreateFromTemplate(container.CreateOptions{Name: "copy"}, "origin")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(framework("copy").Getenv(ctx, "APP_MODE")).To(Equal("production"))
+
+		Expect(ub.RemoveTemplate("origin")).To(Succeed())
+		_, _, err = ub.CreateFromTemplate(container.CreateOptions{Name: "other"}, "origin")
+		Expect(err).To(Equal(hub.TemplateNotFoundError("origin")))
+	})
+})
@@ -0,0 +1,167 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/cron"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// Get recent cron job runs of the application, the most recent first.
+func (br *UserBroker) GetCronRuns(name string) ([]*cron.Run, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return br.Cron.Runs(br.Namespace(), name)
+}
+
+// scheduler executes cron jobs declared in plugin manifests. A job is run
+// in one container of the application or service, and is skipped if the
+// previous run of the job is still in progress.
+type scheduler struct {
+	*Broker
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// RunScheduler executes cron jobs of all applications on schedule until
+// the stop channel is closed.
+func (br *Broker) RunScheduler(stop <-chan bool) {
+	s := &scheduler{Broker: br, running: make(map[string]bool)}
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-stop:
+			return
+		case <-time.After(next.Sub(now)):
+			s.runJobs(next)
+		}
+	}
+}
+
+func (s *scheduler) runJobs(t time.Time) {
+	ctx := context.Background()
+	containers, err := s.FindInNamespace(ctx, "")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to find containers for cron jobs")
+		return
+	}
+
+	// select one running container for each application and service
+	targets := make(map[string]*container.Container)
+	for _, c := range containers {
+		if c.State == nil || !c.State.Running {
+			continue
+		}
+		key := c.Namespace + "/" + c.Name + "/" + c.ServiceName()
+		if other := targets[key]; other == nil || c.ID < other.ID {
+			targets[key] = c
+		}
+	}
+
+	for _, c := range targets {
+		meta, err := s.Hub.GetPluginInfo(c.PluginTag())
+		if err != nil {
+			continue
+		}
+		for _, job := range meta.Cron {
+			sched, err := cron.Parse(job.Schedule)
+			if err != nil {
+				logrus.WithError(err).Warnf("Invalid cron job %s in plugin %s", job.Name, meta.Tag)
+				continue
+			}
+			if sched.Matches(t) {
+				go s.runJob(c, job)
+			}
+		}
+	}
+}
+
+func (s *scheduler) runJob(c *container.Container, job *manifest.CronJob) {
+	key := c.Namespace + "/" + c.Name + "/" + c.ServiceName() + "/" + job.Name
+	if !s.acquire(key) {
+		logrus.Warnf("Cron job %s of %s is still running, skipped", job.Name, c.FQDN())
+		return
+	}
+	defer s.release(key)
+
+	run := &cron.Run{
+		ID:        newRunID(),
+		Job:       job.Name,
+		Service:   c.ServiceName(),
+		Container: c.ID[:12],
+		Schedule:  job.Schedule,
+		Command:   job.Command,
+		Started:   time.Now(),
+	}
+
+	// run the command with application environment
+	out := &limitedBuffer{max: cron.MaxOutput}
+	err := c.Exec(context.Background(), "", nil, out, out, "/usr/bin/cwctl", "sh", "sh", "-c", job.Command)
+	run.Finished = time.Now()
+	run.Output = out.String()
+	if se, ok := err.(container.StatusError); ok {
+		run.ExitCode = se.Code
+	} else if err != nil {
+		run.ExitCode = -1
+		run.Error = err.Error()
+	}
+
+	if err := s.Cron.Record(c.Namespace, c.Name, run); err != nil {
+		logrus.WithError(err).Warn("Failed to record cron job run")
+	}
+}
+
+func (s *scheduler) acquire(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[key] {
+		return false
+	}
+	s.running[key] = true
+	return true
+}
+
+func (s *scheduler) release(key string) {
+	s.mu.Lock()
+	delete(s.running, key)
+	s.mu.Unlock()
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// limitedBuffer keeps at most max bytes of output and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+	mu  sync.Mutex
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.Len(); room < len(p) {
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+	} else {
+		b.Buffer.Write(p)
+	}
+	return len(p), nil
+}
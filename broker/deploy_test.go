@@ -6,10 +6,14 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/docker/engine-api/types"
+	dcontainer "github.com/docker/engine-api/types/container"
+	"github.com/docker/engine-api/types/filters"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/scm"
@@ -97,6 +101,29 @@ var _ = Describe("Deploy", func() {
 		return string(content), err
 	}
 
+	var fetchVolumeFile = func(volume, filename string) (string, error) {
+		ctx := context.Background()
+		resp, err := broker.ContainerCreate(ctx,
+			&dcontainer.Config{Image: "debian:jessie"},
+			&dcontainer.HostConfig{Binds: []string{volume + ":/volume:ro"}},
+			nil, "")
+		if err != nil {
+			return "", err
+		}
+		defer broker.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+
+		r, _, err := broker.CopyFromContainer(ctx, resp.ID, "/volume/"+filename)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		if err := archive.ExtractFiles(checkdir, r); err != nil {
+			return "", err
+		}
+		content, err := ioutil.ReadFile(filepath.Join(checkdir, filename))
+		return string(content), err
+	}
+
 	var fetchCommittedFile = func() (string, error) {
 		return fetchFile(app.RepoDir(), "track")
 	}
@@ -131,7 +158,7 @@ var _ = Describe("Deploy", func() {
 		}
 
 		var assertDeployment = func(branch, actual string) {
-			ExpectWithOffset(1, broker.SCM.Deploy(NAMESPACE, "test", branch, nil)).To(Succeed())
+			ExpectWithOffset(1, broker.SCM.Deploy(context.Background(), NAMESPACE, "test", branch, container.DeployOptions{}, nil)).To(Succeed())
 
 			ref, err := broker.SCM.GetDeploymentBranch(NAMESPACE, "test")
 			ExpectWithOffset(1, err).NotTo(HaveOccurred())
@@ -172,8 +199,29 @@ var _ = Describe("Deploy", func() {
 			assertDeployment("v1.0", "v1.0")
 			assertDeployment("v1.1", "v1.1")
 
+			By("Deploy to a commit")
+			out, err := repo.Output("rev-parse", "develop")
+			Expect(err).NotTo(HaveOccurred())
+			sha := strings.TrimSpace(out)
+			Expect(broker.SCM.Deploy(context.Background(), NAMESPACE, "test", sha, container.DeployOptions{}, nil)).To(Succeed())
+			ref, err := broker.SCM.GetDeploymentBranch(NAMESPACE, "test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ref).To(Equal(scm.Branch{Id: sha, DisplayId: sha[:7], Type: "COMMIT"}))
+			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("develop"))
+
 			By("Deploy to a non-existing branch will reset to default branch")
-			assertDeployment("no-such-branch", "master")
+			assertDeployment("hotfix", "hotfix")
+			Expect(repo.Run("push", "origin", ":refs/heads/hotfix")).To(Succeed())
+			assertDeployment("", "master")
+		})
+
+		It("should fail to deploy a non-existing branch", func() {
+			err := broker.SCM.Deploy(context.Background(), NAMESPACE, "test", "no-such-branch", container.DeployOptions{}, nil)
+			Expect(err).To(Equal(scm.RefNotFoundError("no-such-branch")))
+
+			ref, err := broker.SCM.GetDeploymentBranch(NAMESPACE, "test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ref.DisplayId).To(Equal("master"))
 		})
 	})
 
@@ -228,7 +276,7 @@ var _ = Describe("Deploy", func() {
 			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("master"))
 
 			By("Switch deployment branch to develop")
-			Expect(broker.SCM.Deploy(NAMESPACE, "test", "develop", nil))
+			Expect(broker.SCM.Deploy(context.Background(), NAMESPACE, "test", "develop", container.DeployOptions{}, nil))
 			Eventually(fetchCommittedFile, deployTimeout).Should(Equal("develop"))
 
 			By("Switch local repository to develop branch")
@@ -269,12 +317,55 @@ var _ = Describe("Deploy", func() {
 		})
 
 		It("should save cached data", func() {
+			// the cache volume is only mounted into builders, so read
+			// it from a container created with the volume mounted
+			volume := "test-" + NAMESPACE + "-cache-.cache"
 			Eventually(func() (string, error) {
-				content, err := fetchFile(app.Home(), ".cache/data")
+				content, err := fetchVolumeFile(volume, "data")
 				return strings.TrimSpace(content), err
 			}, deployTimeout).Should(Equal("cached"))
 		})
 
+		It("should save cached data in volume", func() {
+			ctx := context.Background()
+			volume := "test-" + NAMESPACE + "-cache-.cache"
+			Eventually(func() error {
+				_, err := broker.VolumeInspect(ctx, volume)
+				return err
+			}, deployTimeout).Should(Succeed())
+
+			Eventually(func() error {
+				return broker.NewUserBroker(&user, ctx).ClearBuildCache("test")
+			}, deployTimeout).Should(Succeed())
+			_, err := broker.VolumeInspect(ctx, volume)
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("should success push to deploy", pushToDeploy)
+
+		Context("with builder pool", func() {
+			BeforeEach(func() {
+				config.Set("build.pool.enabled", "true")
+			})
+
+			AfterEach(func() {
+				config.Remove("build.pool.enabled")
+			})
+
+			It("should keep warm builder until build caches cleared", func() {
+				ctx := context.Background()
+				builders := func() ([]types.Container, error) {
+					args := filters.NewArgs()
+					args.Add("label", container.BUILDER_KEY+"=test-"+NAMESPACE)
+					return broker.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+				}
+				Eventually(builders, deployTimeout).Should(HaveLen(1))
+
+				Eventually(func() error {
+					return broker.NewUserBroker(&user, ctx).ClearBuildCache("test")
+				}, deployTimeout).Should(Succeed())
+				Expect(builders()).To(BeEmpty())
+			})
+		})
 	})
 })
@@ -0,0 +1,104 @@
+package broker
+
+import (
+	"strconv"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/domain"
+	"github.com/cloudway/platform/proxy"
+)
+
+// GetDomains returns custom domains attached to the application.
+func (br *UserBroker) GetDomains(name string) ([]string, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	return app.Hosts, nil
+}
+
+// DomainVerification returns the DNS records verifying ownership of the
+// domain. The domain must be a CNAME of the target, or have a TXT record
+// containing the token.
+func (br *UserBroker) DomainVerification(name, host string) (target, token string, err error) {
+	_, target, token, err = br.domainVerification(name, host)
+	return
+}
+
+func (br *UserBroker) domainVerification(name, host string) (normalized, target, token string, err error) {
+	normalized, err = domain.Normalize(host, defaults.Domain())
+	if err != nil {
+		return
+	}
+	app, err := br.getApplication(name)
+	if err != nil {
+		return
+	}
+	target = name + "-" + br.Namespace() + "." + defaults.Domain()
+	token = domain.Token(app.Secret, normalized)
+	return
+}
+
+// AddDomain attaches a custom domain to the application after verifying
+// the domain ownership, which can be disabled by the domain.skip_verify
+// setting. Returns the normalized domain name.
+func (br *UserBroker) AddDomain(name, host string) (string, error) {
+	host, target, token, err := br.domainVerification(name, host)
+	if err != nil {
+		return "", err
+	}
+
+	if skip, _ := strconv.ParseBool(config.Get("domain.skip_verify")); !skip {
+		if err = domain.DefaultResolver.Verify(host, target, token); err != nil {
+			return "", err
+		}
+	}
+	if err = br.AddHost(name, host); err != nil {
+		return "", err
+	}
+	br.issueCertificateAsync(br.Namespace(), name, host)
+	return host, nil
+}
+
+// RemoveDomain detaches the custom domain from the application, and
+// removes the certificate of the domain.
+func (br *UserBroker) RemoveDomain(name, host string) error {
+	host = normalizeHost(host)
+	if err := br.RemoveHost(name, host); err != nil {
+		return err
+	}
+	return br.deleteCertificate(br.Namespace(), name, host)
+}
+
+// updateEndpoints updates the proxy routes of running containers after
+// the application hosts changed, so the change takes effect immediately.
+// Standby containers are updated by the proxy when traffic switched to
+// them. Nothing is done if the proxy is not configured.
+func (br *UserBroker) updateEndpoints(cs []*container.Container) error {
+	proxyURL := config.Get("proxy.url")
+	if proxyURL == "" {
+		return nil
+	}
+
+	px, err := proxy.New(proxyURL)
+	if err != nil {
+		return err
+	}
+	defer px.Close()
+
+	for _, c := range cs {
+		if !c.State.Running || c.IsStandby(br.ctx) {
+			continue
+		}
+		info, err := c.GetInfo(br.ctx, "endpoints")
+		if err != nil {
+			return err
+		}
+		if err = px.AddEndpoints(c.ID, info.Endpoints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package broker_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/certs"
+	"github.com/cloudway/platform/pkg/domain"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Domains", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "domains"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("CLOUDWAY_DOMAIN_SKIP_VERIFY")
+		ub.RemoveApplication("domains")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should reject invalid domain", func() {
+		_, err := ub.AddDomain("domains", "localhost")
+		Expect(err).To(Equal(domain.InvalidDomainError("localhost")))
+		_, err = ub.AddDomain("domains", "www."+NAMESPACE+"."+defaults.Domain())
+		Expect(err).To(BeAssignableToTypeOf(domain.InvalidDomainError("")))
+	})
+
+	It("should verify domain ownership", func() {
+		target, token, err := ub.DomainVerification("domains", "www.example.invalid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal("domains-" + NAMESPACE + "." + defaults.Domain()))
+
+		_, err = ub.AddDomain("domains", "www.example.invalid")
+		Expect(err).To(Equal(domain.VerificationError{Domain: "www.example.invalid", Target: target, Token: token}))
+		Expect(ub.GetDomains("domains")).To(BeEmpty())
+	})
+
+	It("should add and remove domains", func() {
+		os.Setenv("CLOUDWAY_DOMAIN_SKIP_VERIFY", "true")
+
+		host, err := ub.AddDomain("domains", "WWW.Example.com.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(host).To(Equal("www.example.com"))
+		Expect(ub.GetDomains("domains")).To(Equal([]string{"www.example.com"}))
+
+		Expect(ub.RemoveDomain("domains", "www.example.com")).To(Succeed())
+		Expect(ub.GetDomains("domains")).To(BeEmpty())
+	})
+
+	It("should upload and remove certificates", func() {
+		os.Setenv("CLOUDWAY_DOMAIN_SKIP_VERIFY", "true")
+
+		_, err := ub.AddDomain("domains", "www.example.com")
+		Expect(err).NotTo(HaveOccurred())
+
+		cert, key := selfSignedCert("www.example.com")
+		_, err = ub.UploadCertificate("domains", "www.example.org", cert, key)
+		Expect(err).To(Equal(br.DomainNotFoundError("www.example.org")))
+		_, err = ub.UploadCertificate("domains", "www.example.com", cert[:len(cert)/2], key)
+		Expect(err).To(BeAssignableToTypeOf(certs.InvalidCertificateError{}))
+
+		c, err := ub.UploadCertificate("domains", "www.example.com", cert, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.ACME).To(BeFalse())
+		Expect(ub.GetCertificates("domains")).To(HaveLen(1))
+
+		Expect(ub.RemoveCertificate("domains", "www.example.com")).To(Succeed())
+		Expect(ub.GetCertificates("domains")).To(BeEmpty())
+
+		_, err = ub.UploadCertificate("domains", "www.example.com", cert, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.RemoveDomain("domains", "www.example.com")).To(Succeed())
+		Expect(ub.GetCertificates("domains")).To(BeEmpty())
+	})
+})
+
+func selfSignedCert(host string) (certPEM, keyPEM []byte) {
+	keyPEM, err := certs.GenerateKey()
+	Expect(err).NotTo(HaveOccurred())
+	key, err := certs.ParseKey(keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM
+}
@@ -0,0 +1,167 @@
+package broker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+var validEnvKey = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+
+// GetNamespaceEnv returns environment variables shared by all applications
+// in the namespace.
+func (br *UserBroker) GetNamespaceEnv() (map[string]string, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	env := br.User.Basic().Env
+	if env == nil {
+		env = make(map[string]string)
+	}
+	return env, nil
+}
+
+// SetNamespaceEnv sets environment variables shared by all applications in
+// the namespace. Running applications inheriting the variables are updated,
+// and variables overridden by the application are kept.
+func (br *UserBroker) SetNamespaceEnv(env map[string]string) error {
+	for k := range env {
+		if err := checkNamespaceEnvKey(k); err != nil {
+			return err
+		}
+	}
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Namespace == "" {
+		return NoNamespaceError(user.Name)
+	}
+
+	old := user.Env
+	shared := make(map[string]string, len(old)+len(env))
+	for k, v := range old {
+		shared[k] = v
+	}
+	for k, v := range env {
+		shared[k] = v
+	}
+	if err := br.Users.UpdateIf(user.Name, user.Revision, userdb.Args{"env": shared}); err != nil {
+		return err
+	}
+	user.Env = shared
+	return br.propagateNamespaceEnv(old, env, nil)
+}
+
+// UnsetNamespaceEnv removes environment variables shared by applications in
+// the namespace. Variables overridden by the application are kept.
+func (br *UserBroker) UnsetNamespaceEnv(keys ...string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	old := user.Env
+	shared := make(map[string]string, len(old))
+	for k, v := range old {
+		shared[k] = v
+	}
+	var removed []string
+	for _, k := range keys {
+		if _, ok := shared[k]; ok {
+			delete(shared, k)
+			removed = append(removed, k)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := br.Users.UpdateIf(user.Name, user.Revision, userdb.Args{"env": shared}); err != nil {
+		return err
+	}
+	user.Env = shared
+	return br.propagateNamespaceEnv(old, nil, removed)
+}
+
+// propagateNamespaceEnv updates inherited variables in running framework
+// containers. A variable is inherited if it's absent from the container or
+// still has the previous value of the namespace, otherwise the variable is
+// overridden by the application and not changed. Secrets set as environment
+// variables always override shared variables.
+func (br *UserBroker) propagateNamespaceEnv(old, changed map[string]string, removed []string) error {
+	user := br.User.Basic()
+	for name, app := range user.Applications {
+		cs, err := br.FindApplications(br.ctx, name, user.Namespace)
+		if err != nil {
+			return err
+		}
+		for _, c := range cs {
+			env, err := c.GetenvAll(br.ctx)
+			if err != nil {
+				return err
+			}
+
+			set := make(map[string]string)
+			for k, v := range changed {
+				if findSecret(app, k) == nil && inherited(env, old, k) {
+					set[k] = v
+				}
+			}
+			var unset []string
+			for _, k := range removed {
+				if _, ok := env[k]; ok && findSecret(app, k) == nil && inherited(env, old, k) {
+					unset = append(unset, k)
+				}
+			}
+
+			if err = c.SetenvAll(br.ctx, set); err != nil {
+				return err
+			}
+			if err = c.Unsetenv(br.ctx, unset...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func inherited(env, shared map[string]string, key string) bool {
+	cur, ok := env[key]
+	if !ok {
+		return true
+	}
+	prev, ok := shared[key]
+	return ok && cur == prev
+}
+
+// inheritEnv returns environment variables of the namespace overridden by
+// the application environment.
+func (br *UserBroker) inheritEnv(env map[string]string) map[string]string {
+	shared := br.User.Basic().Env
+	if len(shared) == 0 {
+		return env
+	}
+
+	merged := make(map[string]string, len(shared)+len(env))
+	for k, v := range shared {
+		merged[k] = v
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return merged
+}
+
+func checkNamespaceEnvKey(key string) error {
+	if !validEnvKey.MatchString(key) {
+		return fmt.Errorf("%s: Invalid environment variable key", key)
+	}
+	if strings.HasPrefix(key, "CLOUDWAY_") {
+		return fmt.Errorf("%s: Platform environment variables cannot be shared", key)
+	}
+	return nil
+}
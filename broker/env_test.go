@@ -0,0 +1,69 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Namespace environment", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("shared")
+		ub.RemoveApplication("override")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	getenv := func(name string) map[string]string {
+		cs, err := broker.FindApplications(context.Background(), name, NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).NotTo(BeEmpty())
+		env, err := cs[0].GetenvAll(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		return env
+	}
+
+	It("should inherit shared environment variables unless overridden", func() {
+		Expect(ub.SetNamespaceEnv(map[string]string{"LOG_LEVEL": "info", "REGION": "east"})).To(Succeed())
+
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "shared"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		opts := container.CreateOptions{Name: "override", Env: map[string]string{"LOG_LEVEL": "debug"}}
+		_, _, err = ub.CreateApplication(opts, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(getenv("shared")).To(HaveKeyWithValue("LOG_LEVEL", "info"))
+		Expect(getenv("override")).To(HaveKeyWithValue("LOG_LEVEL", "debug"))
+		Expect(getenv("override")).To(HaveKeyWithValue("REGION", "east"))
+
+		Expect(ub.SetNamespaceEnv(map[string]string{"LOG_LEVEL": "warn"})).To(Succeed())
+		Expect(getenv("shared")).To(HaveKeyWithValue("LOG_LEVEL", "warn"))
+		Expect(getenv("override")).To(HaveKeyWithValue("LOG_LEVEL", "debug"))
+
+		Expect(ub.UnsetNamespaceEnv("LOG_LEVEL", "REGION")).To(Succeed())
+		Expect(getenv("shared")).NotTo(HaveKey("LOG_LEVEL"))
+		Expect(getenv("override")).To(HaveKeyWithValue("LOG_LEVEL", "debug"))
+		Expect(getenv("override")).NotTo(HaveKey("REGION"))
+		Expect(ub.GetNamespaceEnv()).To(BeEmpty())
+	})
+
+	It("should reject platform environment variables", func() {
+		Expect(ub.SetNamespaceEnv(map[string]string{"CLOUDWAY_APP_NAME": "x"})).NotTo(Succeed())
+		Expect(ub.SetNamespaceEnv(map[string]string{"A-B": "x"})).NotTo(Succeed())
+	})
+})
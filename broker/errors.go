@@ -1,8 +1,13 @@
 package broker
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
 )
 
 type ApplicationNotFoundError string
@@ -15,6 +20,10 @@ func (e ApplicationNotFoundError) HTTPErrorStatusCode() int {
 	return http.StatusNotFound
 }
 
+func (e ApplicationNotFoundError) ErrorCode() string {
+	return "application_not_found"
+}
+
 type ApplicationExistError struct {
 	Name, Namespace string
 }
@@ -27,6 +36,10 @@ func (e ApplicationExistError) HTTPErrorStatusCode() int {
 	return http.StatusConflict
 }
 
+func (e ApplicationExistError) ErrorCode() string {
+	return "application_exists"
+}
+
 type NoNamespaceError string
 
 func (e NoNamespaceError) Error() string {
@@ -37,6 +50,10 @@ func (e NoNamespaceError) HTTPErrorStatusCode() int {
 	return http.StatusBadRequest
 }
 
+func (e NoNamespaceError) ErrorCode() string {
+	return "no_namespace"
+}
+
 type NamespaceNotEmptyError string
 
 func (e NamespaceNotEmptyError) Error() string {
@@ -46,3 +63,343 @@ func (e NamespaceNotEmptyError) Error() string {
 func (e NamespaceNotEmptyError) HTTPErrorStatusCode() int {
 	return http.StatusForbidden
 }
+
+func (e NamespaceNotEmptyError) ErrorCode() string {
+	return "namespace_not_empty"
+}
+
+type NamespaceNotFoundError string
+
+func (e NamespaceNotFoundError) Error() string {
+	return fmt.Sprintf("Namespace '%s' not found", string(e))
+}
+
+func (e NamespaceNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e NamespaceNotFoundError) ErrorCode() string {
+	return "namespace_not_found"
+}
+
+type NamespaceForbiddenError string
+
+func (e NamespaceForbiddenError) Error() string {
+	return fmt.Sprintf("Cannot operate on applications in the namespace '%s'", string(e))
+}
+
+func (e NamespaceForbiddenError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e NamespaceForbiddenError) ErrorCode() string {
+	return "namespace_forbidden"
+}
+
+type HookNotFoundError string
+
+func (e HookNotFoundError) Error() string {
+	return fmt.Sprintf("Webhook '%s' not found", string(e))
+}
+
+func (e HookNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e HookNotFoundError) ErrorCode() string {
+	return "hook_not_found"
+}
+
+type PluginNotScannedError string
+
+func (e PluginNotScannedError) Error() string {
+	return fmt.Sprintf("Plugin '%s' has not been scanned for vulnerabilities", string(e))
+}
+
+func (e PluginNotScannedError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e PluginNotScannedError) ErrorCode() string {
+	return "plugin_not_scanned"
+}
+
+type NoDeploymentError string
+
+func (e NoDeploymentError) Error() string {
+	return fmt.Sprintf("No deployment in progress for application '%s'", string(e))
+}
+
+func (e NoDeploymentError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e NoDeploymentError) ErrorCode() string {
+	return "no_deployment"
+}
+
+type DeploymentInProgressError string
+
+func (e DeploymentInProgressError) Error() string {
+	return fmt.Sprintf("A deployment is in progress for application '%s'", string(e))
+}
+
+func (e DeploymentInProgressError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e DeploymentInProgressError) ErrorCode() string {
+	return "deployment_in_progress"
+}
+
+type BuildLockedError struct {
+	Name, Operation string
+}
+
+func (e BuildLockedError) Error() string {
+	return fmt.Sprintf("Timed out waiting for the %s of application '%s' to finish", e.Operation, e.Name)
+}
+
+func (e BuildLockedError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e BuildLockedError) ErrorCode() string {
+	return "build_locked"
+}
+
+type ShuttingDownError struct{}
+
+func (e ShuttingDownError) Error() string {
+	return "The server is shutting down, please try again later"
+}
+
+func (e ShuttingDownError) HTTPErrorStatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e ShuttingDownError) ErrorCode() string {
+	return "shutting_down"
+}
+
+type DomainNotFoundError string
+
+func (e DomainNotFoundError) Error() string {
+	return fmt.Sprintf("Domain '%s' is not attached to the application", string(e))
+}
+
+func (e DomainNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e DomainNotFoundError) ErrorCode() string {
+	return "domain_not_found"
+}
+
+type TeamPermissionError struct {
+	Namespace string
+	Role      userdb.TeamRole
+}
+
+func (e TeamPermissionError) Error() string {
+	return fmt.Sprintf("The '%s' role is required to perform the operation in the namespace '%s'", e.Role, e.Namespace)
+}
+
+func (e TeamPermissionError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e TeamPermissionError) ErrorCode() string {
+	return "team_permission_denied"
+}
+
+type SecretNotFoundError string
+
+func (e SecretNotFoundError) Error() string {
+	return fmt.Sprintf("Secret '%s' not found", string(e))
+}
+
+func (e SecretNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e SecretNotFoundError) ErrorCode() string {
+	return "secret_not_found"
+}
+
+type InvalidSecretError string
+
+func (e InvalidSecretError) Error() string {
+	return string(e)
+}
+
+func (e InvalidSecretError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidSecretError) ErrorCode() string {
+	return "invalid_secret"
+}
+
+type LogSearchDisabledError struct{}
+
+func (e LogSearchDisabledError) Error() string {
+	return "Log aggregation is not enabled"
+}
+
+func (e LogSearchDisabledError) HTTPErrorStatusCode() int {
+	return http.StatusNotImplemented
+}
+
+func (e LogSearchDisabledError) ErrorCode() string {
+	return "log_search_disabled"
+}
+
+type InvalidLogQueryError string
+
+func (e InvalidLogQueryError) Error() string {
+	return string(e)
+}
+
+func (e InvalidLogQueryError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidLogQueryError) ErrorCode() string {
+	return "invalid_log_query"
+}
+
+type MemberNotFoundError string
+
+func (e MemberNotFoundError) Error() string {
+	return fmt.Sprintf("User '%s' is not a member of the namespace", string(e))
+}
+
+func (e MemberNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e MemberNotFoundError) ErrorCode() string {
+	return "member_not_found"
+}
+
+// FrameworkMismatchError reports that the framework declared in the
+// application manifest differs from the framework of the application,
+// which cannot be changed by deployment.
+type FrameworkMismatchError struct {
+	Name, Declared, Actual string
+}
+
+func (e FrameworkMismatchError) Error() string {
+	return fmt.Sprintf("The application '%s' is built on '%s', but '%s' is declared in %s", e.Name, e.Actual, e.Declared, manifest.AppManifestFile)
+}
+
+func (e FrameworkMismatchError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e FrameworkMismatchError) ErrorCode() string {
+	return "framework_mismatch"
+}
+
+// InvalidStateError reports that the operation is not allowed in the
+// current lifecycle state of the application.
+type InvalidStateError struct {
+	Name      string
+	State     userdb.AppState
+	Operation string
+}
+
+func (e InvalidStateError) Error() string {
+	return fmt.Sprintf("Cannot %s the application '%s' in the %s state", e.Operation, e.Name, e.State)
+}
+
+func (e InvalidStateError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+func (e InvalidStateError) ErrorCode() string {
+	return "invalid_state"
+}
+
+func (e InvalidStateError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{
+		"State": e.State,
+	}
+}
+
+// ContainerFailure describes a container failed or skipped in a lifecycle
+// operation of the application.
+type ContainerFailure struct {
+	ID      string
+	Service string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+func newContainerFailure(c *container.Container, err error) *ContainerFailure {
+	f := &ContainerFailure{ID: c.ID[:12], Service: c.ServiceName()}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	return f
+}
+
+func (f *ContainerFailure) String() string {
+	if f.Service != "" {
+		return f.Service + " (" + f.ID + ")"
+	}
+	return f.ID
+}
+
+// PartialFailureError reports containers failed in a lifecycle operation of
+// the application. Containers depending on failed containers are skipped
+// when starting the application.
+type PartialFailureError struct {
+	Operation string
+	Total     int
+	Failed    []*ContainerFailure
+	Skipped   []*ContainerFailure
+}
+
+func (e PartialFailureError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Failed to %s %d of %d containers", e.Operation, len(e.Failed), e.Total)
+	for _, f := range e.Failed {
+		fmt.Fprintf(&buf, "\n  %s: %s", f, f.Error)
+	}
+	for _, f := range e.Skipped {
+		fmt.Fprintf(&buf, "\n  %s: skipped", f)
+	}
+	return buf.String()
+}
+
+func (e PartialFailureError) HTTPErrorStatusCode() int {
+	return http.StatusInternalServerError
+}
+
+func (e PartialFailureError) ErrorCode() string {
+	return "partial_failure"
+}
+
+func (e PartialFailureError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{
+		"Operation": e.Operation,
+		"Total":     e.Total,
+		"Failed":    e.Failed,
+		"Skipped":   e.Skipped,
+	}
+}
+
+type InvalidNetworkGrantError string
+
+func (e InvalidNetworkGrantError) Error() string {
+	return string(e)
+}
+
+func (e InvalidNetworkGrantError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidNetworkGrantError) ErrorCode() string {
+	return "invalid_network_grant"
+}
@@ -0,0 +1,26 @@
+package broker
+
+import "io"
+
+// Unexported helpers exposed to the external test package.
+
+var (
+	ListDir     = listDir
+	ResolvePath = resolvePath
+)
+
+func TopLevelNames(r io.Reader) ([]string, error) {
+	entries, err := readArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	return topLevelNames(entries), nil
+}
+
+func SymlinkedEntry(r io.Reader, existing []string) (string, error) {
+	entries, err := readArchive(r)
+	if err != nil {
+		return "", err
+	}
+	return symlinkedEntry(entries, existing), nil
+}
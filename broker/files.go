@@ -0,0 +1,350 @@
+package broker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types"
+
+	"github.com/cloudway/platform/container"
+)
+
+// The directories within application containers that can be browsed.
+var fileRoots = map[string]func(*container.Container) string{
+	"data": (*container.Container).DataDir,
+	"repo": (*container.Container).RepoDir,
+}
+
+// FileInfo describes a file within the application container.
+type FileInfo struct {
+	Name       string
+	Size       int64
+	Mode       os.FileMode
+	ModTime    time.Time
+	LinkTarget string
+}
+
+type InvalidFileRootError string
+
+func (e InvalidFileRootError) Error() string {
+	return fmt.Sprintf("Invalid file root '%s', must be one of data or repo", string(e))
+}
+
+func (e InvalidFileRootError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type FileNotFoundError string
+
+func (e FileNotFoundError) Error() string {
+	return fmt.Sprintf("No such file or directory: %s", string(e))
+}
+
+func (e FileNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// The SymlinkError indicates that files would be written through a
+// symbolic link.
+type SymlinkError string
+
+func (e SymlinkError) Error() string {
+	return fmt.Sprintf("Refusing to write through symbolic link: %s", string(e))
+}
+
+func (e SymlinkError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ListFiles returns the directory contents, or information of a regular
+// file, located at the path relative to the root directory.
+func (br *UserBroker) ListFiles(name, service, root, p string) ([]*FileInfo, error) {
+	c, dir, stat, err := br.statFile(name, service, root, p)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode.IsDir() {
+		return []*FileInfo{fileInfoFromStat(stat)}, nil
+	}
+
+	r, _, err := c.CopyFromContainer(br.ctx, c.ID, dir+"/.")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return listDir(r)
+}
+
+// listDir returns the entries directly contained in the directory archived
+// by Docker, excluding the directory itself and nested entries.
+func listDir(r io.Reader) ([]*FileInfo, error) {
+	files := make([]*FileInfo, 0)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if name == "" || name == "." || strings.Contains(name, "/") {
+			continue
+		}
+
+		fi := hdr.FileInfo()
+		files = append(files, &FileInfo{
+			Name:       name,
+			Size:       fi.Size(),
+			Mode:       fi.Mode(),
+			ModTime:    fi.ModTime(),
+			LinkTarget: hdr.Linkname,
+		})
+	}
+	return files, nil
+}
+
+// DownloadFiles returns a tar archive of the file or directory located at
+// the path relative to the root directory.
+func (br *UserBroker) DownloadFiles(name, service, root, p string) (io.ReadCloser, *FileInfo, error) {
+	c, dir, stat, err := br.statFile(name, service, root, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, _, err := c.CopyFromContainer(br.ctx, c.ID, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, fileInfoFromStat(stat), nil
+}
+
+// UploadFiles extracts a tar archive into the directory located at the path
+// relative to the root directory. The extracted files are owned by the
+// application user. The archive is extracted by root, which follows
+// symbolic links, so writing through symbolic links planted in the
+// application container or created by the archive is rejected.
+func (br *UserBroker) UploadFiles(name, service, root, p string, content io.Reader) error {
+	c, dir, stat, err := br.statFile(name, service, root, p)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode.IsDir() {
+		return fmt.Errorf("Not a directory: %s", p)
+	}
+	if err = br.checkSymlinkDir(c, dir); err != nil {
+		return err
+	}
+
+	// spool the archive to check entries before extracting
+	tmp, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	entries, err := readArchive(io.TeeReader(content, tmp))
+	if err != nil {
+		return err
+	}
+
+	links, err := br.findSymlinks(c, dir)
+	if err != nil {
+		return err
+	}
+	if name := symlinkedEntry(entries, links); name != "" {
+		return SymlinkError(name)
+	}
+
+	if _, err = tmp.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	err = c.CopyToContainer(br.ctx, c.ID, dir, tmp, types.CopyToContainerOptions{})
+	if err != nil {
+		return err
+	}
+
+	// never follow symbolic links extracted from the archive
+	if names := topLevelNames(entries); len(names) != 0 {
+		args := []string{"chown", "-R", "-h", c.User()}
+		for _, name := range names {
+			args = append(args, dir+"/"+name)
+		}
+		return c.Exec(br.ctx, "root", nil, nil, nil, args...)
+	}
+	return nil
+}
+
+// checkSymlinkDir rejects the directory if the directory or any of its
+// parent directories within the application home is a symbolic link.
+func (br *UserBroker) checkSymlinkDir(c *container.Container, dir string) error {
+	home := c.Home()
+	for d := dir; strings.HasPrefix(d, home+"/"); d = path.Dir(d) {
+		stat, err := c.ContainerStatPath(br.ctx, c.ID, d)
+		if err != nil {
+			return err
+		}
+		if stat.Mode&os.ModeSymlink != 0 {
+			return SymlinkError(strings.TrimPrefix(d, home+"/"))
+		}
+	}
+	return nil
+}
+
+// findSymlinks returns symbolic links in the directory, relative to the
+// directory.
+func (br *UserBroker) findSymlinks(c *container.Container, dir string) ([]string, error) {
+	var out bytes.Buffer
+	err := c.Exec(br.ctx, "root", nil, &out, nil, "find", dir, "-type", "l", "-print0")
+	if err != nil {
+		return nil, err
+	}
+	var links []string
+	for _, link := range strings.Split(out.String(), "\x00") {
+		if link = strings.TrimPrefix(link, dir+"/"); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (br *UserBroker) statFile(name, service, root, p string) (c *container.Container, dir string, stat types.ContainerPathStat, err error) {
+	if err = br.Refresh(); err != nil {
+		return
+	}
+
+	var cs []*container.Container
+	if service == "" {
+		cs, err = br.FindApplications(br.ctx, name, br.Namespace())
+	} else {
+		cs, err = br.FindService(br.ctx, name, br.Namespace(), service)
+	}
+	if err != nil {
+		return
+	}
+	if len(cs) == 0 {
+		if service == "" {
+			err = ApplicationNotFoundError(name)
+		} else {
+			err = fmt.Errorf("Service '%s' not found in application '%s'", service, name)
+		}
+		return
+	}
+	c = cs[0]
+
+	if root == "" {
+		root = "data"
+	}
+	rootDir, ok := fileRoots[root]
+	if !ok {
+		err = InvalidFileRootError(root)
+		return
+	}
+
+	dir, p = resolvePath(rootDir(c), p)
+
+	stat, err = c.ContainerStatPath(br.ctx, c.ID, dir)
+	if err != nil {
+		err = FileNotFoundError(root + p)
+	}
+	return
+}
+
+// resolvePath joins the path to the root directory, and returns the cleaned
+// path. The cleaned path never escapes from the root directory.
+func resolvePath(rootDir, p string) (dir, cleaned string) {
+	cleaned = path.Clean("/" + p)
+	if cleaned == "/" {
+		return rootDir, cleaned
+	}
+	return rootDir + cleaned, cleaned
+}
+
+func fileInfoFromStat(stat types.ContainerPathStat) *FileInfo {
+	return &FileInfo{
+		Name:       stat.Name,
+		Size:       stat.Size,
+		Mode:       stat.Mode,
+		ModTime:    stat.Mtime,
+		LinkTarget: stat.LinkTarget,
+	}
+}
+
+// archiveEntry is an entry of an uploaded archive, with the name cleaned
+// and relative to the extracting directory.
+type archiveEntry struct {
+	name string
+	link bool
+}
+
+// readArchive reads the entries of the archive. The archive is read to the
+// end, so it can be copied while reading.
+func readArchive(r io.Reader) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name != "" {
+			entries = append(entries, archiveEntry{name, hdr.Typeflag == tar.TypeSymlink})
+		}
+	}
+	_, err := io.Copy(ioutil.Discard, r)
+	return entries, err
+}
+
+// symlinkedEntry returns the name of the first entry to be extracted
+// through a symbolic link, either existing in the extracting directory or
+// created by a prior entry. Returns an empty string if there is none.
+func symlinkedEntry(entries []archiveEntry, existing []string) string {
+	links := make(map[string]bool)
+	for _, link := range existing {
+		links[link] = true
+	}
+	for _, e := range entries {
+		for p := e.name; p != "."; p = path.Dir(p) {
+			if links[p] {
+				return e.name
+			}
+		}
+		if e.link {
+			links[e.name] = true
+		}
+	}
+	return ""
+}
+
+// topLevelNames returns the unique top level names of archive entries.
+func topLevelNames(entries []archiveEntry) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		name := e.name
+		if i := strings.IndexRune(name, '/'); i != -1 {
+			name = name[:i]
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	br "github.com/cloudway/platform/broker"
+)
+
+var _ = Describe("Files", func() {
+	type entry struct {
+		name string
+		link string
+	}
+
+	archive := func(entries ...entry) io.Reader {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0644}
+			switch {
+			case e.link != "":
+				hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+			case e.name[len(e.name)-1] == '/':
+				hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+			default:
+				hdr.Typeflag = tar.TypeReg
+			}
+			Expect(tw.WriteHeader(hdr)).To(Succeed())
+		}
+		Expect(tw.Close()).To(Succeed())
+		return &buf
+	}
+
+	Describe("ListDir", func() {
+		It("should list direct entries only", func() {
+			files, err := br.ListDir(archive(
+				entry{name: "./"},
+				entry{name: "./a.txt"},
+				entry{name: "./sub/"},
+				entry{name: "./sub/b.txt"},
+				entry{name: "./link", link: "/etc"},
+			))
+			Expect(err).NotTo(HaveOccurred())
+
+			var names []string
+			for _, fi := range files {
+				names = append(names, fi.Name)
+			}
+			Expect(names).To(Equal([]string{"a.txt", "sub", "link"}))
+			Expect(files[1].Mode.IsDir()).To(BeTrue())
+			Expect(files[2].LinkTarget).To(Equal("/etc"))
+		})
+
+		It("should return empty list for empty directory", func() {
+			files, err := br.ListDir(archive(entry{name: "./"}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+	})
+
+	Describe("ResolvePath", func() {
+		DescribeTable("should never escape from the root directory",
+			func(p, dir, cleaned string) {
+				d, c := br.ResolvePath("/home/user/data", p)
+				Expect(d).To(Equal(dir))
+				Expect(c).To(Equal(cleaned))
+			},
+			Entry("empty", "", "/home/user/data", "/"),
+			Entry("root", "/", "/home/user/data", "/"),
+			Entry("relative", "a/b", "/home/user/data/a/b", "/a/b"),
+			Entry("trailing slash", "a/b/", "/home/user/data/a/b", "/a/b"),
+			Entry("parent", "..", "/home/user/data", "/"),
+			Entry("parents", "../../etc/passwd", "/home/user/data/etc/passwd", "/etc/passwd"),
+			Entry("inner parents", "a/../../b", "/home/user/data/b", "/b"),
+		)
+	})
+
+	Describe("TopLevelNames", func() {
+		It("should return unique top level names", func() {
+			names, err := br.TopLevelNames(archive(
+				entry{name: "./"},
+				entry{name: "./a/"},
+				entry{name: "./a/b.txt"},
+				entry{name: "c.txt"},
+				entry{name: "a/d/"},
+				entry{name: "../e.txt"},
+			))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"a", "c.txt", "e.txt"}))
+		})
+
+		It("should return nothing for empty archive", func() {
+			names, err := br.TopLevelNames(archive())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(BeEmpty())
+		})
+	})
+
+	Describe("SymlinkedEntry", func() {
+		It("should accept archive without symbolic links", func() {
+			name, err := br.SymlinkedEntry(archive(
+				entry{name: "a/"},
+				entry{name: "a/b.txt"},
+			), []string{"c"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(BeEmpty())
+		})
+
+		It("should accept symbolic links not written through", func() {
+			name, err := br.SymlinkedEntry(archive(
+				entry{name: "link", link: "/etc"},
+				entry{name: "linked.txt"},
+			), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(BeEmpty())
+		})
+
+		It("should reject overwriting existing symbolic link", func() {
+			name, err := br.SymlinkedEntry(archive(
+				entry{name: "a.txt"},
+			), []string{"a.txt"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("a.txt"))
+		})
+
+		It("should reject writing under existing symbolic link", func() {
+			name, err := br.SymlinkedEntry(archive(
+				entry{name: "a/"},
+				entry{name: "a/b/c.txt"},
+			), []string{"a/b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("a/b/c.txt"))
+		})
+
+		It("should reject writing under symbolic link from archive", func() {
+			name, err := br.SymlinkedEntry(archive(
+				entry{name: "etc", link: "/etc"},
+				entry{name: "./etc/passwd"},
+			), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("etc/passwd"))
+		})
+	})
+})
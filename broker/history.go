@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/history"
+	"github.com/cloudway/platform/metrics"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Keep the deployed repository in deployment history.
+func (br *UserBroker) recordDeployment(name string, opts *container.DeployOptions) {
+	if opts.Record == nil {
+		user := br.User
+		opts.Record = func(repo io.Reader) error {
+			return br.saveDeployment(user, name, repo)
+		}
+	}
+}
+
+// RecordDeployment keeps the repository deployed to the application in
+// the given namespace in deployment history.
+func (br *Broker) RecordDeployment(namespace, name string, repo io.Reader) error {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	return br.saveDeployment(user, name, repo)
+}
+
+// saveDeployment saves the repository archive and records it as the
+// current version of the application.
+func (br *Broker) saveDeployment(user userdb.User, name string, repo io.Reader) error {
+	namespace := user.Basic().Namespace
+	v, err := br.History.Save(namespace, name, repo)
+	if err != nil || v == nil {
+		return err
+	}
+
+	var discard []*history.Version
+	err = br.modifyApplication(user, name, func(app *userdb.Application) error {
+		app.Deployments, discard = br.History.Add(app.Deployments, v)
+		app.CurrentDeployment = v.Version
+		return nil
+	})
+	if err != nil {
+		discard = []*history.Version{v}
+	}
+	if er := br.History.Discard(namespace, name, discard); er != nil {
+		logrus.WithError(er).Warn("Failed to discard deployment history")
+	}
+	return err
+}
+
+// Get the deployment history of the application, the most recent first.
+// The second return value is the version currently deployed, zero if
+// unknown.
+func (br *UserBroker) GetDeploymentHistory(name string) ([]*history.Version, int, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, 0, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, 0, ApplicationNotFoundError(name)
+	}
+	return app.Deployments, app.CurrentDeployment, nil
+}
+
+// Rollback the application to a previously deployed version without
+// rebuilding. If version is 0 then rollback to the version prior to the
+// currently deployed version.
+func (br *UserBroker) Rollback(name string, version int, log *serverlog.ServerLog) (*history.Version, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	v, err := history.Find(name, app.Deployments, app.CurrentDeployment, version)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := br.History.Open(br.Namespace(), name, v)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	containers, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, br.checkNoFramework(name)
+	}
+
+	unlock, err := br.lockBuild(br.ctx, name, "rollback", log)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	fmt.Fprintf(log, "Rolling back %s to version %d\n", name, v.Version)
+	opts := container.DeployOptions{}
+	err = br.notifyDeploy(name, opts.Strategy, func() (err error) {
+		defer metrics.ObserveDeployment("rollback", time.Now(), &err)
+		return br.DistributeRepo(br.ctx, containers, repo, false, opts, log)
+	})
+	if err != nil {
+		return v, err
+	}
+
+	// record the rolled back version as current, so the next rollback
+	// picks the version before it
+	return v, br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		app.CurrentDeployment = v.Version
+		return nil
+	})
+}
@@ -0,0 +1,174 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/webhook"
+)
+
+// ListHooks returns webhooks registered to the application.
+func (br *UserBroker) ListHooks(name string) ([]*webhook.Hook, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	return app.Hooks, nil
+}
+
+// AddHook registers a webhook to the application. The hook is notified
+// for the given events, or all events if no event specified.
+func (br *UserBroker) AddHook(name, url string, events []string, secret string) (*webhook.Hook, error) {
+	hook, err := webhook.NewHook(url, events, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		app.Hooks = append(app.Hooks, hook)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// RemoveHook removes a webhook and its delivery log from the application.
+func (br *UserBroker) RemoveHook(name, id string) error {
+	err := br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		for i, h := range app.Hooks {
+			if h.ID == id {
+				app.Hooks = append(app.Hooks[:i], app.Hooks[i+1:]...)
+				return nil
+			}
+		}
+		return HookNotFoundError(id)
+	})
+	if err != nil {
+		return err
+	}
+	return br.Hooks.Remove(br.Namespace(), name, id)
+}
+
+// HookDeliveries returns recent deliveries of the webhook.
+func (br *UserBroker) HookDeliveries(name, id string) ([]*webhook.Delivery, error) {
+	hook, err := br.findHook(name, id)
+	if err != nil {
+		return nil, err
+	}
+	return br.Hooks.Deliveries(br.Namespace(), name, hook.ID)
+}
+
+// RedeliverHook sends a previous delivery to the webhook again.
+func (br *UserBroker) RedeliverHook(name, id, delivery string) (*webhook.Delivery, error) {
+	hook, err := br.findHook(name, id)
+	if err != nil {
+		return nil, err
+	}
+	return br.Hooks.Redeliver(br.Namespace(), name, hook, delivery)
+}
+
+func (br *UserBroker) getApplication(name string) (*userdb.Application, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+	return app, nil
+}
+
+func (br *UserBroker) findHook(name, id string) (*webhook.Hook, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range app.Hooks {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return nil, HookNotFoundError(id)
+}
+
+// notifyDeploy notifies webhooks of the application when the deployment
+// starts and finishes. The user must be refreshed before calling.
+func (br *UserBroker) notifyDeploy(name string, strategy container.DeployStrategy, deploy func() error) error {
+	app := br.User.Basic().Applications[name]
+	if app == nil || len(app.Hooks) == 0 {
+		return deploy()
+	}
+
+	namespace := br.Namespace()
+	br.Hooks.Notify(namespace, name, app.Hooks, &webhook.Payload{
+		Event:    webhook.DeployStarted,
+		Strategy: strategy.String(),
+	})
+
+	err := deploy()
+
+	payload := &webhook.Payload{Event: webhook.DeploySucceeded, Strategy: strategy.String()}
+	if err != nil {
+		payload.Event = webhook.DeployFailed
+		payload.Error = err.Error()
+	}
+	br.Hooks.Notify(namespace, name, app.Hooks, payload)
+	return err
+}
+
+// NotifyCrashLoop notifies webhooks of the application that a container
+// entered a crash loop.
+func (br *Broker) NotifyCrashLoop(c *container.Container, cl *container.CrashLoop) {
+	br.notifyContainer(c.Namespace, c.Name, &webhook.Payload{
+		Event:     webhook.ContainerCrashLoop,
+		Container: c.ID,
+		Error:     cl.String(),
+	})
+}
+
+// HandleContainerEvent notifies webhooks of the application when a
+// container exited abnormally, was killed by the OOM killer, or was
+// removed out of band.
+func (br *Broker) HandleContainerEvent(e *container.ContainerEvent) {
+	if e.Name == "" || e.Namespace == "" {
+		return
+	}
+
+	payload := &webhook.Payload{Container: e.ID, Timestamp: e.Time}
+	switch {
+	case e.Action == container.ContainerOOMKilled:
+		logrus.Warnf("Container %s of %s-%s killed by the OOM killer", e.ID[:12], e.Name, e.Namespace)
+		payload.Event = webhook.ContainerOOMKilled
+		payload.Error = "killed by the OOM killer"
+	case e.Action == container.ContainerDied && e.ExitCode != 0:
+		payload.Event = webhook.ContainerDied
+		payload.Error = fmt.Sprintf("exited with code %d", e.ExitCode)
+	case e.Action == container.ContainerRemoved && e.OutOfBand:
+		logrus.Warnf("Container %s of %s-%s removed out of band", e.ID[:12], e.Name, e.Namespace)
+		payload.Event = webhook.ContainerRemoved
+	default:
+		return
+	}
+	br.notifyContainer(e.Namespace, e.Name, payload)
+}
+
+func (br *Broker) notifyContainer(namespace, name string, payload *webhook.Payload) {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		if !userdb.IsUserNotFound(err) {
+			logrus.WithError(err).Warnf("Failed to notify %s", payload.Event)
+		}
+		return
+	}
+
+	app := user.Basic().Applications[name]
+	if app == nil || len(app.Hooks) == 0 {
+		return
+	}
+	br.Hooks.Notify(namespace, name, app.Hooks, payload)
+}
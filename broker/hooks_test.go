@@ -0,0 +1,150 @@
+package broker_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/scm/push"
+	"github.com/cloudway/platform/webhook"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Webhooks", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "hooks"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("hooks")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should register and remove webhooks", func() {
+		hook, err := ub.AddHook("hooks", "http://example.com/hook", []string{webhook.DeployFailed}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hook.Secret).NotTo(BeEmpty())
+
+		hooks, err := ub.ListHooks("hooks")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks).To(HaveLen(1))
+		Expect(hooks[0].ID).To(Equal(hook.ID))
+		Expect(hooks[0].Events).To(Equal([]string{webhook.DeployFailed}))
+
+		deliveries, err := ub.HookDeliveries("hooks", hook.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deliveries).To(BeEmpty())
+
+		Expect(ub.RemoveHook("hooks", hook.ID)).To(Succeed())
+		Expect(ub.ListHooks("hooks")).To(BeEmpty())
+		Expect(ub.RemoveHook("hooks", hook.ID)).To(Equal(br.HookNotFoundError(hook.ID)))
+	})
+
+	It("should reject invalid webhooks", func() {
+		_, err := ub.AddHook("hooks", "example.com", nil, "")
+		Expect(err).To(Equal(webhook.InvalidURLError("example.com")))
+		_, err = ub.AddHook("hooks", "http://example.com/hook", []string{"unknown"}, "")
+		Expect(err).To(Equal(webhook.InvalidEventError("unknown")))
+		_, err = ub.AddHook("nonexist", "http://example.com/hook", nil, "")
+		Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+	})
+
+	It("should notify crash loops", func() {
+		events := make(chan string, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			events <- r.Header.Get(webhook.EventHeader)
+		}))
+		defer server.Close()
+
+		_, err := ub.AddHook("hooks", server.URL, []string{webhook.ContainerCrashLoop}, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		cs, err := broker.FindAll(context.Background(), "hooks", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).NotTo(BeEmpty())
+
+		broker.NotifyCrashLoop(cs[0], &container.CrashLoop{Restarts: 3, ExitCode: 1})
+		Eventually(events).Should(Receive(Equal(webhook.ContainerCrashLoop)))
+	})
+
+	It("should notify containers died or removed out of band", func() {
+		events := make(chan string, 3)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			events <- r.Header.Get(webhook.EventHeader)
+		}))
+		defer server.Close()
+
+		_, err := ub.AddHook("hooks", server.URL, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		event := func(action string, exitCode int, outOfBand bool) *container.ContainerEvent {
+			return &container.ContainerEvent{
+				Action: action, ID: "0123456789abcdef", Name: "hooks", Namespace: NAMESPACE,
+				ExitCode: exitCode, OutOfBand: outOfBand,
+			}
+		}
+
+		broker.HandleContainerEvent(event(container.ContainerDied, 0, false))
+		broker.HandleContainerEvent(event(container.ContainerRemoved, 0, false))
+		Consistently(events).ShouldNot(Receive())
+
+		broker.HandleContainerEvent(event(container.ContainerDied, 137, false))
+		Eventually(events).Should(Receive(Equal(webhook.ContainerDied)))
+		broker.HandleContainerEvent(event(container.ContainerOOMKilled, 0, false))
+		Eventually(events).Should(Receive(Equal(webhook.ContainerOOMKilled)))
+		broker.HandleContainerEvent(event(container.ContainerRemoved, 0, true))
+		Eventually(events).Should(Receive(Equal(webhook.ContainerRemoved)))
+	})
+
+	It("should generate push webhook secret", func() {
+		secret, err := ub.GetPushSecret("hooks", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret).NotTo(BeEmpty())
+		Expect(ub.GetPushSecret("hooks", false)).To(Equal(secret))
+
+		regenerated, err := ub.GetPushSecret("hooks", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(regenerated).NotTo(Equal(secret))
+	})
+
+	It("should reject push webhooks not signed with the secret", func() {
+		header := http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {"wrong"}}
+		body := []byte(`{"ref":"refs/heads/master","after":"abc123"}`)
+
+		_, _, err := broker.ReceivePush(NAMESPACE, "hooks", header, "", body)
+		Expect(err).To(Equal(br.ApplicationNotFoundError("hooks")))
+
+		_, err = ub.GetPushSecret("hooks", false)
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = broker.ReceivePush(NAMESPACE, "hooks", header, "", body)
+		Expect(err).To(Equal(push.SignatureError("GitLab")))
+	})
+
+	It("should ignore push to other branches", func() {
+		secret, err := ub.GetPushSecret("hooks", false)
+		Expect(err).NotTo(HaveOccurred())
+
+		header := http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {secret}}
+		body := []byte(`{"ref":"refs/heads/feature","after":"abc123"}`)
+		event, deploying, err := broker.ReceivePush(NAMESPACE, "hooks", header, "", body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(event.Ref).To(Equal("refs/heads/feature"))
+		Expect(deploying).To(BeFalse())
+	})
+})
@@ -0,0 +1,172 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/proxy"
+)
+
+// The interval to check activity of running applications.
+const idleCheckInterval = time.Minute
+
+// RunIdleMonitor periodically idles running applications that received no
+// request through the proxy longer than idle.timeout, and parks hosts of idle
+// applications in the proxy so they're woken up on the next request. Returns
+// immediately if idle.timeout is not configured or the proxy doesn't track
+// requests.
+func (br *Broker) RunIdleMonitor(stop <-chan bool) {
+	timeout := idleTimeout()
+	if timeout == 0 {
+		return
+	}
+
+	supported := false
+	withIdleTracker(func(proxy.IdleTracker) error {
+		supported = true
+		return nil
+	})
+	if !supported {
+		logrus.Warn("The proxy doesn't track requests, applications are not idled automatically")
+		return
+	}
+
+	// activity before the monitor started is unknown
+	started := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(idleCheckInterval):
+			br.idleInactive(started, timeout)
+		}
+	}
+}
+
+func idleTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.GetOrDefault("idle.timeout", "0"))
+	if err != nil || timeout < 0 {
+		logrus.Warnf("Invalid idle.timeout configuration: %s", config.Get("idle.timeout"))
+		timeout = 0
+	}
+	return timeout
+}
+
+func (br *Broker) idleInactive(started time.Time, timeout time.Duration) {
+	var activity map[string]time.Time
+	err := withIdleTracker(func(tracker proxy.IdleTracker) (err error) {
+		activity, err = tracker.Activity()
+		return err
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get activity of applications")
+		return
+	}
+
+	var users []userdb.BasicUser
+	if err = br.Users.Search(userdb.Args{}, &users); err != nil {
+		logrus.WithError(err).Error("Failed to find users for idle applications")
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	for i := range users {
+		user := &users[i]
+		for name, app := range user.Applications {
+			switch app.CurrentState() {
+			case userdb.AppIdle:
+				// park again in case the proxy was restarted
+				if err = parkApplication(user.Namespace, name, app); err != nil {
+					logrus.WithError(err).Warnf("Failed to park idle application %s-%s", name, user.Namespace)
+				}
+
+			case userdb.AppRunning:
+				cs, err := br.FindApplications(ctx, name, user.Namespace)
+				if err != nil || len(cs) == 0 {
+					continue
+				}
+				if now.Sub(lastActive(app, cs, activity, started)) < timeout {
+					continue
+				}
+
+				logrus.Infof("Idle inactive application %s-%s", name, user.Namespace)
+				if err = br.NewUserBroker(user, ctx).IdleApplication(name); err != nil {
+					logrus.WithError(err).Errorf("Failed to idle application %s-%s", name, user.Namespace)
+				}
+			}
+		}
+	}
+}
+
+// lastActive returns the time the application was last requested, started
+// or changed state.
+func lastActive(app *userdb.Application, cs []*container.Container, activity map[string]time.Time, since time.Time) time.Time {
+	last := since
+	if app.StateChanged.After(last) {
+		last = app.StateChanged
+	}
+	for _, c := range cs {
+		if t, ok := activity[c.ID]; ok && t.After(last) {
+			last = t
+		}
+		if t, err := time.Parse(time.RFC3339Nano, c.State.StartedAt); err == nil && t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// WakeApplication resumes the idle application on request. The key has the
+// form of name-namespace as parked in the proxy.
+func (br *Broker) WakeApplication(key string) error {
+	_, name, namespace := container.SplitNames(key)
+	if name == "" {
+		return ApplicationNotFoundError(key)
+	}
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	return br.NewUserBroker(user, context.Background()).ResumeApplication(name, nil)
+}
+
+// parkApplication parks the default host and custom domains of the idle
+// application in the proxy.
+func parkApplication(namespace, name string, app *userdb.Application) error {
+	hosts := append([]string{name + "-" + namespace + "." + defaults.Domain()}, app.Hosts...)
+	return withIdleTracker(func(tracker proxy.IdleTracker) error {
+		return tracker.Park(name+"-"+namespace, hosts)
+	})
+}
+
+// unparkApplication stops holding requests of the application in the proxy.
+func unparkApplication(namespace, name string) error {
+	return withIdleTracker(func(tracker proxy.IdleTracker) error {
+		return tracker.Unpark(name + "-" + namespace)
+	})
+}
+
+func withIdleTracker(fn func(proxy.IdleTracker) error) error {
+	proxyURL := config.Get("proxy.url")
+	if proxyURL == "" {
+		return nil
+	}
+
+	px, err := proxy.New(proxyURL)
+	if err != nil {
+		return err
+	}
+	defer px.Close()
+
+	if tracker, ok := px.(proxy.IdleTracker); ok {
+		return fn(tracker)
+	}
+	return nil
+}
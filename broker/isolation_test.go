@@ -0,0 +1,130 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Namespace isolation", func() {
+	const (
+		OTHERUSER      = "broker_test_other@example.com"
+		OTHERNAMESPACE = "broker_test_other"
+	)
+
+	var (
+		ctx   = context.Background()
+		user  = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		other = userdb.BasicUser{Name: OTHERUSER, Namespace: OTHERNAMESPACE}
+	)
+
+	BeforeEach(func() {
+		for _, u := range []*userdb.BasicUser{&user, &other} {
+			Expect(broker.CreateUser(u, "test")).To(Succeed())
+			br := broker.NewUserBroker(u, ctx)
+			_, _, err := br.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(br.StartApplication("test", nil)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		broker.RemoveUser(TESTUSER)
+		broker.RemoveUser(OTHERUSER)
+	})
+
+	var findApp = func(namespace string) []*container.Container {
+		cs, err := broker.FindAll(ctx, "test", namespace)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return cs
+	}
+
+	var startedAt = func(namespace string) string {
+		cs := findApp(namespace)
+		ExpectWithOffset(1, cs).To(HaveLen(1))
+		return cs[0].State.StartedAt
+	}
+
+	var fetchMarker = func(namespace string) func() error {
+		return func() error {
+			cs := findApp(namespace)
+			r, _, err := broker.CopyFromContainer(ctx, cs[0].ID, cs[0].RepoDir()+"/marker")
+			if err == nil {
+				r.Close()
+			}
+			return err
+		}
+	}
+
+	It("should only find containers in the requested namespace", func() {
+		for _, ns := range []string{NAMESPACE, OTHERNAMESPACE} {
+			cs := findApp(ns)
+			Expect(cs).To(HaveLen(1))
+			Expect(cs[0].Name).To(Equal("test"))
+			Expect(cs[0].Namespace).To(Equal(ns))
+		}
+		Expect(findApp(NAMESPACE)[0].ID).NotTo(Equal(findApp(OTHERNAMESPACE)[0].ID))
+	})
+
+	It("should not find application containers without namespace", func() {
+		Expect(findApp("")).To(BeEmpty())
+	})
+
+	It("should isolate deployment", func() {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		tw.WriteHeader(&tar.Header{Name: "marker", Mode: 0644, Size: 6})
+		tw.Write([]byte("marker"))
+		tw.Close()
+		zw.Close()
+
+		br := broker.NewUserBroker(&user, ctx)
+		Expect(br.Upload("test", &buf, true, container.DeployOptions{}, nil)).To(Succeed())
+
+		Eventually(fetchMarker(NAMESPACE), deployTimeout).Should(Succeed())
+		Consistently(fetchMarker(OTHERNAMESPACE), "2s").ShouldNot(Succeed())
+	})
+
+	It("should isolate environment variables", func() {
+		Expect(findApp(NAMESPACE)[0].Setenv(ctx, "ISOLATION", "mine")).To(Succeed())
+
+		_, err := findApp(OTHERNAMESPACE)[0].Getenv(ctx, "ISOLATION")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should isolate scaling", func() {
+		br := broker.NewUserBroker(&user, ctx)
+		_, err := br.ScaleApplication("test", 2)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findApp(NAMESPACE)).To(HaveLen(2))
+		Expect(findApp(OTHERNAMESPACE)).To(HaveLen(1))
+	})
+
+	It("should isolate restart", func() {
+		before := startedAt(OTHERNAMESPACE)
+
+		br := broker.NewUserBroker(&user, ctx)
+		Expect(br.RestartApplication("test", nil)).To(Succeed())
+		Expect(startedAt(OTHERNAMESPACE)).To(Equal(before))
+	})
+
+	It("should not operate other namespace with a stale user namespace", func() {
+		before := startedAt(OTHERNAMESPACE)
+
+		// The user namespace carried by an old token may be reused by others
+		stale := &userdb.BasicUser{Name: TESTUSER, Namespace: OTHERNAMESPACE}
+		br := broker.NewUserBroker(stale, ctx)
+		Expect(br.RestartApplication("test", nil)).To(Succeed())
+		Expect(stale.Namespace).To(Equal(NAMESPACE))
+		Expect(startedAt(OTHERNAMESPACE)).To(Equal(before))
+	})
+})
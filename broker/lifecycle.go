@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The lifecycle state transitions allowed by operations. Restart keeps the
+// application running, and start resumes a stopped or idle application.
+var stateTransitions = map[string][]userdb.AppState{
+	"start":   {userdb.AppRunning, userdb.AppStopped, userdb.AppIdle},
+	"restart": {userdb.AppRunning},
+	"stop":    {userdb.AppRunning, userdb.AppStopped, userdb.AppIdle},
+	"idle":    {userdb.AppRunning, userdb.AppIdle},
+	"resume":  {userdb.AppIdle},
+}
+
+// IdleApplication stops all containers of the running application to save
+// resources. The application keeps its deployment and is resumed later, or
+// woken up by the next request if supported by the proxy.
+func (br *UserBroker) IdleApplication(name string) error {
+	return br.changeState(name, "idle", userdb.AppIdle, func(cs []*container.Container) error {
+		// hold requests before routes are removed from the proxy
+		app := br.User.Basic().Applications[name]
+		if err := parkApplication(br.Namespace(), name, app); err != nil {
+			logrus.WithError(err).Warnf("Failed to park idle application %s-%s", name, br.Namespace())
+		}
+		return stopContainers(cs, func(c *container.Container) error {
+			return c.Stop(br.ctx)
+		})
+	})
+}
+
+// ResumeApplication starts all containers of the idle application.
+func (br *UserBroker) ResumeApplication(name string, log *serverlog.ServerLog) error {
+	return br.changeState(name, "resume", userdb.AppRunning, func(cs []*container.Container) error {
+		return startContainers(cs, func(c *container.Container) error {
+			return c.Start(br.ctx, log)
+		})
+	})
+}
+
+// changeState checks the operation is allowed in the current state of the
+// application, operates containers and records the new state. The state is
+// recorded even if some containers failed, so the application is consistently
+// reported in the state requested by the user.
+func (br *UserBroker) changeState(name, op string, state userdb.AppState, fn func([]*container.Container) error) error {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return err
+	}
+
+	current := app.CurrentState()
+	if !allowsTransition(op, current) {
+		return InvalidStateError{Name: name, State: current, Operation: op}
+	}
+
+	containers, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return ApplicationNotFoundError(name)
+	}
+
+	err = fn(containers)
+	if serr := br.setAppState(br.Namespace(), name, state); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func allowsTransition(op string, state userdb.AppState) bool {
+	for _, s := range stateTransitions[op] {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// setAppState records the lifecycle state of the application. Nothing is
+// done if the state is not changed. Requests are no longer held by the proxy
+// when the idle application is stopped explicitly.
+func (br *Broker) setAppState(namespace, name string, state userdb.AppState) error {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		return err
+	}
+
+	var idle bool
+	err = br.modifyApplication(user, name, func(app *userdb.Application) error {
+		if app.CurrentState() == state {
+			idle = false
+			return errUnchanged
+		}
+		idle = app.CurrentState() == userdb.AppIdle
+		app.State = state
+		app.StateChanged = time.Now()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if idle && state == userdb.AppStopped {
+		return unparkApplication(namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Application lifecycle", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "lifecycle"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("lifecycle")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	state := func() userdb.AppState {
+		apps, err := ub.GetApplications()
+		Expect(err).NotTo(HaveOccurred())
+		return apps["lifecycle"].CurrentState()
+	}
+
+	activeStates := func() []manifest.ActiveState {
+		cs, err := broker.FindAll(context.Background(), "lifecycle", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(2))
+		var states []manifest.ActiveState
+		for _, c := range cs {
+			states = append(states, c.ActiveState(context.Background()))
+		}
+		return states
+	}
+
+	It("should start and stop all containers", func() {
+		Expect(ub.StartApplication("lifecycle", nil)).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppRunning))
+		Expect(activeStates()).To(ConsistOf(manifest.StateRunning, manifest.StateRunning))
+
+		Expect(ub.StopApplication("lifecycle")).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppStopped))
+		Expect(activeStates()).To(ConsistOf(manifest.StateStopped, manifest.StateStopped))
+
+		Expect(ub.RestartApplication("lifecycle", nil)).To(BeAssignableToTypeOf(br.InvalidStateError{}))
+		Expect(ub.StartApplication("lifecycle", nil)).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppRunning))
+	})
+
+	It("should idle and resume the application", func() {
+		Expect(ub.StartApplication("lifecycle", nil)).To(Succeed())
+		Expect(ub.ResumeApplication("lifecycle", nil)).To(BeAssignableToTypeOf(br.InvalidStateError{}))
+
+		Expect(ub.IdleApplication("lifecycle")).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppIdle))
+		Expect(activeStates()).To(ConsistOf(manifest.StateStopped, manifest.StateStopped))
+
+		Expect(ub.ResumeApplication("lifecycle", nil)).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppRunning))
+		Expect(activeStates()).To(ConsistOf(manifest.StateRunning, manifest.StateRunning))
+	})
+
+	It("should wake up the idle application on request", func() {
+		Expect(ub.StartApplication("lifecycle", nil)).To(Succeed())
+		Expect(broker.WakeApplication("lifecycle-" + NAMESPACE)).To(BeAssignableToTypeOf(br.InvalidStateError{}))
+
+		Expect(ub.IdleApplication("lifecycle")).To(Succeed())
+		Expect(broker.WakeApplication("lifecycle-" + NAMESPACE)).To(Succeed())
+		Expect(state()).To(Equal(userdb.AppRunning))
+		Expect(activeStates()).To(ConsistOf(manifest.StateRunning, manifest.StateRunning))
+
+		Expect(broker.WakeApplication("unknown")).To(HaveOccurred())
+	})
+})
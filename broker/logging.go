@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+)
+
+// GetLogConfig returns the log configuration of the application containers,
+// and whether the default configuration is overridden by the application.
+func (br *UserBroker) GetLogConfig(name string) (container.LogConfig, bool, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return container.LogConfig{}, false, err
+	}
+	return container.DefaultLogConfig().Merge(toLogConfig(app.Logging)), app.Logging != nil, nil
+}
+
+// SetLogConfig overrides the log driver and options of the application
+// containers, an empty driver restores the default configuration. Docker
+// cannot change the log driver of existing containers, so the configuration
+// is applied to containers created later, such as scaled or upgraded.
+func (br *UserBroker) SetLogConfig(name string, logging container.LogConfig) error {
+	if err := logging.Validate(); err != nil {
+		return err
+	}
+
+	return br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		if logging.Driver == "" {
+			app.Logging = nil
+		} else {
+			app.Logging = &userdb.LogConfig{Driver: logging.Driver, Options: logging.Options}
+		}
+		return nil
+	})
+}
+
+// logConfigOf returns the log configuration to create containers of the
+// application, an empty configuration uses the default configuration.
+func logConfigOf(app *userdb.Application) container.LogConfig {
+	if app == nil || app.Logging == nil {
+		return container.LogConfig{}
+	}
+	return container.DefaultLogConfig().Merge(toLogConfig(app.Logging))
+}
+
+func toLogConfig(l *userdb.LogConfig) *container.LogConfig {
+	if l == nil {
+		return nil
+	}
+	return &container.LogConfig{Driver: l.Driver, Options: l.Options}
+}
@@ -0,0 +1,74 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Logging", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "logging"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("logging")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should rotate logs of containers by default", func() {
+		logging, custom, err := ub.GetLogConfig("logging")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(custom).To(BeFalse())
+		Expect(logging).To(Equal(container.DefaultLogConfig()))
+
+		cs, err := broker.FindApplications(context.Background(), "logging", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs[0].LogConfig()).To(Equal(container.DefaultLogConfig()))
+	})
+
+	It("should apply the log configuration to new containers", func() {
+		override := container.LogConfig{Driver: "json-file", Options: map[string]string{"max-file": "5"}}
+		Expect(ub.SetLogConfig("logging", override)).To(Succeed())
+
+		logging, custom, err := ub.GetLogConfig("logging")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(custom).To(BeTrue())
+		Expect(logging.Options).To(HaveKeyWithValue("max-file", "5"))
+
+		_, err = ub.ScaleApplication("logging", 2)
+		Expect(err).NotTo(HaveOccurred())
+		cs, err := broker.FindApplications(context.Background(), "logging", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).To(HaveLen(2))
+		var files []string
+		for _, c := range cs {
+			files = append(files, c.LogConfig().Options["max-file"])
+		}
+		Expect(files).To(ConsistOf("3", "5"))
+
+		Expect(ub.SetLogConfig("logging", container.LogConfig{})).To(Succeed())
+		_, custom, err = ub.GetLogConfig("logging")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(custom).To(BeFalse())
+	})
+
+	It("should reject unsupported log drivers", func() {
+		Expect(ub.SetLogConfig("logging", container.LogConfig{Driver: "journald"})).
+			To(BeAssignableToTypeOf(container.InvalidLogConfigError("")))
+	})
+})
@@ -0,0 +1,161 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	dockertypes "github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/pkg/stdcopy"
+)
+
+// Logs streams the stdout and stderr of all containers in the application
+// to the server log. Every line is prefixed with its timestamp and a label
+// identifying the container that produced it. When follow is set the stream
+// is kept open until the context is canceled or all containers stopped.
+func (br *UserBroker) Logs(name string, opts types.LogsOptions, log *serverlog.ServerLog) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	if len(cs) == 0 {
+		return ApplicationNotFoundError(name)
+	}
+
+	ctx, cancel := context.WithCancel(br.ctx)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(chan error, len(cs))
+	)
+
+	for _, c := range cs {
+		wg.Add(1)
+		go func(c *container.Container) {
+			defer wg.Done()
+			label := logLabel(c)
+			stdout := &logWriter{mu: &mu, w: log.Stdout(), label: label}
+			stderr := &logWriter{mu: &mu, w: log.Stderr(), label: label}
+			err := containerLogs(ctx, c, opts, stdout, stderr)
+			if err == nil {
+				err = stdout.Flush()
+			}
+			if err == nil {
+				err = stderr.Flush()
+			}
+			if err != nil && ctx.Err() == nil {
+				// the client has gone away or the docker daemon failed,
+				// stop streaming logs from other containers as well
+				errs <- fmt.Errorf("%s: %v", label, err)
+				cancel()
+			}
+		}(c)
+	}
+
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func containerLogs(ctx context.Context, c *container.Container, opts types.LogsOptions, stdout, stderr io.Writer) error {
+	tail := opts.Tail
+	if tail == "" {
+		tail = "all"
+	}
+
+	rc, err := c.ContainerLogs(ctx, c.ID, dockertypes.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     opts.Follow,
+		Since:      opts.Since,
+		Tail:       tail,
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = stdcopy.Copy(stdout, stderr, nil, rc)
+	return err
+}
+
+// logLabel returns a label identifying the container in the log stream.
+func logLabel(c *container.Container) string {
+	name := c.ServiceName()
+	if name == "" {
+		_, _, name, _, _ = hub.ParseTag(c.PluginTag())
+	}
+	id := c.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return name + "." + id
+}
+
+// logWriter splits the container log into lines and writes every line
+// with container label. The writes from different containers are
+// serialized by a shared mutex so lines are never interleaved.
+type logWriter struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	label string
+	buf   bytes.Buffer
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.buf.Write(p)
+	for {
+		i := bytes.IndexByte(lw.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(lw.buf.Next(i + 1))
+		if err := lw.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes remaining partial line.
+func (lw *logWriter) Flush() error {
+	if lw.buf.Len() == 0 {
+		return nil
+	}
+	line := lw.buf.String() + "\n"
+	lw.buf.Reset()
+	return lw.writeLine(line)
+}
+
+func (lw *logWriter) writeLine(line string) error {
+	// docker prefixes every line with a timestamp when requested,
+	// insert the label between the timestamp and log message
+	var timestamp string
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		timestamp, line = line[:i], line[i+1:]
+	}
+
+	if lw.w == nil {
+		return nil
+	}
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	_, err := fmt.Fprintf(lw.w, "%s %s| %s", timestamp, lw.label, line)
+	return err
+}
@@ -0,0 +1,72 @@
+package broker_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Logs", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub := broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "logs"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartApplication("logs", nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		ub.RemoveApplication("logs")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should label log lines with container", func() {
+		var stdout, stderr bytes.Buffer
+		ub := broker.NewUserBroker(&user, context.Background())
+		err := ub.Logs("logs", types.LogsOptions{}, serverlog.Encap(&stdout, &stderr))
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, out := range []string{stdout.String(), stderr.String()} {
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				if line != "" {
+					Expect(line).To(MatchRegexp(`^\S+ (mock|mockdb)\.[0-9a-f]{12}\| `))
+				}
+			}
+		}
+	})
+
+	It("should stop following logs when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			ub := broker.NewUserBroker(&user, ctx)
+			done <- ub.Logs("logs", types.LogsOptions{Follow: true}, serverlog.Discard)
+		}()
+
+		Consistently(done).ShouldNot(Receive())
+		cancel()
+		Eventually(done).Should(Receive())
+	})
+
+	It("should fail for nonexistent application", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		err := ub.Logs("nonexist", types.LogsOptions{}, serverlog.Discard)
+		Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+	})
+})
@@ -0,0 +1,266 @@
+package broker
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	dockertypes "github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/logstore"
+	"github.com/cloudway/platform/pkg/stdcopy"
+)
+
+// The interval to find containers whose logs are not shipped.
+const logShipInterval = 10 * time.Second
+
+// Log entries are written to the store in batches of the maximum size, or
+// when the flush interval elapsed.
+const (
+	logBatchSize     = 500
+	logFlushInterval = time.Second
+)
+
+// The interval to expire log entries.
+const logExpireInterval = time.Hour
+
+// SearchLogs searches the aggregated logs of the application.
+func (br *UserBroker) SearchLogs(name string, q logstore.Query) ([]*logstore.Entry, error) {
+	if br.LogStore == nil {
+		return nil, LogSearchDisabledError{}
+	}
+	if q.Severity != "" && !logstore.ValidSeverity(q.Severity) {
+		return nil, InvalidLogQueryError("Invalid log severity: " + q.Severity)
+	}
+	if _, err := br.getApplication(name); err != nil {
+		return nil, err
+	}
+	return br.LogStore.Search(br.Namespace(), name, q)
+}
+
+// logShipper follows logs of running application containers and writes
+// log entries to the store.
+type logShipper struct {
+	*Broker
+	entries chan *logstore.Entry
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	following map[string]bool
+	shipped   map[string]time.Time
+}
+
+// RunLogShipper ships logs of application containers to the log store until
+// the stop channel is closed. Logs written before the shipper started are
+// not shipped. Returns immediately if log aggregation is not enabled.
+func (br *Broker) RunLogShipper(stop <-chan bool) {
+	if br.LogStore == nil {
+		return
+	}
+	retention, err := logstore.Retention()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	s := &logShipper{
+		Broker:    br,
+		entries:   make(chan *logstore.Entry, logBatchSize),
+		following: make(map[string]bool),
+		shipped:   make(map[string]time.Time),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.write()
+		close(done)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := time.Now()
+	var expired time.Time
+
+	ticker := time.NewTicker(logShipInterval)
+	defer ticker.Stop()
+	for {
+		s.followAll(ctx, started)
+		if time.Since(expired) > logExpireInterval {
+			expired = time.Now()
+			if err := br.LogStore.Expire(expired.Add(-retention)); err != nil {
+				logrus.WithError(err).Error("Failed to expire logs")
+			}
+		}
+
+		select {
+		case <-stop:
+			cancel()
+			s.wg.Wait()
+			close(s.entries)
+			<-done
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// followAll starts following logs of running containers not followed yet.
+// Logs of restarted containers are followed since the last shipped entry.
+func (s *logShipper) followAll(ctx context.Context, started time.Time) {
+	cs, err := s.FindInNamespace(ctx, "")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to find containers for log shipping")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, c := range cs {
+		seen[c.ID] = true
+		if !c.State.Running || s.following[c.ID] {
+			continue
+		}
+		since, ok := s.shipped[c.ID]
+		if !ok {
+			since = started
+		}
+		s.following[c.ID] = true
+		s.wg.Add(1)
+		go s.follow(ctx, c, since)
+	}
+
+	// forget removed containers
+	for id := range s.shipped {
+		if !seen[id] && !s.following[id] {
+			delete(s.shipped, id)
+		}
+	}
+}
+
+// follow ships logs of the container until the container stopped or the
+// shipper is stopped.
+func (s *logShipper) follow(ctx context.Context, c *container.Container, since time.Time) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.following, c.ID)
+		s.mu.Unlock()
+	}()
+
+	rc, err := c.ContainerLogs(ctx, c.ID, dockertypes.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     true,
+		Since:      since.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.WithError(err).Warnf("Failed to ship logs of container %s", c.ID[:12])
+		}
+		return
+	}
+	defer rc.Close()
+
+	label := logLabel(c)
+	stdout := &shipWriter{s: s, c: c, label: label, stream: "stdout", since: since}
+	stderr := &shipWriter{s: s, c: c, label: label, stream: "stderr", since: since}
+	stdcopy.Copy(stdout, stderr, nil, rc)
+}
+
+// ship records the time of the last entry shipped from the container, and
+// queues the entry to be written.
+func (s *logShipper) ship(id string, e *logstore.Entry) {
+	s.mu.Lock()
+	if e.Time.After(s.shipped[id]) {
+		s.shipped[id] = e.Time
+	}
+	s.mu.Unlock()
+	s.entries <- e
+}
+
+// write entries to the store in batches until the entries channel closed.
+func (s *logShipper) write() {
+	batch := make([]*logstore.Entry, 0, logBatchSize)
+	flush := func() {
+		if len(batch) != 0 {
+			if err := s.LogStore.Write(batch); err != nil {
+				logrus.WithError(err).Errorf("Failed to write %d log entries", len(batch))
+			}
+			batch = batch[:0]
+		}
+	}
+
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-s.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= logBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// shipWriter splits the container log stream into log entries.
+type shipWriter struct {
+	s      *logShipper
+	c      *container.Container
+	label  string
+	stream string
+	since  time.Time
+	buf    bytes.Buffer
+}
+
+func (w *shipWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		w.entry(string(w.buf.Next(i + 1)))
+	}
+	return len(p), nil
+}
+
+func (w *shipWriter) entry(line string) {
+	line = strings.TrimRight(line, "\r\n")
+
+	// docker prefixes every line with a timestamp when requested
+	var t time.Time
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			t, line = ts, line[i+1:]
+		}
+	}
+	if t.IsZero() {
+		t = time.Now()
+	} else if !t.After(w.since) {
+		// already shipped before the container was restarted
+		return
+	}
+
+	w.s.ship(w.c.ID, &logstore.Entry{
+		Time:      t,
+		Namespace: w.c.Namespace,
+		App:       w.c.Name,
+		Container: w.label,
+		Stream:    w.stream,
+		Severity:  logstore.ParseSeverity(w.stream, line),
+		Message:   line,
+	})
+}
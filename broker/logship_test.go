@@ -0,0 +1,76 @@
+package broker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/logstore"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Log search", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var (
+		ub  *br.UserBroker
+		dir string
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "logsearch"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("logsearch")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+		broker.LogStore = nil
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	})
+
+	It("should fail if log aggregation is not enabled", func() {
+		_, err := ub.SearchLogs("logsearch", logstore.Query{})
+		Expect(err).To(BeAssignableToTypeOf(br.LogSearchDisabledError{}))
+	})
+
+	It("should search logs of the application", func() {
+		var err error
+		dir, err = ioutil.TempDir("", "logsearch")
+		Expect(err).NotTo(HaveOccurred())
+		broker.LogStore, err = logstore.NewFileStore(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		now := time.Now()
+		Expect(broker.LogStore.Write([]*logstore.Entry{
+			{Time: now.Add(-time.Minute), Namespace: NAMESPACE, App: "logsearch", Severity: logstore.SeverityInfo, Message: "started"},
+			{Time: now, Namespace: NAMESPACE, App: "logsearch", Severity: logstore.SeverityError, Message: "request failed"},
+		})).To(Succeed())
+
+		entries, err := ub.SearchLogs("logsearch", logstore.Query{Text: "failed"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Severity).To(Equal(logstore.SeverityError))
+
+		_, err = ub.SearchLogs("logsearch", logstore.Query{Severity: "verbose"})
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidLogQueryError("")))
+
+		Expect(ub.RemoveApplication("logsearch")).To(Succeed())
+		entries, err = broker.LogStore.Search(NAMESPACE, "logsearch", logstore.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+})
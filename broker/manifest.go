@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// applyManifest makes the deployment reconcile the application with the
+// manifest in the repository. Declared services are created and declared
+// environment variables are set before the repository is distributed, so
+// the new code finds them in place, and the declared migration is run once
+// for the deployment. The returned function scales the application to the
+// declared scaling after the deployment succeeded.
+func (br *UserBroker) applyManifest(name string, opts *container.DeployOptions, log *serverlog.ServerLog) func(error) error {
+	opts.Migrate = true
+	if opts.Reconcile != nil {
+		return func(err error) error { return err }
+	}
+
+	var scaling int
+	opts.Reconcile = func(app *manifest.Application) error {
+		scaling = app.Scaling
+		return br.reconcile(name, app, log)
+	}
+
+	return func(err error) error {
+		if err != nil || scaling == 0 {
+			return err
+		}
+		cs, err := br.ScaleApplication(name, scaling)
+		if err == nil && len(cs) != 0 {
+			fmt.Fprintf(log, "Scaled to %d containers as declared in %s\n", scaling, manifest.AppManifestFile)
+			err = br.StartContainers(br.ctx, cs, log)
+		}
+		return err
+	}
+}
+
+// reconcile creates services and sets environment variables declared in the
+// application manifest. Existing services are kept even if not declared.
+func (br *UserBroker) reconcile(name string, app *manifest.Application, log *serverlog.ServerLog) error {
+	containers, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+
+	var frameworks []*container.Container
+	services := make(map[string]bool)
+	for _, c := range containers {
+		if c.Category().IsFramework() {
+			frameworks = append(frameworks, c)
+		} else {
+			services[c.ServiceName()] = true
+		}
+	}
+
+	if app.Framework != "" && len(frameworks) != 0 {
+		_, _, declared, _, err := hub.ParseTag(app.Framework)
+		if err != nil {
+			return err
+		}
+		_, _, actual, _, _ := hub.ParseTag(frameworks[0].PluginTag())
+		if declared != actual {
+			return FrameworkMismatchError{Name: name, Declared: declared, Actual: actual}
+		}
+	}
+
+	var missing, tags []string
+	for service := range app.Services {
+		if !services[service] {
+			missing = append(missing, service)
+		}
+	}
+	sort.Strings(missing)
+	for _, service := range missing {
+		tags = append(tags, service+"="+app.Services[service])
+	}
+	if len(tags) != 0 {
+		fmt.Fprintf(log, "Creating services declared in %s: %s\n", manifest.AppManifestFile, strings.Join(missing, ", "))
+		cs, err := br.CreateServices(container.CreateOptions{Name: name, Log: log}, tags)
+		if err != nil {
+			return err
+		}
+		if err = br.StartContainers(br.ctx, cs, log); err != nil {
+			return err
+		}
+	}
+
+	if len(app.Env) == 0 {
+		return nil
+	}
+	for _, c := range frameworks {
+		env, err := c.GetenvAll(br.ctx)
+		if err != nil {
+			return err
+		}
+		changed := make(map[string]string)
+		for k, v := range app.Env {
+			if env[k] != v {
+				changed[k] = v
+			}
+		}
+		if err = c.SetenvAll(br.ctx, changed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
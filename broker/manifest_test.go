@@ -0,0 +1,95 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Manifest", func() {
+	var (
+		ctx  = context.Background()
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		ub   *br.UserBroker
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, ctx)
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartApplication("test", nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("test")
+		broker.RemoveUser(TESTUSER)
+	})
+
+	var archive = func(content string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		tw.WriteHeader(&tar.Header{Name: manifest.AppManifestFile, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+		tw.Close()
+		zw.Close()
+		return &buf
+	}
+
+	var containers = func(cat manifest.Category) []*container.Container {
+		cs, err := broker.FindAll(ctx, "test", NAMESPACE)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		var res []*container.Container
+		for _, c := range cs {
+			if c.Category() == cat {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+
+	It("should reconcile application with the manifest", func() {
+		content := "Services:\n  db: mockdb\nEnv:\n  APP_MODE: production\nScaling: 2\n"
+		Expect(ub.Upload("test", archive(content), true, container.DeployOptions{}, nil)).To(Succeed())
+
+		services := containers(manifest.Service)
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].ServiceName()).To(Equal("db"))
+
+		frameworks := containers(manifest.Framework)
+		Expect(frameworks).To(HaveLen(2))
+		for _, c := range frameworks {
+			Expect(c.Getenv(ctx, "APP_MODE")).To(Equal("production"))
+		}
+
+		// existing services are kept and the scaling is left as is
+		content = "Env:\n  APP_MODE: staging\n"
+		Expect(ub.Upload("test", archive(content), true, container.DeployOptions{}, nil)).To(Succeed())
+		Expect(containers(manifest.Service)).To(HaveLen(1))
+		frameworks = containers(manifest.Framework)
+		Expect(frameworks).To(HaveLen(2))
+		for _, c := range frameworks {
+			Expect(c.Getenv(ctx, "APP_MODE")).To(Equal("staging"))
+		}
+	})
+
+	It("should reject changing the framework", func() {
+		err := ub.Upload("test", archive("Framework: php\n"), true, container.DeployOptions{}, nil)
+		Expect(err).To(Equal(br.FrameworkMismatchError{Name: "test", Declared: "php", Actual: "mock"}))
+	})
+
+	It("should reject invalid manifest", func() {
+		err := ub.Upload("test", archive("Scaling: -1\n"), true, container.DeployOptions{}, nil)
+		Expect(err).To(BeAssignableToTypeOf(manifest.InvalidAppManifestError("")))
+	})
+})
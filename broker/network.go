@@ -0,0 +1,132 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/errors"
+)
+
+// GetNetworkGrants returns names of applications granted access to services
+// of the application, and applications whose services are accessible from
+// the application.
+func (br *UserBroker) GetNetworkGrants(name string) (grants, granted []string, err error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app.NetworkGrants, grantedNetworks(br.User.Basic().Applications, name), nil
+}
+
+// GrantNetworkAccess grants framework containers of another application in
+// the namespace access to services of the application, by connecting them
+// to the application network.
+func (br *UserBroker) GrantNetworkAccess(name, grantee string) error {
+	if name == grantee {
+		return InvalidNetworkGrantError("An application cannot grant network access to itself")
+	}
+
+	app, err := br.getApplication(name)
+	if err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[grantee] == nil {
+		return ApplicationNotFoundError(grantee)
+	}
+	for _, g := range app.NetworkGrants {
+		if g == grantee {
+			return nil
+		}
+	}
+
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		for _, g := range app.NetworkGrants {
+			if g == grantee {
+				return errUnchanged
+			}
+		}
+		app.NetworkGrants = append(app.NetworkGrants, grantee)
+		sort.Strings(app.NetworkGrants)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return br.ConnectNetworks(br.ctx, grantee, user.Namespace, grantedNetworks(user.Applications, grantee))
+}
+
+// RevokeNetworkAccess revokes network access to services of the application
+// granted to another application.
+func (br *UserBroker) RevokeNetworkAccess(name, grantee string) error {
+	err := br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		grants := removeGrant(app.NetworkGrants, grantee)
+		if len(grants) == len(app.NetworkGrants) {
+			return InvalidNetworkGrantError(fmt.Sprintf("Application '%s' is not granted network access to '%s'", grantee, name))
+		}
+		app.NetworkGrants = grants
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Applications[grantee] == nil {
+		return nil
+	}
+	return br.ConnectNetworks(br.ctx, grantee, user.Namespace, grantedNetworks(user.Applications, grantee))
+}
+
+// removeAppNetwork disconnects applications granted access from the network
+// of the removed application, then removes the network. Grants to the removed
+// application are removed from the application records by the caller.
+func (br *UserBroker) removeAppNetwork(name string, apps map[string]*userdb.Application) error {
+	var errors errors.Errors
+	namespace := br.Namespace()
+
+	grantees := apps[name].NetworkGrants
+	apps[name].NetworkGrants = nil
+	for _, app := range apps {
+		app.NetworkGrants = removeGrant(app.NetworkGrants, name)
+	}
+
+	for _, grantee := range grantees {
+		if apps[grantee] != nil {
+			errors.Add(br.ConnectNetworks(br.ctx, grantee, namespace, grantedNetworks(apps, grantee)))
+		}
+	}
+	if container.NetworkIsolation() {
+		errors.Add(br.RemoveAppNetwork(br.ctx, name, namespace))
+	}
+	return errors.Err()
+}
+
+// grantedNetworks returns names of applications that granted the application
+// access to their services.
+func grantedNetworks(apps map[string]*userdb.Application, name string) []string {
+	var granted []string
+	for other, app := range apps {
+		for _, g := range app.NetworkGrants {
+			if g == name {
+				granted = append(granted, other)
+				break
+			}
+		}
+	}
+	sort.Strings(granted)
+	return granted
+}
+
+func removeGrant(grants []string, name string) []string {
+	var result []string
+	for _, g := range grants {
+		if g != name {
+			result = append(result, g)
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Network", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		config.Set("network.isolation", "true")
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "db"}, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "web"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("web")
+		ub.RemoveApplication("db")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+		config.Remove("network.isolation")
+	})
+
+	It("should isolate services in the application network", func() {
+		services, err := broker.FindService(context.Background(), "db", NAMESPACE, "mockdb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].NetworkSettings.Networks).To(HaveKey(container.AppNetworkName("db", NAMESPACE)))
+		Expect(services[0].NetworkSettings.Networks).To(HaveLen(1))
+
+		cs, err := broker.FindApplications(context.Background(), "web", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs[0].NetworkSettings.Networks).NotTo(HaveKey(container.AppNetworkName("db", NAMESPACE)))
+	})
+
+	It("should connect granted applications to the application network", func() {
+		Expect(ub.GrantNetworkAccess("db", "web")).To(Succeed())
+
+		grants, granted, err := ub.GetNetworkGrants("db")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grants).To(Equal([]string{"web"}))
+		Expect(granted).To(BeEmpty())
+
+		_, granted, err = ub.GetNetworkGrants("web")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(granted).To(Equal([]string{"db"}))
+
+		cs, err := broker.FindApplications(context.Background(), "web", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs[0].GrantedNetworks()).To(Equal([]string{"db"}))
+
+		Expect(ub.RevokeNetworkAccess("db", "web")).To(Succeed())
+		cs, err = broker.FindApplications(context.Background(), "web", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs[0].GrantedNetworks()).To(BeEmpty())
+	})
+
+	It("should remove grants of the removed application", func() {
+		Expect(ub.GrantNetworkAccess("db", "web")).To(Succeed())
+		Expect(ub.RemoveApplication("web")).To(Succeed())
+
+		grants, _, err := ub.GetNetworkGrants("db")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(grants).To(BeEmpty())
+	})
+
+	It("should reject invalid grants", func() {
+		Expect(ub.GrantNetworkAccess("db", "db")).To(BeAssignableToTypeOf(br.InvalidNetworkGrantError("")))
+		Expect(ub.GrantNetworkAccess("db", "nonexist")).To(BeAssignableToTypeOf(br.ApplicationNotFoundError("")))
+		Expect(ub.RevokeNetworkAccess("db", "web")).To(BeAssignableToTypeOf(br.InvalidNetworkGrantError("")))
+	})
+})
@@ -0,0 +1,140 @@
+package broker
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/mailer"
+)
+
+// The time limit to reset password with a reset token.
+const passwordResetTimeout = time.Hour
+
+type InvalidResetTokenError struct{}
+
+func (e InvalidResetTokenError) Error() string {
+	return "Invalid or expired password reset token"
+}
+
+func (e InvalidResetTokenError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidResetTokenError) ErrorCode() string {
+	return "invalid_reset_token"
+}
+
+// RequestPasswordReset sends a password reset token to the user by email.
+// The reset URL, if not empty, is followed by the token in the email. No
+// error is returned for unknown users to not reveal registered users.
+func (br *Broker) RequestPasswordReset(email, resetURL string) error {
+	if br.Mail == nil {
+		return fmt.Errorf("Password reset is not available")
+	}
+
+	var user userdb.BasicUser
+	err := br.Users.Find(strings.ToLower(strings.TrimSpace(email)), &user)
+	if userdb.IsUserNotFound(err) {
+		logrus.Debugf("Password reset requested for unknown user %s", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Inactive {
+		logrus.Debugf("Password reset requested for inactive user %s", user.Name)
+		return nil
+	}
+
+	token, err := br.signResetToken(&user, time.Now().Add(passwordResetTimeout))
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("A password reset was requested for your account %s. ", user.Name)
+	if resetURL != "" {
+		body += fmt.Sprintf("Please visit the following link within %v to set a new password:\n\n%s%s\n", passwordResetTimeout, resetURL, token)
+	} else {
+		body += fmt.Sprintf("Please run the following command within %v to set a new password:\n\n    cwcli passwd --reset %s\n", passwordResetTimeout, token)
+	}
+	body += "\nIf you didn't request a password reset, you can ignore this email.\n"
+
+	return br.Mail.Send(&mailer.Message{
+		To:      []string{user.Name},
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+// ResetPassword sets a new password of the user identified by the reset
+// token. The token is invalidated once the password is changed.
+func (br *Broker) ResetPassword(token, password string) error {
+	i := strings.IndexByte(token, '.')
+	if i == -1 {
+		return InvalidResetTokenError{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return InvalidResetTokenError{}
+	}
+	fields := strings.SplitN(string(payload), "\n", 2)
+	if len(fields) != 2 {
+		return InvalidResetTokenError{}
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return InvalidResetTokenError{}
+	}
+
+	var user userdb.BasicUser
+	if err = br.Users.Find(fields[0], &user); err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = InvalidResetTokenError{}
+		}
+		return err
+	}
+
+	expected, err := br.signResetToken(&user, time.Unix(expires, 0))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(token), []byte(expected)) {
+		return InvalidResetTokenError{}
+	}
+	if len(password) < 4 {
+		return InvalidRegistrationError("The password must have at least 4 characters")
+	}
+	return br.Users.SetPassword(user.Name, password)
+}
+
+// signResetToken creates a token containing the user name and expiration
+// time signed by a server secret. The signature covers the current hashed
+// password, so that the token cannot be used after password changed.
+func (br *Broker) signResetToken(user *userdb.BasicUser, expires time.Time) (string, error) {
+	secret, err := br.Users.GetSecret("password-reset", func() []byte {
+		b := make([]byte, 32)
+		rand.Read(b)
+		return b
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := user.Name + "\n" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	mac.Write(user.Password)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,75 @@
+package broker_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/mailer"
+)
+
+var _ = Describe("Password", func() {
+	const resetURL = "http://localhost/reset?token="
+
+	var mail *captureMailer
+	var savedMailer mailer.Mailer
+
+	BeforeEach(func() {
+		mail = &captureMailer{}
+		savedMailer, broker.Mail = broker.Mail, mail
+		user := userdb.BasicUser{Name: TESTUSER}
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		broker.Mail = savedMailer
+		broker.RemoveUser(TESTUSER)
+	})
+
+	token := func() string {
+		Expect(mail.messages).To(HaveLen(1))
+		body := mail.messages[0].Body
+		i := strings.Index(body, resetURL)
+		Expect(i).NotTo(Equal(-1))
+		return strings.Fields(body[i+len(resetURL):])[0]
+	}
+
+	It("should reset password with token", func() {
+		Expect(broker.RequestPasswordReset(TESTUSER, resetURL)).To(Succeed())
+		Expect(mail.messages[0].To).To(Equal([]string{TESTUSER}))
+		Expect(broker.ResetPassword(token(), "newpass")).To(Succeed())
+
+		_, err := broker.Users.Authenticate(TESTUSER, "newpass")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not reuse a reset token", func() {
+		Expect(broker.RequestPasswordReset(TESTUSER, resetURL)).To(Succeed())
+		t := token()
+		Expect(broker.ResetPassword(t, "newpass")).To(Succeed())
+		Expect(broker.ResetPassword(t, "another")).To(BeAssignableToTypeOf(br.InvalidResetTokenError{}))
+	})
+
+	It("should reject a tampered token", func() {
+		Expect(broker.RequestPasswordReset(TESTUSER, resetURL)).To(Succeed())
+		Expect(broker.ResetPassword(token()+"x", "newpass")).To(BeAssignableToTypeOf(br.InvalidResetTokenError{}))
+		Expect(broker.ResetPassword("invalid", "newpass")).To(BeAssignableToTypeOf(br.InvalidResetTokenError{}))
+	})
+
+	It("should not reveal unknown users", func() {
+		Expect(broker.RequestPasswordReset("nobody@example.com", resetURL)).To(Succeed())
+		Expect(mail.messages).To(BeEmpty())
+	})
+
+	It("should verify the current password when changing password", func() {
+		err := broker.Users.ChangePassword(TESTUSER, "wrong", "newpass")
+		Expect(err).To(BeAssignableToTypeOf(userdb.IncorrectPasswordError("")))
+		Expect(broker.Users.ChangePassword(TESTUSER, "test", "newpass")).To(Succeed())
+
+		_, err = broker.Users.Authenticate(TESTUSER, "newpass")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -5,10 +5,19 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/cron"
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/files"
 	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/scanner"
 )
 
 // GetInstalledPlugins returns all installed plugins, include user and system plugins.
@@ -39,15 +48,35 @@ func (br *UserBroker) GetInstalledPlugins(category manifest.Category) (plugins [
 	return plugins
 }
 
-// GetUserPlugins returns a list of user defined plugins.
+// GetUserPlugins returns a list of user defined plugins, including plugins
+// in other namespaces shared with the user.
 func (br *UserBroker) GetUserPlugins(category manifest.Category) (plugins []*manifest.Plugin) {
 	if namespace := br.Namespace(); namespace != "" {
 		plugins = br.Hub.ListPlugins(namespace, category)
+		for _, p := range br.Hub.SharedPlugins(namespace) {
+			if category == "" || category == p.Category {
+				plugins = append(plugins, p)
+			}
+		}
 		sort.Sort(byDisplayName(plugins))
 	}
 	return
 }
 
+// SearchPlugins searches installed plugins matching the query, including
+// user and system plugins unless only user defined plugins are requested.
+// Returns the total number of matched plugins before pagination.
+func (br *UserBroker) SearchPlugins(q hub.Query, userDefined bool) ([]*manifest.Plugin, int, error) {
+	var namespaces []string
+	if !userDefined {
+		namespaces = append(namespaces, "")
+	}
+	if namespace := br.Namespace(); namespace != "" {
+		namespaces = append(namespaces, namespace)
+	}
+	return br.Hub.Search(namespaces, q)
+}
+
 type byDisplayName []*manifest.Plugin
 
 func (a byDisplayName) Len() int           { return len(a) }
@@ -78,7 +107,7 @@ func (br *UserBroker) getPluginInfoWithNames(tag string) (service string, plugin
 	if namespace != "" {
 		// get the shared user defined plugin
 		plugin, err = br.Hub.GetPluginInfo(cleanTag)
-		if err == nil && !plugin.Shared && namespace != br.Namespace() {
+		if err == nil && !br.Hub.CanAccess(namespace, name, br.Namespace()) {
 			err = fmt.Errorf("%s: plugin not found", cleanTag)
 		}
 	} else {
@@ -99,24 +128,122 @@ func (br *UserBroker) getPluginInfoWithNames(tag string) (service string, plugin
 	return
 }
 
-// InstallPlugin installs a user defined plugin.
-func (br *UserBroker) InstallPlugin(ar io.Reader) error {
+// InstallPlugin installs a user defined plugin. The base image of the
+// plugin is built if the plugin ships with a Dockerfile, with the given
+// build arguments, and the build output is written to the log.
+func (br *UserBroker) InstallPlugin(ar io.Reader, buildArgs map[string]string, log *serverlog.ServerLog) error {
 	if br.Namespace() == "" {
 		return NoNamespaceError(br.User.Basic().Name)
 	}
 
-	tempfile, err := ioutil.TempFile("", "plugin")
+	dir, err := extractPlugin(ar)
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(dir)
+
+	if err = br.BuildPluginImage(br.ctx, br.Namespace(), dir, buildArgs, log); err != nil {
+		return err
+	}
+	br.scanPlugin(dir, log)
+	return br.Hub.InstallPlugin(br.Namespace(), dir)
+}
+
+// scanPlugin scans the plugin image and saves the report with the plugin.
+// A plugin failed to scan is installed without report, so an unavailable
+// scanner doesn't prevent plugins from being installed.
+func (br *UserBroker) scanPlugin(dir string, log *serverlog.ServerLog) {
+	// never trust a report shipped with the plugin archive
+	os.Remove(filepath.Join(dir, scanner.ReportEntry))
+
+	if br.Scanner == nil {
+		return
+	}
+	if _, err := scanner.ScanPlugin(br.ctx, br.Scanner, dir, log); err != nil {
+		logrus.WithError(err).Warn("Failed to scan plugin image")
+		fmt.Fprintf(log, "Failed to scan plugin image: %v\n", err)
+	}
+}
+
+// GetPluginScan returns the vulnerability scan report of the plugin image.
+func (br *UserBroker) GetPluginScan(tag string) (*scanner.Report, error) {
+	plugin, err := br.GetPluginInfo(tag)
+	if err != nil {
+		return nil, err
+	}
+	report, err := scanner.LoadReport(plugin.Path)
+	if err == nil && report == nil {
+		err = PluginNotScannedError(plugin.Tag)
+	}
+	return report, err
+}
+
+// ValidatePlugin checks the plugin archive before installation, returns
+// diagnostics of problems found in the plugin.
+func (br *UserBroker) ValidatePlugin(ar io.Reader) (manifest.Diagnostics, error) {
+	dir, err := extractPlugin(ar)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	plugin, diags := manifest.Lint(dir)
+	if plugin == nil {
+		return diags, nil
+	}
+
+	// checks depending on the container and cron packages, which are not
+	// available to the manifest package
+	if plugin.HealthCheck != nil {
+		if err = container.ValidateHealthCheck(plugin.HealthCheck); err != nil {
+			diags.Errorf("Health-Check", "%v", err)
+		}
+	}
+	if err = container.ValidateResources(plugin); err != nil {
+		diags.Errorf("Resources", "%v", err)
+	}
+	if err = container.ValidateVolumes(plugin); err != nil {
+		diags.Errorf("Volumes", "%v", err)
+	}
+	for i, job := range plugin.Cron {
+		if job.Schedule == "" {
+			continue
+		}
+		if _, err = cron.Parse(job.Schedule); err != nil {
+			diags.Errorf(fmt.Sprintf("Cron[%d]", i), "%v", err)
+		}
+	}
+	for _, req := range plugin.Requires {
+		if _, err = br.GetPluginInfo(req); err != nil {
+			diags.Warnf("Requires", "required plugin %s is not available", req)
+		}
+	}
+	return diags, nil
+}
+
+// extractPlugin extracts the plugin archive into a temporary directory.
+func extractPlugin(ar io.Reader) (dir string, err error) {
+	tempfile, err := ioutil.TempFile("", "plugin")
+	if err != nil {
+		return "", err
+	}
 	defer os.Remove(tempfile.Name())
 
 	_, err = io.Copy(tempfile, ar)
 	tempfile.Close()
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	dir, err = ioutil.TempDir("", "plugin")
+	if err != nil {
+		return "", err
 	}
-	return br.Hub.InstallPlugin(br.Namespace(), tempfile.Name())
+	if err = files.ExtractFiles(tempfile.Name(), dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
 }
 
 // RemovePlugin removes a user defined plugin.
@@ -126,3 +253,159 @@ func (br *UserBroker) RemovePlugin(tag string) error {
 	}
 	return br.Hub.RemovePlugin(br.Namespace() + "/" + tag)
 }
+
+// GetPluginAccess returns access control of a user defined plugin.
+func (br *UserBroker) GetPluginAccess(name string) (*hub.Access, error) {
+	if br.Namespace() == "" {
+		return nil, NoNamespaceError(br.User.Basic().Name)
+	}
+	return br.Hub.GetPluginAccess(br.Namespace(), name)
+}
+
+// SetPluginVisibility changes visibility of a user defined plugin.
+func (br *UserBroker) SetPluginVisibility(name string, visibility hub.Visibility) error {
+	if br.Namespace() == "" {
+		return NoNamespaceError(br.User.Basic().Name)
+	}
+	return br.Hub.SetPluginVisibility(br.Namespace(), name, visibility)
+}
+
+// GrantPluginAccess grants another namespace access to a user defined plugin.
+func (br *UserBroker) GrantPluginAccess(name, namespace string) error {
+	if br.Namespace() == "" {
+		return NoNamespaceError(br.User.Basic().Name)
+	}
+	if namespace == br.Namespace() {
+		return nil
+	}
+	if !namespacePattern.MatchString(namespace) {
+		return NamespaceNotFoundError(namespace)
+	}
+	if _, err := br.Users.FindByNamespace(namespace); err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = NamespaceNotFoundError(namespace)
+		}
+		return err
+	}
+	return br.Hub.GrantPluginAccess(br.Namespace(), name, namespace)
+}
+
+// RevokePluginAccess revokes access of another namespace to a user defined
+// plugin.
+func (br *UserBroker) RevokePluginAccess(name, namespace string) error {
+	if br.Namespace() == "" {
+		return NoNamespaceError(br.User.Basic().Name)
+	}
+	return br.Hub.RevokePluginAccess(br.Namespace(), name, namespace)
+}
+
+// UpgradePlugin upgrades applications using another version of the plugin
+// to the given version. If an application name is given then only the
+// application is upgraded. The upgrade stops at the first application
+// failed to upgrade, which is restored to the previous version. Returns
+// names of upgraded applications.
+func (br *UserBroker) UpgradePlugin(tag, name string, log *serverlog.ServerLog) (upgraded []string, err error) {
+	if err = br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	plugin, err := br.GetPluginInfo(tag)
+	if err != nil {
+		return nil, err
+	}
+	if err = scanner.Check(plugin); err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	if name != "" && user.Applications[name] == nil {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	var names []string
+	for appName := range user.Applications {
+		if name == "" || name == appName {
+			names = append(names, appName)
+		}
+	}
+	sort.Strings(names)
+
+	for _, appName := range names {
+		ok, err := br.DockerClient.UpgradePlugin(br.ctx, appName, user.Namespace, plugin, log)
+		if err != nil {
+			return upgraded, fmt.Errorf("%s: %v", appName, err)
+		}
+		if !ok {
+			continue
+		}
+
+		err = br.modifyApplication(br.User, appName, func(app *userdb.Application) error {
+			for i, t := range app.Plugins {
+				if samePlugin(t, plugin.Tag) {
+					app.Plugins[i] = plugin.Tag
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return upgraded, err
+		}
+		upgraded = append(upgraded, appName)
+	}
+	return upgraded, nil
+}
+
+// ReloadPlugin replaces the manifest of a user defined plugin and reloads
+// it in containers of applications using the plugin, without rebuilding
+// the containers. Returns names of reloaded applications.
+func (br *UserBroker) ReloadPlugin(tag string, data []byte, log *serverlog.ServerLog) (reloaded []string, err error) {
+	if br.Namespace() == "" {
+		return nil, NoNamespaceError(br.User.Basic().Name)
+	}
+	if err = br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	plugin, err := br.Hub.UpdateManifest(br.Namespace()+"/"+tag, data)
+	if err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	var names []string
+	for name := range user.Applications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cs, err := br.FindAll(br.ctx, name, user.Namespace)
+		if err != nil {
+			return reloaded, err
+		}
+
+		found := false
+		for _, c := range cs {
+			if c.PluginTag() != plugin.Tag {
+				continue
+			}
+			fmt.Fprintf(log, "Reloading %s in %s\n", plugin.Name, c.FQDN())
+			if err = c.ReloadManifest(br.ctx, data, log); err != nil {
+				return reloaded, fmt.Errorf("%s: %v", name, err)
+			}
+			found = true
+		}
+		if found {
+			reloaded = append(reloaded, name)
+		}
+	}
+	return reloaded, nil
+}
+
+// samePlugin returns true if two tags refer to the same plugin, regardless
+// of versions.
+func samePlugin(tag1, tag2 string) bool {
+	_, ns1, name1, _, err1 := hub.ParseTag(tag1)
+	_, ns2, name2, _, err2 := hub.ParseTag(tag2)
+	return err1 == nil && err2 == nil && ns1 == ns2 && name1 == name2
+}
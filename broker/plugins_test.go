@@ -161,7 +161,7 @@ var _ = Describe("Plugins", func() {
 		}
 		tw.Close()
 
-		return br.InstallPlugin(buf)
+		return br.InstallPlugin(buf, nil, nil)
 	}
 
 	var getTags = func(plugins []*manifest.Plugin) []string {
@@ -328,6 +328,77 @@ var _ = Describe("Plugins", func() {
 		})
 	})
 
+	Describe("Plugin Access", func() {
+		BeforeEach(installTestPlugins)
+
+		It("should be private by default", func() {
+			access, err := br.GetPluginAccess("test")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(access.Visibility).Should(Equal(hub.Private))
+			Ω(access.Grants).Should(BeEmpty())
+		})
+
+		It("should be public if shared in manifest", func() {
+			access, err := broker.Hub.GetPluginAccess("other", "shared")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(access.Visibility).Should(Equal(hub.Public))
+		})
+
+		It("should see plugin shared with the namespace", func() {
+			Ω(broker.Hub.GrantPluginAccess("other", "mock", NAMESPACE)).Should(Succeed())
+
+			plugin, err := br.GetPluginInfo("other/mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.Vendor).Should(Equal("other"))
+			Ω(getTags(br.GetUserPlugins(""))).Should(ConsistOf("mock", "mock", "test"))
+
+			access, err := broker.Hub.GetPluginAccess("other", "mock")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(access.Visibility).Should(Equal(hub.Shared))
+			Ω(access.Grants).Should(Equal([]string{NAMESPACE}))
+		})
+
+		It("should not see plugin after access revoked", func() {
+			Ω(broker.Hub.GrantPluginAccess("other", "mock", NAMESPACE)).Should(Succeed())
+			Ω(broker.Hub.RevokePluginAccess("other", "mock", NAMESPACE)).Should(Succeed())
+
+			_, err := br.GetPluginInfo("other/mock")
+			Ω(err).Should(HaveOccurred())
+			Ω(getTags(br.GetUserPlugins(""))).Should(ConsistOf("mock", "test"))
+		})
+
+		It("should not see plugin made private", func() {
+			Ω(broker.Hub.SetPluginVisibility("other", "shared", hub.Private)).Should(Succeed())
+			_, err := br.GetPluginInfo("other/shared")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should see plugin made public", func() {
+			Ω(broker.Hub.SetPluginVisibility("other", "mock", hub.Public)).Should(Succeed())
+			_, err := br.GetPluginInfo("other/mock")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should change visibility of user defined plugin", func() {
+			Ω(br.SetPluginVisibility("test", hub.Public)).Should(Succeed())
+			access, err := br.GetPluginAccess("test")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(access.Visibility).Should(Equal(hub.Public))
+
+			Ω(br.SetPluginVisibility("test", "secret")).Should(BeAssignableToTypeOf(hub.InvalidVisibilityError("")))
+		})
+
+		It("should fail to grant access to nonexistent namespace", func() {
+			err := br.GrantPluginAccess("test", "nonexistent")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("not found"))
+		})
+
+		It("should fail to change access of nonexistent plugin", func() {
+			Ω(br.SetPluginVisibility("nonexistent", hub.Public)).ShouldNot(Succeed())
+		})
+	})
+
 	Context("when namespace was not set", func() {
 		BeforeEach(func() {
 			br.User.Basic().Namespace = ""
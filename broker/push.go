@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/scm/push"
+)
+
+// GetPushSecret returns the secret verifying push webhooks of the
+// application. A new secret is generated if the application has none, or
+// if regeneration is requested, which invalidates the previous secret.
+func (br *UserBroker) GetPushSecret(name string, regenerate bool) (string, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return "", err
+	}
+	if app.PushSecret != "" && !regenerate {
+		return app.PushSecret, nil
+	}
+
+	secret, err := generateSharedSecret()
+	if err != nil {
+		return "", err
+	}
+
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		app.PushSecret = secret
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ReceivePush handles a push webhook posted by Git hosting service for the
+// application. The application is deployed in background if the pushed ref
+// is the current deployment branch. Returns the push event, and whether a
+// deployment is started.
+func (br *Broker) ReceivePush(namespace, name string, header http.Header, token string, body []byte) (*push.Event, bool, error) {
+	user, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = ApplicationNotFoundError(name)
+		}
+		return nil, false, err
+	}
+
+	// applications without push secret don't accept push webhooks
+	app := user.Basic().Applications[name]
+	if app == nil || app.PushSecret == "" {
+		return nil, false, ApplicationNotFoundError(name)
+	}
+
+	event, err := push.Parse(header, token, body, app.PushSecret)
+	if err != nil || event.Deleted {
+		return event, false, err
+	}
+
+	branch, err := br.SCM.GetDeploymentBranch(namespace, name)
+	if err != nil {
+		return event, false, err
+	}
+	if branch.Id != event.Ref {
+		return event, false, nil
+	}
+
+	ub := br.NewUserBroker(user, context.Background())
+	go func() {
+		if err := ub.Deploy(name, event.Ref, container.DeployOptions{}, nil); err != nil {
+			logrus.WithError(err).Errorf("Failed to deploy %s-%s on push to %s", name, namespace, event.Ref)
+		}
+	}()
+	return event, true, nil
+}
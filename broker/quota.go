@@ -0,0 +1,168 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+)
+
+// Usage is the resources consumed by applications of a user.
+type Usage struct {
+	Applications int
+	Containers   int
+	Memory       int64
+	CPUs         float64
+}
+
+type QuotaExceededError struct {
+	Resource         string
+	Limit, Requested string
+}
+
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("Quota exceeded: the %s limit is %s, but %s requested", e.Resource, e.Limit, e.Requested)
+}
+
+func (e QuotaExceededError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e QuotaExceededError) ErrorCode() string {
+	return types.ErrCodeQuotaExceeded
+}
+
+func (e QuotaExceededError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{
+		"Resource":  e.Resource,
+		"Limit":     e.Limit,
+		"Requested": e.Requested,
+	}
+}
+
+// DefaultQuota returns the quota of users without their own quota,
+// configured in the quota section.
+func DefaultQuota() *userdb.Quota {
+	quota := &userdb.Quota{}
+	quota.Applications, _ = strconv.Atoi(config.Get("quota.applications"))
+	quota.Containers, _ = strconv.Atoi(config.Get("quota.containers"))
+	quota.CPUs, _ = strconv.ParseFloat(config.Get("quota.cpus"), 64)
+	if s := config.Get("quota.memory"); s != "" {
+		var err error
+		if quota.Memory, err = units.RAMInBytes(s); err != nil {
+			logrus.WithError(err).Warn("Invalid quota.memory setting")
+		}
+	}
+	return quota
+}
+
+// GetQuota returns the quota and resource usage of the user.
+func (br *Broker) GetQuota(username string) (*userdb.Quota, *Usage, error) {
+	var user userdb.BasicUser
+	if err := br.Users.Find(username, &user); err != nil {
+		return nil, nil, err
+	}
+
+	usage, err := br.getUsage(&user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return quotaOf(&user), usage, nil
+}
+
+// SetQuota changes the quota of the user. The default quota is restored
+// if the given quota is nil.
+func (br *Broker) SetQuota(username string, quota *userdb.Quota) error {
+	if quota != nil && (quota.Applications < 0 || quota.Containers < 0 || quota.Memory < 0 || quota.CPUs < 0) {
+		return fmt.Errorf("Quota limits cannot be negative")
+	}
+
+	var user userdb.BasicUser
+	if err := br.Users.Find(username, &user); err != nil {
+		return err
+	}
+	return br.Users.Update(username, userdb.Args{"quota": quota})
+}
+
+func quotaOf(user *userdb.BasicUser) *userdb.Quota {
+	if user.Quota != nil {
+		return user.Quota
+	}
+	return DefaultQuota()
+}
+
+func (br *Broker) getUsage(user *userdb.BasicUser) (*Usage, error) {
+	usage := &Usage{Applications: len(user.Applications)}
+	if user.Namespace == "" {
+		return usage, nil
+	}
+
+	cs, err := br.FindInNamespace(context.Background(), user.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cs {
+		usage.add(1, c.Resources())
+	}
+	return usage, nil
+}
+
+// add adds the number of containers allocated with the resources.
+func (u *Usage) add(n int, r container.Resources) {
+	u.Containers += n
+	u.Memory += int64(n) * r.Memory
+	u.CPUs += float64(n) * r.CPUs
+}
+
+// checkQuota verifies the user has enough quota to allocate the requested
+// resources in addition to the resources already consumed.
+func (br *UserBroker) checkQuota(requested Usage) error {
+	user := br.User.Basic()
+	quota := quotaOf(user)
+	if *quota == (userdb.Quota{}) {
+		return nil
+	}
+
+	usage, err := br.getUsage(user)
+	if err != nil {
+		return err
+	}
+
+	if n := usage.Applications + requested.Applications; quota.Applications > 0 && n > quota.Applications {
+		return QuotaExceededError{
+			Resource:  "applications",
+			Limit:     strconv.Itoa(quota.Applications),
+			Requested: strconv.Itoa(n),
+		}
+	}
+	if n := usage.Containers + requested.Containers; quota.Containers > 0 && n > quota.Containers {
+		return QuotaExceededError{
+			Resource:  "containers",
+			Limit:     strconv.Itoa(quota.Containers),
+			Requested: strconv.Itoa(n),
+		}
+	}
+	if n := usage.Memory + requested.Memory; quota.Memory > 0 && n > quota.Memory {
+		return QuotaExceededError{
+			Resource:  "memory",
+			Limit:     units.BytesSize(float64(quota.Memory)),
+			Requested: units.BytesSize(float64(n)),
+		}
+	}
+	if n := usage.CPUs + requested.CPUs; quota.CPUs > 0 && n > quota.CPUs {
+		return QuotaExceededError{
+			Resource:  "CPU",
+			Limit:     strconv.FormatFloat(quota.CPUs, 'f', -1, 64),
+			Requested: strconv.FormatFloat(n, 'f', -1, 64),
+		}
+	}
+	return nil
+}
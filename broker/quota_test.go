@@ -0,0 +1,63 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Quota", func() {
+	var user *userdb.BasicUser
+
+	BeforeEach(func() {
+		user = &userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		Expect(broker.CreateUser(user, "test")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should report usage of applications and containers", func() {
+		ub := broker.NewUserBroker(user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, usage, err := broker.GetQuota(TESTUSER)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage.Applications).To(Equal(1))
+		Expect(usage.Containers).To(Equal(1))
+	})
+
+	It("should reject applications exceeding the quota", func() {
+		Expect(broker.SetQuota(TESTUSER, &userdb.Quota{Applications: 1})).To(Succeed())
+
+		ub := broker.NewUserBroker(user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, err = ub.CreateApplication(container.CreateOptions{Name: "test2"}, []string{"mock"})
+		Expect(err).To(BeAssignableToTypeOf(br.QuotaExceededError{}))
+	})
+
+	It("should reject scaling exceeding the container quota", func() {
+		Expect(broker.SetQuota(TESTUSER, &userdb.Quota{Containers: 2})).To(Succeed())
+
+		ub := broker.NewUserBroker(user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = ub.ScaleApplication("test", 2)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ub.ScaleApplication("test", 3)
+		Expect(err).To(BeAssignableToTypeOf(br.QuotaExceededError{}))
+	})
+
+	It("should reject negative limits", func() {
+		Expect(broker.SetQuota(TESTUSER, &userdb.Quota{Containers: -1})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,224 @@
+package broker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/mailer"
+)
+
+// The time limit to verify the email address of a registration.
+const verificationTimeout = 24 * time.Hour
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+type RegistrationDisabledError struct{}
+
+func (e RegistrationDisabledError) Error() string {
+	return "Self-service registration is disabled"
+}
+
+func (e RegistrationDisabledError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e RegistrationDisabledError) ErrorCode() string {
+	return "registration_disabled"
+}
+
+type InvalidVerificationError struct{}
+
+func (e InvalidVerificationError) Error() string {
+	return "Invalid or expired verification token"
+}
+
+func (e InvalidVerificationError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidVerificationError) ErrorCode() string {
+	return "invalid_verification_token"
+}
+
+type InvalidRegistrationError string
+
+func (e InvalidRegistrationError) Error() string {
+	return string(e)
+}
+
+func (e InvalidRegistrationError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e InvalidRegistrationError) ErrorCode() string {
+	return "invalid_registration"
+}
+
+// RegistrationEnabled returns true if users can register themselves, which
+// is enabled by the register.enabled setting and requires a mailer to send
+// verification emails, and the register.confirm_url setting to link to in
+// the emails.
+func (br *Broker) RegistrationEnabled() bool {
+	enabled, _ := strconv.ParseBool(config.Get("register.enabled"))
+	return enabled && br.Mail != nil && config.Get("register.confirm_url") != ""
+}
+
+// RegistrationApproval returns true if registered users must be approved
+// by an administrator after verified, configured by register.approval.
+func RegistrationApproval() bool {
+	approval, _ := strconv.ParseBool(config.Get("register.approval"))
+	return approval
+}
+
+// Register creates an inactive user named by the email address, and sends
+// a verification email containing the URL configured by register.confirm_url
+// followed by a token. An expired registration of the same user is replaced.
+// The namespace is assigned to the user once the registration is confirmed,
+// so unverified registrations never hold namespaces.
+func (br *Broker) Register(email, password, namespace string) error {
+	if !br.RegistrationEnabled() {
+		return RegistrationDisabledError{}
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !emailPattern.MatchString(email) {
+		return InvalidRegistrationError("Please enter a valid email address")
+	}
+	if len(password) < 4 {
+		return InvalidRegistrationError("The password must have at least 4 characters")
+	}
+	if namespace != "" {
+		if !namespacePattern.MatchString(namespace) {
+			return InvalidRegistrationError("The namespace can only contains lower case letters, digits, or underscores")
+		}
+		if _, err := br.Users.FindByNamespace(namespace); err == nil {
+			return userdb.DuplicateNamespaceError(namespace)
+		} else if !userdb.IsUserNotFound(err) {
+			return err
+		}
+	}
+
+	var existing userdb.BasicUser
+	err := br.Users.Find(email, &existing)
+	if err == nil {
+		reg := existing.Registration
+		if !existing.Inactive || reg == nil || reg.Verified || time.Now().Before(reg.Expires) {
+			return userdb.DuplicateUserError(email)
+		}
+		if err = br.RemoveUser(email); err != nil {
+			return err
+		}
+	} else if !userdb.IsUserNotFound(err) {
+		return err
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	user := &userdb.BasicUser{
+		Name: email,
+		Registration: &userdb.Registration{
+			TokenHash: hashVerificationToken(token),
+			Expires:   time.Now().Add(verificationTimeout),
+			Namespace: namespace,
+		},
+	}
+	if err = br.Users.Create(user, password); err != nil {
+		return err
+	}
+
+	err = br.Mail.Send(&mailer.Message{
+		To:      []string{email},
+		Subject: "Confirm your account",
+		Body: fmt.Sprintf("Please confirm your account by visiting the following link within %v:\n\n%s%s\n",
+			verificationTimeout, config.Get("register.confirm_url"), token),
+	})
+	if err != nil {
+		br.RemoveUser(email)
+		return err
+	}
+	return nil
+}
+
+// ConfirmRegistration verifies the email address of a registered user with
+// the token sent to the user. The user is activated unless an approval of
+// administrator is required. Returns the user name and whether the user
+// is activated.
+func (br *Broker) ConfirmRegistration(token string) (string, bool, error) {
+	if token == "" {
+		return "", false, InvalidVerificationError{}
+	}
+
+	var user userdb.BasicUser
+	err := br.Users.Search(userdb.Args{"registration.tokenhash": hashVerificationToken(token)}, &user)
+	if userdb.IsUserNotFound(err) {
+		return "", false, InvalidVerificationError{}
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	reg := user.Registration
+	if reg == nil || reg.Verified || time.Now().After(reg.Expires) {
+		return "", false, InvalidVerificationError{}
+	}
+
+	if RegistrationApproval() {
+		reg = &userdb.Registration{Verified: true, Namespace: reg.Namespace}
+		err = br.Users.Update(user.Name, userdb.Args{"registration": reg})
+		if err == nil {
+			logrus.Infof("User %s is waiting for approval", user.Name)
+		}
+		return user.Name, false, err
+	}
+
+	if err = br.claimRegisteredNamespace(&user); err != nil {
+		return "", false, err
+	}
+	err = br.Users.Update(user.Name, userdb.Args{"inactive": false, "registration": nil})
+	return user.Name, err == nil, err
+}
+
+// claimRegisteredNamespace assigns the namespace requested by the registered
+// user when the user is activated. The user is activated without namespace
+// if the namespace was taken by others meanwhile.
+func (br *Broker) claimRegisteredNamespace(user *userdb.BasicUser) error {
+	reg := user.Registration
+	if reg == nil || reg.Namespace == "" || user.Namespace != "" {
+		return nil
+	}
+
+	err := br.NewUserBroker(user, context.Background()).CreateNamespace(reg.Namespace)
+	if _, dup := err.(userdb.DuplicateNamespaceError); dup {
+		logrus.Warnf("The namespace %s registered by %s is already in use", reg.Namespace, user.Name)
+		err = nil
+	}
+	return err
+}
+
+func generateVerificationToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
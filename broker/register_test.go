@@ -0,0 +1,115 @@
+package broker_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/mailer"
+)
+
+type captureMailer struct {
+	messages []*mailer.Message
+}
+
+func (m *captureMailer) Send(msg *mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+var _ = Describe("Registration", func() {
+	const email = "register@example.com"
+	const confirmURL = "http://localhost/auth/confirm?token="
+
+	var mail *captureMailer
+	var savedMailer mailer.Mailer
+
+	BeforeEach(func() {
+		mail = &captureMailer{}
+		savedMailer, broker.Mail = broker.Mail, mail
+		config.Set("register.enabled", "true")
+		config.Set("register.confirm_url", confirmURL)
+	})
+
+	AfterEach(func() {
+		broker.Mail = savedMailer
+		config.Remove("register.enabled")
+		config.Remove("register.approval")
+		config.Remove("register.confirm_url")
+		broker.RemoveUser(email)
+	})
+
+	token := func() string {
+		Expect(mail.messages).To(HaveLen(1))
+		body := mail.messages[0].Body
+		i := strings.Index(body, confirmURL)
+		Expect(i).NotTo(Equal(-1))
+		return strings.TrimSpace(body[i+len(confirmURL):])
+	}
+
+	It("should activate user after verified", func() {
+		Expect(broker.Register(email, "test", "")).To(Succeed())
+		_, err := broker.Users.Authenticate(email, "test")
+		Expect(err).To(BeAssignableToTypeOf(userdb.InactiveUserError("")))
+
+		name, activated, err := broker.ConfirmRegistration(token())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal(email))
+		Expect(activated).To(BeTrue())
+
+		_, err = broker.Users.Authenticate(email, "test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should wait for approval after verified", func() {
+		config.Set("register.approval", "true")
+		Expect(broker.Register(email, "test", "")).To(Succeed())
+
+		_, activated, err := broker.ConfirmRegistration(token())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(activated).To(BeFalse())
+		_, err = broker.Users.Authenticate(email, "test")
+		Expect(err).To(HaveOccurred())
+
+		Expect(broker.SetUserActive(email, true)).To(Succeed())
+		_, err = broker.Users.Authenticate(email, "test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should create inactive user without namespace until verified", func() {
+		Expect(broker.Register(email, "test", "register")).To(Succeed())
+
+		var user userdb.BasicUser
+		Expect(broker.Users.Find(email, &user)).To(Succeed())
+		Expect(user.Inactive).To(BeTrue())
+		Expect(user.Namespace).To(BeEmpty())
+		Expect(user.Registration.Namespace).To(Equal("register"))
+
+		_, _, err := broker.ConfirmRegistration(token())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.Users.Find(email, &user)).To(Succeed())
+		Expect(user.Inactive).To(BeFalse())
+		Expect(user.Namespace).To(Equal("register"))
+		Expect(user.Registration).To(BeNil())
+	})
+
+	It("should reject registration without confirm URL", func() {
+		config.Remove("register.confirm_url")
+		Expect(broker.Register(email, "test", "")).To(Equal(br.RegistrationDisabledError{}))
+	})
+
+	It("should reject invalid token", func() {
+		Expect(broker.Register(email, "test", "")).To(Succeed())
+		_, _, err := broker.ConfirmRegistration("invalid")
+		Expect(err).To(Equal(br.InvalidVerificationError{}))
+	})
+
+	It("should reject registration if disabled", func() {
+		config.Set("register.enabled", "false")
+		Expect(broker.Register(email, "test", "")).To(Equal(br.RegistrationDisabledError{}))
+	})
+})
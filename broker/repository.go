@@ -0,0 +1,115 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/scm/remote"
+)
+
+// BindRepository binds the application to an external repository managed
+// by the driver. A new deploy key is generated for the application, and is
+// registered in the Git hosting service if an API token is given. Otherwise
+// the public key must be installed by user. The application is deployed
+// from the external repository afterwards.
+func (br *UserBroker) BindRepository(name, driver, repo, token string) (*userdb.RemoteRepository, error) {
+	if _, err := br.getApplication(name); err != nil {
+		return nil, err
+	}
+
+	d, err := remote.GetDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+	url, err := d.CloneURL(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, publicKey, err := remote.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	user := br.User.Basic()
+	binding := &userdb.RemoteRepository{
+		Driver:     driver,
+		Repo:       repo,
+		URL:        url,
+		Token:      token,
+		PrivateKey: privateKey,
+	}
+	if token != "" {
+		title := fmt.Sprintf("cloudway %s-%s", name, user.Namespace)
+		binding.KeyID, err = d.AddDeployKey(br.ctx, repo, token, title, publicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var previous *userdb.RemoteRepository
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		previous = app.Repository
+		app.Repository = binding
+		return nil
+	})
+	if err != nil {
+		br.removeDeployKey(binding)
+		return nil, err
+	}
+	if previous != nil {
+		br.removeDeployKey(previous)
+	}
+	return binding, nil
+}
+
+// GetRepository returns the external repository bound to the application,
+// or nil if the application is deployed from the platform SCM.
+func (br *UserBroker) GetRepository(name string) (*userdb.RemoteRepository, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	return app.Repository, nil
+}
+
+// UnbindRepository unbinds the application from the external repository
+// and removes the deploy key. The application is deployed from the platform
+// SCM afterwards.
+func (br *UserBroker) UnbindRepository(name string) error {
+	app, err := br.getApplication(name)
+	if err != nil || app.Repository == nil {
+		return err
+	}
+
+	var previous *userdb.RemoteRepository
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		if previous = app.Repository; previous == nil {
+			return errUnchanged
+		}
+		app.Repository = nil
+		return nil
+	})
+	if err == nil && previous != nil {
+		br.removeDeployKey(previous)
+	}
+	return err
+}
+
+// removeDeployKey removes the deploy key registered in the Git hosting
+// service. Failures are logged and ignored since the key can be removed
+// by user.
+func (br *UserBroker) removeDeployKey(repo *userdb.RemoteRepository) {
+	if repo.KeyID == "" {
+		return
+	}
+	d, err := remote.GetDriver(repo.Driver)
+	if err == nil {
+		err = d.RemoveDeployKey(br.ctx, repo.Repo, repo.Token, repo.KeyID)
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to remove deploy key from %s repository %s", repo.Driver, repo.Repo)
+	}
+}
@@ -0,0 +1,62 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/scm/remote"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Remote repository", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "remote"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("remote")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should bind and unbind external repository", func() {
+		Expect(ub.GetRepository("remote")).To(BeNil())
+
+		repo, err := ub.BindRepository("remote", "git", "git@example.com:repos/app.git", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(repo.URL).To(Equal("git@example.com:repos/app.git"))
+		Expect(repo.KeyID).To(BeEmpty())
+		Expect(remote.PublicKey(repo.PrivateKey)).To(HavePrefix("ssh-rsa "))
+
+		bound, err := ub.GetRepository("remote")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bound.PrivateKey).To(Equal(repo.PrivateKey))
+
+		Expect(ub.UnbindRepository("remote")).To(Succeed())
+		Expect(ub.GetRepository("remote")).To(BeNil())
+	})
+
+	It("should reject invalid repository", func() {
+		_, err := ub.BindRepository("remote", "svn", "svn://example.com/app", "")
+		Expect(err).To(Equal(remote.UnknownDriverError("svn")))
+
+		_, err = ub.BindRepository("remote", "github", "app", "")
+		Expect(err).To(Equal(remote.InvalidRepoError("app")))
+
+		_, err = ub.BindRepository("nonexist", "git", "git@example.com:repos/app.git", "")
+		Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+	})
+})
@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// GetResources returns the resource limits of the application containers
+// for each service. The framework containers have an empty service name.
+func (br *UserBroker) GetResources(name string) ([]*userdb.ResourceLimits, error) {
+	if _, err := br.getApplication(name); err != nil {
+		return nil, err
+	}
+
+	cs, err := br.FindAll(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+
+	var limits []*userdb.ResourceLimits
+	seen := make(map[string]bool)
+	for _, c := range cs {
+		service := ""
+		if c.Category().IsService() {
+			service = c.ServiceName()
+		}
+		if !seen[service] {
+			seen[service] = true
+			limits = append(limits, toResourceLimits(service, c.Resources()))
+		}
+	}
+	return limits, nil
+}
+
+// SetResources overrides resource limits of the containers of a service, or
+// the framework containers if the service name is empty. The new limits are
+// applied to running containers, and retained when containers are upgraded
+// or scaled. A zero value of a limit keeps the current limit.
+func (br *UserBroker) SetResources(name, service string, limits container.Resources) error {
+	if limits.Memory < 0 || limits.CPUs < 0 || limits.PidsLimit < 0 {
+		return fmt.Errorf("Resource limits cannot be negative")
+	}
+
+	_, err := br.getApplication(name)
+	if err != nil {
+		return err
+	}
+
+	var cs []*container.Container
+	if service == "" {
+		cs, err = br.FindApplications(br.ctx, name, br.Namespace())
+	} else {
+		cs, err = br.FindService(br.ctx, name, br.Namespace(), service)
+	}
+	if err != nil {
+		return err
+	}
+	if len(cs) == 0 {
+		if service == "" {
+			return ApplicationNotFoundError(name)
+		}
+		return fmt.Errorf("Service '%s' not found in application '%s'", service, name)
+	}
+
+	// only check quota for the increased resources
+	current := cs[0].Resources()
+	target := current.Merge(limits)
+	var requested Usage
+	if target.Memory > current.Memory {
+		requested.Memory = int64(len(cs)) * (target.Memory - current.Memory)
+	}
+	if target.CPUs > current.CPUs {
+		requested.CPUs = float64(len(cs)) * (target.CPUs - current.CPUs)
+	}
+	if err = br.checkQuota(requested); err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		if err = c.UpdateResources(br.ctx, target); err != nil {
+			return err
+		}
+	}
+
+	return br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		override := resourceLimitsOf(app, service).Merge(limits)
+		removeResourceLimits(app, service)
+		app.Resources = append(app.Resources, toResourceLimits(service, override))
+		return nil
+	})
+}
+
+// serviceResources returns the resource limits of containers created from
+// the plugin, which are declared in plugin manifest and may be overridden
+// by the given resources and the application.
+func serviceResources(app *userdb.Application, service string, plugin *manifest.Plugin, base container.Resources) container.Resources {
+	if plugin.IsFramework() {
+		service = ""
+	} else if service == "" {
+		service = plugin.Name
+	}
+	return container.PluginResources(plugin).
+		Merge(base).
+		Merge(resourceLimitsOf(app, service))
+}
+
+func resourceLimitsOf(app *userdb.Application, service string) container.Resources {
+	if app != nil {
+		for _, r := range app.Resources {
+			if r.Service == service {
+				return container.Resources{Memory: r.Memory, CPUs: r.CPUs, PidsLimit: r.PidsLimit}
+			}
+		}
+	}
+	return container.Resources{}
+}
+
+func removeResourceLimits(app *userdb.Application, service string) {
+	for i, r := range app.Resources {
+		if r.Service == service {
+			app.Resources = append(app.Resources[:i], app.Resources[i+1:]...)
+			return
+		}
+	}
+}
+
+func toResourceLimits(service string, r container.Resources) *userdb.ResourceLimits {
+	return &userdb.ResourceLimits{
+		Service:   service,
+		Memory:    r.Memory,
+		CPUs:      r.CPUs,
+		PidsLimit: r.PidsLimit,
+	}
+}
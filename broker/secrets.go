@@ -0,0 +1,245 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/container"
+)
+
+// The maximum number of audit records kept for an application.
+const maxSecretAudit = 100
+
+// The value shown in place of secrets set as environment variables.
+const secretMask = "******"
+
+// ListSecrets returns secrets of the application. Values of secrets are
+// never returned.
+func (br *UserBroker) ListSecrets(name string) ([]*userdb.AppSecret, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*userdb.AppSecret, len(app.Secrets))
+	for i, s := range app.Secrets {
+		secrets[i] = hideSecret(s)
+	}
+	return secrets, nil
+}
+
+// SetSecret creates or updates a secret of the application. The secret is
+// injected into running framework containers as a file in the secrets
+// directory, or as an environment variable if mounted to env.
+func (br *UserBroker) SetSecret(name, key, value, mount string) (*userdb.AppSecret, error) {
+	if !validEnvKey.MatchString(key) {
+		return nil, InvalidSecretError("Invalid secret name: " + key)
+	}
+	switch mount {
+	case "":
+		mount = userdb.SecretFile
+	case userdb.SecretFile, userdb.SecretEnv:
+	default:
+		return nil, InvalidSecretError("Invalid secret mount: " + mount)
+	}
+
+	return br.saveSecret(name, key, value, mount)
+}
+
+// RotateSecret replaces the value of a secret and increases its version.
+// A random value is generated if the new value is empty.
+func (br *UserBroker) RotateSecret(name, key, value string) (*userdb.AppSecret, error) {
+	if value == "" {
+		var err error
+		if value, err = generateSharedSecret(); err != nil {
+			return nil, err
+		}
+	}
+	return br.saveSecret(name, key, value, "")
+}
+
+// saveSecret saves the secret of the application and injects it into running
+// framework containers. The secret is created or moved to the mount if the
+// mount is not empty, otherwise the existing secret is rotated.
+func (br *UserBroker) saveSecret(name, key, value, mount string) (*userdb.AppSecret, error) {
+	encrypted, err := br.Users.EncryptAppSecret(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *userdb.AppSecret
+	var previous string
+	err = br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		action := "rotate"
+		if s = findSecret(app, key); s == nil {
+			if mount == "" {
+				return SecretNotFoundError(key)
+			}
+			s = &userdb.AppSecret{Name: key, Created: time.Now()}
+			app.Secrets = append(app.Secrets, s)
+			action = "create"
+		} else if mount != "" {
+			action = "update"
+		}
+
+		previous = s.Mount
+		if mount != "" {
+			s.Mount = mount
+		}
+		s.Value = encrypted
+		s.Version++
+		s.Updated = time.Now()
+		br.auditSecret(app, action, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	secret := []*container.Secret{{Name: s.Name, Value: value, Env: s.Mount == userdb.SecretEnv}}
+	for _, c := range cs {
+		if previous != "" && previous != s.Mount {
+			if err = c.RemoveSecrets(br.ctx, s.Name); err != nil {
+				return nil, err
+			}
+		}
+		if err = c.InjectSecrets(br.ctx, secret); err != nil {
+			return nil, err
+		}
+	}
+	return hideSecret(s), nil
+}
+
+// RemoveSecret removes the secret from the application and running framework
+// containers.
+func (br *UserBroker) RemoveSecret(name, key string) error {
+	err := br.modifyApplication(br.User, name, func(app *userdb.Application) error {
+		for i, s := range app.Secrets {
+			if s.Name == key {
+				app.Secrets = append(app.Secrets[:i], app.Secrets[i+1:]...)
+				br.auditSecret(app, "remove", key)
+				return nil
+			}
+		}
+		return SecretNotFoundError(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	cs, err := br.FindApplications(br.ctx, name, br.Namespace())
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if err = c.RemoveSecrets(br.ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSecretAudit returns recent operations on secrets of the application,
+// the most recent last.
+func (br *UserBroker) GetSecretAudit(name string) ([]*userdb.SecretAccess, error) {
+	app, err := br.getApplication(name)
+	if err != nil {
+		return nil, err
+	}
+	return app.SecretAudit, nil
+}
+
+// MaskSecrets replaces values of secrets set as environment variables of the
+// application, so secrets are never returned in plaintext by environment
+// queries. The user must be refreshed before calling.
+func (br *UserBroker) MaskSecrets(name string, env map[string]string) {
+	app := br.User.Basic().Applications[name]
+	if app == nil {
+		return
+	}
+	for _, s := range app.Secrets {
+		if _, ok := env[s.Name]; ok && s.Mount == userdb.SecretEnv {
+			env[s.Name] = secretMask
+		}
+	}
+}
+
+func (br *UserBroker) auditSecret(app *userdb.Application, action, key string) {
+	appendSecretAudit(app, &userdb.SecretAccess{
+		Time:   time.Now(),
+		User:   br.User.Basic().Name,
+		Action: action,
+		Secret: key,
+	})
+}
+
+func appendSecretAudit(app *userdb.Application, access ...*userdb.SecretAccess) {
+	app.SecretAudit = append(app.SecretAudit, access...)
+	if n := len(app.SecretAudit); n > maxSecretAudit {
+		app.SecretAudit = append([]*userdb.SecretAccess(nil), app.SecretAudit[n-maxSecretAudit:]...)
+	}
+}
+
+func findSecret(app *userdb.Application, key string) *userdb.AppSecret {
+	for _, s := range app.Secrets {
+		if s.Name == key {
+			return s
+		}
+	}
+	return nil
+}
+
+func hideSecret(s *userdb.AppSecret) *userdb.AppSecret {
+	hidden := *s
+	hidden.Value = ""
+	return &hidden
+}
+
+// provideSecrets decrypts secrets of the application to be injected into
+// the framework container on start, and records the access.
+func (br *Broker) provideSecrets(ctx context.Context, c *container.Container) ([]*container.Secret, error) {
+	user, err := br.Users.FindByNamespace(c.Namespace)
+	if err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	app := user.Basic().Applications[c.Name]
+	if app == nil || len(app.Secrets) == 0 {
+		return nil, nil
+	}
+
+	secrets := make([]*container.Secret, 0, len(app.Secrets))
+	access := make([]*userdb.SecretAccess, 0, len(app.Secrets))
+	now := time.Now()
+	for _, s := range app.Secrets {
+		value, err := br.Users.DecryptAppSecret(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, &container.Secret{Name: s.Name, Value: value, Env: s.Mount == userdb.SecretEnv})
+		access = append(access, &userdb.SecretAccess{
+			Time:      now,
+			Container: c.ID[:12],
+			Action:    "inject",
+			Secret:    s.Name,
+		})
+	}
+
+	err = br.modifyApplication(user, c.Name, func(app *userdb.Application) error {
+		appendSecretAudit(app, access...)
+		return nil
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to record secret access")
+	}
+	return secrets, nil
+}
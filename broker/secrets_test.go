@@ -0,0 +1,117 @@
+package broker_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Secrets", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "secrets"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("secrets")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	readSecret := func(key string) string {
+		cs, err := broker.FindApplications(context.Background(), "secrets", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cs).NotTo(BeEmpty())
+
+		var out bytes.Buffer
+		err = cs[0].Exec(context.Background(), "", nil, &out, nil, "cat", container.SecretsDir+"/"+key)
+		Expect(err).NotTo(HaveOccurred())
+		return out.String()
+	}
+
+	It("should store secrets encrypted and inject them into containers", func() {
+		s, err := ub.SetSecret("secrets", "DB_PASSWORD", "s3cr3t", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Value).To(BeEmpty())
+		Expect(s.Mount).To(Equal(userdb.SecretFile))
+		Expect(s.Version).To(Equal(1))
+		Expect(readSecret("DB_PASSWORD")).To(Equal("s3cr3t"))
+
+		Expect(ub.Refresh()).To(Succeed())
+		stored := user.Applications["secrets"].Secrets[0]
+		Expect(stored.Value).NotTo(BeEmpty())
+		Expect(stored.Value).NotTo(ContainSubstring("s3cr3t"))
+
+		secrets, err := ub.ListSecrets("secrets")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secrets).To(HaveLen(1))
+		Expect(secrets[0].Name).To(Equal("DB_PASSWORD"))
+		Expect(secrets[0].Value).To(BeEmpty())
+	})
+
+	It("should rotate secrets and record the access", func() {
+		_, err := ub.SetSecret("secrets", "API_KEY", "first", "")
+		Expect(err).NotTo(HaveOccurred())
+		s, err := ub.RotateSecret("secrets", "API_KEY", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Version).To(Equal(2))
+		Expect(readSecret("API_KEY")).NotTo(Equal("first"))
+
+		_, err = ub.RotateSecret("secrets", "nonexist", "")
+		Expect(err).To(Equal(br.SecretNotFoundError("nonexist")))
+
+		Expect(ub.RemoveSecret("secrets", "API_KEY")).To(Succeed())
+		Expect(ub.ListSecrets("secrets")).To(BeEmpty())
+
+		audit, err := ub.GetSecretAudit("secrets")
+		Expect(err).NotTo(HaveOccurred())
+		var actions []string
+		for _, a := range audit {
+			Expect(a.User).To(Equal(TESTUSER))
+			actions = append(actions, a.Action)
+		}
+		Expect(actions).To(Equal([]string{"create", "rotate", "remove"}))
+	})
+
+	It("should mask secrets set as environment variables", func() {
+		_, err := ub.SetSecret("secrets", "TOKEN", "s3cr3t", userdb.SecretEnv)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(readSecret(".env/TOKEN")).To(Equal("s3cr3t"))
+
+		cs, err := broker.FindApplications(context.Background(), "secrets", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		env, err := cs[0].GetenvAll(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).NotTo(HaveKey("TOKEN"), "secrets should not be persisted in the env directory")
+
+		env["TOKEN"] = "s3cr3t"
+		Expect(ub.Refresh()).To(Succeed())
+		ub.MaskSecrets("secrets", env)
+		Expect(env["TOKEN"]).NotTo(Equal("s3cr3t"))
+	})
+
+	It("should reject invalid secrets", func() {
+		_, err := ub.SetSecret("secrets", "../passwd", "value", "")
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidSecretError("")))
+		_, err = ub.SetSecret("secrets", "KEY", "value", "volume")
+		Expect(err).To(BeAssignableToTypeOf(br.InvalidSecretError("")))
+		_, err = ub.SetSecret("nonexist", "KEY", "value", "")
+		Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+	})
+})
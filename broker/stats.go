@@ -181,6 +181,64 @@ func calculateNetwork(network map[string]dockertypes.NetworkStats) (rx, tx uint6
 	return
 }
 
+// StatsSnapshot collects a single sample of resource usage statistics from
+// all containers of the application, and aggregates them into a total.
+func (br *UserBroker) StatsSnapshot(ctx context.Context, name string) (*types.ApplicationStats, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+
+	cs, err := br.FindAll(ctx, name, br.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, ApplicationNotFoundError(name)
+	}
+
+	var stopChan = make(chan struct{})
+	defer close(stopChan)
+
+	var wg sync.WaitGroup
+	var all = make([]*containerStats, len(cs))
+	for i, c := range cs {
+		all[i] = br.newContainerStats(c)
+		wg.Add(1)
+		go func(s *containerStats) {
+			defer wg.Done()
+			s.Collect(br.DockerClient, ctx, stopChan, false)
+		}(all[i])
+	}
+	wg.Wait()
+
+	result := &types.ApplicationStats{Name: name}
+	result.Total.Name = name
+	for _, s := range all {
+		sample, err := s.Sample()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Name, err)
+		}
+		result.Containers = append(result.Containers, sample)
+
+		total := &result.Total
+		total.CPUTotalUsage += sample.CPUTotalUsage
+		total.CPUPercentage += sample.CPUPercentage
+		total.MemoryUsage += sample.MemoryUsage
+		total.MemoryLimit += sample.MemoryLimit
+		total.NetworkRx += sample.NetworkRx
+		total.NetworkTx += sample.NetworkTx
+		total.BlockRead += sample.BlockRead
+		total.BlockWrite += sample.BlockWrite
+		if sample.CPUSystemUsage > total.CPUSystemUsage {
+			total.CPUSystemUsage = sample.CPUSystemUsage
+		}
+	}
+	if result.Total.MemoryLimit != 0 {
+		result.Total.MemoryPercentage = float64(result.Total.MemoryUsage) / float64(result.Total.MemoryLimit) * 100.0
+	}
+	return result, nil
+}
+
 func (br *UserBroker) Stats(ctx context.Context, name string, w io.Writer) error {
 	if err := br.Refresh(); err != nil {
 		return err
@@ -0,0 +1,55 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Stats", func() {
+	var user = userdb.BasicUser{
+		Name:      TESTUSER,
+		Namespace: NAMESPACE,
+	}
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub := broker.NewUserBroker(&user, context.Background())
+		opts := container.CreateOptions{Name: "stats"}
+		_, _, err := ub.CreateApplication(opts, []string{"mock", "mockdb"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartApplication("stats", nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		ub.RemoveApplication("stats")
+		Expect(broker.RemoveUser(TESTUSER)).To(Succeed())
+	})
+
+	It("should aggregate container stats", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		stats, err := ub.StatsSnapshot(context.Background(), "stats")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Name).To(Equal("stats"))
+		Expect(stats.Containers).To(HaveLen(2))
+
+		var memory, rx uint64
+		for _, s := range stats.Containers {
+			memory += s.MemoryUsage
+			rx += s.NetworkRx
+		}
+		Expect(stats.Total.MemoryUsage).To(Equal(memory))
+		Expect(stats.Total.NetworkRx).To(Equal(rx))
+	})
+
+	It("should fail for nonexistent application", func() {
+		ub := broker.NewUserBroker(&user, context.Background())
+		_, err := ub.StatsSnapshot(context.Background(), "nonexist")
+		Expect(err).To(Equal(br.ApplicationNotFoundError("nonexist")))
+	})
+})
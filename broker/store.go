@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"errors"
+
+	"github.com/cloudway/platform/auth/userdb"
+)
+
+// errUnchanged is returned by functions modifying applications to indicate
+// that nothing need to be saved.
+var errUnchanged = errors.New("application not changed")
+
+// modifyApplications modifies application records of the user in a
+// transaction. The user is reloaded, and the function is called again with
+// the fresh records if the user was modified concurrently, so modifications
+// made by other API servers are never overwritten. The function must only
+// modify the records, side effects are performed by the caller.
+func (br *Broker) modifyApplications(user userdb.User, fn func(apps map[string]*userdb.Application) error) error {
+	return br.Users.Modify(user.Basic().Name, user, func() (interface{}, error) {
+		basic := user.Basic()
+		if basic.Applications == nil {
+			basic.Applications = make(map[string]*userdb.Application)
+		}
+		if err := fn(basic.Applications); err != nil {
+			if err == errUnchanged {
+				err = nil
+			}
+			return nil, err
+		}
+		return userdb.Args{"applications": basic.Applications}, nil
+	})
+}
+
+// modifyApplication modifies the application record of the user in a
+// transaction, as described by modifyApplications.
+func (br *Broker) modifyApplication(user userdb.User, name string, fn func(app *userdb.Application) error) error {
+	return br.modifyApplications(user, func(apps map[string]*userdb.Application) error {
+		app := apps[name]
+		if app == nil {
+			return ApplicationNotFoundError(name)
+		}
+		return fn(app)
+	})
+}
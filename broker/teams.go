@@ -0,0 +1,163 @@
+package broker
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"golang.org/x/net/context"
+)
+
+// Team is a namespace shared with a user by its owner.
+type Team struct {
+	Namespace    string
+	Owner        string
+	Role         userdb.TeamRole
+	Applications []string
+}
+
+// ResolveNamespace returns the owner of the namespace on behalf of which
+// the user operates on applications. The user must be a member of the
+// namespace with a role that permits operations requiring the given role.
+// The user itself is returned if the namespace is the user's own.
+func (br *Broker) ResolveNamespace(user *userdb.BasicUser, namespace string, role userdb.TeamRole) (*userdb.BasicUser, error) {
+	if namespace == "" || namespace == user.Namespace {
+		return user, nil
+	}
+
+	owner, err := br.Users.FindByNamespace(namespace)
+	if err != nil {
+		if userdb.IsUserNotFound(err) {
+			err = NamespaceNotFoundError(namespace)
+		}
+		return nil, err
+	}
+
+	member := owner.Basic().GetMember(user.Name)
+	if member == nil {
+		return nil, NamespaceForbiddenError(namespace)
+	}
+	if !member.Role.Allows(role) {
+		return nil, TeamPermissionError{Namespace: namespace, Role: role}
+	}
+	return owner.Basic(), nil
+}
+
+// GetMembers returns members sharing the user's namespace.
+func (br *UserBroker) GetMembers() ([]*userdb.Member, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, err
+	}
+	return br.User.Basic().Members, nil
+}
+
+// AddMember invites a user to the namespace with the given role, or changes
+// the role if the user is already a member.
+func (br *UserBroker) AddMember(name string, role userdb.TeamRole) error {
+	if _, err := userdb.ParseTeamRole(string(role)); err != nil {
+		return err
+	}
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.Namespace == "" {
+		return NoNamespaceError(user.Name)
+	}
+	if name == user.Name {
+		return errors.New("The namespace owner cannot be added as a member")
+	}
+
+	var invitee userdb.BasicUser
+	if err := br.Users.Find(name, &invitee); err != nil {
+		return err
+	}
+
+	members := user.Members
+	if m := user.GetMember(name); m != nil {
+		m.Role = role
+	} else {
+		members = append(members, &userdb.Member{Name: name, Role: role})
+	}
+	if err := br.Users.UpdateIf(user.Name, user.Revision, userdb.Args{"members": members}); err != nil {
+		return err
+	}
+	user.Members = members
+	return nil
+}
+
+// RemoveMember revokes access of the user to the namespace.
+func (br *UserBroker) RemoveMember(name string) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	user := br.User.Basic()
+	if user.GetMember(name) == nil {
+		return MemberNotFoundError(name)
+	}
+
+	members := make([]*userdb.Member, 0, len(user.Members))
+	for _, m := range user.Members {
+		if m.Name != name {
+			members = append(members, m)
+		}
+	}
+	if err := br.Users.UpdateIf(user.Name, user.Revision, userdb.Args{"members": members}); err != nil {
+		return err
+	}
+	user.Members = members
+	return nil
+}
+
+// GetTeams returns namespaces shared with the user and applications in
+// these namespaces.
+func (br *UserBroker) GetTeams() ([]*Team, error) {
+	name := br.User.Basic().Name
+
+	var owners []userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{"members.name": name}, &owners); err != nil {
+		return nil, err
+	}
+
+	teams := make([]*Team, 0, len(owners))
+	for i := range owners {
+		owner := &owners[i]
+		if owner.Namespace == "" {
+			continue
+		}
+		team := &Team{
+			Namespace: owner.Namespace,
+			Owner:     owner.Name,
+			Role:      owner.GetMember(name).Role,
+		}
+		for app := range owner.Applications {
+			team.Applications = append(team.Applications, app)
+		}
+		sort.Strings(team.Applications)
+		teams = append(teams, team)
+	}
+	sort.Sort(byTeamNamespace(teams))
+	return teams, nil
+}
+
+type byTeamNamespace []*Team
+
+func (a byTeamNamespace) Len() int           { return len(a) }
+func (a byTeamNamespace) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTeamNamespace) Less(i, j int) bool { return a[i].Namespace < a[j].Namespace }
+
+// removeMemberships revokes access of the user to all shared namespaces.
+func (br *Broker) removeMemberships(name string) error {
+	var owners []userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{"members.name": name}, &owners); err != nil {
+		return err
+	}
+	for i := range owners {
+		if err := br.NewUserBroker(&owners[i], context.Background()).RemoveMember(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	. "github.com/cloudway/platform/auth/userdb/matchers"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Teams", func() {
+	const (
+		MEMBER          = "broker_test_member@example.com"
+		MEMBERNAMESPACE = "broker_test_member"
+	)
+
+	var (
+		ctx    = context.Background()
+		owner  userdb.BasicUser
+		member userdb.BasicUser
+		ownerb *br.UserBroker
+	)
+
+	BeforeEach(func() {
+		owner = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		member = userdb.BasicUser{Name: MEMBER, Namespace: MEMBERNAMESPACE}
+		Expect(broker.CreateUser(&owner, "test")).To(Succeed())
+		Expect(broker.CreateUser(&member, "test")).To(Succeed())
+		ownerb = broker.NewUserBroker(&owner, ctx)
+	})
+
+	AfterEach(func() {
+		broker.RemoveUser(TESTUSER)
+		broker.RemoveUser(MEMBER)
+	})
+
+	It("should invite, update and remove members", func() {
+		Expect(ownerb.AddMember(MEMBER, userdb.TeamViewer)).To(Succeed())
+		Expect(ownerb.AddMember(MEMBER, userdb.TeamDeployer)).To(Succeed())
+
+		members, err := ownerb.GetMembers()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(members).To(Equal([]*userdb.Member{{Name: MEMBER, Role: userdb.TeamDeployer}}))
+
+		Expect(ownerb.RemoveMember(MEMBER)).To(Succeed())
+		Expect(ownerb.GetMembers()).To(BeEmpty())
+		Expect(ownerb.RemoveMember(MEMBER)).To(Equal(br.MemberNotFoundError(MEMBER)))
+	})
+
+	It("should reject invalid members", func() {
+		Expect(ownerb.AddMember("nobody@example.com", userdb.TeamViewer)).To(BeUserNotFound("nobody@example.com"))
+		Expect(ownerb.AddMember(MEMBER, "admin")).To(Equal(userdb.InvalidTeamRoleError("admin")))
+		Expect(ownerb.AddMember(TESTUSER, userdb.TeamOwner)).NotTo(Succeed())
+	})
+
+	It("should resolve namespace by member role", func() {
+		user, err := broker.ResolveNamespace(&member, MEMBERNAMESPACE, userdb.TeamOwner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user).To(BeIdenticalTo(&member))
+
+		_, err = broker.ResolveNamespace(&member, NAMESPACE, userdb.TeamViewer)
+		Expect(err).To(Equal(br.NamespaceForbiddenError(NAMESPACE)))
+		_, err = broker.ResolveNamespace(&member, "broker_test_nonexist", userdb.TeamViewer)
+		Expect(err).To(Equal(br.NamespaceNotFoundError("broker_test_nonexist")))
+
+		Expect(ownerb.AddMember(MEMBER, userdb.TeamDeployer)).To(Succeed())
+
+		user, err = broker.ResolveNamespace(&member, NAMESPACE, userdb.TeamDeployer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Name).To(Equal(TESTUSER))
+		Expect(user.Namespace).To(Equal(NAMESPACE))
+
+		_, err = broker.ResolveNamespace(&member, NAMESPACE, userdb.TeamOwner)
+		Expect(err).To(Equal(br.TeamPermissionError{Namespace: NAMESPACE, Role: userdb.TeamOwner}))
+	})
+
+	It("should list team applications", func() {
+		_, _, err := ownerb.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ownerb.AddMember(MEMBER, userdb.TeamViewer)).To(Succeed())
+
+		teams, err := broker.NewUserBroker(&member, ctx).GetTeams()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(teams).To(Equal([]*br.Team{{
+			Namespace:    NAMESPACE,
+			Owner:        TESTUSER,
+			Role:         userdb.TeamViewer,
+			Applications: []string{"test"},
+		}}))
+
+		// the owner operates on the application on behalf of the member
+		user, err := broker.ResolveNamespace(&member, NAMESPACE, userdb.TeamViewer)
+		Expect(err).NotTo(HaveOccurred())
+		apps, err := broker.NewUserBroker(user, ctx).GetApplications()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(apps).To(HaveKey("test"))
+	})
+
+	It("should revoke membership of removed user", func() {
+		Expect(ownerb.AddMember(MEMBER, userdb.TeamOwner)).To(Succeed())
+		Expect(broker.RemoveUser(MEMBER)).To(Succeed())
+		Expect(ownerb.GetMembers()).To(BeEmpty())
+	})
+})
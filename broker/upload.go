@@ -0,0 +1,200 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/buildlog"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Upload application repository from a archive file. The deployment is
+// aborted if the current application state doesn't satisfy the deploy
+// condition in options. Nothing is deployed if the latest deployment of
+// the application has the same content and isn't failed.
+func (br *UserBroker) Upload(name string, content io.Reader, binary bool, opts container.DeployOptions, log *serverlog.ServerLog) error {
+	if err := br.Refresh(); err != nil {
+		return err
+	}
+
+	f, digest, err := spoolUpload(content, binary)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var blog *buildlog.Log
+	if br.User.Basic().Applications[name] != nil {
+		var dup *buildlog.Build
+		if blog, dup, err = br.queueUpload(name, opts.Strategy, digest); err != nil {
+			return err
+		}
+		if dup != nil {
+			if log != nil {
+				fmt.Fprintf(log.Stdout(), "The same content is deployed by build %s, nothing to deploy\n", dup.ID)
+			}
+			return nil
+		}
+	}
+	return br.upload(name, f, binary, opts, log, blog)
+}
+
+// SubmitUpload queues a deployment of the application repository from a
+// archive file, and returns the build to poll the deployment status. The
+// deployment is run in background after previous uploaded deployments of
+// the application finished. If the latest deployment of the application
+// has the same content and isn't failed, the build of that deployment is
+// returned and false is returned to indicate no deployment is queued.
+func (br *UserBroker) SubmitUpload(name string, content io.Reader, binary bool, opts container.DeployOptions) (*buildlog.Build, bool, error) {
+	if err := br.Refresh(); err != nil {
+		return nil, false, err
+	}
+	if br.User.Basic().Applications[name] == nil {
+		return nil, false, ApplicationNotFoundError(name)
+	}
+
+	// the deployment outlives the request, so use a separate user broker
+	user, err := br.Users.FindByNamespace(br.Namespace())
+	if err != nil {
+		return nil, false, err
+	}
+	ub := br.NewUserBroker(user, context.Background())
+
+	f, digest, err := spoolUpload(content, binary)
+	if err != nil {
+		return nil, false, err
+	}
+
+	blog, dup, err := br.queueUpload(name, opts.Strategy, digest)
+	if err != nil || dup != nil {
+		f.Close()
+		return dup, false, err
+	}
+
+	build, err := br.Builds.Find(br.Namespace(), name, blog.ID())
+	if err == nil {
+		err = addDeploymentJob()
+	}
+	if err != nil {
+		blog.Close(err)
+		f.Close()
+		return nil, false, err
+	}
+
+	go func() {
+		defer deployments.jobs.Done()
+		defer f.Close()
+		if err := ub.upload(name, f, binary, opts, nil, blog); err != nil {
+			logrus.WithError(err).Warnf("Failed to deploy %s-%s with build %s", name, ub.Namespace(), blog.ID())
+		}
+	}()
+	return build, true, nil
+}
+
+// upload deploys the uploaded repository after previous deployments of the
+// application finished.
+func (br *UserBroker) upload(name string, content io.Reader, binary bool, opts container.DeployOptions, log *serverlog.ServerLog, blog *buildlog.Log) (err error) {
+	log, finish := teeBuildLog(blog, &opts, log)
+	defer func() { finish(err) }()
+
+	ctx, done, err := br.startDeployment(name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := br.lockBuild(ctx, name, "upload", log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if blog != nil {
+		blog.SetStatus(buildlog.StatusRunning)
+	}
+
+	release, err := connectTrafficSwitcher(&opts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	br.recordDeployment(name, &opts)
+	reconciled := br.applyManifest(name, &opts, log)
+	return br.notifyDeploy(name, opts.Strategy, func() error {
+		if binary {
+			containers, err := br.FindApplications(ctx, name, br.Namespace())
+			if err != nil {
+				return err
+			}
+			if len(containers) == 0 {
+				return br.checkNoFramework(name)
+			}
+			return reconciled(br.distributeBinary(ctx, containers, content, opts, log))
+		} else {
+			return reconciled(br.DeployRepo(ctx, name, br.Namespace(), content, opts, log))
+		}
+	})
+}
+
+// uploadLock serializes checking and creating builds for uploads, so that
+// repeated submissions of the same content are detected.
+var uploadLock sync.Mutex
+
+// queueUpload creates a queued build for the uploaded content, or returns
+// the latest build of the application if it has the same content and isn't
+// failed.
+func (br *UserBroker) queueUpload(name string, strategy container.DeployStrategy, digest string) (*buildlog.Log, *buildlog.Build, error) {
+	uploadLock.Lock()
+	defer uploadLock.Unlock()
+
+	builds, err := br.Builds.Builds(br.Namespace(), name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(builds) != 0 && builds[0].Digest == digest && builds[0].Status != buildlog.StatusFailed {
+		return nil, builds[0], nil
+	}
+
+	blog, err := br.Builds.Queue(br.Namespace(), name, strategy.String(), digest)
+	return blog, nil, err
+}
+
+// spoolUpload saves the uploaded content to a temporary file that is removed
+// when closed, and computes the digest of the content to detect repeated
+// submissions. The upload is rejected once it exceeds the maximum size of
+// repository archives.
+func spoolUpload(content io.Reader, binary bool) (io.ReadCloser, string, error) {
+	tempfile, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		return nil, "", err
+	}
+	f := deleteReadCloser{tempfile}
+
+	h := sha256.New()
+	if binary {
+		io.WriteString(h, "binary\n")
+	}
+	limit := container.MaxRepoSize()
+	n, err := io.Copy(io.MultiWriter(tempfile, h), io.LimitReader(content, limit+1))
+	if err == nil && n > limit {
+		err = container.RepoTooLargeError{Limit: limit}
+	}
+	if err == nil {
+		_, err = tempfile.Seek(0, os.SEEK_SET)
+	}
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,163 @@
+package broker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/auth/userdb"
+	br "github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/buildlog"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Upload", func() {
+	var user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+	var ub *br.UserBroker
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, context.Background())
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mock"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartApplication("test", nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("test")
+		broker.RemoveUser(TESTUSER)
+	})
+
+	var archive = func(content string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		tw.WriteHeader(&tar.Header{Name: "marker", Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+		tw.Close()
+		zw.Close()
+		return &buf
+	}
+
+	var builds = func() []*buildlog.Build {
+		bs, err := ub.GetBuilds("test")
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return bs
+	}
+
+	It("should not deploy the same content repeatedly", func() {
+		Expect(ub.Upload("test", archive("v1"), true, container.DeployOptions{}, nil)).To(Succeed())
+		Expect(builds()).To(HaveLen(1))
+		Expect(builds()[0].Status).To(Equal(buildlog.StatusSucceeded))
+
+		Expect(ub.Upload("test", archive("v1"), true, container.DeployOptions{}, nil)).To(Succeed())
+		Expect(builds()).To(HaveLen(1))
+
+		Expect(ub.Upload("test", archive("v2"), true, container.DeployOptions{}, nil)).To(Succeed())
+		Expect(builds()).To(HaveLen(2))
+	})
+
+	It("should deploy asynchronously and track status", func() {
+		build, queued, err := ub.SubmitUpload("test", archive("async"), true, container.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queued).To(BeTrue())
+		Expect(build.Status).To(Equal(buildlog.StatusQueued))
+
+		status := func() string {
+			name, b, err := ub.GetDeployment(build.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("test"))
+			return b.Status
+		}
+		Eventually(status, deployTimeout).Should(Equal(buildlog.StatusSucceeded))
+
+		dup, queued, err := ub.SubmitUpload("test", archive("async"), true, container.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queued).To(BeFalse())
+		Expect(dup.ID).To(Equal(build.ID))
+
+		_, _, err = ub.GetDeployment("0000000000000000")
+		Expect(err).To(BeAssignableToTypeOf(buildlog.NotFoundError("")))
+	})
+	It("should serialize concurrent deployments with build lock", func() {
+		b1, _, err := ub.SubmitUpload("test", archive("c1"), true, container.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		b2, _, err := ub.SubmitUpload("test", archive("c2"), true, container.DeployOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		status := func(id string) func() string {
+			return func() string {
+				_, b, err := ub.GetDeployment(id)
+				Expect(err).NotTo(HaveOccurred())
+				return b.Status
+			}
+		}
+		Eventually(status(b1.ID), deployTimeout).Should(Equal(buildlog.StatusSucceeded))
+		Eventually(status(b2.ID), deployTimeout).Should(Equal(buildlog.StatusSucceeded))
+
+		Expect(ub.GetBuildLock("test")).To(BeNil())
+		_, err = ub.GetBuildLock("nonexist")
+		Expect(err).To(BeAssignableToTypeOf(br.ApplicationNotFoundError("")))
+	})
+})
+
+var _ = Describe("Deploy rules", func() {
+	var (
+		ctx  = context.Background()
+		user = userdb.BasicUser{Name: TESTUSER, Namespace: NAMESPACE}
+		ub   *br.UserBroker
+	)
+
+	BeforeEach(func() {
+		Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		ub = broker.NewUserBroker(&user, ctx)
+		_, _, err := ub.CreateApplication(container.CreateOptions{Name: "test"}, []string{"mockb"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ub.StartApplication("test", nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ub.RemoveApplication("test")
+		broker.RemoveUser(TESTUSER)
+	})
+
+	var archive = func(files ...string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for i := 0; i < len(files); i += 2 {
+			tw.WriteHeader(&tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1]))})
+			tw.Write([]byte(files[i+1]))
+		}
+		tw.Close()
+		zw.Close()
+		return &buf
+	}
+
+	var upload = func(content *bytes.Buffer) string {
+		var out bytes.Buffer
+		log := serverlog.Encap(&out, &out)
+		ExpectWithOffset(1, ub.Upload("test", content, false, container.DeployOptions{}, log)).To(Succeed())
+		return out.String()
+	}
+
+	It("should choose hot deployment or full build by changed files", func() {
+		Expect(upload(archive("app.js", "v1"))).To(ContainSubstring("Full build required by changed file app.js"))
+		Expect(upload(archive("app.js", "v1", "static/index.html", "v1"))).To(ContainSubstring("Hot deploying changed files"))
+
+		// outputs of the previous build are kept by hot deployment
+		cs, err := broker.FindApplications(ctx, "test", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		for _, c := range cs {
+			_, err = c.ContainerStatPath(ctx, c.ID, c.RepoDir()+"/built")
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(upload(archive("app.js", "v2", "static/index.html", "v1"))).To(ContainSubstring("Full build required by changed file app.js"))
+	})
+})
@@ -1,6 +1,9 @@
 package broker
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/pkg/errors"
 	"golang.org/x/net/context"
@@ -9,6 +12,10 @@ import (
 func (br *Broker) CreateUser(user userdb.User, password string) (err error) {
 	basic := user.Basic()
 
+	if basic.Namespace != "" && !namespacePattern.MatchString(basic.Namespace) {
+		return fmt.Errorf("The namespace can only contains lower case letters, digits, or underscores")
+	}
+
 	// create the user in the database
 	err = br.Users.Create(user, password)
 	if err != nil {
@@ -56,6 +63,9 @@ func (br *Broker) RemoveUser(username string) (err error) {
 		br.Hub.RemoveNamespace(user.Namespace)
 	}
 
+	// revoke access to namespaces shared with the user
+	errors.Add(br.removeMemberships(user.Name))
+
 	// remove user from user database
 	errors.Add(br.Users.Remove(user.Name))
 
@@ -67,3 +77,46 @@ func (br *Broker) GetUser(username string) (userdb.User, error) {
 	err := br.Users.Find(username, &user)
 	return &user, err
 }
+
+// ListUsers returns all users sorted by name.
+func (br *Broker) ListUsers() ([]*userdb.BasicUser, error) {
+	var users []*userdb.BasicUser
+	if err := br.Users.Search(userdb.Args{}, &users); err != nil {
+		return nil, err
+	}
+	sort.Sort(byUserName(users))
+	return users, nil
+}
+
+// SetUserActive enables or disables the user. A disabled user cannot login
+// or authenticate with access tokens, but the applications keep running.
+// Enabling a registered user approves the registration.
+func (br *Broker) SetUserActive(username string, active bool) error {
+	if active {
+		var user userdb.BasicUser
+		if err := br.Users.Find(username, &user); err != nil {
+			return err
+		}
+		if err := br.claimRegisteredNamespace(&user); err != nil {
+			return err
+		}
+		return br.Users.Update(username, userdb.Args{"inactive": false, "registration": nil})
+	}
+	return br.Users.Update(username, userdb.Args{"inactive": true})
+}
+
+// SetUserNamespace changes the namespace of the user. The namespace can only
+// be changed if the user has no applications.
+func (br *Broker) SetUserNamespace(username, namespace string) error {
+	var user userdb.BasicUser
+	if err := br.Users.Find(username, &user); err != nil {
+		return err
+	}
+	return br.NewUserBroker(&user, context.Background()).CreateNamespace(namespace)
+}
+
+type byUserName []*userdb.BasicUser
+
+func (a byUserName) Len() int           { return len(a) }
+func (a byUserName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byUserName) Less(i, j int) bool { return a[i].Name < a[j].Name }
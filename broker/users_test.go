@@ -79,4 +79,53 @@ var _ = Describe("Users", func() {
 			Expect(br.Refresh()).To(BeUserNotFound(TESTUSER))
 		})
 	})
+
+	Describe("Administer users", func() {
+		BeforeEach(func() {
+			user := userdb.BasicUser{Name: TESTUSER}
+			Expect(broker.CreateUser(&user, "test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			broker.RemoveUser(TESTUSER)
+		})
+
+		It("should list users", func() {
+			users, err := broker.ListUsers()
+			Expect(err).NotTo(HaveOccurred())
+
+			var names []string
+			for _, u := range users {
+				names = append(names, u.Name)
+			}
+			Expect(names).To(ContainElement(TESTUSER))
+		})
+
+		It("should disable and enable user", func() {
+			Expect(broker.SetUserActive(TESTUSER, false)).To(Succeed())
+			_, err := broker.Users.Authenticate(TESTUSER, "test")
+			Expect(err).To(HaveOccurred())
+
+			Expect(broker.SetUserActive(TESTUSER, true)).To(Succeed())
+			_, err = broker.Users.Authenticate(TESTUSER, "test")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reset password", func() {
+			Expect(broker.Users.SetPassword(TESTUSER, "newpass")).To(Succeed())
+			_, err := broker.Users.Authenticate(TESTUSER, "newpass")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should change namespace", func() {
+			var user userdb.BasicUser
+			Expect(broker.SetUserNamespace(TESTUSER, NAMESPACE)).To(Succeed())
+			Expect(broker.Users.Find(TESTUSER, &user)).To(Succeed())
+			Expect(user.Namespace).To(Equal(NAMESPACE))
+		})
+
+		It("should reject malformed namespace", func() {
+			Expect(broker.SetUserNamespace(TESTUSER, "Bad-Namespace")).NotTo(Succeed())
+		})
+	})
 })
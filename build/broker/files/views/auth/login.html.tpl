@@ -15,6 +15,9 @@
           <div class="form-group">
             <input type="password" class="form-control" name="password" placeholder="密码">
           </div>
+          <div class="form-group">
+            <input type="text" class="form-control" name="code" placeholder="两步验证码（如已启用）" autocomplete="off">
+          </div>
           {{if .showRemember}}
           <div class="form-group">
             <input type="checkbox" name="rm" value="true"> 记住我的登录信息
@@ -47,20 +47,32 @@ esac
 
 # the following lines are in sorted order, FYI
 clone git github.com/aarondl/tpl e4905e745b4e2371caa002edf3ccdaf798ffd4b4
+clone git github.com/aws/aws-sdk-go v1.4.22
+clone git github.com/beorn7/perks 4c0e84591b9aa9e6dcfdf3e020114cd81f89d5f9
+clone git github.com/boltdb/bolt v1.3.0
 clone git github.com/dgrijalva/jwt-go v3.0.0
 clone git github.com/docker/distribution v2.5.0
 clone git github.com/docker/engine-api v0.4.0
 clone git github.com/docker/go-connections v0.2.1
 clone git github.com/docker/go-units v0.3.1
 clone git github.com/garyburd/redigo v1.0.0
+clone git github.com/go-ini/ini v1.21.1
+clone git github.com/golang/protobuf 8d92cf5fc15a4382f8964b08e1f42a75c0591aa3
 clone git github.com/gorilla/context aed02d124ae4a0e94fea4541c8effd05bf0c8296
 clone git github.com/gorilla/mux 9fa818a44c2bf1396a17f9d5a3c0f6dd39d2ff8e
 clone git github.com/gorilla/securecookie ff356348f74133a59d3e93aa24b5b4551b6fe90d
 clone git github.com/gorilla/sessions 56ba4b0a11da87516629a57408a5f7e4c8ea7b0b
+clone git github.com/jmespath/go-jmespath 0.2.2
 clone git github.com/justinas/nosurf 2e708f28095ba17463e41438bbfd53abae8b6794
 clone git github.com/kardianos/osext c2c54e542fb797ad986b31721e1baedf214ca413
+clone git github.com/lib/pq v1.0.0
+clone git github.com/matttproud/golang_protobuf_extensions v1.0.0
 clone git github.com/opencontainers/runc 8e22b1d36b2ec794e16fb47cf662c50e2553cb9f
 clone git github.com/oxtoacart/bpool 4e1c5567d7c2dd59fa4c7c83d34c2f3528b025d6
+clone git github.com/prometheus/client_golang v0.8.0
+clone git github.com/prometheus/client_model fa8ad6fec33561be4280a8f0514318c79d7f6cb6
+clone git github.com/prometheus/common ebdfc6da46522d58825777cf1f90490a5b1ef1d8
+clone git github.com/prometheus/procfs abf152e5f3e97f2fafac028d2cc06c1feb87ffa5
 clone git github.com/sevlyar/go-daemon 3bf5e993af87194518e81cf9a81c1c53190a8911
 clone git github.com/Sirupsen/logrus v0.10.0
 clone git github.com/Microsoft/go-winio v0.3.4
@@ -68,8 +80,10 @@ clone git golang.org/x/crypto 5bcd134fee4dd1475da17714aac19c0aa0142e2f https://g
 clone git golang.org/x/net 30db96677b74e24b967e23f911eb3364fc61a011 https://github.com/golang/net.git
 clone git golang.org/x/oauth2 65a8d08c6292395d47053be10b3c5e91960def76 https://github.com/golang/oauth2.git
 clone git golang.org/x/sys eb2c74142fd19a79b3f237334c7384d5167b1b46 https://github.com/golang/sys.git
+clone git gopkg.in/asn1-ber.v1 v1.2
 clone git gopkg.in/authboss.v0 586415a7db9d2b1538cd2c05ca2dbbce0ee9cc62
 clone git gopkg.in/cookieo9/resources-go.v2 d27c04069d0d5dfe11c202dacbf745ae8d1ab181
+clone git gopkg.in/ldap.v2 v2.5.0
 clone git gopkg.in/mgo.v2 29cc868a5ca65f401ff318143f9408d02f4799cc
 clone git gopkg.in/yaml.v2 a83829b6f1293c91addabc89d0571c246397bbf4
 clean
@@ -0,0 +1,340 @@
+// Package buildlog keeps the output of application deployments, so the
+// build log can be retrieved after the live stream is gone.
+package buildlog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Build status. A queued build is waiting to be started, and a running
+// build proceeds to building and distributing stages.
+const (
+	StatusQueued       = "queued"
+	StatusRunning      = "running"
+	StatusBuilding     = "building"
+	StatusDistributing = "distributing"
+	StatusSucceeded    = "succeeded"
+	StatusFailed       = "failed"
+)
+
+// Build describes a deployment of an application.
+type Build struct {
+	ID       string
+	Strategy string
+	Started  time.Time
+	Finished time.Time
+	Status   string
+	Error    string `json:",omitempty"`
+	Digest   string `json:",omitempty"`
+
+	// The deployment status of each container.
+	Containers []*ContainerStatus `json:",omitempty"`
+}
+
+// ContainerStatus describes the last event happened on a container during
+// the distribution of a deployment.
+type ContainerStatus struct {
+	ID      string
+	Event   string
+	Bytes   int64  `json:",omitempty"`
+	Error   string `json:",omitempty"`
+	Updated time.Time
+}
+
+type NotFoundError string
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("Build %s not found", string(e))
+}
+
+func (e NotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// Store keeps build logs of a limited number of recent deployments for
+// each application. Build logs are stored per namespace so a build can
+// be retrieved by its ID.
+type Store struct {
+	dir  string
+	keep int
+	mu   sync.Mutex
+}
+
+func New() (*Store, error) {
+	dir := config.GetOrDefault("build.log.dir", "/var/lib/cloudway/builds")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	keep, err := strconv.Atoi(config.GetOrDefault("build.log.keep", "20"))
+	if err != nil || keep <= 0 {
+		return nil, fmt.Errorf("Invalid build.log.keep configuration: %s", config.Get("build.log.keep"))
+	}
+
+	return &Store{dir: dir, keep: keep}, nil
+}
+
+func (s *Store) indexFile(namespace, name string) string {
+	return filepath.Join(s.dir, namespace, name+".json")
+}
+
+func (s *Store) logFile(namespace, id string) string {
+	return filepath.Join(s.dir, namespace, id+".log")
+}
+
+// Builds returns recent builds of the application, the most recent first.
+func (s *Store) Builds(namespace, name string) ([]*Build, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(namespace, name)
+}
+
+func (s *Store) load(namespace, name string) ([]*Build, error) {
+	data, err := ioutil.ReadFile(s.indexFile(namespace, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []*Build
+	err = json.Unmarshal(data, &builds)
+	return builds, err
+}
+
+// save the build in the index, replacing the previous record of the same
+// build. A new build is added as the most recent one, and logs of oldest
+// builds exceeding the limit are removed.
+func (s *Store) save(namespace, name string, build *Build) error {
+	builds, err := s.load(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	index := builds
+	for i, b := range builds {
+		if b.ID == build.ID {
+			index[i] = build
+			build = nil
+			break
+		}
+	}
+	if build != nil {
+		index = []*Build{build}
+		for _, b := range builds {
+			if len(index) < s.keep {
+				index = append(index, b)
+			} else {
+				os.Remove(s.logFile(namespace, b.ID))
+			}
+		}
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.indexFile(namespace, name), data, 0644)
+}
+
+// Find the build of the application by ID.
+func (s *Store) Find(namespace, name, id string) (*Build, error) {
+	builds, err := s.Builds(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range builds {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return nil, NotFoundError(id)
+}
+
+// Create a build log for a new deployment of the application. The log
+// must be closed when the deployment finished.
+func (s *Store) Create(namespace, name, strategy string) (*Log, error) {
+	return s.create(namespace, name, &Build{Strategy: strategy, Status: StatusRunning})
+}
+
+// Queue creates a build log for a deployment of the content identified by
+// the digest, which is started later. The log must be closed when the
+// deployment finished.
+func (s *Store) Queue(namespace, name, strategy, digest string) (*Log, error) {
+	return s.create(namespace, name, &Build{Strategy: strategy, Status: StatusQueued, Digest: digest})
+}
+
+func (s *Store) create(namespace, name string, build *Build) (*Log, error) {
+	build.ID = newID()
+	build.Started = time.Now()
+
+	if err := os.MkdirAll(filepath.Join(s.dir, namespace), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(s.logFile(namespace, build.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	err = s.save(namespace, name, build)
+	s.mu.Unlock()
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &Log{store: s, namespace: namespace, name: name, build: build, f: f}, nil
+}
+
+// Open the build log by ID.
+func (s *Store) Open(namespace, id string) (io.ReadCloser, error) {
+	if !validID(id) {
+		return nil, NotFoundError(id)
+	}
+	f, err := os.Open(s.logFile(namespace, id))
+	if os.IsNotExist(err) {
+		return nil, NotFoundError(id)
+	}
+	return f, err
+}
+
+// Remove all build logs of the application.
+func (s *Store) Remove(namespace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	builds, err := s.load(namespace, name)
+	if err != nil {
+		return err
+	}
+	for _, b := range builds {
+		os.Remove(s.logFile(namespace, b.ID))
+	}
+
+	err = os.Remove(s.indexFile(namespace, name))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Log writes the output of a deployment to the build log. Failures to
+// write the log are reported once and don't abort the deployment.
+type Log struct {
+	store     *Store
+	namespace string
+	name      string
+	build     *Build
+	mu        sync.Mutex
+	f         *os.File
+	err       error
+}
+
+// ID returns the ID of the build.
+func (l *Log) ID() string {
+	return l.build.ID
+}
+
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		if _, l.err = l.f.Write(p); l.err != nil {
+			logrus.WithError(l.err).Warnf("Failed to write build log %s", l.build.ID)
+		}
+	}
+	return len(p), nil
+}
+
+// SetStatus updates the status of a running build. Failures to save the
+// status are logged and don't abort the deployment.
+func (l *Log) SetStatus(status string) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.build.Status = status
+	if err := l.store.save(l.namespace, l.name, l.build); err != nil {
+		logrus.WithError(err).Warnf("Failed to save build status %s", l.build.ID)
+	}
+}
+
+// SetContainerStatus updates the deployment status of a container. Failures
+// to save the status are logged and don't abort the deployment.
+func (l *Log) SetContainerStatus(id, event string, bytes int64, errmsg string) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	var cs *ContainerStatus
+	for _, c := range l.build.Containers {
+		if c.ID == id {
+			cs = c
+			break
+		}
+	}
+	if cs == nil {
+		cs = &ContainerStatus{ID: id}
+		l.build.Containers = append(l.build.Containers, cs)
+	}
+	cs.Event, cs.Error, cs.Updated = event, errmsg, time.Now()
+	if bytes != 0 {
+		cs.Bytes = bytes
+	}
+
+	if err := l.store.save(l.namespace, l.name, l.build); err != nil {
+		logrus.WithError(err).Warnf("Failed to save build status %s", l.build.ID)
+	}
+}
+
+// Close the build log and record the result of deployment.
+func (l *Log) Close(deployErr error) error {
+	l.mu.Lock()
+	err := l.f.Close()
+	l.mu.Unlock()
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	l.build.Finished = time.Now()
+	if deployErr != nil {
+		l.build.Status = StatusFailed
+		l.build.Error = deployErr.Error()
+	} else {
+		l.build.Status = StatusSucceeded
+	}
+
+	if er := l.store.save(l.namespace, l.name, l.build); er != nil {
+		err = er
+	}
+	return err
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,137 @@
+package buildlog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBuildLog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Build Log Suite")
+}
+
+var _ = Describe("Store", func() {
+	const (
+		NAMESPACE = "buildlog_test"
+		NAME      = "test"
+	)
+
+	var store *Store
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "buildlog")
+		Expect(err).NotTo(HaveOccurred())
+		store = &Store{dir: dir, keep: 3}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(store.dir)
+	})
+
+	var readLog = func(id string) string {
+		r, err := store.Open(NAMESPACE, id)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should record build output and result", func() {
+		log, err := store.Create(NAMESPACE, NAME, "rolling")
+		Expect(err).NotTo(HaveOccurred())
+
+		builds, err := store.Builds(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builds).To(HaveLen(1))
+		Expect(builds[0].ID).To(Equal(log.ID()))
+		Expect(builds[0].Status).To(Equal(StatusRunning))
+
+		log.Write([]byte("building\n"))
+		Expect(log.Close(errors.New("build failed"))).To(Succeed())
+
+		builds, err = store.Builds(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builds[0].Status).To(Equal(StatusFailed))
+		Expect(builds[0].Error).To(Equal("build failed"))
+		Expect(builds[0].Strategy).To(Equal("rolling"))
+		Expect(readLog(log.ID())).To(Equal("building\n"))
+	})
+
+	It("should keep limited number of builds", func() {
+		var ids []string
+		for i := 0; i < 5; i++ {
+			log, err := store.Create(NAMESPACE, NAME, "all-at-once")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(log.Close(nil)).To(Succeed())
+			ids = append(ids, log.ID())
+		}
+
+		builds, err := store.Builds(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builds).To(HaveLen(3))
+		Expect(builds[0].ID).To(Equal(ids[4]))
+		Expect(builds[2].ID).To(Equal(ids[2]))
+
+		_, err = store.Open(NAMESPACE, ids[0])
+		Expect(err).To(Equal(NotFoundError(ids[0])))
+
+		Expect(store.Remove(NAMESPACE, NAME)).To(Succeed())
+		builds, err = store.Builds(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builds).To(BeEmpty())
+		_, err = store.Open(NAMESPACE, ids[4])
+		Expect(err).To(Equal(NotFoundError(ids[4])))
+	})
+
+	It("should track status of queued build", func() {
+		log, err := store.Queue(NAMESPACE, NAME, "rolling", "digest")
+		Expect(err).NotTo(HaveOccurred())
+
+		build, err := store.Find(NAMESPACE, NAME, log.ID())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.Status).To(Equal(StatusQueued))
+		Expect(build.Digest).To(Equal("digest"))
+
+		log.SetStatus(StatusBuilding)
+		build, err = store.Find(NAMESPACE, NAME, log.ID())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.Status).To(Equal(StatusBuilding))
+
+		Expect(log.Close(nil)).To(Succeed())
+		build, err = store.Find(NAMESPACE, NAME, log.ID())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.Status).To(Equal(StatusSucceeded))
+
+		_, err = store.Find(NAMESPACE, NAME, "0000000000000000")
+		Expect(err).To(Equal(NotFoundError("0000000000000000")))
+	})
+
+	It("should track deployment status of containers", func() {
+		log, err := store.Create(NAMESPACE, NAME, "rolling")
+		Expect(err).NotTo(HaveOccurred())
+
+		log.SetContainerStatus("c1", "copying", 1024, "")
+		log.SetContainerStatus("c2", "failed", 0, "disk full")
+		log.SetContainerStatus("c1", "signaled", 0, "")
+		Expect(log.Close(nil)).To(Succeed())
+
+		build, err := store.Find(NAMESPACE, NAME, log.ID())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.Containers).To(HaveLen(2))
+		Expect(build.Containers[0].ID).To(Equal("c1"))
+		Expect(build.Containers[0].Event).To(Equal("signaled"))
+		Expect(build.Containers[0].Bytes).To(Equal(int64(1024)))
+		Expect(build.Containers[1].Error).To(Equal("disk full"))
+	})
+
+	It("should reject malformed build ID", func() {
+		_, err := store.Open(NAMESPACE, "../test.json")
+		Expect(err).To(Equal(NotFoundError("../test.json")))
+	})
+})
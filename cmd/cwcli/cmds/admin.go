@@ -0,0 +1,217 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/gopass"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const adminUserUsage = `Usage: cwcli admin user COMMAND [ARGS...]
+
+Manage users of the platform, requires administrator privilege.
+
+Commands:
+
+  list               List all users
+  info               Show user information
+  create             Create a new user
+  remove             Remove a user and all applications of the user
+  disable            Disable a user from login
+  enable             Enable a disabled user, or approve a registered user
+  passwd             Reset the password of a user
+  namespace          Change the namespace of a user
+
+Use "cwcli quota --user USERNAME" to show or change quota of a user.
+`
+
+func (cli *CWCli) CmdAdminUser(args ...string) error {
+	commands := map[string]func(...string) error{
+		"list":      cli.cmdAdminUserList,
+		"info":      cli.cmdAdminUserInfo,
+		"create":    cli.cmdAdminUserCreate,
+		"remove":    cli.cmdAdminUserRemove,
+		"disable":   cli.cmdAdminUserDisable,
+		"enable":    cli.cmdAdminUserEnable,
+		"passwd":    cli.cmdAdminUserPasswd,
+		"namespace": cli.cmdAdminUserNamespace,
+	}
+
+	if len(args) == 0 || commands[args[0]] == nil {
+		fmt.Fprint(cli.stdout, adminUserUsage)
+		os.Exit(1)
+	}
+	return commands[args[0]](args[1:]...)
+}
+
+func (cli *CWCli) cmdAdminUserList(args ...string) error {
+	cmd := cli.Subcmd("admin user list", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	users, err := cli.ListUsers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("NAME", "NAMESPACE", "ROLE", "STATUS", "APPLICATIONS")
+	for _, u := range users {
+		tab.AddRow(u.Name, u.Namespace, u.Role, userStatus(u), fmt.Sprint(len(u.Applications)))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) cmdAdminUserInfo(args ...string) error {
+	cmd := cli.Subcmd("admin user info", "USERNAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	u, err := cli.GetUser(context.Background(), cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.stdout, "Name:         %s\n", u.Name)
+	fmt.Fprintf(cli.stdout, "Namespace:    %s\n", u.Namespace)
+	fmt.Fprintf(cli.stdout, "Role:         %s\n", u.Role)
+	fmt.Fprintf(cli.stdout, "Status:       %s\n", userStatus(u))
+	fmt.Fprintf(cli.stdout, "Applications: %s\n", strings.Join(u.Applications, ", "))
+	return nil
+}
+
+func (cli *CWCli) cmdAdminUserCreate(args ...string) error {
+	var req types.CreateUser
+
+	cmd := cli.Subcmd("admin user create", "USERNAME [PASSWORD]")
+	cmd.StringVar(&req.Namespace, []string{"n", "-namespace"}, "", "The namespace of the user")
+	cmd.StringVar(&req.Role, []string{"-role"}, "", "The user role, 'admin', 'developer' or 'viewer'")
+	cmd.Require(mflag.Min, 1)
+	cmd.Require(mflag.Max, 2)
+	cmd.ParseFlags(args, true)
+
+	req.Name = strings.ToLower(cmd.Arg(0))
+	req.Password = cmd.Arg(1)
+	if req.Password == "" {
+		var err error
+		if req.Password, err = cli.readNewPassword(); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	u, err := cli.CreateUser(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "User %s created\n", u.Name)
+	return nil
+}
+
+func (cli *CWCli) cmdAdminUserRemove(args ...string) error {
+	cmd := cli.Subcmd("admin user remove", "USERNAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveUser(context.Background(), cmd.Arg(0))
+}
+
+func (cli *CWCli) cmdAdminUserDisable(args ...string) error {
+	cmd := cli.Subcmd("admin user disable", "USERNAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetUserActive(context.Background(), cmd.Arg(0), false)
+}
+
+func (cli *CWCli) cmdAdminUserEnable(args ...string) error {
+	cmd := cli.Subcmd("admin user enable", "USERNAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetUserActive(context.Background(), cmd.Arg(0), true)
+}
+
+func (cli *CWCli) cmdAdminUserPasswd(args ...string) error {
+	cmd := cli.Subcmd("admin user passwd", "USERNAME [PASSWORD]")
+	cmd.Require(mflag.Min, 1)
+	cmd.Require(mflag.Max, 2)
+	cmd.ParseFlags(args, true)
+
+	password := cmd.Arg(1)
+	if password == "" {
+		var err error
+		if password, err = cli.readNewPassword(); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.ResetPassword(context.Background(), cmd.Arg(0), password)
+}
+
+func (cli *CWCli) cmdAdminUserNamespace(args ...string) error {
+	cmd := cli.Subcmd("admin user namespace", "USERNAME NAMESPACE")
+	cmd.Require(mflag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetUserNamespace(context.Background(), cmd.Arg(0), cmd.Arg(1))
+}
+
+// readNewPassword prompts for a new password twice.
+func (cli *CWCli) readNewPassword() (string, error) {
+	fmt.Fprintf(cli.stdout, "New password: ")
+	pass, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(cli.stdout, "Retype new password: ")
+	again, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return "", err
+	}
+	if string(pass) != string(again) {
+		return "", fmt.Errorf("Passwords do not match")
+	}
+	return string(pass), nil
+}
+
+func userStatus(u *types.UserInfo) string {
+	if u.Pending != "" {
+		return "pending " + u.Pending
+	}
+	if u.Inactive {
+		return "disabled"
+	}
+	return "active"
+}
@@ -12,10 +12,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docker/go-units"
+	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/net/context"
 
 	"github.com/cloudway/platform/api/types"
@@ -34,8 +37,9 @@ list applications
 
 Additional commands, type "cwcli help COMMAND" for more details:
 
+  app:list           List applications
   app:create         Create a new application
-  app:remove         Permanently remove an application
+  app:remove         Permanently remove an application, alias app:delete
   app:start          Start an application
   app:stop           Stop an application
   app:restart        Restart an application
@@ -49,37 +53,62 @@ Additional commands, type "cwcli help COMMAND" for more details:
   app:dump           Dump application data
   app:restore        Restore application data
   app:scale          Scale an application
+  app:resources      Show or change resource limits of an application
   app:info           Show application information
   app:env            Get or set application environment variables
   app:open           Open the application in a web brower
-  app:ssh            Log into application console via SSH
+  app:ssh            Log into application console or run a command
+  app:ls             List files in an application container
+  app:cp             Copy files between an application container and the local filesystem
 `
 
 func (cli *CWCli) CmdApps(args ...string) error {
-	var help bool
+	var help, js bool
 
 	cmd := cli.Subcmd("app", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
 	cmd.ParseFlags(args, false)
 
 	if help {
 		fmt.Fprintln(cli.stdout, appCmdUsage)
 		os.Exit(0)
 	}
+	return cli.listApps(js)
+}
+
+func (cli *CWCli) CmdAppList(args ...string) error {
+	var js bool
+
+	cmd := cli.Subcmd("app:list", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
+	cmd.ParseFlags(args, true)
 
+	return cli.listApps(js)
+}
+
+func (cli *CWCli) listApps(js bool) error {
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
 
-	if apps, err := cli.GetApplications(context.Background()); err != nil {
+	apps, err := cli.GetApplications(context.Background())
+	if err != nil {
 		return err
+	}
+
+	if js {
+		if apps == nil {
+			apps = []string{}
+		}
+		cli.writeJson(apps)
 	} else {
 		for _, name := range apps {
 			fmt.Fprintln(cli.stdout, name)
 		}
 	}
-
 	return nil
 }
 
@@ -190,15 +219,52 @@ func (cli *CWCli) CmdAppInfo(args ...string) error {
 		fmt.Fprintf(cli.stdout, "URL:        %s\n", app.URL)
 		fmt.Fprintf(cli.stdout, "Source:     %s\n", app.CloneURL)
 		fmt.Fprintf(cli.stdout, "SSH:        %s\n", app.SSHURL)
+		if app.State != "" {
+			fmt.Fprintf(cli.stdout, "State:      %s\n", app.State)
+		}
+		if app.Degraded {
+			fmt.Fprintf(cli.stdout, "Status:     %s\n", ansi.Fail("degraded"))
+		}
 		fmt.Fprintf(cli.stdout, "Services:\n")
 		for _, p := range app.Services {
 			fmt.Fprintf(cli.stdout, " - %s\n", p.DisplayName)
 		}
+		if len(app.Status) != 0 {
+			fmt.Fprintf(cli.stdout, "Containers:\n")
+			writeContainerStatus(cli.stdout, app.Status)
+		}
+		if len(app.Health) != 0 {
+			fmt.Fprintf(cli.stdout, "Health:\n")
+			for _, h := range app.Health {
+				fmt.Fprintf(cli.stdout, " - %s %s: %s", shortID(h.ID), h.DisplayName, h.Status)
+				if h.Error != "" {
+					fmt.Fprintf(cli.stdout, " (%s)", h.Error)
+				}
+				fmt.Fprintln(cli.stdout)
+			}
+		}
 	}
 
 	return nil
 }
 
+// writeContainerStatus writes the state of containers, one per line, with
+// restarts and the last abnormal exit if any.
+func writeContainerStatus(w io.Writer, status []*types.ContainerStatus) {
+	for _, st := range status {
+		fmt.Fprintf(w, " - %s %s: %s", shortID(st.ID), st.DisplayName, wrapState(st.State))
+		if st.Restarts != 0 {
+			fmt.Fprintf(w, " (%d restarts)", st.Restarts)
+		}
+		if e := st.LastExit; e != nil && e.OOMKilled {
+			fmt.Fprintf(w, " (OOM killed at %s)", e.Time.Local().Format(time.Stamp))
+		} else if e != nil && e.ExitCode != 0 {
+			fmt.Fprintf(w, " (exited with code %d at %s)", e.ExitCode, e.Time.Local().Format(time.Stamp))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
 func (cli *CWCli) CmdAppOpen(args ...string) error {
 	cmd := cli.Subcmd("app:open", "")
 	cmd.Require(mflag.Exact, 0)
@@ -249,9 +315,14 @@ func (cli *CWCli) CmdAppClone(args ...string) error {
 }
 
 func (cli *CWCli) CmdAppUpload(args ...string) error {
+	var deploy deployFlags
+	var detach bool
+
 	cmd := cli.Subcmd("app:upload", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&detach, []string{"d", "-detach"}, false, "Queue the deployment and print the deployment ID without waiting")
+	deploy.install(cmd)
 	cmd.ParseFlags(args, true)
 
 	name := cli.getAppName(cmd)
@@ -264,7 +335,55 @@ func (cli *CWCli) CmdAppUpload(args ...string) error {
 		return err
 	}
 
-	return cli.upload(name, path, binary)
+	return cli.upload(name, path, binary, detach, deploy.options())
+}
+
+// deployFlags holds command line flags controlling deployment.
+type deployFlags struct {
+	condition string
+	strategy  string
+	batch     int
+	failures  int
+	retries   int
+	onFailure string
+	canary    int
+	weight    int
+	delta     bool
+	buildEnv  map[string]string
+	secrets   map[string]string
+	pullEnv   bool
+}
+
+func (f *deployFlags) install(cmd *mflag.FlagSet) {
+	cmd.StringVar(&f.condition, []string{"-if"}, "", "Deploy only if the current application is 'healthy' or 'unhealthy'")
+	cmd.StringVar(&f.strategy, []string{"-strategy"}, "", "Deploy strategy, 'all-at-once', 'rolling', 'blue-green' or 'canary'")
+	cmd.IntVar(&f.batch, []string{"-batch"}, 0, "Number of containers updated at a time in rolling deployment")
+	cmd.IntVar(&f.failures, []string{"-max-failures"}, 0, "Number of failed containers tolerated in rolling deployment")
+	cmd.IntVar(&f.retries, []string{"-retries"}, 0, "Number of times to retry deploying to a failed container")
+	cmd.StringVar(&f.onFailure, []string{"-on-failure"}, "", "On container failure, 'continue' with other containers or 'rollback' updated containers")
+	cmd.IntVar(&f.canary, []string{"-canary"}, 0, "Percentage of containers updated in canary deployment")
+	cmd.IntVar(&f.weight, []string{"-weight"}, 0, "Percentage of traffic routed to canary containers")
+	cmd.BoolVar(&f.delta, []string{"-delta"}, false, "Only transfer files changed since the previous deployment")
+	cmd.Var(opts.NewMapOptsRef(&f.buildEnv, opts.ValidateEnv), []string{"-build-env"}, "Set build variable (KEY=VALUE, or KEY to pass local variable)")
+	cmd.Var(opts.NewMapOptsRef(&f.secrets, opts.ValidateEnv), []string{"-build-secret"}, "Set secret build variable masked in build log")
+	cmd.BoolVar(&f.pullEnv, []string{"-pull-env"}, false, "Pull the application environment into the build")
+}
+
+func (f *deployFlags) options() types.DeployOptions {
+	return types.DeployOptions{
+		Condition:        f.condition,
+		Strategy:         f.strategy,
+		BatchSize:        f.batch,
+		FailureThreshold: f.failures,
+		Retries:          f.retries,
+		OnFailure:        f.onFailure,
+		CanaryPercent:    f.canary,
+		CanaryWeight:     f.weight,
+		Delta:            f.delta,
+		BuildEnv:         f.buildEnv,
+		BuildSecrets:     f.secrets,
+		PullEnv:          f.pullEnv,
+	}
 }
 
 func (cli *CWCli) download(name string) error {
@@ -296,7 +415,7 @@ func (cli *CWCli) download(name string) error {
 	return cfg.Save()
 }
 
-func (cli *CWCli) upload(name, path string, binary bool) error {
+func (cli *CWCli) upload(name, path string, binary, detach bool, opts types.DeployOptions) error {
 	// create temporary archive file containing upload files
 	tempfile, err := ioutil.TempFile("", "deploy")
 	if err != nil {
@@ -321,7 +440,18 @@ func (cli *CWCli) upload(name, path string, binary bool) error {
 		return err
 	}
 
-	return cli.Upload(context.Background(), name, tempfile, binary, cli.stdout, cli.stderr)
+	if !detach {
+		stdout, stderr, progress := newProgressOutput(cli.stdout, cli.stderr)
+		defer progress.Close()
+		return cli.Upload(context.Background(), name, tempfile, binary, opts, stdout, stderr)
+	}
+
+	build, err := cli.SubmitUpload(context.Background(), name, tempfile, binary, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Deployment %s %s\n", build.ID, build.Status)
+	return nil
 }
 
 func (cli *CWCli) CmdAppDump(args ...string) (err error) {
@@ -388,13 +518,15 @@ func (cli *CWCli) CmdAppRestore(args ...string) (err error) {
 }
 
 func (cli *CWCli) CmdAppSSH(args ...string) error {
-	var name, service, identity string
+	var name, service, container, identity string
+	var useSSH bool
 
-	cmd := cli.Subcmd("app:ssh", "")
-	cmd.Require(mflag.Exact, 0)
+	cmd := cli.Subcmd("app:ssh", "[COMMAND...]")
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
 	cmd.StringVar(&service, []string{"s", "-service"}, "", "Service name")
-	cmd.StringVar(&identity, []string{"i"}, "", "Identity file")
+	cmd.StringVar(&container, []string{"c", "-container"}, "", "Container ID")
+	cmd.StringVar(&identity, []string{"i"}, "", "Identity file, implies --ssh")
+	cmd.BoolVar(&useSSH, []string{"-ssh"}, false, "Connect with the ssh client instead of the API server")
 	cmd.ParseFlags(args, true)
 	name = cli.getAppName(cmd)
 
@@ -402,6 +534,14 @@ func (cli *CWCli) CmdAppSSH(args ...string) error {
 		return err
 	}
 
+	if !useSSH && identity == "" {
+		return cli.execShell(name, types.ExecOptions{
+			Service:   service,
+			Container: container,
+			Cmd:       cmd.Args(),
+		})
+	}
+
 	app, err := cli.GetApplicationInfo(context.Background(), name)
 	if err != nil {
 		return err
@@ -426,11 +566,12 @@ func (cli *CWCli) CmdAppSSH(args ...string) error {
 		sshCmdArgs = append(sshCmdArgs, "-i", identity)
 	}
 
-	container := sshurl.User.Username()
+	container = sshurl.User.Username()
 	if service != "" {
 		container = service + "." + container
 	}
 	sshCmdArgs = append(sshCmdArgs, container+"@"+host)
+	sshCmdArgs = append(sshCmdArgs, cmd.Args()...)
 
 	sshCmd := exec.Command("ssh", sshCmdArgs...)
 	sshCmd.Stdin = os.Stdin
@@ -441,15 +582,19 @@ func (cli *CWCli) CmdAppSSH(args ...string) error {
 
 func (cli *CWCli) CmdAppCreate(args ...string) error {
 	var req types.CreateApplication
-	var noclone, binary bool
+	var noclone, binary, js bool
 
 	cmd := cli.Subcmd("app:create", "[OPTIONS] NAME")
 	cmd.Require(mflag.Exact, 1)
 	cmd.StringVar(&req.Framework, []string{"F", "-framework"}, "", "Application framework")
 	cmd.Var(opts.NewListOptsRef(&req.Services, nil), []string{"s", "-service"}, "Service plugins")
 	cmd.StringVar(&req.Repo, []string{"-repo"}, "", "Populate from a repository")
+	cmd.StringVar(&req.Template, []string{"T", "-template"}, "", "Create from an application template")
+	cmd.StringVar(&req.TimeZone, []string{"-timezone"}, "", "Container time zone, such as Asia/Shanghai")
+	cmd.StringVar(&req.Locale, []string{"-locale"}, "", "Container locale, such as en_US.UTF-8")
 	cmd.BoolVar(&noclone, []string{"n", "-no-clone"}, false, "Do not clone source code")
 	cmd.BoolVar(&binary, []string{"-binary"}, false, "Download binary repository")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display the created application as JSON")
 	cmd.ParseFlags(args, true)
 	req.Name = cmd.Arg(0)
 
@@ -462,14 +607,40 @@ func (cli *CWCli) CmdAppCreate(args ...string) error {
 		}
 	}
 
+	// create the application with plugins declared in the application
+	// manifest of the current directory
+	if req.Framework == "" && req.Template == "" {
+		if err := loadAppManifest(&req); err != nil {
+			return err
+		}
+	}
+
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
 
-	app, err := cli.CreateApplication(context.Background(), req, cli.stdout, cli.stderr)
+	if req.Framework == "" && req.Template == "" {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("The application framework must be specified with --framework")
+		}
+		if err := cli.selectPlugins(&req); err != nil {
+			return err
+		}
+	}
+
+	// keep the standard output clean for JSON
+	stdout := cli.stdout
+	if js {
+		stdout = cli.stderr
+	}
+
+	app, err := cli.CreateApplication(context.Background(), req, stdout, cli.stderr)
 	if err != nil {
 		return err
 	}
+	if js {
+		cli.writeJson(app)
+	}
 	if !noclone {
 		if binary {
 			return cli.download(req.Name)
@@ -480,6 +651,24 @@ func (cli *CWCli) CmdAppCreate(args ...string) error {
 	return nil
 }
 
+func loadAppManifest(req *types.CreateApplication) error {
+	app, err := manifest.LoadApplication(".")
+	if err != nil || app == nil || app.Framework == "" {
+		return err
+	}
+
+	req.Framework = app.Framework
+	names := make([]string, 0, len(app.Services))
+	for name := range app.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.Services = append(req.Services, name+"="+app.Services[name])
+	}
+	return nil
+}
+
 func (cli *CWCli) CmdAppRemove(args ...string) error {
 	var yes bool
 
@@ -498,12 +687,19 @@ func (cli *CWCli) CmdAppRemove(args ...string) error {
 }
 
 func (cli *CWCli) CmdAppStart(args ...string) error {
+	var bulk bulkFlags
+
 	cmd := cli.Subcmd("app:start", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	bulk.install(cmd, "start")
 	cmd.ParseFlags(args, true)
-	name := cli.getAppName(cmd)
 
+	if bulk.all {
+		return cli.bulkOperation(&bulk)
+	}
+
+	name := cli.getAppName(cmd)
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
@@ -511,31 +707,123 @@ func (cli *CWCli) CmdAppStart(args ...string) error {
 }
 
 func (cli *CWCli) CmdAppStop(args ...string) error {
+	var bulk bulkFlags
+
 	cmd := cli.Subcmd("app:stop", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	bulk.install(cmd, "stop")
 	cmd.ParseFlags(args, true)
-	name := cli.getAppName(cmd)
 
+	if bulk.all {
+		return cli.bulkOperation(&bulk)
+	}
+
+	name := cli.getAppName(cmd)
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
 	return cli.StopApplication(context.Background(), name)
 }
 
+// bulkFlags holds command line options to operate multiple applications.
+type bulkFlags struct {
+	op          string
+	all         bool
+	namespace   string
+	category    string
+	labels      map[string]string
+	concurrency int
+}
+
+func (b *bulkFlags) install(cmd *mflag.FlagSet, op string) {
+	b.op = op
+	cmd.BoolVar(&b.all, []string{"-all"}, false, "Operate all applications matching the selector")
+	cmd.StringVar(&b.namespace, []string{"-namespace"}, "", "Select applications in the namespace")
+	cmd.StringVar(&b.category, []string{"-category"}, "", "Select containers in the category (Framework or Service)")
+	cmd.Var(opts.NewMapOptsRef(&b.labels, nil), []string{"-label"}, "Select containers with the label (KEY or KEY=VALUE)")
+	cmd.IntVar(&b.concurrency, []string{"-concurrency"}, 0, "Maximum number of applications operated concurrently")
+}
+
+func (cli *CWCli) bulkOperation(b *bulkFlags) error {
+	req := types.BulkOptions{
+		Operation:   b.op,
+		Namespace:   b.namespace,
+		Category:    manifest.Category(b.category),
+		Labels:      b.labels,
+		Concurrency: b.concurrency,
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	results, err := cli.BulkOperation(context.Background(), req, cli.stdout, cli.stderr)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, res := range results {
+		if res.Error == "" {
+			fmt.Fprintf(cli.stdout, "%s: %s\n", res.Name, ansi.Success("ok"))
+		} else {
+			fmt.Fprintf(cli.stdout, "%s: %s\n", res.Name, ansi.Fail(res.Error))
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d applications failed to %s", failed, len(results), b.op)
+	}
+	return nil
+}
+
 func (cli *CWCli) CmdAppRestart(args ...string) error {
+	var bulk bulkFlags
+
 	cmd := cli.Subcmd("app:restart", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	bulk.install(cmd, "restart")
 	cmd.ParseFlags(args, true)
-	name := cli.getAppName(cmd)
 
+	if bulk.all {
+		return cli.bulkOperation(&bulk)
+	}
+
+	name := cli.getAppName(cmd)
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
 	return cli.RestartApplication(context.Background(), name, cli.stdout, cli.stderr)
 }
 
+func (cli *CWCli) CmdAppIdle(args ...string) error {
+	cmd := cli.Subcmd("app:idle", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.IdleApplication(context.Background(), name)
+}
+
+func (cli *CWCli) CmdAppResume(args ...string) error {
+	cmd := cli.Subcmd("app:resume", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.ResumeApplication(context.Background(), name, cli.stdout, cli.stderr)
+}
+
 func (cli *CWCli) CmdAppStatus(args ...string) error {
 	var all, js bool
 	var name string
@@ -644,6 +932,10 @@ func (cli *CWCli) CmdAppPs(args ...string) error {
 
 	for _, pl := range procs {
 		io.WriteString(cli.stdout, ansi.Warning(pl.ID[:12])+" "+ansi.Info(pl.DisplayName+"\n"))
+		if pl.Error != "" {
+			fmt.Fprintf(cli.stdout, "%s\n\n", ansi.Fail(pl.Error))
+			continue
+		}
 		tab := NewTable(pl.Headers...)
 		for _, row := range pl.Processes {
 			tab.AddRow(row...)
@@ -654,10 +946,13 @@ func (cli *CWCli) CmdAppPs(args ...string) error {
 	return nil
 }
 
-func (cli *CWCli) CmdAppStats(args ...string) error {
-	cmd := cli.Subcmd("app:stats", "")
+func (cli *CWCli) CmdAppVolumes(args ...string) error {
+	var js bool
+
+	cmd := cli.Subcmd("app:volumes", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
 	cmd.ParseFlags(args, true)
 	name := cli.getAppName(cmd)
 
@@ -665,53 +960,204 @@ func (cli *CWCli) CmdAppStats(args ...string) error {
 		return err
 	}
 
-	resp, err := cli.GetApplicationStats(context.Background(), name)
+	volumes, err := cli.GetApplicationVolumes(context.Background(), name)
 	if err != nil {
 		return err
 	}
-	defer resp.Close()
 
-	dec := json.NewDecoder(resp)
-	for {
-		var stats []*types.ContainerStats
-		err = dec.Decode(&stats)
-		if err == io.EOF {
-			return nil
+	if js {
+		cli.writeJson(volumes)
+		return nil
+	}
+
+	tab := NewTable("CONTAINER", "NAME", "MOUNT PATH", "USED", "SIZE")
+	tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
+	for _, cv := range volumes {
+		for _, v := range cv.Volumes {
+			used, size := "-", "unlimited"
+			if v.Used >= 0 {
+				used = units.HumanSize(float64(v.Used))
+			}
+			if v.Size > 0 {
+				size = units.HumanSize(float64(v.Size))
+			}
+			tab.AddRow(cv.ID[:12], cv.Name+"/"+v.Name, v.MountPath, used, size)
+		}
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdTop(args ...string) error {
+	var js bool
+
+	cmd := cli.Subcmd("top", "[APP]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&js, []string{"-json"}, false, "Display as JSON")
+	cmd.ParseFlags(args, true)
+
+	var name string
+	if cmd.NArg() == 1 {
+		name = cmd.Arg(0)
+	} else {
+		name = cli.getAppName(cmd)
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	procs, err := cli.GetApplicationProcesses(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	if js {
+		cli.writeJson(procs)
+		return nil
+	}
+
+	for _, pl := range procs {
+		io.WriteString(cli.stdout, ansi.Warning(pl.ID[:12])+" "+ansi.Info(pl.DisplayName+"\n"))
+		if pl.Error != "" {
+			fmt.Fprintf(cli.stdout, "%s\n\n", ansi.Fail(pl.Error))
+			continue
+		}
+		tab := NewTable("USER", "PID", "PPID", "%CPU", "START", "TIME", "COMMAND")
+		for _, p := range pl.Parsed {
+			tab.AddRow(p.User, strconv.Itoa(p.PID), strconv.Itoa(p.PPID), p.CPU, p.Start, p.Time, p.Command)
 		}
+		tab.Display(cli.stdout, 1)
+		fmt.Fprintln(cli.stdout)
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdAppStats(args ...string) error {
+	var noStream bool
+
+	cmd := cli.Subcmd("app:stats", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&noStream, []string{"-no-stream"}, false, "Display the first result with total usage and exit")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	if noStream {
+		stats, err := cli.GetApplicationStatsSnapshot(context.Background(), name)
 		if err != nil {
 			return err
 		}
+		tab := newStatsTable(stats.Containers)
+		tab.AddRow(statsRow("TOTAL", &stats.Total)...)
+		tab.Display(cli.stdout, 2)
+		return nil
+	}
 
-		tab := NewTable("ID", "NAME", "%CPU", "%MEM", "MEM USAGE / LIMIT", "NET RX / TX", "BLOCK IO (R/W)")
-		tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
-		tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
-		for _, s := range stats {
-			tab.AddRow(
-				s.ID[:12],
-				s.Name,
-				fmt.Sprintf("%.2f%%", s.CPUPercentage),
-				fmt.Sprintf("%.2f%%", s.MemoryPercentage),
-				units.BytesSize(float64(s.MemoryUsage))+" / "+units.BytesSize(float64(s.MemoryLimit)),
-				units.HumanSize(float64(s.NetworkRx))+" / "+units.HumanSize(float64(s.NetworkTx)),
-				units.HumanSize(float64(s.BlockRead))+" / "+units.HumanSize(float64(s.BlockWrite)))
-		}
+	return cli.StreamApplicationStats(context.Background(), name, func(stats []*types.ContainerStats) error {
+		tab := newStatsTable(stats)
 		io.WriteString(cli.stdout, "\033[2J\033[H") // clear screen
 		tab.Display(cli.stdout, 2)
+		return nil
+	})
+}
+
+func newStatsTable(stats []*types.ContainerStats) *Table {
+	tab := NewTable("ID", "NAME", "%CPU", "%MEM", "MEM USAGE / LIMIT", "NET RX / TX", "BLOCK IO (R/W)")
+	tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
+	tab.SetColor(1, ansi.NewColor(ansi.FgCyan))
+	for _, s := range stats {
+		tab.AddRow(statsRow(s.ID[:12], s)...)
+	}
+	return tab
+}
+
+func statsRow(id string, s *types.ContainerStats) []string {
+	return []string{
+		id,
+		s.Name,
+		fmt.Sprintf("%.2f%%", s.CPUPercentage),
+		fmt.Sprintf("%.2f%%", s.MemoryPercentage),
+		units.BytesSize(float64(s.MemoryUsage)) + " / " + units.BytesSize(float64(s.MemoryLimit)),
+		units.HumanSize(float64(s.NetworkRx)) + " / " + units.HumanSize(float64(s.NetworkTx)),
+		units.HumanSize(float64(s.BlockRead)) + " / " + units.HumanSize(float64(s.BlockWrite)),
+	}
+}
+
+func (cli *CWCli) CmdAppLogs(args ...string) error {
+	var opts types.LogsOptions
+	var search types.LogSearchOptions
+
+	cmd := cli.Subcmd("app:logs", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&opts.Follow, []string{"f", "-follow"}, false, "Follow log output")
+	cmd.StringVar(&opts.Tail, []string{"n", "-tail"}, "all", "Number of lines to show from the end of the logs")
+	cmd.StringVar(&opts.Since, []string{"-since"}, "", "Show logs since timestamp or relative time (e.g. 10m)")
+	cmd.StringVar(&search.Query, []string{"-search"}, "", "Search aggregated logs containing the terms")
+	cmd.StringVar(&search.Until, []string{"-until"}, "", "Search logs until timestamp or relative time")
+	cmd.StringVar(&search.Severity, []string{"-severity"}, "", "Search logs with the minimum severity (debug, info, warning, error, fatal)")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
 	}
+
+	if search.Query != "" || search.Until != "" || search.Severity != "" {
+		if opts.Follow {
+			return errors.New("Cannot follow log search results")
+		}
+		search.Since = opts.Since
+		if opts.Tail != "all" {
+			limit, err := strconv.Atoi(opts.Tail)
+			if err != nil || limit <= 0 {
+				return fmt.Errorf("Invalid number of lines: %s", opts.Tail)
+			}
+			search.Limit = limit
+		}
+		return cli.searchLogs(name, search)
+	}
+	return cli.ApplicationLogs(context.Background(), name, opts, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) searchLogs(name string, opts types.LogSearchOptions) error {
+	entries, err := cli.SearchLogs(context.Background(), name, opts)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Fprintf(cli.stdout, "%s %s| [%s] %s\n",
+			e.Time.Local().Format(time.RFC3339), e.Container, e.Severity, e.Message)
+	}
+	return nil
 }
 
 func (cli *CWCli) CmdAppDeploy(args ...string) error {
-	var branch string
+	var rev string
 	var show bool
+	var bulk bulkFlags
+	var deploy deployFlags
 
 	cmd := cli.Subcmd("app:deploy", "")
 	cmd.Require(mflag.Exact, 0)
 	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
-	cmd.StringVar(&branch, []string{"b", "-branch"}, "", "The branch to deploy")
+	cmd.StringVar(&rev, []string{"r", "-ref", "b", "-branch"}, "", "The branch, tag or commit to deploy")
 	cmd.BoolVar(&show, []string{"-show"}, false, "Show application deployments")
+	deploy.install(cmd)
+	bulk.install(cmd, "redeploy")
 	cmd.ParseFlags(args, true)
-	name := cli.getAppName(cmd)
 
+	if bulk.all {
+		return cli.bulkOperation(&bulk)
+	}
+
+	name := cli.getAppName(cmd)
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
@@ -747,14 +1193,68 @@ func (cli *CWCli) CmdAppDeploy(args ...string) error {
 			}
 		}
 
+		if deployments.Current.Type == "COMMIT" {
+			fmt.Fprintln(cli.stdout)
+			fmt.Fprintln(cli.stdout, "Commit:")
+			display(deployments.Current)
+		}
+
 		return nil
 	} else {
-		return cli.DeployApplication(context.Background(), name, branch, cli.stdout, cli.stderr)
+		stdout, stderr, progress := newProgressOutput(cli.stdout, cli.stderr)
+		defer progress.Close()
+		return cli.DeployApplication(context.Background(), name, rev, deploy.options(), stdout, stderr)
+	}
+}
+
+func (cli *CWCli) CmdAppRollback(args ...string) error {
+	var list bool
+
+	cmd := cli.Subcmd("app:rollback", "[VERSION]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&list, []string{"l", "-list"}, false, "List previous deployments")
+	cmd.ParseFlags(args, true)
+
+	var version int
+	if cmd.NArg() == 1 {
+		var err error
+		if version, err = strconv.Atoi(cmd.Arg(0)); err != nil || version <= 0 {
+			return fmt.Errorf("Invalid version: %s", cmd.Arg(0))
+		}
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	if list {
+		versions, err := cli.GetDeploymentHistory(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		tab := NewTable("VERSION", "DEPLOYED", "SIZE")
+		tab.SetColor(0, ansi.NewColor(ansi.FgYellow))
+		for _, v := range versions {
+			display := strconv.Itoa(v.Version)
+			if v.Current {
+				display = "* " + display
+			} else {
+				display = "  " + display
+			}
+			deployed := units.HumanDuration(time.Since(v.Created)) + " ago"
+			tab.AddRow(display, deployed, units.HumanSize(float64(v.Size)))
+		}
+		tab.Display(cli.stdout, 3)
+		return nil
 	}
+
+	return cli.Rollback(context.Background(), name, version, cli.stdout, cli.stderr)
 }
 
 func (cli *CWCli) CmdAppScale(args ...string) error {
-	cmd := cli.Subcmd("app:scale", "NAME [+|-]SCALING")
+	cmd := cli.Subcmd("app:scale", "NAME [+|-]SCALING", "NAME SERVICE=[+|-]SCALING")
 	cmd.Require(mflag.Exact, 2)
 	cmd.ParseFlags(args, true)
 
@@ -762,10 +1262,19 @@ func (cli *CWCli) CmdAppScale(args ...string) error {
 	if name == "." {
 		name = cli.getAppName(nil)
 	}
+
+	var service string
+	if i := strings.IndexRune(scale, '='); i >= 0 {
+		service, scale = scale[:i], scale[i+1:]
+		if service == "" {
+			return fmt.Errorf("Invalid scaling argument: %s", cmd.Arg(1))
+		}
+	}
+
 	if err := cli.ConnectAndLogin(); err != nil {
 		return err
 	}
-	return cli.ScaleApplication(context.Background(), name, scale, cli.stdout, cli.stderr)
+	return cli.ScaleService(context.Background(), name, service, scale, cli.stdout, cli.stderr)
 }
 
 func (cli *CWCli) CmdAppEnv(args ...string) error {
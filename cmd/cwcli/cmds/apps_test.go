@@ -0,0 +1,46 @@
+package cmds
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+func TestWriteContainerStatus(t *testing.T) {
+	defer func(tty bool) { ansi.IsTerminal = tty }(ansi.IsTerminal)
+	ansi.IsTerminal = false
+
+	exited := time.Date(2016, 3, 1, 10, 20, 30, 0, time.UTC)
+	status := []*types.ContainerStatus{
+		{
+			ContainerJSONBase: types.ContainerJSONBase{ID: "0123456789abcdef", DisplayName: "PHP 7.0"},
+			State:             manifest.StateRunning,
+		},
+		{
+			ContainerJSONBase: types.ContainerJSONBase{ID: "fedcba", DisplayName: "MySQL 5.7"},
+			State:             manifest.StateRestarting,
+			Restarts:          3,
+			LastExit:          &types.ContainerExit{OOMKilled: true, Time: exited},
+		},
+		{
+			ContainerJSONBase: types.ContainerJSONBase{ID: "abc", DisplayName: "Redis 3.0"},
+			State:             manifest.StateFailed,
+			LastExit:          &types.ContainerExit{ExitCode: 1, Time: exited},
+		},
+	}
+
+	var buf bytes.Buffer
+	writeContainerStatus(&buf, status)
+
+	stamp := exited.Local().Format(time.Stamp)
+	expected := " - 0123456789ab PHP 7.0: running\n" +
+		" - fedcba MySQL 5.7: restarting (3 restarts) (OOM killed at " + stamp + ")\n" +
+		" - abc Redis 3.0: failed (exited with code 1 at " + stamp + ")\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cloudway/platform/api/client"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/gopass"
 	"github.com/cloudway/platform/pkg/mflag"
@@ -73,7 +75,16 @@ func (c *CWCli) authenticate(prompt, username, password string) (err error) {
 		}
 	}
 
-	token, err := c.Authenticate(context.Background(), strings.ToLower(username), password)
+	ctx := context.Background()
+	username = strings.ToLower(username)
+	token, err := c.Authenticate(ctx, username, password)
+	if err == client.ErrTwoFactorRequired {
+		var code string
+		if code, err = c.readAuthCode(); err != nil {
+			return err
+		}
+		token, err = c.AuthenticateWithCode(ctx, username, password, code)
+	}
 	if err != nil {
 		if se, ok := err.(rest.ServerError); ok && se.StatusCode() == http.StatusUnauthorized {
 			err = errors.New("Login failed. Please enter valid user name and password.")
@@ -86,3 +97,110 @@ func (c *CWCli) authenticate(prompt, username, password string) (err error) {
 	config.Save()
 	return nil
 }
+
+func (cli *CWCli) CmdRegister(args ...string) error {
+	var namespace, confirm string
+
+	cmd := cli.Subcmd("register", "[EMAIL]", "--confirm TOKEN")
+	cmd.StringVar(&namespace, []string{"n", "-namespace"}, "", "The namespace of applications")
+	cmd.StringVar(&confirm, []string{"-confirm"}, "", "Confirm the registration with the token in verification email")
+	cmd.Require(mflag.Max, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.Connect(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if confirm != "" {
+		result, err := cli.ConfirmRegistration(ctx, confirm)
+		if err != nil {
+			return err
+		}
+		if result.Activated {
+			fmt.Fprintf(cli.stdout, "Your account %s has been activated.\n", result.Name)
+		} else {
+			fmt.Fprintf(cli.stdout, "Your email has been verified, the account %s is waiting for approval.\n", result.Name)
+		}
+		return nil
+	}
+
+	email := cmd.Arg(0)
+	if email == "" {
+		fmt.Fprintf(cli.stdout, "Email: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		email = strings.TrimSpace(line)
+	}
+
+	password, err := cli.readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	req := types.Register{Email: email, Password: password, Namespace: namespace}
+	if err = cli.Register(ctx, req); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "A verification email has been sent to %s, please follow the link in the email to activate your account.\n", email)
+	return nil
+}
+
+func (cli *CWCli) CmdPasswd(args ...string) error {
+	var forgot, reset string
+
+	cmd := cli.Subcmd("passwd", "", "--forgot EMAIL", "--reset TOKEN")
+	cmd.StringVar(&forgot, []string{"-forgot"}, "", "Send a password reset email to the user")
+	cmd.StringVar(&reset, []string{"-reset"}, "", "Set a new password with the token in password reset email")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	ctx := context.Background()
+
+	if forgot != "" {
+		if err := cli.Connect(); err != nil {
+			return err
+		}
+		if err := cli.RequestPasswordReset(ctx, forgot); err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.stdout, "If the account %s exists, a password reset email has been sent to it.\n", forgot)
+		return nil
+	}
+
+	if reset != "" {
+		if err := cli.Connect(); err != nil {
+			return err
+		}
+		password, err := cli.readNewPassword()
+		if err != nil {
+			return err
+		}
+		if err = cli.ResetPasswordWithToken(ctx, reset, password); err != nil {
+			return err
+		}
+		fmt.Fprintln(cli.stdout, "Your password has been changed, please login with the new password.")
+		return nil
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.stdout, "Current password: ")
+	old, err := gopass.GetPasswdMasked()
+	if err != nil {
+		return err
+	}
+	password, err := cli.readNewPassword()
+	if err != nil {
+		return err
+	}
+	if err = cli.ChangePassword(ctx, string(old), password); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.stdout, "Your password has been changed.")
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appBackupUsage = `Usage: cwcli app:backup [OPTIONS]
+
+Backup application repository, environment and data.
+
+Options:
+
+  -a, --app          Specify the application name
+  -l, --list         List application backups
+
+Additional commands, type "cwcli help app:backup COMMAND" for more details:
+
+  restore            Recreate the application from a backup
+`
+
+func (cli *CWCli) CmdAppBackup(args ...string) error {
+	var list, help bool
+
+	cmd := cli.Subcmd("app:backup", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&list, []string{"l", "-list"}, false, "List application backups")
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprint(cli.stdout, appBackupUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	if list {
+		backups, err := cli.GetApplicationBackups(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		tab := NewTable("ID", "CREATED", "SIZE")
+		for _, b := range backups {
+			tab.AddRow(b.ID, units.HumanDuration(time.Since(b.Created))+" ago", units.HumanSize(float64(b.Size)))
+		}
+		tab.Display(cli.stdout, 2)
+		return nil
+	}
+
+	b, err := cli.BackupApplication(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Backup %s created (%s)\n", b.ID, units.HumanSize(float64(b.Size)))
+	return nil
+}
+
+func (cli *CWCli) CmdAppBackupRestore(args ...string) error {
+	cmd := cli.Subcmd("app:backup restore", "[ID]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RestoreApplication(context.Background(), name, cmd.Arg(0), cli.stdout, cli.stderr)
+}
@@ -0,0 +1,129 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppBuilds(args ...string) error {
+	var page, limit int
+
+	cmd := cli.Subcmd("app:builds", "[ID]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.IntVar(&page, []string{"p", "-page"}, 1, "Show the given page of build history")
+	cmd.IntVar(&limit, []string{"n", "-limit"}, 20, "Number of builds shown in a page")
+	cmd.ParseFlags(args, true)
+
+	if page <= 0 {
+		return fmt.Errorf("Invalid page: %d", page)
+	}
+	if limit <= 0 {
+		return fmt.Errorf("Invalid limit: %d", limit)
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	// show log of the given build
+	if cmd.NArg() == 1 {
+		r, err := cli.GetBuildLog(context.Background(), cmd.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(cli.stdout, r)
+		return err
+	}
+
+	builds, err := cli.GetApplicationBuilds(context.Background(), name, (page-1)*limit, limit)
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("ID", "STRATEGY", "STARTED", "DURATION", "STATUS")
+	for _, b := range builds {
+		var duration string
+		if !b.Finished.IsZero() {
+			duration = units.HumanDuration(b.Finished.Sub(b.Started))
+		}
+		tab.AddRow(b.ID, b.Strategy,
+			units.HumanDuration(time.Since(b.Started))+" ago",
+			duration, buildStatus(b))
+	}
+	tab.Display(cli.stdout, 2)
+
+	if len(builds) == limit {
+		fmt.Fprintf(cli.stdout, "\nRun with --page %d to show more builds\n", page+1)
+	}
+	return nil
+}
+
+func buildStatus(b *types.Build) string {
+	if b.Error != "" {
+		return b.Status + ": " + b.Error
+	}
+	return b.Status
+}
+
+func (cli *CWCli) CmdAppBuildsCancel(args ...string) error {
+	cmd := cli.Subcmd("app:builds cancel", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.CancelBuild(context.Background(), name)
+}
+
+func (cli *CWCli) CmdAppBuildsLock(args ...string) error {
+	cmd := cli.Subcmd("app:builds lock", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	lock, err := cli.GetBuildLock(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	if !lock.Locked {
+		fmt.Fprintln(cli.stdout, "No deployment in progress")
+		return nil
+	}
+	fmt.Fprintf(cli.stdout, "The %s started by %s %s ago holds the build lock\n",
+		lock.Operation, lock.User, units.HumanDuration(time.Since(lock.Acquired)))
+	if lock.Waiting != 0 {
+		fmt.Fprintf(cli.stdout, "%d deployments waiting in queue\n", lock.Waiting)
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdAppCacheClear(args ...string) error {
+	cmd := cli.Subcmd("app:cache clear", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.ClearBuildCache(context.Background(), name)
+}
@@ -0,0 +1,33 @@
+package cmds
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppCanaryPromote(args ...string) error {
+	cmd := cli.Subcmd("app:canary promote", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.PromoteCanary(context.Background(), name, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdAppCanaryAbort(args ...string) error {
+	cmd := cli.Subcmd("app:canary abort", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.AbortCanary(context.Background(), name, cli.stdout, cli.stderr)
+}
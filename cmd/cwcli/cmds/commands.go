@@ -2,9 +2,13 @@ package cmds
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/cli"
 	flag "github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/rest"
 )
 
 // Command is the struct containing the command name and description
@@ -23,7 +28,8 @@ type Command struct {
 
 type CWCli struct {
 	*cli.Cli
-	host string
+	host     string
+	certAuth bool // authenticated by client certificate
 	*client.APIClient
 	stdout, stderr io.Writer
 	handlers       map[string]func(...string) error
@@ -33,32 +39,104 @@ type CWCli struct {
 var CommandUsage = []Command{
 	{"login", "Login to a Cloudway server"},
 	{"logout", "Log out from a Cloudway server"},
+	{"register", "Register a new account on a Cloudway server"},
+	{"passwd", "Change or reset your password"},
 	{"namespace", "Get or set application namespace"},
+	{"namespace:env", "Get or set environment variables shared by applications in the namespace"},
+	{"team", "List namespaces shared with you"},
+	{"team:members", "List members sharing your namespace"},
+	{"team:add", "Invite a user to your namespace"},
+	{"team:remove", "Remove a member from your namespace"},
 	{"app", "Manage applications"},
+	{"app:list", "List applications"},
 	{"app:create", "Create application"},
 	{"app:remove", "Permanently remove an application"},
+	{"app:delete", "Permanently remove an application"},
 	{"app:start", "Start an application"},
 	{"app:stop", "Stop an application"},
 	{"app:restart", "Restart an application"},
+	{"app:idle", "Stop an application to save resources until resumed"},
+	{"app:resume", "Start an idle application"},
 	{"app:status", "Show application status"},
 	{"app:ps", "Show application processes"},
+	{"app:volumes", "Show application persistent volumes"},
+	{"top", "Display the running processes of an application"},
 	{"app:stats", "Display application live resource usage statistics"},
+	{"app:logs", "Show application logs"},
 	{"app:service", "Manage application services"},
 	{"app:service add", "Add services to the application"},
 	{"app:service remove", "Remove service from the application"},
 	{"app:clone", "Clone application source code"},
+	{"app:copy", "Create a new application with the same plugins and environment"},
+	{"app:template", "Save an application as a template"},
 	{"app:deploy", "Deploy an application"},
+	{"app:rollback", "Rollback an application to a previous deployment"},
+	{"app:canary promote", "Promote the canary release to all containers"},
+	{"app:canary abort", "Abort the canary deployment of an application"},
+	{"app:builds", "Show build history and logs of an application"},
+	{"app:builds cancel", "Abort the in-progress deployment of an application"},
+	{"app:builds lock", "Show the deployment holding the build lock of an application"},
+	{"app:cache clear", "Clear build caches of an application"},
 	{"app:upload", "Upload an application repository"},
 	{"app:dump", "Dump application data"},
 	{"app:restore", "Restore application data"},
+	{"app:backup", "Backup an application"},
+	{"app:backup restore", "Recreate an application from a backup"},
+	{"app:cron", "Show recent cron job runs of an application"},
 	{"app:scale", "Scale an application"},
+	{"app:resources", "Show or change resource limits of an application"},
+	{"app:logging", "Show or change log driver of an application"},
+	{"app:network", "Show or change network access to application services"},
+	{"app:hooks", "Manage application webhooks"},
+	{"app:hooks add", "Register a webhook to the application"},
+	{"app:hooks remove", "Remove a webhook from the application"},
+	{"app:hooks deliveries", "Show recent deliveries of a webhook"},
+	{"app:hooks redeliver", "Redeliver a previous webhook delivery"},
+	{"app:hooks push", "Show the webhook that deploys the application on push"},
+	{"app:remote", "Show the external repository of the application"},
+	{"app:remote bind", "Deploy the application from a GitHub, GitLab or Git repository"},
+	{"app:remote unbind", "Deploy the application from the platform repository"},
 	{"app:info", "Show application information"},
 	{"app:env", "Get or set application environment variables"},
 	{"app:open", "Open the application in a web brower"},
-	{"app:ssh", "Log into application console via SSH"},
+	{"app:ssh", "Log into application console or run a command"},
+	{"app:ls", "List files in an application container"},
+	{"app:cp", "Copy files between an application container and the local filesystem"},
+	{"domain", "Manage custom domains of the application"},
+	{"domain list", "List custom domains of the application"},
+	{"domain add", "Attach a custom domain to the application"},
+	{"domain remove", "Detach a custom domain from the application"},
+	{"domain cert", "Upload or remove the TLS certificate of a custom domain"},
+	{"secret", "Manage application secrets"},
+	{"secret list", "List secrets of the application"},
+	{"secret set", "Create or update a secret of the application"},
+	{"secret rotate", "Replace the value of a secret"},
+	{"secret remove", "Remove a secret from the application"},
+	{"secret audit", "Show recent operations on secrets of the application"},
+	{"env", "Manage application environment variables"},
+	{"env list", "List application environment variables"},
+	{"env get", "Get application environment variables"},
+	{"env set", "Set application environment variables"},
+	{"env unset", "Remove application environment variables"},
+	{"template", "List application templates"},
+	{"template:remove", "Remove an application template"},
 	{"plugin", "Show plugin information"},
+	{"plugin:init", "Generate a plugin skeleton"},
 	{"plugin:install", "Install a user defined plugin"},
 	{"plugin:remove", "Remove a user defined plugin"},
+	{"plugin:upgrade", "Upgrade applications to a new plugin version"},
+	{"plugin:reload", "Reload a changed plugin manifest in applications"},
+	{"plugin:access", "Show or change access to a user defined plugin"},
+	{"plugin:lint", "Check a plugin for problems before installation"},
+	{"plugin:scan", "Show vulnerabilities found in a plugin image"},
+	{"quota", "Show or change resource quota"},
+	{"token", "List personal access tokens"},
+	{"token:create", "Create a personal access token"},
+	{"token:revoke", "Revoke a personal access token"},
+	{"2fa", "Show two-factor authentication status"},
+	{"2fa:enable", "Enable two-factor authentication"},
+	{"2fa:disable", "Disable two-factor authentication"},
+	{"admin user", "Manage users of the platform"},
 	{"version", "Show the version information"},
 }
 
@@ -79,35 +157,107 @@ func Init(host string, stdout, stderr io.Writer) *CWCli {
 	c.stderr = stderr
 
 	c.handlers = map[string]func(...string) error{
-		"login":              c.CmdLogin,
-		"logout":             c.CmdLogout,
-		"namespace":          c.CmdNamespace,
-		"app":                c.CmdApps,
-		"app:create":         c.CmdAppCreate,
-		"app:remove":         c.CmdAppRemove,
-		"app:start":          c.CmdAppStart,
-		"app:stop":           c.CmdAppStop,
-		"app:restart":        c.CmdAppRestart,
-		"app:status":         c.CmdAppStatus,
-		"app:ps":             c.CmdAppPs,
-		"app:stats":          c.CmdAppStats,
-		"app:service":        c.CmdAppService,
-		"app:service add":    c.CmdAppServiceAdd,
-		"app:service remove": c.CmdAppServiceRemove,
-		"app:clone":          c.CmdAppClone,
-		"app:deploy":         c.CmdAppDeploy,
-		"app:upload":         c.CmdAppUpload,
-		"app:dump":           c.CmdAppDump,
-		"app:restore":        c.CmdAppRestore,
-		"app:scale":          c.CmdAppScale,
-		"app:info":           c.CmdAppInfo,
-		"app:env":            c.CmdAppEnv,
-		"app:open":           c.CmdAppOpen,
-		"app:ssh":            c.CmdAppSSH,
-		"plugin":             c.CmdPlugin,
-		"plugin:install":     c.CmdPluginInstall,
-		"plugin:remove":      c.CmdPluginRemove,
-		"version":            c.CmdVersion,
+		"login":                c.CmdLogin,
+		"logout":               c.CmdLogout,
+		"register":             c.CmdRegister,
+		"passwd":               c.CmdPasswd,
+		"namespace":            c.CmdNamespace,
+		"namespace:env":        c.CmdNamespaceEnv,
+		"team":                 c.CmdTeam,
+		"team:members":         c.CmdTeamMembers,
+		"team:add":             c.CmdTeamAdd,
+		"team:remove":          c.CmdTeamRemove,
+		"app":                  c.CmdApps,
+		"app:list":             c.CmdAppList,
+		"app:create":           c.CmdAppCreate,
+		"app:remove":           c.CmdAppRemove,
+		"app:delete":           c.CmdAppRemove,
+		"app:start":            c.CmdAppStart,
+		"app:stop":             c.CmdAppStop,
+		"app:restart":          c.CmdAppRestart,
+		"app:idle":             c.CmdAppIdle,
+		"app:resume":           c.CmdAppResume,
+		"app:status":           c.CmdAppStatus,
+		"app:ps":               c.CmdAppPs,
+		"app:volumes":          c.CmdAppVolumes,
+		"top":                  c.CmdTop,
+		"app:stats":            c.CmdAppStats,
+		"app:logs":             c.CmdAppLogs,
+		"app:service":          c.CmdAppService,
+		"app:service add":      c.CmdAppServiceAdd,
+		"app:service remove":   c.CmdAppServiceRemove,
+		"app:clone":            c.CmdAppClone,
+		"app:copy":             c.CmdAppCopy,
+		"app:template":         c.CmdAppTemplate,
+		"app:deploy":           c.CmdAppDeploy,
+		"app:rollback":         c.CmdAppRollback,
+		"app:canary promote":   c.CmdAppCanaryPromote,
+		"app:canary abort":     c.CmdAppCanaryAbort,
+		"app:builds":           c.CmdAppBuilds,
+		"app:builds cancel":    c.CmdAppBuildsCancel,
+		"app:builds lock":      c.CmdAppBuildsLock,
+		"app:cache clear":      c.CmdAppCacheClear,
+		"app:upload":           c.CmdAppUpload,
+		"app:dump":             c.CmdAppDump,
+		"app:backup":           c.CmdAppBackup,
+		"app:backup restore":   c.CmdAppBackupRestore,
+		"app:cron":             c.CmdAppCron,
+		"app:restore":          c.CmdAppRestore,
+		"app:scale":            c.CmdAppScale,
+		"app:resources":        c.CmdAppResources,
+		"app:logging":          c.CmdAppLogging,
+		"app:network":          c.CmdAppNetwork,
+		"app:hooks":            c.CmdAppHooks,
+		"app:hooks add":        c.CmdAppHooksAdd,
+		"app:hooks remove":     c.CmdAppHooksRemove,
+		"app:hooks deliveries": c.CmdAppHooksDeliveries,
+		"app:hooks redeliver":  c.CmdAppHooksRedeliver,
+		"app:hooks push":       c.CmdAppHooksPush,
+		"app:remote":           c.CmdAppRemote,
+		"app:remote bind":      c.CmdAppRemoteBind,
+		"app:remote unbind":    c.CmdAppRemoteUnbind,
+		"app:info":             c.CmdAppInfo,
+		"app:env":              c.CmdAppEnv,
+		"app:open":             c.CmdAppOpen,
+		"app:ssh":              c.CmdAppSSH,
+		"app:ls":               c.CmdAppLs,
+		"app:cp":               c.CmdAppCp,
+		"domain":               c.CmdDomain,
+		"domain list":          c.CmdDomainList,
+		"domain add":           c.CmdDomainAdd,
+		"domain remove":        c.CmdDomainRemove,
+		"domain cert":          c.CmdDomainCert,
+		"secret":               c.CmdSecret,
+		"secret list":          c.CmdSecretList,
+		"secret set":           c.CmdSecretSet,
+		"secret rotate":        c.CmdSecretRotate,
+		"secret remove":        c.CmdSecretRemove,
+		"secret audit":         c.CmdSecretAudit,
+		"env":                  c.CmdEnv,
+		"env list":             c.CmdEnvList,
+		"env get":              c.CmdEnvGet,
+		"env set":              c.CmdEnvSet,
+		"env unset":            c.CmdEnvUnset,
+		"template":             c.CmdTemplate,
+		"template:remove":      c.CmdTemplateRemove,
+		"plugin":               c.CmdPlugin,
+		"plugin:init":          c.CmdPluginInit,
+		"plugin:install":       c.CmdPluginInstall,
+		"plugin:remove":        c.CmdPluginRemove,
+		"plugin:upgrade":       c.CmdPluginUpgrade,
+		"plugin:reload":        c.CmdPluginReload,
+		"plugin:access":        c.CmdPluginAccess,
+		"plugin:lint":          c.CmdPluginLint,
+		"plugin:scan":          c.CmdPluginScan,
+		"quota":                c.CmdQuota,
+		"token":                c.CmdToken,
+		"token:create":         c.CmdTokenCreate,
+		"token:revoke":         c.CmdTokenRevoke,
+		"2fa":                  c.Cmd2fa,
+		"2fa:enable":           c.Cmd2faEnable,
+		"2fa:disable":          c.Cmd2faDisable,
+		"admin user":           c.CmdAdminUser,
+		"version":              c.CmdVersion,
 	}
 
 	return c
@@ -147,7 +297,25 @@ func (c *CWCli) Connect() (err error) {
 		"Accept": "application/json",
 	}
 
-	c.APIClient, err = client.NewAPIClient(c.host+"/api", "", nil, headers)
+	var httpClient *http.Client
+	tlsConfig, err := c.clientTLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+	}
+
+	c.APIClient, err = client.NewAPIClient(c.host+"/api", "", httpClient, headers)
+	if err == nil {
+		c.APIClient.SetRetryPolicy(&rest.DefaultRetryPolicy)
+		c.APIClient.UseNamespace(os.Getenv(namespaceEnv))
+	}
 	return err
 }
 
@@ -156,15 +324,54 @@ func (c *CWCli) ConnectAndLogin() (err error) {
 		return err
 	}
 
-	token := config.GetOption(c.host, "token")
+	// personal access token from environment used by automation tools
+	token := os.Getenv("CLOUDWAY_TOKEN")
+	if token == "" {
+		token = config.GetOption(c.host, "token")
+	}
 	if token != "" {
 		c.SetToken(token)
-	} else {
+	} else if !c.certAuth {
 		err = c.authenticate("You must login.", "", "")
 	}
 	return err
 }
 
+// clientTLSConfig returns the TLS configuration to connect to the host,
+// configured by tls_ca, tls_cert and tls_key options in the host section.
+// The server certificate is verified against CA certificates in tls_ca if
+// configured. The client certificate in tls_cert and tls_key authenticates
+// machine users without login.
+func (c *CWCli) clientTLSConfig() (*tls.Config, error) {
+	caFile := config.GetOption(c.host, "tls_ca")
+	certFile := config.GetOption(c.host, "tls_cert")
+	keyFile := config.GetOption(c.host, "tls_key")
+	if caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No CA certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		c.certAuth = true
+	}
+	return tlsConfig, nil
+}
+
 func (cli *CWCli) confirm(prompt string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	for {
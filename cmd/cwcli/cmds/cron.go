@@ -0,0 +1,69 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppCron(args ...string) error {
+	cmd := cli.Subcmd("app:cron", "[RUN]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	runs, err := cli.GetCronRuns(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	// show output of the given run
+	if cmd.NArg() == 1 {
+		for _, run := range runs {
+			if run.ID == cmd.Arg(0) {
+				fmt.Fprint(cli.stdout, run.Output)
+				if run.Error != "" {
+					fmt.Fprintln(cli.stderr, run.Error)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("Cron job run %s not found", cmd.Arg(0))
+	}
+
+	tab := NewTable("ID", "JOB", "COMMAND", "STARTED", "DURATION", "STATUS")
+	for _, run := range runs {
+		job := run.Job
+		if run.Service != "" {
+			job = run.Service + "/" + job
+		}
+		tab.AddRow(run.ID, job, strings.TrimSpace(run.Command),
+			units.HumanDuration(time.Since(run.Started))+" ago",
+			units.HumanDuration(run.Finished.Sub(run.Started)),
+			cronRunStatus(run))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func cronRunStatus(run *types.CronRun) string {
+	switch {
+	case run.Error != "":
+		return "failed: " + run.Error
+	case run.ExitCode != 0:
+		return fmt.Sprintf("exited (%d)", run.ExitCode)
+	default:
+		return "succeeded"
+	}
+}
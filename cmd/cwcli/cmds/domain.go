@@ -0,0 +1,142 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const domainUsage = `Usage: cwcli domain COMMAND [ARGS...]
+
+Manage custom domains of the application.
+
+Additional commands, type "cwcli help domain COMMAND" for more details:
+
+  list               List custom domains of the application
+  add                Attach a custom domain to the application
+  remove             Detach a custom domain from the application
+  cert               Upload or remove the TLS certificate of a custom domain
+
+The ownership of a domain is verified before it's attached. The domain
+must be a CNAME of the application hostname, or have a TXT record named
+_cloudway.DOMAIN containing the verification token shown on failure.
+
+If enabled by the platform, TLS certificates of custom domains are issued
+and renewed automatically. A custom certificate can be uploaded instead.
+`
+
+func (cli *CWCli) CmdDomain(args ...string) error {
+	fmt.Fprint(cli.stdout, domainUsage)
+	os.Exit(0)
+	return nil
+}
+
+func (cli *CWCli) CmdDomainList(args ...string) error {
+	cmd := cli.Subcmd("domain list", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	domains, err := cli.ListDomains(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	for _, d := range domains {
+		switch {
+		case d.Certificate == nil:
+			fmt.Fprintln(cli.stdout, d.Name)
+		case d.Certificate.ACME:
+			fmt.Fprintf(cli.stdout, "%s (certificate issued by ACME, expires %s)\n", d.Name, d.Certificate.NotAfter.Format("2006-01-02"))
+		default:
+			fmt.Fprintf(cli.stdout, "%s (custom certificate, expires %s)\n", d.Name, d.Certificate.NotAfter.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdDomainAdd(args ...string) error {
+	cmd := cli.Subcmd("domain add", "DOMAIN")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	d, err := cli.AddDomain(context.Background(), name, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Domain %s attached to %s\n", d.Name, name)
+	return nil
+}
+
+func (cli *CWCli) CmdDomainRemove(args ...string) error {
+	cmd := cli.Subcmd("domain remove", "DOMAIN")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveDomain(context.Background(), name, cmd.Arg(0))
+}
+
+func (cli *CWCli) CmdDomainCert(args ...string) error {
+	var certFile, keyFile string
+	var remove bool
+
+	cmd := cli.Subcmd("domain cert", "DOMAIN")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&certFile, []string{"-cert"}, "", "The certificate chain file in PEM format")
+	cmd.StringVar(&keyFile, []string{"-key"}, "", "The private key file in PEM format")
+	cmd.BoolVar(&remove, []string{"-remove"}, false, "Remove the certificate")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	host := cmd.Arg(0)
+
+	var cert, key []byte
+	if !remove {
+		if certFile == "" || keyFile == "" {
+			return errors.New("Both --cert and --key are required to upload certificate")
+		}
+		var err error
+		if cert, err = ioutil.ReadFile(certFile); err != nil {
+			return err
+		}
+		if key, err = ioutil.ReadFile(keyFile); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	if remove {
+		return cli.RemoveCertificate(context.Background(), name, host)
+	}
+
+	c, err := cli.UploadCertificate(context.Background(), name, host, cert, key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Certificate installed for %s, expires %s\n", host, c.NotAfter.Format("2006-01-02"))
+	return nil
+}
@@ -0,0 +1,137 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+const envUsage = `Usage: cwcli env COMMAND APP [ARGS...]
+
+Manage application environment variables.
+
+Additional commands, type "cwcli help env COMMAND" for more details:
+
+  list               List all environment variables of the application
+  get                Get the value of environment variables
+  set                Set environment variables
+  unset              Remove environment variables
+`
+
+func (cli *CWCli) CmdEnv(args ...string) error {
+	fmt.Fprint(cli.stdout, envUsage)
+	os.Exit(0)
+	return nil
+}
+
+func (cli *CWCli) CmdEnvList(args ...string) error {
+	cmd := cli.Subcmd("env list", "APP")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	env, err := cli.GetenvAll(context.Background(), cli.envAppName(cmd.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(cli.stdout, "%s=%s\n", k, env[k])
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdEnvGet(args ...string) error {
+	cmd := cli.Subcmd("env get", "APP KEY...")
+	cmd.Require(mflag.Min, 2)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	env, err := cli.GetenvAll(context.Background(), cli.envAppName(cmd.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	keys := cmd.Args()[1:]
+	for _, k := range keys {
+		if v, ok := env[k]; ok {
+			if len(keys) == 1 {
+				fmt.Fprintln(cli.stdout, v)
+			} else {
+				fmt.Fprintf(cli.stdout, "%s=%s\n", k, v)
+			}
+		}
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdEnvSet(args ...string) error {
+	cmd := cli.Subcmd("env set", "APP KEY=VALUE...", "--file FILE APP [KEY=VALUE...]")
+	file := cmd.String([]string{"f", "-file"}, "", "Load environment variables from a dotenv file")
+	cmd.Require(mflag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	env := make(map[string]string)
+	if *file != "" {
+		var err error
+		if env, err = opts.ParseEnvFile(*file); err != nil {
+			return err
+		}
+	}
+
+	// variables given on command line take precedence over the env file
+	for _, arg := range cmd.Args()[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("Invalid environment variable: %s", arg)
+		}
+		env[kv[0]] = kv[1]
+	}
+
+	if len(env) == 0 {
+		return errors.New("No environment variables to set")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.SetenvAll(context.Background(), cli.envAppName(cmd.Arg(0)), env)
+}
+
+func (cli *CWCli) CmdEnvUnset(args ...string) error {
+	cmd := cli.Subcmd("env unset", "APP KEY...")
+	cmd.Require(mflag.Min, 2)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.Unsetenv(context.Background(), cli.envAppName(cmd.Arg(0)), cmd.Args()[1:]...)
+}
+
+// envAppName returns the application name given on the command line,
+// where "." denotes the application in the current directory.
+func (cli *CWCli) envAppName(name string) string {
+	if name == "." {
+		return cli.getAppName(nil)
+	}
+	return name
+}
@@ -0,0 +1,84 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/stdcopy"
+)
+
+// StatusError reports the exit status of a command executed in the
+// application container.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Command exited with status %d", e.StatusCode)
+}
+
+// execShell runs an interactive shell, or the command in options, in the
+// application container through the API server. A TTY is allocated if both
+// standard input and output are terminals.
+func (cli *CWCli) execShell(name string, opts types.ExecOptions) error {
+	ctx := context.Background()
+
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	opts.Tty = terminal.IsTerminal(inFd) && terminal.IsTerminal(outFd)
+	if opts.Tty {
+		opts.Term = os.Getenv("TERM")
+		opts.Width, opts.Height, _ = terminal.GetSize(outFd)
+	}
+
+	resp, execId, err := cli.ExecApplication(ctx, name, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	if opts.Tty {
+		state, err := terminal.MakeRaw(inFd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(inFd, state)
+
+		stop := cli.monitorTtySize(ctx, name, execId, outFd)
+		defer stop()
+	}
+
+	go func() {
+		io.Copy(resp.Conn, os.Stdin)
+		resp.CloseWrite()
+	}()
+
+	if opts.Tty {
+		_, err = io.Copy(os.Stdout, resp.Reader)
+	} else {
+		_, err = stdcopy.Copy(os.Stdout, os.Stderr, nil, resp.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	inspect, err := cli.InspectExec(ctx, name, execId)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return StatusError{inspect.ExitCode}
+	}
+	return nil
+}
+
+func (cli *CWCli) resizeTty(ctx context.Context, name, execId string, fd int) {
+	width, height, err := terminal.GetSize(fd)
+	if err == nil && width > 0 && height > 0 {
+		cli.ResizeExec(ctx, name, execId, width, height)
+	}
+}
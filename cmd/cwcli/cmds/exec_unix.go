@@ -0,0 +1,35 @@
+// +build !windows
+
+package cmds
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/net/context"
+)
+
+// monitorTtySize resizes the remote TTY when the local terminal window
+// changes size. It returns a function to stop monitoring.
+func (cli *CWCli) monitorTtySize(ctx context.Context, name, execId string, fd int) func() {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGWINCH)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigchan:
+				cli.resizeTty(ctx, name, execId, fd)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigchan)
+		close(done)
+	}
+}
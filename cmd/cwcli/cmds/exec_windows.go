@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
+)
+
+// monitorTtySize resizes the remote TTY when the local console window
+// changes size. Windows has no SIGWINCH so the size is polled.
+func (cli *CWCli) monitorTtySize(ctx context.Context, name, execId string, fd int) func() {
+	done := make(chan struct{})
+	go func() {
+		prevW, prevH, _ := terminal.GetSize(fd)
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := terminal.GetSize(fd)
+				if err == nil && (w != prevW || h != prevH) {
+					prevW, prevH = w, h
+					cli.resizeTty(ctx, name, execId, fd)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,164 @@
+package cmds
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppLs(args ...string) error {
+	var service, root string
+
+	cmd := cli.Subcmd("app:ls", "[PATH]")
+	cmd.Require(mflag.Max, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Service name")
+	cmd.StringVar(&root, []string{"-root"}, "data", "Root directory, data or repo")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	files, err := cli.ListFiles(context.Background(), name, service, root, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("MODE", "SIZE", "MODIFIED", "NAME")
+	for _, f := range files {
+		size, fname := units.HumanSize(float64(f.Size)), f.Name
+		if f.Mode.IsDir() {
+			size, fname = "-", fname+"/"
+		}
+		if f.LinkTarget != "" {
+			fname += " -> " + f.LinkTarget
+		}
+		tab.AddRow(f.Mode.String(), size, f.ModTime.Local().Format("2006-01-02 15:04"), fname)
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdAppCp(args ...string) error {
+	var service, root string
+
+	cmd := cli.Subcmd("app:cp", ":SRC_PATH DEST_PATH|-", "SRC_PATH|- :DEST_PATH")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Service name")
+	cmd.StringVar(&root, []string{"-root"}, "data", "Root directory in the container, data or repo")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	src, dst := cmd.Arg(0), cmd.Arg(1)
+	fromRemote, toRemote := strings.HasPrefix(src, ":"), strings.HasPrefix(dst, ":")
+	if fromRemote == toRemote {
+		return errors.New("Exactly one of the source and destination must be a container path prefixed with ':'")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if fromRemote {
+		return cli.copyFromContainer(ctx, name, service, root, src[1:], dst)
+	} else {
+		return cli.copyToContainer(ctx, name, service, root, src, dst[1:])
+	}
+}
+
+// copyFromContainer downloads a file or directory from the container. The
+// source is copied into the destination if it's an existing directory,
+// otherwise the source is copied as the destination.
+func (cli *CWCli) copyFromContainer(ctx context.Context, name, service, root, src, dst string) error {
+	content, stat, err := cli.DownloadFiles(ctx, name, service, root, src)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if dst == "-" {
+		_, err = io.Copy(cli.stdout, content)
+		return err
+	}
+
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		return archive.ExtractFiles(dst, content)
+	}
+
+	if !stat.Mode.IsDir() {
+		tr := tar.NewReader(content)
+		if _, err := tr.Next(); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, stat.Mode.Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	// extract the directory aside and rename it to the destination
+	tempdir, err := ioutil.TempDir(filepath.Dir(dst), ".cwcli-cp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+	if err = archive.ExtractFiles(tempdir, content); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tempdir, stat.Name), dst)
+}
+
+// copyToContainer uploads a local file or directory, or a tar archive read
+// from standard input, into an existing directory in the container.
+func (cli *CWCli) copyToContainer(ctx context.Context, name, service, root, src, dst string) error {
+	if src == "-" {
+		return cli.UploadFiles(ctx, name, service, root, dst, os.Stdin)
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		tw := tar.NewWriter(pw)
+		base := filepath.Base(src)
+		if fi.IsDir() {
+			err = archive.CopyFileTree(tw, base, src, nil, false)
+		} else {
+			err = archive.CopyFile(tw, src, base, 0)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	if err := cli.UploadFiles(ctx, name, service, root, dst, pr); err != nil {
+		return fmt.Errorf("Failed to copy %s: %v", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appHooksUsage = `Usage: cwcli app:hooks [COMMAND]
+
+Manage application webhooks.
+
+Additional commands, type "cwcli help app:hooks COMMAND" for more details:
+
+  add                Register a webhook to the application
+  remove             Remove a webhook from the application
+  deliveries         Show recent deliveries of a webhook
+  redeliver          Redeliver a previous webhook delivery
+  push               Show the webhook that deploys the application on push
+`
+
+func (cli *CWCli) CmdAppHooks(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("app:hooks", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprint(cli.stdout, appHooksUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	hooks, err := cli.ListHooks(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("ID", "URL", "EVENTS", "CREATED")
+	for _, h := range hooks {
+		events := "all"
+		if len(h.Events) != 0 {
+			events = strings.Join(h.Events, ",")
+		}
+		tab.AddRow(h.ID, h.URL, events, h.Created.Local().Format("2006-01-02 15:04"))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdAppHooksAdd(args ...string) error {
+	var events, secret string
+
+	cmd := cli.Subcmd("app:hooks add", "URL")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&events, []string{"e", "-events"}, "", "Comma separated events to subscribe, 'deploy.started', 'deploy.succeeded', 'deploy.failed', 'container.crashloop', 'container.died', 'container.oom' or 'container.removed'")
+	cmd.StringVar(&secret, []string{"-secret"}, "", "The secret used to sign payloads")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	opts := types.CreateWebhook{URL: cmd.Arg(0), Secret: secret}
+	if events != "" {
+		opts.Events = strings.Split(events, ",")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	hook, err := cli.CreateHook(context.Background(), name, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.stdout, "Webhook %s created\n", hook.ID)
+	if secret == "" {
+		fmt.Fprintf(cli.stdout, "Secret: %s\n", hook.Secret)
+		fmt.Fprintln(cli.stderr, "Make sure to copy the secret now, you won't be able to see it again.")
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdAppHooksRemove(args ...string) error {
+	cmd := cli.Subcmd("app:hooks remove", "ID")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveHook(context.Background(), name, cmd.Arg(0))
+}
+
+func (cli *CWCli) CmdAppHooksDeliveries(args ...string) error {
+	cmd := cli.Subcmd("app:hooks deliveries", "ID")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	deliveries, err := cli.HookDeliveries(context.Background(), name, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("ID", "EVENT", "CREATED", "ATTEMPTS", "STATUS")
+	for _, d := range deliveries {
+		tab.AddRow(d.ID, d.Event, units.HumanDuration(time.Since(d.Created))+" ago", strconv.Itoa(d.Attempts), deliveryStatus(d))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdAppHooksRedeliver(args ...string) error {
+	cmd := cli.Subcmd("app:hooks redeliver", "ID DELIVERY")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	d, err := cli.RedeliverHook(context.Background(), name, cmd.Arg(0), cmd.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Delivery %s: %s\n", d.ID, deliveryStatus(d))
+	return nil
+}
+
+func (cli *CWCli) CmdAppHooksPush(args ...string) error {
+	cmd := cli.Subcmd("app:hooks push")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	regenerate := cmd.Bool([]string{"-regenerate"}, false, "Regenerate the secret, the previous secret no longer works")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	hook, err := cli.GetPushHook(context.Background(), name, *regenerate)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "URL:     %s/api%s\n", cli.host, hook.Path)
+	fmt.Fprintf(cli.stdout, "Secret:  %s\n", hook.Secret)
+	fmt.Fprintln(cli.stdout, "\nAdd the webhook to the GitHub, GitLab or Bitbucket repository with the secret.")
+	fmt.Fprintln(cli.stdout, "For Bitbucket Cloud, append '?token=SECRET' to the URL instead.")
+	return nil
+}
+
+func deliveryStatus(d *types.WebhookDelivery) string {
+	switch {
+	case d.Delivered:
+		return "delivered"
+	case d.Error != "":
+		return "failed: " + d.Error
+	default:
+		return "pending"
+	}
+}
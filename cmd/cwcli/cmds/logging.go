@@ -0,0 +1,69 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
+)
+
+func (cli *CWCli) CmdAppLogging(args ...string) error {
+	var driver string
+	var options map[string]string
+	var reset bool
+
+	cmd := cli.Subcmd("app:logging", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&driver, []string{"d", "-driver"}, "", "Log driver: json-file, syslog, fluentd or gelf")
+	cmd.Var(opts.NewMapOptsRef(&options, nil), []string{"o", "-opt"}, "Log driver option (KEY=VALUE)")
+	cmd.BoolVar(&reset, []string{"-reset"}, false, "Restore the default log configuration")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if reset && (driver != "" || len(options) != 0) {
+		return errors.New("--reset cannot be used with --driver or --opt")
+	}
+	if driver == "" && len(options) != 0 {
+		return errors.New("--opt requires --driver")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if driver != "" || reset {
+		req := &types.LogConfig{Driver: driver, Options: options}
+		if err := cli.SetLogConfig(ctx, name, req); err != nil {
+			return err
+		}
+		fmt.Fprintln(cli.stdout, "The log configuration is applied to containers created later.")
+	}
+
+	logging, err := cli.GetLogConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	source := "default"
+	if logging.Custom {
+		source = "application"
+	}
+	fmt.Fprintf(cli.stdout, "Driver: %s (%s)\n", logging.Driver, source)
+
+	keys := make([]string, 0, len(logging.Options))
+	for k := range logging.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(cli.stdout, "  %s=%s\n", k, logging.Options[k])
+	}
+	return nil
+}
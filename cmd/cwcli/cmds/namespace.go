@@ -1,10 +1,14 @@
 package cmds
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
 	"golang.org/x/net/context"
 )
 
@@ -55,3 +59,57 @@ func (cli *CWCli) CmdNamespace(args ...string) error {
 
 	return nil
 }
+
+func (cli *CWCli) CmdNamespaceEnv(args ...string) error {
+	var file string
+	var unset bool
+
+	cmd := cli.Subcmd("namespace:env", "[KEY=VALUE...]", "--file FILE [KEY=VALUE...]", "--unset KEY...")
+	cmd.StringVar(&file, []string{"f", "-file"}, "", "Load environment variables from a dotenv file")
+	cmd.BoolVar(&unset, []string{"-unset"}, false, "Remove shared environment variables")
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if unset {
+		if cmd.NArg() == 0 {
+			return errors.New("No environment variables to remove")
+		}
+		return cli.UnsetNamespaceEnv(ctx, cmd.Args()...)
+	}
+
+	env := make(map[string]string)
+	if file != "" {
+		var err error
+		if env, err = opts.ParseEnvFile(file); err != nil {
+			return err
+		}
+	}
+	for _, arg := range cmd.Args() {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("Invalid environment variable: %s", arg)
+		}
+		env[kv[0]] = kv[1]
+	}
+	if len(env) != 0 {
+		return cli.SetNamespaceEnv(ctx, env)
+	}
+
+	env, err := cli.GetNamespaceEnv(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(cli.stdout, "%s=%s\n", k, env[k])
+	}
+	return nil
+}
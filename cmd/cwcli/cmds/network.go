@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppNetwork(args ...string) error {
+	var grant, revoke string
+
+	cmd := cli.Subcmd("app:network", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&grant, []string{"-grant"}, "", "Grant another application access to services of the application")
+	cmd.StringVar(&revoke, []string{"-revoke"}, "", "Revoke network access granted to another application")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	if grant != "" && revoke != "" {
+		return errors.New("--grant cannot be used with --revoke")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if grant != "" {
+		if err := cli.GrantNetworkAccess(ctx, name, grant); err != nil {
+			return err
+		}
+	}
+	if revoke != "" {
+		if err := cli.RevokeNetworkAccess(ctx, name, revoke); err != nil {
+			return err
+		}
+	}
+
+	grants, err := cli.GetNetworkGrants(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if grants.Isolation {
+		fmt.Fprintf(cli.stdout, "Network: %s\n", grants.Network)
+	} else {
+		fmt.Fprintln(cli.stdout, "Network: shared (isolation is not enabled)")
+	}
+	fmt.Fprintf(cli.stdout, "Granted to: %s\n", joinOrNone(grants.Grants))
+	fmt.Fprintf(cli.stdout, "Accessible: %s\n", joinOrNone(grants.Granted))
+	return nil
+}
+
+func joinOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -2,19 +2,32 @@ package cmds
 
 import (
 	"archive/tar"
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/opts"
 	"golang.org/x/net/context"
 )
 
-const pluginCmdUsage = `Usage: cwcli plugin
-   or: cwcli plugin:install PATH
+const pluginCmdUsage = `Usage: cwcli plugin [OPTIONS] [TAG]
+   or: cwcli plugin:init [OPTIONS] [DIR]
+   or: cwcli plugin:install [--build-arg KEY=VALUE] PATH
    or: cwcli plugin:remove TAG
+   or: cwcli plugin:upgrade [--app NAME] TAG
+   or: cwcli plugin:reload PATH
+   or: cwcli plugin:lint [--server] DIR
+   or: cwcli plugin:scan [--severity SEVERITY] TAG
+   or: cwcli plugin:access [OPTIONS] NAME
 `
 
 func (cli *CWCli) CmdPlugin(args ...string) (err error) {
@@ -22,6 +35,8 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 	var framework, service bool
 	var category manifest.Category
 	var userDefined bool
+	var query types.PluginQuery
+	var page int
 
 	cmd := cli.Subcmd("plugin", "")
 	cmd.Require(mflag.Min, 0)
@@ -30,6 +45,11 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 	cmd.BoolVar(&framework, []string{"F", "-framework"}, false, "Show framework plugins")
 	cmd.BoolVar(&service, []string{"s", "-service"}, false, "Show service plugins")
 	cmd.BoolVar(&userDefined, []string{"u", "-user"}, false, "Show user defined plugins")
+	cmd.StringVar(&query.Text, []string{"q", "-search"}, "", "Search plugins containing the text")
+	cmd.StringVar(&query.Version, []string{"-version"}, "", "Show plugins matching version constraints, e.g. '>=1.2,<2'")
+	cmd.StringVar(&query.Sort, []string{"-sort"}, "", "Sort by 'name', 'display-name', 'vendor' or 'version', prefix '-' for descending order")
+	cmd.IntVar(&page, []string{"p", "-page"}, 1, "Show the given page of plugins")
+	cmd.IntVar(&query.Limit, []string{"n", "-limit"}, 0, "Number of plugins shown in a page")
 	cmd.ParseFlags(args, false)
 
 	if help {
@@ -48,17 +68,26 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 			category = manifest.Service
 		}
 
-		var plugins []*manifest.Plugin
-		if userDefined {
-			plugins, err = cli.GetUserPlugins(context.Background(), category)
-		} else {
-			plugins, err = cli.GetInstalledPlugins(context.Background(), category)
+		if page <= 0 {
+			return fmt.Errorf("Invalid page: %d", page)
 		}
+		if query.Limit < 0 {
+			return fmt.Errorf("Invalid limit: %d", query.Limit)
+		}
+		query.User = userDefined
+		query.Category = string(category)
+		query.Offset = (page - 1) * query.Limit
+
+		plugins, total, err := cli.SearchPlugins(context.Background(), query)
 		if err != nil {
 			return err
 		}
 		for _, p := range plugins {
-			fmt.Fprintf(cli.stdout, "%-15s %s\n", p.Name, p.DisplayName)
+			fmt.Fprintf(cli.stdout, "%-15s %-10s %s\n", p.Name, p.Version, p.DisplayName)
+		}
+		if query.Offset+len(plugins) < total {
+			fmt.Fprintf(cli.stdout, "\nShowing %d of %d plugins, run with --page %d to show more plugins\n",
+				query.Offset+len(plugins), total, page+1)
 		}
 	} else {
 		plugin, err := cli.GetPluginInfo(context.Background(), cmd.Arg(0))
@@ -79,7 +108,9 @@ func (cli *CWCli) CmdPlugin(args ...string) (err error) {
 }
 
 func (cli *CWCli) CmdPluginInstall(args ...string) (err error) {
+	var buildArgs map[string]string
 	cmd := cli.Subcmd("plugin:install", "PATH")
+	cmd.Var(opts.NewMapOptsRef(&buildArgs, opts.ValidateEnv), []string{"-build-arg"}, "Set build argument of the plugin Dockerfile (KEY=VALUE, or KEY to pass local variable)")
 	cmd.Require(mflag.Exact, 1)
 	cmd.ParseFlags(args, true)
 	path := cmd.Arg(0)
@@ -111,7 +142,9 @@ func (cli *CWCli) CmdPluginInstall(args ...string) (err error) {
 		defer file.Close()
 	}
 
-	return cli.InstallPlugin(context.Background(), file)
+	stdout, stderr, progress := newProgressOutput(cli.stdout, cli.stderr)
+	defer progress.Close()
+	return cli.InstallPlugin(context.Background(), file, buildArgs, stdout, stderr)
 }
 
 func makeArchive(path string) (file *os.File, err error) {
@@ -140,3 +173,188 @@ func (cli *CWCli) CmdPluginRemove(args ...string) (err error) {
 	}
 	return cli.RemovePlugin(context.Background(), cmd.Arg(0))
 }
+
+func (cli *CWCli) CmdPluginUpgrade(args ...string) (err error) {
+	cmd := cli.Subcmd("plugin:upgrade", "TAG")
+	app := cmd.String([]string{"a", "-app"}, "", "Only upgrade the given application")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err = cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.UpgradePlugin(context.Background(), cmd.Arg(0), *app, cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdPluginReload(args ...string) (err error) {
+	cmd := cli.Subcmd("plugin:reload", "PATH")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	// the path is a plugin directory or a manifest file
+	path := cmd.Arg(0)
+	if st, err := os.Stat(path); err != nil {
+		return err
+	} else if st.IsDir() {
+		path = filepath.Join(path, filepath.FromSlash(manifest.ManifestEntry))
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	meta, err := manifest.Read(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if err = cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	tag := meta.Name + ":" + meta.Version
+	return cli.ReloadPlugin(context.Background(), tag, bytes.NewReader(data), cli.stdout, cli.stderr)
+}
+
+func (cli *CWCli) CmdPluginLint(args ...string) (err error) {
+	cmd := cli.Subcmd("plugin:lint", "DIR")
+	server := cmd.Bool([]string{"-server"}, false, "Also validate the plugin on the server")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+	dir := cmd.Arg(0)
+
+	if st, err := os.Stat(dir); err != nil {
+		return err
+	} else if !st.IsDir() {
+		return fmt.Errorf("%s: not a plugin directory", dir)
+	}
+
+	_, diags := manifest.Lint(dir)
+	if *server && !diags.HasErrors() {
+		if err = cli.ConnectAndLogin(); err != nil {
+			return err
+		}
+
+		file, err := makeArchive(dir)
+		if file != nil {
+			defer func() {
+				file.Close()
+				os.Remove(file.Name())
+			}()
+		}
+		if err != nil {
+			return err
+		}
+
+		// the server repeats local checks, so only server diagnostics are shown
+		result, err := cli.ValidatePlugin(context.Background(), file)
+		if err != nil {
+			return err
+		}
+		diags = result.Diagnostics
+	}
+
+	for _, d := range diags {
+		fmt.Fprintln(cli.stdout, d)
+	}
+	if diags.HasErrors() {
+		return errors.New("plugin validation failed")
+	}
+	return nil
+}
+
+var scanSeverities = []string{"unknown", "low", "medium", "high", "critical"}
+
+func (cli *CWCli) CmdPluginScan(args ...string) (err error) {
+	cmd := cli.Subcmd("plugin:scan", "TAG")
+	severity := cmd.String([]string{"-severity"}, "", "Only show vulnerabilities of the given severity or higher")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	minLevel := 0
+	if *severity != "" {
+		minLevel = scanSeverityLevel(strings.ToLower(*severity))
+		if minLevel < 0 {
+			return fmt.Errorf("Invalid severity: %s, must be one of %s", *severity, strings.Join(scanSeverities, ", "))
+		}
+	}
+
+	if err = cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	scan, err := cli.GetPluginScan(context.Background(), cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.stdout, "Image:          %s\n", scan.Image)
+	fmt.Fprintf(cli.stdout, "Scanned:        %s by %s\n", scan.ScannedAt.Local().Format(time.RFC1123), scan.Scanner)
+	var counts []string
+	for i := len(scanSeverities) - 1; i >= 0; i-- {
+		counts = append(counts, fmt.Sprintf("%s: %d", scanSeverities[i], scan.Summary[scanSeverities[i]]))
+	}
+	fmt.Fprintf(cli.stdout, "Summary:        %s\n", strings.Join(counts, ", "))
+
+	shown := 0
+	tab := NewTable("ID", "SEVERITY", "PACKAGE", "VERSION", "FIXED")
+	for _, v := range scan.Vulnerabilities {
+		if scanSeverityLevel(v.Severity) >= minLevel {
+			tab.AddRow(v.ID, v.Severity, v.Package, v.Version, v.FixedVersion)
+			shown++
+		}
+	}
+	if shown != 0 {
+		fmt.Fprintln(cli.stdout)
+		tab.Display(cli.stdout, 2)
+	}
+	return nil
+}
+
+func scanSeverityLevel(severity string) int {
+	for i, s := range scanSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+func (cli *CWCli) CmdPluginAccess(args ...string) (err error) {
+	cmd := cli.Subcmd("plugin:access", "NAME")
+	visibility := cmd.String([]string{"-visibility"}, "", "Change plugin visibility to 'private', 'shared' or 'public'")
+	grant := cmd.String([]string{"-grant"}, "", "Grant the namespace access to the plugin")
+	revoke := cmd.String([]string{"-revoke"}, "", "Revoke access of the namespace to the plugin")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+	name := cmd.Arg(0)
+
+	if err = cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *visibility != "" {
+		if err = cli.SetPluginVisibility(ctx, name, *visibility); err != nil {
+			return err
+		}
+	}
+	if *grant != "" {
+		if err = cli.GrantPluginAccess(ctx, name, *grant); err != nil {
+			return err
+		}
+	}
+	if *revoke != "" {
+		if err = cli.RevokePluginAccess(ctx, name, *revoke); err != nil {
+			return err
+		}
+	}
+
+	access, err := cli.GetPluginAccess(ctx, name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Visibility:     %s\n", access.Visibility)
+	if len(access.Grants) != 0 {
+		fmt.Fprintf(cli.stdout, "Shared with:    %s\n", strings.Join(access.Grants, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+// pluginSkeleton holds parameters of the generated plugin skeleton.
+type pluginSkeleton struct {
+	Name        string
+	DisplayName string
+	Version     string
+	Vendor      string
+	Category    manifest.Category
+	BaseImage   string
+	Port        int
+	Dockerfile  bool
+}
+
+type skeletonFile struct {
+	name     string
+	mode     os.FileMode
+	template *template.Template
+	include  func(*pluginSkeleton) bool
+}
+
+var skeletonFiles = []skeletonFile{
+	{manifest.ManifestEntry, 0644, manifestSkeleton, nil},
+	{manifest.DockerfileEntry, 0644, dockerfileSkeleton, func(p *pluginSkeleton) bool { return p.Dockerfile }},
+	{"bin/control", 0755, controlSkeleton, func(p *pluginSkeleton) bool { return !p.Category.IsLibrary() }},
+	{"bin/build", 0755, buildSkeleton, func(p *pluginSkeleton) bool { return p.Category.IsFramework() }},
+	{"bin/deploy", 0755, deploySkeleton, func(p *pluginSkeleton) bool { return p.Category.IsFramework() }},
+	{"bin/install", 0755, installSkeleton, func(p *pluginSkeleton) bool { return p.Category.IsLibrary() }},
+	{"template/index.html", 0644, indexSkeleton, func(p *pluginSkeleton) bool { return p.Category.IsFramework() }},
+}
+
+var manifestSkeleton = template.Must(template.New("manifest").Parse(`---
+Name: {{.Name}}
+Display-Name: {{.DisplayName}}
+Description: {{.DisplayName}} plugin.
+Version: '{{.Version}}'
+Vendor: {{.Vendor}}
+Category: {{.Category}}
+{{- if not .Dockerfile}}
+Base-Image: {{.BaseImage}}
+{{- end}}
+{{- if not .Category.IsLibrary}}
+Endpoints:
+- Private-Host-Name: HOST
+  Private-Port-Name: PORT
+  Private-Port: {{.Port}}
+{{- if .Category.IsFramework}}
+  Proxy-Mappings:
+  - Frontend: /
+    Backend: /
+{{- end}}
+# Health-Check:
+#   Type: tcp
+#   Port: {{.Port}}
+#   Interval: 30s
+{{- end}}
+# Cron:
+# - Name: cleanup
+#   Schedule: '@daily'
+#   Command: echo cleanup
+`))
+
+var dockerfileSkeleton = template.Must(template.New("Dockerfile").Parse(`FROM {{.BaseImage}}
+
+# Install packages required by the plugin, e.g.
+# RUN apt-get update && apt-get install -y --no-install-recommends curl \
+#  && rm -rf /var/lib/apt/lists/*
+`))
+
+var controlSkeleton = template.Must(template.New("control").Funcs(template.FuncMap{"upper": strings.ToUpper}).Parse(`#!/bin/bash
+
+start() {
+    echo "Starting {{.DisplayName}}"
+    # Start the {{if .Category.IsFramework}}application{{else}}service{{end}} listening on $CLOUDWAY_{{.Name | upper}}_PORT
+}
+
+stop() {
+    echo "Stopping {{.DisplayName}}"
+}
+
+restart() {
+    stop
+    start
+}
+
+case "$1" in
+    start)    start ;;
+    stop)     stop ;;
+    restart)  restart;;
+    *) exit 0
+esac
+
+exit 0
+`))
+
+var buildSkeleton = template.Must(template.New("build").Parse(`#!/bin/bash
+
+# Build the application in the repository directory $CLOUDWAY_REPO_DIR
+echo "Building application"
+`))
+
+var deploySkeleton = template.Must(template.New("deploy").Parse(`#!/bin/bash
+
+# Deploy the built application
+echo "Deploying application"
+`))
+
+var installSkeleton = template.Must(template.New("install").Parse(`#!/bin/sh
+
+# Install the {{.DisplayName}} library into the application container
+echo "Installing {{.DisplayName}}"
+`))
+
+var indexSkeleton = template.Must(template.New("index").Parse(`<html>
+<body>
+<h1>Welcome to {{.DisplayName}}</h1>
+</body>
+</html>
+`))
+
+func (cli *CWCli) CmdPluginInit(args ...string) (err error) {
+	var p pluginSkeleton
+	var category string
+	var noDockerfile bool
+
+	cmd := cli.Subcmd("plugin:init", "[DIR]")
+	cmd.StringVar(&p.Name, []string{"-name"}, "", "Specify the plugin name, defaults to the directory name")
+	cmd.StringVar(&category, []string{"c", "-category"}, "framework", "Specify the plugin category, 'framework', 'service' or 'library'")
+	cmd.StringVar(&p.Version, []string{"-version"}, "1.0", "Specify the plugin version")
+	cmd.StringVar(&p.Vendor, []string{"-vendor"}, "", "Specify the plugin vendor, defaults to the current user")
+	cmd.StringVar(&p.BaseImage, []string{"-base-image"}, "debian:jessie", "Specify the base image of plugin containers")
+	cmd.IntVar(&p.Port, []string{"p", "-port"}, 8080, "Specify the private port of the plugin endpoint")
+	cmd.BoolVar(&noDockerfile, []string{"-no-dockerfile"}, false, "Use the base image directly instead of generating a Dockerfile")
+	cmd.Require(mflag.Max, 1)
+	cmd.ParseFlags(args, true)
+
+	dir := cmd.Arg(0)
+	if p.Name == "" && dir == "" {
+		return errors.New("Plugin name or directory is required")
+	}
+	if p.Name == "" {
+		p.Name = filepath.Base(dir)
+	}
+	if dir == "" {
+		dir = p.Name
+	}
+	if _, err = os.Stat(dir); err == nil {
+		return fmt.Errorf("%s: directory already exists", dir)
+	}
+
+	switch strings.ToLower(category) {
+	case "framework":
+		p.Category = manifest.Framework
+	case "service":
+		p.Category = manifest.Service
+	case "library":
+		p.Category = manifest.Library
+	default:
+		return fmt.Errorf("Invalid plugin category: %s", category)
+	}
+	if p.Port <= 0 || p.Port > 65535 {
+		return fmt.Errorf("Invalid port: %d", p.Port)
+	}
+	if p.Vendor == "" {
+		if u, err := user.Current(); err == nil {
+			p.Vendor = u.Username
+		}
+	}
+	p.DisplayName = strings.Title(p.Name) + " " + p.Version
+	p.Dockerfile = !noDockerfile
+
+	if err = generatePlugin(dir, &p); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	// check the generated plugin, as the plugin name may be malformed
+	// or reserved
+	if _, diags := manifest.Lint(dir); diags.HasErrors() {
+		for _, d := range diags {
+			fmt.Fprintln(cli.stderr, d)
+		}
+		os.RemoveAll(dir)
+		return errors.New("Failed to generate plugin")
+	}
+
+	fmt.Fprintf(cli.stdout, "Generated %s plugin %s in %s\n", strings.ToLower(string(p.Category)), p.Name, dir)
+	return nil
+}
+
+// generatePlugin writes the plugin skeleton into the directory.
+func generatePlugin(dir string, p *pluginSkeleton) error {
+	for _, f := range skeletonFiles {
+		if f.include != nil && !f.include(p) {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.mode)
+		if err != nil {
+			return err
+		}
+		err = f.template.Execute(out, p)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+func TestGeneratePlugin(t *testing.T) {
+	for _, category := range []manifest.Category{manifest.Framework, manifest.Service, manifest.Library} {
+		for _, dockerfile := range []bool{true, false} {
+			tmp, err := ioutil.TempDir("", "plugin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmp)
+
+			dir := filepath.Join(tmp, "demo")
+			p := &pluginSkeleton{
+				Name:        "demo",
+				DisplayName: "Demo 1.0",
+				Version:     "1.0",
+				Vendor:      "cloudway",
+				Category:    category,
+				BaseImage:   "debian:jessie",
+				Port:        8080,
+				Dockerfile:  dockerfile,
+			}
+			if err = generatePlugin(dir, p); err != nil {
+				t.Fatal(err)
+			}
+
+			plugin, diags := manifest.Lint(dir)
+			if len(diags) != 0 {
+				t.Errorf("%s plugin (Dockerfile: %v): %v", category, dockerfile, diags)
+				continue
+			}
+			if plugin.Category != category || plugin.Version != "1.0" {
+				t.Errorf("%s plugin: unexpected manifest %+v", category, plugin)
+			}
+			if manifest.HasDockerfile(dir) != dockerfile {
+				t.Errorf("%s plugin: Dockerfile generated: %v, want %v", category, !dockerfile, dockerfile)
+			}
+			if !category.IsLibrary() && len(plugin.Endpoints) != 1 {
+				t.Errorf("%s plugin: endpoints %v", category, plugin.Endpoints)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/cmd/cwcli/cmds/ansi"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The width of the progress bar.
+const progressBarWidth = 30
+
+// progressOutput renders progress records from server as a progress bar on
+// the last line of the terminal, below the server output, followed by the
+// last event happened on containers. Progress records are ignored if the
+// output is not a terminal.
+type progressOutput struct {
+	mu     sync.Mutex
+	term   io.Writer
+	bar    string
+	phase  serverlog.Progress
+	closed bool
+}
+
+// progressStream is the standard output or error stream that keeps the
+// progress bar below the output.
+type progressStream struct {
+	p *progressOutput
+	w io.Writer
+}
+
+func newProgressOutput(stdout, stderr io.Writer) (out, err *progressStream, p *progressOutput) {
+	p = &progressOutput{}
+	if ansi.IsTerminal {
+		p.term = stdout
+	}
+	return &progressStream{p, stdout}, &progressStream{p, stderr}, p
+}
+
+func (s *progressStream) Write(b []byte) (int, error) {
+	p := s.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bar == "" {
+		return s.w.Write(b)
+	}
+
+	// redraw the progress bar after complete lines of output
+	p.clear()
+	n, err := s.w.Write(b)
+	if err == nil && len(b) != 0 && b[len(b)-1] == '\n' {
+		io.WriteString(p.term, p.bar)
+	} else {
+		p.bar = ""
+	}
+	return n, err
+}
+
+func (s *progressStream) WriteProgress(prog *serverlog.Progress) {
+	p := s.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.term == nil || p.closed {
+		return
+	}
+	p.clear()
+
+	// show the last event of containers after the progress of the phase,
+	// and keep failures in the output
+	var event string
+	if prog.Event == "" {
+		p.phase = *prog
+	} else {
+		event = shortID(prog.Container) + " " + prog.Event
+		if prog.Bytes > 0 {
+			event += " " + units.BytesSize(float64(prog.Bytes))
+		}
+		if prog.Error != "" {
+			fmt.Fprintf(p.term, "Container %s: %s\n", event, prog.Error)
+		}
+	}
+
+	p.bar = formatProgress(&p.phase)
+	if event != "" {
+		p.bar += "  " + event
+	}
+	io.WriteString(p.term, p.bar)
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// Close removes the progress bar from the terminal.
+func (p *progressOutput) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.bar = ""
+	p.closed = true
+}
+
+func (p *progressOutput) clear() {
+	if p.bar != "" {
+		io.WriteString(p.term, "\r\x1b[K")
+	}
+}
+
+func formatProgress(prog *serverlog.Progress) string {
+	phase := strings.Title(prog.Phase)
+	if prog.Percent < 0 {
+		return phase + "..."
+	}
+
+	pct := prog.Percent
+	if pct > 100 {
+		pct = 100
+	}
+	filled := pct * progressBarWidth / 100
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %3d%%", phase, bar, pct)
+}
@@ -0,0 +1,96 @@
+package cmds
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// selectPlugins prompts the user to select the application framework and
+// services from the installed plugins.
+func (cli *CWCli) selectPlugins(req *types.CreateApplication) error {
+	ctx := context.Background()
+	reader := bufio.NewReader(os.Stdin)
+
+	frameworks, err := cli.GetInstalledPlugins(ctx, manifest.Framework)
+	if err != nil {
+		return err
+	}
+	if len(frameworks) == 0 {
+		return errors.New("No framework plugins installed")
+	}
+
+	fmt.Fprintln(cli.stdout, "Available frameworks:")
+	cli.listChoices(frameworks)
+	choices, err := cli.choose(reader, "Select the application framework: ", len(frameworks), false)
+	if err != nil {
+		return err
+	}
+	req.Framework = frameworks[choices[0]].Name
+
+	services, err := cli.GetInstalledPlugins(ctx, manifest.Service)
+	if err != nil || len(services) == 0 {
+		return err
+	}
+
+	fmt.Fprintln(cli.stdout, "Available services:")
+	cli.listChoices(services)
+	choices, err = cli.choose(reader, "Select services, separated by comma (none): ", len(services), true)
+	if err != nil {
+		return err
+	}
+	for _, i := range choices {
+		req.Services = append(req.Services, services[i].Name)
+	}
+	return nil
+}
+
+func (cli *CWCli) listChoices(plugins []*manifest.Plugin) {
+	for i, p := range plugins {
+		fmt.Fprintf(cli.stdout, "%3d) %-15s %s\n", i+1, p.Name, p.DisplayName)
+	}
+}
+
+// choose reads numbers of selected items from the reader. An empty answer
+// selects nothing if multiple choices are allowed.
+func (cli *CWCli) choose(reader *bufio.Reader, prompt string, n int, multiple bool) ([]int, error) {
+	for {
+		fmt.Fprint(cli.stdout, prompt)
+		answer, err := reader.ReadString('\n')
+		if err == io.EOF && answer == "" {
+			return nil, errors.New("No selection made")
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		answer = strings.TrimSpace(answer)
+		if answer == "" && multiple {
+			return nil, nil
+		}
+
+		var choices []int
+		valid := answer != ""
+		for _, field := range strings.Split(answer, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || i < 1 || i > n {
+				valid = false
+				break
+			}
+			choices = append(choices, i-1)
+		}
+		if valid && (multiple || len(choices) == 1) {
+			return choices, nil
+		}
+		fmt.Fprintf(cli.stdout, "Please enter a number between 1 and %d.\n", n)
+	}
+}
@@ -0,0 +1,116 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdQuota(args ...string) error {
+	var user, set string
+	var reset bool
+
+	cmd := cli.Subcmd("quota", "")
+	cmd.StringVar(&user, []string{"u", "-user"}, "", "Show or change quota of the user, requires administrator privilege")
+	cmd.StringVar(&set, []string{"-set"}, "", "Change quota limits, e.g. applications=5,containers=10,memory=4g,cpus=2")
+	cmd.BoolVar(&reset, []string{"-reset"}, false, "Restore the default quota of the user")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if (set != "" || reset) && user == "" {
+		return errors.New("The --user option is required to change quota")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if set != "" || reset {
+		var quota *types.Quota
+		if !reset {
+			current, err := cli.GetUserQuota(ctx, user)
+			if err != nil {
+				return err
+			}
+			quota = &current.Quota
+			if err = parseQuota(quota, set); err != nil {
+				return err
+			}
+		}
+		if err := cli.SetUserQuota(ctx, user, quota); err != nil {
+			return err
+		}
+	}
+
+	var result *types.QuotaUsage
+	var err error
+	if user == "" {
+		result, err = cli.GetQuota(ctx)
+	} else {
+		result, err = cli.GetUserQuota(ctx, user)
+	}
+	if err != nil {
+		return err
+	}
+
+	q, u := result.Quota, result.Usage
+	tab := NewTable("RESOURCE", "USED", "LIMIT")
+	tab.AddRow("applications", strconv.Itoa(u.Applications), quotaLimit(q.Applications != 0, strconv.Itoa(q.Applications)))
+	tab.AddRow("containers", strconv.Itoa(u.Containers), quotaLimit(q.Containers != 0, strconv.Itoa(q.Containers)))
+	tab.AddRow("memory", units.BytesSize(float64(u.Memory)), quotaLimit(q.Memory != 0, units.BytesSize(float64(q.Memory))))
+	tab.AddRow("cpus", formatCPUs(u.CPUs), quotaLimit(q.CPUs != 0, formatCPUs(q.CPUs)))
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func quotaLimit(limited bool, limit string) string {
+	if limited {
+		return limit
+	}
+	return "unlimited"
+}
+
+func formatCPUs(cpus float64) string {
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
+
+// parseQuota changes quota limits from a comma separated list of key=value
+// pairs, a value of 0 removes the limit.
+func parseQuota(quota *types.Quota, s string) (err error) {
+	for _, kv := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Invalid quota setting: %s", strings.Join(kv, "="))
+		}
+
+		key, value := strings.ToLower(kv[0]), kv[1]
+		switch key {
+		case "applications", "apps":
+			quota.Applications, err = strconv.Atoi(value)
+		case "containers":
+			quota.Containers, err = strconv.Atoi(value)
+		case "memory":
+			if value == "0" {
+				quota.Memory = 0
+			} else {
+				quota.Memory, err = units.RAMInBytes(value)
+			}
+		case "cpus":
+			quota.CPUs, err = strconv.ParseFloat(value, 64)
+		default:
+			return fmt.Errorf("Unknown quota resource: %s", key)
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid %s quota: %s", key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const appRemoteUsage = `Usage: cwcli app:remote [COMMAND]
+
+Manage the external repository the application is deployed from.
+
+Additional commands, type "cwcli help app:remote COMMAND" for more details:
+
+  bind               Deploy the application from a GitHub, GitLab or Git repository
+  unbind             Deploy the application from the platform repository
+`
+
+func (cli *CWCli) CmdAppRemote(args ...string) error {
+	var help bool
+
+	cmd := cli.Subcmd("app:remote", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.BoolVar(&help, []string{"-help"}, false, "Print usage")
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, false)
+
+	if help {
+		fmt.Fprint(cli.stdout, appRemoteUsage)
+		os.Exit(0)
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	repo, err := cli.GetRemote(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		fmt.Fprintln(cli.stdout, "The application is deployed from the platform repository.")
+		return nil
+	}
+	cli.printRemote(repo)
+	return nil
+}
+
+func (cli *CWCli) CmdAppRemoteBind(args ...string) error {
+	cmd := cli.Subcmd("app:remote bind", "DRIVER REPO")
+	cmd.Require(mflag.Exact, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	token := cmd.String([]string{"-token"}, "", "The API token used to register the deploy key")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	opts := types.BindRepository{
+		Driver: cmd.Arg(0),
+		Repo:   cmd.Arg(1),
+		Token:  *token,
+	}
+	repo, err := cli.BindRemote(context.Background(), name, opts)
+	if err != nil {
+		return err
+	}
+	cli.printRemote(repo)
+	return nil
+}
+
+func (cli *CWCli) CmdAppRemoteUnbind(args ...string) error {
+	cmd := cli.Subcmd("app:remote unbind")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.UnbindRemote(context.Background(), name)
+}
+
+func (cli *CWCli) printRemote(repo *types.RemoteRepository) {
+	fmt.Fprintf(cli.stdout, "Driver:      %s\n", repo.Driver)
+	fmt.Fprintf(cli.stdout, "Repository:  %s\n", repo.Repo)
+	fmt.Fprintf(cli.stdout, "URL:         %s\n", repo.URL)
+	fmt.Fprintf(cli.stdout, "Deploy key:  %s\n", repo.PublicKey)
+	if !repo.KeyRegistered {
+		fmt.Fprintln(cli.stdout, "\nAdd the deploy key to the repository with read access.")
+	}
+}
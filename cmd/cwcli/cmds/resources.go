@@ -0,0 +1,65 @@
+package cmds
+
+import (
+	"strconv"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppResources(args ...string) error {
+	var service, memory string
+	var cpus float64
+	var pids int64
+
+	cmd := cli.Subcmd("app:resources", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&service, []string{"s", "-service"}, "", "Change resource limits of the service")
+	cmd.StringVar(&memory, []string{"m", "-memory"}, "", "Memory limit, e.g. 512m")
+	cmd.Float64Var(&cpus, []string{"-cpus"}, 0, "Number of CPUs, e.g. 0.5")
+	cmd.Int64Var(&pids, []string{"-pids-limit"}, 0, "Maximum number of processes")
+	cmd.ParseFlags(args, true)
+	name := cli.getAppName(cmd)
+
+	req := &types.Resources{Service: service, CPUs: cpus, PidsLimit: pids}
+	if memory != "" {
+		var err error
+		if req.Memory, err = units.RAMInBytes(memory); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if req.Memory != 0 || req.CPUs != 0 || req.PidsLimit != 0 {
+		if err := cli.SetResources(ctx, name, req); err != nil {
+			return err
+		}
+	}
+
+	resources, err := cli.GetResources(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("SERVICE", "MEMORY", "CPUS", "PIDS")
+	for _, r := range resources {
+		service := r.Service
+		if service == "" {
+			service = "(framework)"
+		}
+		tab.AddRow(service,
+			quotaLimit(r.Memory != 0, units.BytesSize(float64(r.Memory))),
+			quotaLimit(r.CPUs != 0, formatCPUs(r.CPUs)),
+			quotaLimit(r.PidsLimit != 0, strconv.FormatInt(r.PidsLimit, 10)))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
@@ -0,0 +1,178 @@
+package cmds
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+const secretUsage = `Usage: cwcli secret COMMAND [ARGS...]
+
+Manage secrets of the application.
+
+Additional commands, type "cwcli help secret COMMAND" for more details:
+
+  list               List secrets of the application
+  set                Create or update a secret of the application
+  rotate             Replace the value of a secret
+  remove             Remove a secret from the application
+  audit              Show recent operations on secrets of the application
+
+Secrets are encrypted when stored by the platform, and values are never
+shown after set. A secret is written to a file in /run/secrets of running
+application containers, or set as an environment variable if mounted to
+env. Secrets are kept in memory and never saved to the container file
+system, so they are not included in backups.
+`
+
+func (cli *CWCli) CmdSecret(args ...string) error {
+	fmt.Fprint(cli.stdout, secretUsage)
+	os.Exit(0)
+	return nil
+}
+
+func (cli *CWCli) CmdSecretList(args ...string) error {
+	cmd := cli.Subcmd("secret list", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	secrets, err := cli.ListSecrets(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("NAME", "MOUNT", "VERSION", "UPDATED")
+	for _, s := range secrets {
+		tab.AddRow(s.Name, s.Mount, strconv.Itoa(s.Version), s.Updated.Local().Format("2006-01-02 15:04"))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdSecretSet(args ...string) error {
+	var env bool
+
+	cmd := cli.Subcmd("secret set", "NAME [VALUE]")
+	cmd.Require(mflag.Min, 1)
+	cmd.Require(mflag.Max, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&env, []string{"-env"}, false, "Set the secret as an environment variable instead of a file")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	value, err := secretValue(cmd)
+	if err != nil {
+		return err
+	}
+
+	mount := "file"
+	if env {
+		mount = "env"
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	s, err := cli.SetSecret(context.Background(), name, cmd.Arg(0), value, mount)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Secret %s set to version %d\n", s.Name, s.Version)
+	return nil
+}
+
+func (cli *CWCli) CmdSecretRotate(args ...string) error {
+	var generate bool
+
+	cmd := cli.Subcmd("secret rotate", "NAME [VALUE]")
+	cmd.Require(mflag.Min, 1)
+	cmd.Require(mflag.Max, 2)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.BoolVar(&generate, []string{"-generate"}, false, "Generate a random value")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	var value string
+	if !generate {
+		var err error
+		if value, err = secretValue(cmd); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	s, err := cli.RotateSecret(context.Background(), name, cmd.Arg(0), value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Secret %s rotated to version %d\n", s.Name, s.Version)
+	return nil
+}
+
+func (cli *CWCli) CmdSecretRemove(args ...string) error {
+	cmd := cli.Subcmd("secret remove", "NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveSecret(context.Background(), name, cmd.Arg(0))
+}
+
+func (cli *CWCli) CmdSecretAudit(args ...string) error {
+	cmd := cli.Subcmd("secret audit", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.ParseFlags(args, true)
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	audit, err := cli.GetSecretAudit(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("TIME", "ACTION", "SECRET", "BY")
+	for _, a := range audit {
+		by := a.User
+		if by == "" {
+			by = "container " + a.Container
+		}
+		tab.AddRow(a.Time.Local().Format("2006-01-02 15:04:05"), a.Action, a.Secret, by)
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+// secretValue returns the secret value from command line, or reads it from
+// standard input if not given, so the value is not kept in shell history.
+func secretValue(cmd *mflag.FlagSet) (string, error) {
+	if cmd.NArg() > 1 {
+		return cmd.Arg(1), nil
+	}
+	value, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(value), "\r\n"), nil
+}
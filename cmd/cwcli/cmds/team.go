@@ -0,0 +1,82 @@
+package cmds
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+// The environment variable selects the namespace shared by other user that
+// application commands operate on.
+const namespaceEnv = "CLOUDWAY_NAMESPACE"
+
+func (cli *CWCli) CmdTeam(args ...string) error {
+	cmd := cli.Subcmd("team", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	teams, err := cli.GetTeams(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("NAMESPACE", "OWNER", "ROLE", "APPLICATIONS")
+	for _, t := range teams {
+		tab.AddRow(t.Namespace, t.Owner, t.Role, strings.Join(t.Applications, ","))
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdTeamMembers(args ...string) error {
+	cmd := cli.Subcmd("team:members", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	members, err := cli.GetMembers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("NAME", "ROLE")
+	for _, m := range members {
+		tab.AddRow(m.Name, m.Role)
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdTeamAdd(args ...string) error {
+	var role string
+
+	cmd := cli.Subcmd("team:add", "USER")
+	cmd.StringVar(&role, []string{"r", "-role"}, "deployer", "The role of the member, 'owner', 'deployer' or 'viewer'")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.AddMember(context.Background(), cmd.Arg(0), role)
+}
+
+func (cli *CWCli) CmdTeamRemove(args ...string) error {
+	cmd := cli.Subcmd("team:remove", "USER")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveMember(context.Background(), cmd.Arg(0))
+}
@@ -0,0 +1,112 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdAppCopy(args ...string) error {
+	var req types.CloneApplication
+	var noclone bool
+
+	cmd := cli.Subcmd("app:copy", "[OPTIONS] NAME")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name to copy from")
+	cmd.BoolVar(&req.Repo, []string{"r", "-repo"}, false, "Copy the deployed repository")
+	cmd.BoolVar(&noclone, []string{"n", "-no-clone"}, false, "Do not clone source code")
+	cmd.ParseFlags(args, true)
+	req.Name = cmd.Arg(0)
+
+	if !noclone {
+		if _, err := os.Stat(req.Name); !os.IsNotExist(err) {
+			if err == nil {
+				err = fmt.Errorf("destination path '%s' already exists", req.Name)
+			}
+			return err
+		}
+	}
+
+	name := cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	app, err := cli.CloneApplication(context.Background(), name, req, cli.stdout, cli.stderr)
+	if err != nil {
+		return err
+	}
+	if !noclone && app.CloneURL != "" {
+		return gitClone(cli.host, app, false)
+	}
+	return nil
+}
+
+func (cli *CWCli) CmdAppTemplate(args ...string) error {
+	var req types.SaveTemplate
+
+	cmd := cli.Subcmd("app:template", "[OPTIONS] TEMPLATE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.String([]string{"a", "-app"}, "", "Specify the application name")
+	cmd.StringVar(&req.Description, []string{"d", "-description"}, "", "Describe the template")
+	cmd.BoolVar(&req.Repo, []string{"r", "-repo"}, false, "Save the deployed repository with the template")
+	cmd.ParseFlags(args, true)
+	req.Name = cmd.Arg(0)
+
+	req.Application = cli.getAppName(cmd)
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	t, err := cli.SaveTemplate(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Template %s saved, create applications with 'cwcli app:create --template %s NAME'\n", t.Name, t.Name)
+	return nil
+}
+
+func (cli *CWCli) CmdTemplate(args ...string) error {
+	cmd := cli.Subcmd("template", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	templates, err := cli.GetTemplates(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("NAME", "PLUGINS", "REPO", "CREATED", "DESCRIPTION")
+	for _, t := range templates {
+		repo := "no"
+		if t.Repo {
+			repo = "yes"
+		}
+		tab.AddRow(t.Name, strings.Join(t.Plugins, ","), repo,
+			units.HumanDuration(time.Since(t.Created))+" ago", t.Description)
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdTemplateRemove(args ...string) error {
+	cmd := cli.Subcmd("template:remove", "TEMPLATE")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RemoveTemplate(context.Background(), cmd.Arg(0))
+}
@@ -0,0 +1,76 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/api/types"
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) CmdToken(args ...string) error {
+	cmd := cli.Subcmd("token", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	tokens, err := cli.ListTokens(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tab := NewTable("ID", "NAME", "SCOPES", "CREATED", "EXPIRES")
+	for _, t := range tokens {
+		expires := "never"
+		if !t.Expires.IsZero() {
+			expires = t.Expires.Local().Format("2006-01-02 15:04")
+		}
+		tab.AddRow(t.ID, t.Name, strings.Join(t.Scopes, ","), t.Created.Local().Format("2006-01-02 15:04"), expires)
+	}
+	tab.Display(cli.stdout, 2)
+	return nil
+}
+
+func (cli *CWCli) CmdTokenCreate(args ...string) error {
+	var scopes, expires string
+
+	cmd := cli.Subcmd("token:create", "NAME")
+	cmd.StringVar(&scopes, []string{"s", "-scope"}, "", "Comma separated permissions granted to the token, 'read', 'write' or 'admin'")
+	cmd.StringVar(&expires, []string{"-expires"}, "", "Duration after which the token expires, e.g. 720h")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	opts := types.CreateToken{Name: cmd.Arg(0), ExpiresIn: expires}
+	if scopes != "" {
+		opts.Scopes = strings.Split(scopes, ",")
+	}
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	token, err := cli.CreateToken(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cli.stdout, token.Token)
+	fmt.Fprintln(cli.stderr, "Make sure to copy the token now, you won't be able to see it again.")
+	return nil
+}
+
+func (cli *CWCli) CmdTokenRevoke(args ...string) error {
+	cmd := cli.Subcmd("token:revoke", "ID")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+	return cli.RevokeToken(context.Background(), cmd.Arg(0))
+}
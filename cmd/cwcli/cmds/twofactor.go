@@ -0,0 +1,97 @@
+package cmds
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/mflag"
+)
+
+func (cli *CWCli) Cmd2fa(args ...string) error {
+	cmd := cli.Subcmd("2fa", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	status, err := cli.GetTwoFactor(context.Background())
+	if err != nil {
+		return err
+	}
+	if status.Enabled {
+		fmt.Fprintf(cli.stdout, "Two-factor authentication is enabled, %d recovery codes left.\n", status.RecoveryCodes)
+	} else {
+		fmt.Fprintln(cli.stdout, "Two-factor authentication is disabled.")
+	}
+	return nil
+}
+
+func (cli *CWCli) Cmd2faEnable(args ...string) error {
+	cmd := cli.Subcmd("2fa:enable", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	enroll, err := cli.EnrollTwoFactor(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cli.stdout, "Add the following key or URL to your authenticator app:")
+	fmt.Fprintln(cli.stdout)
+	fmt.Fprintf(cli.stdout, "  Key: %s\n", enroll.Secret)
+	fmt.Fprintf(cli.stdout, "  URL: %s\n", enroll.URL)
+	fmt.Fprintln(cli.stdout)
+
+	code, err := cli.readAuthCode()
+	if err != nil {
+		return err
+	}
+	codes, err := cli.EnableTwoFactor(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cli.stdout, "Two-factor authentication is enabled. Save the following recovery codes")
+	fmt.Fprintln(cli.stdout, "in a safe place, each code can be used once if you lost your device:")
+	fmt.Fprintln(cli.stdout)
+	for _, c := range codes {
+		fmt.Fprintf(cli.stdout, "  %s\n", c)
+	}
+	return nil
+}
+
+func (cli *CWCli) Cmd2faDisable(args ...string) error {
+	cmd := cli.Subcmd("2fa:disable", "")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	if err := cli.ConnectAndLogin(); err != nil {
+		return err
+	}
+
+	code, err := cli.readAuthCode()
+	if err != nil {
+		return err
+	}
+	return cli.DisableTwoFactor(context.Background(), code)
+}
+
+func (cli *CWCli) readAuthCode() (string, error) {
+	fmt.Fprintf(cli.stdout, "Authentication code: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(code), nil
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/cloudway/platform/api/client"
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/cmd/cwcli/cmds"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/colorable"
@@ -74,15 +76,36 @@ func main() {
 
 	c := cmds.Init(host, stdout, stderr)
 	if err := c.Run(flag.Args()...); err != nil {
+		if se, ok := err.(cmds.StatusError); ok {
+			os.Exit(se.StatusCode)
+		}
 		if se, ok := err.(rest.ServerError); ok && se.StatusCode() == http.StatusUnauthorized {
 			fmt.Fprintln(stderr, "Your access token has been expired, please login again.")
 		} else {
 			fmt.Fprintln(stderr, err)
+			if hint := errorHint(err); hint != "" {
+				fmt.Fprintln(stderr, hint)
+			}
 		}
 		os.Exit(1)
 	}
 }
 
+// errorHint returns a suggestion to resolve the error returned from server.
+func errorHint(err error) string {
+	switch client.ErrorCode(err) {
+	case types.ErrCodeQuotaExceeded:
+		return "Run 'cwcli quota' to show your resource quota and usage."
+	case types.ErrCodeTooManyRequests:
+		if v, ok := client.ErrorDetails(err)["RetryAfter"].(float64); ok {
+			return fmt.Sprintf("Please try again after %d seconds.", int(v))
+		}
+	case "no_namespace":
+		return "Run 'cwcli namespace --set NAMESPACE' to create a namespace."
+	}
+	return ""
+}
+
 func parseHost(host string) (string, error) {
 	if strings.Contains(host, "://") {
 		if u, err := url.Parse(host); err != nil {
@@ -40,6 +40,8 @@ func Init() *CWCtl {
 		"restart": cli.CmdRestart,
 		"daemon":  cli.CmdDaemon,
 		"build":   cli.CmdBuild,
+		"migrate": cli.CmdMigrate,
+		"verify":  cli.CmdVerify,
 		"status":  cli.CmdStatus,
 		"dump":    cli.CmdDump,
 		"restore": cli.CmdRestore,
@@ -52,6 +54,7 @@ func Init() *CWCtl {
 		cli.handlers["info"] = cli.CmdInfo
 		cli.handlers["setenv"] = cli.CmdSetenv
 		cli.handlers["install"] = cli.CmdInstall
+		cli.handlers["reload"] = cli.CmdReload
 	}
 
 	for _, cmd := range CommandUsage {
@@ -28,6 +28,13 @@ func (cli *CWCtl) CmdRestart(args ...string) error {
 	return sandbox.New().Restart()
 }
 
+func (cli *CWCtl) CmdReload(args ...string) error {
+	cmd := cli.Subcmd("reload")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+	return sandbox.New().Reload()
+}
+
 func (cli *CWCtl) CmdStatus(args ...string) error {
 	cmd := cli.Subcmd("status")
 	cmd.Require(mflag.Exact, 0)
@@ -0,0 +1,15 @@
+package cmds
+
+import (
+	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/sandbox"
+)
+
+// CmdVerify verifies the SHA-256 digest of a deployment archive copied into
+// the deploy directory, before the application is restarted onto it.
+func (cli *CWCtl) CmdVerify(args ...string) error {
+	cmd := cli.Subcmd("verify", "NAME SHA256")
+	cmd.Require(mflag.Exact, 2)
+	cmd.ParseFlags(args, true)
+	return sandbox.New().VerifyDeployment(cmd.Arg(0), cmd.Arg(1))
+}
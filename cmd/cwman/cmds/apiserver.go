@@ -1,54 +1,159 @@
 package cmds
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	prof "runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
 
 	"github.com/cloudway/platform/api/server"
 	"github.com/cloudway/platform/api/server/middleware"
+	"github.com/cloudway/platform/api/server/router/admin"
 	"github.com/cloudway/platform/api/server/router/applications"
 	"github.com/cloudway/platform/api/server/router/namespace"
 	"github.com/cloudway/platform/api/server/router/plugins"
+	"github.com/cloudway/platform/api/server/router/scm"
+	"github.com/cloudway/platform/api/server/router/spec"
 	"github.com/cloudway/platform/api/server/router/system"
+	"github.com/cloudway/platform/api/server/router/users"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/console"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/discovery"
 )
 
 const _CONTEXT_ROOT = "/api"
 
+// The time to wait for in-flight requests after deployments are drained,
+// such as streaming logs that never finish by themselves.
+const requestDrainTimeout = 5 * time.Second
+
 func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	var addr string
 
 	cmd := cli.Subcmd("api-server")
-	cmd.StringVar(&addr, []string{"-bind"}, ":6616", "API server bind address")
+	cmd.StringVar(&addr, []string{"-bind"}, "", "API server bind addresses, separated by comma")
 	cmd.ParseFlags(args, true)
 
+	// report all configuration errors before starting any services
+	if err = config.Validate(); err != nil {
+		return err
+	}
+	if addr == "" {
+		addr = config.GetOrDefault("apiserver.listen", ":6616")
+	}
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	drainTimeout, err := time.ParseDuration(config.GetOrDefault("apiserver.drain_timeout", "2m"))
+	if err != nil {
+		return fmt.Errorf("Invalid apiserver.drain_timeout configuration: %s", config.Get("apiserver.drain_timeout"))
+	}
+
 	stopc := make(chan bool)
-	defer close(stopc)
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(stopc) }) }
+	defer stop()
+
+	done := make(chan struct{})
+	defer close(done)
 
 	br, err := broker.New(cli.DockerClient)
 	if err != nil {
 		return err
 	}
+	defer br.Close()
 
-	con, err := console.NewConsole(br)
+	// run scheduled jobs of applications
+	go br.RunScheduler(stopc)
+
+	// issue and renew certificates of custom domains
+	go br.RunCertificateRenewal(stopc)
+
+	// monitor health of application containers
+	monitor, err := container.NewMonitor(cli.DockerClient)
 	if err != nil {
 		return err
 	}
+	monitor.OnCrashLoop = br.NotifyCrashLoop
+	go monitor.Run(stopc)
 
-	api := server.New(_CONTEXT_ROOT)
+	// resolve names of application services
+	registry, err := discovery.New(stopc)
+	if err != nil {
+		return err
+	}
 
-	l, err := net.Listen("tcp", addr)
+	// watch containers dying or removed out of band, and update the
+	// service registry on container lifecycle events
+	watcher := container.NewEventWatcher(cli.DockerClient)
+	watcher.OnEvent = br.HandleContainerEvent
+	if registry != nil {
+		watcher.OnEvent = func(e *container.ContainerEvent) {
+			br.HandleContainerEvent(e)
+			registry.HandleEvent(cli.DockerClient, e)
+		}
+		watcher.OnResync = func(container.DockerClient) {
+			if err := registry.Rebuild(context.Background(), cli.DockerClient); err != nil {
+				logrus.WithError(err).Warn("Failed to rebuild service registry")
+			}
+		}
+	}
+	go watcher.Run(stopc)
+
+	// ship logs of application containers to the log store
+	go br.RunLogShipper(stopc)
+
+	// idle applications without requests
+	go br.RunIdleMonitor(stopc)
+
+	// expire warm builder containers
+	go cli.DockerClient.RunBuilderPool(stopc)
+
+	// remove orphaned builders, unused images and stale temporary files
+	go cli.DockerClient.RunGarbageCollector(stopc)
+
+	con, err := console.NewConsole(br)
 	if err != nil {
 		return err
 	}
-	api.Accept(addr, l)
+
+	api := server.New(_CONTEXT_ROOT)
+
+	for _, a := range strings.Split(addr, ",") {
+		a = strings.TrimSpace(a)
+		l, err := net.Listen("tcp", a)
+		if err != nil {
+			api.Close()
+			return err
+		}
+		if tlsConfig != nil {
+			l = tls.NewListener(l, tlsConfig)
+		}
+		api.Accept(a, l)
+	}
+	if tlsConfig != nil {
+		if err = listenRedirect(api, addr); err != nil {
+			api.Close()
+			return err
+		}
+	}
 
 	initMiddlewares(api, br)
 	initRouters(api, br)
@@ -62,8 +167,9 @@ func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	go api.Wait(waitChan)
 	trapSignals(func() {
 		api.Close()
-		<-stopc // wait for CmdServer() to return
+		<-done // wait for CmdAPIServer() to return
 	})
+	reloadOnHangup()
 
 	// Server is fully initialized and handling API traffic.
 	// Wait for serve API to complete
@@ -71,13 +177,91 @@ func (cli *CWMan) CmdAPIServer(args ...string) (err error) {
 	if apiErr != nil {
 		logrus.WithError(apiErr).Error("API server error")
 	}
+
+	// stop background jobs and drain in-flight deployments and requests
+	stop()
+	logrus.Infof("Draining in-flight deployments and requests, timeout %v", drainTimeout)
+	if !br.DrainDeployments(drainTimeout) {
+		logrus.Warn("Some deployments are not finished")
+	}
+	if !api.Drain(requestDrainTimeout) {
+		logrus.Warn("Some requests are not finished")
+	}
+
 	logrus.Info("API server terminated")
 	return nil
 }
 
+// serverTLSConfig returns the TLS configuration of the API server if the
+// certificate is configured by apiserver.tls_cert and apiserver.tls_key.
+// Client certificates signed by CAs in apiserver.tls_client_ca are used to
+// authenticate machine users. Relative file names are resolved from the
+// configuration directory.
+func serverTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := confFile("apiserver.tls_cert"), confFile("apiserver.tls_key")
+	if certFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load API server certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := confFile("apiserver.tls_client_ca"); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client CA certificates: %v", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No client CA certificates found in %s", caFile)
+		}
+		// client certificates are optional, users can still be
+		// authenticated by access tokens
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// confFile returns the file name configured by the key, relative file names
+// are resolved from the configuration directory.
+func confFile(key string) string {
+	name := config.Get(key)
+	if name != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(config.RootDir, "conf", name)
+	}
+	return name
+}
+
+// listenRedirect listens on the address configured by apiserver.http_redirect
+// to redirect plain HTTP requests to the HTTPS port of the first bind address.
+func listenRedirect(api *server.Server, bindAddr string) error {
+	addr := config.Get("apiserver.http_redirect")
+	if addr == "" {
+		return nil
+	}
+
+	_, port, err := net.SplitHostPort(strings.TrimSpace(strings.Split(bindAddr, ",")[0]))
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	api.AcceptRedirect(addr, l, port)
+	return nil
+}
+
 func initMiddlewares(s *server.Server, br *broker.Broker) {
 	s.UseMiddleware(middleware.NewVersionMiddleware(br))
 	s.UseMiddleware(middleware.NewAuthMiddleware(br, _CONTEXT_ROOT))
+	s.UseMiddleware(middleware.NewRateLimitMiddleware(br, _CONTEXT_ROOT))
 }
 
 func initRouters(s *server.Server, br *broker.Broker) {
@@ -86,6 +270,10 @@ func initRouters(s *server.Server, br *broker.Broker) {
 		plugins.NewRouter(br),
 		namespace.NewRouter(br),
 		applications.NewRouter(br),
+		admin.NewRouter(br),
+		users.NewRouter(br),
+		scm.NewRouter(br),
+		spec.NewRouter(s.Routers, _CONTEXT_ROOT, middleware.IsPublicPath),
 	)
 }
 
@@ -121,6 +309,26 @@ func trapSignals(cleanup func()) {
 	}()
 }
 
+// reloadOnHangup reloads the configuration when SIGHUP is received. Changes
+// of configurations that are not reloadable are reported to the log.
+func reloadOnHangup() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			logrus.Info("Reloading configuration")
+			restart, err := config.Reload()
+			if err != nil {
+				logrus.WithError(err).Error("Failed to reload configuration")
+				continue
+			}
+			for _, key := range restart {
+				logrus.Warnf("Configuration %s is changed, restart API server to take effect", key)
+			}
+		}
+	}()
+}
+
 func dumpStacks() {
 	var buf []byte
 	var stackSize int
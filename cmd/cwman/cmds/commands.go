@@ -23,9 +23,13 @@ var CommandUsage = []Command{
 	{"api-server", "Start the API server"},
 	{"config", "Get or set a configuration value"},
 	{"install", "Install one or more plugins"},
+	{"proxy-server", "Start the built-in proxy server"},
 	{"upgrade", "Upgrade application containers"},
 	{"useradd", "Add a user"},
 	{"userdel", "Remove a user"},
+	{"userexport", "Export the user database"},
+	{"userimport", "Import the user database"},
+	{"usermigrate", "Migrate the user database to another database"},
 }
 
 var Commands = make(map[string]Command)
@@ -45,6 +49,7 @@ func Init(docker container.DockerClient) *CWMan {
 	cli.handlers = map[string]func(...string) error{
 		"api-server":   cli.CmdAPIServer,
 		"update-proxy": cli.CmdUpdateProxy,
+		"proxy-server": cli.CmdProxyServer,
 		"sshd":         cli.CmdSshd,
 		"git-ssh":      cli.CmdGitSSH,
 		"config":       cli.CmdConfig,
@@ -53,6 +58,9 @@ func Init(docker container.DockerClient) *CWMan {
 		"upgrade":      cli.CmdUpgrade,
 		"useradd":      cli.CmdUserAdd,
 		"userdel":      cli.CmdUserDel,
+		"userexport":   cli.CmdUserExport,
+		"userimport":   cli.CmdUserImport,
+		"usermigrate":  cli.CmdUserMigrate,
 	}
 
 	return cli
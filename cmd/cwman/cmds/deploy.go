@@ -1,19 +1,84 @@
 package cmds
 
 import (
+	"io"
 	"os"
 
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/mflag"
 	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/proxy"
 	"golang.org/x/net/context"
 )
 
+// The environment variable containing build variables encoded by
+// container.DeployOptions.EncodeBuildEnv.
+const buildEnvVar = "CLOUDWAY_BUILD_ENV"
+
 func (cli *CWMan) CmdDeploy(args ...string) (err error) {
 	cmd := cli.Subcmd("deploy", "NAME NAMESPACE")
+	condition := cmd.String([]string{"-if", "-condition"}, "", "Deploy only if the current application is 'healthy' or 'unhealthy'")
+	strategy := cmd.String([]string{"-strategy"}, "", "Deploy strategy, 'all-at-once', 'rolling', 'blue-green' or 'canary'")
+	batch := cmd.Int([]string{"-batch"}, 1, "Number of containers updated at a time in rolling deployment")
+	failures := cmd.Int([]string{"-max-failures"}, 0, "Number of failed containers tolerated in rolling deployment")
+	retries := cmd.Int([]string{"-retries"}, 0, "Number of times to retry deploying to a failed container")
+	onFailure := cmd.String([]string{"-on-failure"}, "", "On container failure, 'continue' with other containers or 'rollback' updated containers")
+	canary := cmd.Int([]string{"-canary"}, 0, "Percentage of containers updated in canary deployment")
+	weight := cmd.Int([]string{"-weight"}, 0, "Percentage of traffic routed to canary containers")
+	delta := cmd.Bool([]string{"-delta"}, false, "Only transfer files changed since the previous deployment")
+	pullEnv := cmd.Bool([]string{"-pull-env"}, false, "Pull the application environment into the build")
 	cmd.Require(mflag.Exact, 2)
 	cmd.ParseFlags(args, true)
 
+	opts := container.DeployOptions{
+		BatchSize:        *batch,
+		FailureThreshold: *failures,
+		Retries:          *retries,
+		CanaryPercent:    *canary,
+		CanaryWeight:     *weight,
+		Delta:            *delta,
+		PullEnv:          *pullEnv,
+	}
+
+	// build variables are passed in environment rather than command line
+	// arguments, which are visible to all users
+	if err = opts.ParseBuildEnv(os.Getenv(buildEnvVar)); err != nil {
+		return err
+	}
+
+	if opts.Condition, err = container.ParseDeployCondition(*condition); err != nil {
+		return err
+	}
+	if opts.Strategy, err = container.ParseDeployStrategy(*strategy); err != nil {
+		return err
+	}
+	if opts.OnFailure, err = container.ParseFailurePolicy(*onFailure); err != nil {
+		return err
+	}
+
+	if opts.Strategy == container.DeployBlueGreen || opts.Strategy == container.DeployCanary {
+		px, err := proxy.New(config.Get("proxy.url"))
+		if err != nil {
+			return err
+		}
+		defer px.Close()
+		opts.Switcher, opts.Splitter = px, px
+	}
+
 	name, namespace := cmd.Arg(0), cmd.Arg(1)
+
+	// keep deployed repository in deployment history
+	br, err := broker.New(cli.DockerClient)
+	if err != nil {
+		return err
+	}
+	defer br.Close()
+	opts.Record = func(repo io.Reader) error {
+		return br.RecordDeployment(namespace, name, repo)
+	}
+
 	log := serverlog.Encap(os.Stdout, os.Stderr)
-	return cli.DeployRepo(context.Background(), name, namespace, os.Stdin, log)
+	return cli.DeployRepo(context.Background(), name, namespace, os.Stdin, opts, log)
 }
@@ -1,8 +1,17 @@
 package cmds
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/net/context"
+
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/files"
 	"github.com/cloudway/platform/pkg/mflag"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/scanner"
 )
 
 func (cli *CWMan) CmdInstallPlugin(args ...string) error {
@@ -10,16 +19,53 @@ func (cli *CWMan) CmdInstallPlugin(args ...string) error {
 	cmd.Require(mflag.Min, 1)
 	cmd.ParseFlags(args, true)
 
-	hub, err := hub.New()
+	h, err := hub.New()
 	if err != nil {
 		return err
 	}
 
 	for _, path := range cmd.Args() {
-		if err = hub.InstallPlugin("", path); err != nil {
+		if err = cli.installPlugin(h, path); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// installPlugin installs a system plugin, the base image of the plugin is
+// built if the plugin ships with a Dockerfile.
+func (cli *CWMan) installPlugin(h *hub.PluginHub, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if fi.IsDir() {
+		err = files.CopyFiles(path, dir)
+	} else {
+		err = files.ExtractFiles(path, dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	log := serverlog.Encap(os.Stdout, os.Stderr)
+	if err = cli.BuildPluginImage(context.Background(), "", dir, nil, log); err != nil {
+		return err
+	}
+	if s, err := scanner.New(); err != nil {
+		return err
+	} else if s != nil {
+		if _, err = scanner.ScanPlugin(context.Background(), s, dir, log); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to scan plugin image: %v\n", err)
+		}
+	}
+	return h.InstallPlugin("", dir)
+}
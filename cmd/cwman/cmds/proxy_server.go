@@ -0,0 +1,66 @@
+package cmds
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/proxy"
+)
+
+// CmdProxyServer starts the built-in proxy server. The routing table is
+// updated by container events, and by the broker through the admin API
+// when the proxy URL is configured as builtin://ADMIN-ADDRESS. Requests
+// to idle applications are held until applications are woken up.
+func (cli *CWMan) CmdProxyServer(args ...string) error {
+	var addr, tlsAddr, adminAddr, certDir string
+	var wakeTimeout time.Duration
+
+	cmd := cli.Subcmd("proxy-server")
+	cmd.StringVar(&addr, []string{"-bind"}, ":80", "HTTP bind address")
+	cmd.StringVar(&tlsAddr, []string{"-tls-bind"}, ":443", "HTTPS bind address, empty to disable HTTPS")
+	cmd.StringVar(&adminAddr, []string{"-admin-bind"}, "127.0.0.1:6380", "Admin API bind address")
+	cmd.StringVar(&certDir, []string{"-cert-dir"}, "/var/lib/cloudway/certs", "Directory to store certificates")
+	cmd.DurationVar(&wakeTimeout, []string{"-wake-timeout"}, 2*time.Minute, "Time to hold requests while waking up idle applications")
+	cmd.ParseFlags(args, true)
+
+	table, err := proxy.NewRoutingTable(certDir)
+	if err != nil {
+		return err
+	}
+
+	br, err := broker.New(cli.DockerClient)
+	if err != nil {
+		logrus.WithError(err).Warn("Idle applications are not woken up on request")
+	} else {
+		defer br.Close()
+		table.Wake = br.WakeApplication
+		table.WakeTimeout = wakeTimeout
+	}
+
+	errc := make(chan error, 4)
+	go func() {
+		errc <- http.ListenAndServe(adminAddr, proxy.NewAdminHandler(table))
+	}()
+	go func() {
+		errc <- http.ListenAndServe(addr, table)
+	}()
+	if tlsAddr != "" {
+		srv := &http.Server{
+			Addr:      tlsAddr,
+			Handler:   table,
+			TLSConfig: &tls.Config{GetCertificate: table.GetCertificate},
+		}
+		go func() {
+			errc <- srv.ListenAndServeTLS("", "")
+		}()
+	}
+	go func() {
+		errc <- proxy.RunUpdater(cli.DockerClient, table)
+	}()
+
+	return <-errc
+}
@@ -1,8 +1,12 @@
 package cmds
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/cloudway/platform/auth/userdb"
 	"github.com/cloudway/platform/broker"
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
 	"github.com/cloudway/platform/pkg/mflag"
 )
@@ -13,11 +17,23 @@ type CustomUser struct {
 }
 
 func (cli *CWMan) CmdUserAdd(args ...string) (err error) {
+	var admin bool
+	var role string
+
 	cmd := cli.Subcmd("useradd", "USERNAME PASSWORD [NAMESPACE]")
+	cmd.BoolVar(&admin, []string{"-admin"}, false, "Grant administrator privilege to the user")
+	cmd.StringVar(&role, []string{"-role"}, "", "The user role, 'admin', 'developer' or 'viewer'")
 	cmd.Require(mflag.Min, 2)
 	cmd.Require(mflag.Max, 3)
 	cmd.ParseFlags(args, true)
 
+	var userRole userdb.Role
+	if role != "" {
+		if userRole, err = userdb.ParseRole(role); err != nil {
+			return err
+		}
+	}
+
 	br, err := broker.New(cli.DockerClient)
 	if err != nil {
 		return err
@@ -26,6 +42,8 @@ func (cli *CWMan) CmdUserAdd(args ...string) (err error) {
 	user := &CustomUser{}
 	user.Name = cmd.Arg(0)
 	user.Email = user.Name + "@" + defaults.Domain()
+	user.Admin = admin
+	user.Role = userRole
 	if cmd.NArg() == 3 {
 		user.Namespace = cmd.Arg(2)
 	}
@@ -43,3 +61,111 @@ func (cli *CWMan) CmdUserDel(args ...string) error {
 	}
 	return br.RemoveUser(cmd.Arg(0))
 }
+
+func (cli *CWMan) CmdUserExport(args ...string) error {
+	var output string
+
+	cmd := cli.Subcmd("userexport", "")
+	cmd.StringVar(&output, []string{"o", "-output"}, "", "Write to a file instead of standard output")
+	cmd.Require(mflag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	db, err := userdb.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if output == "" {
+		return db.Export(os.Stdout)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return db.Export(f)
+}
+
+func (cli *CWMan) CmdUserImport(args ...string) error {
+	var replace bool
+
+	cmd := cli.Subcmd("userimport", "FILE")
+	cmd.BoolVar(&replace, []string{"-replace"}, false, "Replace existing users")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	f, err := os.Open(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := userdb.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	opts := userdb.ImportOptions{Mode: userdb.ImportMerge}
+	if replace {
+		opts.Mode = userdb.ImportReplace
+	}
+
+	result, err := db.Import(f, opts)
+	if result != nil {
+		for _, name := range result.Imported {
+			fmt.Fprintf(os.Stdout, "imported: %s\n", name)
+		}
+		for _, name := range result.Skipped {
+			fmt.Fprintf(os.Stdout, "skipped: %s\n", name)
+		}
+	}
+	return err
+}
+
+func (cli *CWMan) CmdUserMigrate(args ...string) error {
+	var from string
+	var replace bool
+
+	cmd := cli.Subcmd("usermigrate", "URL")
+	cmd.StringVar(&from, []string{"-from"}, "", "Migrate from the database URL instead of the configured database")
+	cmd.BoolVar(&replace, []string{"-replace"}, false, "Replace existing users")
+	cmd.Require(mflag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	var src userdb.Plugin
+	var err error
+	if from == "" {
+		src, err = userdb.OpenPlugin(config.Get("userdb.type"), config.Get("userdb.url"))
+	} else {
+		src, err = userdb.OpenPlugin("", from)
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := userdb.OpenPlugin("", cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	opts := userdb.ImportOptions{Mode: userdb.ImportMerge}
+	if replace {
+		opts.Mode = userdb.ImportReplace
+	}
+
+	result, err := userdb.Migrate(src, dst, opts)
+	if result != nil {
+		for _, name := range result.Imported {
+			fmt.Fprintf(os.Stdout, "migrated: %s\n", name)
+		}
+		for _, name := range result.Skipped {
+			fmt.Fprintf(os.Stdout, "skipped: %s\n", name)
+		}
+	}
+	return err
+}
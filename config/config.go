@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,8 +14,12 @@ import (
 type Config struct {
 	filename string
 	cfg      *conf.ConfigFile
+	readonly bool
 }
 
+// Error is returned when saving a configuration file that is read only.
+var ErrReadOnly = errors.New("the configuration file is read only, edit it directly")
+
 // Create a new configuration.
 func New(filename string) *Config {
 	return &Config{filename: filename, cfg: conf.NewConfigFile()}
@@ -31,6 +36,9 @@ func Open(filename string) (*Config, error) {
 
 // Save configurations to file.
 func (c *Config) Save() (err error) {
+	if c.readonly {
+		return ErrReadOnly
+	}
 	if err := os.MkdirAll(filepath.Dir(c.filename), 0750); err != nil {
 		return err
 	}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testYAML = `
+domain: example.com
+apiserver:
+  listen: [":6616", ":6617"]
+auth:
+  provider: ldap
+  ldap:
+    url: ldap://localhost
+quota:
+  applications: 5
+`
+
+func setupRoot(t *testing.T, yml string) func() {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(dir, "conf"), 0755)
+	writeYAML(t, dir, yml)
+
+	oldRoot := RootDir
+	RootDir = dir
+	c, err := openGlobal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	global = c
+	return func() {
+		RootDir, global = oldRoot, nil
+		os.RemoveAll(dir)
+	}
+}
+
+func writeYAML(t *testing.T, root, yml string) {
+	err := ioutil.WriteFile(filepath.Join(root, "conf", "cloudway.yml"), []byte(yml), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	defer setupRoot(t, testYAML)()
+
+	tests := map[string]string{
+		"domain":             "example.com",
+		"apiserver.listen":   ":6616,:6617",
+		"auth.provider":      "ldap",
+		"auth.ldap.url":      "ldap://localhost",
+		"quota.applications": "5",
+	}
+	for key, want := range tests {
+		if got := Get(key); got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+	if err := Save(); err != ErrReadOnly {
+		t.Errorf("expected read only error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer setupRoot(t, `
+apiserver:
+  listen: localhost
+  tls_cert: server.crt
+quota:
+  applications: many
+proxy:
+  url: http://proxy:6616
+network:
+  subnet: 10.0.0.0
+  gateway: 10.0.0.1
+`)()
+
+	err, ok := Validate().(ValidationError)
+	if !ok || len(err) != 4 {
+		t.Fatalf("expected 4 validation errors, got %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	defer setupRoot(t, testYAML)()
+
+	reloaded := 0
+	OnReload(func() { reloaded++ })
+
+	writeYAML(t, RootDir, `
+domain: example.org
+auth:
+  provider: ldap
+quota:
+  applications: 10
+`)
+	restart, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded != 1 {
+		t.Errorf("expected reload hook to be called once, got %d", reloaded)
+	}
+	if got := Get("quota.applications"); got != "10" {
+		t.Errorf("expected reloaded quota.applications, got %q", got)
+	}
+	if got := Get("domain"); got != "example.com" {
+		t.Errorf("expected domain to be retained, got %q", got)
+	}
+	want := []string{"apiserver.listen", "auth.ldap.url", "domain"}
+	if !reflect.DeepEqual(restart, want) {
+		t.Errorf("expected restart keys %v, got %v", want, restart)
+	}
+
+	// invalid configuration is not applied
+	writeYAML(t, RootDir, "quota:\n  applications: many\n")
+	if _, err = Reload(); err == nil {
+		t.Error("expected validation error")
+	}
+	if got := Get("quota.applications"); got != "10" {
+		t.Errorf("expected quota.applications to be retained, got %q", got)
+	}
+}
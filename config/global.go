@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // The root directory of cloudway installation.
@@ -14,8 +15,11 @@ var RootDir string
 // The global debug flag
 var Debug bool
 
-// The global configuration file
-var global *Config
+// The global configuration file, replaced on reload
+var (
+	mu     sync.RWMutex
+	global *Config
+)
 
 // Error is paniked if the global configuration was not initialized.
 var ErrNotInitialized = errors.New("the configuration was not initialized")
@@ -33,14 +37,40 @@ func Initialize() (err error) {
 	}
 
 	// Load configuration file
-	filename := filepath.Join(RootDir, "conf", "cloudway.conf")
-	global, err = Open(filename)
-	if err != nil && !os.IsNotExist(err) { // Use defaults if configuration file is missing
+	c, err := openGlobal()
+	if err != nil {
 		return err
 	}
+	mu.Lock()
+	global = c
+	mu.Unlock()
 	return nil
 }
 
+// openGlobal opens the structured configuration file conf/cloudway.yml if
+// exists, otherwise the conf/cloudway.conf file is opened. Use defaults if
+// configuration file is missing.
+func openGlobal() (*Config, error) {
+	filename := filepath.Join(RootDir, "conf", "cloudway.yml")
+	if _, err := os.Stat(filename); err == nil {
+		return OpenYAML(filename)
+	}
+
+	filename = filepath.Join(RootDir, "conf", "cloudway.conf")
+	c, err := Open(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// current returns the current global configuration.
+func current() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return global
+}
+
 // Initialize the client configuration file.
 func InitializeClient() (err error) {
 	home := os.Getenv("HOME")
@@ -52,19 +82,23 @@ func InitializeClient() (err error) {
 	}
 
 	filename := filepath.Join(home, ".cloudway")
-	global, err = Open(filename)
+	c, err := Open(filename)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	mu.Lock()
+	global = c
+	mu.Unlock()
 	return nil
 }
 
 // Save global configurations to file.
 func Save() (err error) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		return ErrNotInitialized
 	}
-	return global.Save()
+	return c.Save()
 }
 
 // Get a configuration value as string
@@ -75,6 +109,12 @@ func Get(key string) string {
 // GetOrDefault get a configuration value, if no such value configured then
 // the default value is returned.
 func GetOrDefault(key, deflt string) string {
+	return lookup(current(), key, deflt)
+}
+
+// lookup get a configuration value from environment, which overrides the
+// value in the configuration file.
+func lookup(c *Config, key, deflt string) string {
 	// get value from environment
 	envKey := "CLOUDWAY_" + strings.ToUpper(key)
 	envKey = strings.Replace(envKey, "-", "_", -1)
@@ -84,8 +124,8 @@ func GetOrDefault(key, deflt string) string {
 	}
 
 	// get value from configuration file
-	if global != nil {
-		return global.GetOrDefault(key, deflt)
+	if c != nil {
+		return c.GetOrDefault(key, deflt)
 	}
 
 	// return the default value
@@ -94,64 +134,72 @@ func GetOrDefault(key, deflt string) string {
 
 // Set a configuration of the given key to the given value.
 func Set(key, value string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.Set(key, value)
+	c.Set(key, value)
 }
 
 // Remove a key from configuration.
 func Remove(key string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.Remove(key)
+	c.Remove(key)
 }
 
 // GetSections returns the list of sections in the configuration.
 func GetSections() []string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetSections()
+	return c.GetSections()
 }
 
 // GetSection get section in the configuration file.
 func GetSection(section string) map[string]string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetSection(section)
+	return c.GetSection(section)
 }
 
 // RemoveSection remove a section from configuration.
 func RemoveSection(section string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.RemoveSection(section)
+	c.RemoveSection(section)
 }
 
 // GetFrom get a configuration value from the given section.
 func GetOption(section, key string) string {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	return global.GetOption(section, key)
+	return c.GetOption(section, key)
 }
 
 // AddOption add a configuration value into the given section.
 func AddOption(section, key, value string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.AddOption(section, key, value)
+	c.AddOption(section, key, value)
 }
 
 // RemoveOption removes a configuration value from the given section.
 func RemoveOption(section, key string) {
-	if global == nil {
+	c := current()
+	if c == nil {
 		panic(ErrNotInitialized)
 	}
-	global.RemoveOption(section, key)
+	c.RemoveOption(section, key)
 }
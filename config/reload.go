@@ -0,0 +1,108 @@
+package config
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cloudway/platform/pkg/conf"
+)
+
+// The sections that take effect when the configuration is reloaded. Other
+// sections configure long-lived services, such as listeners, database
+// connections and SCM, which are initialized on startup and require a
+// restart to change.
+var reloadableSections = map[string]bool{
+	"register":  true,
+	"password":  true,
+	"twofactor": true,
+	"spec":      true,
+	"proxy":     true,
+	"quota":     true,
+	"ratelimit": true,
+	"domain":    true,
+}
+
+var reloadHooks struct {
+	sync.Mutex
+	fns []func()
+}
+
+// OnReload registers a function to be called after the global configuration
+// is reloaded, to apply configurations that are cached by services.
+func OnReload(fn func()) {
+	reloadHooks.Lock()
+	reloadHooks.fns = append(reloadHooks.fns, fn)
+	reloadHooks.Unlock()
+}
+
+// Reload re-reads the global configuration file and replaces reloadable
+// sections. The configuration file is validated before taking effect, the
+// current configuration is retained if it's invalid. Returns changed keys
+// that require a restart to take effect.
+func Reload() (restart []string, err error) {
+	c, err := openGlobal()
+	if err != nil {
+		return nil, err
+	}
+	if err = validate(func(key string) string { return lookup(c, key, "") }); err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	old := global
+	if old != nil {
+		restart = retain(c, old)
+	}
+	global = c
+	mu.Unlock()
+
+	reloadHooks.Lock()
+	fns := reloadHooks.fns
+	reloadHooks.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return restart, nil
+}
+
+// retain restores non-reloadable sections of the new configuration from the
+// old configuration, and returns keys that are changed in these sections.
+func retain(c, old *Config) (changed []string) {
+	sections := make(map[string]bool)
+	for _, s := range c.GetSections() {
+		sections[s] = true
+	}
+	for _, s := range old.GetSections() {
+		sections[s] = true
+	}
+
+	for section := range sections {
+		if reloadableSections[section] {
+			continue
+		}
+
+		newOpts, oldOpts := c.GetSection(section), old.GetSection(section)
+		for _, key := range sortedKeys(newOpts) {
+			if v, ok := oldOpts[key]; !ok || v != newOpts[key] {
+				changed = append(changed, qualify(section, key))
+			}
+			c.RemoveOption(section, key)
+		}
+		for _, key := range sortedKeys(oldOpts) {
+			if _, ok := newOpts[key]; !ok {
+				changed = append(changed, qualify(section, key))
+			}
+			c.AddOption(section, key, oldOpts[key])
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// qualify returns the dotted key of an option in a section.
+func qualify(section, key string) string {
+	if section == conf.DefaultSection {
+		return key
+	}
+	return section + "." + key
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// A validator checks the syntax of a configuration value.
+type validator func(value string) error
+
+// The known configurations with values to be validated. Values of other
+// configurations are checked when they are used.
+var schema = map[string]validator{
+	"apiserver.listen":        isAddrList,
+	"apiserver.http_redirect": isAddrList,
+	"apiserver.drain_timeout": isDuration,
+
+	"auth.ldap.url":            isURL,
+	"auth.ldap.starttls":       isBool,
+	"auth.oauth2.token_url":    isURL,
+	"auth.oauth2.userinfo_url": isURL,
+
+	"scm.url":        isURL,
+	"scm.github.api": isURL,
+	"scm.gitlab.url": isURL,
+
+	"console.url":          isURL,
+	"proxy.url":            isURL,
+	"password.reset_url":   isURL,
+	"register.confirm_url": isURL,
+	"register.enabled":     isBool,
+	"register.approval":    isBool,
+	"domain.skip_verify":   isBool,
+	"acme.directory":       isURL,
+	"acme.backend":         isURL,
+	"spec.ui_url":          isURL,
+
+	"ratelimit.trust_proxy": isBool,
+	"ratelimit.per_ip":      isInt,
+	"ratelimit.per_user":    isInt,
+	"ratelimit.auth":        isInt,
+
+	"quota.applications": isInt,
+	"quota.containers":   isInt,
+	"quota.cpus":         isNumber,
+	"quota.memory":       isSize,
+
+	"container.cpus":       isNumber,
+	"container.memory":     isSize,
+	"container.pids-limit": isInt,
+
+	"log.max-size": isSize,
+	"log.max-file": isInt,
+
+	"logstore.url":       isURL,
+	"logstore.retention": isDuration,
+
+	"network.isolation": isBool,
+	"network.ipv6":      isBool,
+	"network.subnet":    isCIDR,
+	"network.subnet6":   isCIDR,
+	"network.gateway":   isIP,
+
+	"discovery.listen":   isAddrList,
+	"discovery.dns":      isIP,
+	"discovery.upstream": isAddrList,
+
+	"hub.sync_interval": isDuration,
+	"hub.registry.url":  isURL,
+
+	"scanner.timeout":      isDuration,
+	"scanner.trivy.server": isURL,
+
+	"deploy.concurrency":   isInt,
+	"deploy.history.keep":  isInt,
+	"deploy.lock_timeout":  isDuration,
+	"deploy.max_repo_size": isSize,
+	"build.log.keep":       isInt,
+	"build.pool.enabled":   isBool,
+	"build.pool.size":      isInt,
+	"build.pool.idle":      isDuration,
+	"cron.log.keep":        isInt,
+	"idle.timeout":         isDuration,
+	"webhook.log.keep":     isInt,
+	"webhook.retries":      isInt,
+	"webhook.timeout":      isDuration,
+	"gc.interval":          isDuration,
+	"gc.grace":             isDuration,
+	"gc.temp_age":          isDuration,
+	"gc.dry_run":           isBool,
+	"smtp.port":            isInt,
+
+	"health.interval":            isDuration,
+	"health.timeout":             isDuration,
+	"health.retries":             isInt,
+	"health.restart.max":         isInt,
+	"health.restart.backoff":     isDuration,
+	"health.restart.max_backoff": isDuration,
+	"health.crashloop.restarts":  isInt,
+	"health.crashloop.window":    isDuration,
+}
+
+// ValidationError reports all invalid configurations.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "Invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+// Validate checks the global configuration, returns a ValidationError
+// listing all invalid configurations.
+func Validate() error {
+	return validate(Get)
+}
+
+// Validate checks the configuration, returns a ValidationError listing all
+// invalid configurations.
+func (c *Config) Validate() error {
+	return validate(c.Get)
+}
+
+func validate(get func(key string) string) error {
+	var errs ValidationError
+	for key, check := range schema {
+		if value := get(key); value != "" {
+			if err := check(value); err != nil {
+				errs = append(errs, fmt.Sprintf("%s = %s: %v", key, value, err))
+			}
+		}
+	}
+	if (get("apiserver.tls_cert") == "") != (get("apiserver.tls_key") == "") {
+		errs = append(errs, "apiserver.tls_cert and apiserver.tls_key must be configured together")
+	}
+	for _, key := range []string{"apiserver.tls_client_ca", "apiserver.http_redirect"} {
+		if get(key) != "" && get("apiserver.tls_cert") == "" {
+			errs = append(errs, key+" requires apiserver.tls_cert")
+		}
+	}
+
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return errs
+	}
+	return nil
+}
+
+func isDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+func isInt(value string) error {
+	_, err := strconv.Atoi(value)
+	return err
+}
+
+func isNumber(value string) error {
+	_, err := strconv.ParseFloat(value, 64)
+	return err
+}
+
+func isBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func isSize(value string) error {
+	_, err := units.RAMInBytes(value)
+	return err
+}
+
+func isURL(value string) error {
+	u, err := url.Parse(value)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = fmt.Errorf("absolute URL required")
+	}
+	return err
+}
+
+func isCIDR(value string) error {
+	_, _, err := net.ParseCIDR(value)
+	return err
+}
+
+func isIP(value string) error {
+	if net.ParseIP(value) == nil {
+		return fmt.Errorf("invalid IP address")
+	}
+	return nil
+}
+
+func isAddrList(value string) error {
+	for _, addr := range strings.Split(value, ",") {
+		if _, _, err := net.SplitHostPort(strings.TrimSpace(addr)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudway/platform/pkg/conf"
+)
+
+// OpenYAML opens a structured configuration file in YAML format. Top level
+// mappings are sections, and nested mappings are flattened to dotted keys,
+// so the following configuration:
+//
+//	apiserver:
+//	  listen: [":6616", ":6617"]
+//	auth:
+//	  ldap:
+//	    url: ldap://localhost
+//
+// is the same as the following INI configuration:
+//
+//	[apiserver]
+//	listen = :6616,:6617
+//
+//	[auth]
+//	ldap.url = ldap://localhost
+//
+// The YAML configuration file is read only, configurations are not saved
+// back to the file.
+func OpenYAML(filename string) (*Config, error) {
+	c := &Config{filename: filename, readonly: true}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+
+	var doc map[string]interface{}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return c, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	c.cfg = conf.NewConfigFile()
+	for key, val := range doc {
+		if m, ok := toMap(val); ok {
+			flatten(c.cfg, key, "", m)
+		} else {
+			c.cfg.AddOption(conf.DefaultSection, key, scalar(val))
+		}
+	}
+	return c, nil
+}
+
+func flatten(cfg *conf.ConfigFile, section, prefix string, m map[string]interface{}) {
+	cfg.AddSection(section)
+	for key, val := range m {
+		if sub, ok := toMap(val); ok {
+			flatten(cfg, section, prefix+key+".", sub)
+		} else {
+			cfg.AddOption(section, prefix+key, scalar(val))
+		}
+	}
+}
+
+// toMap converts a YAML mapping to a map with string keys.
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			result[fmt.Sprint(k)] = v
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// scalar converts a YAML scalar value to string, lists are joined with
+// commas.
+func scalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i := range v {
+			items[i] = scalar(v[i])
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortedKeys returns sorted keys of the map.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -426,7 +426,7 @@ func (con *Console) addHost(w http.ResponseWriter, r *http.Request) {
 
 	name := mux.Vars(r)["name"]
 	host := r.FormValue("hostname")
-	err := con.NewUserBroker(user).AddHost(name, host)
+	_, err := con.NewUserBroker(user).AddDomain(name, host)
 
 	if err != nil {
 		data := con.layoutUserData(w, r, user)
@@ -446,7 +446,7 @@ func (con *Console) removeHost(w http.ResponseWriter, r *http.Request) {
 
 	name := mux.Vars(r)["name"]
 	host := r.FormValue("hostname")
-	err := con.NewUserBroker(user).RemoveHost(name, host)
+	err := con.NewUserBroker(user).RemoveDomain(name, host)
 	if !con.badRequest(w, r, err, "/applications/"+name+"/settings") {
 		http.Redirect(w, r, "/applications/"+name+"/settings", http.StatusFound)
 	}
@@ -587,7 +587,7 @@ func (con *Console) deployApplication(w http.ResponseWriter, r *http.Request) {
 	h := func(conn *websocket.Conn) {
 		jw := jsonWriter{enc: json.NewEncoder(conn)}
 		log := serverlog.Encap(jw, jw)
-		err := con.SCM.Deploy(user.Namespace, name, branch, log)
+		err := con.SCM.Deploy(context.Background(), user.Namespace, name, branch, container.DeployOptions{}, log)
 		if err != nil {
 			data := map[string]string{"err": err.Error()}
 			json.NewEncoder(conn).Encode(data)
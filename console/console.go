@@ -101,7 +101,7 @@ func NewConsole(br *broker.Broker) (con *Console, err error) {
 }
 
 func (con *Console) InitRoutes(s *server.Server) {
-	s.Mux.PathPrefix("/auth/").Handler(con.ab.NewRouter())
+	s.Mux.PathPrefix("/auth/").Handler(con.verifyTwoFactor(con.ab.NewRouter()))
 
 	dist := http.FileServer(http.Dir(filepath.Join(config.RootDir, "dist")))
 	s.Mux.PathPrefix("/dist/").Handler(http.StripPrefix("/dist/", dist))
@@ -176,6 +176,45 @@ func (con *Console) setupAuthboss(br *broker.Broker) error {
 	return ab.Init(modules...)
 }
 
+// verifyTwoFactor requires the two-factor authentication code on login if
+// enabled by the user, since authboss only verifies the password. The code
+// is verified after the password, so a code is never consumed by a login
+// with wrong password, which is then rejected by authboss.
+func (con *Console) verifyTwoFactor(next http.Handler) http.Handler {
+	loginPath := con.ab.MountPath + "/login"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != loginPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := strings.ToLower(r.FormValue(con.ab.PrimaryID))
+		user, err := con.Users.Authenticate(name, r.FormValue("password"))
+		if err == nil {
+			err = con.Users.VerifyTwoFactor(user, r.FormValue("code"))
+			switch err.(type) {
+			case nil:
+			case userdb.TwoFactorRequiredError:
+				con.loginFailed(w, r, "请输入两步验证码")
+				return
+			case userdb.InvalidTwoFactorCodeError:
+				con.loginFailed(w, r, "两步验证码无效")
+				return
+			default:
+				logrus.Error(err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (con *Console) loginFailed(w http.ResponseWriter, r *http.Request, message string) {
+	con.ab.SessionStoreMaker(w, r).Put(authboss.FlashErrorKey, message)
+	http.Redirect(w, r, con.ab.MountPath+"/login", http.StatusFound)
+}
+
 func initMailer() authboss.Mailer {
 	host := config.Get("smtp.host")
 	port := config.Get("smtp.port")
@@ -0,0 +1,221 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+)
+
+// The interval to expire idle builder containers.
+const builderPoolTick = time.Minute
+
+// The builder pool keeps warm builder containers returned by finished builds,
+// so the next build of the application leases a running builder instead of
+// creating a new one. Builders are keyed by the application and the image,
+// since build caches of the application are mounted into builders and the
+// image is rebuilt when the plugin is upgraded.
+//
+// The pool is enabled by build.pool.enabled. At most build.pool.size builders
+// are kept for each key, and builders idle longer than build.pool.idle are
+// removed.
+var builderPool = struct {
+	sync.Mutex
+	m map[string][]*idleBuilder
+}{m: make(map[string][]*idleBuilder)}
+
+type idleBuilder struct {
+	*Container
+	since time.Time
+}
+
+func builderKey(name, namespace, image string) string {
+	return name + "-" + namespace + "/" + image
+}
+
+func builderPoolEnabled() bool {
+	enabled, _ := strconv.ParseBool(config.Get("build.pool.enabled"))
+	return enabled
+}
+
+func builderPoolSize() int {
+	size, err := strconv.Atoi(config.GetOrDefault("build.pool.size", "1"))
+	if err != nil || size < 0 {
+		logrus.Warnf("Invalid build.pool.size configuration: %s", config.Get("build.pool.size"))
+		size = 1
+	}
+	return size
+}
+
+func builderPoolIdle() time.Duration {
+	idle, err := time.ParseDuration(config.GetOrDefault("build.pool.idle", "10m"))
+	if err != nil {
+		logrus.Warnf("Invalid build.pool.idle configuration: %s", config.Get("build.pool.idle"))
+		idle = 10 * time.Minute
+	}
+	return idle
+}
+
+// leaseBuilder takes a running builder from the pool, or returns nil if no
+// builder available. Builders stopped in the pool are removed.
+func leaseBuilder(ctx context.Context, key string) *Container {
+	for {
+		builderPool.Lock()
+		idle := builderPool.m[key]
+		if len(idle) == 0 {
+			builderPool.Unlock()
+			return nil
+		}
+		b := idle[len(idle)-1]
+		if len(idle) == 1 {
+			delete(builderPool.m, key)
+		} else {
+			builderPool.m[key] = idle[:len(idle)-1]
+		}
+		builderPool.Unlock()
+
+		info, err := b.ContainerInspect(ctx, b.ID)
+		if err == nil && info.State != nil && info.State.Running {
+			b.ContainerJSON = &info
+			return b.Container
+		}
+		removeBuilder(b.Container)
+	}
+}
+
+// returnBuilder sanitizes the builder and puts it back to the pool. Returns
+// false if the builder can't be reused and should be removed.
+func returnBuilder(key string, builder *Container) bool {
+	if !builderPoolEnabled() {
+		return false
+	}
+
+	builderPool.Lock()
+	full := len(builderPool.m[key]) >= builderPoolSize()
+	builderPool.Unlock()
+	if full {
+		return false
+	}
+
+	if err := sanitizeBuilder(builder); err != nil {
+		logrus.WithError(err).Warnf("Failed to sanitize builder %s", builder.ID[:12])
+		return false
+	}
+
+	builderPool.Lock()
+	defer builderPool.Unlock()
+	if len(builderPool.m[key]) >= builderPoolSize() {
+		return false
+	}
+	builderPool.m[key] = append(builderPool.m[key], &idleBuilder{builder, time.Now()})
+	return true
+}
+
+// sanitizeBuilder removes the repository built by the previous build, so
+// the next build starts with a clean repository. Build caches are kept in
+// volumes.
+func sanitizeBuilder(builder *Container) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	script := fmt.Sprintf("rm -rf %[1]s %[2]s && mkdir -p %[1]s %[2]s && chown %[3]s:%[3]s %[1]s %[2]s",
+		builder.RepoDir(), builder.DeployDir(), builder.User())
+	return builder.ExecQ(ctx, "root", "/bin/sh", "-c", script)
+}
+
+func removeBuilder(builder *Container) {
+	rmopts := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	builder.ContainerRemove(context.Background(), builder.ID, rmopts)
+}
+
+// removeIdleBuilders removes builders of the application from the pool,
+// which must be done before removing build cache volumes mounted into
+// the builders.
+func removeIdleBuilders(name, namespace string) {
+	removeIdle(func(b *idleBuilder) bool {
+		return b.Config.Labels[BUILDER_KEY] == name+"-"+namespace
+	})
+}
+
+// removeIdle removes builders matching the predicate from the pool.
+func removeIdle(match func(*idleBuilder) bool) {
+	var removed []*idleBuilder
+
+	builderPool.Lock()
+	for key, idle := range builderPool.m {
+		kept := idle[:0]
+		for _, b := range idle {
+			if match(b) {
+				removed = append(removed, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == 0 {
+			delete(builderPool.m, key)
+		} else {
+			builderPool.m[key] = kept
+		}
+	}
+	builderPool.Unlock()
+
+	for _, b := range removed {
+		removeBuilder(b.Container)
+	}
+}
+
+// RunBuilderPool removes builders left by previous runs, and expires idle
+// builders until the stop channel is closed. All idle builders are removed
+// when stopped.
+func (cli DockerClient) RunBuilderPool(stop <-chan bool) {
+	cli.removeStaleBuilders()
+
+	ticker := time.NewTicker(builderPoolTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			removeIdle(func(*idleBuilder) bool { return true })
+			return
+		case <-ticker.C:
+			now, idle := time.Now(), builderPoolIdle()
+			removeIdle(func(b *idleBuilder) bool {
+				return now.Sub(b.since) > idle
+			})
+		}
+	}
+}
+
+func (cli DockerClient) removeStaleBuilders() {
+	clients := []DockerClient{cli}
+	if len(cli.nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range cli.nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	ctx := context.Background()
+	args := filters.NewArgs()
+	args.Add("label", BUILDER_KEY)
+	for _, c := range clients {
+		list, err := c.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to find stale builders")
+			continue
+		}
+		for _, b := range list {
+			rmopts := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+			if err = c.ContainerRemove(ctx, b.ID, rmopts); err == nil {
+				logrus.Debugf("Removed stale builder %s", b.ID[:12])
+			}
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/client"
 	"github.com/docker/engine-api/types"
 	"github.com/docker/engine-api/types/filters"
 	"golang.org/x/net/context"
@@ -25,6 +27,13 @@ const (
 	FLAGS_KEY           = "com.cloudway.container.flags"
 	SERVICE_NAME_KEY    = "com.cloudway.service.name"
 	SERVICE_DEPENDS_KEY = "com.cloudway.service.depends"
+	VOLUME_NAME_KEY     = "com.cloudway.volume.name"
+	VOLUME_SIZE_KEY     = "com.cloudway.volume.size"
+	BUILD_CACHE_KEY     = "com.cloudway.build.cache"
+	BUILDER_KEY         = "com.cloudway.builder"
+	IMAGE_BASE_KEY      = "com.cloudway.image.base"
+	IMAGE_PLUGIN_KEY    = "com.cloudway.image.plugin"
+	GENERATION_KEY      = "com.cloudway.container.generation"
 )
 
 const (
@@ -122,6 +131,53 @@ func (cli DockerClient) FindService(ctx context.Context, name, namespace, servic
 }
 
 func find(cli DockerClient, ctx context.Context, category manifest.Category, service, name, namespace string) ([]*Container, error) {
+	// an application is always scoped to a namespace
+	if name != "" && namespace == "" {
+		return nil, nil
+	}
+
+	if len(cli.nodes) != 0 {
+		return findInNodes(cli, ctx, category, service, name, namespace)
+	}
+	return findInHost(cli, ctx, category, service, name, namespace)
+}
+
+// findInHost finds containers in the Docker host of the client, ignoring
+// other nodes the client manages.
+func findInHost(cli DockerClient, ctx context.Context, category manifest.Category, service, name, namespace string) ([]*Container, error) {
+	ids, err := indexOf(cli).lookup(cli, ctx, category, service, name, namespace)
+	if err != nil {
+		logrus.WithError(err).Warn("Container index unavailable, listing containers")
+		if ids, err = listContainers(cli, ctx, category, service, name, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	containers := make([]*Container, 0, len(ids))
+	for _, id := range ids {
+		cc, err := cli.Inspect(ctx, id)
+		if client.IsErrContainerNotFound(err) {
+			// removed before the destroy event is indexed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Double check the container is in the requested scope, so an
+		// application never touches containers in other namespaces.
+		if (name != "" && cc.Name != name) || (namespace != "" && cc.Namespace != namespace) {
+			continue
+		}
+		containers = append(containers, cc)
+	}
+	return containers, nil
+}
+
+// listContainers returns IDs of containers matching labels by listing
+// containers on the Docker host, used when the container index is
+// unavailable.
+func listContainers(cli DockerClient, ctx context.Context, category manifest.Category, service, name, namespace string) ([]string, error) {
 	args := filters.NewArgs()
 	if category != "" {
 		args.Add("label", CATEGORY_KEY+"="+string(category))
@@ -146,15 +202,11 @@ func find(cli DockerClient, ctx context.Context, category manifest.Category, ser
 		return nil, err
 	}
 
-	containers := make([]*Container, 0, len(list))
-	for _, c := range list {
-		cc, err := cli.Inspect(ctx, c.ID)
-		if err != nil {
-			return nil, err
-		}
-		containers = append(containers, cc)
+	ids := make([]string, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
 	}
-	return containers, nil
+	return ids, nil
 }
 
 func (c *Container) Version() string {
@@ -174,6 +226,16 @@ func (c *Container) Flags() uint32 {
 	return uint32(flags)
 }
 
+// Generation returns the generation of the container, which is increased
+// when containers are replaced by blue-green deployments or upgrades.
+func (c *Container) Generation() int {
+	gen, _ := strconv.Atoi(c.Config.Labels[GENERATION_KEY])
+	if gen <= 0 {
+		gen = 1 // created before generations are labeled
+	}
+	return gen
+}
+
 func (c *Container) ServiceName() string {
 	return c.Config.Labels[SERVICE_NAME_KEY]
 }
@@ -208,12 +270,36 @@ func (c *Container) IP() (ip string) {
 			ip = net.IPAddress
 		}
 	}
+	if ip == "" {
+		if net := c.NetworkSettings.Networks[c.appNetwork()]; net != nil {
+			ip = net.IPAddress
+		}
+	}
 	if ip == "" {
 		ip = c.NetworkSettings.IPAddress
 	}
 	return ip
 }
 
+// Returns the global IPv6 address of the container, or an empty string if
+// IPv6 networking is not enabled.
+func (c *Container) IPv6() (ip string) {
+	if network := config.Get("network"); network != "" {
+		if net := c.NetworkSettings.Networks[network]; net != nil {
+			ip = net.GlobalIPv6Address
+		}
+	}
+	if ip == "" {
+		if net := c.NetworkSettings.Networks[c.appNetwork()]; net != nil {
+			ip = net.GlobalIPv6Address
+		}
+	}
+	if ip == "" {
+		ip = c.NetworkSettings.GlobalIPv6Address
+	}
+	return ip
+}
+
 // Returns the container's operating system user that running the application.
 func (c *Container) User() string {
 	return c.Config.User
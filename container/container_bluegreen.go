@@ -0,0 +1,197 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The environment variable marks a container is on standby, i.e. it's not
+// serving traffic until switched by blue-green deployment.
+const STANDBY_KEY = ".standby"
+
+// TrafficSwitcher switches traffic from a set of containers to another set
+// of containers atomically.
+type TrafficSwitcher interface {
+	// Remove endpoints of old containers and add endpoints of new containers
+	// in a single atomic operation.
+	SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error
+}
+
+var ErrNoTrafficSwitcher = errors.New("Blue-green deployment requires a configured proxy")
+
+// IsStandby returns true if the container is on standby and should not be
+// added to the proxy.
+func (c *Container) IsStandby(ctx context.Context) bool {
+	v, _ := c.Getenv(ctx, STANDBY_KEY)
+	return v != ""
+}
+
+// Create a parallel set of containers for the application, deploy the
+// repository to new containers, then switch traffic from the old set to the
+// new set and remove the old set. The old set keeps serving traffic if the
+// new set failed to become running.
+func (cli DockerClient) blueGreenDeploy(ctx context.Context, blue []*Container, repodir string, opts DeployOptions, log *serverlog.ServerLog) (err error) {
+	if opts.Switcher == nil {
+		return ErrNoTrafficSwitcher
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = rollingDefaultTimeout
+	}
+
+	base := blue[0]
+	fmt.Fprintf(log, "Creating %d standby containers for %s\n", len(blue), base.Name)
+	green, err := cli.createStandby(ctx, blue, log)
+	defer func() {
+		if err != nil {
+			for _, c := range green {
+				c.Destroy(ctx)
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	// deploy and validate the standby containers
+	for _, c := range green {
+		if err = c.Start(ctx, log); err != nil {
+			return err
+		}
+		if err = deployRepo(ctx, c, repodir, opts.manifest, log); err != nil {
+			return err
+		}
+	}
+	for _, c := range green {
+		if err = waitForRunning(ctx, c, timeout, log); err != nil {
+			return err
+		}
+	}
+
+	// switch traffic to the standby containers
+	var old []string
+	for _, c := range blue {
+		old = append(old, c.ID)
+	}
+	endpoints := make(map[string][]*manifest.Endpoint)
+	for _, c := range green {
+		info, err := c.GetInfo(ctx, "endpoints")
+		if err != nil {
+			return err
+		}
+		if err = c.Setenv(ctx, STANDBY_KEY, ""); err != nil {
+			return err
+		}
+		endpoints[c.ID] = info.Endpoints
+	}
+
+	fmt.Fprintf(log, "Switching traffic to new containers of %s\n", base.Name)
+	if err = opts.Switcher.SwitchEndpoints(old, endpoints); err != nil {
+		return err
+	}
+
+	// tear down the old containers, the deployment succeeded regardless
+	for _, c := range blue {
+		if er := c.Destroy(ctx); er != nil {
+			logrus.WithError(er).Warnf("Failed to remove old container %s", c.ID)
+		}
+	}
+	return nil
+}
+
+// Create standby containers using the same configuration as the blue
+// containers. Each standby container takes over persistent volumes of
+// the blue container it replaces, so the data survive the deployment.
+func (cli DockerClient) createStandby(ctx context.Context, blue []*Container, log *serverlog.ServerLog) (green []*Container, err error) {
+	base := blue[0]
+	plugin, err := readPluginManifestFromContainer(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := cli.FindApplications(ctx, base.Name, base.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range blue {
+		volumes, err := c.VolumeNames(ctx)
+		if err != nil {
+			return green, err
+		}
+
+		opts := replicaOptions(base, plugin, len(existing)+i+1, log)
+		opts.Volumes = volumes
+		opts.Generation++
+
+		cs, err := cli.Create(ctx, opts)
+		green = append(green, cs...)
+		if err != nil {
+			return green, err
+		}
+	}
+
+	// copy environment variables from base container, including hosts
+	for _, c := range green {
+		if err = CopyEnv(ctx, base, c); err != nil {
+			return green, err
+		}
+		if err = c.Setenv(ctx, STANDBY_KEY, "true"); err != nil {
+			return green, err
+		}
+	}
+	return green, nil
+}
+
+// replicaOptions returns options to create containers with the same
+// configuration as the base container.
+func replicaOptions(base *Container, plugin *manifest.Plugin, scaling int, log *serverlog.ServerLog) CreateOptions {
+	return CreateOptions{
+		Name:       base.Name,
+		Namespace:  base.Namespace,
+		Plugin:     plugin,
+		Image:      base.Config.Image,
+		Flags:      base.Flags(),
+		Secret:     base.configEnv("CLOUDWAY_SHARED_SECRET"),
+		Home:       base.Home(),
+		User:       base.User(),
+		TimeZone:   base.configEnv("TZ"),
+		Locale:     base.configEnv("LANG"),
+		Scaling:    scaling,
+		Resources:  base.Resources(),
+		Generation: base.Generation(),
+		Logging:    base.LogConfig(),
+		Networks:   base.GrantedNetworks(),
+		Log:        log,
+	}
+}
+
+// CopyEnv copies all environment variables, including hidden ones,
+// from a container to another container in the same application.
+func CopyEnv(ctx context.Context, from, to *Container) error {
+	r, _, err := from.CopyFromContainer(ctx, from.ID, from.EnvDir()+"/.")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return to.CopyToContainer(ctx, to.ID, to.EnvDir(), r, types.CopyToContainerOptions{})
+}
+
+// Returns the value of environment variable configured on container creation.
+func (c *Container) configEnv(key string) string {
+	for _, e := range c.Config.Env {
+		if strings.HasPrefix(e, key+"=") {
+			return e[len(key)+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package container
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var cacheNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// cacheVolumeName returns the name of the Docker volume holding the build
+// cache directory of the application.
+func cacheVolumeName(name, namespace, cache string) string {
+	return name + "-" + namespace + "-cache-" + cacheNamePattern.ReplaceAllString(cache, "_")
+}
+
+// validCachePath returns false if the build cache path declared in plugin
+// manifest is not a clean relative path in the home directory.
+func validCachePath(cache string) bool {
+	return cache != "" && !path.IsAbs(cache) && path.Clean(cache) == cache &&
+		cache != "." && cache != ".." && !strings.HasPrefix(cache, "../")
+}
+
+// mountBuildCache creates volumes for build cache directories declared in
+// plugin manifest, and returns the volume bindings to be mounted into the
+// builder container. Existing volumes are reused, so caches persist across
+// builds without copying.
+func mountBuildCache(cli DockerClient, ctx context.Context, cfg *createConfig) ([]string, error) {
+	var binds []string
+	for _, cache := range cfg.Plugin.BuildCache {
+		if !validCachePath(cache) {
+			logrus.Warnf("Ignored invalid build cache %q declared in plugin %s", cache, cfg.Plugin.Name)
+			continue
+		}
+
+		req := types.VolumeCreateRequest{
+			Name: cacheVolumeName(cfg.Name, cfg.Namespace, cache),
+			Labels: map[string]string{
+				APP_NAME_KEY:      cfg.Name,
+				APP_NAMESPACE_KEY: cfg.Namespace,
+				BUILD_CACHE_KEY:   cache,
+			},
+		}
+		v, err := cli.VolumeCreate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, v.Name+":"+cfg.Home+"/"+cache)
+	}
+	return binds, nil
+}
+
+// ownBuildCache changes owner of the build cache directories mounted into
+// the builder container to the application user. Volumes are created with
+// root owned mount points.
+func ownBuildCache(ctx context.Context, plugin *manifest.Plugin, builder *Container) {
+	args := []string{"chown", builder.User()}
+	for _, cache := range plugin.BuildCache {
+		if validCachePath(cache) {
+			args = append(args, builder.Home()+"/"+cache)
+		}
+	}
+	if len(args) > 2 {
+		builder.Exec(ctx, "root", nil, nil, nil, args...)
+	}
+}
+
+// RemoveBuildCache removes volumes holding build caches of the application
+// on all Docker hosts. The next build starts with empty caches.
+func (cli DockerClient) RemoveBuildCache(ctx context.Context, name, namespace string) error {
+	if name == "" || namespace == "" {
+		return nil
+	}
+	removeIdleBuilders(name, namespace)
+
+	clients := []DockerClient{cli}
+	if len(cli.nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range cli.nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	args := filters.NewArgs()
+	args.Add("label", BUILD_CACHE_KEY)
+	args.Add("label", APP_NAME_KEY+"="+name)
+	args.Add("label", APP_NAMESPACE_KEY+"="+namespace)
+
+	for _, c := range clients {
+		resp, err := c.VolumeList(ctx, args)
+		if err != nil {
+			return err
+		}
+		for _, v := range resp.Volumes {
+			if err = c.VolumeRemove(ctx, v.Name); err != nil {
+				return err
+			}
+			logrus.Debugf("Removed build cache volume %s", v.Name)
+		}
+	}
+	return nil
+}
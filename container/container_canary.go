@@ -0,0 +1,274 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// The environment variable marks a container is running a canary release.
+// The value is the percentage of traffic routed to canary containers.
+const CANARY_KEY = ".canary"
+
+// The default percentage of containers updated in canary deployment.
+const canaryDefaultPercent = 10
+
+// TrafficSplitter routes a share of traffic to canary containers.
+type TrafficSplitter interface {
+	// Replace endpoints of stable and canary containers so that canary
+	// containers receive the given percentage of traffic, in a single
+	// atomic operation.
+	SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error
+}
+
+var ErrNoTrafficSplitter = errors.New("Canary deployment requires a configured proxy")
+
+// CanaryInProgressError reports that a canary deployment is already in
+// progress and must be promoted or aborted first.
+type CanaryInProgressError string
+
+func (e CanaryInProgressError) Error() string {
+	return fmt.Sprintf("%s: canary deployment in progress, promote or abort it first", string(e))
+}
+
+func (e CanaryInProgressError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// NoCanaryError reports that there is no canary deployment to promote
+// or abort.
+type NoCanaryError string
+
+func (e NoCanaryError) Error() string {
+	return fmt.Sprintf("%s: no canary deployment in progress", string(e))
+}
+
+func (e NoCanaryError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// CanaryScalingError reports that the application has too few containers
+// to keep a stable release while running a canary release.
+type CanaryScalingError string
+
+func (e CanaryScalingError) Error() string {
+	return fmt.Sprintf("%s: canary deployment requires at least 2 containers", string(e))
+}
+
+func (e CanaryScalingError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// IsCanary returns true if the container is running a canary release.
+func (c *Container) IsCanary(ctx context.Context) bool {
+	v, _ := c.Getenv(ctx, CANARY_KEY)
+	return v != ""
+}
+
+// CanaryWeight returns the percentage of traffic routed to canary containers
+// of the application, or 0 if the container is not running a canary release.
+func (c *Container) CanaryWeight(ctx context.Context) int {
+	v, _ := c.Getenv(ctx, CANARY_KEY)
+	weight, _ := strconv.Atoi(v)
+	return weight
+}
+
+// Deploy the repository to a percentage of containers and route a share of
+// traffic to them. Other containers keep running the stable release until
+// the canary deployment is promoted or aborted. The canary containers are
+// restored to the stable release if they failed to become running.
+func canaryDeploy(ctx context.Context, targets []*Container, repodir string, opts DeployOptions, log *serverlog.ServerLog) (err error) {
+	if opts.Splitter == nil {
+		return ErrNoTrafficSplitter
+	}
+
+	name := targets[0].Name
+	for _, c := range targets {
+		if c.IsCanary(ctx) {
+			return CanaryInProgressError(name)
+		}
+	}
+	if len(targets) < 2 {
+		return CanaryScalingError(name)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = rollingDefaultTimeout
+	}
+
+	percent := opts.CanaryPercent
+	if percent <= 0 {
+		percent = canaryDefaultPercent
+	}
+	n := (len(targets)*percent + 99) / 100
+	if n >= len(targets) {
+		n = len(targets) - 1
+	}
+	canary, stable := targets[:n], targets[n:]
+
+	weight := opts.CanaryWeight
+	if weight <= 0 {
+		weight = n * 100 / len(targets)
+	}
+
+	// restore the stable release if the canary release failed
+	defer func() {
+		if err != nil {
+			if er := restoreCanary(ctx, stable[0], canary); er != nil {
+				logrus.WithError(er).Warnf("Failed to restore canary containers of %s", name)
+			}
+		}
+	}()
+
+	fmt.Fprintf(log, "Updating %d canary containers of %s\n", len(canary), name)
+	for _, c := range canary {
+		if err = deployRepo(ctx, c, repodir, opts.manifest, log); err != nil {
+			return err
+		}
+	}
+	for _, c := range canary {
+		if err = waitForRunning(ctx, c, timeout, log); err != nil {
+			return err
+		}
+	}
+	for _, c := range canary {
+		if err = c.Setenv(ctx, CANARY_KEY, strconv.Itoa(weight)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(log, "Routing %d%% of traffic to canary containers of %s\n", weight, name)
+	return splitTraffic(ctx, opts.Splitter, stable, canary, weight)
+}
+
+// PromoteCanary deploys the canary release to all containers of the
+// application and routes traffic evenly among them.
+func (cli DockerClient) PromoteCanary(ctx context.Context, name, namespace string, splitter TrafficSplitter, log *serverlog.ServerLog) error {
+	if splitter == nil {
+		return ErrNoTrafficSplitter
+	}
+	stable, canary, err := cli.findCanary(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Promoting canary release of %s to %d containers\n", name, len(stable))
+	if err = copyRepo(ctx, canary[0], stable, log); err != nil {
+		return err
+	}
+	if err = clearCanary(ctx, canary); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Routing traffic evenly to all containers of %s\n", name)
+	return splitTraffic(ctx, splitter, append(stable, canary...), nil, 0)
+}
+
+// AbortCanary stops routing traffic to canary containers and restores the
+// stable release to them.
+func (cli DockerClient) AbortCanary(ctx context.Context, name, namespace string, splitter TrafficSplitter, log *serverlog.ServerLog) error {
+	if splitter == nil {
+		return ErrNoTrafficSplitter
+	}
+	stable, canary, err := cli.findCanary(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Routing traffic away from canary containers of %s\n", name)
+	if err = splitTraffic(ctx, splitter, stable, canary, 0); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "Restoring stable release to %d canary containers\n", len(canary))
+	if err = restoreCanary(ctx, stable[0], canary); err != nil {
+		return err
+	}
+	return splitTraffic(ctx, splitter, append(stable, canary...), nil, 0)
+}
+
+// findCanary returns stable and canary framework containers of the
+// application.
+func (cli DockerClient) findCanary(ctx context.Context, name, namespace string) (stable, canary []*Container, err error) {
+	containers, err := cli.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range containers {
+		if !c.Category().IsFramework() {
+			continue
+		}
+		if c.IsCanary(ctx) {
+			canary = append(canary, c)
+		} else {
+			stable = append(stable, c)
+		}
+	}
+	if len(canary) == 0 || len(stable) == 0 {
+		return nil, nil, NoCanaryError(name)
+	}
+	return stable, canary, nil
+}
+
+// restoreCanary copies the stable release to canary containers and removes
+// the canary marks.
+func restoreCanary(ctx context.Context, from *Container, canary []*Container) error {
+	if err := copyRepo(ctx, from, canary, nil); err != nil {
+		return err
+	}
+	return clearCanary(ctx, canary)
+}
+
+func clearCanary(ctx context.Context, canary []*Container) error {
+	for _, c := range canary {
+		if err := c.Unsetenv(ctx, CANARY_KEY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRepo deploys the repository of a container to other containers.
+func copyRepo(ctx context.Context, from *Container, to []*Container, log *serverlog.ServerLog) error {
+	repodir, err := snapshotRepo(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repodir)
+	return deployAll(ctx, to, repodir, DeployOptions{}, log)
+}
+
+// splitTraffic collects endpoints of stable and canary containers and
+// routes the given percentage of traffic to canary containers.
+func splitTraffic(ctx context.Context, splitter TrafficSplitter, stable, canary []*Container, weight int) error {
+	stableEndpoints, err := collectEndpoints(ctx, stable)
+	if err != nil {
+		return err
+	}
+	canaryEndpoints, err := collectEndpoints(ctx, canary)
+	if err != nil {
+		return err
+	}
+	return splitter.SplitEndpoints(stableEndpoints, canaryEndpoints, weight)
+}
+
+func collectEndpoints(ctx context.Context, containers []*Container) (map[string][]*manifest.Endpoint, error) {
+	endpoints := make(map[string][]*manifest.Endpoint)
+	for _, c := range containers {
+		info, err := c.GetInfo(ctx, "endpoints")
+		if err != nil {
+			return nil, err
+		}
+		endpoints[c.ID] = info.Endpoints
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,123 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"golang.org/x/net/context"
+)
+
+// DeployCondition specifies the precondition on the health of currently
+// running application that must be satisfied before deployment.
+type DeployCondition int
+
+const (
+	// Deploy regardless of the current application health.
+	DeployAlways DeployCondition = iota
+
+	// Deploy only if the current application is healthy, to avoid compounding
+	// an outage with a risky change.
+	DeployIfHealthy
+
+	// Deploy only if the current application is unhealthy, to recover from
+	// a failure without replacing a healthy version.
+	DeployIfUnhealthy
+)
+
+var conditionString = [...]string{
+	DeployAlways:      "always",
+	DeployIfHealthy:   "healthy",
+	DeployIfUnhealthy: "unhealthy",
+}
+
+func (cond DeployCondition) String() string {
+	return conditionString[cond]
+}
+
+type InvalidDeployConditionError string
+
+func (e InvalidDeployConditionError) Error() string {
+	return fmt.Sprintf("Invalid deploy condition: %s", string(e))
+}
+
+func (e InvalidDeployConditionError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseDeployCondition parses the deploy condition from string. An empty
+// string is parsed as DeployAlways.
+func ParseDeployCondition(s string) (DeployCondition, error) {
+	if s == "" {
+		return DeployAlways, nil
+	}
+	for cond, str := range conditionString {
+		if s == str {
+			return DeployCondition(cond), nil
+		}
+	}
+	return DeployAlways, InvalidDeployConditionError(s)
+}
+
+// DeployConditionError reports that a deployment is aborted because the
+// current application state doesn't satisfy the deploy condition.
+type DeployConditionError struct {
+	Name      string
+	Condition DeployCondition
+	State     manifest.ActiveState
+}
+
+func (e DeployConditionError) Error() string {
+	return fmt.Sprintf("%s: deployment aborted, the application is %s but the deploy condition is '%s'",
+		e.Name, e.State, e.Condition)
+}
+
+func (e DeployConditionError) HTTPErrorStatusCode() int {
+	return http.StatusPreconditionFailed
+}
+
+// Check returns an error if the application with given container states
+// doesn't satisfy the deploy condition. The application is healthy only
+// if all containers are running.
+func (cond DeployCondition) Check(name string, states []manifest.ActiveState) error {
+	state := manifest.StateRunning
+	for _, s := range states {
+		if s != manifest.StateRunning {
+			state = s
+			break
+		}
+	}
+
+	healthy := len(states) != 0 && state == manifest.StateRunning
+	if (cond == DeployIfHealthy && !healthy) || (cond == DeployIfUnhealthy && healthy) {
+		if len(states) == 0 {
+			state = manifest.StateUnknown
+		}
+		return DeployConditionError{Name: name, Condition: cond, State: state}
+	}
+	return nil
+}
+
+// CheckDeployCondition checks the active state of framework containers
+// against the deploy condition. The decision to proceed is reported to the
+// log, and the decision to abort is reported by returned error.
+func CheckDeployCondition(ctx context.Context, containers []*Container, cond DeployCondition, log *serverlog.ServerLog) error {
+	if cond == DeployAlways || len(containers) == 0 {
+		return nil
+	}
+
+	var states []manifest.ActiveState
+	for _, c := range containers {
+		if c.Category().IsFramework() {
+			states = append(states, c.ActiveState(ctx))
+		}
+	}
+
+	name := containers[0].Name
+	if err := cond.Check(name, states); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "Deploy condition '%s' satisfied, proceeding with deployment of %s\n", cond, name)
+	return nil
+}
@@ -0,0 +1,78 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Deploy condition", func() {
+	var (
+		healthy   = []manifest.ActiveState{manifest.StateRunning, manifest.StateRunning}
+		unhealthy = []manifest.ActiveState{manifest.StateRunning, manifest.StateFailed}
+		stopped   = []manifest.ActiveState{manifest.StateStopped}
+	)
+
+	It("should parse deploy condition", func() {
+		for _, cond := range []container.DeployCondition{container.DeployAlways, container.DeployIfHealthy, container.DeployIfUnhealthy} {
+			Expect(container.ParseDeployCondition(cond.String())).To(Equal(cond))
+		}
+		Expect(container.ParseDeployCondition("")).To(Equal(container.DeployAlways))
+
+		_, err := container.ParseDeployCondition("sometimes")
+		Expect(err).To(Equal(container.InvalidDeployConditionError("sometimes")))
+	})
+
+	Context("always", func() {
+		cond := container.DeployAlways
+
+		It("should proceed when application is healthy", func() {
+			Expect(cond.Check("test", healthy)).To(Succeed())
+		})
+
+		It("should proceed when application is unhealthy", func() {
+			Expect(cond.Check("test", unhealthy)).To(Succeed())
+			Expect(cond.Check("test", stopped)).To(Succeed())
+		})
+	})
+
+	Context("if healthy", func() {
+		cond := container.DeployIfHealthy
+
+		It("should proceed when application is healthy", func() {
+			Expect(cond.Check("test", healthy)).To(Succeed())
+		})
+
+		It("should abort when application is unhealthy", func() {
+			Expect(cond.Check("test", unhealthy)).To(Equal(container.DeployConditionError{
+				Name: "test", Condition: cond, State: manifest.StateFailed,
+			}))
+			Expect(cond.Check("test", stopped)).To(Equal(container.DeployConditionError{
+				Name: "test", Condition: cond, State: manifest.StateStopped,
+			}))
+		})
+
+		It("should abort when application state is unknown", func() {
+			Expect(cond.Check("test", nil)).To(Equal(container.DeployConditionError{
+				Name: "test", Condition: cond, State: manifest.StateUnknown,
+			}))
+		})
+	})
+
+	Context("if unhealthy", func() {
+		cond := container.DeployIfUnhealthy
+
+		It("should abort when application is healthy", func() {
+			Expect(cond.Check("test", healthy)).To(Equal(container.DeployConditionError{
+				Name: "test", Condition: cond, State: manifest.StateRunning,
+			}))
+		})
+
+		It("should proceed when application is unhealthy", func() {
+			Expect(cond.Check("test", unhealthy)).To(Succeed())
+			Expect(cond.Check("test", stopped)).To(Succeed())
+		})
+	})
+})
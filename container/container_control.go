@@ -37,6 +37,13 @@ func (c *Container) Stop(ctx context.Context) error {
 }
 
 func startSandbox(ctx context.Context, c *Container, log *serverlog.ServerLog) error {
+	if err := linkServices(ctx, c); err != nil {
+		logrus.WithError(err).Error("Failed to link services")
+	}
+	if err := injectProvidedSecrets(ctx, c); err != nil {
+		return err
+	}
+
 	err := c.Exec(ctx, "", nil, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "start")
 	if err != nil {
 		return err
@@ -55,8 +62,18 @@ func distributeEnv(ctx context.Context, c *Container, env map[string]string) err
 		return nil
 	}
 
-	// Create an archive that contains all exported environment files
+	// Create an archive that contains all exported environment files,
+	// framework containers also receive connection variables
 	envfile := createEnvFile(env)
+	linkfile := envfile
+	if link := c.linkEnv(env); len(link) != 0 {
+		for k, v := range env {
+			if _, exists := link[k]; !exists {
+				link[k] = v
+			}
+		}
+		linkfile = createEnvFile(link)
+	}
 
 	// Write environments to all containers in the application
 	cs, err := c.FindAll(ctx, c.Name, c.Namespace)
@@ -67,7 +84,11 @@ func distributeEnv(ctx context.Context, c *Container, env map[string]string) err
 	opt := types.CopyToContainerOptions{}
 	for _, cc := range cs {
 		if cc.ID != c.ID {
-			err := cc.CopyToContainer(ctx, cc.ID, cc.EnvDir(), bytes.NewReader(envfile), opt)
+			data := envfile
+			if cc.Category().IsFramework() {
+				data = linkfile
+			}
+			err := cc.CopyToContainer(ctx, cc.ID, cc.EnvDir(), bytes.NewReader(data), opt)
 			if err != nil {
 				logrus.Error(err)
 			}
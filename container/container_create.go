@@ -25,6 +25,7 @@ import (
 	"github.com/cloudway/platform/api"
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/config/defaults"
+	"github.com/cloudway/platform/metrics"
 	"github.com/cloudway/platform/pkg/archive"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/serverlog"
@@ -45,8 +46,35 @@ type CreateOptions struct {
 	Scaling     int
 	Hosts       []string
 	Env         map[string]string
+	TimeZone    string
+	Locale      string
 	Repo        string
 	Log         *serverlog.ServerLog
+
+	// Resource limits of the container, overriding the resources declared
+	// in plugin manifest.
+	Resources Resources
+
+	// Log driver and options of the container, the default configuration
+	// is used if the driver is empty.
+	Logging LogConfig
+
+	// Existing volumes mounted instead of provisioning new volumes, keyed
+	// by the volume name declared in plugin manifest.
+	Volumes map[string]string
+
+	// Other applications in the namespace whose networks the framework
+	// containers are connected to, if network isolation is enabled.
+	Networks []string
+
+	// The generation of containers, increased when containers are replaced
+	// by blue-green deployments or upgrades. Zero for the generation of
+	// existing application containers, or the first generation.
+	Generation int
+
+	// Create a service container replacing an existing one of the same
+	// service name, which is removed once the upgrade completed.
+	upgrade bool
 }
 
 type createConfig struct {
@@ -64,16 +92,31 @@ type createConfig struct {
 
 // Create new application containers.
 func (cli DockerClient) Create(ctx context.Context, opts CreateOptions) ([]*Container, error) {
+	if err := validateOptions(&opts); err != nil {
+		return nil, err
+	}
+
 	cfg := configure(&opts)
 
+	// place new containers on the Docker host with the most spare capacity
+	cli, err := cli.place(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []*Container
+
 	switch cfg.Category {
 	case manifest.Framework:
-		return createApplicationContainer(cli, ctx, cfg)
+		containers, err = createApplicationContainer(cli, ctx, cfg)
 	case manifest.Service:
-		return createServiceContainer(cli, ctx, cfg)
+		containers, err = createServiceContainer(cli, ctx, cfg)
 	default:
 		return nil, fmt.Errorf("%s:%s is not a valid plugin", cfg.Plugin.Name, cfg.Plugin.Version)
 	}
+
+	metrics.ContainersCreated.WithLabelValues(string(cfg.Category)).Add(float64(len(containers)))
+	return containers, err
 }
 
 func configure(opts *CreateOptions) *createConfig {
@@ -98,6 +141,7 @@ func configure(opts *CreateOptions) *createConfig {
 	if cfg.Network == "" {
 		cfg.Network = config.Get("network")
 	}
+	cfg.Resources = PluginResources(meta).Merge(opts.Resources)
 
 	cfg.Category = meta.Category
 	cfg.PluginInstallPath = meta.Name + "-" + meta.Version
@@ -113,6 +157,7 @@ func configure(opts *CreateOptions) *createConfig {
 	cfg.Env["CLOUDWAY_REPO_DIR"] = cfg.Home + "/repo"
 	cfg.Env["CLOUDWAY_DATA_DIR"] = cfg.Home + "/data"
 	cfg.Env["CLOUDWAY_LOG_DIR"] = cfg.Home + "/logs"
+	configureLocale(cfg)
 
 	// passthrough plugin specific environment variables from broker
 	prefix := "CLOUDWAY_PLUGIN_" + strings.ToUpper(cfg.Plugin.Name) + "_"
@@ -135,8 +180,32 @@ func configure(opts *CreateOptions) *createConfig {
 	return cfg
 }
 
+func validateLocale(opts *CreateOptions) error {
+	if err := ValidateTimeZone(opts.TimeZone); err != nil {
+		return err
+	}
+	return ValidateLocale(opts.Locale)
+}
+
+func validateOptions(opts *CreateOptions) error {
+	if err := validateLocale(opts); err != nil {
+		return err
+	}
+	if err := ValidateResources(opts.Plugin); err != nil {
+		return err
+	}
+	if err := opts.Logging.Validate(); err != nil {
+		return err
+	}
+	return ValidateVolumes(opts.Plugin)
+}
+
 // Create a builder container.
 func (cli DockerClient) CreateBuilder(ctx context.Context, opts CreateOptions) (c *Container, err error) {
+	if err = validateLocale(&opts); err != nil {
+		return nil, err
+	}
+
 	cfg := configure(&opts)
 
 	cfg.Hostname = cfg.Name + "-" + cfg.Namespace
@@ -160,15 +229,20 @@ func createApplicationContainer(cli DockerClient, ctx context.Context, cfg *crea
 	cfg.FQDN = cfg.Hostname + "." + defaults.Domain()
 	cfg.Env["CLOUDWAY_APP_DNS"] = cfg.FQDN
 
-	if _, e := os.Stat(filepath.Join(cfg.Plugin.Path, "bin", "build")); os.IsNotExist(e) {
-		// If the framework has no build script, the application ca be hot deployed
-		cfg.Flags |= HotDeployable
+	if cfg.Plugin.Path != "" {
+		if _, e := os.Stat(filepath.Join(cfg.Plugin.Path, "bin", "build")); os.IsNotExist(e) {
+			// If the framework has no build script, the application ca be hot deployed
+			cfg.Flags |= HotDeployable
+		}
 	}
 
-	scale, err := getScaling(cli, ctx, cfg.Name, cfg.Namespace, cfg.Scaling)
+	scale, generation, err := getScaling(cli, ctx, cfg.Name, cfg.Namespace, cfg.Scaling)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Generation <= 0 {
+		cfg.Generation = generation
+	}
 
 	err = buildImage(cli, ctx, dockerfileTemplate, cfg)
 	if err != nil {
@@ -187,23 +261,31 @@ func createApplicationContainer(cli DockerClient, ctx context.Context, cfg *crea
 	return containers, err
 }
 
-func getScaling(cli DockerClient, ctx context.Context, name, namespace string, scale int) (int, error) {
+// getScaling returns the number of application containers to create, and
+// the latest generation of existing containers.
+func getScaling(cli DockerClient, ctx context.Context, name, namespace string, scale int) (int, int, error) {
 	if scale <= 0 {
-		return 0, fmt.Errorf("Invalid scaling value, it must be greater than 0")
+		return 0, 0, fmt.Errorf("Invalid scaling value, it must be greater than 0")
 	}
 
 	cs, err := cli.FindApplications(ctx, name, namespace)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	n := len(cs)
 	if scale <= n {
-		return 0, fmt.Errorf("Application containers already reached maximum scaling value. "+
+		return 0, 0, fmt.Errorf("Application containers already reached maximum scaling value. "+
 			"(maximum scaling = %d, existing containers = %d", scale, n)
 	}
 
-	return scale - n, nil
+	generation := 1
+	for _, c := range cs {
+		if c.Generation() > generation {
+			generation = c.Generation()
+		}
+	}
+	return scale - n, generation, nil
 }
 
 type serviceExistsError struct {
@@ -233,7 +315,7 @@ func createServiceContainer(cli DockerClient, ctx context.Context, cfg *createCo
 	if err != nil {
 		return nil, err
 	}
-	if len(cs) != 0 {
+	if len(cs) != 0 && !cfg.upgrade {
 		return nil, serviceExistsError{service: service, app: name}
 	}
 
@@ -380,6 +462,11 @@ func addPluginFiles(tw *tar.Writer, dst, path string) error {
 }
 
 func createContainer(cli DockerClient, ctx context.Context, cfg *createConfig) (*Container, error) {
+	generation := cfg.Generation
+	if generation <= 0 {
+		generation = 1
+	}
+
 	config := &container.Config{
 		Labels: map[string]string{
 			VERSION_KEY:       api.Version,
@@ -389,6 +476,7 @@ func createContainer(cli DockerClient, ctx context.Context, cfg *createConfig) (
 			APP_NAME_KEY:      cfg.Name,
 			APP_NAMESPACE_KEY: cfg.Namespace,
 			APP_HOME_KEY:      cfg.Home,
+			GENERATION_KEY:    strconv.Itoa(generation),
 		},
 
 		Image:      cfg.Image,
@@ -405,13 +493,40 @@ func createContainer(cli DockerClient, ctx context.Context, cfg *createConfig) (
 		config.Labels[SERVICE_DEPENDS_KEY] = strings.Join(cfg.DependsOn, ",")
 	}
 
-	hostConfig := &container.HostConfig{}
+	restart, err := restartPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	hostConfig := &container.HostConfig{RestartPolicy: restart}
 	netConfig := &network.NetworkingConfig{}
 
+	if err = cli.ensureNetwork(ctx, cfg.Network); err != nil {
+		return nil, err
+	}
 	if cfg.Network != "" {
 		hostConfig.NetworkMode = container.NetworkMode(cfg.Network)
 	}
 
+	// service containers are only connected to the application network,
+	// framework containers are connected to it after creation
+	var appNet string
+	if NetworkIsolation() {
+		if appNet, err = cli.createAppNetwork(ctx, cfg.Name, cfg.Namespace); err != nil {
+			return nil, err
+		}
+		if cfg.Category.IsService() {
+			hostConfig.NetworkMode = container.NetworkMode(appNet)
+		}
+	}
+	setResources(&hostConfig.Resources, cfg.Resources)
+	setLogConfig(hostConfig, cfg.Logging)
+	setDNS(hostConfig, cfg)
+
+	if cfg.Category.IsFramework() {
+		hostConfig.Tmpfs = map[string]string{SecretsDir: "mode=0755"}
+	}
+
 	var baseName = cfg.Name + "-" + cfg.Namespace + "-"
 	if cfg.ServiceName != "" {
 		baseName = cfg.ServiceName + "." + baseName
@@ -426,15 +541,32 @@ func createContainer(cli DockerClient, ctx context.Context, cfg *createConfig) (
 		}
 	}
 
+	binds, err := createVolumes(cli, ctx, cfg, containerName)
+	if err != nil {
+		logrus.WithError(err).Error("failed to create volumes")
+		return nil, err
+	}
+	hostConfig.Binds = binds
+
 	resp, err := cli.ContainerCreate(ctx, config, hostConfig, netConfig, containerName)
 	if err != nil {
 		logrus.WithError(err).Error("failed to create container")
+		removeCreatedVolumes(cli, ctx, cfg, binds)
 		return nil, err
 	}
+	if appNet != "" && cfg.Category.IsFramework() {
+		if err = cli.connectNetworks(ctx, resp.ID, cfg, appNet); err != nil {
+			logrus.WithError(err).Error("failed to connect networks")
+			cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+			removeCreatedVolumes(cli, ctx, cfg, binds)
+			return nil, err
+		}
+	}
 	c, err := cli.Inspect(ctx, resp.ID)
 	if err != nil {
 		return nil, err
 	}
+	indexCreated(c)
 
 	if len(cfg.Hosts) != 0 {
 		hosts := cfg.Hosts
@@ -456,11 +588,20 @@ func createBuilderContainer(cli DockerClient, ctx context.Context, cfg *createCo
 		Image:      cfg.Image,
 		User:       cfg.User,
 		Entrypoint: strslice.StrSlice{"/usr/bin/cwctl", "run"},
+		Labels:     map[string]string{BUILDER_KEY: cfg.Name + "-" + cfg.Namespace},
 	}
 
-	hostConfig := &container.HostConfig{}
+	binds, err := mountBuildCache(cli, ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hostConfig := &container.HostConfig{Binds: binds}
 	netConfig := &network.NetworkingConfig{}
 
+	if err = cli.ensureNetwork(ctx, cfg.Network); err != nil {
+		return nil, err
+	}
 	if cfg.Network != "" {
 		hostConfig.NetworkMode = container.NetworkMode(cfg.Network)
 	}
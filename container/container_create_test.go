@@ -47,6 +47,36 @@ var _ = Describe("Create Container", func() {
 		Expect(containers).To(HaveLen(1))
 	})
 
+	It("should find containers immediately after created and removed", func() {
+		containers, err = dockerCli.Create(ctx, options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0].Generation()).To(Equal(1))
+
+		found, err := dockerCli.FindApplications(ctx, "test", NAMESPACE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(HaveLen(1))
+		Expect(found[0].ID).To(Equal(containers[0].ID))
+
+		Expect(containers[0].Destroy(ctx)).To(Succeed())
+		containers = nil
+		Expect(dockerCli.FindApplications(ctx, "test", NAMESPACE)).To(BeEmpty())
+	})
+
+	It("should inherit generation of existing containers", func() {
+		options.Generation = 3
+		containers, err = dockerCli.Create(ctx, options)
+		Expect(err).NotTo(HaveOccurred())
+
+		options.Generation = 0
+		options.Scaling = 2
+		more, err := dockerCli.Create(ctx, options)
+		containers = append(containers, more...)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(HaveLen(1))
+		Expect(more[0].Generation()).To(Equal(3))
+	})
+
 	It("should fail if no name specified", func() {
 		options.Name = ""
 		containers, err = dockerCli.Create(ctx, options)
@@ -96,6 +126,136 @@ var _ = Describe("Create Container", func() {
 		containers = append(containers, more...)
 	})
 
+	Context("Locale", func() {
+		It("should set time zone and locale in container environment", func() {
+			options.TimeZone = "Asia/Shanghai"
+			options.Locale = "zh_CN.UTF-8"
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+
+			env := containers[0].Config.Env
+			Expect(env).To(ContainElement("TZ=Asia/Shanghai"))
+			Expect(env).To(ContainElement("LANG=zh_CN.UTF-8"))
+			Expect(env).To(ContainElement("LC_ALL=zh_CN.UTF-8"))
+		})
+
+		It("should reject unknown time zone", func() {
+			options.TimeZone = "Mars/Olympus_Mons"
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).To(Equal(container.InvalidTimeZoneError("Mars/Olympus_Mons")))
+		})
+
+		It("should reject malformed locale", func() {
+			options.Locale = "en_US UTF-8"
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).To(Equal(container.InvalidLocaleError("en_US UTF-8")))
+		})
+	})
+
+	Context("Volumes", func() {
+		BeforeEach(func() {
+			service, err := pluginHub.GetPluginInfo("mockdb")
+			Expect(err).NotTo(HaveOccurred())
+
+			withVolumes := *service
+			withVolumes.Volumes = []*manifest.Volume{
+				{Name: "data", MountPath: "/var/lib/mockdb", Size: "1GB"},
+				{Name: "logs", MountPath: "/var/log/mockdb"},
+			}
+			options.Plugin = &withVolumes
+		})
+
+		It("should provision and mount declared volumes", func() {
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+
+			mounts := make(map[string]string)
+			for _, m := range containers[0].Mounts {
+				mounts[m.Destination] = m.Name
+			}
+			Expect(mounts).To(HaveKey("/var/lib/mockdb"))
+			Expect(mounts).To(HaveKey("/var/log/mockdb"))
+
+			v, err := dockerCli.VolumeInspect(ctx, mounts["/var/lib/mockdb"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Labels).To(HaveKeyWithValue(container.APP_NAME_KEY, "test"))
+			Expect(v.Labels).To(HaveKeyWithValue(container.APP_NAMESPACE_KEY, NAMESPACE))
+			Expect(v.Labels).To(HaveKeyWithValue(container.VOLUME_NAME_KEY, "data"))
+			Expect(v.Labels).To(HaveKeyWithValue(container.VOLUME_SIZE_KEY, "1073741824"))
+		})
+
+		It("should remove volumes when container destroyed", func() {
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+
+			c := containers[0]
+			containers = nil
+			Expect(c.Destroy(ctx)).To(Succeed())
+			for _, m := range c.Mounts {
+				_, err := dockerCli.VolumeInspect(ctx, m.Name)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+
+		It("should keep volumes mounted by replacement container", func() {
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+
+			old := containers[0]
+			volumes, err := old.VolumeNames(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(volumes).To(HaveLen(2))
+
+			options.ServiceName = "replica"
+			options.Volumes = volumes
+			replaced, err := dockerCli.Create(ctx, options)
+			containers = append(containers, replaced...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replaced).To(HaveLen(1))
+
+			containers = containers[1:]
+			Expect(old.Destroy(ctx)).To(Succeed())
+
+			list, err := replaced[0].Volumes(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(HaveLen(2))
+			for _, v := range list {
+				Expect(v.VolumeName).To(Equal(volumes[v.Name]))
+				_, err := dockerCli.VolumeInspect(ctx, v.VolumeName)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("should reject invalid volume declarations", func() {
+			for _, vol := range []*manifest.Volume{
+				{Name: "Data!", MountPath: "/data"},
+				{Name: "data", MountPath: "data"},
+				{Name: "data", MountPath: "/data/../etc"},
+				{Name: "data", MountPath: "/"},
+				{Name: "data", MountPath: "/data", Size: "huge"},
+				{Name: "data", MountPath: "/data", Size: "0"},
+			} {
+				options.Plugin.Volumes = []*manifest.Volume{vol}
+				_, err := dockerCli.Create(ctx, options)
+				Expect(err).To(BeAssignableToTypeOf(container.InvalidVolumeError{}), vol.Name+":"+vol.MountPath)
+			}
+		})
+
+		It("should reject duplicate volume declarations", func() {
+			options.Plugin.Volumes = append(options.Plugin.Volumes, &manifest.Volume{Name: "data", MountPath: "/data"})
+			_, err := dockerCli.Create(ctx, options)
+			Expect(err).To(BeAssignableToTypeOf(container.InvalidVolumeError{}))
+
+			options.Plugin.Volumes[2] = &manifest.Volume{Name: "other", MountPath: "/var/log/mockdb"}
+			_, err = dockerCli.Create(ctx, options)
+			Expect(err).To(BeAssignableToTypeOf(container.InvalidVolumeError{}))
+		})
+	})
+
 	Context("Scaling", func() {
 		It("should fail if container exceeding maximum scaling level", func() {
 			containers, err = dockerCli.Create(ctx, options)
@@ -0,0 +1,126 @@
+package container
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/delta"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// scanRepo creates the manifest of the repository archive for delta
+// deployment. Returns nil if the repository can't be described, so the
+// full repository is deployed.
+func scanRepo(repodir string) delta.Manifest {
+	f, err := os.Open(repoArchive(repodir))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil
+	}
+	m, err := delta.ScanArchive(zr)
+	if err != nil {
+		logrus.WithError(err).Warn("Delta deployment disabled")
+		return nil
+	}
+	return m
+}
+
+// deployRepo deploys the repository to the container. Only changed files
+// are transferred if a manifest of the repository is given and the
+// container has a manifest of the previous deployment. Otherwise the full
+// repository is deployed.
+func deployRepo(ctx context.Context, c *Container, repodir string, target delta.Manifest, log *serverlog.ServerLog) error {
+	if target != nil {
+		if ok, err := c.deployDelta(ctx, repodir, target, log); ok || err != nil {
+			return err
+		}
+	}
+	return c.Deploy(ctx, repodir, log)
+}
+
+// deployDelta deploys changed files of the repository to the container.
+// Returns false if the container has no manifest of previous deployment,
+// or the delta is not smaller than the full repository.
+func (c *Container) deployDelta(ctx context.Context, repodir string, target delta.Manifest, log *serverlog.ServerLog) (bool, error) {
+	base, err := c.readRepoManifest(ctx)
+	if err != nil {
+		return false, nil
+	}
+
+	deltadir, err := ioutil.TempDir("", "deploy")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(deltadir)
+
+	deltafile := filepath.Join(deltadir, filepath.Base(deltadir)+".delta.tar.gz")
+	n, err := writeDelta(deltafile, repoArchive(repodir), base, target)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to create delta for %s", c.ServiceName())
+		return false, nil
+	}
+
+	full, err1 := os.Stat(repoArchive(repodir))
+	part, err2 := os.Stat(deltafile)
+	if err1 != nil || err2 != nil || part.Size() >= full.Size() {
+		return false, nil
+	}
+
+	if log != nil {
+		fmt.Fprintf(log, "Copying %d changed files to %s\n", n, c.ServiceName())
+	}
+	return true, c.Deploy(ctx, deltadir, log)
+}
+
+// readRepoManifest reads the manifest of the repository deployed to the
+// container.
+func (c *Container) readRepoManifest(ctx context.Context) (delta.Manifest, error) {
+	r, _, err := c.CopyFromContainer(ctx, c.ID, c.Home()+"/"+delta.ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	if _, err = tr.Next(); err != nil {
+		return nil, err
+	}
+	return delta.ReadManifest(tr)
+}
+
+func writeDelta(deltafile, repofile string, base, target delta.Manifest) (n int, err error) {
+	in, err := os.Open(repofile)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.Create(deltafile)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	if n, err = delta.Write(zw, zr, base, target); err != nil {
+		return n, err
+	}
+	return n, zw.Close()
+}
@@ -3,44 +3,251 @@ package container
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"golang.org/x/net/context"
 	"gopkg.in/yaml.v2"
 
+	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/metrics"
 	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/delta"
 	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/serverlog"
 	"github.com/docker/engine-api/types"
+	"github.com/docker/go-units"
 )
 
-func (c *Container) Deploy(ctx context.Context, path string) error {
+// The interval and threshold of deployment progress reporting. Deployments
+// smaller than the threshold are copied silently.
+var (
+	deployProgressInterval  = 2 * time.Second
+	deployProgressThreshold = int64(16 * 1024 * 1024)
+)
+
+// The suffix of files holding SHA-256 digests of repository archives.
+const digestSuffix = ".sha256"
+
+func (c *Container) Deploy(ctx context.Context, path string, log *serverlog.ServerLog) error {
+	// Digest the repo archive so the transfer can be verified
+	digests, err := repoDigests(path)
+	if err != nil {
+		return err
+	}
+	var excludes []string
+	for name := range digests {
+		excludes = append(excludes, name+digestSuffix)
+	}
+
 	// Create context archive containing the repo archive
 	r, w := io.Pipe()
 	go func() {
 		tw := tar.NewWriter(w)
-		err := archive.CopyFileTree(tw, "", path, nil, false)
+		err := archive.CopyFileTree(tw, "", path, excludes, false)
 		tw.Close()
 		w.CloseWithError(err)
 	}()
 
+	// Report copy progress for large deployments
+	var in io.Reader = r
+	var size int64
+	if log != nil {
+		size = treeSize(path)
+		reportDeploy(log, c, DeployEventCopying, 0, nil)
+		in = archive.NewProgressReader(r, size, deployProgressInterval, deployProgressThreshold,
+			func(p archive.Progress) {
+				reportDeploy(log, c, DeployEventCopying, p.Current, nil)
+				if p.Total > 0 {
+					percent := p.Current * 100 / p.Total
+					if percent > 100 {
+						percent = 100 // tar headers are counted
+					}
+					fmt.Fprintf(log, "Copying repository to %s: %s of %s (%d%%)\n",
+						c.ServiceName(), units.BytesSize(float64(p.Current)), units.BytesSize(float64(p.Total)), percent)
+				} else {
+					fmt.Fprintf(log, "Copying repository to %s: %s\n",
+						c.ServiceName(), units.BytesSize(float64(p.Current)))
+				}
+			})
+	}
+
 	// Copy file to container
-	err := c.CopyToContainer(ctx, c.ID, c.DeployDir(), r, types.CopyToContainerOptions{})
+	err = c.CopyToContainer(ctx, c.ID, c.DeployDir(), in, types.CopyToContainerOptions{})
 	if err != nil {
+		reportDeploy(log, c, DeployEventFailed, 0, err)
 		return err
 	}
+	reportDeploy(log, c, DeployEventCopied, size, nil)
 
-	// Send signal to container to complete the deployment
-	c.ContainerKill(ctx, c.ID, "SIGHUP")
+	// Verify the transfer before restarting onto the repository, the
+	// corrupted archive is removed by the container
+	for name, digest := range digests {
+		if err = c.ExecQ(ctx, "", "/usr/bin/cwctl", "verify", name, digest); err != nil {
+			err = RepoChecksumError{ID: c.ID, Name: name, Err: err}
+			reportDeploy(log, c, DeployEventFailed, 0, err)
+			return err
+		}
+	}
+	reportDeploy(log, c, DeployEventVerified, 0, nil)
+
+	// Notify the container to complete the deployment
+	if err = notifyDeployed(ctx, c); err != nil {
+		err = DeployNotifyError{ID: c.ID, Err: err}
+		reportDeploy(log, c, DeployEventFailed, 0, err)
+		return err
+	}
+	reportDeploy(log, c, DeployEventSignaled, 0, nil)
 	return nil
 }
 
+// RepoChecksumError reports that the repository archive copied to a
+// container doesn't match the digest computed before the transfer.
+type RepoChecksumError struct {
+	ID   string
+	Name string
+	Err  error
+}
+
+func (e RepoChecksumError) Error() string {
+	return fmt.Sprintf("%s: verification of %s failed: %v", shortID(e.ID), e.Name, e.Err)
+}
+
+// DeployNotifyError reports that a container failed to be notified to
+// complete the deployment.
+type DeployNotifyError struct {
+	ID  string
+	Err error
+}
+
+func (e DeployNotifyError) Error() string {
+	return fmt.Sprintf("%s: deploy notification failed: %v", shortID(e.ID), e.Err)
+}
+
+// repoDigests returns SHA-256 digests of archives in the directory, keyed
+// by file names. Digests computed when the archive was prepared are used,
+// otherwise the digest is computed and saved for subsequent deployments.
+func repoDigests(path string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string)
+	for _, fi := range files {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || strings.HasSuffix(name, digestSuffix) {
+			continue
+		}
+
+		file := filepath.Join(path, name)
+		if data, err := ioutil.ReadFile(file + digestSuffix); err == nil {
+			digests[name] = strings.TrimSpace(string(data))
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		digests[name] = hex.EncodeToString(h.Sum(nil))
+		ioutil.WriteFile(file+digestSuffix, []byte(digests[name]), 0644)
+	}
+	return digests, nil
+}
+
+// treeSize returns the total size of regular files in the directory.
+func treeSize(path string) (size int64) {
+	filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// The default maximum size of a repository archive, configured by
+// deploy.max_repo_size.
+const defaultMaxRepoSize = 1 << 30
+
+// MaxRepoSize returns the maximum size in bytes of a repository archive
+// accepted for deployment.
+func MaxRepoSize() int64 {
+	n, err := units.RAMInBytes(config.GetOrDefault("deploy.max_repo_size", strconv.Itoa(defaultMaxRepoSize)))
+	if err != nil || n <= 0 {
+		logrus.Warnf("Invalid deploy.max_repo_size configuration: %s", config.Get("deploy.max_repo_size"))
+		n = defaultMaxRepoSize
+	}
+	return n
+}
+
+// RepoTooLargeError reports that a repository archive exceeds the maximum
+// size accepted for deployment.
+type RepoTooLargeError struct {
+	Limit int64
+}
+
+func (e RepoTooLargeError) Error() string {
+	return fmt.Sprintf("repository archive exceeds the maximum size of %s", units.BytesSize(float64(e.Limit)))
+}
+
+func (e RepoTooLargeError) HTTPErrorStatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+func (e RepoTooLargeError) ErrorCode() string {
+	return "repo_too_large"
+}
+
+func (e RepoTooLargeError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{"Limit": e.Limit}
+}
+
+// repoSizeWriter fails writes once the repository archive exceeds the
+// maximum size, so an oversized archive is rejected before it's spooled
+// entirely to disk.
+type repoSizeWriter struct {
+	w     io.Writer
+	size  int64
+	limit int64
+}
+
+func (w *repoSizeWriter) Write(p []byte) (int, error) {
+	if w.size+int64(len(p)) > w.limit {
+		return 0, RepoTooLargeError{w.limit}
+	}
+	n, err := w.w.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// PrepareRepo saves the repository archive to a temporary directory, and
+// returns the directory which must be removed after use. The content is
+// compressed if zip is true. The archive is digested as it's written, and
+// a RepoTooLargeError is returned once it exceeds the maximum size.
 func PrepareRepo(content io.Reader, zip bool) (repodir string, err error) {
 	// create a temporary directory to hold deployment archive
 	repodir, err = ioutil.TempDir("", "deploy")
@@ -48,27 +255,370 @@ func PrepareRepo(content io.Reader, zip bool) (repodir string, err error) {
 		return "", err
 	}
 
-	// save archive to a temporary file
-	filename := filepath.Join(repodir, filepath.Base(repodir)+".tar.gz")
-	repofile, err := os.Create(filename)
+	// save archive to a temporary file, digesting the archive so the
+	// transfer to containers can be verified
+	repofile, err := os.Create(repoArchive(repodir))
 	if err != nil {
 		return
 	}
 	defer repofile.Close()
 
+	h := sha256.New()
+	out := &repoSizeWriter{w: io.MultiWriter(repofile, h), limit: MaxRepoSize()}
 	if zip {
-		w := gzip.NewWriter(repofile)
+		w := gzip.NewWriter(out)
 		_, err = io.Copy(w, content)
 		if err == nil {
 			err = w.Close()
 		}
 	} else {
-		_, err = io.Copy(repofile, content)
+		_, err = io.Copy(out, content)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(repoArchive(repodir)+digestSuffix, []byte(hex.EncodeToString(h.Sum(nil))), 0644)
 	}
 	return
 }
 
-func (cli DockerClient) DistributeRepo(ctx context.Context, containers []*Container, repo io.Reader, zip bool) error {
+// NoFrameworkError reports that an application has no framework container
+// to deploy to, e.g. the application only contains services.
+type NoFrameworkError string
+
+func (e NoFrameworkError) Error() string {
+	return fmt.Sprintf("%s: no framework container to deploy in the application", string(e))
+}
+
+func (e NoFrameworkError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// DeployCancelledError reports that a deployment is aborted because it's
+// cancelled by the user or the client connection is closed.
+type DeployCancelledError string
+
+func (e DeployCancelledError) Error() string {
+	return fmt.Sprintf("%s: deployment cancelled", string(e))
+}
+
+// DeployOptions controls how a repository is deployed to application containers.
+type DeployOptions struct {
+	// The precondition on the health of current application.
+	Condition DeployCondition
+
+	// The strategy to distribute repository to containers.
+	Strategy DeployStrategy
+
+	// The number of containers updated at a time in rolling deployment,
+	// defaults to 1.
+	BatchSize int
+
+	// The number of failed containers tolerated in rolling deployment
+	// before aborting the deployment.
+	FailureThreshold int
+
+	// The number of times to retry deploying to a container that failed.
+	Retries int
+
+	// How to proceed when the repository failed to deploy to a container
+	// after retries.
+	OnFailure FailurePolicy
+
+	// The maximum time to wait for an updated container becoming running
+	// in rolling or blue-green deployment.
+	Timeout time.Duration
+
+	// The traffic switcher used by blue-green deployment.
+	Switcher TrafficSwitcher
+
+	// The percentage of containers updated in canary deployment,
+	// defaults to 10. At least one container is updated.
+	CanaryPercent int
+
+	// The percentage of traffic routed to canary containers. Defaults
+	// to the proportion of canary containers.
+	CanaryWeight int
+
+	// The traffic splitter used by canary deployment.
+	Splitter TrafficSplitter
+
+	// Only transfer files changed since the previous deployment to each
+	// container. Containers without a manifest of the previous deployment
+	// receive the full repository.
+	Delta bool
+
+	// The manifest of the repository computed for delta deployment.
+	manifest delta.Manifest
+
+	// The function called with the gzipped repository archive after the
+	// repository is distributed successfully, to keep deployment history.
+	Record func(repo io.Reader) error
+
+	// The function called when the deployment enters the building or
+	// distributing stage, to report the progress of deployment.
+	Stage func(stage string)
+
+	// The function called with the application manifest found in the
+	// repository before the repository is distributed, to reconcile the
+	// application with the manifest. The deployment is aborted if failed.
+	Reconcile func(app *manifest.Application) error
+
+	// Run the database migration declared in the application manifest.
+	Migrate bool
+
+	// The variables set in the environment of the build.
+	BuildEnv map[string]string
+
+	// The names of build variables with secret values, which are masked
+	// in the build log.
+	BuildSecrets []string
+
+	// Pull the environment of the application into the build, build
+	// variables take precedence over application variables.
+	PullEnv bool
+}
+
+// Deployment stages reported to DeployOptions.Stage.
+const (
+	StageBuilding     = "building"
+	StageDistributing = "distributing"
+)
+
+// Events of deploying the repository to a container, reported as progress
+// of the distributing stage. Containers are restarted after notified, the
+// restarted and healthy events are only reported by deploy strategies that
+// wait for containers becoming running.
+const (
+	DeployEventCopying    = "copying"
+	DeployEventCopied     = "copied"
+	DeployEventVerified   = "verified"
+	DeployEventSignaled   = "signaled"
+	DeployEventRestarted  = "restarted"
+	DeployEventHealthy    = "healthy"
+	DeployEventUnhealthy  = "unhealthy"
+	DeployEventFailed     = "failed"
+	DeployEventRolledBack = "rolled-back"
+)
+
+func reportDeploy(log *serverlog.ServerLog, c *Container, event string, bytes int64, err error) {
+	p := serverlog.Progress{
+		Phase:     StageDistributing,
+		Percent:   -1,
+		Container: c.ID,
+		Event:     event,
+		Bytes:     bytes,
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	log.Progress(p)
+}
+
+// enterStage reports the deployment stage to DeployOptions.Stage, and to
+// the client as a progress record.
+func (opts *DeployOptions) enterStage(stage string, log *serverlog.ServerLog) {
+	if opts.Stage != nil {
+		opts.Stage(stage)
+	}
+	log.Progress(serverlog.Progress{Phase: stage, Percent: -1})
+}
+
+// deployProgress reports the percentage of containers the repository has
+// been deployed to.
+type deployProgress struct {
+	log   *serverlog.ServerLog
+	total int
+	mu    sync.Mutex
+	done  int
+}
+
+func newDeployProgress(log *serverlog.ServerLog, total int) *deployProgress {
+	log.Progress(serverlog.Progress{Phase: StageDistributing, Percent: 0})
+	return &deployProgress{log: log, total: total}
+}
+
+func (p *deployProgress) deployed(c *Container) {
+	p.mu.Lock()
+	p.done++
+	pct := p.done * 100 / p.total
+	p.mu.Unlock()
+	p.log.Progress(serverlog.Progress{Phase: StageDistributing, Percent: pct, Container: c.ID})
+}
+
+type InvalidDeployOptionError struct {
+	Name  string
+	Value string
+}
+
+func (e InvalidDeployOptionError) Error() string {
+	return fmt.Sprintf("Invalid deploy option %s: %s", e.Name, e.Value)
+}
+
+func (e InvalidDeployOptionError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseDeployOptions parses deploy options from URL query values.
+func ParseDeployOptions(query url.Values) (opts DeployOptions, err error) {
+	if opts.Condition, err = ParseDeployCondition(query.Get("condition")); err != nil {
+		return
+	}
+	if opts.Strategy, err = ParseDeployStrategy(query.Get("strategy")); err != nil {
+		return
+	}
+	if v := query.Get("batch"); v != "" {
+		if opts.BatchSize, err = strconv.Atoi(v); err != nil || opts.BatchSize <= 0 {
+			return opts, InvalidDeployOptionError{"batch", v}
+		}
+	}
+	if v := query.Get("max-failures"); v != "" {
+		if opts.FailureThreshold, err = strconv.Atoi(v); err != nil || opts.FailureThreshold < 0 {
+			return opts, InvalidDeployOptionError{"max-failures", v}
+		}
+	}
+	if v := query.Get("retries"); v != "" {
+		if opts.Retries, err = strconv.Atoi(v); err != nil || opts.Retries < 0 {
+			return opts, InvalidDeployOptionError{"retries", v}
+		}
+	}
+	if opts.OnFailure, err = ParseFailurePolicy(query.Get("on-failure")); err != nil {
+		return
+	}
+	if v := query.Get("canary"); v != "" {
+		if opts.CanaryPercent, err = strconv.Atoi(v); err != nil || opts.CanaryPercent <= 0 || opts.CanaryPercent >= 100 {
+			return opts, InvalidDeployOptionError{"canary", v}
+		}
+	}
+	if v := query.Get("weight"); v != "" {
+		if opts.CanaryWeight, err = strconv.Atoi(v); err != nil || opts.CanaryWeight <= 0 || opts.CanaryWeight > 100 {
+			return opts, InvalidDeployOptionError{"weight", v}
+		}
+	}
+	if v := query.Get("delta"); v != "" {
+		if opts.Delta, err = strconv.ParseBool(v); err != nil {
+			return opts, InvalidDeployOptionError{"delta", v}
+		}
+	}
+	if v := query.Get("pull-env"); v != "" {
+		if opts.PullEnv, err = strconv.ParseBool(v); err != nil {
+			return opts, InvalidDeployOptionError{"pull-env", v}
+		}
+	}
+	return opts, nil
+}
+
+var buildEnvKey = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+
+// ParseBuildEnv parses build variables encoded by EncodeBuildEnv. Values
+// are never included in errors since they may be secrets.
+func (opts *DeployOptions) ParseBuildEnv(encoded string) error {
+	if encoded == "" {
+		return nil
+	}
+	query, err := url.ParseQuery(encoded)
+	if err != nil {
+		return InvalidDeployOptionError{"build-env", "malformed encoding"}
+	}
+	for _, key := range []string{"build-env", "build-secret"} {
+		for _, v := range query[key] {
+			kv := strings.SplitN(v, "=", 2)
+			if len(kv) != 2 || !buildEnvKey.MatchString(kv[0]) {
+				return InvalidDeployOptionError{key, kv[0]}
+			}
+			if opts.BuildEnv == nil {
+				opts.BuildEnv = make(map[string]string)
+			}
+			opts.BuildEnv[kv[0]] = kv[1]
+			if key == "build-secret" {
+				opts.BuildSecrets = append(opts.BuildSecrets, kv[0])
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeBuildEnv encodes build variables as URL query values. Build
+// variables are sent in a request header rather than URL query, which is
+// usually logged. Returns an empty string if there is no build variable.
+func (opts DeployOptions) EncodeBuildEnv() string {
+	if len(opts.BuildEnv) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(opts.BuildEnv))
+	for k := range opts.BuildEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	query := url.Values{}
+	for _, k := range keys {
+		if opts.isSecret(k) {
+			query.Add("build-secret", k+"="+opts.BuildEnv[k])
+		} else {
+			query.Add("build-env", k+"="+opts.BuildEnv[k])
+		}
+	}
+	return query.Encode()
+}
+
+// Encode deploy options into URL query values. Default options are omitted.
+// Build variables are encoded separately by EncodeBuildEnv.
+func (opts DeployOptions) Encode(query url.Values) {
+	if opts.Condition != DeployAlways {
+		query.Set("condition", opts.Condition.String())
+	}
+	if opts.Strategy != DeployAllAtOnce {
+		query.Set("strategy", opts.Strategy.String())
+	}
+	if opts.BatchSize > 0 {
+		query.Set("batch", strconv.Itoa(opts.BatchSize))
+	}
+	if opts.FailureThreshold > 0 {
+		query.Set("max-failures", strconv.Itoa(opts.FailureThreshold))
+	}
+	if opts.Retries > 0 {
+		query.Set("retries", strconv.Itoa(opts.Retries))
+	}
+	if opts.OnFailure != FailContinue {
+		query.Set("on-failure", opts.OnFailure.String())
+	}
+	if opts.CanaryPercent > 0 {
+		query.Set("canary", strconv.Itoa(opts.CanaryPercent))
+	}
+	if opts.CanaryWeight > 0 {
+		query.Set("weight", strconv.Itoa(opts.CanaryWeight))
+	}
+	if opts.Delta {
+		query.Set("delta", "true")
+	}
+	if opts.PullEnv {
+		query.Set("pull-env", "true")
+	}
+}
+
+func (opts *DeployOptions) isSecret(key string) bool {
+	for _, k := range opts.BuildSecrets {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (cli DockerClient) DistributeRepo(ctx context.Context, containers []*Container, repo io.Reader, zip bool, opts DeployOptions, log *serverlog.ServerLog) error {
+	var targets []*Container
+	for _, c := range containers {
+		if c.Category().IsFramework() {
+			targets = append(targets, c)
+		}
+	}
+	if len(targets) == 0 {
+		var name string
+		if len(containers) != 0 {
+			name = containers[0].Name
+		}
+		return NoFrameworkError(name)
+	}
+
+	opts.enterStage(StageDistributing, log)
 	repodir, err := PrepareRepo(repo, zip)
 	if repodir != "" {
 		defer os.RemoveAll(repodir)
@@ -76,25 +626,262 @@ func (cli DockerClient) DistributeRepo(ctx context.Context, containers []*Contai
 	if err != nil {
 		return err
 	}
+	var migration *manifest.Migration
+	if opts.Reconcile != nil || opts.Migrate {
+		app, err := readAppManifest(repodir)
+		if err == nil && app != nil && opts.Reconcile != nil {
+			err = opts.Reconcile(app)
+		}
+		if err != nil {
+			return err
+		}
+		if app != nil && opts.Migrate {
+			migration = app.Migrate
+		}
+	}
+	if opts.Delta {
+		opts.manifest = scanRepo(repodir)
+	}
 
-	for _, c := range containers {
-		if c.Category().IsFramework() {
-			er := c.Deploy(ctx, repodir)
-			if er != nil {
-				err = er
+	// run the migration before the rollout if the rollout is blocked,
+	// otherwise run the migration along with the rollout
+	var migrated chan error
+	if migration != nil {
+		if migration.Block {
+			if err = cli.migrate(ctx, targets[0], repodir, migration, log); err != nil {
+				return err
+			}
+		} else {
+			log = serverlog.Synchronized(log)
+			migrated = make(chan error, 1)
+			go func() {
+				migrated <- cli.migrate(ctx, targets[0], repodir, migration, log)
+			}()
+		}
+	}
+
+	switch opts.Strategy {
+	case DeployRolling:
+		err = rollingDeploy(ctx, targets, repodir, opts, log)
+	case DeployBlueGreen:
+		err = cli.blueGreenDeploy(ctx, targets, repodir, opts, log)
+	case DeployCanary:
+		err = canaryDeploy(ctx, targets, repodir, opts, log)
+	default:
+		err = deployAll(ctx, targets, repodir, opts, log)
+	}
+
+	// the deployment succeeded even if the migration along with the
+	// rollout failed
+	if migrated != nil {
+		if er := <-migrated; er != nil {
+			logrus.WithError(er).Warnf("Migration of %s-%s failed", targets[0].Name, targets[0].Namespace)
+			if log != nil {
+				fmt.Fprintf(log.Stderr(), "WARNING: %v\n", er)
 			}
 		}
 	}
+
+	// the deployment succeeded even if failed to record the repository
+	if err == nil && opts.Record != nil {
+		if er := recordRepo(repodir, opts.Record); er != nil {
+			logrus.WithError(er).Warn("Failed to record deployment")
+		}
+	}
 	return err
 }
 
-func (cli DockerClient) DeployRepo(ctx context.Context, name, namespace string, in io.Reader, log *serverlog.ServerLog) error {
+// The default maximum number of containers the repository is deployed to
+// at the same time.
+const defaultDeployConcurrency = 8
+
+// DistributeError reports that the repository failed to deploy to some
+// containers of the application. Results of all containers are available
+// if the error is returned from a deployment.
+type DistributeError struct {
+	Name       string
+	Total      int
+	Errors     map[string]error
+	Results    []*DeployResult
+	RolledBack bool
+}
+
+func (e DistributeError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("%s: %v", shortID(id), e.Errors[id])
+	}
+	msg := fmt.Sprintf("%s: failed to deploy to %d of %d containers: %s",
+		e.Name, len(e.Errors), e.Total, strings.Join(msgs, "; "))
+	if e.RolledBack {
+		msg += "; deployment rolled back"
+	}
+	return msg
+}
+
+// ErrorDetails returns results of containers, reported to the client.
+func (e DistributeError) ErrorDetails() map[string]interface{} {
+	if e.Results == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"Containers": e.Results,
+		"RolledBack": e.RolledBack,
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// deployConcurrency returns the maximum number of containers deployed at
+// the same time, configured by deploy.concurrency.
+func deployConcurrency() int {
+	n, err := strconv.Atoi(config.GetOrDefault("deploy.concurrency", strconv.Itoa(defaultDeployConcurrency)))
+	if err != nil || n <= 0 {
+		logrus.Warnf("Invalid deploy.concurrency configuration: %s", config.Get("deploy.concurrency"))
+		n = defaultDeployConcurrency
+	}
+	return n
+}
+
+// deployAll deploys the repository to all containers in parallel, so the
+// deployment fans out across Docker hosts. At most deploy.concurrency
+// containers are deployed at the same time. By default containers failed
+// to deploy don't stop the deployment to other containers, and the errors
+// are reported together. With the rollback policy the deployment stops on
+// the first failure, and the containers attempted are rolled back.
+func deployAll(ctx context.Context, targets []*Container, repodir string, opts DeployOptions, log *serverlog.ServerLog) error {
+	log = serverlog.Synchronized(log)
+
+	snapshot := prepareRollback(ctx, targets, opts, log)
+	if snapshot != "" {
+		defer os.RemoveAll(snapshot)
+	}
+
+	dctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make([]*DeployResult, len(targets))
+		errs     = make([]error, len(targets))
+		sem      = make(chan struct{}, deployConcurrency())
+		wg       sync.WaitGroup
+		progress = newDeployProgress(log, len(targets))
+	)
+
+	for i, c := range targets {
+		sem <- struct{}{}
+		if dctx.Err() != nil {
+			// stopped on failure
+			<-sem
+			results[i] = &DeployResult{ID: c.ID}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c *Container) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i], errs[i] = deployWithRetry(dctx, c, repodir, opts, log)
+			if errs[i] != nil && opts.OnFailure == FailRollback {
+				cancel()
+			}
+			progress.deployed(c)
+			if log == nil {
+				return
+			}
+			if errs[i] != nil {
+				fmt.Fprintf(log.Stderr(), "Failed to deploy to container %s: %v\n", shortID(c.ID), errs[i])
+			} else {
+				fmt.Fprintf(log, "Deployed to container %s\n", shortID(c.ID))
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	failed := make(map[string]error)
+	for i, err := range errs {
+		if err != nil {
+			failed[targets[i].ID] = err
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	derr := DistributeError{Name: targets[0].Name, Total: len(targets), Errors: failed, Results: results}
+	if opts.OnFailure == FailRollback && ctx.Err() == nil {
+		derr.RolledBack = rollbackDeployed(ctx, snapshot, targets, results, log)
+	}
+	return derr
+}
+
+// readAppManifest reads the application manifest in the root of the
+// repository. Nil is returned if the repository has no manifest.
+func readAppManifest(repodir string) (*manifest.Application, error) {
+	f, err := os.Open(repoArchive(repodir))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Clean(hdr.Name) == manifest.AppManifestFile {
+			return manifest.ReadApplication(tr)
+		}
+	}
+}
+
+func recordRepo(repodir string, record func(io.Reader) error) error {
+	f, err := os.Open(repoArchive(repodir))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return record(f)
+}
+
+func repoArchive(repodir string) string {
+	return filepath.Join(repodir, filepath.Base(repodir)+".tar.gz")
+}
+
+func (cli DockerClient) DeployRepo(ctx context.Context, name, namespace string, in io.Reader, opts DeployOptions, log *serverlog.ServerLog) (err error) {
+	defer metrics.ObserveDeployment(opts.Strategy.String(), time.Now(), &err)
+
 	containers, err := cli.FindApplications(ctx, name, namespace)
 	if err != nil {
 		return err
 	}
 	if len(containers) == 0 {
-		return fmt.Errorf("%s: application not found", name)
+		return checkNoFramework(cli, ctx, name, namespace)
+	}
+	if err = CheckDeployCondition(ctx, containers, opts.Condition, log); err != nil {
+		return err
 	}
 
 	// randomly select a base container
@@ -105,53 +892,82 @@ func (cli DockerClient) DeployRepo(ctx context.Context, name, namespace string,
 		base = containers[rand.Intn(len(containers))]
 	}
 
-	if base.Flags()&HotDeployable != 0 {
+	// choose hot deployment or full build by files changed in the repository
+	hot := base.Flags()&HotDeployable != 0
+	if plugin, err := readPluginManifestFromContainer(ctx, base); err == nil && len(plugin.DeployRules) != 0 {
+		if err = manifest.ValidateDeployRules(plugin.DeployRules); err != nil {
+			logrus.WithError(err).Warnf("Ignored deploy rules of %s", base.FQDN())
+		} else {
+			repodir, err := PrepareRepo(in, false)
+			if repodir != "" {
+				defer os.RemoveAll(repodir)
+			}
+			if err != nil {
+				return err
+			}
+			if hot, err = chooseDeploy(ctx, base, repodir, plugin.DeployRules, hot, log); err != nil {
+				return err
+			}
+			f, err := os.Open(repoArchive(repodir))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+	}
+
+	if hot {
 		// distribute the repository directly
-		return cli.DistributeRepo(ctx, containers, in, false)
+		return cli.DistributeRepo(ctx, containers, in, false, opts, log)
 	} else {
 		// build and distribute the repository
-		return build(cli, ctx, containers, base, in, log)
+		return build(cli, ctx, containers, base, in, opts, log)
 	}
 }
 
-func build(cli DockerClient, ctx context.Context, containers []*Container, base *Container, in io.Reader, log *serverlog.ServerLog) (err error) {
-	plugin, err := readPluginManifestFromContainer(ctx, base)
+// checkNoFramework distinguishes an application without framework
+// container from an application that doesn't exist.
+func checkNoFramework(cli DockerClient, ctx context.Context, name, namespace string) error {
+	cs, err := cli.FindAll(ctx, name, namespace)
 	if err != nil {
-		return
+		return err
 	}
-
-	// create a builder container
-	opts := CreateOptions{
-		Name:      base.Name,
-		Namespace: base.Namespace,
-		Plugin:    plugin,
-		Image:     base.Config.Image,
-		Home:      base.Home(),
-		User:      base.User(),
-		Log:       log,
+	if len(cs) != 0 {
+		return NoFrameworkError(name)
 	}
-	builder, err := cli.CreateBuilder(ctx, opts)
+	return fmt.Errorf("%s: application not found", name)
+}
+
+func build(cli DockerClient, ctx context.Context, containers []*Container, base *Container, in io.Reader, deploy DeployOptions, log *serverlog.ServerLog) (err error) {
+	deploy.enterStage(StageBuilding, log)
+
+	// builders with build environment are never reused, so variables
+	// never leak into other builds
+	pooled := builderPoolEnabled() && len(deploy.BuildEnv) == 0 && !deploy.PullEnv
+	builder, plugin, remove, err := startBuilder(cli, ctx, base, pooled, log)
 	if err != nil {
 		return
 	}
 	defer func() {
-		rmopts := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
-		cli.ContainerRemove(ctx, builder.ID, rmopts)
+		remove()
+		if err != nil && ctx.Err() != nil {
+			err = DeployCancelledError(base.Name)
+		}
 	}()
 
-	// start builder container
-	err = builder.ContainerStart(ctx, builder.ID, types.ContainerStartOptions{})
-	if err != nil {
+	if err = setBuildEnv(ctx, base, builder, deploy); err != nil {
 		return
 	}
+	masked, flush := serverlog.Masked(log, deploy.buildSecretValues())
 
-	// build the application, use cache during build
-	copyCache(ctx, plugin, base, builder, true)
-	err = builder.Exec(ctx, "", in, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "build")
+	// build the application, build caches are mounted from volumes
+	ownBuildCache(ctx, plugin, builder)
+	err = builder.Exec(ctx, "", in, masked.Stdout(), masked.Stderr(), "/usr/bin/cwctl", "build")
+	flush()
 	if err != nil {
 		return
 	}
-	copyCache(ctx, plugin, builder, base, false)
 
 	// download application repository from builder container
 	repo, _, err := builder.CopyFromContainer(ctx, builder.ID, builder.RepoDir()+"/.")
@@ -160,7 +976,103 @@ func build(cli DockerClient, ctx context.Context, containers []*Container, base
 	}
 	defer repo.Close()
 
-	return cli.DistributeRepo(ctx, containers, repo, true)
+	return cli.DistributeRepo(ctx, containers, repo, true, deploy, log)
+}
+
+// setBuildEnv pulls the environment of the application into the builder
+// if requested, and sets build variables in the environment of the builder.
+func setBuildEnv(ctx context.Context, base, builder *Container, deploy DeployOptions) error {
+	if deploy.PullEnv {
+		if err := CopyEnv(ctx, base, builder); err != nil {
+			return err
+		}
+	}
+	return builder.SetenvAll(ctx, deploy.BuildEnv)
+}
+
+func (opts *DeployOptions) buildSecretValues() []string {
+	var values []string
+	for _, k := range opts.BuildSecrets {
+		if v := opts.BuildEnv[k]; v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// startBuilder creates and starts a one-off container from the image of the
+// base container, or leases a warm builder from the builder pool if pooled.
+// The returned function removes the container, or returns it to the pool if
+// the build is not cancelled. The container is killed immediately when the
+// context is cancelled.
+func startBuilder(cli DockerClient, ctx context.Context, base *Container, pooled bool, log *serverlog.ServerLog) (builder *Container, plugin *manifest.Plugin, remove func(), err error) {
+	plugin, err = readPluginManifestFromContainer(ctx, base)
+	if err != nil {
+		return
+	}
+
+	// verify the builder image against the plugin manifest
+	image, err := ResolveBuildImage(ctx, base, plugin)
+	if mismatch, ok := err.(BuildImageMismatchError); ok {
+		if log != nil {
+			fmt.Fprintf(log.Stderr(), "WARNING: %s, building with image %s\n", mismatch, shortImageID(image))
+		}
+	} else if err != nil {
+		return
+	}
+
+	// lease a warm builder or create a builder container
+	key := builderKey(base.Name, base.Namespace, image)
+	if pooled {
+		builder = leaseBuilder(ctx, key)
+	}
+	leased := builder != nil
+	if !leased {
+		opts := CreateOptions{
+			Name:      base.Name,
+			Namespace: base.Namespace,
+			Plugin:    plugin,
+			Image:     image,
+			Home:      base.Home(),
+			User:      base.User(),
+			Log:       log,
+		}
+		builder, err = cli.CreateBuilder(ctx, opts)
+		if err != nil {
+			return
+		}
+	}
+
+	setBuilderActive(builder.ID, true)
+
+	// kill the builder container immediately when the deployment is
+	// cancelled, the builder container is removed regardless of the
+	// cancellation
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cli.ContainerKill(context.Background(), builder.ID, "SIGKILL")
+		case <-done:
+		}
+	}()
+	remove = func() {
+		close(done)
+		defer setBuilderActive(builder.ID, false)
+		if pooled && ctx.Err() == nil && returnBuilder(key, builder) {
+			return
+		}
+		removeBuilder(builder)
+	}
+
+	// start builder container
+	if !leased {
+		err = builder.ContainerStart(ctx, builder.ID, types.ContainerStartOptions{})
+		if err != nil {
+			remove()
+		}
+	}
+	return
 }
 
 func readPluginManifestFromContainer(ctx context.Context, base *Container) (meta *manifest.Plugin, err error) {
@@ -190,28 +1102,3 @@ func readPluginManifestFromContainer(ctx context.Context, base *Container) (meta
 	plugin.Tag = base.PluginTag()
 	return &plugin, err
 }
-
-func copyCache(ctx context.Context, plugin *manifest.Plugin, from, to *Container, chown bool) {
-	if len(plugin.BuildCache) == 0 {
-		return
-	}
-
-	var paths = make([]string, len(plugin.BuildCache))
-	for i, cache := range plugin.BuildCache {
-		paths[i] = from.Home() + "/" + cache
-	}
-
-	opts := types.CopyToContainerOptions{AllowOverwriteDirWithFile: true}
-	for _, path := range paths {
-		content, _, err := from.CopyFromContainer(ctx, from.ID, path+"/.")
-		if err == nil {
-			to.CopyToContainer(ctx, to.ID, path+"/", content, opts)
-			content.Close()
-		}
-	}
-
-	if chown {
-		args := append([]string{"chown", "-R", to.User()}, paths...)
-		to.Exec(ctx, "root", nil, nil, nil, args...)
-	}
-}
@@ -0,0 +1,498 @@
+package container_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+type fakeSwitcher struct {
+	old       []string
+	endpoints map[string][]*manifest.Endpoint
+	err       error
+}
+
+func (s *fakeSwitcher) SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.old, s.endpoints = old, endpoints
+	return nil
+}
+
+type fakeSplitter struct {
+	stable, canary map[string][]*manifest.Endpoint
+	weight         int
+	err            error
+}
+
+func (s *fakeSplitter) SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.stable, s.canary, s.weight = stable, canary, weight
+	return nil
+}
+
+var _ = Describe("Deploy", func() {
+	const NAMESPACE = "container_deploy_test"
+
+	var (
+		ctx        = context.Background()
+		containers []*container.Container
+	)
+
+	AfterEach(func() {
+		for _, c := range containers {
+			Expect(c.Destroy(ctx)).To(Succeed())
+		}
+		containers = nil
+	})
+
+	Context("with service only application", func() {
+		BeforeEach(func() {
+			service, err := pluginHub.GetPluginInfo("mockdb")
+			Expect(err).NotTo(HaveOccurred())
+
+			options := container.CreateOptions{
+				Name:      "test",
+				Namespace: NAMESPACE,
+				Plugin:    service,
+				Scaling:   1,
+			}
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+		})
+
+		It("should report no deploy target when distributing repository", func() {
+			err := dockerCli.DistributeRepo(ctx, containers, bytes.NewReader(nil), false, container.DeployOptions{}, nil)
+			Expect(err).To(Equal(container.NoFrameworkError("test")))
+		})
+
+		It("should report no deploy target when deploying repository", func() {
+			err := dockerCli.DeployRepo(ctx, "test", NAMESPACE, bytes.NewReader(nil), container.DeployOptions{}, nil)
+			Expect(err).To(Equal(container.NoFrameworkError("test")))
+		})
+	})
+
+	Context("with rolling deployment", func() {
+		var rolling = container.DeployOptions{
+			Strategy: container.DeployRolling,
+			Timeout:  30 * time.Second,
+		}
+
+		var repo = func() *bytes.Buffer {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(zw)
+			tw.WriteHeader(&tar.Header{Name: "marker", Mode: 0644, Size: 7})
+			tw.Write([]byte("rolling"))
+			tw.Close()
+			zw.Close()
+			return &buf
+		}
+
+		BeforeEach(func() {
+			plugin, err := pluginHub.GetPluginInfo("mock")
+			Expect(err).NotTo(HaveOccurred())
+
+			options := container.CreateOptions{
+				Name:      "test",
+				Namespace: NAMESPACE,
+				Plugin:    plugin,
+				Scaling:   3,
+			}
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(3))
+
+			for _, c := range containers {
+				Expect(c.Start(ctx, nil)).To(Succeed())
+			}
+		})
+
+		It("should parse deploy strategy", func() {
+			Expect(container.ParseDeployStrategy("")).To(Equal(container.DeployAllAtOnce))
+			Expect(container.ParseDeployStrategy("rolling")).To(Equal(container.DeployRolling))
+
+			_, err := container.ParseDeployStrategy("random")
+			Expect(err).To(Equal(container.InvalidDeployStrategyError("random")))
+		})
+
+		It("should update all containers", func() {
+			opts := rolling
+			opts.BatchSize = 2
+			Expect(dockerCli.DistributeRepo(ctx, containers, repo(), false, opts, nil)).To(Succeed())
+
+			for _, c := range containers {
+				c := c
+				Eventually(func() error {
+					r, _, err := c.CopyFromContainer(ctx, c.ID, c.RepoDir()+"/marker")
+					if err == nil {
+						r.Close()
+					}
+					return err
+				}, "10s").Should(Succeed())
+			}
+		})
+
+		It("should abort when failures exceed threshold", func() {
+			Expect(containers[0].Stop(ctx)).To(Succeed())
+
+			err := dockerCli.DistributeRepo(ctx, containers, repo(), false, rolling, nil)
+			Expect(err).To(BeAssignableToTypeOf(container.RollingDeployError{}))
+
+			rerr := err.(container.RollingDeployError)
+			Expect(rerr.Updated).To(Equal(1))
+			Expect(rerr.Total).To(Equal(3))
+			Expect(rerr.Failed).To(Equal([]string{containers[0].ID}))
+
+			// remaining containers are not updated
+			_, _, err = containers[2].CopyFromContainer(ctx, containers[2].ID, containers[2].RepoDir()+"/marker")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should proceed when failures within threshold", func() {
+			Expect(containers[0].Stop(ctx)).To(Succeed())
+
+			opts := rolling
+			opts.FailureThreshold = 1
+			Expect(dockerCli.DistributeRepo(ctx, containers, repo(), false, opts, nil)).To(Succeed())
+		})
+	})
+
+	Context("with blue-green deployment", func() {
+		var (
+			switcher *fakeSwitcher
+			original []string
+		)
+
+		BeforeEach(func() {
+			plugin, err := pluginHub.GetPluginInfo("mock")
+			Expect(err).NotTo(HaveOccurred())
+
+			options := container.CreateOptions{
+				Name:      "test",
+				Namespace: NAMESPACE,
+				Plugin:    plugin,
+				Scaling:   2,
+			}
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(2))
+
+			original = nil
+			for _, c := range containers {
+				Expect(c.Start(ctx, nil)).To(Succeed())
+				original = append(original, c.ID)
+			}
+			switcher = &fakeSwitcher{}
+		})
+
+		AfterEach(func() {
+			// containers may be replaced by deployment
+			var err error
+			containers, err = dockerCli.FindApplications(ctx, "test", NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		var deploy = func() error {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(zw)
+			tw.WriteHeader(&tar.Header{Name: "marker", Mode: 0644, Size: 10})
+			tw.Write([]byte("blue-green"))
+			tw.Close()
+			zw.Close()
+
+			opts := container.DeployOptions{
+				Strategy: container.DeployBlueGreen,
+				Timeout:  30 * time.Second,
+				Switcher: switcher,
+			}
+			return dockerCli.DistributeRepo(ctx, containers, &buf, false, opts, nil)
+		}
+
+		var currentIDs = func() []string {
+			cs, err := dockerCli.FindApplications(ctx, "test", NAMESPACE)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			var ids []string
+			for _, c := range cs {
+				ids = append(ids, c.ID)
+			}
+			return ids
+		}
+
+		It("should switch traffic to new containers and remove old containers", func() {
+			Expect(deploy()).To(Succeed())
+
+			Expect(switcher.old).To(ConsistOf(original))
+			Expect(switcher.endpoints).To(HaveLen(2))
+
+			ids := currentIDs()
+			Expect(ids).To(HaveLen(2))
+			for _, id := range ids {
+				Expect(original).NotTo(ContainElement(id))
+				Expect(switcher.endpoints).To(HaveKey(id))
+			}
+
+			cs, _ := dockerCli.FindApplications(ctx, "test", NAMESPACE)
+			for _, c := range cs {
+				Expect(c.IsStandby(ctx)).To(BeFalse())
+				r, _, err := c.CopyFromContainer(ctx, c.ID, c.RepoDir()+"/marker")
+				Expect(err).NotTo(HaveOccurred())
+				r.Close()
+			}
+		})
+
+		It("should keep old containers when traffic switch failed", func() {
+			switcher.err = errors.New("proxy unavailable")
+			Expect(deploy()).To(MatchError("proxy unavailable"))
+			Expect(currentIDs()).To(ConsistOf(original))
+		})
+
+		It("should require a traffic switcher", func() {
+			switcher = nil
+			opts := container.DeployOptions{Strategy: container.DeployBlueGreen}
+			err := dockerCli.DistributeRepo(ctx, containers, bytes.NewReader(nil), false, opts, nil)
+			Expect(err).To(Equal(container.ErrNoTrafficSwitcher))
+			Expect(currentIDs()).To(ConsistOf(original))
+		})
+	})
+
+	Context("with canary deployment", func() {
+		var splitter *fakeSplitter
+
+		BeforeEach(func() {
+			plugin, err := pluginHub.GetPluginInfo("mock")
+			Expect(err).NotTo(HaveOccurred())
+
+			options := container.CreateOptions{
+				Name:      "test",
+				Namespace: NAMESPACE,
+				Plugin:    plugin,
+				Scaling:   4,
+			}
+			containers, err = dockerCli.Create(ctx, options)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(4))
+
+			for _, c := range containers {
+				Expect(c.Start(ctx, nil)).To(Succeed())
+			}
+			splitter = &fakeSplitter{}
+		})
+
+		var deploy = func() error {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(zw)
+			tw.WriteHeader(&tar.Header{Name: "marker", Mode: 0644, Size: 6})
+			tw.Write([]byte("canary"))
+			tw.Close()
+			zw.Close()
+
+			opts := container.DeployOptions{
+				Strategy:      container.DeployCanary,
+				Timeout:       30 * time.Second,
+				CanaryPercent: 25,
+				CanaryWeight:  20,
+				Splitter:      splitter,
+			}
+			return dockerCli.DistributeRepo(ctx, containers, &buf, false, opts, nil)
+		}
+
+		var hasMarker = func(c *container.Container) bool {
+			r, _, err := c.CopyFromContainer(ctx, c.ID, c.RepoDir()+"/marker")
+			if err == nil {
+				r.Close()
+			}
+			return err == nil
+		}
+
+		var canaries = func() (canary []*container.Container) {
+			for _, c := range containers {
+				if c.IsCanary(ctx) {
+					canary = append(canary, c)
+				}
+			}
+			return
+		}
+
+		It("should parse canary options", func() {
+			opts, err := container.ParseDeployOptions(url.Values{"strategy": {"canary"}, "canary": {"25"}, "weight": {"20"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Strategy).To(Equal(container.DeployCanary))
+			Expect(opts.CanaryPercent).To(Equal(25))
+			Expect(opts.CanaryWeight).To(Equal(20))
+
+			_, err = container.ParseDeployOptions(url.Values{"canary": {"100"}})
+			Expect(err).To(Equal(container.InvalidDeployOptionError{Name: "canary", Value: "100"}))
+		})
+
+		It("should route traffic to canary containers", func() {
+			Expect(deploy()).To(Succeed())
+
+			canary := canaries()
+			Expect(canary).To(HaveLen(1))
+			Expect(canary[0].CanaryWeight(ctx)).To(Equal(20))
+			Expect(hasMarker(canary[0])).To(BeTrue())
+
+			Expect(splitter.weight).To(Equal(20))
+			Expect(splitter.stable).To(HaveLen(3))
+			Expect(splitter.canary).To(HaveKey(canary[0].ID))
+
+			Expect(deploy()).To(Equal(container.CanaryInProgressError("test")))
+		})
+
+		It("should promote canary release to all containers", func() {
+			Expect(deploy()).To(Succeed())
+			Expect(dockerCli.PromoteCanary(ctx, "test", NAMESPACE, splitter, nil)).To(Succeed())
+
+			Expect(canaries()).To(BeEmpty())
+			Expect(splitter.stable).To(HaveLen(4))
+			Expect(splitter.canary).To(BeEmpty())
+			for _, c := range containers {
+				Expect(hasMarker(c)).To(BeTrue())
+			}
+		})
+
+		It("should restore stable release when aborted", func() {
+			Expect(deploy()).To(Succeed())
+			Expect(dockerCli.AbortCanary(ctx, "test", NAMESPACE, splitter, nil)).To(Succeed())
+
+			Expect(canaries()).To(BeEmpty())
+			Expect(splitter.stable).To(HaveLen(4))
+			Expect(splitter.canary).To(BeEmpty())
+
+			err := dockerCli.AbortCanary(ctx, "test", NAMESPACE, splitter, nil)
+			Expect(err).To(Equal(container.NoCanaryError("test")))
+		})
+
+		It("should restore stable release when traffic split failed", func() {
+			splitter.err = errors.New("proxy unavailable")
+			Expect(deploy()).To(MatchError("proxy unavailable"))
+			Expect(canaries()).To(BeEmpty())
+		})
+	})
+
+	It("should report containers failed to deploy", func() {
+		err := container.DistributeError{
+			Name:  "test",
+			Total: 3,
+			Errors: map[string]error{
+				"bbbbbbbbbbbbbbbb": errors.New("no space left on device"),
+				"aaaaaaaaaaaaaaaa": errors.New("container not running"),
+			},
+		}
+		Expect(err.Error()).To(Equal("test: failed to deploy to 2 of 3 containers: " +
+			"aaaaaaaaaaaa: container not running; bbbbbbbbbbbb: no space left on device"))
+	})
+
+	It("should digest the prepared repository", func() {
+		repodir, err := container.PrepareRepo(bytes.NewReader([]byte("repository")), false)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(repodir)
+
+		digests, err := filepath.Glob(filepath.Join(repodir, "*.tar.gz.sha256"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digests).To(HaveLen(1))
+		digest, err := ioutil.ReadFile(digests[0])
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256([]byte("repository"))
+		Expect(string(digest)).To(Equal(hex.EncodeToString(sum[:])))
+	})
+
+	It("should reject repository exceeding the maximum size", func() {
+		config.Set("deploy.max_repo_size", "16")
+		defer config.Remove("deploy.max_repo_size")
+
+		repodir, err := container.PrepareRepo(bytes.NewReader(make([]byte, 32)), false)
+		if repodir != "" {
+			defer os.RemoveAll(repodir)
+		}
+		Expect(err).To(Equal(container.RepoTooLargeError{Limit: 16}))
+	})
+
+	It("should parse failure policy options", func() {
+		opts, err := container.ParseDeployOptions(url.Values{"retries": {"2"}, "on-failure": {"rollback"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Retries).To(Equal(2))
+		Expect(opts.OnFailure).To(Equal(container.FailRollback))
+
+		encoded := url.Values{}
+		opts.Encode(encoded)
+		Expect(encoded).To(Equal(url.Values{"retries": {"2"}, "on-failure": {"rollback"}}))
+
+		_, err = container.ParseDeployOptions(url.Values{"on-failure": {"ignore"}})
+		Expect(err).To(Equal(container.InvalidDeployOptionError{Name: "on-failure", Value: "ignore"}))
+		_, err = container.ParseDeployOptions(url.Values{"retries": {"-1"}})
+		Expect(err).To(Equal(container.InvalidDeployOptionError{Name: "retries", Value: "-1"}))
+	})
+
+	It("should report results of containers in rolled back deployment", func() {
+		results := []*container.DeployResult{
+			{ID: "aaaaaaaaaaaaaaaa", Attempts: 1, RolledBack: true},
+			{ID: "bbbbbbbbbbbbbbbb", Attempts: 3, Error: "no space left on device", RolledBack: true},
+			{ID: "cccccccccccccccc"},
+		}
+		err := container.DistributeError{
+			Name:       "test",
+			Total:      3,
+			Errors:     map[string]error{"bbbbbbbbbbbbbbbb": errors.New("no space left on device")},
+			Results:    results,
+			RolledBack: true,
+		}
+		Expect(err.Error()).To(Equal("test: failed to deploy to 1 of 3 containers: " +
+			"bbbbbbbbbbbb: no space left on device; deployment rolled back"))
+		Expect(err.ErrorDetails()).To(HaveKeyWithValue("Containers", results))
+	})
+
+	It("should parse build environment options", func() {
+		opts, err := container.ParseDeployOptions(url.Values{"pull-env": {"true"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.PullEnv).To(BeTrue())
+
+		header := url.Values{
+			"build-env":    {"MODE=release", "FLAGS=a=b"},
+			"build-secret": {"TOKEN=s3cr3t"},
+		}
+		Expect(opts.ParseBuildEnv(header.Encode())).To(Succeed())
+		Expect(opts.BuildEnv).To(Equal(map[string]string{"MODE": "release", "FLAGS": "a=b", "TOKEN": "s3cr3t"}))
+		Expect(opts.BuildSecrets).To(Equal([]string{"TOKEN"}))
+
+		encoded := url.Values{}
+		opts.Encode(encoded)
+		Expect(encoded).To(Equal(url.Values{"pull-env": {"true"}}), "build variables should not be encoded in URL query")
+		Expect(opts.EncodeBuildEnv()).To(Equal(url.Values{
+			"build-env":    {"FLAGS=a=b", "MODE=release"},
+			"build-secret": {"TOKEN=s3cr3t"},
+		}.Encode()))
+
+		err = opts.ParseBuildEnv(url.Values{"build-secret": {"BAD-KEY=s3cr3t"}}.Encode())
+		Expect(err).To(Equal(container.InvalidDeployOptionError{Name: "build-secret", Value: "BAD-KEY"}))
+	})
+
+	It("should report application not found", func() {
+		err := dockerCli.DeployRepo(ctx, "nonexist", NAMESPACE, bytes.NewReader(nil), container.DeployOptions{}, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(BeAssignableToTypeOf(container.NoFrameworkError("")))
+	})
+})
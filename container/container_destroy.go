@@ -4,6 +4,8 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/engine-api/types"
 	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/metrics"
 )
 
 // Destroy the application container.
@@ -12,11 +14,18 @@ func (c *Container) Destroy(ctx context.Context) error {
 
 	// remove the container, force kill if it's running
 	options := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	expectRemoval(c.ID)
 	err := c.ContainerRemove(ctx, c.ID, options)
 	if err != nil {
+		removalExpected(c.ID)
 		return err
 	}
 	logrus.Debugf("Removed container %s", c.ID)
+	indexRemoved(c)
+	metrics.ContainersDestroyed.WithLabelValues(string(c.Category())).Inc()
+
+	// remove volumes provisioned for the container
+	c.removeVolumes(ctx)
 
 	// remove associated image
 	if image != "" {
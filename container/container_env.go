@@ -4,7 +4,10 @@ import (
 	"archive/tar"
 	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -58,11 +61,93 @@ func (c *Container) Getenv(ctx context.Context, name string) (string, error) {
 	return strings.TrimRight(string(content), "\r\n"), nil
 }
 
+// Adds all variables to the environment in a single archive copy.
+func (c *Container) SetenvAll(ctx context.Context, env map[string]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+
+	// Make an archive containing all environment files
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, value := range env {
+		content := []byte(value)
+		tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0644,
+			Size: int64(len(content)),
+		})
+		tw.Write(content)
+	}
+	tw.Close()
+
+	// Copy the archive to the container at specified path
+	return c.CopyToContainer(ctx, c.ID, c.EnvDir(), buf, types.CopyToContainerOptions{})
+}
+
+// Get all environment variables in a single archive copy. Hidden variables
+// used internally are not included.
+func (c *Container) GetenvAll(ctx context.Context) (map[string]string, error) {
+	r, _, err := c.CopyFromContainer(ctx, c.ID, c.EnvDir()+"/.")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	env := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Base(hdr.Name)
+		regular := hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA
+		if !regular || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		env[name] = strings.TrimRight(string(content), "\r\n")
+	}
+	return env, nil
+}
+
+// Removes the variables from the environment.
+func (c *Container) Unsetenv(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	args := []string{"rm", "-f", "--"}
+	for _, name := range names {
+		if name == "" || strings.ContainsRune(name, '/') || name == "." || name == ".." {
+			return fmt.Errorf("Invalid environment variable name: %q", name)
+		}
+		args = append(args, c.EnvDir()+"/"+name)
+	}
+	return c.ExecE(ctx, "root", nil, nil, args...)
+}
+
 func (c *Container) ActiveState(ctx context.Context) manifest.ActiveState {
+	if c.IsCrashLooping() {
+		return manifest.StateCrashLoop
+	}
+
 	// Get active state from running processes
 	if c.State.Running {
 		state, err := c.activeStateFromRunningProcess(ctx)
 		if err == nil {
+			if state == manifest.StateRunning && c.IsUnhealthy() {
+				state = manifest.StateUnhealthy
+			}
 			return state
 		}
 	} else {
@@ -84,26 +169,14 @@ func (c *Container) ActiveState(ctx context.Context) manifest.ActiveState {
 var statePattern = regexp.MustCompile(`^/usr/bin/cwctl \[([0-9])\]`)
 
 func (c *Container) activeStateFromRunningProcess(ctx context.Context) (manifest.ActiveState, error) {
-	ps, err := c.ContainerTop(ctx, c.ID, []string{})
+	ps, err := c.Top(ctx)
 	if err != nil {
 		return manifest.StateUnknown, err
 	}
 
-	var cmdIndex = -1
-	for i, t := range ps.Titles {
-		if strings.ToUpper(t) == "COMMAND" {
-			cmdIndex = i
-			break
-		}
-	}
-	if cmdIndex == -1 {
-		return manifest.StateUnknown, errors.New("no command column in process list")
-	}
-
 	for _, p := range ps.Processes {
-		command := p[cmdIndex]
-		if m := statePattern.FindStringSubmatch(command); m != nil {
-			state, err := strconv.Atoi(command)
+		if m := statePattern.FindStringSubmatch(p.Command); m != nil {
+			state, err := strconv.Atoi(m[1])
 			return manifest.ActiveState(state), err
 		}
 	}
@@ -0,0 +1,76 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Environment", func() {
+	const NAMESPACE = "container_env_test"
+
+	var (
+		ctx = context.Background()
+		c   *container.Container
+	)
+
+	BeforeEach(func() {
+		plugin, err := pluginHub.GetPluginInfo("mock")
+		Expect(err).NotTo(HaveOccurred())
+
+		options := container.CreateOptions{
+			Name:      "test",
+			Namespace: NAMESPACE,
+			Plugin:    plugin,
+			Scaling:   1,
+		}
+		containers, err := dockerCli.Create(ctx, options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(HaveLen(1))
+		c = containers[0]
+		Expect(c.Start(ctx, nil)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(c.Destroy(ctx)).To(Succeed())
+	})
+
+	It("should set and get multiple variables", func() {
+		env := map[string]string{"FOO": "foo", "BAR": "bar", "EMPTY": ""}
+		Expect(c.SetenvAll(ctx, env)).To(Succeed())
+
+		all, err := c.GetenvAll(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		for k, v := range env {
+			Expect(all).To(HaveKeyWithValue(k, v))
+		}
+		Expect(c.Getenv(ctx, "FOO")).To(Equal("foo"))
+	})
+
+	It("should not get hidden variables", func() {
+		Expect(c.Setenv(ctx, ".hidden", "secret")).To(Succeed())
+
+		all, err := c.GetenvAll(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).NotTo(HaveKey(".hidden"))
+		Expect(all).NotTo(HaveKey(".state"))
+	})
+
+	It("should unset variables", func() {
+		Expect(c.SetenvAll(ctx, map[string]string{"FOO": "foo", "BAR": "bar", "BAZ": "baz"})).To(Succeed())
+		Expect(c.Unsetenv(ctx, "FOO", "BAR", "NONEXIST")).To(Succeed())
+
+		all, err := c.GetenvAll(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).NotTo(HaveKey("FOO"))
+		Expect(all).NotTo(HaveKey("BAR"))
+		Expect(all).To(HaveKeyWithValue("BAZ", "baz"))
+	})
+
+	It("should reject invalid variable names when unset", func() {
+		Expect(c.Unsetenv(ctx, "../repo")).NotTo(Succeed())
+		Expect(c.Unsetenv(ctx, "")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,166 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// FailurePolicy specifies how a deployment proceeds when the repository
+// failed to deploy to a container.
+type FailurePolicy int
+
+const (
+	// Continue deploying to other containers, and report the containers
+	// failed to deploy.
+	FailContinue FailurePolicy = iota
+
+	// Stop the deployment on the first failure, and restore the previous
+	// repository to containers already updated.
+	FailRollback
+)
+
+var failurePolicyString = [...]string{
+	FailContinue: "continue",
+	FailRollback: "rollback",
+}
+
+func (p FailurePolicy) String() string {
+	return failurePolicyString[p]
+}
+
+// ParseFailurePolicy parses the failure policy from string. An empty
+// string is parsed as FailContinue.
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	if s == "" {
+		return FailContinue, nil
+	}
+	for policy, str := range failurePolicyString {
+		if s == str {
+			return FailurePolicy(policy), nil
+		}
+	}
+	return FailContinue, InvalidDeployOptionError{"on-failure", s}
+}
+
+// DeployResult describes the result of deploying the repository to a
+// container.
+type DeployResult struct {
+	ID string
+
+	// The number of attempts to deploy to the container, zero if the
+	// container was skipped because the deployment stopped on failure.
+	Attempts int
+
+	// The error occurred on the last attempt.
+	Error string `json:",omitempty"`
+
+	// The previous repository is restored to the container.
+	RolledBack bool `json:",omitempty"`
+}
+
+// The delay before retrying a failed deployment to a container, increased
+// on each attempt.
+var deployRetryDelay = 2 * time.Second
+
+// deployWithRetry deploys the repository to the container, retrying failed
+// attempts as many times as DeployOptions.Retries.
+func deployWithRetry(ctx context.Context, c *Container, repodir string, opts DeployOptions, log *serverlog.ServerLog) (*DeployResult, error) {
+	res := &DeployResult{ID: c.ID}
+	for {
+		res.Attempts++
+		err := deployRepo(ctx, c, repodir, opts.manifest, log)
+		if err == nil {
+			res.Error = ""
+			return res, nil
+		}
+		res.Error = err.Error()
+		if res.Attempts > opts.Retries || ctx.Err() != nil {
+			return res, err
+		}
+
+		if log != nil {
+			fmt.Fprintf(log.Stderr(), "Retrying deployment to container %s: %v\n", shortID(c.ID), err)
+		}
+		select {
+		case <-time.After(time.Duration(res.Attempts) * deployRetryDelay):
+		case <-ctx.Done():
+			return res, err
+		}
+	}
+}
+
+// snapshotRepo saves the repository deployed to the container, so it can
+// be deployed to other containers. The returned directory must be removed
+// after use.
+func snapshotRepo(ctx context.Context, from *Container) (string, error) {
+	r, _, err := from.CopyFromContainer(ctx, from.ID, from.RepoDir()+"/.")
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	repodir, err := PrepareRepo(r, true)
+	if err != nil && repodir != "" {
+		os.RemoveAll(repodir)
+		repodir = ""
+	}
+	return repodir, err
+}
+
+// prepareRollback saves the current repository of containers if the
+// deployment rolls back on failure. Returns an empty directory if the
+// repository can't be saved, and the deployment stops on failure without
+// rolling back.
+func prepareRollback(ctx context.Context, targets []*Container, opts DeployOptions, log *serverlog.ServerLog) string {
+	if opts.OnFailure != FailRollback {
+		return ""
+	}
+	snapshot, err := snapshotRepo(ctx, targets[0])
+	if err != nil && log != nil {
+		fmt.Fprintf(log.Stderr(), "WARNING: failed to save current repository, deployment can't be rolled back: %v\n", err)
+	}
+	return snapshot
+}
+
+// rollbackDeployed restores the previous repository to containers the
+// deployment attempted, and marks the results of restored containers.
+// Returns true if all containers are restored.
+func rollbackDeployed(ctx context.Context, snapshot string, targets []*Container, results []*DeployResult, log *serverlog.ServerLog) bool {
+	if snapshot == "" {
+		return false
+	}
+
+	var attempted []*Container
+	for i, c := range targets {
+		if results[i] != nil && results[i].Attempts != 0 {
+			attempted = append(attempted, c)
+		}
+	}
+	if len(attempted) == 0 {
+		return true
+	}
+
+	if log != nil {
+		fmt.Fprintf(log, "Rolling back %d containers to the previous repository\n", len(attempted))
+	}
+	err := deployAll(ctx, attempted, snapshot, DeployOptions{}, log)
+
+	var failed map[string]error
+	if derr, ok := err.(DistributeError); ok {
+		failed = derr.Errors
+	} else if err != nil {
+		return false
+	}
+	for i, c := range targets {
+		if results[i] != nil && results[i].Attempts != 0 && failed[c.ID] == nil {
+			results[i].RolledBack = true
+			reportDeploy(log, c, DeployEventRolledBack, 0, nil)
+		}
+	}
+	return len(failed) == 0
+}
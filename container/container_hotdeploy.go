@@ -0,0 +1,134 @@
+package container
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/delta"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// chooseDeploy decides whether the repository is hot deployed or deployed
+// with a full build, by matching files changed since the previous deployment
+// against deploy rules declared in the plugin manifest. Files not matched
+// by any rule are deployed by the default mode of the framework. All files
+// are changed for the first deployment.
+//
+// A hot deployed repository is merged with the repository deployed to the
+// base container, so outputs of the previous build are kept. Files removed
+// from the repository are kept until the next full build.
+func chooseDeploy(ctx context.Context, base *Container, repodir string, rules []*manifest.DeployRule, hot bool, log *serverlog.ServerLog) (bool, error) {
+	target := scanRepo(repodir)
+	if target == nil {
+		return hot, nil
+	}
+	deployed, _ := base.readRepoManifest(ctx)
+
+	def := manifest.DeployBuild
+	if hot {
+		def = manifest.DeployHot
+	}
+	for _, name := range delta.Changed(deployed, target) {
+		if manifest.DeployMode(rules, name, def) == manifest.DeployBuild {
+			if log != nil {
+				fmt.Fprintf(log, "Full build required by changed file %s\n", name)
+			}
+			return false, nil
+		}
+	}
+
+	if log != nil {
+		fmt.Fprintln(log, "Hot deploying changed files")
+	}
+	if deployed != nil {
+		if err := mergeDeployedRepo(ctx, base, repodir, target); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// mergeDeployedRepo appends entries of the repository deployed to the base
+// container that are not in the new repository to the repository archive.
+func mergeDeployedRepo(ctx context.Context, base *Container, repodir string, target delta.Manifest) (err error) {
+	deployed, _, err := base.CopyFromContainer(ctx, base.ID, base.RepoDir()+"/.")
+	if err != nil {
+		return err
+	}
+	defer deployed.Close()
+
+	in, err := os.Open(repoArchive(repodir))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+
+	out, err := ioutil.TempFile("", "merge")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	tw := tar.NewWriter(zw)
+	if err = copyEntries(tw, tar.NewReader(zr), nil); err != nil {
+		return err
+	}
+	if err = copyEntries(tw, tar.NewReader(deployed), target); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), repoArchive(repodir))
+}
+
+// copyEntries copies entries of the tar stream that are not in the skip
+// manifest. The root directory is skipped unless all entries are copied.
+func copyEntries(tw *tar.Writer, tr *tar.Reader, skip delta.Manifest) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if _, ok := skip[name]; ok || (skip != nil && name == ".") {
+			continue
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
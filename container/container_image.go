@@ -0,0 +1,75 @@
+package container
+
+import (
+	"fmt"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// BuildImageMismatchError reports that the image of a container is not
+// built from the base image declared by the plugin.
+type BuildImageMismatchError struct {
+	Image    string
+	Expected string
+	Actual   string
+}
+
+func (e BuildImageMismatchError) Error() string {
+	return fmt.Sprintf("Image %s is built from %s, but the plugin declares base image %s",
+		shortImageID(e.Image), e.Actual, e.Expected)
+}
+
+func shortImageID(id string) string {
+	if len(id) > 19 && id[:7] == "sha256:" {
+		return id[7:19]
+	}
+	return id
+}
+
+// ResolveBuildImage resolves the image used to create builder container for
+// the base container. The base container's image is used if it's built from
+// the base image declared in plugin manifest. Otherwise, the most recent
+// image built for the plugin from the declared base image is used, and a
+// BuildImageMismatchError is returned along with the resolved image to report
+// the mismatch. If no such image is found then the base container's image is
+// used as a last resort.
+func ResolveBuildImage(ctx context.Context, base *Container, plugin *manifest.Plugin) (string, error) {
+	image := base.Config.Image
+	info, _, err := base.ImageInspectWithRaw(ctx, image, false)
+	if err != nil {
+		return image, err
+	}
+
+	// images built before base image was recorded can't be verified
+	var actual string
+	if info.Config != nil {
+		actual = info.Config.Labels[IMAGE_BASE_KEY]
+	}
+	if actual == "" || actual == plugin.BaseImage {
+		return image, nil
+	}
+
+	mismatch := BuildImageMismatchError{Image: image, Expected: plugin.BaseImage, Actual: actual}
+
+	args := filters.NewArgs()
+	args.Add("label", IMAGE_BASE_KEY+"="+plugin.BaseImage)
+	args.Add("label", IMAGE_PLUGIN_KEY+"="+plugin.Tag)
+	images, err := base.ImageList(ctx, types.ImageListOptions{Filters: args})
+	if err == nil {
+		var latest *types.Image
+		for i := range images {
+			if latest == nil || images[i].Created > latest.Created {
+				latest = &images[i]
+			}
+		}
+		if latest != nil {
+			image = latest.ID
+		}
+	}
+
+	return image, mismatch
+}
@@ -0,0 +1,81 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Build image", func() {
+	const NAMESPACE = "container_image_test"
+
+	var (
+		ctx        = context.Background()
+		plugin     *manifest.Plugin
+		drifted    manifest.Plugin
+		base       *container.Container
+		containers []*container.Container
+	)
+
+	BeforeEach(func() {
+		var err error
+		plugin, err = pluginHub.GetPluginInfo("mockb")
+		Expect(err).NotTo(HaveOccurred())
+
+		drifted = *plugin
+		drifted.BaseImage = "debian:8"
+
+		containers, err = dockerCli.Create(ctx, container.CreateOptions{
+			Name:      "test",
+			Namespace: NAMESPACE,
+			Plugin:    plugin,
+			Scaling:   1,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(HaveLen(1))
+		base = containers[0]
+	})
+
+	AfterEach(func() {
+		for _, c := range containers {
+			Expect(c.Destroy(ctx)).To(Succeed())
+		}
+		containers = nil
+	})
+
+	It("should use the base container image when it matches plugin manifest", func() {
+		image, err := container.ResolveBuildImage(ctx, base, plugin)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal(base.Config.Image))
+	})
+
+	It("should report mismatch when no image built from declared base image", func() {
+		image, err := container.ResolveBuildImage(ctx, base, &drifted)
+		Expect(err).To(Equal(container.BuildImageMismatchError{
+			Image:    base.Config.Image,
+			Expected: "debian:8",
+			Actual:   "debian:jessie",
+		}))
+		Expect(image).To(Equal(base.Config.Image))
+	})
+
+	It("should use the image built from declared base image on mismatch", func() {
+		others, err := dockerCli.Create(ctx, container.CreateOptions{
+			Name:      "other",
+			Namespace: NAMESPACE,
+			Plugin:    &drifted,
+			Scaling:   1,
+		})
+		containers = append(containers, others...)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(others).To(HaveLen(1))
+
+		image, err := container.ResolveBuildImage(ctx, base, &drifted)
+		Expect(err).To(BeAssignableToTypeOf(container.BuildImageMismatchError{}))
+		Expect(image).To(Equal(others[0].Config.Image))
+		Expect(image).NotTo(Equal(base.Config.Image))
+	})
+})
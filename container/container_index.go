@@ -0,0 +1,230 @@
+package container
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// containerIndex indexes application containers on a Docker host by
+// namespace, so containers of an application are found without listing
+// all containers on the host. The index is loaded on first use and kept
+// up to date by Docker events. Only labels are indexed, the state of found
+// containers is always inspected.
+type containerIndex struct {
+	mu    sync.Mutex
+	ready bool
+	epoch int
+
+	// labels and creation time of containers, keyed by namespace and
+	// container ID
+	namespaces map[string]map[string]*indexEntry
+}
+
+type indexEntry struct {
+	labels  map[string]string
+	created int64
+}
+
+var indexes = struct {
+	sync.Mutex
+	m map[*client.Client]*containerIndex
+}{m: make(map[*client.Client]*containerIndex)}
+
+// indexOf returns the container index of the Docker host.
+func indexOf(cli DockerClient) *containerIndex {
+	indexes.Lock()
+	defer indexes.Unlock()
+	idx := indexes.m[cli.Client]
+	if idx == nil {
+		idx = &containerIndex{}
+		indexes.m[cli.Client] = idx
+	}
+	return idx
+}
+
+// lookup returns IDs of containers matching labels, the newest first.
+func (idx *containerIndex) lookup(cli DockerClient, ctx context.Context, category manifest.Category, service, name, namespace string) ([]string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.ready {
+		if err := idx.load(cli, ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var found []*indexEntry
+	var ids []string
+	match := func(id string, e *indexEntry) {
+		if (category == "" || e.labels[CATEGORY_KEY] == string(category)) &&
+			(service == "" || e.labels[SERVICE_NAME_KEY] == service) &&
+			(name == "" || e.labels[APP_NAME_KEY] == name) {
+			found = append(found, e)
+			ids = append(ids, id)
+		}
+	}
+	if namespace != "" {
+		for id, e := range idx.namespaces[namespace] {
+			match(id, e)
+		}
+	} else {
+		for _, entries := range idx.namespaces {
+			for id, e := range entries {
+				match(id, e)
+			}
+		}
+	}
+
+	sort.Sort(byCreated{ids, found})
+	return ids, nil
+}
+
+type byCreated struct {
+	ids     []string
+	entries []*indexEntry
+}
+
+func (s byCreated) Len() int {
+	return len(s.ids)
+}
+
+func (s byCreated) Less(i, j int) bool {
+	if s.entries[i].created != s.entries[j].created {
+		return s.entries[i].created > s.entries[j].created
+	}
+	return s.ids[i] < s.ids[j]
+}
+
+func (s byCreated) Swap(i, j int) {
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+}
+
+// load watches container events and lists application containers on the
+// Docker host. Events are watched before listing so no container created
+// during the listing is missed, they are applied after the listing as the
+// index is locked.
+func (idx *containerIndex) load(cli DockerClient, ctx context.Context) error {
+	args := filters.NewArgs()
+	args.Add("type", "container")
+	args.Add("event", "create")
+	args.Add("event", "destroy")
+	args.Add("label", APP_NAME_KEY)
+
+	resp, err := cli.Events(context.Background(), types.EventsOptions{Filters: args})
+	if err != nil {
+		return err
+	}
+
+	args = filters.NewArgs()
+	args.Add("label", APP_NAME_KEY)
+	args.Add("label", APP_NAMESPACE_KEY)
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+	if err != nil {
+		resp.Close()
+		return err
+	}
+
+	idx.epoch++
+	idx.namespaces = make(map[string]map[string]*indexEntry)
+	for _, c := range list {
+		idx.add(c.ID, c.Labels, c.Created)
+	}
+	idx.ready = true
+
+	go idx.watch(resp, idx.epoch)
+	return nil
+}
+
+// watch applies container events to the index until the event stream is
+// broken, then the index is reloaded on next lookup.
+func (idx *containerIndex) watch(resp io.ReadCloser, epoch int) {
+	defer resp.Close()
+
+	dec := json.NewDecoder(resp)
+	for {
+		var event events.Message
+		if err := dec.Decode(&event); err != nil {
+			logrus.WithError(err).Debug("Container events stream closed, invalidating container index")
+			idx.mu.Lock()
+			if idx.epoch == epoch {
+				idx.ready = false
+				idx.namespaces = nil
+			}
+			idx.mu.Unlock()
+			return
+		}
+
+		idx.mu.Lock()
+		if idx.epoch == epoch {
+			switch event.Action {
+			case "create":
+				idx.add(event.Actor.ID, event.Actor.Attributes, event.Time)
+			case "destroy":
+				idx.remove(event.Actor.ID)
+			}
+		}
+		idx.mu.Unlock()
+	}
+}
+
+// add indexes the container by labels, containers without application
+// labels are ignored. The index must be locked.
+func (idx *containerIndex) add(id string, labels map[string]string, created int64) {
+	name, namespace := labels[APP_NAME_KEY], labels[APP_NAMESPACE_KEY]
+	if idx.namespaces == nil || name == "" || namespace == "" {
+		return
+	}
+	entries := idx.namespaces[namespace]
+	if entries == nil {
+		entries = make(map[string]*indexEntry)
+		idx.namespaces[namespace] = entries
+	}
+	entries[id] = &indexEntry{labels: labels, created: created}
+}
+
+// remove removes the container from the index. The index must be locked.
+func (idx *containerIndex) remove(id string) {
+	for namespace, entries := range idx.namespaces {
+		if _, ok := entries[id]; ok {
+			delete(entries, id)
+			if len(entries) == 0 {
+				delete(idx.namespaces, namespace)
+			}
+			return
+		}
+	}
+}
+
+// indexCreated adds a container just created to the index of the Docker
+// host, so it's found before the create event arrives.
+func indexCreated(c *Container) {
+	created, _ := time.Parse(time.RFC3339Nano, c.Created)
+	idx := indexOf(c.DockerClient)
+	idx.mu.Lock()
+	if idx.ready {
+		idx.add(c.ID, c.Config.Labels, created.Unix())
+	}
+	idx.mu.Unlock()
+}
+
+// indexRemoved removes a container just removed from the index of the
+// Docker host.
+func indexRemoved(c *Container) {
+	idx := indexOf(c.DockerClient)
+	idx.mu.Lock()
+	idx.remove(c.ID)
+	idx.mu.Unlock()
+}
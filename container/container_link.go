@@ -0,0 +1,138 @@
+package container
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/hub"
+)
+
+// The connection variables injected into framework containers for each
+// service in the application.
+var linkSuffixes = []string{"HOST", "PORT", "USERNAME", "PASSWORD"}
+
+// LinkEnvNames returns names of connection variables of the service.
+func LinkEnvNames(service string) []string {
+	prefix := linkPrefix(service)
+	names := make([]string, len(linkSuffixes))
+	for i, suffix := range linkSuffixes {
+		names[i] = prefix + suffix
+	}
+	return names
+}
+
+func linkPrefix(service string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, service) + "_"
+}
+
+// LinkEnv derives connection variables of the service from environment
+// variables exported by the service plugin. The variable <SERVICE>_HOST
+// is taken from the exported variable CLOUDWAY_<PLUGIN>_HOST, or the
+// shortest variable CLOUDWAY_<PLUGIN>_..._HOST, and so on for PORT,
+// USERNAME and PASSWORD.
+func LinkEnv(service, plugin string, env map[string]string) map[string]string {
+	pluginPrefix := "CLOUDWAY_" + strings.ToUpper(plugin) + "_"
+
+	var keys []string
+	for k := range env {
+		if strings.HasPrefix(k, pluginPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	link := make(map[string]string)
+	prefix := linkPrefix(service)
+	for _, suffix := range linkSuffixes {
+		var found string
+		for _, k := range keys {
+			name := k[len(pluginPrefix):]
+			if name != suffix && !strings.HasSuffix(name, "_"+suffix) {
+				continue
+			}
+			if found == "" || len(k) < len(found) {
+				found = k
+			}
+		}
+		if found != "" {
+			link[prefix+suffix] = env[found]
+		}
+	}
+	return link
+}
+
+// linkEnv returns connection variables of the service container. The host
+// is the DNS name of the service if service discovery is enabled, so it's
+// not stale when the service container is replaced.
+func (c *Container) linkEnv(env map[string]string) map[string]string {
+	_, _, plugin, _, err := hub.ParseTag(c.PluginTag())
+	if err != nil || c.ServiceName() == "" {
+		return nil
+	}
+	link := LinkEnv(c.ServiceName(), plugin, env)
+	host := linkPrefix(c.ServiceName()) + "HOST"
+	if name := c.DNSName(); name != "" && link[host] != "" {
+		link[host] = name
+	}
+	return link
+}
+
+// linkServices copies variables exported by running service containers to
+// the framework container, including the connection variables, so that a
+// new or replaced framework container connects to services without
+// restarting them.
+func linkServices(ctx context.Context, c *Container) error {
+	if !c.Category().IsFramework() {
+		return nil
+	}
+
+	cs, err := c.FindAll(ctx, c.Name, c.Namespace)
+	if err != nil {
+		return err
+	}
+
+	env := make(map[string]string)
+	for _, cc := range cs {
+		if !cc.Category().IsService() || cc.State == nil || !cc.State.Running {
+			continue
+		}
+		info, err := cc.GetInfo(ctx, "env")
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to get environment of service %s", cc.ServiceName())
+			continue
+		}
+		for k, v := range info.Env {
+			env[k] = v
+		}
+		for k, v := range cc.linkEnv(info.Env) {
+			env[k] = v
+		}
+	}
+	return c.SetenvAll(ctx, env)
+}
+
+// UnlinkService removes connection variables of the service from framework
+// containers after the service is removed.
+func UnlinkService(ctx context.Context, cs []*Container, service string) error {
+	names := LinkEnvNames(service)
+	for _, c := range cs {
+		if c.Category().IsFramework() && c.State != nil && c.State.Running {
+			if err := c.Unsetenv(ctx, names...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Service link", func() {
+	It("should derive connection variables from exported variables", func() {
+		env := map[string]string{
+			"CLOUDWAY_MYSQL_DB_HOST":       "172.17.0.2",
+			"CLOUDWAY_MYSQL_DB_PORT":       "3306",
+			"CLOUDWAY_MYSQL_ADMIN_PORT":    "8080",
+			"CLOUDWAY_MYSQL_DB_USERNAME":   "admin",
+			"CLOUDWAY_MYSQL_DB_PASSWORD":   "secret",
+			"CLOUDWAY_MYSQL_DB_PORT_EXTRA": "ignored",
+			"CLOUDWAY_OTHER_HOST":          "ignored",
+		}
+		Expect(container.LinkEnv("my-db", "mysql", env)).To(Equal(map[string]string{
+			"MY_DB_HOST":     "172.17.0.2",
+			"MY_DB_PORT":     "3306",
+			"MY_DB_USERNAME": "admin",
+			"MY_DB_PASSWORD": "secret",
+		}))
+	})
+
+	It("should prefer variables without qualifier", func() {
+		env := map[string]string{
+			"CLOUDWAY_MOCKDB_HOST":       "172.17.0.3",
+			"CLOUDWAY_MOCKDB_PORT":       "1234",
+			"CLOUDWAY_MOCKDB_ADMIN_PORT": "8080",
+		}
+		Expect(container.LinkEnv("mockdb", "mockdb", env)).To(Equal(map[string]string{
+			"MOCKDB_HOST": "172.17.0.3",
+			"MOCKDB_PORT": "1234",
+		}))
+		Expect(container.LinkEnvNames("mockdb")).To(Equal([]string{
+			"MOCKDB_HOST", "MOCKDB_PORT", "MOCKDB_USERNAME", "MOCKDB_PASSWORD",
+		}))
+	})
+})
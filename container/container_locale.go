@@ -0,0 +1,65 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+type InvalidTimeZoneError string
+
+func (e InvalidTimeZoneError) Error() string {
+	return fmt.Sprintf("Unknown time zone: %s", string(e))
+}
+
+func (e InvalidTimeZoneError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type InvalidLocaleError string
+
+func (e InvalidLocaleError) Error() string {
+	return fmt.Sprintf("Invalid locale: %s", string(e))
+}
+
+func (e InvalidLocaleError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ValidateTimeZone checks the time zone is a known IANA time zone name,
+// such as "UTC" or "Asia/Shanghai". An empty time zone is valid.
+func ValidateTimeZone(tz string) error {
+	if tz == "" {
+		return nil
+	}
+	if tz == "Local" {
+		return InvalidTimeZoneError(tz)
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return InvalidTimeZoneError(tz)
+	}
+	return nil
+}
+
+var localePattern = regexp.MustCompile(`^(C|POSIX|[a-z]{2,3}(_[A-Z]{2})?)(\.[A-Za-z0-9-]+)?(@[a-z]+)?$`)
+
+// ValidateLocale checks the locale is a well formed locale name, such as
+// "en_US.UTF-8". An empty locale is valid.
+func ValidateLocale(locale string) error {
+	if locale != "" && !localePattern.MatchString(locale) {
+		return InvalidLocaleError(locale)
+	}
+	return nil
+}
+
+// Adds time zone and locale settings into container environment.
+func configureLocale(cfg *createConfig) {
+	if cfg.TimeZone != "" {
+		cfg.Env["TZ"] = cfg.TimeZone
+	}
+	if cfg.Locale != "" {
+		cfg.Env["LANG"] = cfg.Locale
+		cfg.Env["LC_ALL"] = cfg.Locale
+	}
+}
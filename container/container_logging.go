@@ -0,0 +1,185 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/go-units"
+
+	"github.com/cloudway/platform/config"
+)
+
+// The log rotation applied to json-file logs unless configured, so hosts
+// don't fill up with unbounded container logs.
+const (
+	defaultLogMaxSize = "10m"
+	defaultLogMaxFile = "3"
+)
+
+// The supported log drivers and their options.
+var logDrivers = map[string][]string{
+	"json-file": {"max-size", "max-file", "labels", "env"},
+	"syslog":    {"syslog-address", "syslog-facility", "syslog-format", "tag", "labels", "env"},
+	"fluentd":   {"fluentd-address", "fluentd-async-connect", "fluentd-buffer-limit", "fluentd-retry-wait", "fluentd-max-retries", "tag", "labels", "env"},
+	"gelf":      {"gelf-address", "gelf-compression-type", "gelf-compression-level", "tag", "labels", "env"},
+}
+
+// LogConfig contains the log driver and options of a container.
+type LogConfig struct {
+	Driver  string
+	Options map[string]string
+}
+
+// InvalidLogConfigError reports an unsupported log driver or option.
+type InvalidLogConfigError string
+
+func (e InvalidLogConfigError) Error() string {
+	return string(e)
+}
+
+func (e InvalidLogConfigError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// LogDrivers returns names of the supported log drivers.
+func LogDrivers() []string {
+	names := make([]string, 0, len(logDrivers))
+	for name := range logDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the log driver and options are supported. An empty log
+// configuration uses the default configuration.
+func (l LogConfig) Validate() error {
+	if l.Driver == "" {
+		if len(l.Options) != 0 {
+			return InvalidLogConfigError("Log options require a log driver")
+		}
+		return nil
+	}
+
+	allowed, ok := logDrivers[l.Driver]
+	if !ok {
+		return InvalidLogConfigError(fmt.Sprintf("Unsupported log driver %s, must be one of %s",
+			l.Driver, strings.Join(LogDrivers(), ", ")))
+	}
+	for key, value := range l.Options {
+		if !containsString(allowed, key) {
+			return InvalidLogConfigError(fmt.Sprintf("Unsupported option %s of log driver %s", key, l.Driver))
+		}
+		switch key {
+		case "max-size":
+			if n, err := units.RAMInBytes(value); err != nil || n <= 0 {
+				return InvalidLogConfigError("Invalid max-size of logs: " + value)
+			}
+		case "max-file":
+			if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+				return InvalidLogConfigError("Invalid max-file of logs: " + value)
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultLogConfig returns the log configuration of containers configured
+// by the log section, which contains the driver and options of the driver.
+// Logs are written by json-file driver with rotation by default.
+func DefaultLogConfig() LogConfig {
+	var l LogConfig
+	for key, value := range config.GetSection("log") {
+		if key == "driver" {
+			l.Driver = value
+		} else {
+			if l.Options == nil {
+				l.Options = make(map[string]string)
+			}
+			l.Options[key] = value
+		}
+	}
+	if l.Driver == "" {
+		l.Driver = "json-file"
+	}
+	if err := l.Validate(); err != nil {
+		logrus.WithError(err).Warn("Invalid log configuration, using json-file driver")
+		l = LogConfig{Driver: "json-file"}
+	}
+	return l.withRotation()
+}
+
+// Merge returns the log configuration overridden by the other. Options are
+// merged if the driver is not changed.
+func (l LogConfig) Merge(other *LogConfig) LogConfig {
+	if other == nil || other.Driver == "" {
+		return l
+	}
+
+	merged := LogConfig{Driver: other.Driver, Options: make(map[string]string)}
+	if l.Driver == other.Driver {
+		for k, v := range l.Options {
+			merged.Options[k] = v
+		}
+	}
+	for k, v := range other.Options {
+		merged.Options[k] = v
+	}
+	return merged.withRotation()
+}
+
+// withRotation adds default rotation options to json-file logs.
+func (l LogConfig) withRotation() LogConfig {
+	if l.Driver != "json-file" {
+		return l
+	}
+	opts := make(map[string]string, len(l.Options)+2)
+	for k, v := range l.Options {
+		opts[k] = v
+	}
+	if opts["max-size"] == "" {
+		opts["max-size"] = defaultLogMaxSize
+	}
+	if opts["max-file"] == "" {
+		opts["max-file"] = defaultLogMaxFile
+	}
+	l.Options = opts
+	return l
+}
+
+// LogConfig returns the log configuration of the container. An empty
+// configuration is returned if the container was created with a log driver
+// not supported, such as the default driver of the Docker daemon.
+func (c *Container) LogConfig() LogConfig {
+	var l LogConfig
+	if hc := c.HostConfig; hc != nil {
+		l.Driver = hc.LogConfig.Type
+		l.Options = hc.LogConfig.Config
+	}
+	if l.Validate() != nil {
+		return LogConfig{}
+	}
+	return l
+}
+
+func setLogConfig(hc *container.HostConfig, l LogConfig) {
+	if l.Driver == "" {
+		l = DefaultLogConfig()
+	}
+	l = l.withRotation()
+	hc.LogConfig = container.LogConfig{Type: l.Driver, Config: l.Options}
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Logging", func() {
+	It("should rotate json-file logs by default", func() {
+		l := container.DefaultLogConfig()
+		Expect(l.Driver).To(Equal("json-file"))
+		Expect(l.Options).To(HaveKeyWithValue("max-size", "10m"))
+		Expect(l.Options).To(HaveKeyWithValue("max-file", "3"))
+	})
+
+	It("should merge options of the same driver", func() {
+		base := container.DefaultLogConfig()
+		l := base.Merge(&container.LogConfig{Driver: "json-file", Options: map[string]string{"max-file": "5"}})
+		Expect(l.Options).To(Equal(map[string]string{"max-size": "10m", "max-file": "5"}))
+
+		l = base.Merge(&container.LogConfig{Driver: "syslog", Options: map[string]string{"tag": "app"}})
+		Expect(l).To(Equal(container.LogConfig{Driver: "syslog", Options: map[string]string{"tag": "app"}}))
+
+		Expect(base.Merge(nil)).To(Equal(base))
+	})
+
+	It("should reject unsupported drivers and options", func() {
+		for _, l := range []container.LogConfig{
+			{Driver: "journald"},
+			{Driver: "json-file", Options: map[string]string{"syslog-address": "udp://localhost"}},
+			{Driver: "json-file", Options: map[string]string{"max-size": "lots"}},
+			{Driver: "json-file", Options: map[string]string{"max-file": "0"}},
+			{Options: map[string]string{"tag": "app"}},
+		} {
+			Expect(l.Validate()).NotTo(Succeed())
+		}
+		l := container.LogConfig{Driver: "gelf", Options: map[string]string{"gelf-address": "udp://localhost:12201"}}
+		Expect(l.Validate()).To(Succeed())
+	})
+})
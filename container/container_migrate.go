@@ -0,0 +1,90 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// MigrationError reports that the database migration of an application
+// failed or timed out.
+type MigrationError struct {
+	Name string
+	Err  error
+}
+
+func (e MigrationError) Error() string {
+	return fmt.Sprintf("%s: migration failed: %v", e.Name, e.Err)
+}
+
+// migrationLocks serializes migrations of each application, so concurrent
+// deployments never migrate the same database at the same time.
+var migrationLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+func lockMigration(key string) func() {
+	migrationLocks.Lock()
+	mu := migrationLocks.m[key]
+	if mu == nil {
+		mu = new(sync.Mutex)
+		migrationLocks.m[key] = mu
+	}
+	migrationLocks.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// migrate runs the database migration once for the deployment, in a one-off
+// container created from the base container with the environment of the
+// base container and the repository to be deployed. The migration is killed
+// if it doesn't finish in the timeout.
+func (cli DockerClient) migrate(ctx context.Context, base *Container, repodir string, m *manifest.Migration, log *serverlog.ServerLog) (err error) {
+	unlock := lockMigration(base.Name + "-" + base.Namespace)
+	defer unlock()
+
+	timeout := m.GetTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+			err = MigrationError{Name: base.Name, Err: err}
+		}
+	}()
+
+	if log != nil {
+		fmt.Fprintf(log, "Running migration: %s\n", m.Command)
+	}
+
+	migrator, _, remove, err := startBuilder(cli, ctx, base, false, log)
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	if err = CopyEnv(ctx, base, migrator); err != nil {
+		return err
+	}
+
+	repo, err := os.Open(repoArchive(repodir))
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	err = migrator.Exec(ctx, "", repo, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "migrate")
+	if err == nil && log != nil {
+		fmt.Fprintln(log, "Migration succeeded")
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The timeout of exec and HTTP deploy notifications if not declared by
+// the plugin.
+var deployNotifyTimeout = 30 * time.Second
+
+// notifyDeployed notifies the container to complete the deployment, with
+// the method declared in the plugin manifest of the container. Containers
+// are signaled with SIGHUP if no notification is declared or the manifest
+// can't be read.
+func notifyDeployed(ctx context.Context, c *Container) error {
+	var notify *manifest.DeployNotify
+	if meta, err := readPluginManifestFromContainer(ctx, c); err == nil && meta.DeployNotify != nil {
+		if err = manifest.ValidateDeployNotify(meta.DeployNotify); err != nil {
+			logrus.WithError(err).Warnf("Ignored deploy notification of %s", c.FQDN())
+		} else {
+			notify = meta.DeployNotify
+		}
+	}
+
+	if notify == nil || notify.Type == manifest.NotifySignal {
+		if err := markDeployed(ctx, c); err != nil {
+			return err
+		}
+		return c.ContainerKill(ctx, c.ID, notify.DeploySignal())
+	}
+
+	timeout := deployNotifyTimeout
+	if notify.Timeout != "" {
+		timeout, _ = time.ParseDuration(notify.Timeout)
+	}
+	if notify.Type == manifest.NotifyHTTP {
+		return postDeployNotify(c, notify, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := c.ExecQ(ctx, "", "/usr/bin/cwctl", "sh", "sh", "-c", notify.Command)
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("deploy notification timed out after %s", timeout)
+	}
+	return err
+}
+
+// markDeployed writes a new deployment marker to the container before the
+// sandbox is signaled to restart. The sandbox copies the marker to the
+// started marker after restarted, so a running state from before the
+// restart is never taken as the result of the deployment.
+func markDeployed(ctx context.Context, c *Container) error {
+	marker := strconv.FormatInt(time.Now().UnixNano(), 36)
+	script := fmt.Sprintf("echo %[2]s > %[1]s/.deployed && touch %[1]s/.started && chown %[3]s %[1]s/.started",
+		c.EnvDir(), marker, c.User())
+	return c.ExecQ(ctx, "root", "/bin/sh", "-c", script)
+}
+
+// restarted returns true if the sandbox has restarted after the latest
+// deployment marker was written, or if no marker was written.
+func (c *Container) restarted(ctx context.Context) bool {
+	deployed, err := c.Getenv(ctx, ".deployed")
+	if err != nil || deployed == "" {
+		return true
+	}
+	started, _ := c.Getenv(ctx, ".started")
+	return started == deployed
+}
+
+// postDeployNotify posts to the HTTP endpoint declared by the plugin. The
+// notification fails if the response status isn't 2xx.
+func postDeployNotify(c *Container, notify *manifest.DeployNotify, timeout time.Duration) error {
+	addr := net.JoinHostPort(c.IP(), strconv.Itoa(int(notify.Port)))
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post("http://"+addr+notify.Path, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"path"
+
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// ReloadManifest replaces the plugin manifest in the container. A running
+// container re-reads the manifest immediately, and environment variables
+// exported by service plugin are distributed again. A stopped container
+// picks up the manifest when started.
+func (c *Container) ReloadManifest(ctx context.Context, data []byte, log *serverlog.ServerLog) error {
+	_, _, pn, _, err := hub.ParseTag(c.PluginTag())
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	hdr := &tar.Header{
+		Name: path.Base(manifest.ManifestEntry),
+		Mode: 0644,
+		Size: int64(len(data)),
+	}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	dir := c.Home() + "/" + pn + "/" + path.Dir(manifest.ManifestEntry)
+	if err = c.CopyToContainer(ctx, c.ID, dir, buf, types.CopyToContainerOptions{}); err != nil {
+		return err
+	}
+
+	if !c.State.Running || c.State.Paused {
+		return nil
+	}
+
+	err = c.Exec(ctx, "root", nil, log.Stdout(), log.Stderr(), "/usr/bin/cwctl", "reload")
+	if err != nil {
+		return err
+	}
+
+	info, err := c.GetInfo(ctx, "env")
+	if err != nil {
+		return err
+	}
+	return distributeEnv(ctx, c, info.Env)
+}
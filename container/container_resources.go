@@ -0,0 +1,160 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types/container"
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The CPU CFS scheduler period used to limit CPUs of a container.
+const cpuPeriod = 100000
+
+// Resources contains resource limits of a container. A zero value of a
+// limit means unlimited, or not specified when overriding other limits.
+type Resources struct {
+	Memory    int64
+	CPUs      float64
+	PidsLimit int64
+}
+
+// Merge returns the resources overridden by non-zero limits of the other.
+func (r Resources) Merge(other Resources) Resources {
+	if other.Memory != 0 {
+		r.Memory = other.Memory
+	}
+	if other.CPUs != 0 {
+		r.CPUs = other.CPUs
+	}
+	if other.PidsLimit != 0 {
+		r.PidsLimit = other.PidsLimit
+	}
+	return r
+}
+
+// InvalidResourcesError reports invalid resource limits declared in plugin
+// manifest.
+type InvalidResourcesError struct {
+	Plugin string
+	Reason string
+}
+
+func (e InvalidResourcesError) Error() string {
+	return fmt.Sprintf("Invalid resources declared in plugin %s: %s", e.Plugin, e.Reason)
+}
+
+func (e InvalidResourcesError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// DefaultResources returns the resource limits of containers that not
+// declared in plugin manifest, configured by container.memory,
+// container.cpus and container.pids-limit.
+func DefaultResources() Resources {
+	var r Resources
+	var err error
+	if s := config.Get("container.memory"); s != "" {
+		if r.Memory, err = units.RAMInBytes(s); err != nil || r.Memory < 0 {
+			logrus.Warnf("Invalid container.memory setting: %s", s)
+			r.Memory = 0
+		}
+	}
+	if s := config.Get("container.cpus"); s != "" {
+		if r.CPUs, err = strconv.ParseFloat(s, 64); err != nil || r.CPUs < 0 {
+			logrus.Warnf("Invalid container.cpus setting: %s", s)
+			r.CPUs = 0
+		}
+	}
+	if s := config.Get("container.pids-limit"); s != "" {
+		if r.PidsLimit, err = strconv.ParseInt(s, 10, 64); err != nil || r.PidsLimit < 0 {
+			logrus.Warnf("Invalid container.pids-limit setting: %s", s)
+			r.PidsLimit = 0
+		}
+	}
+	return r
+}
+
+// ValidateResources checks the resource limits declared in plugin manifest.
+func ValidateResources(plugin *manifest.Plugin) error {
+	_, err := parseResources(plugin)
+	return err
+}
+
+// PluginResources returns the resource limits of plugin containers, which
+// are declared in plugin manifest and default to DefaultResources.
+func PluginResources(plugin *manifest.Plugin) Resources {
+	r, err := parseResources(plugin)
+	if err != nil {
+		logrus.WithError(err).Warn("Ignored invalid plugin resources")
+	}
+	return DefaultResources().Merge(r)
+}
+
+func parseResources(plugin *manifest.Plugin) (r Resources, err error) {
+	if plugin.Resources == nil {
+		return
+	}
+
+	res := plugin.Resources
+	if res.Memory != "" {
+		if r.Memory, err = units.RAMInBytes(res.Memory); err != nil || r.Memory <= 0 {
+			return Resources{}, InvalidResourcesError{plugin.Name, "invalid memory " + res.Memory}
+		}
+	}
+	if res.CPUs < 0 {
+		return Resources{}, InvalidResourcesError{plugin.Name, "CPUs cannot be negative"}
+	}
+	if res.PidsLimit < 0 {
+		return Resources{}, InvalidResourcesError{plugin.Name, "pids limit cannot be negative"}
+	}
+	r.CPUs, r.PidsLimit = res.CPUs, res.PidsLimit
+	return r, nil
+}
+
+// Resources returns the resource limits of the container.
+func (c *Container) Resources() Resources {
+	var r Resources
+	if hc := c.HostConfig; hc != nil {
+		r.Memory = hc.Memory
+		if hc.CPUPeriod != 0 {
+			r.CPUs = float64(hc.CPUQuota) / float64(hc.CPUPeriod)
+		}
+		if hc.PidsLimit > 0 {
+			r.PidsLimit = hc.PidsLimit
+		}
+	}
+	return r
+}
+
+// UpdateResources changes resource limits of the running container. Docker
+// ignores zero limits, so a limit cannot be removed until the container is
+// recreated.
+func (c *Container) UpdateResources(ctx context.Context, r Resources) error {
+	var update container.UpdateConfig
+	setResources(&update.Resources, r)
+	if r.Memory > 0 {
+		// keep the default swap limit, which is twice the memory limit
+		update.MemorySwap = 2 * r.Memory
+	}
+	return c.ContainerUpdate(ctx, c.ID, update)
+}
+
+func setResources(res *container.Resources, r Resources) {
+	if r.Memory > 0 {
+		res.Memory = r.Memory
+	}
+	if r.CPUs > 0 {
+		res.CPUPeriod = cpuPeriod
+		res.CPUQuota = int64(r.CPUs * cpuPeriod)
+	}
+	if r.PidsLimit > 0 {
+		res.PidsLimit = r.PidsLimit
+	}
+}
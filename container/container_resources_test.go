@@ -0,0 +1,42 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Resources", func() {
+	It("should apply resources declared in plugin manifest", func() {
+		plugin := &manifest.Plugin{
+			Name:      "test",
+			Resources: &manifest.Resources{Memory: "512m", CPUs: 0.5, PidsLimit: 100},
+		}
+		Expect(container.ValidateResources(plugin)).To(Succeed())
+
+		r := container.PluginResources(plugin)
+		Expect(r.Memory).To(Equal(int64(512 * 1024 * 1024)))
+		Expect(r.CPUs).To(Equal(0.5))
+		Expect(r.PidsLimit).To(Equal(int64(100)))
+	})
+
+	It("should override resources with non-zero limits", func() {
+		base := container.Resources{Memory: 1024, CPUs: 1, PidsLimit: 100}
+		r := base.Merge(container.Resources{CPUs: 2})
+		Expect(r).To(Equal(container.Resources{Memory: 1024, CPUs: 2, PidsLimit: 100}))
+	})
+
+	It("should reject invalid resource declarations", func() {
+		for _, res := range []*manifest.Resources{
+			{Memory: "lots"},
+			{Memory: "0"},
+			{CPUs: -1},
+			{PidsLimit: -1},
+		} {
+			plugin := &manifest.Plugin{Name: "test", Resources: res}
+			Expect(container.ValidateResources(plugin)).NotTo(Succeed())
+		}
+	})
+})
@@ -0,0 +1,221 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// DeployStrategy specifies how a repository is distributed to application
+// containers.
+type DeployStrategy int
+
+const (
+	// Update all containers at once.
+	DeployAllAtOnce DeployStrategy = iota
+
+	// Update containers in batches, waiting for each batch becoming running
+	// before proceeding to the next batch.
+	DeployRolling
+
+	// Create a parallel set of containers and switch traffic to it once
+	// all containers are running.
+	DeployBlueGreen
+
+	// Update a percentage of containers and route a share of traffic to
+	// them, until the deployment is promoted or aborted.
+	DeployCanary
+)
+
+var strategyString = [...]string{
+	DeployAllAtOnce: "all-at-once",
+	DeployRolling:   "rolling",
+	DeployBlueGreen: "blue-green",
+	DeployCanary:    "canary",
+}
+
+func (s DeployStrategy) String() string {
+	return strategyString[s]
+}
+
+type InvalidDeployStrategyError string
+
+func (e InvalidDeployStrategyError) Error() string {
+	return fmt.Sprintf("Invalid deploy strategy: %s", string(e))
+}
+
+func (e InvalidDeployStrategyError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseDeployStrategy parses the deploy strategy from string. An empty
+// string is parsed as DeployAllAtOnce.
+func ParseDeployStrategy(s string) (DeployStrategy, error) {
+	if s == "" {
+		return DeployAllAtOnce, nil
+	}
+	for strategy, str := range strategyString {
+		if s == str {
+			return DeployStrategy(strategy), nil
+		}
+	}
+	return DeployAllAtOnce, InvalidDeployStrategyError(s)
+}
+
+// RollingDeployError reports that a rolling deployment is aborted because
+// too many containers failed to become running after update.
+type RollingDeployError struct {
+	Name       string
+	Updated    int
+	Failed     []string
+	Total      int
+	Err        error
+	Results    []*DeployResult
+	RolledBack bool
+}
+
+func (e RollingDeployError) Error() string {
+	msg := fmt.Sprintf("%s: rolling deployment aborted after updating %d of %d containers, %d failed: %v",
+		e.Name, e.Updated, e.Total, len(e.Failed), e.Err)
+	if e.RolledBack {
+		msg += "; deployment rolled back"
+	}
+	return msg
+}
+
+// ErrorDetails returns results of containers, reported to the client.
+// Containers not updated before aborting have no result.
+func (e RollingDeployError) ErrorDetails() map[string]interface{} {
+	if e.Results == nil {
+		return nil
+	}
+	var results []*DeployResult
+	for _, r := range e.Results {
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	return map[string]interface{}{
+		"Containers": results,
+		"RolledBack": e.RolledBack,
+	}
+}
+
+// The default time to wait for an updated container becoming running, and
+// the interval to poll the container state.
+var (
+	rollingDefaultTimeout = 2 * time.Minute
+	rollingPollInterval   = 500 * time.Millisecond
+)
+
+func rollingDeploy(ctx context.Context, targets []*Container, repodir string, opts DeployOptions, log *serverlog.ServerLog) error {
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = 1
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = rollingDefaultTimeout
+	}
+
+	snapshot := prepareRollback(ctx, targets, opts, log)
+	if snapshot != "" {
+		defer os.RemoveAll(snapshot)
+	}
+
+	var failed []string
+	var lastErr error
+	results := make([]*DeployResult, len(targets))
+	progress := newDeployProgress(log, len(targets))
+	for i := 0; i < len(targets); i += batch {
+		end := i + batch
+		if end > len(targets) {
+			end = len(targets)
+		}
+		fmt.Fprintf(log, "Updating containers %d-%d of %d\n", i+1, end, len(targets))
+
+		var deployed []int
+		for j, c := range targets[i:end] {
+			res, err := deployWithRetry(ctx, c, repodir, opts, log)
+			results[i+j] = res
+			if err != nil {
+				failed = append(failed, c.ID)
+				lastErr = err
+			} else {
+				deployed = append(deployed, i+j)
+			}
+			progress.deployed(c)
+		}
+
+		for _, k := range deployed {
+			if err := waitForRunning(ctx, targets[k], timeout, log); err != nil {
+				failed = append(failed, targets[k].ID)
+				lastErr = err
+				results[k].Error = err.Error()
+			}
+		}
+
+		if len(failed) > opts.FailureThreshold {
+			rerr := RollingDeployError{
+				Name:    targets[0].Name,
+				Updated: end,
+				Failed:  failed,
+				Total:   len(targets),
+				Err:     lastErr,
+				Results: results,
+			}
+			if opts.OnFailure == FailRollback && ctx.Err() == nil {
+				rerr.RolledBack = rollbackDeployed(ctx, snapshot, targets, results, log)
+			}
+			return rerr
+		}
+	}
+	return nil
+}
+
+// Wait for the container becoming running after restarted by deployment.
+// The restarted event is reported when the container is running, followed
+// by the result of health check if declared by the plugin. The container
+// fails if the health check doesn't pass.
+func waitForRunning(ctx context.Context, c *Container, timeout time.Duration, log *serverlog.ServerLog) (err error) {
+	defer func() {
+		if err != nil {
+			reportDeploy(log, c, DeployEventFailed, 0, err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		// give the sandbox a chance to restart before polling state
+		time.Sleep(rollingPollInterval)
+
+		// refresh container state from docker
+		current, err := c.DockerClient.Inspect(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+
+		state := current.ActiveState(ctx)
+		if state == manifest.StateRunning && !current.restarted(ctx) {
+			// the signal is not yet handled by the sandbox
+			state = manifest.StateRestarting
+		}
+		switch state {
+		case manifest.StateRunning:
+			reportDeploy(log, c, DeployEventRestarted, 0, nil)
+			return checkDeployed(ctx, c, deadline, log)
+		case manifest.StateFailed, manifest.StateStopped:
+			return fmt.Errorf("%s: container is %s after deployment", c.ID, state)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s: timed out waiting for container running, current state is %s", c.ID, state)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// SecretsDir is the tmpfs mount point in framework containers holding
+// secret files. Files in tmpfs are never written to the container file
+// system, and are lost when the container is stopped.
+const SecretsDir = "/run/secrets"
+
+// SecretEnvDir is the directory in the secrets tmpfs holding secrets set as
+// environment variables. The sandbox loads them in addition to the env
+// directory, so they are never persisted in the container file system and
+// never included in backups or environment copies.
+const SecretEnvDir = SecretsDir + "/.env"
+
+// Secret is a decrypted secret injected into framework containers.
+type Secret struct {
+	Name  string
+	Value string
+
+	// Set the secret as an environment variable instead of a file.
+	Env bool
+}
+
+// SecretProvider returns secrets to be injected into the container.
+type SecretProvider func(ctx context.Context, c *Container) ([]*Secret, error)
+
+var secretProvider struct {
+	sync.RWMutex
+	fn SecretProvider
+}
+
+// SetSecretProvider sets the provider of secrets injected into framework
+// containers before the application is started.
+func SetSecretProvider(fn SecretProvider) {
+	secretProvider.Lock()
+	secretProvider.fn = fn
+	secretProvider.Unlock()
+}
+
+// injectProvidedSecrets injects secrets returned by the secret provider into
+// a framework container. Secret files in tmpfs must be injected again after
+// the container is restarted.
+func injectProvidedSecrets(ctx context.Context, c *Container) error {
+	secretProvider.RLock()
+	fn := secretProvider.fn
+	secretProvider.RUnlock()
+
+	if fn == nil || !c.Category().IsFramework() {
+		return nil
+	}
+	secrets, err := fn(ctx, c)
+	if err != nil {
+		return err
+	}
+	return c.InjectSecrets(ctx, secrets)
+}
+
+// InjectSecrets writes secrets to files in the secrets directory, or to the
+// secret env directory if set as environment variables, readable only by the
+// application user.
+func (c *Container) InjectSecrets(ctx context.Context, secrets []*Secret) error {
+	var envNames []string
+	for _, s := range secrets {
+		if !validSecretName(s.Name) {
+			return fmt.Errorf("Invalid secret name: %q", s.Name)
+		}
+		dir := SecretsDir
+		if s.Env {
+			dir = SecretEnvDir
+			envNames = append(envNames, s.Name)
+		}
+		path := dir + "/" + s.Name
+		script := fmt.Sprintf("umask 077 && mkdir -p -m 0755 %[1]s && cat > %[2]s.tmp && chown %[3]s %[2]s.tmp && mv -f %[2]s.tmp %[2]s",
+			dir, path, c.User())
+		err := c.ExecE(ctx, "root", strings.NewReader(s.Value), nil, "/bin/sh", "-c", script)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove plaintext copies persisted in the env directory by earlier
+	// versions
+	return c.Unsetenv(ctx, envNames...)
+}
+
+// RemoveSecrets removes the secret environment variables and files.
+func (c *Container) RemoveSecrets(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	args := []string{"rm", "-f", "--"}
+	for _, name := range names {
+		if !validSecretName(name) {
+			return fmt.Errorf("Invalid secret name: %q", name)
+		}
+		args = append(args, SecretsDir+"/"+name, SecretEnvDir+"/"+name)
+	}
+	if err := c.ExecQ(ctx, "root", args...); err != nil {
+		return err
+	}
+	return c.Unsetenv(ctx, names...)
+}
+
+func validSecretName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/'\"$`\\ \t\n")
+}
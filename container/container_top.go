@@ -0,0 +1,97 @@
+package container
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+)
+
+// Process is a single entry of the container process table.
+type Process struct {
+	User    string
+	PID     int
+	PPID    int
+	CPU     string
+	Start   string
+	TTY     string
+	Time    string
+	Command string
+}
+
+// ProcessList is the parsed process table of a container.
+type ProcessList struct {
+	// The original column titles reported by docker.
+	Titles []string
+
+	// The parsed processes.
+	Processes []*Process
+}
+
+// Top returns the processes running inside the container.
+func (c *Container) Top(ctx context.Context) (*ProcessList, error) {
+	top, err := c.ContainerTop(ctx, c.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProcessList(top)
+}
+
+// The column titles for each process field. Different docker versions and
+// ps implementations report different titles for the same column.
+var processColumns = []struct {
+	titles []string
+	set    func(p *Process, v string)
+}{
+	{[]string{"UID", "USER"}, func(p *Process, v string) { p.User = v }},
+	{[]string{"PID"}, func(p *Process, v string) { p.PID, _ = strconv.Atoi(v) }},
+	{[]string{"PPID"}, func(p *Process, v string) { p.PPID, _ = strconv.Atoi(v) }},
+	{[]string{"C", "%CPU"}, func(p *Process, v string) { p.CPU = v }},
+	{[]string{"STIME", "START", "STARTED"}, func(p *Process, v string) { p.Start = v }},
+	{[]string{"TTY", "TT"}, func(p *Process, v string) { p.TTY = v }},
+	{[]string{"TIME"}, func(p *Process, v string) { p.Time = v }},
+	{[]string{"CMD", "COMMAND", "ARGS"}, func(p *Process, v string) { p.Command = v }},
+}
+
+// ParseProcessList parses the process table returned from docker. The
+// columns are located by their titles, so the column layout may vary.
+func ParseProcessList(top types.ContainerProcessList) (*ProcessList, error) {
+	var index = make([]int, len(processColumns))
+	for i, col := range processColumns {
+		index[i] = findColumn(top.Titles, col.titles)
+	}
+
+	// the command column is required to identify processes
+	if index[len(index)-1] == -1 {
+		return nil, errors.New("no command column in process list")
+	}
+
+	pl := &ProcessList{
+		Titles:    top.Titles,
+		Processes: make([]*Process, 0, len(top.Processes)),
+	}
+	for _, row := range top.Processes {
+		p := &Process{}
+		for i, col := range processColumns {
+			if j := index[i]; j != -1 && j < len(row) {
+				col.set(p, strings.TrimSpace(row[j]))
+			}
+		}
+		pl.Processes = append(pl.Processes, p)
+	}
+	return pl, nil
+}
+
+func findColumn(titles []string, names []string) int {
+	for i, t := range titles {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		for _, name := range names {
+			if t == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,68 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"github.com/docker/engine-api/types"
+)
+
+var _ = Describe("Process List", func() {
+	It("should parse process list with ps -ef layout", func() {
+		top := types.ContainerProcessList{
+			Titles: []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"},
+			Processes: [][]string{
+				{"1000", "3201", "3185", "0", "09:12", "?", "00:00:00", "/usr/bin/cwctl [1] run"},
+				{"1000", "3240", "3201", "2", "09:12", "?", "00:00:03", "python app.py"},
+			},
+		}
+
+		pl, err := container.ParseProcessList(top)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pl.Titles).To(Equal(top.Titles))
+		Expect(pl.Processes).To(HaveLen(2))
+		Expect(*pl.Processes[0]).To(Equal(container.Process{
+			User:    "1000",
+			PID:     3201,
+			PPID:    3185,
+			CPU:     "0",
+			Start:   "09:12",
+			TTY:     "?",
+			Time:    "00:00:00",
+			Command: "/usr/bin/cwctl [1] run",
+		}))
+		Expect(pl.Processes[1].PPID).To(Equal(3201))
+		Expect(pl.Processes[1].Command).To(Equal("python app.py"))
+	})
+
+	It("should parse process list with ps aux layout", func() {
+		top := types.ContainerProcessList{
+			Titles: []string{"USER", "PID", "%CPU", "%MEM", "VSZ", "RSS", "TTY", "STAT", "START", "TIME", "COMMAND"},
+			Processes: [][]string{
+				{"app", "7", "1.5", "0.3", "18172", "3136", "?", "Ss", "09:12", "0:00", "/usr/bin/cwctl [2] run"},
+			},
+		}
+
+		pl, err := container.ParseProcessList(top)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pl.Processes).To(HaveLen(1))
+
+		p := pl.Processes[0]
+		Expect(p.User).To(Equal("app"))
+		Expect(p.PID).To(Equal(7))
+		Expect(p.PPID).To(Equal(0))
+		Expect(p.CPU).To(Equal("1.5"))
+		Expect(p.Start).To(Equal("09:12"))
+		Expect(p.Command).To(Equal("/usr/bin/cwctl [2] run"))
+	})
+
+	It("should fail if no command column in process list", func() {
+		top := types.ContainerProcessList{
+			Titles:    []string{"PID", "TIME"},
+			Processes: [][]string{{"1", "0:00"}},
+		}
+		_, err := container.ParseProcessList(top)
+		Expect(err).To(HaveOccurred())
+	})
+})
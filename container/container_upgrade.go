@@ -0,0 +1,176 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/hub"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// UpgradeCategoryError reports that a plugin cannot be upgraded to a
+// version of different category.
+type UpgradeCategoryError struct {
+	Plugin string
+	From   manifest.Category
+	To     manifest.Category
+}
+
+func (e UpgradeCategoryError) Error() string {
+	return fmt.Sprintf("Cannot upgrade %s from a %s plugin to a %s plugin", e.Plugin, e.From, e.To)
+}
+
+func (e UpgradeCategoryError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// UpgradePlugin replaces containers of the application running another
+// version of the plugin with containers running the given version. The
+// environment variables, repository, data and persistent volumes are
+// migrated to new containers. The old containers are removed after all
+// new containers started, or restarted if the upgrade failed. Returns
+// false if no container is running another version of the plugin.
+func (cli DockerClient) UpgradePlugin(ctx context.Context, name, namespace string, plugin *manifest.Plugin, log *serverlog.ServerLog) (upgraded bool, err error) {
+	old, err := cli.findPluginContainers(ctx, name, namespace, plugin)
+	if err != nil || len(old) == 0 {
+		return false, err
+	}
+
+	existing, err := cli.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	var created, stopped []*Container
+	defer func() {
+		if err != nil {
+			fmt.Fprintf(log, "Upgrade failed, restoring %s\n", plugin.Name)
+			for _, c := range created {
+				c.Destroy(ctx)
+			}
+			for _, c := range stopped {
+				if er := c.Start(ctx, log); er != nil {
+					logrus.WithError(er).Warnf("Failed to restart container %s", shortID(c.ID))
+				}
+			}
+		}
+	}()
+
+	var image string
+	for i, c := range old {
+		fmt.Fprintf(log, "Upgrading container %s from %s to %s\n", shortID(c.ID), c.PluginTag(), plugin.Tag)
+
+		// stop the old container so persistent data is not modified
+		// while migrating to the new container
+		if c.Category().IsService() && c.State.Running {
+			if err = c.Stop(ctx); err != nil {
+				return false, err
+			}
+			stopped = append(stopped, c)
+		}
+
+		var nc *Container
+		nc, err = cli.upgradeContainer(ctx, c, plugin, image, len(existing)+i+1, log)
+		if nc != nil {
+			created = append(created, nc)
+			image = nc.Config.Image
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, c := range created {
+		if err = c.Start(ctx, log); err != nil {
+			return false, err
+		}
+	}
+
+	for _, c := range old {
+		if er := c.Destroy(ctx); er != nil {
+			logrus.WithError(er).Warnf("Failed to remove upgraded container %s", shortID(c.ID))
+		}
+	}
+	return true, nil
+}
+
+// findPluginContainers returns containers of the application running
+// another version of the plugin.
+func (cli DockerClient) findPluginContainers(ctx context.Context, name, namespace string, plugin *manifest.Plugin) ([]*Container, error) {
+	_, pns, _, _, err := hub.ParseTag(plugin.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := cli.FindAll(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Container
+	for _, c := range containers {
+		_, cns, cname, _, err := hub.ParseTag(c.PluginTag())
+		if err != nil || cns != pns || cname != plugin.Name || c.PluginTag() == plugin.Tag {
+			continue
+		}
+		if c.Category() != plugin.Category {
+			return nil, UpgradeCategoryError{plugin.Name, c.Category(), plugin.Category}
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// upgradeContainer creates a container running the new version of the
+// plugin and migrates the old container to it. The image built for the
+// first upgraded container is reused for other containers.
+func (cli DockerClient) upgradeContainer(ctx context.Context, c *Container, plugin *manifest.Plugin, image string, scaling int, log *serverlog.ServerLog) (*Container, error) {
+	volumes, err := c.VolumeNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := replicaOptions(c, plugin, scaling, log)
+	opts.Image = image
+	opts.ServiceName = c.ServiceName()
+	opts.Volumes = volumes
+	opts.Generation++
+	opts.upgrade = true
+
+	cs, err := cli.Create(ctx, opts)
+	if len(cs) == 0 {
+		if err == nil {
+			err = fmt.Errorf("%s: no container created", plugin.Name)
+		}
+		return nil, err
+	}
+	nc := cs[0]
+	if err != nil {
+		return nc, err
+	}
+
+	if err = CopyEnv(ctx, c, nc); err != nil {
+		return nc, err
+	}
+
+	if c.Category().IsFramework() {
+		repo, _, err := c.CopyFromContainer(ctx, c.ID, c.RepoDir()+"/.")
+		if err != nil {
+			return nc, err
+		}
+		defer repo.Close()
+		return nc, cli.DistributeRepo(ctx, []*Container{nc}, repo, true, DeployOptions{}, nil)
+	}
+
+	data, _, err := c.CopyFromContainer(ctx, c.ID, c.DataDir()+"/.")
+	if err != nil {
+		return nc, err
+	}
+	defer data.Close()
+	return nc, nc.CopyToContainer(ctx, nc.ID, nc.DataDir(), data, types.CopyToContainerOptions{})
+}
@@ -0,0 +1,250 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"github.com/docker/go-units"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// InvalidVolumeError reports an invalid volume declared in plugin manifest.
+type InvalidVolumeError struct {
+	Plugin string
+	Volume string
+	Reason string
+}
+
+func (e InvalidVolumeError) Error() string {
+	return fmt.Sprintf("Invalid volume '%s' declared in plugin %s: %s", e.Volume, e.Plugin, e.Reason)
+}
+
+func (e InvalidVolumeError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+var volumeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// ValidateVolumes checks the volumes declared in plugin manifest. The volume
+// name must be unique, the mount path must be a distinct absolute path, and
+// the size, if present, must be a positive size such as "10GB".
+func ValidateVolumes(plugin *manifest.Plugin) error {
+	names := make(map[string]bool)
+	paths := make(map[string]bool)
+
+	for _, vol := range plugin.Volumes {
+		invalid := func(reason string) error {
+			return InvalidVolumeError{Plugin: plugin.Name, Volume: vol.Name, Reason: reason}
+		}
+
+		if !volumeNamePattern.MatchString(vol.Name) {
+			return invalid("malformed volume name")
+		}
+		if names[vol.Name] {
+			return invalid("duplicate volume name")
+		}
+		names[vol.Name] = true
+
+		if !path.IsAbs(vol.MountPath) || path.Clean(vol.MountPath) != vol.MountPath || vol.MountPath == "/" {
+			return invalid("mount path must be a clean absolute path other than root")
+		}
+		if paths[vol.MountPath] {
+			return invalid("duplicate mount path " + vol.MountPath)
+		}
+		paths[vol.MountPath] = true
+
+		if vol.Size != "" {
+			if size, err := units.RAMInBytes(vol.Size); err != nil || size <= 0 {
+				return invalid("invalid size " + vol.Size)
+			}
+		}
+	}
+	return nil
+}
+
+// Create volumes declared in the plugin manifest for the container, returns
+// the volume bindings to be mounted into the container.
+func createVolumes(cli DockerClient, ctx context.Context, cfg *createConfig, containerName string) (binds []string, err error) {
+	var created []string
+	defer func() {
+		if err != nil {
+			for _, name := range created {
+				cli.VolumeRemove(ctx, name)
+			}
+		}
+	}()
+
+	for _, vol := range cfg.Plugin.Volumes {
+		// mount the existing volume of a replaced container
+		if name := cfg.Volumes[vol.Name]; name != "" {
+			binds = append(binds, name+":"+vol.MountPath)
+			continue
+		}
+
+		req := types.VolumeCreateRequest{
+			Name:   containerName + "-" + vol.Name,
+			Driver: vol.StorageClass,
+			Labels: map[string]string{
+				APP_NAME_KEY:      cfg.Name,
+				APP_NAMESPACE_KEY: cfg.Namespace,
+				VOLUME_NAME_KEY:   vol.Name,
+			},
+		}
+
+		if vol.Size != "" {
+			size, _ := units.RAMInBytes(vol.Size)
+			req.Labels[VOLUME_SIZE_KEY] = strconv.FormatInt(size, 10)
+
+			// The default local driver doesn't support size limit, the
+			// size option is only passed to a storage class volume driver.
+			if vol.StorageClass != "" {
+				req.DriverOpts = map[string]string{"size": vol.Size}
+			}
+		}
+
+		var v types.Volume
+		if v, err = cli.VolumeCreate(ctx, req); err != nil {
+			return nil, err
+		}
+		created = append(created, v.Name)
+		binds = append(binds, v.Name+":"+vol.MountPath)
+	}
+	return binds, nil
+}
+
+// Remove volumes provisioned by createVolumes when failed to create the
+// container, existing volumes mounted into the container are kept.
+func removeCreatedVolumes(cli DockerClient, ctx context.Context, cfg *createConfig, binds []string) {
+	existing := make(map[string]bool)
+	for _, name := range cfg.Volumes {
+		existing[name] = true
+	}
+	for _, b := range binds {
+		if name := b[:strings.Index(b, ":")]; !existing[name] {
+			cli.VolumeRemove(ctx, name)
+		}
+	}
+}
+
+// Volume describes a persistent volume mounted into the container.
+type Volume struct {
+	// The volume name declared in the plugin manifest.
+	Name string
+
+	// The name of the Docker volume.
+	VolumeName string
+
+	MountPath string
+	Driver    string
+
+	// The size limit of the volume in bytes, 0 if unlimited.
+	Size int64
+
+	// The disk space used by the volume in bytes, -1 if unknown.
+	Used int64
+}
+
+// Volumes returns persistent volumes provisioned for the container.
+func (c *Container) Volumes(ctx context.Context) ([]*Volume, error) {
+	var volumes []*Volume
+	for _, m := range c.Mounts {
+		if m.Name == "" {
+			continue
+		}
+
+		v, err := c.VolumeInspect(ctx, m.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !c.ownsVolume(v) {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(v.Labels[VOLUME_SIZE_KEY], 10, 64)
+		volumes = append(volumes, &Volume{
+			Name:       v.Labels[VOLUME_NAME_KEY],
+			VolumeName: v.Name,
+			MountPath:  m.Destination,
+			Driver:     v.Driver,
+			Size:       size,
+			Used:       c.diskUsage(ctx, m.Destination),
+		})
+	}
+	return volumes, nil
+}
+
+// diskUsage returns the disk space used by the directory in the container,
+// or -1 if the usage cannot be determined, e.g. the container is stopped.
+func (c *Container) diskUsage(ctx context.Context, dir string) int64 {
+	out, err := c.Subst(ctx, "root", nil, "du", "-sk", dir)
+	if err != nil {
+		return -1
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return -1
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return kb * 1024
+}
+
+// VolumeNames returns Docker volumes of the container keyed by the volume
+// name declared in plugin manifest, so the volumes can be mounted into a
+// container replacing this one.
+func (c *Container) VolumeNames(ctx context.Context) (map[string]string, error) {
+	volumes, err := c.Volumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(volumes))
+	for _, v := range volumes {
+		names[v.Name] = v.VolumeName
+	}
+	return names, nil
+}
+
+func (c *Container) ownsVolume(v types.Volume) bool {
+	return v.Labels[VOLUME_NAME_KEY] != "" &&
+		v.Labels[APP_NAME_KEY] == c.Name && v.Labels[APP_NAMESPACE_KEY] == c.Namespace
+}
+
+// Remove volumes provisioned for the container. The container must be
+// removed before removing volumes. Volumes still mounted by other
+// containers, such as a container replacing this one, are kept.
+func (c *Container) removeVolumes(ctx context.Context) {
+	for _, m := range c.Mounts {
+		if m.Name == "" {
+			continue
+		}
+
+		v, err := c.VolumeInspect(ctx, m.Name)
+		if err != nil || !c.ownsVolume(v) || c.volumeInUse(ctx, v.Name) {
+			continue
+		}
+
+		if err = c.VolumeRemove(ctx, v.Name); err != nil {
+			logrus.WithError(err).Warnf("Failed to remove volume %s", v.Name)
+		} else {
+			logrus.Debugf("Removed volume %s", v.Name)
+		}
+	}
+}
+
+func (c *Container) volumeInUse(ctx context.Context, name string) bool {
+	args := filters.NewArgs()
+	args.Add("volume", name)
+	list, err := c.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+	return err == nil && len(list) != 0
+}
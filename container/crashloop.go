@@ -0,0 +1,184 @@
+package container
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types/container"
+
+	"github.com/cloudway/platform/config"
+)
+
+// DefaultRestartPolicy is the restart policy of application containers if
+// not configured. Docker restarts a crashed container at most 5 times, so
+// a container that never starts successfully is not restarted forever.
+const DefaultRestartPolicy = "on-failure:5"
+
+type InvalidRestartPolicyError string
+
+func (e InvalidRestartPolicyError) Error() string {
+	return fmt.Sprintf("Invalid restart policy: %s", string(e))
+}
+
+func (e InvalidRestartPolicyError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ParseRestartPolicy parses the restart policy in the form accepted by
+// docker run: "no", "always", "unless-stopped" or "on-failure[:max-retries]".
+func ParseRestartPolicy(s string) (container.RestartPolicy, error) {
+	var policy container.RestartPolicy
+
+	name, retries := s, ""
+	if i := strings.IndexRune(s, ':'); i != -1 {
+		name, retries = s[:i], s[i+1:]
+	}
+
+	switch name {
+	case "no", "always", "unless-stopped":
+		if retries != "" {
+			return policy, InvalidRestartPolicyError(s)
+		}
+	case "on-failure":
+		if retries != "" {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return policy, InvalidRestartPolicyError(s)
+			}
+			policy.MaximumRetryCount = n
+		}
+	default:
+		return policy, InvalidRestartPolicyError(s)
+	}
+
+	policy.Name = name
+	return policy, nil
+}
+
+func restartPolicy() (container.RestartPolicy, error) {
+	return ParseRestartPolicy(config.GetOrDefault("container.restart-policy", DefaultRestartPolicy))
+}
+
+// CrashLoop describes a container that crashed and was restarted by Docker
+// too many times in a short period, or that Docker gave up restarting.
+type CrashLoop struct {
+	Restarts int
+	ExitCode int
+	Since    time.Time
+	Error    string
+}
+
+func (cl *CrashLoop) String() string {
+	msg := fmt.Sprintf("crashed %d times, last exited with code %d", cl.Restarts, cl.ExitCode)
+	if cl.Error != "" {
+		msg += ": " + cl.Error
+	}
+	return msg
+}
+
+var crashLoopRegistry = struct {
+	sync.RWMutex
+	m map[string]*CrashLoop
+}{m: make(map[string]*CrashLoop)}
+
+// CrashLoopOf returns the crash loop of the container, or nil if the
+// container is not crash looping.
+func CrashLoopOf(id string) *CrashLoop {
+	crashLoopRegistry.RLock()
+	defer crashLoopRegistry.RUnlock()
+	if cl := crashLoopRegistry.m[id]; cl != nil {
+		crashLoop := *cl
+		return &crashLoop
+	}
+	return nil
+}
+
+func setCrashLoop(id string, cl CrashLoop) {
+	crashLoopRegistry.Lock()
+	crashLoopRegistry.m[id] = &cl
+	crashLoopRegistry.Unlock()
+}
+
+func removeCrashLoop(id string) {
+	crashLoopRegistry.Lock()
+	delete(crashLoopRegistry.m, id)
+	crashLoopRegistry.Unlock()
+}
+
+// IsCrashLooping returns true if the container is crash looping.
+func (c *Container) IsCrashLooping() bool {
+	return CrashLoopOf(c.ID) != nil
+}
+
+// crashState keeps restarts of a container performed by Docker.
+type crashState struct {
+	count    int
+	restarts []time.Time
+	looping  bool
+}
+
+// detectCrashLoop observes the restart count of the container maintained
+// by Docker. The container is crash looping if it was restarted too many
+// times in the window, or if it was exited abnormally and Docker gave up
+// restarting it. The crash loop handler is called once when the container
+// enters a crash loop.
+func (m *Monitor) detectCrashLoop(c *Container, now time.Time) {
+	s := m.crashes[c.ID]
+	if s == nil {
+		s = &crashState{count: c.RestartCount}
+		m.crashes[c.ID] = s
+	}
+
+	if c.RestartCount < s.count {
+		// the restart count is reset when container is started manually
+		s.restarts = nil
+	}
+	for i := s.count; i < c.RestartCount; i++ {
+		s.restarts = append(s.restarts, now)
+	}
+	s.count = c.RestartCount
+
+	i := 0
+	for i < len(s.restarts) && now.Sub(s.restarts[i]) > m.crashWindow {
+		i++
+	}
+	s.restarts = s.restarts[i:]
+
+	if len(s.restarts) < m.crashRestarts && !gaveUpRestart(c) {
+		if s.looping {
+			logrus.Infof("Container %s of %s recovered from crash loop", c.ID[:12], c.FQDN())
+			s.looping = false
+			removeCrashLoop(c.ID)
+		}
+		return
+	}
+
+	cl := CrashLoop{Restarts: c.RestartCount, ExitCode: c.State.ExitCode, Since: now, Error: c.State.Error}
+	if prev := CrashLoopOf(c.ID); prev != nil {
+		cl.Since = prev.Since
+	}
+	setCrashLoop(c.ID, cl)
+
+	if !s.looping {
+		s.looping = true
+		logrus.Warnf("Container %s of %s is crash looping: %s", c.ID[:12], c.FQDN(), cl.String())
+		if m.OnCrashLoop != nil {
+			go m.OnCrashLoop(c, &cl)
+		}
+	}
+}
+
+// gaveUpRestart returns true if the container exited abnormally and would
+// not be restarted by Docker because the maximum retry count was reached.
+func gaveUpRestart(c *Container) bool {
+	if c.State.Running || c.State.Restarting || c.State.ExitCode == 0 || c.HostConfig == nil {
+		return false
+	}
+	policy := c.HostConfig.RestartPolicy
+	return policy.IsOnFailure() && policy.MaximumRetryCount > 0 && c.RestartCount >= policy.MaximumRetryCount
+}
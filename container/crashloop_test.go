@@ -0,0 +1,31 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Restart policy", func() {
+	It("should parse restart policies", func() {
+		for _, s := range []string{"no", "always", "unless-stopped", "on-failure"} {
+			policy, err := container.ParseRestartPolicy(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Name).To(Equal(s))
+			Expect(policy.MaximumRetryCount).To(Equal(0))
+		}
+
+		policy, err := container.ParseRestartPolicy(container.DefaultRestartPolicy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy.Name).To(Equal("on-failure"))
+		Expect(policy.MaximumRetryCount).To(Equal(5))
+	})
+
+	It("should reject malformed restart policies", func() {
+		for _, s := range []string{"", "sometimes", "always:3", "on-failure:", "on-failure:-1", "on-failure:many"} {
+			_, err := container.ParseRestartPolicy(s)
+			Expect(err).To(Equal(container.InvalidRestartPolicyError(s)))
+		}
+	})
+})
@@ -0,0 +1,45 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/docker/engine-api/types/container"
+
+	"github.com/cloudway/platform/config"
+)
+
+// DiscoveryDomain returns the domain of names resolved by the service
+// discovery DNS server, configured by "discovery.domain", or an empty string
+// if containers are not configured to use the DNS server by "discovery.dns".
+func DiscoveryDomain() string {
+	if config.Get("discovery.dns") == "" {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(config.GetOrDefault("discovery.domain", "cloudway.local"), "."))
+}
+
+// DNSName returns the name of the container resolved by the service
+// discovery DNS server, "<service>.<app>.<domain>" for service containers
+// and "<app>.<domain>" for framework containers. Returns an empty string if
+// service discovery is not enabled.
+func (c *Container) DNSName() string {
+	domain := DiscoveryDomain()
+	if domain == "" {
+		return ""
+	}
+	if c.Category().IsService() {
+		return c.ServiceName() + "." + c.Name + "." + domain
+	}
+	return c.Name + "." + domain
+}
+
+// setDNS configures the container to resolve names with the service
+// discovery DNS server, and search service names in the application.
+func setDNS(hostConfig *container.HostConfig, cfg *createConfig) {
+	domain := DiscoveryDomain()
+	if domain == "" {
+		return
+	}
+	hostConfig.DNS = []string{config.Get("discovery.dns")}
+	hostConfig.DNSSearch = []string{cfg.Name + "." + domain}
+}
@@ -4,13 +4,24 @@ import "github.com/docker/engine-api/client"
 
 type DockerClient struct {
 	*client.Client
+
+	// The Docker hosts that application containers are placed on.
+	nodes []*Node
 }
 
+// NewEnvClient connects to the Docker host from environment variables.
+// Application containers are placed on multiple hosts if configured.
 func NewEnvClient() (DockerClient, error) {
 	cli, err := client.NewEnvClient()
-	return NewClient(cli), err
+	if err != nil {
+		return NewClient(cli), err
+	}
+
+	dc := NewClient(cli)
+	dc.nodes, err = loadNodes()
+	return dc, err
 }
 
 func NewClient(cli *client.Client) DockerClient {
-	return DockerClient{cli}
+	return DockerClient{Client: cli}
 }
@@ -4,6 +4,7 @@ import "text/template"
 
 var dockerfileTemplate = template.Must(template.New("Dockerfile").Parse(`
 FROM {{.BaseImage}}
+LABEL com.cloudway.image.base={{printf "%q" .BaseImage}} com.cloudway.image.plugin={{printf "%q" .Plugin.Tag}}
 
 {{ if .InstallScript -}}
 RUN echo '{{.InstallScript}}' | /bin/sh
@@ -0,0 +1,227 @@
+package container
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+)
+
+// Actions of container events reported by the event watcher.
+const (
+	ContainerStarted   = "start"
+	ContainerDied      = "die"
+	ContainerOOMKilled = "oom"
+	ContainerRemoved   = "destroy"
+)
+
+// ContainerEvent is a Docker event of a container. The name and namespace
+// are empty if the container is not an application container.
+type ContainerEvent struct {
+	Action    string
+	ID        string
+	Name      string
+	Namespace string
+	Service   string
+	Time      time.Time
+
+	// The exit code of a died container.
+	ExitCode int
+
+	// True if the container is removed without destroying it by this
+	// server, e.g. removed by docker rm.
+	OutOfBand bool
+
+	// The attributes of the event, including labels of the container.
+	Attributes map[string]string
+}
+
+// ContainerExit describes the last exit of a container.
+type ContainerExit struct {
+	ExitCode  int
+	OOMKilled bool
+	Time      time.Time
+}
+
+var exitRegistry = struct {
+	sync.RWMutex
+	m   map[string]*ContainerExit
+	oom map[string]bool
+}{m: make(map[string]*ContainerExit), oom: make(map[string]bool)}
+
+// LastExitOf returns the last exit of the container observed by the event
+// watcher, or nil if the container never exited since the server started.
+func LastExitOf(id string) *ContainerExit {
+	exitRegistry.RLock()
+	defer exitRegistry.RUnlock()
+	if e := exitRegistry.m[id]; e != nil {
+		exit := *e
+		return &exit
+	}
+	return nil
+}
+
+// The containers being removed by this server, so removals by others are
+// reported as out of band.
+var removing = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+func expectRemoval(id string) {
+	removing.Lock()
+	removing.m[id] = true
+	removing.Unlock()
+}
+
+func removalExpected(id string) bool {
+	removing.Lock()
+	defer removing.Unlock()
+	expected := removing.m[id]
+	delete(removing.m, id)
+	return expected
+}
+
+// The delay before reconnecting a broken Docker events stream.
+var eventRetryDelay = 5 * time.Second
+
+// EventWatcher watches Docker events of containers on all Docker hosts,
+// and keeps watching across broken event streams, e.g. when the Docker
+// daemon is restarted.
+type EventWatcher struct {
+	cli DockerClient
+
+	// OnEvent is called for each container event. Events of a Docker
+	// host are delivered in order.
+	OnEvent func(e *ContainerEvent)
+
+	// OnResync is called when connected to the events stream of a Docker
+	// host, so the state missed while disconnected can be rebuilt.
+	OnResync func(cli DockerClient)
+}
+
+func NewEventWatcher(cli DockerClient) *EventWatcher {
+	return &EventWatcher{cli: cli}
+}
+
+// Run the watcher until the stop channel is closed.
+func (w *EventWatcher) Run(stop <-chan bool) {
+	clients := []DockerClient{w.cli}
+	if nodes := w.cli.Nodes(); len(nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, cli := range clients {
+		wg.Add(1)
+		go func(cli DockerClient) {
+			defer wg.Done()
+			w.watch(cli, stop)
+		}(cli)
+	}
+	wg.Wait()
+}
+
+func (w *EventWatcher) watch(cli DockerClient, stop <-chan bool) {
+	for {
+		err := w.stream(cli, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		logrus.WithError(err).Warnf("Docker events stream broken, reconnecting in %s", eventRetryDelay)
+		select {
+		case <-stop:
+			return
+		case <-time.After(eventRetryDelay):
+		}
+	}
+}
+
+func (w *EventWatcher) stream(cli DockerClient, stop <-chan bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	args := filters.NewArgs()
+	args.Add("type", "container")
+	for _, action := range []string{ContainerStarted, ContainerDied, ContainerOOMKilled, ContainerRemoved} {
+		args.Add("event", action)
+	}
+	resp, err := cli.Events(ctx, types.EventsOptions{Filters: args})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	if w.OnResync != nil {
+		w.OnResync(cli)
+	}
+
+	dec := json.NewDecoder(resp)
+	for {
+		var msg events.Message
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+		if e := recordEvent(&msg); e != nil && w.OnEvent != nil {
+			w.OnEvent(e)
+		}
+	}
+}
+
+// recordEvent converts the Docker event to container event, and records
+// exits of the container.
+func recordEvent(msg *events.Message) *ContainerEvent {
+	attrs := msg.Actor.Attributes
+	e := &ContainerEvent{
+		Action:     msg.Action,
+		ID:         msg.Actor.ID,
+		Name:       attrs[APP_NAME_KEY],
+		Namespace:  attrs[APP_NAMESPACE_KEY],
+		Service:    attrs[SERVICE_NAME_KEY],
+		Time:       time.Unix(msg.Time, 0),
+		Attributes: attrs,
+	}
+	if msg.TimeNano != 0 {
+		e.Time = time.Unix(0, msg.TimeNano)
+	}
+
+	exitRegistry.Lock()
+	defer exitRegistry.Unlock()
+
+	switch msg.Action {
+	case ContainerOOMKilled:
+		// the container dies after killed by the OOM killer
+		exitRegistry.oom[e.ID] = true
+	case ContainerDied:
+		e.ExitCode, _ = strconv.Atoi(attrs["exitCode"])
+		exitRegistry.m[e.ID] = &ContainerExit{ExitCode: e.ExitCode, OOMKilled: exitRegistry.oom[e.ID], Time: e.Time}
+		delete(exitRegistry.oom, e.ID)
+	case ContainerRemoved:
+		delete(exitRegistry.m, e.ID)
+		delete(exitRegistry.oom, e.ID)
+		e.OutOfBand = e.Name != "" && !removalExpected(e.ID)
+	case ContainerStarted:
+	default:
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,247 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+)
+
+// GCResult lists the garbage found by the garbage collector. Nothing is
+// removed in dry run.
+type GCResult struct {
+	DryRun bool
+
+	// IDs of builder containers not used by any build.
+	Builders []string `json:",omitempty"`
+
+	// IDs of images built for plugins and not used by any container.
+	Images []string `json:",omitempty"`
+
+	// Temporary files and directories left by uploads and deployments.
+	TempFiles []string `json:",omitempty"`
+
+	// Errors occurred when removing garbage.
+	Errors []string `json:",omitempty"`
+}
+
+// The builders used by running builds, so they are not collected.
+var activeBuilders = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+func setBuilderActive(id string, active bool) {
+	activeBuilders.Lock()
+	if active {
+		activeBuilders.m[id] = true
+	} else {
+		delete(activeBuilders.m, id)
+	}
+	activeBuilders.Unlock()
+}
+
+// isBuilderKnown returns true if the builder is used by a running build or
+// kept in the builder pool.
+func isBuilderKnown(id string) bool {
+	activeBuilders.Lock()
+	active := activeBuilders.m[id]
+	activeBuilders.Unlock()
+	if active {
+		return true
+	}
+
+	builderPool.Lock()
+	defer builderPool.Unlock()
+	for _, idle := range builderPool.m {
+		for _, b := range idle {
+			if b.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The garbage created within the grace period is not collected, since it
+// may be used by an operation just started.
+func gcGrace() time.Duration {
+	d, err := time.ParseDuration(config.GetOrDefault("gc.grace", "10m"))
+	if err != nil || d < 0 {
+		logrus.Warnf("Invalid gc.grace configuration: %s", config.Get("gc.grace"))
+		d = 10 * time.Minute
+	}
+	return d
+}
+
+// Temporary files are only collected after this age, since they are used
+// for the entire duration of uploads and deployments.
+func gcTempAge() time.Duration {
+	d, err := time.ParseDuration(config.GetOrDefault("gc.temp_age", "24h"))
+	if err != nil || d <= 0 {
+		logrus.Warnf("Invalid gc.temp_age configuration: %s", config.Get("gc.temp_age"))
+		d = 24 * time.Hour
+	}
+	return d
+}
+
+// CollectGarbage removes builder containers leaked by builds, e.g. when the
+// server crashed in the middle of a build, images built for plugins that
+// are no longer used by any container, and temporary files left by uploads
+// and deployments. Nothing is removed in dry run.
+func (cli DockerClient) CollectGarbage(ctx context.Context, dryRun bool) (*GCResult, error) {
+	clients := []DockerClient{cli}
+	if len(cli.nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range cli.nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	res := &GCResult{DryRun: dryRun}
+	before := time.Now().Add(-gcGrace()).Unix()
+	for _, c := range clients {
+		if err := c.collectBuilders(ctx, res, before); err != nil {
+			return res, err
+		}
+		if err := c.collectImages(ctx, res, before); err != nil {
+			return res, err
+		}
+	}
+	collectTempFiles(res, time.Now().Add(-gcTempAge()))
+	return res, nil
+}
+
+func (cli DockerClient) collectBuilders(ctx context.Context, res *GCResult, before int64) error {
+	args := filters.NewArgs()
+	args.Add("label", BUILDER_KEY)
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+	if err != nil {
+		return err
+	}
+
+	for _, b := range list {
+		if b.Created > before || isBuilderKnown(b.ID) {
+			continue
+		}
+		res.Builders = append(res.Builders, b.ID)
+		if !res.DryRun {
+			rmopts := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+			if err := cli.ContainerRemove(ctx, b.ID, rmopts); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			} else {
+				logrus.Infof("Removed orphaned builder %s", shortID(b.ID))
+			}
+		}
+	}
+	return nil
+}
+
+func (cli DockerClient) collectImages(ctx context.Context, res *GCResult, before int64) error {
+	args := filters.NewArgs()
+	args.Add("label", IMAGE_PLUGIN_KEY)
+	images, err := cli.ImageList(ctx, types.ImageListOptions{Filters: args})
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return nil
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for _, c := range containers {
+		used[c.ImageID] = true
+		used[c.Image] = true
+	}
+
+	for _, image := range images {
+		if image.Created > before || used[image.ID] {
+			continue
+		}
+		res.Images = append(res.Images, image.ID)
+		if !res.DryRun {
+			rmopts := types.ImageRemoveOptions{Force: false, PruneChildren: true}
+			if _, err := cli.ImageRemove(ctx, image.ID, rmopts); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			} else {
+				logrus.Infof("Removed unused image %s", shortImageID(image.ID))
+			}
+		}
+	}
+	return nil
+}
+
+// The names of temporary files created by PrepareRepo and uploads.
+var tempFilePattern = regexp.MustCompile(`^(deploy|upload)[0-9]+$`)
+
+func collectTempFiles(res *GCResult, before time.Time) {
+	dir := os.TempDir()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		res.Errors = append(res.Errors, err.Error())
+		return
+	}
+
+	for _, fi := range files {
+		if !tempFilePattern.MatchString(fi.Name()) || fi.ModTime().After(before) {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		res.TempFiles = append(res.TempFiles, path)
+		if !res.DryRun {
+			if err := os.RemoveAll(path); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			} else {
+				logrus.Infof("Removed stale temporary file %s", path)
+			}
+		}
+	}
+}
+
+// RunGarbageCollector collects garbage every gc.interval until the stop
+// channel is closed. The collector is disabled if the interval is zero,
+// and only reports garbage found if gc.dry_run is true.
+func (cli DockerClient) RunGarbageCollector(stop <-chan bool) {
+	interval, err := time.ParseDuration(config.GetOrDefault("gc.interval", "1h"))
+	if err != nil {
+		logrus.Warnf("Invalid gc.interval configuration: %s", config.Get("gc.interval"))
+		interval = time.Hour
+	}
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			dryRun, _ := strconv.ParseBool(config.Get("gc.dry_run"))
+			res, err := cli.CollectGarbage(context.Background(), dryRun)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to collect garbage")
+				continue
+			}
+			if dryRun && len(res.Builders)+len(res.Images)+len(res.TempFiles) != 0 {
+				logrus.Infof("Garbage found: %d builders, %d images, %d temporary files",
+					len(res.Builders), len(res.Images), len(res.TempFiles))
+			}
+		}
+	}
+}
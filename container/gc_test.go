@@ -0,0 +1,56 @@
+package container_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/config"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Garbage collection", func() {
+	var stale, fresh string
+
+	BeforeEach(func() {
+		config.Set("gc.temp_age", "1h")
+
+		f, err := ioutil.TempFile("", "upload")
+		Expect(err).NotTo(HaveOccurred())
+		f.Close()
+		stale = f.Name()
+		old := time.Now().Add(-2 * time.Hour)
+		Expect(os.Chtimes(stale, old, old)).To(Succeed())
+
+		f, err = ioutil.TempFile("", "upload")
+		Expect(err).NotTo(HaveOccurred())
+		f.Close()
+		fresh = f.Name()
+	})
+
+	AfterEach(func() {
+		config.Remove("gc.temp_age")
+		os.Remove(stale)
+		os.Remove(fresh)
+	})
+
+	It("should only report garbage in dry run", func() {
+		res, err := dockerCli.CollectGarbage(context.Background(), true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.DryRun).To(BeTrue())
+		Expect(res.TempFiles).To(ContainElement(stale))
+		Expect(res.TempFiles).NotTo(ContainElement(fresh))
+		Expect(stale).To(BeAnExistingFile())
+	})
+
+	It("should remove stale temporary files", func() {
+		res, err := dockerCli.CollectGarbage(context.Background(), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.TempFiles).To(ContainElement(stale))
+		Expect(stale).NotTo(BeAnExistingFile())
+		Expect(fresh).To(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,459 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Health status of monitored containers.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health describes the result of health checks performed on a container.
+type Health struct {
+	Status    string
+	Failures  int
+	Restarts  int
+	LastCheck time.Time
+	Error     string
+}
+
+var healthRegistry = struct {
+	sync.RWMutex
+	m map[string]*Health
+}{m: make(map[string]*Health)}
+
+// HealthOf returns the health of the container, or nil if the container
+// has no health check declared or is not monitored.
+func HealthOf(id string) *Health {
+	healthRegistry.RLock()
+	defer healthRegistry.RUnlock()
+	if h := healthRegistry.m[id]; h != nil {
+		health := *h
+		return &health
+	}
+	return nil
+}
+
+func setHealth(id string, h Health) {
+	healthRegistry.Lock()
+	healthRegistry.m[id] = &h
+	healthRegistry.Unlock()
+}
+
+func removeHealth(id string) {
+	healthRegistry.Lock()
+	delete(healthRegistry.m, id)
+	healthRegistry.Unlock()
+}
+
+// IsUnhealthy returns true if the container failed health checks.
+func (c *Container) IsUnhealthy() bool {
+	h := HealthOf(c.ID)
+	return h != nil && h.Status == HealthUnhealthy
+}
+
+const monitorTick = 5 * time.Second
+
+// Monitor probes containers with health checks declared in plugin manifests.
+// An unhealthy container is restarted, and a framework container is replaced
+// by a new container if it's still unhealthy after too many restarts.
+// Restarts are delayed with exponential backoff. The monitor also detects
+// containers crash looping with the restart policy.
+type Monitor struct {
+	cli         DockerClient
+	interval    time.Duration
+	timeout     time.Duration
+	retries     int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxRestarts int
+	probes      map[string]*probe
+
+	crashRestarts int
+	crashWindow   time.Duration
+	crashes       map[string]*crashState
+
+	// OnCrashLoop is called when a container enters a crash loop.
+	OnCrashLoop func(c *Container, cl *CrashLoop)
+}
+
+// probe keeps the health check state of a container. The state is owned
+// by the probing goroutine while the probe is busy.
+type probe struct {
+	mu        sync.Mutex
+	busy      bool
+	check     *manifest.HealthCheck
+	interval  time.Duration
+	timeout   time.Duration
+	retries   int
+	next      time.Time
+	backoff   time.Duration
+	restartAt time.Time
+	health    Health
+}
+
+func NewMonitor(cli DockerClient) (*Monitor, error) {
+	m := &Monitor{cli: cli, probes: make(map[string]*probe), crashes: make(map[string]*crashState)}
+
+	var err error
+	if m.interval, err = time.ParseDuration(config.GetOrDefault("health.interval", "30s")); err != nil {
+		return nil, fmt.Errorf("Invalid health.interval configuration: %s", config.Get("health.interval"))
+	}
+	if m.timeout, err = time.ParseDuration(config.GetOrDefault("health.timeout", "5s")); err != nil {
+		return nil, fmt.Errorf("Invalid health.timeout configuration: %s", config.Get("health.timeout"))
+	}
+	if m.retries, err = strconv.Atoi(config.GetOrDefault("health.retries", "3")); err != nil || m.retries <= 0 {
+		return nil, fmt.Errorf("Invalid health.retries configuration: %s", config.Get("health.retries"))
+	}
+	if m.backoff, err = time.ParseDuration(config.GetOrDefault("health.restart.backoff", "10s")); err != nil {
+		return nil, fmt.Errorf("Invalid health.restart.backoff configuration: %s", config.Get("health.restart.backoff"))
+	}
+	if m.maxBackoff, err = time.ParseDuration(config.GetOrDefault("health.restart.max_backoff", "5m")); err != nil {
+		return nil, fmt.Errorf("Invalid health.restart.max_backoff configuration: %s", config.Get("health.restart.max_backoff"))
+	}
+	if m.maxRestarts, err = strconv.Atoi(config.GetOrDefault("health.restart.max", "3")); err != nil || m.maxRestarts < 0 {
+		return nil, fmt.Errorf("Invalid health.restart.max configuration: %s", config.Get("health.restart.max"))
+	}
+	if m.crashRestarts, err = strconv.Atoi(config.GetOrDefault("health.crashloop.restarts", "3")); err != nil || m.crashRestarts <= 0 {
+		return nil, fmt.Errorf("Invalid health.crashloop.restarts configuration: %s", config.Get("health.crashloop.restarts"))
+	}
+	if m.crashWindow, err = time.ParseDuration(config.GetOrDefault("health.crashloop.window", "10m")); err != nil {
+		return nil, fmt.Errorf("Invalid health.crashloop.window configuration: %s", config.Get("health.crashloop.window"))
+	}
+	return m, nil
+}
+
+// Run the monitor loop until the stop channel is closed.
+func (m *Monitor) Run(stop <-chan bool) {
+	ticker := time.NewTicker(monitorTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.probeAll()
+		}
+	}
+}
+
+func (m *Monitor) probeAll() {
+	ctx := context.Background()
+	containers, err := m.cli.FindInNamespace(ctx, "")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to find containers for health checks")
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		seen[c.ID] = true
+		m.detectCrashLoop(c, now)
+
+		p := m.probes[c.ID]
+		if p == nil {
+			if p = m.newProbe(ctx, c); p == nil {
+				continue // try again later
+			}
+			m.probes[c.ID] = p
+		}
+		if p.check == nil || !c.State.Running || m.crashes[c.ID].looping || !p.acquire(now) {
+			continue
+		}
+
+		// probe in background since recovering an unhealthy container
+		// may take a long time
+		go func(c *Container, p *probe) {
+			defer p.release()
+			m.probe(ctx, c, p)
+		}(c, p)
+	}
+
+	// forget removed containers
+	for id := range m.probes {
+		if !seen[id] {
+			delete(m.probes, id)
+			removeHealth(id)
+		}
+	}
+	for id := range m.crashes {
+		if !seen[id] {
+			delete(m.crashes, id)
+			removeCrashLoop(id)
+		}
+	}
+}
+
+func (p *probe) acquire(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.busy || now.Before(p.next) {
+		return false
+	}
+	p.busy = true
+	p.next = now.Add(p.interval)
+	return true
+}
+
+func (p *probe) release() {
+	p.mu.Lock()
+	p.busy = false
+	p.mu.Unlock()
+}
+
+// newProbe creates the probe from the health check declared in plugin
+// manifest of the container. Returns nil if the manifest cannot be read.
+func (m *Monitor) newProbe(ctx context.Context, c *Container) *probe {
+	p := &probe{interval: m.interval, timeout: m.timeout, retries: m.retries}
+
+	meta, err := readPluginManifestFromContainer(ctx, c)
+	if err != nil {
+		return nil
+	}
+	if meta.HealthCheck == nil {
+		return p
+	}
+
+	hc := meta.HealthCheck
+	if err = ValidateHealthCheck(hc); err != nil {
+		logrus.WithError(err).Warnf("Ignored health check of %s", c.FQDN())
+		return p
+	}
+	if hc.Interval != "" {
+		p.interval, _ = time.ParseDuration(hc.Interval)
+	}
+	if hc.Timeout != "" {
+		p.timeout, _ = time.ParseDuration(hc.Timeout)
+	}
+	if hc.Retries > 0 {
+		p.retries = hc.Retries
+	}
+
+	p.check = hc
+	p.health.Status = HealthStarting
+	setHealth(c.ID, p.health)
+	return p
+}
+
+// ValidateHealthCheck returns an error if the health check declared in the
+// plugin manifest is malformed.
+func ValidateHealthCheck(hc *manifest.HealthCheck) error {
+	switch hc.Type {
+	case "http", "tcp":
+		if hc.Port <= 0 {
+			return fmt.Errorf("%s health check requires a port", hc.Type)
+		}
+	case "exec":
+		if hc.Command == "" {
+			return errors.New("exec health check requires a command")
+		}
+	default:
+		return fmt.Errorf("unsupported health check type: %q", hc.Type)
+	}
+	for _, d := range []string{hc.Interval, hc.Timeout} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("invalid health check duration: %q", d)
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) probe(ctx context.Context, c *Container, p *probe) {
+	err := check(ctx, c, p.check, p.timeout)
+
+	h := &p.health
+	h.LastCheck = time.Now()
+	if err == nil {
+		h.Status, h.Failures, h.Error = HealthHealthy, 0, ""
+		p.backoff = 0
+	} else {
+		h.Failures++
+		h.Error = err.Error()
+		if h.Failures >= p.retries {
+			h.Status = HealthUnhealthy
+		}
+	}
+	setHealth(c.ID, *h)
+
+	if h.Status == HealthUnhealthy {
+		m.recover(ctx, c, p)
+	}
+}
+
+func check(ctx context.Context, c *Container, hc *manifest.HealthCheck, timeout time.Duration) error {
+	addr := net.JoinHostPort(c.IP(), strconv.Itoa(int(hc.Port)))
+
+	switch hc.Type {
+	case "http":
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get("http://" + addr + hc.Path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected response status: %s", resp.Status)
+		}
+		return nil
+
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	default:
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err := c.ExecQ(ctx, "", "/usr/bin/cwctl", "sh", "sh", "-c", hc.Command)
+		if err == nil && ctx.Err() != nil {
+			err = fmt.Errorf("health check timed out after %s", timeout)
+		}
+		return err
+	}
+}
+
+// The maximum time to wait for a deployed container becoming healthy, and
+// the timeout of a health check if not declared by the plugin.
+var (
+	deployHealthWindow  = 30 * time.Second
+	deployHealthTimeout = 5 * time.Second
+)
+
+// checkDeployed runs the health check declared by the plugin on a deployed
+// container, until the check passes or the deadline exceeded. Returns an
+// error if the container is still unhealthy, which fails the deployment.
+func checkDeployed(ctx context.Context, c *Container, deadline time.Time, log *serverlog.ServerLog) error {
+	meta, err := readPluginManifestFromContainer(ctx, c)
+	if err != nil || meta.HealthCheck == nil || ValidateHealthCheck(meta.HealthCheck) != nil {
+		return nil
+	}
+
+	hc, timeout := meta.HealthCheck, deployHealthTimeout
+	if hc.Timeout != "" {
+		timeout, _ = time.ParseDuration(hc.Timeout)
+	}
+	if d := time.Now().Add(deployHealthWindow); d.Before(deadline) {
+		deadline = d
+	}
+
+	for {
+		if err = check(ctx, c, hc, timeout); err == nil {
+			reportDeploy(log, c, DeployEventHealthy, 0, nil)
+			return nil
+		}
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			reportDeploy(log, c, DeployEventUnhealthy, 0, err)
+			return fmt.Errorf("%s: container is unhealthy after deployment: %v", c.ID, err)
+		}
+		time.Sleep(rollingPollInterval)
+	}
+}
+
+// recover restarts or replaces an unhealthy container. Consecutive
+// recoveries are delayed with exponential backoff.
+func (m *Monitor) recover(ctx context.Context, c *Container, p *probe) {
+	now := time.Now()
+	if now.Before(p.restartAt) {
+		return
+	}
+	if p.backoff == 0 {
+		p.backoff = m.backoff
+	} else if p.backoff *= 2; p.backoff > m.maxBackoff {
+		p.backoff = m.maxBackoff
+	}
+	p.restartAt = now.Add(p.backoff)
+
+	h := &p.health
+	if h.Restarts >= m.maxRestarts && c.Category().IsFramework() {
+		logrus.Warnf("Replacing unhealthy container %s of %s: %s", c.ID[:12], c.FQDN(), h.Error)
+		nc, err := m.cli.replace(ctx, c)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to replace unhealthy container %s", c.ID[:12])
+		} else {
+			logrus.Infof("Replaced unhealthy container %s with %s", c.ID[:12], nc.ID[:12])
+		}
+		return
+	}
+
+	logrus.Warnf("Restarting unhealthy container %s of %s: %s", c.ID[:12], c.FQDN(), h.Error)
+	h.Restarts++
+	h.Status, h.Failures = HealthStarting, 0
+	setHealth(c.ID, *h)
+	if err := c.Restart(ctx, nil); err != nil {
+		logrus.WithError(err).Errorf("Failed to restart unhealthy container %s", c.ID[:12])
+	}
+}
+
+// replace creates a new container with the same configuration, environment
+// and repository as the given framework container, then removes the old
+// container. Persistent volumes are taken over by the new container.
+func (cli DockerClient) replace(ctx context.Context, c *Container) (nc *Container, err error) {
+	plugin, err := readPluginManifestFromContainer(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := cli.FindApplications(ctx, c.Name, c.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := c.VolumeNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := replicaOptions(c, plugin, len(existing)+1, nil)
+	opts.Volumes = volumes
+	cs, err := cli.Create(ctx, opts)
+	defer func() {
+		if err != nil {
+			for _, c := range cs {
+				c.Destroy(ctx)
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, errors.New("no container created")
+	}
+
+	if err = CopyEnv(ctx, c, cs[0]); err != nil {
+		return nil, err
+	}
+	repo, _, err := c.CopyFromContainer(ctx, c.ID, c.RepoDir()+"/.")
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+	if err = cli.DistributeRepo(ctx, cs, repo, true, DeployOptions{}, nil); err != nil {
+		return nil, err
+	}
+
+	if er := c.Destroy(ctx); er != nil {
+		logrus.WithError(er).Warnf("Failed to remove unhealthy container %s", c.ID[:12])
+	}
+	return cs[0], nil
+}
@@ -0,0 +1,34 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Health check", func() {
+	It("should accept valid health checks", func() {
+		for _, hc := range []*manifest.HealthCheck{
+			{Type: "http", Port: 8080, Path: "/health"},
+			{Type: "tcp", Port: 3306, Interval: "10s", Timeout: "2s", Retries: 5},
+			{Type: "exec", Command: "pg_isready"},
+		} {
+			Expect(container.ValidateHealthCheck(hc)).To(Succeed())
+		}
+	})
+
+	It("should reject malformed health checks", func() {
+		for _, hc := range []*manifest.HealthCheck{
+			{Type: "udp", Port: 53},
+			{Type: "http", Path: "/health"},
+			{Type: "tcp"},
+			{Type: "exec"},
+			{Type: "tcp", Port: 3306, Interval: "often"},
+			{Type: "tcp", Port: 3306, Timeout: "-1s"},
+		} {
+			Expect(container.ValidateHealthCheck(hc)).NotTo(Succeed())
+		}
+	})
+})
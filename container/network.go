@@ -0,0 +1,322 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/network"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+)
+
+// The prefix of application network names.
+const appNetworkPrefix = "cloudway-"
+
+// NetworkIsolation returns true if containers of each application are
+// isolated in a dedicated network, configured by "network.isolation".
+// Service containers are only connected to the application network, so
+// they are reachable only from framework containers of the application,
+// and of other applications granted access. Framework containers are also
+// connected to the shared network configured by "network" to be reachable
+// from the proxy.
+func NetworkIsolation() bool {
+	isolation, err := strconv.ParseBool(config.GetOrDefault("network.isolation", "false"))
+	if err != nil {
+		logrus.Warnf("Invalid network.isolation configuration: %s", config.Get("network.isolation"))
+		return false
+	}
+	return isolation
+}
+
+// AppNetworkName returns the name of the dedicated network of the application.
+func AppNetworkName(name, namespace string) string {
+	return appNetworkPrefix + name + "-" + namespace
+}
+
+// Returns the name of the application network the container belongs to.
+func (c *Container) appNetwork() string {
+	return AppNetworkName(c.Name, c.Namespace)
+}
+
+// GrantedNetworks returns names of other applications whose networks the
+// container is connected to.
+func (c *Container) GrantedNetworks() []string {
+	var apps []string
+	own, suffix := c.appNetwork(), "-"+c.Namespace
+	for name := range c.NetworkSettings.Networks {
+		if name != own && strings.HasPrefix(name, appNetworkPrefix) && strings.HasSuffix(name, suffix) {
+			apps = append(apps, strings.TrimSuffix(strings.TrimPrefix(name, appNetworkPrefix), suffix))
+		}
+	}
+	return apps
+}
+
+// NetworkConfig is the configuration of networks created by the platform,
+// including the shared network configured by "network" if it doesn't exist.
+type NetworkConfig struct {
+	// The driver of the shared network, configured by "network.driver":
+	// bridge, overlay or macvlan. The overlay driver is required if the
+	// containers are placed on multiple Docker hosts.
+	Driver string
+
+	// Enable IPv6 networking, configured by "network.ipv6".
+	IPv6 bool
+
+	// The IPv4 subnet and gateway of the shared network, configured by
+	// "network.subnet" and "network.gateway". Allocated by Docker if empty.
+	Subnet  string
+	Gateway string
+
+	// The IPv6 subnet of the shared network, configured by "network.subnet6".
+	Subnet6 string
+
+	// The host interface of macvlan networks, configured by "network.parent".
+	Parent string
+}
+
+// The network drivers supported by the platform.
+var networkDrivers = []string{"bridge", "overlay", "macvlan"}
+
+// InvalidNetworkConfigError reports invalid network configuration.
+type InvalidNetworkConfigError string
+
+func (e InvalidNetworkConfigError) Error() string {
+	return "Invalid network configuration: " + string(e)
+}
+
+// LoadNetworkConfig loads and validates the network configuration.
+func LoadNetworkConfig() (cfg NetworkConfig, err error) {
+	cfg.Driver = config.GetOrDefault("network.driver", "bridge")
+	cfg.Subnet = config.Get("network.subnet")
+	cfg.Gateway = config.Get("network.gateway")
+	cfg.Subnet6 = config.Get("network.subnet6")
+	cfg.Parent = config.Get("network.parent")
+	if v := config.Get("network.ipv6"); v != "" {
+		if cfg.IPv6, err = strconv.ParseBool(v); err != nil {
+			return cfg, InvalidNetworkConfigError("network.ipv6 must be a boolean value")
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks the network configuration.
+func (cfg NetworkConfig) Validate() error {
+	valid := false
+	for _, d := range networkDrivers {
+		valid = valid || cfg.Driver == d
+	}
+	if !valid {
+		return InvalidNetworkConfigError(fmt.Sprintf("unsupported driver %q, must be one of %s",
+			cfg.Driver, strings.Join(networkDrivers, ", ")))
+	}
+
+	if cfg.Subnet != "" {
+		ip, subnet, err := net.ParseCIDR(cfg.Subnet)
+		if err != nil || ip.To4() == nil {
+			return InvalidNetworkConfigError("network.subnet must be an IPv4 CIDR")
+		}
+		if cfg.Gateway != "" {
+			if gw := net.ParseIP(cfg.Gateway); gw == nil || !subnet.Contains(gw) {
+				return InvalidNetworkConfigError("network.gateway must be an address in network.subnet")
+			}
+		}
+	} else if cfg.Gateway != "" {
+		return InvalidNetworkConfigError("network.gateway requires network.subnet")
+	}
+
+	if cfg.Subnet6 != "" {
+		ip, _, err := net.ParseCIDR(cfg.Subnet6)
+		if err != nil || ip.To4() != nil {
+			return InvalidNetworkConfigError("network.subnet6 must be an IPv6 CIDR")
+		}
+		if !cfg.IPv6 {
+			return InvalidNetworkConfigError("network.subnet6 requires network.ipv6")
+		}
+	}
+
+	if cfg.Parent != "" && cfg.Driver != "macvlan" {
+		return InvalidNetworkConfigError("network.parent requires the macvlan driver")
+	}
+	return nil
+}
+
+// sharedNetwork returns options to create the shared network.
+func (cfg NetworkConfig) sharedNetwork() types.NetworkCreate {
+	options := types.NetworkCreate{Driver: cfg.Driver, EnableIPv6: cfg.IPv6}
+	if cfg.Subnet != "" {
+		options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{
+			Subnet:  cfg.Subnet,
+			Gateway: cfg.Gateway,
+		})
+	}
+	if cfg.Subnet6 != "" {
+		options.IPAM.Config = append(options.IPAM.Config, network.IPAMConfig{Subnet: cfg.Subnet6})
+	}
+	if cfg.Parent != "" {
+		options.Options = map[string]string{"parent": cfg.Parent}
+	}
+	return options
+}
+
+// appNetwork returns options to create application networks, which use
+// address pools of Docker daemons.
+func (cfg NetworkConfig) appNetwork(labels map[string]string) types.NetworkCreate {
+	driver := "bridge"
+	if cfg.Driver == "overlay" {
+		driver = "overlay"
+	}
+	return types.NetworkCreate{Driver: driver, EnableIPv6: cfg.IPv6, Labels: labels}
+}
+
+// Networks predefined by Docker, or container network modes, which cannot
+// be created.
+func predefinedNetwork(name string) bool {
+	switch name {
+	case "bridge", "host", "none", "default":
+		return true
+	}
+	return strings.HasPrefix(name, "container:")
+}
+
+// ensureNetwork creates the shared network on the Docker host if it doesn't
+// exist, using the network configuration.
+func (cli DockerClient) ensureNetwork(ctx context.Context, name string) error {
+	if name == "" || predefinedNetwork(name) {
+		return nil
+	}
+	netcfg, err := LoadNetworkConfig()
+	if err != nil {
+		return err
+	}
+	return cli.createNetwork(ctx, name, netcfg.sharedNetwork())
+}
+
+// createAppNetwork creates the network of the application if it doesn't
+// exist on the Docker host. Application networks are created with the
+// overlay driver if the shared network uses the overlay driver, so they
+// span the cluster, or the bridge driver otherwise.
+func (cli DockerClient) createAppNetwork(ctx context.Context, name, namespace string) (string, error) {
+	netcfg, err := LoadNetworkConfig()
+	if err != nil {
+		return "", err
+	}
+
+	netName := AppNetworkName(name, namespace)
+	return netName, cli.createNetwork(ctx, netName, netcfg.appNetwork(map[string]string{
+		APP_NAME_KEY:      name,
+		APP_NAMESPACE_KEY: namespace,
+	}))
+}
+
+// createNetwork creates the network if it doesn't exist on the Docker host.
+func (cli DockerClient) createNetwork(ctx context.Context, name string, options types.NetworkCreate) error {
+	if _, err := cli.NetworkInspect(ctx, name); err == nil {
+		return nil
+	} else if !client.IsErrNetworkNotFound(err) {
+		return err
+	}
+
+	options.CheckDuplicate = true
+	if _, err := cli.NetworkCreate(ctx, name, options); err != nil {
+		// the network may be created concurrently
+		if _, ierr := cli.NetworkInspect(ctx, name); ierr == nil {
+			return nil
+		}
+		return err
+	}
+	logrus.Debugf("Created %s network %s", options.Driver, name)
+	return nil
+}
+
+// connectNetworks connects the new framework container to the application
+// network and networks of other applications granted access.
+func (cli DockerClient) connectNetworks(ctx context.Context, id string, cfg *createConfig, appNet string) error {
+	if err := cli.NetworkConnect(ctx, appNet, id, nil); err != nil {
+		return err
+	}
+	for _, app := range cfg.Networks {
+		netName, err := cli.createAppNetwork(ctx, app, cfg.Namespace)
+		if err != nil {
+			return err
+		}
+		if err = cli.NetworkConnect(ctx, netName, id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectNetworks connects framework containers of the application to the
+// networks of granted applications in the same namespace, and disconnects
+// them from networks of applications no longer granted. Containers created
+// later are connected by the Networks create option.
+func (cli DockerClient) ConnectNetworks(ctx context.Context, name, namespace string, granted []string) error {
+	if !NetworkIsolation() {
+		return nil
+	}
+
+	cs, err := cli.FindApplications(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		connected := make(map[string]bool)
+		for _, app := range c.GrantedNetworks() {
+			connected[app] = true
+		}
+
+		for _, app := range granted {
+			if connected[app] {
+				delete(connected, app)
+				continue
+			}
+			netName, err := c.createAppNetwork(ctx, app, namespace)
+			if err != nil {
+				return err
+			}
+			if err = c.NetworkConnect(ctx, netName, c.ID, nil); err != nil {
+				return err
+			}
+		}
+
+		for app := range connected {
+			err := c.NetworkDisconnect(ctx, AppNetworkName(app, namespace), c.ID, true)
+			if err != nil && !client.IsErrNetworkNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveAppNetwork removes the network of the application from all Docker
+// hosts. Containers of the application must be removed, and containers of
+// other applications must be disconnected.
+func (cli DockerClient) RemoveAppNetwork(ctx context.Context, name, namespace string) error {
+	clients := []DockerClient{cli}
+	if len(cli.nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range cli.nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	netName := AppNetworkName(name, namespace)
+	for _, c := range clients {
+		err := c.NetworkRemove(ctx, netName)
+		if err != nil && !client.IsErrNetworkNotFound(err) {
+			return err
+		}
+		if err == nil {
+			logrus.Debugf("Removed network %s", netName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package container_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/container"
+)
+
+var _ = Describe("Network configuration", func() {
+	It("should accept supported drivers and subnets", func() {
+		for _, cfg := range []container.NetworkConfig{
+			{Driver: "bridge"},
+			{Driver: "overlay", Subnet: "10.10.0.0/16", Gateway: "10.10.0.1"},
+			{Driver: "bridge", IPv6: true, Subnet6: "fd00:cafe::/64"},
+			{Driver: "macvlan", Subnet: "192.168.1.0/24", Parent: "eth0"},
+		} {
+			Expect(cfg.Validate()).To(Succeed(), "%+v", cfg)
+		}
+	})
+
+	It("should reject invalid configurations", func() {
+		for _, cfg := range []container.NetworkConfig{
+			{Driver: "host"},
+			{Driver: "bridge", Subnet: "fd00:cafe::/64"},
+			{Driver: "bridge", Subnet: "10.10.0.0/16", Gateway: "10.20.0.1"},
+			{Driver: "bridge", Gateway: "10.10.0.1"},
+			{Driver: "bridge", IPv6: true, Subnet6: "10.10.0.0/16"},
+			{Driver: "bridge", Subnet6: "fd00:cafe::/64"},
+			{Driver: "bridge", Parent: "eth0"},
+		} {
+			Expect(cfg.Validate()).To(BeAssignableToTypeOf(container.InvalidNetworkConfigError("")), "%+v", cfg)
+		}
+	})
+})
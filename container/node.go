@@ -0,0 +1,158 @@
+package container
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/engine-api/client"
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The daemon label that specifies the maximum number of application
+// containers can be placed on a Docker host.
+const NODE_CAPACITY_LABEL = "com.cloudway.node.capacity"
+
+// Node is a Docker host that application containers can be placed on.
+type Node struct {
+	Host string
+	DockerClient
+}
+
+// NoCapacityError indicates that all Docker hosts reached their capacity.
+type NoCapacityError struct{}
+
+func (e NoCapacityError) Error() string {
+	return "No Docker host has capacity for new containers"
+}
+
+func (e NoCapacityError) HTTPErrorStatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// loadNodes connects to Docker hosts configured by "docker.nodes", a
+// comma separated list of daemon URLs.
+func loadNodes() ([]*Node, error) {
+	var nodes []*Node
+	for _, host := range strings.Split(config.Get("docker.nodes"), ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		cli, err := client.NewClient(host, os.Getenv("DOCKER_API_VERSION"), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", host, err)
+		}
+		nodes = append(nodes, &Node{Host: host, DockerClient: NewClient(cli)})
+	}
+
+	// every node searches containers in all nodes, but creates containers
+	// on its own host
+	for _, n := range nodes {
+		n.nodes = nodes
+	}
+	return nodes, nil
+}
+
+// Nodes returns the Docker hosts managed by the client, or nil if the
+// client only manages a single Docker host.
+func (cli DockerClient) Nodes() []*Node {
+	return cli.nodes
+}
+
+// place selects the Docker host with the most spare capacity for new
+// containers. The capacity of a host is specified by the daemon label
+// com.cloudway.node.capacity, hosts without the label are unlimited.
+func (cli DockerClient) place(ctx context.Context) (DockerClient, error) {
+	if len(cli.nodes) == 0 {
+		return cli, nil
+	}
+
+	spare := make([]int, len(cli.nodes))
+	errs := make([]error, len(cli.nodes))
+
+	var wg sync.WaitGroup
+	for i, n := range cli.nodes {
+		wg.Add(1)
+		go func(i int, n *Node) {
+			defer wg.Done()
+			spare[i], errs[i] = n.spareCapacity(ctx)
+		}(i, n)
+	}
+	wg.Wait()
+
+	best := -1
+	for i := range cli.nodes {
+		if errs[i] == nil && spare[i] > 0 && (best == -1 || spare[i] > spare[best]) {
+			best = i
+		}
+	}
+	if best == -1 {
+		for _, err := range errs {
+			if err != nil {
+				return cli, err
+			}
+		}
+		return cli, NoCapacityError{}
+	}
+	return cli.nodes[best].DockerClient, nil
+}
+
+func (n *Node) spareCapacity(ctx context.Context) (int, error) {
+	info, err := n.Info(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", n.Host, err)
+	}
+
+	capacity := math.MaxInt32
+	for _, label := range info.Labels {
+		if strings.HasPrefix(label, NODE_CAPACITY_LABEL+"=") {
+			capacity, err = strconv.Atoi(label[len(NODE_CAPACITY_LABEL)+1:])
+			if err != nil {
+				return 0, fmt.Errorf("%s: invalid capacity label: %s", n.Host, label)
+			}
+		}
+	}
+
+	args := filters.NewArgs()
+	args.Add("label", APP_NAME_KEY)
+	list, err := n.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", n.Host, err)
+	}
+	return capacity - len(list), nil
+}
+
+// findInNodes finds containers in all Docker hosts concurrently.
+func findInNodes(cli DockerClient, ctx context.Context, category manifest.Category, service, name, namespace string) ([]*Container, error) {
+	results := make([][]*Container, len(cli.nodes))
+	errs := make([]error, len(cli.nodes))
+
+	var wg sync.WaitGroup
+	for i, n := range cli.nodes {
+		wg.Add(1)
+		go func(i int, n *Node) {
+			defer wg.Done()
+			results[i], errs[i] = findInHost(n.DockerClient, ctx, category, service, name, namespace)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var containers []*Container
+	for i := range cli.nodes {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		containers = append(containers, results[i]...)
+	}
+	return containers, nil
+}
@@ -0,0 +1,90 @@
+package container_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/manifest"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Nodes", func() {
+	const (
+		NAME      = "nodetest"
+		NAMESPACE = "nodetest"
+	)
+
+	// fakeDaemon serves a Docker host with a single framework container
+	// of the test application.
+	var fakeDaemon = func(id string) *httptest.Server {
+		labels := map[string]string{
+			container.APP_NAME_KEY:      NAME,
+			container.APP_NAMESPACE_KEY: NAMESPACE,
+			container.CATEGORY_KEY:      string(manifest.Framework),
+		}
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/events"):
+				// closing the event stream makes the index reload
+				w.WriteHeader(http.StatusOK)
+			case strings.HasSuffix(r.URL.Path, "/containers/json"):
+				json.NewEncoder(w).Encode([]map[string]interface{}{
+					{"Id": id, "Labels": labels, "Created": 1},
+				})
+			case strings.HasSuffix(r.URL.Path, "/containers/"+id+"/json"):
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"Id":     id,
+					"Name":   "/" + id,
+					"Config": map[string]interface{}{"Labels": labels},
+					"State":  map[string]interface{}{"Running": true},
+				})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	}
+
+	var servers []*httptest.Server
+
+	BeforeEach(func() {
+		servers = []*httptest.Server{fakeDaemon("node1"), fakeDaemon("node2")}
+		var hosts []string
+		for _, s := range servers {
+			hosts = append(hosts, strings.Replace(s.URL, "http://", "tcp://", 1))
+		}
+		config.Set("docker.nodes", strings.Join(hosts, ","))
+	})
+
+	AfterEach(func() {
+		config.Remove("docker.nodes")
+		for _, s := range servers {
+			s.Close()
+		}
+	})
+
+	It("should find containers in all nodes", func() {
+		cli, err := container.NewEnvClient()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cli.Nodes()).To(HaveLen(2))
+
+		found := make(chan []string, 1)
+		go func() {
+			defer GinkgoRecover()
+			cs, err := cli.FindApplications(context.Background(), NAME, NAMESPACE)
+			Expect(err).NotTo(HaveOccurred())
+			var ids []string
+			for _, c := range cs {
+				ids = append(ids, c.ID)
+			}
+			found <- ids
+		}()
+		Eventually(found, "5s").Should(Receive(ConsistOf("node1", "node2")))
+	})
+})
@@ -0,0 +1,222 @@
+package container
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/engine-api/types"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// BuildTargetNotFoundError reports that the build target declared by the
+// plugin is not a stage of the Dockerfile.
+type BuildTargetNotFoundError struct {
+	Plugin string
+	Target string
+}
+
+func (e BuildTargetNotFoundError) Error() string {
+	return fmt.Sprintf("%s: build target %s not found in Dockerfile", e.Plugin, e.Target)
+}
+
+func (e BuildTargetNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// pluginImageName returns the name of the base image built for the plugin.
+// Image names must be lower case, and can't start or end with underscores.
+func pluginImageName(namespace, name, version string) string {
+	repo := "cloudway-plugins/"
+	if namespace != "" {
+		repo += strings.ToLower(strings.Trim(namespace, "_")) + "/"
+	}
+	repo += strings.ToLower(strings.Trim(name, "_"))
+	return repo + ":" + version
+}
+
+// BuildPluginImage builds the base image of plugin containers from the
+// Dockerfile shipped with the plugin in the directory, then records the
+// image and its digest in the plugin manifest. Build arguments declared
+// by the plugin are overridden by the given build arguments. Nothing is
+// done if the plugin has no Dockerfile.
+//
+// The image is built on the first Docker host and copied to other hosts,
+// so all hosts have the same image.
+func (cli DockerClient) BuildPluginImage(ctx context.Context, namespace, dir string, buildArgs map[string]string, log *serverlog.ServerLog) error {
+	if !manifest.HasDockerfile(dir) {
+		return nil
+	}
+
+	meta, err := manifest.Load(dir)
+	if err != nil {
+		return err
+	}
+	if meta.Name == "" || meta.Version == "" {
+		return fmt.Errorf("invalid plugin manifest")
+	}
+
+	dockerfile, err := ioutil.ReadFile(filepath.Join(dir, manifest.DockerfileEntry))
+	if err != nil {
+		return err
+	}
+	if meta.BuildTarget != "" {
+		if dockerfile = selectBuildTarget(dockerfile, meta.BuildTarget); dockerfile == nil {
+			return BuildTargetNotFoundError{Plugin: meta.Name, Target: meta.BuildTarget}
+		}
+	}
+
+	args := make(map[string]string)
+	for k, v := range meta.BuildArgs {
+		args[k] = v
+	}
+	for k, v := range buildArgs {
+		args[k] = v
+	}
+
+	clients := []DockerClient{cli}
+	if len(cli.nodes) != 0 {
+		clients = clients[:0]
+		for _, n := range cli.nodes {
+			clients = append(clients, n.DockerClient)
+		}
+	}
+
+	image := pluginImageName(namespace, meta.Name, meta.Version)
+	digest, err := clients[0].buildPluginImage(ctx, dir, dockerfile, image, args, log)
+	if err != nil {
+		return err
+	}
+	for _, c := range clients[1:] {
+		if err = copyImage(ctx, clients[0], c, image); err != nil {
+			return err
+		}
+	}
+
+	meta.BaseImage = image
+	meta.ImageDigest = digest
+	return manifest.Save(dir, meta)
+}
+
+func (cli DockerClient) buildPluginImage(ctx context.Context, dir string, dockerfile []byte, image string, args map[string]string, log *serverlog.ServerLog) (string, error) {
+	// create the build context from plugin files, replacing the
+	// Dockerfile with the selected build target
+	tarFile, err := ioutil.TempFile("", "docker")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		tarFile.Close()
+		os.Remove(tarFile.Name())
+	}()
+
+	tw := tar.NewWriter(tarFile)
+	if err = archive.CopyFileTree(tw, "", dir, []string{manifest.DockerfileEntry}, false); err != nil {
+		return "", err
+	}
+	if err = archive.AddFile(tw, manifest.DockerfileEntry, 0644, dockerfile); err != nil {
+		return "", err
+	}
+	tw.Close()
+	if _, err = tarFile.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	log.Progress(serverlog.Progress{Phase: StageBuilding, Percent: 0})
+	options := types.ImageBuildOptions{
+		Tags:        []string{image},
+		Dockerfile:  manifest.DockerfileEntry,
+		BuildArgs:   args,
+		Remove:      true,
+		ForceRemove: true,
+	}
+	response, err := cli.ImageBuild(ctx, tarFile, options)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	id, err := readBuildStream(response.Body, &buildProgress{w: log.Stdout(), log: log})
+	if err != nil {
+		return "", err
+	}
+
+	info, _, err := cli.ImageInspectWithRaw(ctx, id, false)
+	if err != nil {
+		return "", err
+	}
+	log.Progress(serverlog.Progress{Phase: StageBuilding, Percent: 100})
+	logrus.Infof("Built plugin image %s (%s)", image, shortImageID(info.ID))
+	return info.ID, nil
+}
+
+// copyImage copies the image between Docker hosts.
+func copyImage(ctx context.Context, src, dst DockerClient, image string) error {
+	r, err := src.ImageSave(ctx, []string{image})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	resp, err := dst.ImageLoad(ctx, r, true)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return err
+}
+
+var fromInstruction = regexp.MustCompile(`(?im)^[ \t]*FROM[ \t]+(?:--\S+[ \t]+)*\S+(?:[ \t]+AS[ \t]+(\S+))?[ \t]*$`)
+
+// selectBuildTarget returns the Dockerfile truncated after the stage with
+// the given name of a multi-stage Dockerfile, so the stage is the last stage
+// built. Returns nil if the stage is not found.
+func selectBuildTarget(dockerfile []byte, target string) []byte {
+	stages := fromInstruction.FindAllSubmatchIndex(dockerfile, -1)
+	for i, loc := range stages {
+		if loc[2] < 0 || !strings.EqualFold(string(dockerfile[loc[2]:loc[3]]), target) {
+			continue
+		}
+		if i+1 < len(stages) {
+			return dockerfile[:stages[i+1][0]]
+		}
+		return dockerfile
+	}
+	return nil
+}
+
+// buildProgress writes the build output, and reports the percentage of
+// completed Dockerfile steps.
+type buildProgress struct {
+	w   io.Writer
+	log *serverlog.ServerLog
+}
+
+var buildStep = regexp.MustCompile(`^Step (\d+)/(\d+) :`)
+
+func (p *buildProgress) Write(b []byte) (int, error) {
+	if m := buildStep.FindSubmatch(b); m != nil {
+		step, _ := strconv.Atoi(string(m[1]))
+		total, _ := strconv.Atoi(string(m[2]))
+		if total > 0 {
+			p.log.Progress(serverlog.Progress{Phase: StageBuilding, Percent: (step - 1) * 100 / total})
+		}
+	}
+	if p.w == nil {
+		return len(b), nil
+	}
+	return p.w.Write(b)
+}
@@ -0,0 +1,121 @@
+// Package cron parses cron schedules and keeps the run history of
+// scheduled application jobs.
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Run records an execution of a scheduled job.
+type Run struct {
+	ID        string
+	Job       string
+	Service   string `json:",omitempty"`
+	Container string
+	Schedule  string
+	Command   string
+	Started   time.Time
+	Finished  time.Time
+	ExitCode  int
+	Error     string `json:",omitempty"`
+	Output    string `json:",omitempty"`
+}
+
+// MaxOutput is the maximum length of job output kept in the run history.
+const MaxOutput = 64 * 1024
+
+// Store keeps a limited number of recent job runs for each application.
+type Store struct {
+	dir  string
+	keep int
+	mu   sync.Mutex
+}
+
+func New() (*Store, error) {
+	dir := config.GetOrDefault("cron.log.dir", "/var/lib/cloudway/cron")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	keep, err := strconv.Atoi(config.GetOrDefault("cron.log.keep", "100"))
+	if err != nil || keep <= 0 {
+		return nil, fmt.Errorf("Invalid cron.log.keep configuration: %s", config.Get("cron.log.keep"))
+	}
+
+	return &Store{dir: dir, keep: keep}, nil
+}
+
+func (s *Store) logFile(namespace, name string) string {
+	return filepath.Join(s.dir, namespace, name+".json")
+}
+
+// Runs returns recent job runs of the application, the most recent first.
+func (s *Store) Runs(namespace, name string) ([]*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(namespace, name)
+}
+
+func (s *Store) load(namespace, name string) ([]*Run, error) {
+	data, err := ioutil.ReadFile(s.logFile(namespace, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*Run
+	err = json.Unmarshal(data, &runs)
+	return runs, err
+}
+
+// Record the job run in the history. Oldest runs exceeding the limit are
+// discarded.
+func (s *Store) Record(namespace, name string, run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := s.load(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	log := []*Run{run}
+	for _, r := range runs {
+		if r.ID != run.ID && len(log) < s.keep {
+			log = append(log, r)
+		}
+	}
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+
+	file := s.logFile(namespace, name)
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// Remove the run history of the application.
+func (s *Store) Remove(namespace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.logFile(namespace, name))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package cron
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCron(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cron Suite")
+}
+
+var _ = Describe("Schedule", func() {
+	// 2016-08-01 is a Monday
+	var at = func(day, hour, min int) time.Time {
+		return time.Date(2016, time.August, day, hour, min, 0, 0, time.UTC)
+	}
+
+	var matches = func(spec string, t time.Time) bool {
+		s, err := Parse(spec)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return s.Matches(t)
+	}
+
+	It("should match every minute", func() {
+		Expect(matches("* * * * *", at(1, 0, 0))).To(BeTrue())
+		Expect(matches("* * * * *", at(15, 13, 47))).To(BeTrue())
+	})
+
+	It("should match values, ranges and steps", func() {
+		Expect(matches("30 2 * * *", at(1, 2, 30))).To(BeTrue())
+		Expect(matches("30 2 * * *", at(1, 2, 31))).To(BeFalse())
+		Expect(matches("*/15 * * * *", at(1, 5, 45))).To(BeTrue())
+		Expect(matches("*/15 * * * *", at(1, 5, 46))).To(BeFalse())
+		Expect(matches("0 9-17/2 * * *", at(1, 11, 0))).To(BeTrue())
+		Expect(matches("0 9-17/2 * * *", at(1, 12, 0))).To(BeFalse())
+		Expect(matches("0,20,40 * * * *", at(1, 3, 40))).To(BeTrue())
+	})
+
+	It("should match day of week", func() {
+		Expect(matches("0 0 * * 1-5", at(1, 0, 0))).To(BeTrue())
+		Expect(matches("0 0 * * 1-5", at(7, 0, 0))).To(BeFalse())
+		Expect(matches("0 0 * * 7", at(7, 0, 0))).To(BeTrue())
+		Expect(matches("@weekly", at(7, 0, 0))).To(BeTrue())
+	})
+
+	It("should match either day of month or day of week if both restricted", func() {
+		Expect(matches("0 0 15 * 1", at(1, 0, 0))).To(BeTrue())
+		Expect(matches("0 0 15 * 1", at(15, 0, 0))).To(BeTrue())
+		Expect(matches("0 0 15 * 1", at(16, 0, 0))).To(BeFalse())
+	})
+
+	It("should reject invalid schedules", func() {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "@never"} {
+			_, err := Parse(spec)
+			Expect(err).To(BeAssignableToTypeOf(InvalidScheduleError{}), spec)
+		}
+	})
+})
+
+var _ = Describe("Store", func() {
+	const (
+		NAMESPACE = "cron_test"
+		NAME      = "test"
+	)
+
+	var store *Store
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "cron")
+		Expect(err).NotTo(HaveOccurred())
+		store = &Store{dir: dir, keep: 3}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(store.dir)
+	})
+
+	It("should keep limited number of runs", func() {
+		for i := 1; i <= 5; i++ {
+			Expect(store.Record(NAMESPACE, NAME, &Run{ID: strconv.Itoa(i), Job: "job"})).To(Succeed())
+		}
+
+		runs, err := store.Runs(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(3))
+		Expect(runs[0].ID).To(Equal("5"))
+		Expect(runs[2].ID).To(Equal("3"))
+
+		Expect(store.Remove(NAMESPACE, NAME)).To(Succeed())
+		runs, err = store.Runs(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(BeEmpty())
+	})
+
+	It("should replace the record of the same run", func() {
+		run := &Run{ID: "1", Job: "job"}
+		Expect(store.Record(NAMESPACE, NAME, run)).To(Succeed())
+		run.ExitCode = 1
+		Expect(store.Record(NAMESPACE, NAME, run)).To(Succeed())
+
+		runs, err := store.Runs(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(1))
+		Expect(runs[0].ExitCode).To(Equal(1))
+	})
+})
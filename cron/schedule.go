@@ -0,0 +1,152 @@
+package cron
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with minute resolution.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// true if both day of month and day of week are restricted, in which
+	// case a day matches if either field matches
+	dayOr bool
+}
+
+type InvalidScheduleError struct {
+	Spec   string
+	Reason string
+}
+
+func (e InvalidScheduleError) Error() string {
+	return fmt.Sprintf("Invalid cron schedule '%s': %s", e.Spec, e.Reason)
+}
+
+func (e InvalidScheduleError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+var predefined = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	min, max int
+}
+
+var (
+	minutes = bounds{0, 59}
+	hours   = bounds{0, 23}
+	doms    = bounds{1, 31}
+	months  = bounds{1, 12}
+	dows    = bounds{0, 7} // both 0 and 7 are Sunday
+)
+
+// Parse a standard five-field cron expression: minute, hour, day of month,
+// month and day of week. Each field is a comma separated list of values,
+// ranges ("1-5") and steps ("*/15", "0-30/10").
+func Parse(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if p, ok := predefined[expr]; ok {
+		expr = p
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, InvalidScheduleError{spec, "expected 5 fields"}
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err == nil {
+		if s.hour, err = parseField(fields[1], hours); err == nil {
+			if s.dom, err = parseField(fields[2], doms); err == nil {
+				if s.month, err = parseField(fields[3], months); err == nil {
+					s.dow, err = parseField(fields[4], dows)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, InvalidScheduleError{spec, err.Error()}
+	}
+
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.dayOr = fields[2] != "*" && fields[4] != "*"
+	return &s, nil
+}
+
+func parseField(field string, b bounds) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		lo, hi := b.min, b.max
+		if part != "*" {
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = parseValue(part[:i], b)
+				if err == nil {
+					hi, err = parseValue(part[i+1:], b)
+				}
+			} else {
+				lo, err = parseValue(part, b)
+				hi = lo
+				if step != 1 {
+					hi = b.max
+				}
+			}
+			if err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range '%s'", part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < b.min || v > b.max {
+		return 0, fmt.Errorf("value '%s' out of range %d-%d", s, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Matches returns true if the schedule fires at the minute of the given time.
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.dayOr {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
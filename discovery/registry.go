@@ -0,0 +1,170 @@
+// Package discovery keeps a registry of application containers updated on
+// container lifecycle events, and resolves names of application services
+// with an embedded DNS server, so applications connect to services by
+// names instead of addresses that change when containers are replaced.
+package discovery
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// Record is a running application container in the registry.
+type Record struct {
+	ID        string
+	Name      string
+	Namespace string
+
+	// The service name, empty for framework containers.
+	Service string
+
+	// The addresses resolved for the container.
+	IP   string
+	IPv6 string `json:",omitempty"`
+
+	// All addresses of the container in connected networks, used to
+	// identify the container querying the DNS server.
+	Addrs []string `json:"-"`
+}
+
+// Registry contains running application containers, and resolves names of
+// application services in the namespace of the container querying the
+// names. Services are named "<service>.<app>.<domain>" and framework
+// containers are named "<app>.<domain>".
+type Registry struct {
+	domain string
+
+	mu   sync.RWMutex
+	apps map[string][]*Record // keyed by application name and namespace
+	ips  map[string]*Record   // keyed by container addresses
+}
+
+// NewRegistry creates an empty registry resolving names in the domain.
+func NewRegistry(domain string) *Registry {
+	return &Registry{
+		domain: strings.ToLower(strings.Trim(domain, ".")),
+		apps:   make(map[string][]*Record),
+		ips:    make(map[string]*Record),
+	}
+}
+
+// Domain returns the domain of names resolved by the registry.
+func (r *Registry) Domain() string {
+	return r.domain
+}
+
+func appKey(name, namespace string) string {
+	return name + "-" + namespace
+}
+
+// Update replaces records of the application.
+func (r *Registry) Update(name, namespace string, records []*Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := appKey(name, namespace)
+	r.remove(key)
+	if len(records) != 0 {
+		r.apps[key] = records
+		r.index(records)
+	}
+}
+
+// Reset replaces all records in the registry.
+func (r *Registry) Reset(records []*Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apps = make(map[string][]*Record)
+	r.ips = make(map[string]*Record)
+	for _, rec := range records {
+		key := appKey(rec.Name, rec.Namespace)
+		r.apps[key] = append(r.apps[key], rec)
+	}
+	r.index(records)
+}
+
+func (r *Registry) remove(key string) {
+	for _, rec := range r.apps[key] {
+		for _, addr := range rec.Addrs {
+			if r.ips[addr] == rec {
+				delete(r.ips, addr)
+			}
+		}
+	}
+	delete(r.apps, key)
+}
+
+func (r *Registry) index(records []*Record) {
+	for _, rec := range records {
+		for _, addr := range rec.Addrs {
+			r.ips[addr] = rec
+		}
+	}
+}
+
+// Lookup returns records of the service in the application, or framework
+// containers if the service name is empty.
+func (r *Registry) Lookup(name, namespace, service string) []*Record {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*Record
+	for _, rec := range r.apps[appKey(name, namespace)] {
+		if rec.Service == service {
+			result = append(result, rec)
+		}
+	}
+	return result
+}
+
+// FindByIP returns the record of the container with the address, or nil
+// if the address doesn't belong to any application container.
+func (r *Registry) FindByIP(ip net.IP) *Record {
+	if ip == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ips[ip.String()]
+}
+
+// Resolve the name queried by the client container. Names are resolved
+// in the namespace of the client, and queries of unknown clients are not
+// resolved, so tenants cannot discover services of other tenants.
+func (r *Registry) Resolve(name string, client net.IP) ([]net.IP, bool) {
+	if !strings.HasSuffix(name, "."+r.domain) {
+		return nil, false
+	}
+	requester := r.FindByIP(client)
+	if requester == nil {
+		return nil, false
+	}
+
+	var app, service string
+	labels := strings.Split(strings.TrimSuffix(name, "."+r.domain), ".")
+	switch len(labels) {
+	case 1:
+		app = labels[0]
+	case 2:
+		service, app = labels[0], labels[1]
+	default:
+		return nil, false
+	}
+
+	records := r.Lookup(app, requester.Namespace, service)
+	if len(records) == 0 {
+		return nil, false
+	}
+
+	var addrs []net.IP
+	for _, rec := range records {
+		for _, s := range []string{rec.IP, rec.IPv6} {
+			if ip := net.ParseIP(s); ip != nil {
+				addrs = append(addrs, ip)
+			}
+		}
+	}
+	return addrs, true
+}
@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDiscovery(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Service Discovery Suite")
+}
+
+var _ = Describe("Registry", func() {
+	var r *Registry
+
+	BeforeEach(func() {
+		r = NewRegistry("Cloudway.Local.")
+		r.Update("demo", "alice", []*Record{
+			{ID: "1", Name: "demo", Namespace: "alice", IP: "172.18.0.2", Addrs: []string{"172.18.0.2", "10.0.1.2"}},
+			{ID: "2", Name: "demo", Namespace: "alice", Service: "mysql", IP: "10.0.1.3", IPv6: "fd00::3", Addrs: []string{"10.0.1.3"}},
+		})
+		r.Update("demo", "bob", []*Record{
+			{ID: "3", Name: "demo", Namespace: "bob", IP: "172.18.0.4", Addrs: []string{"172.18.0.4"}},
+			{ID: "4", Name: "demo", Namespace: "bob", Service: "mysql", IP: "10.0.2.3", Addrs: []string{"10.0.2.3"}},
+		})
+	})
+
+	resolve := func(name, client string) []string {
+		addrs, ok := r.Resolve(name, net.ParseIP(client))
+		if !ok {
+			return nil
+		}
+		var result []string
+		for _, ip := range addrs {
+			result = append(result, ip.String())
+		}
+		return result
+	}
+
+	It("should resolve services in the namespace of the client", func() {
+		Expect(resolve("mysql.demo.cloudway.local", "10.0.1.2")).To(Equal([]string{"10.0.1.3", "fd00::3"}))
+		Expect(resolve("mysql.demo.cloudway.local", "172.18.0.2")).To(Equal([]string{"10.0.1.3", "fd00::3"}))
+		Expect(resolve("mysql.demo.cloudway.local", "172.18.0.4")).To(Equal([]string{"10.0.2.3"}))
+		Expect(resolve("demo.cloudway.local", "10.0.1.3")).To(Equal([]string{"172.18.0.2"}))
+	})
+
+	It("should not resolve names for unknown clients", func() {
+		Expect(resolve("mysql.demo.cloudway.local", "192.168.1.1")).To(BeNil())
+	})
+
+	It("should not resolve unknown names", func() {
+		Expect(resolve("redis.demo.cloudway.local", "10.0.1.2")).To(BeNil())
+		Expect(resolve("a.mysql.demo.cloudway.local", "10.0.1.2")).To(BeNil())
+		Expect(resolve("mysql.demo.example.com", "10.0.1.2")).To(BeNil())
+	})
+
+	It("should remove records of the application", func() {
+		r.Update("demo", "alice", nil)
+		Expect(r.Lookup("demo", "alice", "mysql")).To(BeEmpty())
+		Expect(r.FindByIP(net.ParseIP("10.0.1.2"))).To(BeNil())
+		Expect(r.Lookup("demo", "bob", "mysql")).To(HaveLen(1))
+	})
+
+	It("should reset all records", func() {
+		r.Reset([]*Record{{ID: "5", Name: "web", Namespace: "alice", IP: "172.18.0.5", Addrs: []string{"172.18.0.5"}}})
+		Expect(r.Lookup("demo", "alice", "")).To(BeEmpty())
+		Expect(r.FindByIP(net.ParseIP("172.18.0.5")).Name).To(Equal("web"))
+	})
+})
@@ -0,0 +1,145 @@
+package discovery
+
+import (
+	"net"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/dns"
+)
+
+// The time to live of DNS answers in seconds, kept short as containers
+// may be replaced at any time.
+const dnsTTL = 5
+
+// New creates the service registry, and starts the DNS server listening on
+// the UDP address configured by "discovery.listen" until the stop channel
+// is closed. Names not in the domain configured by "discovery.domain" are
+// relayed to the servers configured by "discovery.upstream", a comma
+// separated list of addresses, or name servers of the system by default.
+// Returns nil if service discovery is not enabled.
+func New(stop <-chan bool) (*Registry, error) {
+	addr := config.Get("discovery.listen")
+	if addr == "" {
+		return nil, nil
+	}
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRegistry(config.GetOrDefault("discovery.domain", "cloudway.local"))
+	server := &dns.Server{
+		Zone:     r.Domain(),
+		Resolver: r,
+		Upstream: upstream(),
+		TTL:      dnsTTL,
+	}
+
+	go func() {
+		<-stop
+		conn.Close()
+	}()
+	go func() {
+		err := server.Serve(conn)
+		select {
+		case <-stop:
+		default:
+			logrus.WithError(err).Error("Service discovery DNS server stopped")
+		}
+	}()
+
+	logrus.Infof("Service discovery DNS server listening on %s", addr)
+	return r, nil
+}
+
+func upstream() []string {
+	list := config.Get("discovery.upstream")
+	if list == "" {
+		return dns.SystemUpstream()
+	}
+
+	var servers []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(addr, "53")
+			}
+			servers = append(servers, addr)
+		}
+	}
+	return servers
+}
+
+// HandleEvent updates records of the application when a container is
+// started, died or removed.
+func (r *Registry) HandleEvent(cli container.DockerClient, e *container.ContainerEvent) {
+	if e.Name == "" || e.Namespace == "" {
+		return
+	}
+	switch e.Action {
+	case container.ContainerStarted, container.ContainerDied, container.ContainerRemoved:
+		if err := r.Refresh(context.Background(), cli, e.Name, e.Namespace); err != nil {
+			logrus.WithError(err).Warnf("Failed to update service registry of %s-%s", e.Name, e.Namespace)
+		}
+	}
+}
+
+// Refresh records of the application from its running containers.
+func (r *Registry) Refresh(ctx context.Context, cli container.DockerClient, name, namespace string) error {
+	cs, err := cli.FindAll(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+	r.Update(name, namespace, recordsOf(cs))
+	return nil
+}
+
+// Rebuild the registry from all running application containers, so the
+// events missed while disconnected from Docker hosts are recovered.
+func (r *Registry) Rebuild(ctx context.Context, cli container.DockerClient) error {
+	cs, err := cli.FindInNamespace(ctx, "")
+	if err != nil {
+		return err
+	}
+	r.Reset(recordsOf(cs))
+	return nil
+}
+
+func recordsOf(cs []*container.Container) []*Record {
+	var records []*Record
+	for _, c := range cs {
+		if c.State == nil || !c.State.Running {
+			continue
+		}
+
+		rec := &Record{
+			ID:        c.ID,
+			Name:      c.Name,
+			Namespace: c.Namespace,
+			IP:        c.IP(),
+			IPv6:      c.IPv6(),
+		}
+		if c.Category().IsService() {
+			rec.Service = c.ServiceName()
+		}
+		for _, ep := range c.NetworkSettings.Networks {
+			if ep.IPAddress != "" {
+				rec.Addrs = append(rec.Addrs, ep.IPAddress)
+			}
+			if ep.GlobalIPv6Address != "" {
+				rec.Addrs = append(rec.Addrs, ep.GlobalIPv6Address)
+			}
+		}
+		if c.NetworkSettings.IPAddress != "" {
+			rec.Addrs = append(rec.Addrs, c.NetworkSettings.IPAddress)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
@@ -0,0 +1,134 @@
+// Package history keeps previously deployed repository archives of
+// applications, so an application can be rolled back without rebuilding.
+package history
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/cloudway/platform/backup"
+	"github.com/cloudway/platform/config"
+)
+
+// Store keeps a limited number of previously deployed repository archives
+// for each application. Archives are kept in the backup storage, so they
+// are shared by all API servers if the backup storage is shared. The
+// versions of an application are recorded by the caller, usually in the
+// user database.
+type Store struct {
+	storage backup.Storage
+	keep    int
+}
+
+// Version describes a deployed repository archive.
+type Version struct {
+	Version int
+	Archive string
+	Created time.Time
+	Size    int64
+}
+
+type VersionNotFoundError struct {
+	Name    string
+	Version int
+}
+
+func (e VersionNotFoundError) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("%s: no previous deployment to rollback", e.Name)
+	}
+	return fmt.Sprintf("%s: deployment version %d not found", e.Name, e.Version)
+}
+
+func (e VersionNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// The namespaces of archives in the backup storage are prefixed, so they
+// never conflict with backups of applications.
+const storagePrefix = ".history"
+
+func New(storage backup.Storage) (*Store, error) {
+	keep, err := strconv.Atoi(config.GetOrDefault("deploy.history.keep", "5"))
+	if err != nil || keep < 0 {
+		return nil, fmt.Errorf("Invalid deploy.history.keep configuration: %s", config.Get("deploy.history.keep"))
+	}
+	return &Store{storage, keep}, nil
+}
+
+func storageNamespace(namespace string) string {
+	return path.Join(storagePrefix, namespace)
+}
+
+// Save the gzipped repository archive. The returned version is numbered
+// and recorded by the caller with Add. Returns nil if history is disabled.
+func (s *Store) Save(namespace, name string, archive io.Reader) (*Version, error) {
+	if s.keep == 0 {
+		return nil, nil
+	}
+
+	b, err := s.storage.Save(storageNamespace(namespace), name, archive)
+	if err != nil {
+		return nil, err
+	}
+	return &Version{Archive: b.ID, Created: b.Created, Size: b.Size}, nil
+}
+
+// Add the saved version to versions, the most recent version first, and
+// number it after the most recent version. Returns the versions to keep
+// and the oldest versions exceeding the configured limit, which should be
+// removed by the caller with Discard after the versions are recorded.
+func (s *Store) Add(versions []*Version, v *Version) (keep, discard []*Version) {
+	v.Version = 1
+	if len(versions) != 0 {
+		v.Version = versions[0].Version + 1
+	}
+	keep = append([]*Version{v}, versions...)
+	if len(keep) > s.keep {
+		keep, discard = keep[:s.keep], keep[s.keep:]
+	}
+	return keep, discard
+}
+
+// Find the version to rollback to. If version is 0 then the version prior
+// to the current version is returned. The current version is the most
+// recent version if not known.
+func Find(name string, versions []*Version, current, version int) (*Version, error) {
+	if version == 0 && current == 0 && len(versions) != 0 {
+		current = versions[0].Version
+	}
+	for _, v := range versions {
+		if version == 0 && v.Version < current || version != 0 && v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, VersionNotFoundError{name, version}
+}
+
+// Open the archive of the given version.
+func (s *Store) Open(namespace, name string, v *Version) (io.ReadCloser, error) {
+	r, err := s.storage.Open(storageNamespace(namespace), name, v.Archive)
+	if _, ok := err.(backup.NotFoundError); ok {
+		err = VersionNotFoundError{name, v.Version}
+	}
+	return r, err
+}
+
+// Discard archives of the given versions. Archives already removed are
+// ignored.
+func (s *Store) Discard(namespace, name string, versions []*Version) error {
+	for _, v := range versions {
+		err := s.storage.Remove(storageNamespace(namespace), name, v.Archive)
+		if _, ok := err.(backup.NotFoundError); ok {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
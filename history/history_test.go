@@ -0,0 +1,161 @@
+package history
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/backup"
+)
+
+func TestHistory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "History Suite")
+}
+
+var _ = Describe("Deployment history", func() {
+	const (
+		NAMESPACE = "history_test"
+		NAME      = "test"
+	)
+
+	var (
+		dir      string
+		store    *Store
+		recorded []*Version
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "history")
+		Expect(err).NotTo(HaveOccurred())
+		storage, err := backup.NewFileStorage(dir)
+		Expect(err).NotTo(HaveOccurred())
+		store = &Store{storage: storage, keep: 3}
+		recorded = nil
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	var save = func(content string) *Version {
+		v, err := store.Save(NAMESPACE, NAME, bytes.NewBufferString(content))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		var discard []*Version
+		recorded, discard = store.Add(recorded, v)
+		ExpectWithOffset(1, store.Discard(NAMESPACE, NAME, discard)).To(Succeed())
+		return v
+	}
+
+	var read = func(v *Version) string {
+		r, err := store.Open(NAMESPACE, NAME, v)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		defer r.Close()
+		content, err := ioutil.ReadAll(r)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return string(content)
+	}
+
+	var versions = func() (vs []int) {
+		for _, v := range recorded {
+			vs = append(vs, v.Version)
+		}
+		return vs
+	}
+
+	It("should have no version to rollback for application never deployed", func() {
+		_, err := Find(NAME, recorded, 0, 0)
+		Expect(err).To(Equal(VersionNotFoundError{NAME, 0}))
+	})
+
+	It("should number versions in order", func() {
+		for i := 1; i <= 3; i++ {
+			v := save("v" + strconv.Itoa(i))
+			Expect(v.Version).To(Equal(i))
+			Expect(v.Size).To(Equal(int64(2)))
+		}
+		Expect(versions()).To(Equal([]int{3, 2, 1}))
+	})
+
+	It("should keep limited number of versions", func() {
+		var first *Version
+		for i := 1; i <= 5; i++ {
+			v := save("v" + strconv.Itoa(i))
+			if i == 1 {
+				first = v
+			}
+		}
+		Expect(versions()).To(Equal([]int{5, 4, 3}))
+
+		_, err := Find(NAME, recorded, 0, 1)
+		Expect(err).To(Equal(VersionNotFoundError{NAME, 1}))
+		_, err = store.Open(NAMESPACE, NAME, first)
+		Expect(err).To(Equal(VersionNotFoundError{NAME, 1}))
+	})
+
+	It("should open the given version", func() {
+		save("v1")
+		save("v2")
+		for i := 1; i <= 2; i++ {
+			v, err := Find(NAME, recorded, 0, i)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Version).To(Equal(i))
+			Expect(read(v)).To(Equal("v" + strconv.Itoa(i)))
+		}
+	})
+
+	It("should find the version prior to the most recent version by default", func() {
+		save("v1")
+		_, err := Find(NAME, recorded, 0, 0)
+		Expect(err).To(Equal(VersionNotFoundError{NAME, 0}))
+
+		save("v2")
+		v, err := Find(NAME, recorded, 0, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v.Version).To(Equal(1))
+		Expect(read(v)).To(Equal("v1"))
+	})
+
+	It("should find the version prior to the current version", func() {
+		save("v1")
+		save("v2")
+		save("v3")
+
+		v, err := Find(NAME, recorded, 2, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v.Version).To(Equal(1))
+
+		_, err = Find(NAME, recorded, 1, 0)
+		Expect(err).To(Equal(VersionNotFoundError{NAME, 0}))
+	})
+
+	It("should not keep history if disabled", func() {
+		store.keep = 0
+		v, err := store.Save(NAMESPACE, NAME, bytes.NewBufferString("v1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(BeNil())
+	})
+
+	It("should not conflict with backups", func() {
+		save("v1")
+		backups, err := store.storage.List(NAMESPACE, NAME)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backups).To(BeEmpty())
+	})
+
+	It("should discard all versions", func() {
+		save("v1")
+		save("v2")
+		Expect(store.Discard(NAMESPACE, NAME, recorded)).To(Succeed())
+		for _, v := range recorded {
+			_, err := store.Open(NAMESPACE, NAME, v)
+			Expect(err).To(Equal(VersionNotFoundError{NAME, v.Version}))
+		}
+	})
+})
@@ -0,0 +1,210 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// Visibility controls which namespaces can use a user defined plugin.
+type Visibility string
+
+const (
+	// Private plugins can only be used in the owner namespace.
+	Private Visibility = "private"
+
+	// Shared plugins can be used in the owner namespace and namespaces
+	// granted access to the plugin.
+	Shared Visibility = "shared"
+
+	// Public plugins can be used in all namespaces.
+	Public Visibility = "public"
+)
+
+// Access describes the visibility of a user defined plugin and namespaces
+// granted access to the plugin.
+type Access struct {
+	Visibility Visibility
+	Grants     []string `json:",omitempty"`
+}
+
+// accessFile is saved in the plugin directory, next to plugin versions.
+const accessFile = ".access"
+
+type InvalidVisibilityError string
+
+func (e InvalidVisibilityError) Error() string {
+	return fmt.Sprintf("Invalid plugin visibility: %s, must be one of private, shared or public", string(e))
+}
+
+func (e InvalidVisibilityError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// GetPluginAccess returns access control of the plugin. Plugins without
+// access control are private, or public if the Shared flag is set in the
+// plugin manifest.
+func (hub *PluginHub) GetPluginAccess(namespace, name string) (*Access, error) {
+	path, err := hub.pluginPath(namespace, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), accessFile))
+	if err == nil {
+		var access Access
+		if err = json.Unmarshal(data, &access); err != nil {
+			return nil, err
+		}
+		return &access, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	plugin, err := hub.GetPluginInfo(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if plugin.Shared {
+		return &Access{Visibility: Public}, nil
+	}
+	return &Access{Visibility: Private}, nil
+}
+
+// SetPluginVisibility changes visibility of the plugin. Granted namespaces
+// are kept so they regain access when the plugin is shared again.
+func (hub *PluginHub) SetPluginVisibility(namespace, name string, visibility Visibility) error {
+	switch visibility {
+	case Private, Shared, Public:
+	default:
+		return InvalidVisibilityError(visibility)
+	}
+	return hub.updateAccess(namespace, name, func(access *Access) {
+		access.Visibility = visibility
+	})
+}
+
+// GrantPluginAccess grants the namespace access to the plugin. A private
+// plugin becomes shared after granted.
+func (hub *PluginHub) GrantPluginAccess(namespace, name, grantee string) error {
+	return hub.updateAccess(namespace, name, func(access *Access) {
+		if access.Visibility == Private {
+			access.Visibility = Shared
+		}
+		for _, ns := range access.Grants {
+			if ns == grantee {
+				return
+			}
+		}
+		access.Grants = append(access.Grants, grantee)
+		sort.Strings(access.Grants)
+	})
+}
+
+// RevokePluginAccess revokes access of the namespace to the plugin.
+func (hub *PluginHub) RevokePluginAccess(namespace, name, grantee string) error {
+	return hub.updateAccess(namespace, name, func(access *Access) {
+		for i, ns := range access.Grants {
+			if ns == grantee {
+				access.Grants = append(access.Grants[:i], access.Grants[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func (hub *PluginHub) updateAccess(namespace, name string, update func(*Access)) error {
+	if namespace == "" {
+		return fmt.Errorf("%s: cannot change access of system plugin", name)
+	}
+
+	access, err := hub.GetPluginAccess(namespace, name)
+	if err != nil {
+		return err
+	}
+	update(access)
+
+	data, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+
+	filename := filepath.Join(hub.getBaseDir(namespace, name, ""), accessFile)
+	tempfile := filename + ".tmp"
+	if err = ioutil.WriteFile(tempfile, data, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tempfile, filename); err != nil {
+		return err
+	}
+	return hub.publishAccess(namespace, name, access)
+}
+
+// CanAccess returns true if plugins in the namespace can use the plugin.
+func (hub *PluginHub) CanAccess(namespace, name, accessor string) bool {
+	if namespace == "" || namespace == accessor {
+		return true
+	}
+
+	access, err := hub.GetPluginAccess(namespace, name)
+	if err != nil {
+		return false
+	}
+
+	return access.Visibility == Public || access.sharedWith(accessor)
+}
+
+func (access *Access) sharedWith(namespace string) bool {
+	if access.Visibility == Shared {
+		for _, ns := range access.Grants {
+			if ns == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SharedPlugins returns the highest version of plugins in other namespaces
+// explicitly shared with the namespace.
+func (hub *PluginHub) SharedPlugins(accessor string) []*manifest.Plugin {
+	if accessor == "" {
+		return nil
+	}
+
+	hub.sync(false)
+	f, err := os.Open(hub.installDir)
+	if err != nil {
+		return nil
+	}
+	namespaces, err := f.Readdirnames(0)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+
+	var result []*manifest.Plugin
+	for _, namespace := range namespaces {
+		if namespace == "_" || namespace == accessor {
+			continue
+		}
+		for name, versions := range hub.versions(namespace) {
+			access, err := hub.GetPluginAccess(namespace, name)
+			if err == nil && access.sharedWith(accessor) {
+				meta := *versions[len(versions)-1]
+				result = append(result, &meta)
+			}
+		}
+	}
+	return result
+}
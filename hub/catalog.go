@@ -0,0 +1,280 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// catalog indexes all versions of plugins installed in a namespace, so
+// listing and searching plugins don't read every plugin manifest. Entries
+// are reloaded when modification time of the plugin directory changed.
+type catalog struct {
+	modTime time.Time
+	plugins map[string]*catalogEntry
+}
+
+type catalogEntry struct {
+	modTime  time.Time
+	versions []*manifest.Plugin // sorted by version in ascending order
+}
+
+// versions returns all versions of plugins installed in the namespace,
+// keyed by plugin names.
+func (hub *PluginHub) versions(namespace string) map[string][]*manifest.Plugin {
+	hub.sync(false)
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	dir := hub.getBaseDir(namespace, "", "")
+	fi, err := os.Stat(dir)
+	if err != nil {
+		delete(hub.catalogs, namespace)
+		return nil
+	}
+
+	if hub.catalogs == nil {
+		hub.catalogs = make(map[string]*catalog)
+	}
+
+	cat := hub.catalogs[namespace]
+	if cat == nil || !cat.modTime.Equal(fi.ModTime()) {
+		if cat, err = hub.loadCatalog(dir, fi.ModTime(), cat); err != nil {
+			return nil
+		}
+		hub.catalogs[namespace] = cat
+	}
+
+	result := make(map[string][]*manifest.Plugin, len(cat.plugins))
+	for name, entry := range cat.plugins {
+		base := filepath.Join(dir, name)
+		if fi, err := os.Stat(base); err != nil {
+			continue
+		} else if !entry.modTime.Equal(fi.ModTime()) {
+			entry.modTime = fi.ModTime()
+			entry.versions = loadVersions(namespace, base)
+		}
+		if len(entry.versions) != 0 {
+			result[name] = entry.versions
+		}
+	}
+	return result
+}
+
+// loadCatalog reads plugin names in the namespace directory, keeping
+// entries of the previous catalog.
+func (hub *PluginHub) loadCatalog(dir string, modTime time.Time, prev *catalog) (*catalog, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(0)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	cat := &catalog{modTime: modTime, plugins: make(map[string]*catalogEntry, len(names))}
+	for _, name := range names {
+		if prev != nil && prev.plugins[name] != nil {
+			cat.plugins[name] = prev.plugins[name]
+		} else {
+			cat.plugins[name] = &catalogEntry{}
+		}
+	}
+	return cat, nil
+}
+
+func loadVersions(namespace, base string) []*manifest.Plugin {
+	vers, err := getAllVersions(base)
+	if err != nil {
+		return nil
+	}
+
+	var plugins []*manifest.Plugin
+	for _, v := range vers {
+		plugin, err := archive.ReadManifest(filepath.Join(base, joinVersion(v)))
+		if err == nil {
+			plugins = append(plugins, tagged(namespace, plugin))
+		}
+	}
+	return plugins
+}
+
+// invalidate the catalog of the namespace after plugins changed.
+func (hub *PluginHub) invalidate(namespace string) {
+	hub.mu.Lock()
+	delete(hub.catalogs, namespace)
+	hub.mu.Unlock()
+}
+
+// Query selects plugins from the catalog.
+type Query struct {
+	// Select plugins of the category.
+	Category manifest.Category
+
+	// Select plugins containing the text in name, display name, description
+	// or vendor, case insensitive.
+	Text string
+
+	// Comma separated version constraints, e.g. ">=1.2,<2". A version
+	// without operator matches all versions starting with it.
+	Version string
+
+	// The sort key, one of "name", "display-name", "vendor" and "version",
+	// prefixed with "-" for descending order. Defaults to "display-name".
+	Sort string
+
+	// The number of plugins skipped and the maximum number of plugins
+	// returned. A zero limit returns all plugins.
+	Offset int
+	Limit  int
+}
+
+type InvalidQueryError struct {
+	Name  string
+	Value string
+}
+
+func (e InvalidQueryError) Error() string {
+	return fmt.Sprintf("Invalid plugin query %s: %s", e.Name, e.Value)
+}
+
+func (e InvalidQueryError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+var sortKeys = map[string]func(p1, p2 *manifest.Plugin) int{
+	"name": func(p1, p2 *manifest.Plugin) int {
+		return strings.Compare(p1.Name, p2.Name)
+	},
+	"display-name": func(p1, p2 *manifest.Plugin) int {
+		return strings.Compare(p1.DisplayName, p2.DisplayName)
+	},
+	"vendor": func(p1, p2 *manifest.Plugin) int {
+		return strings.Compare(p1.Vendor, p2.Vendor)
+	},
+	"version": func(p1, p2 *manifest.Plugin) int {
+		return compareVersions(splitVersion(p1.Version), splitVersion(p2.Version))
+	},
+}
+
+// ParseQuery parses the plugin query from URL query values.
+func ParseQuery(values url.Values) (q Query, err error) {
+	q.Category = manifest.Category(values.Get("category"))
+	q.Text = values.Get("q")
+	q.Version = values.Get("version")
+	q.Sort = values.Get("sort")
+
+	if _, err = parseConstraints(q.Version); err != nil {
+		return q, InvalidQueryError{"version", q.Version}
+	}
+	if q.Sort != "" && sortKeys[strings.TrimPrefix(q.Sort, "-")] == nil {
+		return q, InvalidQueryError{"sort", q.Sort}
+	}
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, InvalidQueryError{"offset", v}
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			return q, InvalidQueryError{"limit", v}
+		}
+	}
+	return q, nil
+}
+
+// Search the plugins installed in the namespaces. Plugins in a namespace
+// override plugins of the same name in preceding namespaces. The highest
+// version of each plugin matching the query is returned, together with
+// the total number of matched plugins before pagination.
+func (hub *PluginHub) Search(namespaces []string, q Query) ([]*manifest.Plugin, int, error) {
+	constraints, err := parseConstraints(q.Version)
+	if err != nil {
+		return nil, 0, InvalidQueryError{"version", q.Version}
+	}
+
+	sortKey := strings.TrimPrefix(q.Sort, "-")
+	if sortKey == "" {
+		sortKey = "display-name"
+	}
+	compare := sortKeys[sortKey]
+	if compare == nil {
+		return nil, 0, InvalidQueryError{"sort", q.Sort}
+	}
+
+	merged := make(map[string][]*manifest.Plugin)
+	for _, namespace := range namespaces {
+		for name, versions := range hub.versions(namespace) {
+			merged[name] = versions
+		}
+	}
+
+	text := strings.ToLower(q.Text)
+	var result []*manifest.Plugin
+	for _, versions := range merged {
+		for i := len(versions) - 1; i >= 0; i-- {
+			p := versions[i]
+			if (q.Category == "" || p.Category == q.Category) &&
+				matchText(p, text) && constraints.match(p.Version) {
+				plugin := *p
+				result = append(result, &plugin)
+				break
+			}
+		}
+	}
+
+	desc := strings.HasPrefix(q.Sort, "-")
+	sort.Sort(pluginSorter{result, func(p1, p2 *manifest.Plugin) bool {
+		c := compare(p1, p2)
+		if c == 0 {
+			c = strings.Compare(p1.Tag, p2.Tag)
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}})
+
+	total := len(result)
+	if q.Offset > len(result) {
+		q.Offset = len(result)
+	}
+	result = result[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(result) {
+		result = result[:q.Limit]
+	}
+	return result, total, nil
+}
+
+func matchText(p *manifest.Plugin, text string) bool {
+	if text == "" {
+		return true
+	}
+	for _, s := range []string{p.Name, p.DisplayName, p.Description, p.Vendor} {
+		if strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	return false
+}
+
+type pluginSorter struct {
+	plugins []*manifest.Plugin
+	less    func(p1, p2 *manifest.Plugin) bool
+}
+
+func (s pluginSorter) Len() int           { return len(s.plugins) }
+func (s pluginSorter) Swap(i, j int)      { s.plugins[i], s.plugins[j] = s.plugins[j], s.plugins[i] }
+func (s pluginSorter) Less(i, j int) bool { return s.less(s.plugins[i], s.plugins[j]) }
@@ -1,11 +1,18 @@
 package hub
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
 
 	"github.com/cloudway/platform/config"
 	"github.com/cloudway/platform/pkg/archive"
@@ -15,6 +22,15 @@ import (
 
 type PluginHub struct {
 	installDir string
+	mu         sync.Mutex
+	catalogs   map[string]*catalog
+
+	// The shared storage of plugins, the install directory is used as a
+	// local cache if configured. Changes to the cache are serialized by
+	// the sync lock.
+	storage Storage
+	syncMu  sync.Mutex
+	synced  time.Time
 }
 
 func New() (*PluginHub, error) {
@@ -22,29 +38,30 @@ func New() (*PluginHub, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
-	return &PluginHub{dir}, nil
-}
 
-func (hub *PluginHub) ListPlugins(namespace string, category manifest.Category) []*manifest.Plugin {
-	dir, err := os.Open(hub.getBaseDir(namespace, "", ""))
+	storage, err := NewStorage()
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	defer dir.Close()
 
-	names, err := dir.Readdirnames(0)
-	if err != nil {
-		return nil
+	hub := &PluginHub{installDir: dir, storage: storage}
+	if storage != nil {
+		if err = hub.seed(); err != nil {
+			return nil, err
+		}
+		hub.sync(true)
 	}
+	return hub, nil
+}
 
+// ListPlugins returns the highest version of plugins installed in the
+// namespace.
+func (hub *PluginHub) ListPlugins(namespace string, category manifest.Category) []*manifest.Plugin {
 	var result []*manifest.Plugin
-	for _, tag := range names {
-		if namespace != "" {
-			tag = namespace + "/" + tag
-		}
-		meta, err := hub.GetPluginInfo(tag)
-		if err == nil && (category == "" || category == meta.Category) {
-			result = append(result, tagged(namespace, meta))
+	for _, versions := range hub.versions(namespace) {
+		meta := *versions[len(versions)-1]
+		if category == "" || category == meta.Category {
+			result = append(result, &meta)
 		}
 	}
 	return result
@@ -59,6 +76,8 @@ func (hub *PluginHub) GetPluginPath(tag string) (string, error) {
 }
 
 func (hub *PluginHub) pluginPath(namespace, name, version string) (string, error) {
+	hub.sync(false)
+
 	base := hub.getBaseDir(namespace, name, "")
 	versions, err := getAllVersions(base)
 	if err != nil {
@@ -115,6 +134,15 @@ func (hub *PluginHub) InstallPlugin(namespace string, path string) (err error) {
 	if _, _, _, _, err = ParseTag(tag); err != nil {
 		return invalidManifestErr{}
 	}
+	for _, req := range meta.Requires {
+		if _, _, name, _, err := ParseTag(req); err != nil || name == meta.Name {
+			return invalidManifestErr{}
+		}
+	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+	defer hub.invalidate(namespace)
 
 	installDir := hub.getBaseDir(namespace, meta.Name, meta.Version)
 	if err = os.RemoveAll(installDir); err != nil {
@@ -125,10 +153,82 @@ func (hub *PluginHub) InstallPlugin(namespace string, path string) (err error) {
 	}
 
 	if fi, _ := os.Stat(path); fi.IsDir() {
-		return files.CopyFiles(path, installDir)
+		err = files.CopyFiles(path, installDir)
 	} else {
-		return files.ExtractFiles(path, installDir)
+		err = files.ExtractFiles(path, installDir)
+	}
+	if err == nil {
+		err = hub.publishVersion(namespace, meta.Name, meta.Version)
 	}
+	if err != nil {
+		// the previous revision is fetched again on next sync
+		os.RemoveAll(installDir)
+		os.Remove(revisionFile(filepath.Dir(installDir), meta.Version))
+	}
+	return err
+}
+
+// UpdateManifest replaces the manifest of an installed plugin. Only
+// metadata such as endpoints, health check and cron jobs can be changed,
+// changes requiring containers to be rebuilt are rejected.
+func (hub *PluginHub) UpdateManifest(tag string, data []byte) (*manifest.Plugin, error) {
+	_, namespace, name, version, err := ParseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	path, err := hub.pluginPath(namespace, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := archive.ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := manifest.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidManifestErr{}
+	}
+
+	switch {
+	case meta.Name != old.Name:
+		return nil, ManifestChangeError{old.Name, "Name"}
+	case meta.Version != old.Version:
+		return nil, ManifestChangeError{old.Name, "Version"}
+	case meta.Category != old.Category:
+		return nil, ManifestChangeError{old.Name, "Category"}
+	case meta.BaseImage != old.BaseImage:
+		return nil, ManifestChangeError{old.Name, "Base-Image"}
+	case meta.ImageDigest != old.ImageDigest:
+		return nil, ManifestChangeError{old.Name, "Image-Digest"}
+	case meta.User != old.User:
+		return nil, ManifestChangeError{old.Name, "User"}
+	case !reflect.DeepEqual(meta.Volumes, old.Volumes):
+		return nil, ManifestChangeError{old.Name, "Volumes"}
+	}
+	for _, req := range meta.Requires {
+		if _, _, name, _, err := ParseTag(req); err != nil || name == meta.Name {
+			return nil, invalidManifestErr{}
+		}
+	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+
+	filename := filepath.Join(path, filepath.FromSlash(manifest.ManifestEntry))
+	if err = ioutil.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(filename+".tmp", filename); err != nil {
+		return nil, err
+	}
+	hub.invalidate(namespace)
+	if err = hub.publishVersion(namespace, name, filepath.Base(path)); err != nil {
+		return nil, err
+	}
+
+	meta.Path = path
+	return tagged(namespace, meta), nil
 }
 
 func (hub *PluginHub) RemovePlugin(tag string) error {
@@ -140,6 +240,14 @@ func (hub *PluginHub) RemovePlugin(tag string) error {
 	if _, err := os.Stat(dir); err != nil {
 		return err
 	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+	defer hub.invalidate(namespace)
+
+	if err = hub.unpublish(itemPrefix(namespace, name, version)); err != nil {
+		return err
+	}
 	return os.RemoveAll(dir)
 }
 
@@ -147,7 +255,15 @@ func (hub *PluginHub) RemoveNamespace(namespace string) {
 	if namespace == "" || namespace == "_" {
 		return
 	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+
+	if err := hub.unpublish(itemPrefix(namespace, "", "")); err != nil {
+		logrus.WithError(err).Warnf("Failed to remove plugins of %s from hub storage", namespace)
+	}
 	os.RemoveAll(filepath.Join(hub.installDir, namespace))
+	hub.invalidate(namespace)
 }
 
 func (hub *PluginHub) getBaseDir(namespace, name, version string) string {
@@ -198,6 +314,21 @@ func (e invalidManifestErr) HTTPErrorStatusCode() int {
 	return http.StatusBadRequest
 }
 
+// ManifestChangeError reports that a manifest field cannot be changed
+// without reinstalling the plugin and rebuilding containers.
+type ManifestChangeError struct {
+	Plugin string
+	Field  string
+}
+
+func (e ManifestChangeError) Error() string {
+	return fmt.Sprintf("Cannot change %s of plugin %s without reinstalling the plugin", e.Field, e.Plugin)
+}
+
+func (e ManifestChangeError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
 type malformedTagError string
 
 func (e malformedTagError) Error() string {
@@ -25,7 +25,7 @@ var pluginHub *PluginHub
 var _ = BeforeSuite(func() {
 	testdir, err := ioutil.TempDir("", "hub")
 	Ω(err).ShouldNot(HaveOccurred())
-	pluginHub = &PluginHub{testdir}
+	pluginHub = &PluginHub{installDir: testdir}
 })
 
 var _ = AfterSuite(func() {
@@ -196,6 +196,77 @@ var _ = Describe("Hub", func() {
 		})
 	})
 
+	Describe("Search plugins", func() {
+		BeforeEach(func() {
+			for _, p := range []struct{ name, display, version, vendor string }{
+				{"php", "PHP", "5.6.30", "zend"},
+				{"php", "PHP", "7.1.2", "zend"},
+				{"java", "Java", "1.8", "oracle"},
+				{"nodejs", "Node.js", "6.10", "joyent"},
+			} {
+				meta.Name, meta.DisplayName, meta.Version, meta.Vendor = p.name, p.display, p.version, p.vendor
+				install("", meta)
+			}
+		})
+
+		var search = func(q Query) []string {
+			plugins, _, err := pluginHub.Search([]string{""}, q)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			return getTagsWithVersion(plugins)
+		}
+
+		It("should sort plugins by display name by default", func() {
+			Ω(search(Query{})).Should(Equal([]string{"java:1.8", "nodejs:6.10", "php:7.1.2"}))
+		})
+
+		It("should search plugins by text", func() {
+			Ω(search(Query{Text: "NODE"})).Should(Equal([]string{"nodejs:6.10"}))
+			Ω(search(Query{Text: "oracle"})).Should(Equal([]string{"java:1.8"}))
+			Ω(search(Query{Text: "nonexist"})).Should(BeEmpty())
+		})
+
+		It("should return highest version matching constraints", func() {
+			Ω(search(Query{Text: "php", Version: "5"})).Should(Equal([]string{"php:5.6.30"}))
+			Ω(search(Query{Text: "php", Version: ">=5.6,<7"})).Should(Equal([]string{"php:5.6.30"}))
+			Ω(search(Query{Version: ">=6"})).Should(Equal([]string{"nodejs:6.10", "php:7.1.2"}))
+		})
+
+		It("should sort and paginate plugins", func() {
+			Ω(search(Query{Sort: "-version"})).Should(Equal([]string{"php:7.1.2", "nodejs:6.10", "java:1.8"}))
+
+			plugins, total, err := pluginHub.Search([]string{""}, Query{Sort: "name", Offset: 1, Limit: 1})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(total).Should(Equal(3))
+			Ω(getTags(plugins)).Should(Equal([]string{"nodejs"}))
+		})
+
+		It("should override plugins in preceding namespaces", func() {
+			meta.Name, meta.Version = "java", "9.0"
+			install("user", meta)
+
+			plugins, _, err := pluginHub.Search([]string{"", "user"}, Query{Text: "java"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugins).Should(HaveLen(1))
+			Ω(plugins[0].Tag).Should(Equal("user/java:9.0"))
+		})
+
+		It("should reflect plugins changed after indexed", func() {
+			Ω(search(Query{Text: "java"})).Should(Equal([]string{"java:1.8"}))
+			meta.Name, meta.DisplayName, meta.Version = "java", "Java", "1.9"
+			install("", meta)
+			Ω(search(Query{Text: "java"})).Should(Equal([]string{"java:1.9"}))
+			Ω(pluginHub.RemovePlugin("java")).Should(Succeed())
+			Ω(search(Query{Text: "java"})).Should(BeEmpty())
+		})
+
+		It("should reject invalid queries", func() {
+			_, _, err := pluginHub.Search([]string{""}, Query{Version: ">=x"})
+			Ω(err).Should(BeAssignableToTypeOf(InvalidQueryError{}))
+			_, _, err = pluginHub.Search([]string{""}, Query{Sort: "size"})
+			Ω(err).Should(BeAssignableToTypeOf(InvalidQueryError{}))
+		})
+	})
+
 	Describe("Get plugin info", func() {
 		It("should return the plugin information", func() {
 			install("", meta)
@@ -290,6 +361,56 @@ var _ = Describe("Hub", func() {
 		})
 	})
 
+	Describe("Update manifest", func() {
+		var update = func(tag string, meta *manifest.Plugin) (*manifest.Plugin, error) {
+			data, err := yaml.Marshal(meta)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			return pluginHub.UpdateManifest(tag, data)
+		}
+
+		It("should update metadata of installed plugin", func() {
+			install("test", meta)
+
+			meta.DisplayName = "Updated mock plugin"
+			meta.Endpoints[0].PrivatePort = 9090
+			meta.Cron = []*manifest.CronJob{{Name: "cleanup", Schedule: "@daily", Command: "cleanup"}}
+			plugin, err := update("test/mock", meta)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.Tag).Should(Equal("test/mock:1.0"))
+
+			plugin, err = pluginHub.GetPluginInfo("test/mock:1.0")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(plugin.Endpoints[0].PrivatePort).Should(Equal(int32(9090)))
+			Ω(plugin.Cron).Should(HaveLen(1))
+			Ω(getTags(pluginHub.ListPlugins("test", ""))).Should(ConsistOf("mock"))
+			Ω(pluginHub.ListPlugins("test", "")[0].DisplayName).Should(Equal("Updated mock plugin"))
+		})
+
+		It("should reject changes requiring rebuild", func() {
+			install("test", meta)
+
+			changed := *meta
+			changed.BaseImage = "alpine"
+			_, err := update("test/mock", &changed)
+			Ω(err).Should(Equal(ManifestChangeError{"mock", "Base-Image"}))
+
+			changed = *meta
+			changed.Version = "2.0"
+			_, err = update("test/mock", &changed)
+			Ω(err).Should(Equal(ManifestChangeError{"mock", "Version"}))
+
+			changed = *meta
+			changed.Volumes = []*manifest.Volume{{Name: "data", MountPath: "/data"}}
+			_, err = update("test/mock", &changed)
+			Ω(err).Should(Equal(ManifestChangeError{"mock", "Volumes"}))
+		})
+
+		It("should fail if plugin not found", func() {
+			_, err := update("test/mock", meta)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
 	Describe("Remove plugin", func() {
 		It("should remove installed plugin", func() {
 			install("", meta)
@@ -356,4 +477,86 @@ var _ = Describe("Hub", func() {
 			Ω(pluginHub.RemovePlugin("..:..")).ShouldNot(Succeed())
 		})
 	})
+
+	Describe("Resolve requires", func() {
+		var service = func(name, version string, requires ...string) *manifest.Plugin {
+			return &manifest.Plugin{
+				Name:      name,
+				Version:   version,
+				Vendor:    "cloudway",
+				Category:  manifest.Service,
+				BaseImage: "busybox",
+				Requires:  requires,
+			}
+		}
+
+		var resolve = func(selected ...*manifest.Plugin) ([]string, error) {
+			required, err := ResolveRequires(nil, selected, pluginHub.GetPluginInfo)
+			return getTagsWithVersion(required), err
+		}
+
+		It("should add required plugins transitively", func() {
+			install("", service("mysql", "5.7.12"))
+			install("", service("cache", "1.0", "mysql:5.7"))
+
+			meta.Requires = []string{"cache"}
+			Ω(resolve(meta)).Should(Equal([]string{"cache:1.0", "mysql:5.7.12"}))
+		})
+
+		It("should not add selected or installed plugins", func() {
+			install("", service("mysql", "5.7.12"))
+			mysql, err := pluginHub.GetPluginInfo("mysql")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			meta.Requires = []string{"mysql:5"}
+			Ω(resolve(meta, mysql)).Should(BeEmpty())
+
+			required, err := ResolveRequires([]*manifest.Plugin{mysql}, []*manifest.Plugin{meta}, pluginHub.GetPluginInfo)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(required).Should(BeEmpty())
+		})
+
+		It("should report version conflicts", func() {
+			install("", service("mysql", "5.6.30"))
+			mysql, err := pluginHub.GetPluginInfo("mysql")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			meta.Requires = []string{"mysql:5.7"}
+			_, err = resolve(meta, mysql)
+			Ω(err).Should(BeAssignableToTypeOf(RequirementConflictError{}))
+		})
+
+		It("should report circular requirements", func() {
+			install("", service("a", "1.0", "b"))
+			install("", service("b", "1.0", "a"))
+
+			meta.Requires = []string{"a"}
+			_, err := resolve(meta)
+			Ω(err).Should(Equal(RequirementCycleError{"a", "b", "a"}))
+		})
+
+		It("should fail if required plugin not found", func() {
+			meta.Requires = []string{"nosuch"}
+			_, err := resolve(meta)
+			Ω(err).Should(BeAssignableToTypeOf(RequirementError{}))
+		})
+
+		It("should only add service plugins", func() {
+			other := *meta
+			other.Name = "other"
+			install("", &other)
+
+			meta.Requires = []string{"other"}
+			_, err := resolve(meta)
+			Ω(err).Should(BeAssignableToTypeOf(RequirementError{}))
+		})
+
+		It("should reject plugin requiring itself", func() {
+			meta.Requires = []string{"mock"}
+			path, err := makeMockPlugin(meta)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(path)
+			Ω(pluginHub.InstallPlugin("", path)).ShouldNot(Succeed())
+		})
+	})
 })
@@ -0,0 +1,468 @@
+// Package registry keeps plugins of the hub in a Docker registry. Each
+// object is pushed as an artifact with a single layer, tagged with the
+// object key, so plugins are stored next to application images and share
+// replication and access control of the registry.
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/hub"
+)
+
+const (
+	manifestType = "application/vnd.oci.image.manifest.v1+json"
+	configType   = "application/vnd.cloudway.plugin.config.v1+json"
+	layerType    = "application/vnd.cloudway.plugin.layer.v1"
+)
+
+// The maximum length of a tag accepted by registries.
+const maxTagLen = 128
+
+type storage struct {
+	client     *http.Client
+	url        string
+	repository string
+	username   string
+	password   string
+
+	mu    sync.Mutex
+	token string
+}
+
+func init() {
+	prev := hub.NewStorage
+	hub.NewStorage = func() (hub.Storage, error) {
+		if config.Get("hub.storage") != "registry" {
+			return prev()
+		}
+
+		u := config.Get("hub.registry.url")
+		if u == "" {
+			return nil, errors.New("Hub registry URL not configured")
+		}
+
+		return &storage{
+			client:     &http.Client{},
+			url:        strings.TrimSuffix(u, "/"),
+			repository: config.GetOrDefault("hub.registry.repository", "cloudway/plugins"),
+			username:   config.Get("hub.registry.username"),
+			password:   config.Get("hub.registry.password"),
+		}, nil
+	}
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// Keys are mapped to tags by replacing slashes with dashes, which never
+// appear in keys.
+func tagOf(key string) (string, error) {
+	tag := strings.Replace(key, "/", "-", -1)
+	if len(tag) > maxTagLen {
+		return "", fmt.Errorf("%s: key too long to be stored in registry", key)
+	}
+	return tag, nil
+}
+
+func keyOf(tag string) string {
+	return strings.Replace(tag, "-", "/", -1)
+}
+
+func (s *storage) endpoint(path string) string {
+	return s.url + "/v2/" + s.repository + path
+}
+
+func (s *storage) Put(key string, r io.Reader) error {
+	tag, err := tagOf(key)
+	if err != nil {
+		return err
+	}
+
+	// spool the object to compute the digest before uploading
+	f, err := ioutil.TempFile("", "hub")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	layer := descriptor{layerType, "sha256:" + hex.EncodeToString(h.Sum(nil)), size}
+	if err = s.pushBlob(layer, func() io.Reader {
+		f.Seek(0, os.SEEK_SET)
+		return f
+	}); err != nil {
+		return err
+	}
+
+	// the config differs for each key, so manifests of identical objects
+	// are not shared and removed together
+	cfg, _ := json.Marshal(map[string]string{"key": key})
+	sum := sha256.Sum256(cfg)
+	conf := descriptor{configType, "sha256:" + hex.EncodeToString(sum[:]), int64(len(cfg))}
+	if err = s.pushBlob(conf, func() io.Reader { return bytes.NewReader(cfg) }); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestType,
+		Config:        conf,
+		Layers:        []descriptor{layer},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.send("PUT", s.endpoint("/manifests/"+tag), func(req *http.Request) {
+		req.Header.Set("Content-Type", manifestType)
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+	})
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusCreated)
+}
+
+// pushBlob uploads the blob in a single request unless it already exists.
+func (s *storage) pushBlob(desc descriptor, body func() io.Reader) error {
+	resp, err := s.send("HEAD", s.endpoint("/blobs/"+desc.Digest), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = s.send("POST", s.endpoint("/blobs/uploads/"), nil)
+	if err != nil {
+		return err
+	}
+	if err = checkResponse(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = s.send("PUT", location.String(), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Body = ioutil.NopCloser(body())
+		req.ContentLength = desc.Size
+	})
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, http.StatusCreated)
+}
+
+func (s *storage) Get(key string) (io.ReadCloser, error) {
+	tag, err := tagOf(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.send("GET", s.endpoint("/manifests/"+tag), acceptManifest)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	err = json.NewDecoder(resp.Body).Decode(&m)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Layers) != 1 || m.Layers[0].MediaType != layerType {
+		return nil, fmt.Errorf("%s: not a plugin artifact", key)
+	}
+
+	resp, err = s.send("GET", s.endpoint("/blobs/"+m.Layers[0].Digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *storage) List(prefix string) ([]string, error) {
+	var keys []string
+	next := s.endpoint("/tags/list")
+	for next != "" {
+		resp, err := s.send("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			// the repository is created when the first object is stored
+			resp.Body.Close()
+			return nil, nil
+		}
+		if err = checkStatus(resp, http.StatusOK); err != nil {
+			return nil, err
+		}
+
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&tags)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags.Tags {
+			if key := keyOf(tag); strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+
+		next, err = s.nextPage(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+var linkPattern = regexp.MustCompile(`^\s*<([^>]+)>\s*;\s*rel="?next"?`)
+
+// nextPage returns the URL of the next page of tags from the Link header.
+func (s *storage) nextPage(resp *http.Response) (string, error) {
+	m := linkPattern.FindStringSubmatch(resp.Header.Get("Link"))
+	if m == nil {
+		return "", nil
+	}
+	u, err := url.Parse(m[1])
+	if err != nil {
+		return "", err
+	}
+	return resp.Request.URL.ResolveReference(u).String(), nil
+}
+
+func (s *storage) Remove(key string) error {
+	tag, err := tagOf(key)
+	if err != nil {
+		return err
+	}
+
+	// manifests can only be deleted by digest
+	resp, err := s.send("HEAD", s.endpoint("/manifests/"+tag), acceptManifest)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return fmt.Errorf("%s: manifest digest not returned by registry", key)
+	}
+
+	resp, err = s.send("DELETE", s.endpoint("/manifests/"+digest), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil
+	}
+	return checkResponse(resp, http.StatusAccepted)
+}
+
+func acceptManifest(req *http.Request) {
+	req.Header.Set("Accept", manifestType)
+}
+
+// send sends the request to the registry. The request is authenticated
+// and sent again if the registry requires authentication.
+func (s *storage) send(method, urlStr string, prepare func(*http.Request)) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, urlStr, nil)
+		if err != nil {
+			return nil, err
+		}
+		if prepare != nil {
+			prepare(req)
+		}
+		s.authorize(req)
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if err = s.authenticate(challenge); err != nil {
+		return nil, err
+	}
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *storage) authorize(req *http.Request) {
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate obtains a bearer token as requested by the challenge. Basic
+// authentication is used if no token is required.
+func (s *storage) authenticate(challenge string) error {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		if s.username == "" {
+			return errors.New("registry authentication required")
+		}
+		return nil
+	}
+
+	params := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("invalid registry authentication challenge: %s", challenge)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.repository + ":pull,push,delete"
+	}
+	query := url.Values{"scope": {scope}}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if err = checkStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	s.mu.Lock()
+	s.token = token.Token
+	s.mu.Unlock()
+	return nil
+}
+
+// checkResponse checks the response status and closes the response.
+func checkResponse(resp *http.Response, status int) error {
+	err := checkStatus(resp, status)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+// checkStatus returns an error with messages reported by the registry if
+// the response status is unexpected. The response is closed on error.
+func checkStatus(resp *http.Response, status int) error {
+	if resp.StatusCode == status {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&body) == nil && len(body.Errors) != 0 {
+		var msgs []string
+		for _, e := range body.Errors {
+			msgs = append(msgs, e.Code+": "+e.Message)
+		}
+		return fmt.Errorf("registry: %s", strings.Join(msgs, "; "))
+	}
+	return fmt.Errorf("registry: unexpected response status: %s", resp.Status)
+}
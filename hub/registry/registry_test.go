@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry implements the registry API used by the storage, requiring
+// a bearer token for all requests. Tags are listed one per page.
+type fakeRegistry struct {
+	*httptest.Server
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte // keyed by digest
+	tags      map[string]string // tag to digest
+	uploads   int
+}
+
+func newFakeRegistry() *fakeRegistry {
+	r := &fakeRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string]string),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		if user, pass, _ := req.BasicAuth(); user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"token":"t0ken"}`)
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer t0ken" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.URL+`/token",service="test"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/v2/cloudway/plugins/"
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, prefix)
+	body, _ := ioutil.ReadAll(req.Body)
+
+	switch {
+	case path == "tags/list":
+		var tags []string
+		for tag := range r.tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		last := req.URL.Query().Get("last")
+		for len(tags) != 0 && last != "" && tags[0] <= last {
+			tags = tags[1:]
+		}
+		if len(tags) > 1 {
+			w.Header().Set("Link", `<`+prefix+`tags/list?n=1&last=`+tags[0]+`>; rel="next"`)
+			tags = tags[:1]
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"tags": tags})
+
+	case path == "blobs/uploads/" && req.Method == "POST":
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("%sblobs/uploads/%d?state=x", prefix, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+
+	case strings.HasPrefix(path, "blobs/uploads/") && req.Method == "PUT":
+		digest := req.URL.Query().Get("digest")
+		if digest != digestOf(body) || req.URL.Query().Get("state") != "x" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest] = body
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasPrefix(path, "blobs/"):
+		data, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+
+	case strings.HasPrefix(path, "manifests/"):
+		ref := strings.TrimPrefix(path, "manifests/")
+		switch req.Method {
+		case "PUT":
+			digest := digestOf(body)
+			r.manifests[digest] = body
+			r.tags[ref] = digest
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			if _, ok := r.manifests[ref]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(r.manifests, ref)
+			for tag, digest := range r.tags {
+				if digest == ref {
+					delete(r.tags, tag)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			digest, ok := r.tags[ref]
+			if !ok || req.Header.Get("Accept") != manifestType {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Write(r.manifests[digest])
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	reg := newFakeRegistry()
+	defer reg.Close()
+
+	s := &storage{
+		client:     &http.Client{},
+		url:        reg.URL,
+		repository: "cloudway/plugins",
+		username:   "user",
+		password:   "secret",
+	}
+
+	if keys, err := s.List(""); err != nil || len(keys) != 0 {
+		t.Fatalf("List() = %v, %v; want empty", keys, err)
+	}
+
+	objects := map[string]string{
+		"_/mysql/5.7/1.tar.gz":  "mysql",
+		"_/mysql/access/2.json": "{}",
+		"demo/php/7.0/3.tar.gz": "mysql",
+	}
+	for key, data := range objects {
+		if err := s.Put(key, strings.NewReader(data)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	keys, err := s.List("_/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_/mysql/5.7/1.tar.gz", "_/mysql/access/2.json"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() = %v; want %v", keys, want)
+	}
+
+	for key, data := range objects {
+		r, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", key, err)
+		}
+		got, _ := ioutil.ReadAll(r)
+		r.Close()
+		if string(got) != data {
+			t.Errorf("Get(%s) = %q; want %q", key, got, data)
+		}
+	}
+
+	// objects with identical content are removed independently
+	if err = s.Remove("_/mysql/5.7/1.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("_/mysql/5.7/1.tar.gz"); !os.IsNotExist(err) {
+		t.Errorf("Get() after Remove() = %v; want not exist", err)
+	}
+	if _, err = s.Get("demo/php/7.0/3.tar.gz"); err != nil {
+		t.Errorf("Get() of object with identical content: %v", err)
+	}
+	if err = s.Remove("_/mysql/5.7/1.tar.gz"); err != nil {
+		t.Errorf("Remove() of missing object: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// RequirementCycleError reports that plugins require each other, directly
+// or indirectly. The value is the names of plugins in the cycle.
+type RequirementCycleError []string
+
+func (e RequirementCycleError) Error() string {
+	return fmt.Sprintf("Circular plugin requirements: %s", strings.Join(e, " -> "))
+}
+
+func (e RequirementCycleError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// RequirementConflictError reports that a plugin requires a version of
+// another plugin different from the one selected.
+type RequirementConflictError struct {
+	Plugin   string
+	Requires string
+	Selected string
+}
+
+func (e RequirementConflictError) Error() string {
+	return fmt.Sprintf("%s requires %s, which conflicts with %s", e.Plugin, e.Requires, e.Selected)
+}
+
+func (e RequirementConflictError) HTTPErrorStatusCode() int {
+	return http.StatusConflict
+}
+
+// RequirementError reports that a required plugin cannot be installed.
+type RequirementError struct {
+	Plugin   string
+	Requires string
+	Err      error
+}
+
+func (e RequirementError) Error() string {
+	return fmt.Sprintf("%s requires %s: %v", e.Plugin, e.Requires, e.Err)
+}
+
+func (e RequirementError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ResolveRequires returns plugins required by the selected plugins, directly
+// or indirectly, that are neither installed nor selected. A requirement is
+// a plugin tag, and the version in the tag matches all versions starting
+// with it. Installed and selected plugins satisfy requirements of the same
+// name, other required plugins are looked up by the given function and
+// must be service plugins.
+func ResolveRequires(installed, selected []*manifest.Plugin, lookup func(tag string) (*manifest.Plugin, error)) ([]*manifest.Plugin, error) {
+	r := &resolver{
+		lookup:   lookup,
+		selected: make(map[string]*manifest.Plugin),
+		state:    make(map[string]int),
+	}
+	for _, p := range installed {
+		r.selected[p.Name] = p
+		r.state[p.Name] = visited
+	}
+	for _, p := range selected {
+		r.selected[p.Name] = p
+	}
+	for _, p := range selected {
+		if err := r.visit(p); err != nil {
+			return nil, err
+		}
+	}
+	return r.added, nil
+}
+
+const (
+	visiting = iota + 1
+	visited
+)
+
+type resolver struct {
+	lookup   func(tag string) (*manifest.Plugin, error)
+	selected map[string]*manifest.Plugin
+	state    map[string]int
+	path     []string
+	added    []*manifest.Plugin
+}
+
+func (r *resolver) visit(p *manifest.Plugin) error {
+	switch r.state[p.Name] {
+	case visited:
+		return nil
+	case visiting:
+		for i, name := range r.path {
+			if name == p.Name {
+				cycle := append([]string{}, r.path[i:]...)
+				return RequirementCycleError(append(cycle, p.Name))
+			}
+		}
+	}
+
+	r.state[p.Name] = visiting
+	r.path = append(r.path, p.Name)
+
+	for _, req := range p.Requires {
+		dep, err := r.require(p, req)
+		if err != nil {
+			return err
+		}
+		if err = r.visit(dep); err != nil {
+			return err
+		}
+	}
+
+	r.path = r.path[:len(r.path)-1]
+	r.state[p.Name] = visited
+	return nil
+}
+
+func (r *resolver) require(p *manifest.Plugin, req string) (*manifest.Plugin, error) {
+	_, _, name, version, err := ParseTag(req)
+	if err != nil {
+		return nil, RequirementError{p.Name, req, err}
+	}
+
+	if dep := r.selected[name]; dep != nil {
+		if !versionMatches(dep.Version, version) {
+			return nil, RequirementConflictError{p.Name, req, dep.Tag}
+		}
+		return dep, nil
+	}
+
+	dep, err := r.lookup(req)
+	if err != nil {
+		return nil, RequirementError{p.Name, req, err}
+	}
+	if !dep.IsService() {
+		return nil, RequirementError{p.Name, req, fmt.Errorf("%s is not a service plugin", dep.Name)}
+	}
+
+	r.selected[name] = dep
+	r.added = append(r.added, dep)
+	return dep, nil
+}
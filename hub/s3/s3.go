@@ -0,0 +1,119 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/hub"
+)
+
+// Hub storage that keeps plugin archives in an Amazon S3 compatible object
+// storage. Objects are stored with the key prefix/key.
+type storage struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+func init() {
+	prev := hub.NewStorage
+	hub.NewStorage = func() (hub.Storage, error) {
+		if config.Get("hub.storage") != "s3" {
+			return prev()
+		}
+
+		bucket := config.Get("hub.s3.bucket")
+		if bucket == "" {
+			return nil, errors.New("S3 hub bucket not configured")
+		}
+
+		cfg := aws.NewConfig()
+		if region := config.Get("hub.s3.region"); region != "" {
+			cfg = cfg.WithRegion(region)
+		}
+		if endpoint := config.Get("hub.s3.endpoint"); endpoint != "" {
+			cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+		}
+
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		prefix := strings.Trim(config.Get("hub.s3.prefix"), "/")
+		if prefix != "" {
+			prefix += "/"
+		}
+
+		return &storage{
+			client:   s3.New(sess),
+			uploader: s3manager.NewUploader(sess),
+			bucket:   bucket,
+			prefix:   prefix,
+		}, nil
+	}
+}
+
+func (s *storage) Put(key string, r io.Reader) error {
+	_, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   r,
+	})
+	return err
+}
+
+func (s *storage) Get(key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if isNotFound(err) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *storage) List(prefix string) ([]string, error) {
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix + prefix),
+	}
+
+	var keys []string
+	err := s.client.ListObjectsPages(input, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.StringValue(obj.Key), s.prefix))
+		}
+		return true
+	})
+	return keys, err
+}
+
+func (s *storage) Remove(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	return err
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == s3.ErrCodeNoSuchKey
+	}
+	return false
+}
@@ -0,0 +1,431 @@
+package hub
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/archive"
+)
+
+// Storage keeps plugin archives and access control in a shared location,
+// so multiple API servers can share the hub and plugins survive the loss
+// of a host. The hub directory is used as a local cache of the storage.
+//
+// Keys are slash separated paths. Each path element only contains letters,
+// digits, underscores and dots.
+type Storage interface {
+	// Put stores the object with the key, replacing the existing object.
+	Put(key string, r io.Reader) error
+
+	// Get opens the object with the key. The returned error satisfies
+	// os.IsNotExist if the object is not found.
+	Get(key string) (io.ReadCloser, error)
+
+	// List keys of all objects with the prefix.
+	List(prefix string) ([]string, error)
+
+	// Remove the object with the key. Removing a missing object is not
+	// an error.
+	Remove(key string) error
+}
+
+// NewStorage creates the storage configured by "hub.storage", returns nil
+// if plugins are only kept in the local hub directory. Other storage
+// backends are registered by chaining this function.
+var NewStorage = func() (Storage, error) {
+	switch typ := config.Get("hub.storage"); typ {
+	case "", "local":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unsupported hub storage: %s", typ)
+	}
+}
+
+// Objects are stored with the key namespace/name/item/revision.ext, where
+// item is a plugin version or "access". A new revision is stored when the
+// object changed, so changes made by other servers are found by listing
+// keys. Older revisions are removed after the new revision is stored.
+const (
+	accessItem    = "access"
+	archiveSuffix = ".tar.gz"
+	accessSuffix  = ".json"
+)
+
+// The local revision of a cached object is kept in a hidden file besides
+// the object.
+func revisionFile(base, item string) string {
+	return filepath.Join(base, "."+item+".rev")
+}
+
+func readRevision(base, item string) int64 {
+	data, err := ioutil.ReadFile(revisionFile(base, item))
+	if err != nil {
+		return 0
+	}
+	rev, _ := strconv.ParseInt(string(data), 10, 64)
+	return rev
+}
+
+func writeRevision(base, item string, rev int64) error {
+	return ioutil.WriteFile(revisionFile(base, item), []byte(strconv.FormatInt(rev, 10)), 0644)
+}
+
+// storedObject is the latest revision of an object in the storage.
+type storedObject struct {
+	namespace, name, item string
+	rev                   int64
+	key                   string
+}
+
+func itemPrefix(namespace, name, item string) string {
+	if namespace == "" {
+		namespace = "_"
+	}
+	prefix := namespace + "/"
+	if name != "" {
+		prefix += name + "/"
+		if item != "" {
+			prefix += item + "/"
+		}
+	}
+	return prefix
+}
+
+func itemKey(namespace, name, item string, rev int64) string {
+	suffix := archiveSuffix
+	if item == accessItem {
+		suffix = accessSuffix
+	}
+	return itemPrefix(namespace, name, item) + strconv.FormatInt(rev, 10) + suffix
+}
+
+// parseKey returns the object stored with the key, or nil if the key is
+// not an object of the hub.
+func parseKey(key string) *storedObject {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 {
+		return nil
+	}
+
+	namespace, name, item, file := parts[0], parts[1], parts[2], parts[3]
+	if namespace == "_" {
+		namespace = ""
+	}
+	if item == accessItem {
+		file = strings.TrimSuffix(file, accessSuffix)
+	} else if splitVersion(item) != nil {
+		file = strings.TrimSuffix(file, archiveSuffix)
+	} else {
+		return nil
+	}
+
+	rev, err := strconv.ParseInt(file, 10, 64)
+	if err != nil || rev <= 0 {
+		return nil
+	}
+	return &storedObject{namespace, name, item, rev, key}
+}
+
+// newRevision returns a revision higher than the given revision. Revisions
+// are based on time so revisions stored by different servers are ordered.
+func newRevision(prev int64) int64 {
+	rev := time.Now().UnixNano()
+	if rev <= prev {
+		rev = prev + 1
+	}
+	return rev
+}
+
+func syncInterval() time.Duration {
+	d, err := time.ParseDuration(config.GetOrDefault("hub.sync_interval", "30s"))
+	if err != nil || d < 0 {
+		logrus.Warnf("Invalid hub.sync_interval configuration: %s", config.Get("hub.sync_interval"))
+		d = 30 * time.Second
+	}
+	return d
+}
+
+// sync updates the local cache from the storage, at most once within the
+// sync interval unless forced. The cache is kept if the storage can't be
+// reached, so installed plugins remain usable.
+func (hub *PluginHub) sync(force bool) {
+	if hub.storage == nil {
+		return
+	}
+
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+
+	if !force && time.Since(hub.synced) < syncInterval() {
+		return
+	}
+	if err := hub.syncLocked(); err != nil {
+		logrus.WithError(err).Warn("Failed to synchronize plugin hub from storage")
+		return
+	}
+	hub.synced = time.Now()
+}
+
+func (hub *PluginHub) syncLocked() error {
+	keys, err := hub.storage.List("")
+	if err != nil {
+		return err
+	}
+
+	latest := make(map[string]*storedObject)
+	for _, key := range keys {
+		if obj := parseKey(key); obj != nil {
+			id := itemPrefix(obj.namespace, obj.name, obj.item)
+			if prev := latest[id]; prev == nil || obj.rev > prev.rev {
+				latest[id] = obj
+			}
+		}
+	}
+
+	changed := make(map[string]bool)
+	for _, obj := range latest {
+		base := hub.getBaseDir(obj.namespace, obj.name, "")
+		if readRevision(base, obj.item) == obj.rev {
+			continue
+		}
+		if err := hub.fetch(base, obj); err != nil {
+			logrus.WithError(err).Warnf("Failed to fetch %s from hub storage", obj.key)
+			continue
+		}
+		changed[obj.namespace] = true
+	}
+
+	// remove cached objects removed from the storage by other servers
+	namespaces, _ := readDirNames(hub.installDir)
+	for _, ns := range namespaces {
+		names, _ := readDirNames(filepath.Join(hub.installDir, ns))
+		if ns == "_" {
+			ns = ""
+		}
+		for _, name := range names {
+			base := hub.getBaseDir(ns, name, "")
+			items, _ := readDirNames(base)
+			for _, item := range items {
+				if item != accessFile && splitVersion(item) == nil {
+					continue
+				}
+				id := item
+				if item == accessFile {
+					id = accessItem
+				}
+				if latest[itemPrefix(ns, name, id)] == nil && readRevision(base, id) != 0 {
+					os.RemoveAll(filepath.Join(base, item))
+					os.Remove(revisionFile(base, id))
+					changed[ns] = true
+				}
+			}
+		}
+	}
+
+	for ns := range changed {
+		hub.invalidate(ns)
+	}
+	return nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(0)
+}
+
+// fetch replaces the cached object with the object in the storage.
+func (hub *PluginHub) fetch(base string, obj *storedObject) error {
+	r, err := hub.storage.Get(obj.key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err = os.MkdirAll(base, 0755); err != nil {
+		return err
+	}
+
+	if obj.item == accessItem {
+		filename := filepath.Join(base, accessFile)
+		if err = writeFile(filename+".tmp", r); err != nil {
+			return err
+		}
+		if err = os.Rename(filename+".tmp", filename); err != nil {
+			return err
+		}
+		return writeRevision(base, obj.item, obj.rev)
+	}
+
+	// extract to a temporary directory so an incomplete plugin is never
+	// used, hidden files are not recognized as plugin versions
+	tempdir, err := ioutil.TempDir(base, ".fetch")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+	if err = os.Chmod(tempdir, 0755); err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	if err = archive.ExtractFiles(tempdir, zr); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(base, obj.item)
+	if err = os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err = os.Rename(tempdir, dir); err != nil {
+		return err
+	}
+	return writeRevision(base, obj.item, obj.rev)
+}
+
+// publishVersion stores the installed plugin version in the storage. The
+// sync lock must be held.
+func (hub *PluginHub) publishVersion(namespace, name, version string) error {
+	if hub.storage == nil {
+		return nil
+	}
+
+	dir := hub.getBaseDir(namespace, name, version)
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		tw := tar.NewWriter(zw)
+		err := archive.CopyFileTree(tw, "", dir, nil, false)
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := hub.publish(namespace, name, version, pr)
+	pr.Close()
+	return err
+}
+
+// publishAccess stores access control of the plugin in the storage. The
+// sync lock must be held.
+func (hub *PluginHub) publishAccess(namespace, name string, access *Access) error {
+	if hub.storage == nil {
+		return nil
+	}
+	data, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+	return hub.publish(namespace, name, accessItem, bytes.NewReader(data))
+}
+
+func (hub *PluginHub) publish(namespace, name, item string, r io.Reader) error {
+	base := hub.getBaseDir(namespace, name, "")
+	rev := newRevision(readRevision(base, item))
+	if err := hub.storage.Put(itemKey(namespace, name, item, rev), r); err != nil {
+		return err
+	}
+	if err := writeRevision(base, item, rev); err != nil {
+		return err
+	}
+
+	// remove older revisions, failures are harmless since the latest
+	// revision is always used
+	if keys, err := hub.storage.List(itemPrefix(namespace, name, item)); err == nil {
+		for _, key := range keys {
+			if obj := parseKey(key); obj != nil && obj.item == item && obj.rev < rev {
+				hub.storage.Remove(key)
+			}
+		}
+	}
+	return nil
+}
+
+// unpublish removes objects with the prefix from the storage. The sync
+// lock must be held.
+func (hub *PluginHub) unpublish(prefix string) error {
+	if hub.storage == nil {
+		return nil
+	}
+	keys, err := hub.storage.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err = hub.storage.Remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seed stores plugins in the local hub directory to an empty storage, so
+// plugins installed before the storage is configured are not lost.
+func (hub *PluginHub) seed() error {
+	hub.syncMu.Lock()
+	defer hub.syncMu.Unlock()
+
+	keys, err := hub.storage.List("")
+	if err != nil || len(keys) != 0 {
+		return err
+	}
+
+	namespaces, _ := readDirNames(hub.installDir)
+	for _, ns := range namespaces {
+		names, _ := readDirNames(filepath.Join(hub.installDir, ns))
+		if ns == "_" {
+			ns = ""
+		}
+		for _, name := range names {
+			base := hub.getBaseDir(ns, name, "")
+			items, _ := readDirNames(base)
+			for _, item := range items {
+				if splitVersion(item) != nil {
+					err = hub.publishVersion(ns, name, item)
+				} else if item == accessFile {
+					err = hub.seedAccess(ns, name)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	logrus.Infof("Plugin hub storage seeded from %s", hub.installDir)
+	return nil
+}
+
+func (hub *PluginHub) seedAccess(namespace, name string) error {
+	data, err := ioutil.ReadFile(filepath.Join(hub.getBaseDir(namespace, name, ""), accessFile))
+	if err != nil {
+		return err
+	}
+	var access Access
+	if err = json.Unmarshal(data, &access); err != nil {
+		return err
+	}
+	return hub.publishAccess(namespace, name, &access)
+}
@@ -0,0 +1,167 @@
+package hub
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// memStorage keeps objects in memory.
+type memStorage struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memStorage) Put(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.objects[key] = data
+	s.Unlock()
+	return nil
+}
+
+func (s *memStorage) Get(key string) (io.ReadCloser, error) {
+	s.Lock()
+	defer s.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memStorage) List(prefix string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *memStorage) Remove(key string) error {
+	s.Lock()
+	delete(s.objects, key)
+	s.Unlock()
+	return nil
+}
+
+var _ = Describe("Storage", func() {
+	var storage *memStorage
+	var hub1, hub2 *PluginHub
+	var meta *manifest.Plugin
+
+	newHub := func() *PluginHub {
+		dir, err := ioutil.TempDir("", "hub")
+		Expect(err).NotTo(HaveOccurred())
+		return &PluginHub{installDir: dir, storage: storage}
+	}
+
+	install := func(hub *PluginHub, namespace string, meta *manifest.Plugin) {
+		path, err := makeMockPlugin(meta)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		defer os.RemoveAll(path)
+		ExpectWithOffset(1, hub.InstallPlugin(namespace, path)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		storage = &memStorage{objects: make(map[string][]byte)}
+		hub1, hub2 = newHub(), newHub()
+		meta = &manifest.Plugin{
+			Name:      "mock",
+			Version:   "1.0",
+			Category:  manifest.Framework,
+			BaseImage: "busybox",
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(hub1.installDir)
+		os.RemoveAll(hub2.installDir)
+	})
+
+	It("should share installed plugins", func() {
+		install(hub1, "test", meta)
+		Expect(storage.List("test/mock/1.0/")).To(HaveLen(1))
+
+		hub2.sync(true)
+		plugin, err := hub2.GetPluginInfo("test/mock")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.Tag).To(Equal("test/mock:1.0"))
+		Expect(getTags(hub2.ListPlugins("test", ""))).To(ConsistOf("mock"))
+	})
+
+	It("should share updated manifests", func() {
+		install(hub1, "test", meta)
+		hub2.sync(true)
+		Expect(hub2.ListPlugins("test", "")).To(HaveLen(1))
+
+		meta.DisplayName = "Updated"
+		data, err := yaml.Marshal(meta)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = hub1.UpdateManifest("test/mock:1.0", data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(storage.List("test/mock/1.0/")).To(HaveLen(1))
+
+		hub2.sync(true)
+		plugin, err := hub2.GetPluginInfo("test/mock:1.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.DisplayName).To(Equal("Updated"))
+	})
+
+	It("should share plugin access", func() {
+		install(hub1, "test", meta)
+		Expect(hub1.GrantPluginAccess("test", "mock", "other")).To(Succeed())
+
+		hub2.sync(true)
+
+		Expect(hub2.CanAccess("test", "mock", "other")).To(BeTrue())
+		Expect(getTags(hub2.SharedPlugins("other"))).To(ConsistOf("mock"))
+	})
+
+	It("should share removed plugins", func() {
+		install(hub1, "test", meta)
+		meta.Version = "2.0"
+		install(hub1, "test", meta)
+		hub2.sync(true)
+		Expect(getTagsWithVersion(hub2.ListPlugins("test", ""))).To(ConsistOf("mock:2.0"))
+
+		Expect(hub1.RemovePlugin("test/mock:2.0")).To(Succeed())
+		hub2.sync(true)
+		Expect(getTagsWithVersion(hub2.ListPlugins("test", ""))).To(ConsistOf("mock:1.0"))
+
+		hub1.RemoveNamespace("test")
+		Expect(storage.List("")).To(BeEmpty())
+		hub2.sync(true)
+		Expect(hub2.ListPlugins("test", "")).To(BeEmpty())
+	})
+
+	It("should keep plugins installed before the storage is configured", func() {
+		hub1.storage = nil
+		install(hub1, "", meta)
+
+		hub1.storage = storage
+		Expect(hub1.seed()).To(Succeed())
+		Expect(storage.List("_/mock/1.0/")).To(HaveLen(1))
+		hub2.sync(true)
+		Expect(getTags(hub2.ListPlugins("", ""))).To(ConsistOf("mock"))
+	})
+})
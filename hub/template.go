@@ -0,0 +1,190 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Template is an application saved in the hub, from which new applications
+// are created with the same plugins, environment and optionally repository.
+type Template struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Created     time.Time
+
+	// The plugin tags of the application, service plugins are prefixed
+	// with the service name.
+	Plugins []string
+
+	// Environment variables of the application framework.
+	Env map[string]string `json:",omitempty"`
+
+	TimeZone string `json:",omitempty"`
+	Locale   string `json:",omitempty"`
+
+	// True if the application repository is saved with the template.
+	Repo bool `json:",omitempty"`
+}
+
+// Templates are saved in a hidden directory of the namespace directory,
+// which is never taken as a plugin since it contains no plugin versions.
+const (
+	templatesDir = ".templates"
+	templateFile = "template.json"
+	repoFile     = "repo.tar"
+)
+
+var templateNamePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
+
+type TemplateNotFoundError string
+
+func (e TemplateNotFoundError) Error() string {
+	return fmt.Sprintf("Template not found: %s", string(e))
+}
+
+func (e TemplateNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+type InvalidTemplateError string
+
+func (e InvalidTemplateError) Error() string {
+	return fmt.Sprintf("Invalid template: %s", string(e))
+}
+
+func (e InvalidTemplateError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (hub *PluginHub) templateDir(namespace, name string) string {
+	return filepath.Join(hub.getBaseDir(namespace, "", ""), templatesDir, name)
+}
+
+// SaveTemplate saves the template in the namespace, replacing the template
+// of the same name. The repository archive is saved if not nil.
+func (hub *PluginHub) SaveTemplate(namespace string, t *Template, repo io.Reader) (err error) {
+	if !templateNamePattern.MatchString(t.Name) {
+		return InvalidTemplateError("the name can only contain lower case letters, digits or underscores")
+	}
+	if len(t.Plugins) == 0 {
+		return InvalidTemplateError("no plugins specified")
+	}
+
+	// write the template into a temporary directory and move it into place,
+	// so the template is never seen partially written
+	base := filepath.Join(hub.getBaseDir(namespace, "", ""), templatesDir)
+	if err = os.MkdirAll(base, 0755); err != nil {
+		return err
+	}
+	tempdir, err := ioutil.TempDir(base, ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	t.Repo = repo != nil
+	if t.Repo {
+		if err = writeFile(filepath.Join(tempdir, repoFile), repo); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(tempdir, templateFile), data, 0644); err != nil {
+		return err
+	}
+
+	dir := hub.templateDir(namespace, t.Name)
+	if err = os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tempdir, dir)
+}
+
+func writeFile(filename string, r io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// GetTemplate returns the template saved in the namespace.
+func (hub *PluginHub) GetTemplate(namespace, name string) (*Template, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, TemplateNotFoundError(name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(hub.templateDir(namespace, name), templateFile))
+	if os.IsNotExist(err) {
+		return nil, TemplateNotFoundError(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t Template
+	if err = json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// OpenTemplateRepo opens the repository archive saved with the template.
+func (hub *PluginHub) OpenTemplateRepo(namespace, name string) (*os.File, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, TemplateNotFoundError(name)
+	}
+	f, err := os.Open(filepath.Join(hub.templateDir(namespace, name), repoFile))
+	if os.IsNotExist(err) {
+		return nil, TemplateNotFoundError(name)
+	}
+	return f, err
+}
+
+// ListTemplates returns all templates saved in the namespace, sorted by name.
+func (hub *PluginHub) ListTemplates(namespace string) ([]*Template, error) {
+	f, err := os.Open(filepath.Join(hub.getBaseDir(namespace, "", ""), templatesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(0)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	templates := make([]*Template, 0, len(names))
+	for _, name := range names {
+		if t, err := hub.GetTemplate(namespace, name); err == nil {
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+// RemoveTemplate removes the template from the namespace.
+func (hub *PluginHub) RemoveTemplate(namespace, name string) error {
+	if _, err := hub.GetTemplate(namespace, name); err != nil {
+		return err
+	}
+	return os.RemoveAll(hub.templateDir(namespace, name))
+}
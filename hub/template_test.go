@@ -0,0 +1,99 @@
+package hub
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+var _ = Describe("Templates", func() {
+	AfterEach(func() {
+		emptyTestDir()
+	})
+
+	var template = func(name string) *Template {
+		return &Template{
+			Name:    name,
+			Plugins: []string{"php:5.6", "db=mysql:5.5"},
+			Env:     map[string]string{"APP_ENV": "production"},
+		}
+	}
+
+	It("should save and list templates", func() {
+		Ω(pluginHub.SaveTemplate("test", template("b"), nil)).Should(Succeed())
+		Ω(pluginHub.SaveTemplate("test", template("a"), strings.NewReader("repo"))).Should(Succeed())
+
+		templates, err := pluginHub.ListTemplates("test")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(templates).Should(HaveLen(2))
+		Ω(templates[0].Name).Should(Equal("a"))
+		Ω(templates[0].Repo).Should(BeTrue())
+		Ω(templates[1].Name).Should(Equal("b"))
+		Ω(templates[1].Repo).Should(BeFalse())
+		Ω(templates[1].Plugins).Should(Equal([]string{"php:5.6", "db=mysql:5.5"}))
+		Ω(templates[1].Env).Should(HaveKeyWithValue("APP_ENV", "production"))
+
+		f, err := pluginHub.OpenTemplateRepo("test", "a")
+		Ω(err).ShouldNot(HaveOccurred())
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		Ω(string(data)).Should(Equal("repo"))
+
+		_, err = pluginHub.OpenTemplateRepo("test", "b")
+		Ω(err).Should(Equal(TemplateNotFoundError("b")))
+
+		templates, err = pluginHub.ListTemplates("other")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(templates).Should(BeEmpty())
+	})
+
+	It("should replace existing template", func() {
+		Ω(pluginHub.SaveTemplate("test", template("a"), strings.NewReader("repo"))).Should(Succeed())
+		t := template("a")
+		t.Description = "changed"
+		Ω(pluginHub.SaveTemplate("test", t, nil)).Should(Succeed())
+
+		t, err := pluginHub.GetTemplate("test", "a")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(t.Description).Should(Equal("changed"))
+		Ω(t.Repo).Should(BeFalse())
+		Ω(filepath.Join(pluginHub.templateDir("test", "a"), repoFile)).ShouldNot(BeAnExistingFile())
+	})
+
+	It("should remove template", func() {
+		Ω(pluginHub.SaveTemplate("test", template("a"), nil)).Should(Succeed())
+		Ω(pluginHub.RemoveTemplate("test", "a")).Should(Succeed())
+		Ω(pluginHub.RemoveTemplate("test", "a")).Should(Equal(TemplateNotFoundError("a")))
+
+		_, err := pluginHub.GetTemplate("test", "a")
+		Ω(err).Should(Equal(TemplateNotFoundError("a")))
+	})
+
+	It("should reject invalid templates", func() {
+		Ω(pluginHub.SaveTemplate("test", template("../a"), nil)).Should(BeAssignableToTypeOf(InvalidTemplateError("")))
+		Ω(pluginHub.SaveTemplate("test", &Template{Name: "a"}, nil)).Should(BeAssignableToTypeOf(InvalidTemplateError("")))
+		_, err := pluginHub.GetTemplate("test", "../a")
+		Ω(err).Should(Equal(TemplateNotFoundError("../a")))
+	})
+
+	It("should not list templates as plugins", func() {
+		path, err := makeMockPlugin(&manifest.Plugin{
+			Name:      "mock",
+			Version:   "1.0",
+			Category:  manifest.Framework,
+			BaseImage: "busybox",
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(path)
+		Ω(pluginHub.InstallPlugin("test", path)).Should(Succeed())
+		Ω(pluginHub.SaveTemplate("test", template("a"), nil)).Should(Succeed())
+
+		Ω(getTags(pluginHub.ListPlugins("test", ""))).Should(ConsistOf("mock"))
+	})
+})
@@ -1,6 +1,7 @@
 package hub
 
 import (
+	"fmt"
 	"os"
 	"sort"
 	"strconv"
@@ -118,3 +119,87 @@ comparing:
 
 	return joinVersion(lastMatch)
 }
+
+// versionMatches returns true if the version starts with all components
+// of the wanted version. An empty wanted version matches any version.
+func versionMatches(version, want string) bool {
+	if want == "" {
+		return true
+	}
+	vtab, wtab := splitVersion(version), splitVersion(want)
+	if vtab == nil || wtab == nil || len(vtab) < len(wtab) {
+		return false
+	}
+	for i := range wtab {
+		if vtab[i] != wtab[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type constraint struct {
+	op      string
+	version string
+	vtab    []int
+}
+
+type constraints []constraint
+
+// parseConstraints parses comma separated version constraints. Supported
+// operators are "=", "!=", ">", ">=", "<" and "<=".
+func parseConstraints(s string) (constraints, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var cs constraints
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		var op string
+		for _, prefix := range []string{"!=", ">=", "<=", "=", ">", "<"} {
+			if strings.HasPrefix(c, prefix) {
+				op, c = prefix, strings.TrimSpace(c[len(prefix):])
+				break
+			}
+		}
+		vtab := splitVersion(c)
+		if vtab == nil {
+			return nil, fmt.Errorf("invalid version constraint: %s", s)
+		}
+		cs = append(cs, constraint{op, c, vtab})
+	}
+	return cs, nil
+}
+
+// match returns true if the version satisfies all constraints.
+func (cs constraints) match(version string) bool {
+	vtab := splitVersion(version)
+	if vtab == nil {
+		return len(cs) == 0
+	}
+
+	for _, c := range cs {
+		var ok bool
+		switch cmp := compareVersions(vtab, c.vtab); c.op {
+		case "":
+			ok = versionMatches(version, c.version)
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/logstore"
+)
+
+// Log store that keeps log entries in an Elasticsearch index, configured by
+// logstore.url and logstore.index. Entries are written with the bulk API.
+type store struct {
+	base   string
+	index  string
+	client *http.Client
+}
+
+func init() {
+	prev := logstore.New
+	logstore.New = func() (logstore.Store, error) {
+		if config.Get("logstore.type") != "elasticsearch" {
+			return prev()
+		}
+
+		url := strings.TrimRight(config.Get("logstore.url"), "/")
+		if url == "" {
+			return nil, errors.New("Elasticsearch URL of log store not configured")
+		}
+
+		s := &store{
+			base:   url,
+			index:  config.GetOrDefault("logstore.index", "cloudway-logs"),
+			client: &http.Client{Timeout: 30 * time.Second},
+		}
+		if err := s.createIndex(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// document is the log entry indexed by Elasticsearch.
+type document struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Namespace string    `json:"namespace"`
+	App       string    `json:"app"`
+	Container string    `json:"container"`
+	Stream    string    `json:"stream"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+}
+
+var mapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"@timestamp": map[string]string{"type": "date"},
+			"namespace":  map[string]string{"type": "keyword"},
+			"app":        map[string]string{"type": "keyword"},
+			"container":  map[string]string{"type": "keyword"},
+			"stream":     map[string]string{"type": "keyword"},
+			"severity":   map[string]string{"type": "keyword"},
+			"message":    map[string]string{"type": "text"},
+		},
+	},
+}
+
+// createIndex creates the index with the mapping of log entries, unless
+// the index exists.
+func (s *store) createIndex() error {
+	resp, err := s.do("HEAD", "/"+s.index, nil, "")
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	body, _ := json.Marshal(mapping)
+	resp, err = s.do("PUT", "/"+s.index, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends the request to Elasticsearch. The response is returned with an
+// error if the request failed.
+func (s *store) do(method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, fmt.Errorf("elasticsearch: %s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (s *store) post(path string, query interface{}, result interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do("POST", path, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (s *store) Write(entries []*logstore.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	action := map[string]interface{}{"index": map[string]string{"_index": s.index}}
+	for _, e := range entries {
+		enc.Encode(action)
+		enc.Encode(&document{
+			Timestamp: e.Time,
+			Namespace: e.Namespace,
+			App:       e.App,
+			Container: e.Container,
+			Stream:    e.Stream,
+			Severity:  e.Severity,
+			Message:   e.Message,
+		})
+	}
+
+	resp, err := s.do("POST", "/_bulk", &buf, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Errors bool
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return errors.New("elasticsearch: failed to index some log entries")
+	}
+	return nil
+}
+
+func (s *store) Search(namespace, name string, q logstore.Query) ([]*logstore.Entry, error) {
+	filter := appFilter(namespace, name)
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		r := map[string]interface{}{}
+		if !q.Since.IsZero() {
+			r["gte"] = q.Since
+		}
+		if !q.Until.IsZero() {
+			r["lte"] = q.Until
+		}
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"@timestamp": r}})
+	}
+	if q.Severity != "" {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"severity": logstore.SeveritiesFrom(q.Severity)}})
+	}
+
+	query := map[string]interface{}{"filter": filter}
+	if q.Text != "" {
+		query["must"] = map[string]interface{}{
+			"simple_query_string": map[string]interface{}{
+				"query":            q.Text,
+				"fields":           []string{"message"},
+				"default_operator": "and",
+			},
+		}
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source document `json:"_source"`
+			}
+		}
+	}
+	err := s.post("/"+s.index+"/_search", map[string]interface{}{
+		"size":  q.MaxEntries(),
+		"sort":  []interface{}{map[string]string{"@timestamp": "desc"}},
+		"query": map[string]interface{}{"bool": query},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	// hits are sorted with the most recent first
+	hits := result.Hits.Hits
+	entries := make([]*logstore.Entry, len(hits))
+	for i, h := range hits {
+		d := h.Source
+		entries[len(hits)-1-i] = &logstore.Entry{
+			Time:      d.Timestamp,
+			Namespace: d.Namespace,
+			App:       d.App,
+			Container: d.Container,
+			Stream:    d.Stream,
+			Severity:  d.Severity,
+			Message:   d.Message,
+		}
+	}
+	return entries, nil
+}
+
+func (s *store) Remove(namespace, name string) error {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": appFilter(namespace, name)},
+		},
+	}
+	return s.post("/"+s.index+"/_delete_by_query", query, nil)
+}
+
+func (s *store) Expire(before time.Time) error {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"range": map[string]interface{}{
+				"@timestamp": map[string]interface{}{"lt": before},
+			},
+		},
+	}
+	return s.post("/"+s.index+"/_delete_by_query", query, nil)
+}
+
+func appFilter(namespace, name string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{"term": map[string]string{"namespace": namespace}},
+		map[string]interface{}{"term": map[string]string{"app": name}},
+	}
+}
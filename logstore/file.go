@@ -0,0 +1,201 @@
+package logstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The layout of the date in names of daily log files.
+const dayLayout = "2006-01-02"
+
+const logSuffix = ".log"
+
+// The file store keeps log entries in daily files of each application, in
+// JSON lines. The date in file names indexes entries by time so a search
+// only reads files in the time range.
+type fileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore creates a store that keeps log entries in the local file
+// system.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) appDir(namespace, name string) string {
+	return filepath.Join(s.dir, namespace, name)
+}
+
+func (s *fileStore) Write(entries []*Entry) error {
+	files := make(map[string][]*Entry)
+	for _, e := range entries {
+		file := filepath.Join(s.appDir(e.Namespace, e.App), e.Time.UTC().Format(dayLayout)+logSuffix)
+		files[file] = append(files[file], e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for file, entries := range files {
+		if err := appendEntries(file, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendEntries(file string, entries []*Entry) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err = enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *fileStore) Search(namespace, name string, q Query) ([]*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	days, err := s.days(s.appDir(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+
+	// read the most recent files first until enough entries found
+	var result []*Entry
+	limit := q.MaxEntries()
+	for i := len(days) - 1; i >= 0 && len(result) < limit; i-- {
+		day := days[i]
+		if !q.Since.IsZero() && day.Add(24*time.Hour).Before(q.Since) {
+			break
+		}
+		if !q.Until.IsZero() && day.After(q.Until) {
+			continue
+		}
+
+		file := filepath.Join(s.appDir(namespace, name), day.Format(dayLayout)+logSuffix)
+		entries, err := readEntries(file, &q)
+		if err != nil {
+			return nil, err
+		}
+		sort.Stable(byTime(entries))
+		if n := limit - len(result); len(entries) > n {
+			entries = entries[len(entries)-n:]
+		}
+		result = append(entries, result...)
+	}
+	return result, nil
+}
+
+func readEntries(file string, q *Query) ([]*Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var e Entry
+		if err = dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		if q.Match(&e) {
+			entries = append(entries, &e)
+		}
+	}
+	return entries, nil
+}
+
+// days returns dates of log files in the directory in ascending order.
+func (s *fileStore) days(dir string) ([]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+logSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var days []time.Time
+	for _, file := range files {
+		day, err := time.Parse(dayLayout, strings.TrimSuffix(filepath.Base(file), logSuffix))
+		if err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Sort(byDay(days))
+	return days, nil
+}
+
+func (s *fileStore) Remove(namespace, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.RemoveAll(s.appDir(namespace, name))
+}
+
+// Expire removes daily files with all entries written before the given
+// time.
+func (s *fileStore) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := filepath.Glob(filepath.Join(s.dir, "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		days, err := s.days(dir)
+		if err != nil {
+			return err
+		}
+		for _, day := range days {
+			if !day.Add(24 * time.Hour).After(before) {
+				file := filepath.Join(dir, day.Format(dayLayout)+logSuffix)
+				if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type byTime []*Entry
+
+func (a byTime) Len() int           { return len(a) }
+func (a byTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTime) Less(i, j int) bool { return a[i].Time.Before(a[j].Time) }
+
+type byDay []time.Time
+
+func (a byDay) Len() int           { return len(a) }
+func (a byDay) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byDay) Less(i, j int) bool { return a[i].Before(a[j]) }
@@ -0,0 +1,184 @@
+// Package logstore aggregates logs of application containers into a
+// searchable store.
+package logstore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Log severities in increasing order.
+const (
+	SeverityDebug   = "debug"
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+	SeverityFatal   = "fatal"
+)
+
+var severities = []string{SeverityDebug, SeverityInfo, SeverityWarning, SeverityError, SeverityFatal}
+
+// The default and maximum number of entries returned by a search.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Entry is a line of container log.
+type Entry struct {
+	Time      time.Time
+	Namespace string
+	App       string
+	Container string
+	Stream    string
+	Severity  string
+	Message   string
+}
+
+// Query selects log entries of an application. Zero values match all.
+type Query struct {
+	// Terms must all be contained in the message, case insensitive.
+	Text string
+
+	// The time range of entries.
+	Since, Until time.Time
+
+	// The minimum severity of entries.
+	Severity string
+
+	// The maximum number of most recent entries returned.
+	Limit int
+}
+
+// Store saves and searches log entries of applications.
+type Store interface {
+	// Write log entries of any applications.
+	Write(entries []*Entry) error
+
+	// Search log entries of the application, the most recent last.
+	Search(namespace, name string, q Query) ([]*Entry, error)
+
+	// Remove all log entries of the application.
+	Remove(namespace, name string) error
+
+	// Expire log entries written before the given time.
+	Expire(before time.Time) error
+}
+
+// New creates the store configured by "logstore.type", returns nil if log
+// aggregation is not enabled. Other stores are registered by chaining this
+// function.
+var New = func() (Store, error) {
+	switch typ := config.Get("logstore.type"); typ {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileStore(config.GetOrDefault("logstore.dir", "/var/lib/cloudway/logs"))
+	default:
+		return nil, fmt.Errorf("Unsupported log store: %s", typ)
+	}
+}
+
+// Retention returns how long log entries are kept, configured by
+// "logstore.retention".
+func Retention() (time.Duration, error) {
+	retention, err := time.ParseDuration(config.GetOrDefault("logstore.retention", "168h"))
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("Invalid logstore.retention configuration: %s", config.Get("logstore.retention"))
+	}
+	return retention, nil
+}
+
+// ValidSeverity returns true if the severity is known.
+func ValidSeverity(severity string) bool {
+	return severityLevel(severity) >= 0
+}
+
+func severityLevel(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// SeveritiesFrom returns the severities not lower than the given severity.
+func SeveritiesFrom(severity string) []string {
+	if i := severityLevel(severity); i > 0 {
+		return severities[i:]
+	}
+	return severities
+}
+
+// Only the beginning of a message is examined for severity, so words like
+// "error" in the message body are not mistaken for the log level.
+const severityPrefixLen = 64
+
+var reSeverity = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|err|error|crit|critical|fatal|panic|emerg|alert)\b`)
+
+// ParseSeverity detects the severity of a log message from the log level
+// written by most logging libraries. Messages without a known level have
+// the info severity on stdout, and the error severity on stderr.
+func ParseSeverity(stream, message string) string {
+	prefix := message
+	if len(prefix) > severityPrefixLen {
+		prefix = prefix[:severityPrefixLen]
+	}
+	if m := reSeverity.FindString(prefix); m != "" {
+		switch strings.ToLower(m) {
+		case "trace", "debug":
+			return SeverityDebug
+		case "info", "notice":
+			return SeverityInfo
+		case "warn", "warning":
+			return SeverityWarning
+		case "err", "error":
+			return SeverityError
+		default:
+			return SeverityFatal
+		}
+	}
+	if stream == "stderr" {
+		return SeverityError
+	}
+	return SeverityInfo
+}
+
+// Match returns true if the entry is selected by the query.
+func (q *Query) Match(e *Entry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Severity != "" && severityLevel(e.Severity) < severityLevel(q.Severity) {
+		return false
+	}
+	if q.Text != "" {
+		message := strings.ToLower(e.Message)
+		for _, term := range strings.Fields(strings.ToLower(q.Text)) {
+			if !strings.Contains(message, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MaxEntries returns the maximum number of entries returned by the query,
+// DefaultLimit if not specified and bounded to MaxLimit.
+func (q *Query) MaxEntries() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return q.Limit
+}
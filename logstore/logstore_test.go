@@ -0,0 +1,126 @@
+package logstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Log Store Suite")
+}
+
+var _ = Describe("Severity", func() {
+	It("should detect log levels", func() {
+		Expect(ParseSeverity("stdout", "2016/10/17 [ERROR] connection refused")).To(Equal(SeverityError))
+		Expect(ParseSeverity("stdout", `time="2016-10-17" level=warning msg="slow"`)).To(Equal(SeverityWarning))
+		Expect(ParseSeverity("stderr", "DEBUG: cache miss")).To(Equal(SeverityDebug))
+		Expect(ParseSeverity("stdout", "PHP Fatal error: out of memory")).To(Equal(SeverityFatal))
+		Expect(ParseSeverity("stdout", "GET /index.html 200")).To(Equal(SeverityInfo))
+		Expect(ParseSeverity("stderr", "Traceback (most recent call last):")).To(Equal(SeverityError))
+		Expect(ParseSeverity("stdout", "errors")).To(Equal(SeverityInfo))
+	})
+
+	It("should select severities not lower than the minimum", func() {
+		Expect(SeveritiesFrom(SeverityError)).To(Equal([]string{SeverityError, SeverityFatal}))
+		Expect(SeveritiesFrom("")).To(HaveLen(5))
+		Expect(ValidSeverity("verbose")).To(BeFalse())
+	})
+})
+
+var _ = Describe("File store", func() {
+	const (
+		NAMESPACE = "logstore_test"
+		NAME      = "test"
+	)
+
+	var (
+		dir   string
+		store Store
+		now   = time.Date(2016, 10, 17, 12, 0, 0, 0, time.UTC)
+	)
+
+	entry := func(t time.Time, severity, message string) *Entry {
+		return &Entry{
+			Time:      t,
+			Namespace: NAMESPACE,
+			App:       NAME,
+			Container: "php.0123456789ab",
+			Stream:    "stdout",
+			Severity:  severity,
+			Message:   message,
+		}
+	}
+
+	messages := func(entries []*Entry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.Message)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "logstore")
+		Expect(err).NotTo(HaveOccurred())
+		store, err = NewFileStore(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.Write([]*Entry{
+			entry(now.Add(-48*time.Hour), SeverityInfo, "old request"),
+			entry(now.Add(-time.Hour), SeverityError, "Database connection refused"),
+			entry(now.Add(-30*time.Minute), SeverityInfo, "GET /index.html"),
+			entry(now, SeverityWarning, "slow database query"),
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should search entries in time order", func() {
+		entries, err := store.Search(NAMESPACE, NAME, Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages(entries)).To(Equal([]string{
+			"old request", "Database connection refused", "GET /index.html", "slow database query",
+		}))
+	})
+
+	It("should filter entries by text, time and severity", func() {
+		entries, err := store.Search(NAMESPACE, NAME, Query{Text: "DATABASE"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages(entries)).To(Equal([]string{"Database connection refused", "slow database query"}))
+
+		entries, err = store.Search(NAMESPACE, NAME, Query{Since: now.Add(-2 * time.Hour), Until: now.Add(-time.Minute)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages(entries)).To(Equal([]string{"Database connection refused", "GET /index.html"}))
+
+		entries, err = store.Search(NAMESPACE, NAME, Query{Severity: SeverityWarning})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages(entries)).To(Equal([]string{"Database connection refused", "slow database query"}))
+	})
+
+	It("should return the most recent entries up to the limit", func() {
+		entries, err := store.Search(NAMESPACE, NAME, Query{Limit: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages(entries)).To(Equal([]string{"GET /index.html", "slow database query"}))
+	})
+
+	It("should expire and remove entries", func() {
+		Expect(store.Expire(now.Add(-24 * time.Hour))).To(Succeed())
+		entries, err := store.Search(NAMESPACE, NAME, Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+
+		Expect(store.Remove(NAMESPACE, NAME)).To(Succeed())
+		entries, err = store.Search(NAMESPACE, NAME, Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+})
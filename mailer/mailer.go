@@ -0,0 +1,103 @@
+// Package mailer sends notification emails, such as registration
+// verification, through a configurable transport.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/config/defaults"
+)
+
+// Message is a plain text email message.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New creates the mailer configured by "mail.driver", which defaults to
+// SMTP if the SMTP server is configured in the smtp section. Returns nil
+// if no mailer is configured. Other drivers are registered by chaining
+// this function.
+var New = func() (Mailer, error) {
+	switch typ := config.Get("mail.driver"); typ {
+	case "":
+		if config.Get("smtp.host") == "" {
+			return nil, nil
+		}
+		return newSMTPMailer(), nil
+	case "smtp":
+		return newSMTPMailer(), nil
+	case "log":
+		return LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported mail driver: %s", typ)
+	}
+}
+
+// SMTPMailer sends messages through a SMTP server.
+type SMTPMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+func newSMTPMailer() *SMTPMailer {
+	host := config.Get("smtp.host")
+	port := config.GetOrDefault("smtp.port", "25")
+
+	var auth smtp.Auth
+	if username := config.Get("smtp.username"); username != "" {
+		auth = smtp.PlainAuth("", username, config.Get("smtp.password"), host)
+	}
+
+	return &SMTPMailer{
+		Addr: host + ":" + port,
+		Auth: auth,
+		From: config.GetOrDefault("smtp.from", "Cloudway <daemon@"+defaults.Domain()+">"),
+	}
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	from := m.From
+	if i := strings.LastIndex(from, "<"); i != -1 {
+		from = strings.TrimSuffix(from[i+1:], ">")
+	}
+	return smtp.SendMail(m.Addr, m.Auth, from, msg.To, format(m.From, msg))
+}
+
+// LogMailer writes messages to the log instead of sending them, used
+// for development.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg *Message) error {
+	logrus.WithFields(logrus.Fields{
+		"to":      strings.Join(msg.To, ", "),
+		"subject": msg.Subject,
+	}).Info(msg.Body)
+	return nil
+}
+
+func format(from string, msg *Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	return buf.Bytes()
+}
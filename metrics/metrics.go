@@ -0,0 +1,112 @@
+// Package metrics collects operational metrics of the platform and
+// exposes them in Prometheus format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "cloudway"
+
+var (
+	// RequestDuration observes latencies of API requests per route.
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Latencies of API requests in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "code"},
+	)
+
+	// BrokerErrors counts errors returned by the broker while serving API requests.
+	BrokerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "broker",
+			Name:      "errors_total",
+			Help:      "Number of errors returned by the broker.",
+		},
+		[]string{"route"},
+	)
+
+	// Deployments counts application deployments by strategy and result.
+	Deployments = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deployments_total",
+			Help:      "Number of application deployments.",
+		},
+		[]string{"strategy", "result"},
+	)
+
+	// DeploymentDuration observes durations of application deployments.
+	DeploymentDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "deployment_duration_seconds",
+			Help:      "Durations of application deployments in seconds.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+		},
+		[]string{"strategy"},
+	)
+
+	// ContainersCreated counts created application containers by category.
+	ContainersCreated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "containers_created_total",
+			Help:      "Number of created application containers.",
+		},
+		[]string{"category"},
+	)
+
+	// ContainersDestroyed counts destroyed application containers by category.
+	ContainersDestroyed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "containers_destroyed_total",
+			Help:      "Number of destroyed application containers.",
+		},
+		[]string{"category"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		RequestDuration,
+		BrokerErrors,
+		Deployments,
+		DeploymentDuration,
+		ContainersCreated,
+		ContainersDestroyed,
+	)
+}
+
+// Handler returns the HTTP handler that serves metrics to Prometheus.
+func Handler() http.Handler {
+	return prometheus.Handler()
+}
+
+// ObserveRequest records the latency of an API request.
+func ObserveRequest(method, route string, code int, start time.Time) {
+	RequestDuration.WithLabelValues(method, route, strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveDeployment records the result and duration of a deployment. It's
+// intended to be deferred with the start time and a pointer to the error
+// returned by the deployment.
+func ObserveDeployment(strategy string, start time.Time, err *error) {
+	result := "success"
+	if *err != nil {
+		result = "failure"
+	}
+	Deployments.WithLabelValues(strategy, result).Inc()
+	DeploymentDuration.WithLabelValues(strategy).Observe(time.Since(start).Seconds())
+}
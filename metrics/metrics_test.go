@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T) string {
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestObserveDeployment(t *testing.T) {
+	deploy := func(fail bool) (err error) {
+		defer ObserveDeployment("rolling", time.Now(), &err)
+		if fail {
+			err = errors.New("deployment failed")
+		}
+		return err
+	}
+	deploy(false)
+	deploy(true)
+	deploy(true)
+
+	body := scrape(t)
+	for _, line := range []string{
+		`cloudway_deployments_total{result="success",strategy="rolling"} 1`,
+		`cloudway_deployments_total{result="failure",strategy="rolling"} 2`,
+		`cloudway_deployment_duration_seconds_count{strategy="rolling"} 3`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
+
+func TestObserveRequest(t *testing.T) {
+	ObserveRequest("GET", "/applications/{name}", http.StatusNotFound, time.Now())
+
+	line := `cloudway_api_request_duration_seconds_count{code="404",method="GET",route="/applications/{name}"} 1`
+	if body := scrape(t); !strings.Contains(body, line) {
+		t.Errorf("metrics missing %q", line)
+	}
+}
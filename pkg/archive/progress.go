@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"io"
+	"time"
+)
+
+// Progress describes the progress of copying an archive.
+type Progress struct {
+	// The number of bytes copied.
+	Current int64
+
+	// The total number of bytes to be copied, zero if unknown.
+	Total int64
+}
+
+// ProgressReader wraps an archive stream and periodically reports the
+// number of bytes read through it. Nothing is reported until the threshold
+// number of bytes is reached, so small archives are copied silently.
+type ProgressReader struct {
+	r         io.Reader
+	progress  Progress
+	interval  time.Duration
+	threshold int64
+	last      time.Time
+	reported  bool
+	report    func(Progress)
+}
+
+// NewProgressReader creates a ProgressReader that calls report at most
+// once per interval after threshold bytes have been read.
+func NewProgressReader(r io.Reader, total int64, interval time.Duration, threshold int64, report func(Progress)) *ProgressReader {
+	return &ProgressReader{
+		r:         r,
+		progress:  Progress{Total: total},
+		interval:  interval,
+		threshold: threshold,
+		last:      time.Now(),
+		report:    report,
+	}
+}
+
+func (p *ProgressReader) Read(b []byte) (n int, err error) {
+	n, err = p.r.Read(b)
+	p.progress.Current += int64(n)
+
+	if p.progress.Current >= p.threshold {
+		now := time.Now()
+		if err == io.EOF {
+			// always report the completion if progress was reported
+			if p.reported {
+				p.report(p.progress)
+			}
+		} else if now.Sub(p.last) >= p.interval {
+			p.last = now
+			p.reported = true
+			p.report(p.progress)
+		}
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func copyWithProgress(size int, threshold int64) []Progress {
+	var reports []Progress
+	content := bytes.NewReader(make([]byte, size))
+	r := NewProgressReader(content, int64(size), 0, threshold, func(p Progress) {
+		reports = append(reports, p)
+	})
+	io.CopyBuffer(ioutil.Discard, r, make([]byte, 1024))
+	return reports
+}
+
+func TestProgressReaderLargeArchive(t *testing.T) {
+	reports := copyWithProgress(64*1024, 16*1024)
+	if len(reports) == 0 {
+		t.Fatal("Expected progress reported for large archive")
+	}
+
+	first := reports[0]
+	if first.Current < 16*1024 {
+		t.Fatalf("Progress reported before threshold: %d", first.Current)
+	}
+
+	last := reports[len(reports)-1]
+	if last.Current != 64*1024 || last.Total != 64*1024 {
+		t.Fatalf("Unexpected final progress: %+v", last)
+	}
+
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Current < reports[i-1].Current {
+			t.Fatalf("Progress goes backward: %+v", reports)
+		}
+	}
+}
+
+func TestProgressReaderSmallArchive(t *testing.T) {
+	if reports := copyWithProgress(4*1024, 16*1024); len(reports) != 0 {
+		t.Fatalf("Unexpected progress reported for small archive: %+v", reports)
+	}
+}
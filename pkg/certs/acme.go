@@ -0,0 +1,164 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/context"
+)
+
+// LetsEncryptURL is the directory URL of Let's Encrypt production server.
+const LetsEncryptURL = acme.LetsEncryptURL
+
+// Issuer obtains certificates from an ACME server using the HTTP-01
+// challenge. The challenge responses must be served to the ACME server
+// by the ServeChallenge method at the path ChallengePath + token of the
+// domain being validated.
+type Issuer struct {
+	client *acme.Client
+	email  string
+
+	mu         sync.Mutex
+	registered bool
+	challenges map[string]string
+}
+
+// ChallengePath is the URL path of HTTP-01 challenge responses.
+const ChallengePath = "/.well-known/acme-challenge/"
+
+// NewIssuer creates an ACME issuer with the account key. The account is
+// registered on the ACME server when the first certificate is issued.
+func NewIssuer(directoryURL, email string, key crypto.Signer) *Issuer {
+	return &Issuer{
+		client:     &acme.Client{Key: key, DirectoryURL: directoryURL},
+		email:      email,
+		challenges: make(map[string]string),
+	}
+}
+
+// ServeChallenge returns the key authorization of the challenge token.
+func (iss *Issuer) ServeChallenge(token string) (string, bool) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	resp, ok := iss.challenges[token]
+	return resp, ok
+}
+
+func (iss *Issuer) register(ctx context.Context) error {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	if iss.registered {
+		return nil
+	}
+
+	acct := &acme.Account{}
+	if iss.email != "" {
+		acct.Contact = []string{"mailto:" + iss.email}
+	}
+	_, err := iss.client.Register(ctx, acct, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return err
+	}
+	iss.registered = true
+	return nil
+}
+
+// Issue obtains a certificate for the domain, and returns the certificate
+// chain and private key encoded in PEM format.
+func (iss *Issuer) Issue(ctx context.Context, host string) (certPEM, keyPEM []byte, err error) {
+	if err = iss.register(ctx); err != nil {
+		return
+	}
+
+	order, err := iss.client.AuthorizeOrder(ctx, acme.DomainIDs(host))
+	if err != nil {
+		return
+	}
+
+	for _, u := range order.AuthzURLs {
+		if err = iss.authorize(ctx, u); err != nil {
+			return
+		}
+	}
+
+	order, err = iss.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return
+	}
+
+	keyPEM, err = GenerateKey()
+	if err != nil {
+		return
+	}
+	key, err := ParseKey(keyPEM)
+	if err != nil {
+		return
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: host},
+		DNSNames: []string{host},
+	}, key)
+	if err != nil {
+		return
+	}
+
+	der, _, err := iss.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return
+	}
+	for _, b := range der {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	return certPEM, keyPEM, nil
+}
+
+func (iss *Issuer) authorize(ctx context.Context, url string) error {
+	authz, err := iss.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return errors.New("acme: no supported challenge for " + authz.Identifier.Value)
+	}
+
+	resp, err := iss.client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+
+	iss.mu.Lock()
+	iss.challenges[chal.Token] = resp
+	iss.mu.Unlock()
+	defer func() {
+		iss.mu.Lock()
+		delete(iss.challenges, chal.Token)
+		iss.mu.Unlock()
+	}()
+
+	if _, err = iss.client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	if _, err = iss.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("acme: authorization of %s failed: %v", authz.Identifier.Value, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package certs validates TLS certificates of custom domains, and issues
+// certificates from an ACME certificate authority such as Let's Encrypt.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RenewBefore is the time before expiration that a certificate is renewed.
+const RenewBefore = 30 * 24 * time.Hour
+
+type InvalidCertificateError struct {
+	Domain string
+	Reason string
+}
+
+func (e InvalidCertificateError) Error() string {
+	return fmt.Sprintf("Invalid certificate for %s: %s", e.Domain, e.Reason)
+}
+
+func (e InvalidCertificateError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Parse validates the PEM encoded certificate chain and private key for
+// the given host, and returns the leaf certificate.
+func Parse(host string, certPEM, keyPEM []byte) (*x509.Certificate, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, InvalidCertificateError{host, err.Error()}
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, InvalidCertificateError{host, err.Error()}
+	}
+	if err = leaf.VerifyHostname(host); err != nil {
+		return nil, InvalidCertificateError{host, err.Error()}
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, InvalidCertificateError{host, "certificate has expired"}
+	}
+	return leaf, nil
+}
+
+// NeedsRenewal returns true if the certificate expires in RenewBefore.
+func NeedsRenewal(notAfter time.Time) bool {
+	return time.Now().Add(RenewBefore).After(notAfter)
+}
+
+// GenerateKey generates an ECDSA private key encoded in PEM format.
+func GenerateKey() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseKey parses an ECDSA private key encoded in PEM format.
+func ParseKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, errors.New("invalid private key")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
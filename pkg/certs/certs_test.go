@@ -0,0 +1,71 @@
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, host string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	keyPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM
+}
+
+func TestParse(t *testing.T) {
+	notAfter := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+	cert, key := selfSigned(t, "www.example.com", notAfter)
+
+	leaf, err := Parse("www.example.com", cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !leaf.NotAfter.Equal(notAfter) {
+		t.Errorf("NotAfter: got %v, want %v", leaf.NotAfter, notAfter)
+	}
+
+	if _, err = Parse("example.org", cert, key); err == nil {
+		t.Error("expected hostname mismatch")
+	}
+
+	_, other := selfSigned(t, "www.example.com", notAfter)
+	if _, err = Parse("www.example.com", cert, other); err == nil {
+		t.Error("expected key mismatch")
+	}
+
+	expired, key := selfSigned(t, "www.example.com", time.Now().Add(-time.Hour))
+	if _, err = Parse("www.example.com", expired, key); err == nil {
+		t.Error("expected expired certificate")
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	if NeedsRenewal(time.Now().Add(60 * 24 * time.Hour)) {
+		t.Error("certificate expires in 60 days should not be renewed")
+	}
+	if !NeedsRenewal(time.Now().Add(10 * 24 * time.Hour)) {
+		t.Error("certificate expires in 10 days should be renewed")
+	}
+}
@@ -0,0 +1,407 @@
+// Package delta computes differences between repository trees, so that
+// only changed files are transferred when deploying a repository to a
+// container which already has a previous version of the repository.
+package delta
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The name of the manifest file kept in the home directory of a container,
+// describing the repository currently deployed.
+const ManifestFile = ".repo.manifest"
+
+// The name of the archive entry describing the delta.
+const markerName = ".cloudway-delta"
+
+var (
+	// ErrNotDelta reports that an archive is not a delta archive.
+	ErrNotDelta = errors.New("not a delta archive")
+
+	// ErrBaseMismatch reports that a delta archive was computed against
+	// a repository different from the current one.
+	ErrBaseMismatch = errors.New("delta base doesn't match current repository")
+)
+
+// UnsupportedEntryError reports that an archive contains an entry that
+// cannot be tracked by a manifest, e.g. a hard link.
+type UnsupportedEntryError string
+
+func (e UnsupportedEntryError) Error() string {
+	return fmt.Sprintf("unsupported archive entry: %s", string(e))
+}
+
+// Manifest maps slash separated relative paths in a repository tree to
+// digests of the entries. A directory is described as "dir", a symbolic
+// link as "link:" followed by the target, and a regular file by its SHA-1
+// checksum, followed by "+x" if the file is executable.
+type Manifest map[string]string
+
+func fileDigest(r io.Reader, mode os.FileMode) (string, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if mode&0111 != 0 {
+		digest += "+x"
+	}
+	return digest, nil
+}
+
+// Scan the directory tree to create a manifest.
+func Scan(dir string) (Manifest, error) {
+	m := make(Manifest)
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case fi.IsDir():
+			m[rel] = "dir"
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			m[rel] = "link:" + target
+		case fi.Mode().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			m[rel], err = fileDigest(f, fi.Mode())
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return UnsupportedEntryError(rel)
+		}
+		return nil
+	})
+	return m, err
+}
+
+// ScanArchive reads the tar stream to create a manifest.
+func ScanArchive(r io.Reader) (Manifest, error) {
+	m := make(Manifest)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := cleanName(hdr.Name)
+		if !ok {
+			continue
+		}
+		if m[name], err = entryDigest(hdr, tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func entryDigest(hdr *tar.Header, r io.Reader) (string, error) {
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return "dir", nil
+	case tar.TypeSymlink:
+		return "link:" + hdr.Linkname, nil
+	case tar.TypeReg, tar.TypeRegA:
+		return fileDigest(r, hdr.FileInfo().Mode())
+	default:
+		return "", UnsupportedEntryError(hdr.Name)
+	}
+}
+
+// cleanName normalizes the archive entry name, returns false if the entry
+// is the root directory.
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if name == "." || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// validName returns false if the path escapes from the repository.
+func validName(name string) bool {
+	return name != ".." && !strings.HasPrefix(name, "../") && !path.IsAbs(name)
+}
+
+// ReadManifest reads a manifest written by WriteTo.
+func ReadManifest(r io.Reader) (Manifest, error) {
+	m := make(Manifest)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed manifest line: %q", s.Text())
+		}
+		m[fields[1]] = fields[0]
+	}
+	return m, s.Err()
+}
+
+// WriteTo writes the manifest as lines of digests and paths, sorted by path.
+func (m Manifest) WriteTo(w io.Writer) (n int64, err error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		k, err := fmt.Fprintf(w, "%s\t%s\n", m[name], name)
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Sum returns the checksum of the manifest.
+func (m Manifest) Sum() string {
+	h := sha1.New()
+	m.WriteTo(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Changed returns paths of files and symbolic links in the target manifest
+// that are not in the base manifest or changed since the base manifest,
+// sorted by path. Removed entries and directories are not reported.
+func Changed(base, target Manifest) []string {
+	var names []string
+	for name, digest := range target {
+		if digest != "dir" && base[name] != digest {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type marker struct {
+	Base    string
+	Removed []string `json:",omitempty"`
+}
+
+// Write a delta archive to w, containing entries of the tar stream r that
+// are not in the base manifest or changed since the base manifest. The
+// target manifest must describe the tar stream. Returns the number of
+// changed entries written to the delta archive.
+func Write(w io.Writer, r io.Reader, base, target Manifest) (n int, err error) {
+	mk := marker{Base: base.Sum()}
+	for name := range base {
+		if _, ok := target[name]; !ok {
+			mk.Removed = append(mk.Removed, name)
+		}
+	}
+	sort.Strings(mk.Removed)
+
+	data, err := json.Marshal(&mk)
+	if err != nil {
+		return 0, err
+	}
+
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{Name: markerName, Mode: 0644, Size: int64(len(data))}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return 0, err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		name, ok := cleanName(hdr.Name)
+		if !ok || (base[name] == target[name] && target[name] != "dir") {
+			continue
+		}
+
+		// directories are always written to keep their permissions
+		hdr.Name = name
+		if err = tw.WriteHeader(hdr); err != nil {
+			return n, err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return n, err
+		}
+		if target[name] != "dir" || base[name] != "dir" {
+			n++
+		}
+	}
+	return n, tw.Close()
+}
+
+// Apply the delta archive to the directory described by the base manifest.
+// Returns ErrBaseMismatch if the delta was not computed against the base
+// manifest.
+func Apply(dir string, base Manifest, r io.Reader) error {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != markerName {
+		return ErrNotDelta
+	}
+
+	var mk marker
+	if err = json.NewDecoder(tr).Decode(&mk); err != nil {
+		return err
+	}
+	if mk.Base != base.Sum() {
+		return ErrBaseMismatch
+	}
+
+	for _, name := range mk.Removed {
+		if !validName(name) {
+			return UnsupportedEntryError(name)
+		}
+		if err = os.RemoveAll(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = applyEntry(dir, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func applyEntry(dir string, hdr *tar.Header, r io.Reader) error {
+	name, ok := cleanName(hdr.Name)
+	if !ok {
+		return nil
+	}
+	if !validName(name) {
+		return UnsupportedEntryError(hdr.Name)
+	}
+
+	dst := filepath.Join(dir, filepath.FromSlash(name))
+	mode := hdr.FileInfo().Mode()
+
+	if hdr.Typeflag == tar.TypeDir {
+		if fi, err := os.Lstat(dst); err == nil && fi.IsDir() {
+			return os.Chmod(dst, mode.Perm())
+		}
+		os.RemoveAll(dst)
+		return os.MkdirAll(dst, mode.Perm())
+	}
+
+	// replace the existing entry
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		target := filepath.Join(filepath.Dir(dst), hdr.Linkname)
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid symlink %q -> %q", name, hdr.Linkname)
+		}
+		return os.Symlink(hdr.Linkname, dst)
+
+	case tar.TypeReg, tar.TypeRegA:
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+
+	default:
+		return UnsupportedEntryError(hdr.Name)
+	}
+}
+
+// Clone copies the directory tree, preserving symbolic links and file
+// permissions, so a delta can be applied to the copy.
+func Clone(src, dst string) error {
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, fi.Mode().Perm())
+
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+
+		case fi.Mode().IsRegular():
+			in, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			if _, err = io.Copy(out, in); err != nil {
+				out.Close()
+				return err
+			}
+			return out.Close()
+
+		default:
+			return nil
+		}
+	})
+}
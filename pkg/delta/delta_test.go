@@ -0,0 +1,168 @@
+package delta
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type entry struct {
+	name, content, link string
+	mode                int64
+}
+
+func makeArchive(t *testing.T, entries []entry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		case e.mode&0040000 != 0:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, e.mode&0777
+		default:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(e.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func extract(t *testing.T, data []byte) string {
+	dir, err := ioutil.TempDir("", "delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if err = applyEntry(dir, hdr, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestApplyDelta(t *testing.T) {
+	v1 := makeArchive(t, []entry{
+		{name: "./", mode: 0040755},
+		{name: "./app.py", content: "print 1", mode: 0644},
+		{name: "./run.sh", content: "#!/bin/sh", mode: 0755},
+		{name: "./lib/", mode: 0040755},
+		{name: "./lib/util.py", content: "pass", mode: 0644},
+		{name: "./current", link: "app.py"},
+	})
+	v2 := makeArchive(t, []entry{
+		{name: "./", mode: 0040755},
+		{name: "./app.py", content: "print 2", mode: 0644},
+		{name: "./run.sh", content: "#!/bin/sh", mode: 0755},
+		{name: "./lib/", mode: 0040755},
+		{name: "./static/", mode: 0040755},
+		{name: "./static/index.html", content: "<html>", mode: 0644},
+		{name: "./current", link: "run.sh"},
+	})
+
+	dir := extract(t, v1)
+	defer os.RemoveAll(dir)
+
+	base, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived, err := ScanArchive(bytes.NewReader(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Sum() != archived.Sum() {
+		t.Fatalf("Manifest of directory differs from archive:\n%v\n%v", base, archived)
+	}
+
+	target, err := ScanArchive(bytes.NewReader(v2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, bytes.NewReader(v2), base, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("Expected 4 changed entries, got %d", n)
+	}
+
+	if err = Apply(dir, base, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sum() != target.Sum() {
+		t.Fatalf("Unexpected result after applying delta:\n%v\n%v", result, target)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "lib", "util.py")); !os.IsNotExist(err) {
+		t.Fatal("Removed file still exists")
+	}
+
+	// the delta can't be applied again to the updated tree
+	if err = Apply(dir, result, bytes.NewReader(buf.Bytes())); err != ErrBaseMismatch {
+		t.Fatalf("Expected base mismatch, got %v", err)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	base := Manifest{"a": "dir", "a/b": "0123", "c": "4567", "d": "link:c", "e": "89ab"}
+	target := Manifest{"a": "dir", "a/b": "0123+x", "c": "4567", "d": "link:a/b", "f": "cdef", "g": "dir"}
+
+	changed := Changed(base, target)
+	if want := []string{"a/b", "d", "f"}; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("Expected changed entries %v, got %v", want, changed)
+	}
+	if changed = Changed(target, target); len(changed) != 0 {
+		t.Fatalf("Expected no changed entries, got %v", changed)
+	}
+}
+
+func TestManifestReadWrite(t *testing.T) {
+	m := Manifest{"a": "dir", "a/b c": "0123+x", "d": "link:a"}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Sum() != m.Sum() || len(read) != len(m) {
+		t.Fatalf("Manifest changed after read back: %v", read)
+	}
+}
+
+func TestUnsupportedEntry(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "file"})
+	tw.Close()
+
+	if _, err := ScanArchive(&buf); err == nil {
+		t.Fatal("Expected error for hard link")
+	}
+}
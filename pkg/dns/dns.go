@@ -0,0 +1,290 @@
+// Package dns implements a minimal DNS server. Address queries of names in
+// the served zone are answered by a resolver, and other queries are relayed
+// to upstream servers.
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Resource record types and classes.
+const (
+	TypeA    uint16 = 1
+	TypeAAAA uint16 = 28
+	TypeANY  uint16 = 255
+	ClassIN  uint16 = 1
+)
+
+// Response codes.
+const (
+	RcodeSuccess        = 0
+	RcodeFormatError    = 1
+	RcodeServerFailure  = 2
+	RcodeNameError      = 3
+	RcodeNotImplemented = 4
+	RcodeRefused        = 5
+)
+
+const (
+	headerLen  = 12
+	maxUDPSize = 512
+)
+
+// Resolver resolves names in the zone served by the server.
+type Resolver interface {
+	// Resolve returns addresses of the name queried by the client, or
+	// false if the name doesn't exist. The name is in lower case, without
+	// the trailing dot.
+	Resolve(name string, client net.IP) ([]net.IP, bool)
+}
+
+// The ResolverFunc type is an adapter to use ordinary functions as resolvers.
+type ResolverFunc func(name string, client net.IP) ([]net.IP, bool)
+
+func (f ResolverFunc) Resolve(name string, client net.IP) ([]net.IP, bool) {
+	return f(name, client)
+}
+
+// Server is a DNS server over UDP.
+type Server struct {
+	// The zone served by the resolver, such as "cloudway.local".
+	Zone string
+
+	// The resolver of names in the zone.
+	Resolver Resolver
+
+	// Addresses of upstream servers to relay queries of names not in the
+	// zone. The queries are refused if no upstream server is configured.
+	Upstream []string
+
+	// The time to live of answers in seconds.
+	TTL uint32
+
+	// The timeout of relayed queries.
+	Timeout time.Duration
+}
+
+// Serve queries received from the connection until it's closed.
+func (s *Server) Serve(conn net.PacketConn) error {
+	for {
+		buf := make([]byte, 65535)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		go func(req []byte, addr net.Addr) {
+			var client net.IP
+			if udp, ok := addr.(*net.UDPAddr); ok {
+				client = udp.IP
+			}
+			if resp := s.Handle(req, client); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}(buf[:n], addr)
+	}
+}
+
+// question is the question section of a query.
+type question struct {
+	name  string
+	qtype uint16
+	class uint16
+	end   int // the offset after the question
+}
+
+var errMalformed = errors.New("malformed DNS message")
+
+// Handle the query from the client, returns the response or nil if the
+// query is dropped.
+func (s *Server) Handle(req []byte, client net.IP) []byte {
+	if len(req) < headerLen || req[2]&0x80 != 0 {
+		return nil // not a query
+	}
+
+	opcode := (req[2] >> 3) & 0x0f
+	if opcode != 0 {
+		return reply(req, nil, RcodeNotImplemented)
+	}
+	if binary.BigEndian.Uint16(req[4:]) != 1 {
+		return reply(req, nil, RcodeFormatError)
+	}
+	q, err := parseQuestion(req)
+	if err != nil {
+		return reply(req, nil, RcodeFormatError)
+	}
+
+	zone := strings.ToLower(strings.TrimSuffix(s.Zone, "."))
+	if q.name != zone && !strings.HasSuffix(q.name, "."+zone) {
+		return s.relay(req, q)
+	}
+
+	addrs, ok := s.Resolver.Resolve(q.name, client)
+	if !ok {
+		return reply(req, q, RcodeNameError)
+	}
+	return s.answer(req, q, addrs)
+}
+
+func parseQuestion(msg []byte) (*question, error) {
+	var labels []string
+	off := headerLen
+	for {
+		if off >= len(msg) {
+			return nil, errMalformed
+		}
+		n := int(msg[off])
+		off++
+		if n == 0 {
+			break
+		}
+		if n&0xc0 != 0 || off+n > len(msg) {
+			return nil, errMalformed // compression is not used in questions
+		}
+		labels = append(labels, string(msg[off:off+n]))
+		off += n
+	}
+	if off+4 > len(msg) {
+		return nil, errMalformed
+	}
+	return &question{
+		name:  strings.ToLower(strings.Join(labels, ".")),
+		qtype: binary.BigEndian.Uint16(msg[off:]),
+		class: binary.BigEndian.Uint16(msg[off+2:]),
+		end:   off + 4,
+	}, nil
+}
+
+// reply builds a response with the question and no answers.
+func reply(req []byte, q *question, rcode int) []byte {
+	var resp []byte
+	if q != nil {
+		resp = append(resp, req[:q.end]...)
+		binary.BigEndian.PutUint16(resp[4:], 1)
+	} else {
+		resp = append(resp, req[:headerLen]...)
+		binary.BigEndian.PutUint16(resp[4:], 0)
+	}
+	resp[2] = 0x80 | (req[2] & 0x79) // QR, opcode and RD
+	resp[3] = 0x80 | byte(rcode)     // RA
+	binary.BigEndian.PutUint16(resp[6:], 0)
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+	return resp
+}
+
+// answer builds an authoritative response with addresses of the queried
+// type, truncated if it doesn't fit in a UDP message.
+func (s *Server) answer(req []byte, q *question, addrs []net.IP) []byte {
+	resp := reply(req, q, RcodeSuccess)
+	resp[2] |= 0x04 // AA
+
+	if q.class != ClassIN && q.class != TypeANY {
+		return resp
+	}
+
+	var count uint16
+	for _, ip := range addrs {
+		var rtype uint16
+		var rdata []byte
+		if ip4 := ip.To4(); ip4 != nil {
+			rtype, rdata = TypeA, ip4
+		} else if ip16 := ip.To16(); ip16 != nil {
+			rtype, rdata = TypeAAAA, ip16
+		} else {
+			continue
+		}
+		if q.qtype != rtype && q.qtype != TypeANY {
+			continue
+		}
+
+		rr := make([]byte, 12, 12+len(rdata))
+		binary.BigEndian.PutUint16(rr[0:], 0xc000|headerLen) // pointer to the question name
+		binary.BigEndian.PutUint16(rr[2:], rtype)
+		binary.BigEndian.PutUint16(rr[4:], ClassIN)
+		binary.BigEndian.PutUint32(rr[6:], s.TTL)
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(rdata)))
+		rr = append(rr, rdata...)
+
+		if len(resp)+len(rr) > maxUDPSize {
+			resp[2] |= 0x02 // TC
+			break
+		}
+		resp = append(resp, rr...)
+		count++
+	}
+	binary.BigEndian.PutUint16(resp[6:], count)
+	return resp
+}
+
+// relay the query to upstream servers, returns the first response.
+func (s *Server) relay(req []byte, q *question) []byte {
+	if len(s.Upstream) == 0 {
+		return reply(req, q, RcodeRefused)
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	for _, upstream := range s.Upstream {
+		resp, err := exchange(upstream, req, timeout)
+		if err == nil {
+			return resp
+		}
+		logrus.WithError(err).Debugf("Failed to relay DNS query of %s to %s", q.name, upstream)
+	}
+	return reply(req, q, RcodeServerFailure)
+}
+
+func exchange(addr string, req []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// ignore responses of other queries
+		if n >= headerLen && buf[0] == req[0] && buf[1] == req[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// SystemUpstream returns addresses of name servers configured in the
+// resolver configuration of the system.
+func SystemUpstream() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
@@ -0,0 +1,129 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func query(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, headerLen)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	msg[2] = 0x01 // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], ClassIN)
+	return msg
+}
+
+// answers returns the response code and addresses in the response.
+func answers(t *testing.T, resp []byte) (int, []string) {
+	if len(resp) < headerLen || resp[2]&0x80 == 0 {
+		t.Fatalf("invalid response: %v", resp)
+	}
+	if binary.BigEndian.Uint16(resp[4:]) == 0 {
+		return int(resp[3] & 0x0f), nil
+	}
+	q, err := parseQuestion(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var addrs []string
+	off := q.end
+	for i := 0; i < int(binary.BigEndian.Uint16(resp[6:])); i++ {
+		n := int(binary.BigEndian.Uint16(resp[off+10:]))
+		addrs = append(addrs, net.IP(resp[off+12:off+12+n]).String())
+		off += 12 + n
+	}
+	return int(resp[3] & 0x0f), addrs
+}
+
+func TestResolve(t *testing.T) {
+	s := &Server{
+		Zone: "cloudway.local",
+		TTL:  5,
+		Resolver: ResolverFunc(func(name string, client net.IP) ([]net.IP, bool) {
+			if name == "mysql.demo.cloudway.local" && client.Equal(net.ParseIP("10.0.0.2")) {
+				return []net.IP{net.ParseIP("10.0.0.3"), net.ParseIP("fd00::3")}, true
+			}
+			return nil, false
+		}),
+	}
+	client := net.ParseIP("10.0.0.2")
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		rcode int
+		addrs []string
+	}{
+		{"mysql.demo.cloudway.local", TypeA, RcodeSuccess, []string{"10.0.0.3"}},
+		{"MySQL.Demo.Cloudway.Local", TypeAAAA, RcodeSuccess, []string{"fd00::3"}},
+		{"mysql.demo.cloudway.local", TypeANY, RcodeSuccess, []string{"10.0.0.3", "fd00::3"}},
+		{"redis.demo.cloudway.local", TypeA, RcodeNameError, nil},
+		{"example.com", TypeA, RcodeRefused, nil},
+	}
+	for _, tt := range tests {
+		resp := s.Handle(query(42, tt.name, tt.qtype), client)
+		if id := binary.BigEndian.Uint16(resp); id != 42 {
+			t.Errorf("%s: response id %d, want 42", tt.name, id)
+		}
+		rcode, addrs := answers(t, resp)
+		if rcode != tt.rcode || strings.Join(addrs, ",") != strings.Join(tt.addrs, ",") {
+			t.Errorf("%s: got %d %v, want %d %v", tt.name, rcode, addrs, tt.rcode, tt.addrs)
+		}
+	}
+
+	// names are resolved for the client only
+	if rcode, _ := answers(t, s.Handle(query(1, "mysql.demo.cloudway.local", TypeA), net.ParseIP("10.0.1.2"))); rcode != RcodeNameError {
+		t.Errorf("resolved for another client, rcode %d", rcode)
+	}
+}
+
+func TestMalformedQuery(t *testing.T) {
+	s := &Server{Zone: "cloudway.local", Resolver: ResolverFunc(func(string, net.IP) ([]net.IP, bool) {
+		return nil, false
+	})}
+
+	if resp := s.Handle([]byte{1, 2, 3}, nil); resp != nil {
+		t.Errorf("short message answered: %v", resp)
+	}
+
+	req := query(7, "demo.cloudway.local", TypeA)
+	if rcode, _ := answers(t, s.Handle(req[:len(req)-2], nil)); rcode != RcodeFormatError {
+		t.Errorf("truncated question: rcode %d", rcode)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+
+	go (&Server{
+		Zone: "example.com",
+		TTL:  60,
+		Resolver: ResolverFunc(func(name string, client net.IP) ([]net.IP, bool) {
+			return []net.IP{net.ParseIP("93.184.216.34")}, name == "example.com"
+		}),
+	}).Serve(upstream)
+
+	s := &Server{
+		Zone:     "cloudway.local",
+		Upstream: []string{upstream.LocalAddr().String()},
+		Resolver: ResolverFunc(func(string, net.IP) ([]net.IP, bool) { return nil, false }),
+	}
+	rcode, addrs := answers(t, s.Handle(query(9, "example.com", TypeA), nil))
+	if rcode != RcodeSuccess || len(addrs) != 1 || addrs[0] != "93.184.216.34" {
+		t.Errorf("relayed query: got %d %v", rcode, addrs)
+	}
+}
@@ -0,0 +1,121 @@
+// Package domain validates custom domain names attached to applications
+// and verifies the domain ownership through DNS. A domain is verified if
+// it's a CNAME of the application hostname, or if a TXT record contains
+// the verification token of the application.
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ChallengePrefix is prepended to the domain name to form the name of the
+// TXT record containing the verification token.
+const ChallengePrefix = "_cloudway."
+
+type InvalidDomainError string
+
+func (e InvalidDomainError) Error() string {
+	return fmt.Sprintf("Invalid domain name: %s", string(e))
+}
+
+func (e InvalidDomainError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// VerificationError reports that the domain ownership can't be verified,
+// and describes the DNS records to add.
+type VerificationError struct {
+	Domain string
+	Target string
+	Token  string
+}
+
+func (e VerificationError) Error() string {
+	return fmt.Sprintf("Cannot verify ownership of domain %s. Add a CNAME record pointing to %s, "+
+		"or a TXT record %s%s with the value %s", e.Domain, e.Target, ChallengePrefix, e.Domain, e.Token)
+}
+
+func (e VerificationError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Normalize converts the domain name to lower case without the trailing dot,
+// and validates the syntax of domain name. Subdomains of the platform domain
+// are reserved and rejected.
+func Normalize(name, platformDomain string) (string, error) {
+	host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if host == "" || len(host) > 253 {
+		return "", InvalidDomainError(name)
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", InvalidDomainError(name)
+	}
+	for i, label := range labels {
+		// allow wildcard domain
+		if i == 0 && label == "*" {
+			continue
+		}
+		if !labelPattern.MatchString(label) {
+			return "", InvalidDomainError(name)
+		}
+	}
+
+	if platformDomain != "" && (host == platformDomain || strings.HasSuffix(host, "."+platformDomain)) {
+		return "", InvalidDomainError(name)
+	}
+	return host, nil
+}
+
+// Token returns the verification token of the domain, derived from the
+// application secret so it needn't be stored.
+func Token(secret, host string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(host))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// Resolver looks up DNS records, it's replaced in tests.
+type Resolver struct {
+	LookupCNAME func(host string) (string, error)
+	LookupTXT   func(host string) ([]string, error)
+}
+
+// DefaultResolver looks up DNS records with the system resolver.
+var DefaultResolver = &Resolver{
+	LookupCNAME: net.LookupCNAME,
+	LookupTXT:   net.LookupTXT,
+}
+
+// Verify checks that the domain is a CNAME of the target, or the TXT record
+// of the domain contains the token. The TXT record of the parent domain
+// verifies a wildcard domain.
+func (r *Resolver) Verify(host, target, token string) error {
+	name := strings.TrimPrefix(host, "*.")
+
+	if cname, err := r.LookupCNAME(name); err == nil {
+		if strings.TrimSuffix(strings.ToLower(cname), ".") == target {
+			return nil
+		}
+	}
+
+	if records, err := r.LookupTXT(ChallengePrefix + name); err == nil {
+		for _, txt := range records {
+			if strings.TrimSpace(txt) == token {
+				return nil
+			}
+		}
+	}
+
+	return VerificationError{Domain: host, Target: target, Token: token}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"www.Example.com.", "www.example.com"},
+		{"*.example.com", "*.example.com"},
+		{"example.com", "example.com"},
+		{"localhost", ""},
+		{"foo..example.com", ""},
+		{"-foo.example.com", ""},
+		{"foo.*.example.com", ""},
+		{"foo_bar.example.com", ""},
+		{"app.cloudway.local", ""},
+		{"cloudway.local", ""},
+	}
+
+	for _, tt := range tests {
+		host, err := Normalize(tt.name, "cloudway.local")
+		if tt.want == "" {
+			if err != InvalidDomainError(tt.name) {
+				t.Errorf("%s: got %q, %v, want InvalidDomainError", tt.name, host, err)
+			}
+		} else if err != nil || host != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, host, err, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const target = "app-ns.cloudway.local"
+	token := Token("secret", "www.example.com")
+
+	r := &Resolver{
+		LookupCNAME: func(host string) (string, error) {
+			switch host {
+			case "www.example.com":
+				return "APP-NS.cloudway.local.", nil
+			case "example.org":
+				return "example.org.", nil
+			}
+			return "", errors.New("no such host")
+		},
+		LookupTXT: func(host string) ([]string, error) {
+			if host == "_cloudway.example.org" {
+				return []string{"unrelated", token}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+
+	for _, host := range []string{"www.example.com", "example.org", "*.example.org"} {
+		if err := r.Verify(host, target, token); err != nil {
+			t.Errorf("%s: %v", host, err)
+		}
+	}
+
+	err := r.Verify("example.net", target, token)
+	if err != (VerificationError{"example.net", target, token}) {
+		t.Errorf("example.net: got %v, want VerificationError", err)
+	}
+
+	if Token("secret", "example.net") == token || Token("other", "www.example.com") == token {
+		t.Error("token should depend on secret and domain")
+	}
+}
@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// AppManifestFile is the application manifest in the root of application
+// repository. The manifest is read on each deployment to reconcile the
+// application with the declared plugins, environment and scaling.
+const AppManifestFile = "cloudway.yml"
+
+// Application is the application manifest kept in version control. Services
+// are mapped from the service name to the plugin tag. Declared services are
+// created if missing, but services not declared are never removed.
+type Application struct {
+	Framework string            `yaml:"Framework,omitempty" json:",omitempty"`
+	Services  map[string]string `yaml:"Services,omitempty" json:",omitempty"`
+	Env       map[string]string `yaml:"Env,omitempty" json:",omitempty"`
+	Scaling   int               `yaml:"Scaling,omitempty" json:",omitempty"`
+	Hooks     *Hooks            `yaml:"Hooks,omitempty" json:",omitempty"`
+	Migrate   *Migration        `yaml:"Migrate,omitempty" json:",omitempty"`
+}
+
+// The default maximum time a database migration may run.
+const DefaultMigrationTimeout = 10 * time.Minute
+
+// Migration is a database migration command run exactly once per deployment,
+// in a one-off container with the new repository and the environment of the
+// application. If the rollout is blocked then containers are updated only
+// after the migration succeeded, otherwise the migration runs along with the
+// rollout and the failure is reported without failing the deployment.
+type Migration struct {
+	Command string `yaml:"Command"`
+	Timeout string `yaml:"Timeout,omitempty" json:",omitempty"`
+	Block   bool   `yaml:"Block,omitempty" json:",omitempty"`
+}
+
+// GetTimeout returns the maximum time the migration may run.
+func (m *Migration) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(m.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMigrationTimeout
+}
+
+// InvalidAppManifestError reports a malformed application manifest.
+type InvalidAppManifestError string
+
+func (e InvalidAppManifestError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", AppManifestFile, string(e))
+}
+
+var (
+	serviceNamePattern = regexp.MustCompile("^[a-z][a-z_0-9]*$")
+	envNamePattern     = regexp.MustCompile("^[A-Za-z_][A-Za-z_0-9]*$")
+)
+
+// ReadApplication reads and validates the application manifest.
+func ReadApplication(f io.Reader) (*Application, error) {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &Application{}
+	if err = yaml.Unmarshal(data, app); err != nil {
+		return nil, InvalidAppManifestError(err.Error())
+	}
+	if err = app.Validate(); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// LoadApplication loads the application manifest from the repository
+// directory. Nil is returned if the repository has no manifest.
+func LoadApplication(dir string) (*Application, error) {
+	f, err := os.Open(filepath.Join(dir, AppManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadApplication(f)
+}
+
+func (app *Application) Validate() error {
+	if app.Scaling < 0 {
+		return InvalidAppManifestError(fmt.Sprintf("negative scaling %d", app.Scaling))
+	}
+	for name, tag := range app.Services {
+		if !serviceNamePattern.MatchString(name) {
+			return InvalidAppManifestError(fmt.Sprintf("invalid service name '%s'", name))
+		}
+		if tag == "" {
+			return InvalidAppManifestError(fmt.Sprintf("no plugin specified for service '%s'", name))
+		}
+	}
+	for name := range app.Env {
+		if !envNamePattern.MatchString(name) {
+			return InvalidAppManifestError(fmt.Sprintf("invalid environment variable name '%s'", name))
+		}
+		if strings.HasPrefix(name, "CLOUDWAY_") {
+			return InvalidAppManifestError(fmt.Sprintf("reserved environment variable name '%s'", name))
+		}
+	}
+	if err := app.Hooks.Validate(); err != nil {
+		return InvalidAppManifestError(err.Error())
+	}
+	if m := app.Migrate; m != nil {
+		if m.Command == "" {
+			return InvalidAppManifestError("no migration command specified")
+		}
+		if m.Timeout != "" {
+			if d, err := time.ParseDuration(m.Timeout); err != nil || d <= 0 {
+				return InvalidAppManifestError(fmt.Sprintf("invalid migration timeout '%s'", m.Timeout))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadApplication(t *testing.T) {
+	app, err := ReadApplication(strings.NewReader(`
+Framework: php:5.6
+Services:
+  db: mysql:5.5
+Env:
+  APP_ENV: production
+Scaling: 2
+Hooks:
+  Pre-Build: composer install
+  Post-Deploy:
+    Command: php artisan cache:clear
+    On-Failure: warn
+Migrate:
+  Command: php artisan migrate
+  Timeout: 5m
+  Block: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Framework != "php:5.6" || app.Services["db"] != "mysql:5.5" || app.Env["APP_ENV"] != "production" || app.Scaling != 2 {
+		t.Fatalf("unexpected manifest: %+v", app)
+	}
+	if h := app.Hooks.Get(PreBuild); h == nil || h.Command != "composer install" || !h.Abort() {
+		t.Fatalf("unexpected pre_build hook: %+v", h)
+	}
+	if h := app.Hooks.Get(PostDeploy); h == nil || h.Command != "php artisan cache:clear" || h.Abort() {
+		t.Fatalf("unexpected post_deploy hook: %+v", h)
+	}
+	if h := app.Hooks.Get(PreDeploy); h != nil {
+		t.Fatalf("unexpected pre_deploy hook: %+v", h)
+	}
+	if m := app.Migrate; m == nil || m.Command != "php artisan migrate" || m.GetTimeout() != 5*time.Minute || !m.Block {
+		t.Fatalf("unexpected migration: %+v", m)
+	}
+	if d := (&Migration{}).GetTimeout(); d != DefaultMigrationTimeout {
+		t.Fatalf("expected default migration timeout, got %s", d)
+	}
+}
+
+func TestReadInvalidApplication(t *testing.T) {
+	tests := []string{
+		"Scaling: -1",
+		"Services:\n  DB: mysql",
+		"Services:\n  db: ''",
+		"Env:\n  '1ENV': x",
+		"Env:\n  CLOUDWAY_DOMAIN: x",
+		"Framework: [php]",
+		"Hooks:\n  Pre-Build: ''",
+		"Hooks:\n  Pre-Build:\n    Command: make\n    On-Failure: ignore",
+		"Migrate:\n  Timeout: 5m",
+		"Migrate:\n  Command: migrate\n  Timeout: soon",
+	}
+	for _, test := range tests {
+		_, err := ReadApplication(strings.NewReader(test))
+		if _, ok := err.(InvalidAppManifestError); !ok {
+			t.Errorf("%q: expected invalid manifest error, got %v", test, err)
+		}
+	}
+}
+
+func TestLoadApplication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app, err := LoadApplication(dir)
+	if app != nil || err != nil {
+		t.Fatalf("expected no manifest, got %v, %v", app, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, AppManifestFile), []byte("Scaling: 3\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err = LoadApplication(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Scaling != 3 {
+		t.Fatalf("expected scaling 3, got %d", app.Scaling)
+	}
+}
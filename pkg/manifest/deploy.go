@@ -0,0 +1,143 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Deploy modes of changed files.
+const (
+	DeployHot   = "hot"
+	DeployBuild = "build"
+)
+
+// DeployRule decides how a changed file matching the path pattern is
+// deployed, either hot deployed or deployed with a full build. A pattern
+// without slash matches the base name of files, such as "*.css". A pattern
+// ending with "/**" matches all files under the directory, and other
+// patterns match the path relative to the repository root.
+type DeployRule struct {
+	Path   string `yaml:"Path"`
+	Deploy string `yaml:"Deploy"`
+}
+
+// Match returns true if the slash separated path matches the rule.
+func (r *DeployRule) Match(name string) bool {
+	if dir := strings.TrimSuffix(r.Path, "/**"); dir != r.Path {
+		return strings.HasPrefix(name, dir+"/")
+	}
+	if !strings.Contains(r.Path, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(r.Path, name)
+	return ok
+}
+
+// ValidateDeployRules returns an error if any deploy rule is malformed.
+func ValidateDeployRules(rules []*DeployRule) error {
+	for _, r := range rules {
+		if r.Path == "" {
+			return fmt.Errorf("no path specified for deploy rule")
+		}
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return fmt.Errorf("invalid path pattern '%s' for deploy rule", r.Path)
+		}
+		if r.Deploy != DeployHot && r.Deploy != DeployBuild {
+			return fmt.Errorf("invalid deploy mode '%s' for path '%s', must be '%s' or '%s'", r.Deploy, r.Path, DeployHot, DeployBuild)
+		}
+	}
+	return nil
+}
+
+// DeployMode returns the deploy mode of the changed file decided by the
+// first matching rule, or the default mode if no rule matches the file.
+func DeployMode(rules []*DeployRule, name, def string) string {
+	for _, r := range rules {
+		if r.Match(name) {
+			return r.Deploy
+		}
+	}
+	return def
+}
+
+// Methods to notify a container that a new repository is deployed.
+const (
+	NotifySignal = "signal"
+	NotifyExec   = "exec"
+	NotifyHTTP   = "http"
+)
+
+// DefaultDeploySignal is the signal sent to containers to complete the
+// deployment if the plugin declares no notification.
+const DefaultDeploySignal = "SIGHUP"
+
+// DeploySignals are the signals a container can be notified with. Other
+// signals are reserved by the sandbox to stop the container or update the
+// application state.
+var DeploySignals = []string{"SIGHUP", "SIGUSR2", "SIGWINCH"}
+
+// DeployNotify describes how a container is notified to complete the
+// deployment after the repository is copied into it. A signal notification
+// sends the signal to the container, which checks out the repository and
+// restarts the application. An exec notification runs the command in the
+// container, and an HTTP notification posts to the path on the port of the
+// container. The command or endpoint is responsible to complete the
+// deployment, typically by running "cwctl restart".
+type DeployNotify struct {
+	Type    string `yaml:"Type"`
+	Signal  string `yaml:"Signal,omitempty" json:",omitempty"`
+	Command string `yaml:"Command,omitempty" json:",omitempty"`
+	Port    int32  `yaml:"Port,omitempty" json:",omitempty"`
+	Path    string `yaml:"Path,omitempty" json:",omitempty"`
+	Timeout string `yaml:"Timeout,omitempty" json:",omitempty"`
+}
+
+// DeploySignal returns the canonical name of the signal of a signal
+// notification, such as "SIGHUP" for "hup". Returns the default signal
+// if the notification is nil or no signal is specified.
+func (n *DeployNotify) DeploySignal() string {
+	if n == nil || n.Signal == "" {
+		return DefaultDeploySignal
+	}
+	sig := strings.ToUpper(n.Signal)
+	if !strings.HasPrefix(sig, "SIG") {
+		sig = "SIG" + sig
+	}
+	return sig
+}
+
+// ValidateDeployNotify returns an error if the deploy notification is
+// malformed.
+func ValidateDeployNotify(n *DeployNotify) error {
+	switch n.Type {
+	case NotifySignal:
+		sig := n.DeploySignal()
+		valid := false
+		for _, s := range DeploySignals {
+			if s == sig {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unsupported deploy signal '%s', must be one of %s", n.Signal, strings.Join(DeploySignals, ", "))
+		}
+	case NotifyExec:
+		if n.Command == "" {
+			return fmt.Errorf("exec deploy notification requires a command")
+		}
+	case NotifyHTTP:
+		if n.Port <= 0 {
+			return fmt.Errorf("http deploy notification requires a port")
+		}
+	default:
+		return fmt.Errorf("unsupported deploy notification type '%s'", n.Type)
+	}
+	if n.Timeout != "" {
+		if d, err := time.ParseDuration(n.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid deploy notification timeout '%s'", n.Timeout)
+		}
+	}
+	return nil
+}
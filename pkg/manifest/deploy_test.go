@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeployRules(t *testing.T) {
+	plugin, err := Read(strings.NewReader(`
+Name: nodejs
+Deploy-Rules:
+  - Path: docs/**
+    Deploy: hot
+  - Path: package.json
+    Deploy: build
+  - Path: assets/*.scss
+    Deploy: build
+  - Path: "*.js"
+    Deploy: hot
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := plugin.DeployRules
+	if err = ValidateDeployRules(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, def, mode string
+	}{
+		{"package.json", DeployHot, DeployBuild},
+		{"lib/package.json", DeployHot, DeployBuild},
+		{"docs/package.json", DeployHot, DeployHot},
+		{"assets/site.scss", DeployHot, DeployBuild},
+		{"assets/css/site.scss", DeployHot, DeployHot},
+		{"lib/index.js", DeployBuild, DeployHot},
+		{"README.md", DeployBuild, DeployBuild},
+		{"docs", DeployHot, DeployHot},
+	}
+	for _, tt := range tests {
+		if mode := DeployMode(rules, tt.name, tt.def); mode != tt.mode {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.mode, mode)
+		}
+	}
+}
+
+func TestInvalidDeployRules(t *testing.T) {
+	for _, r := range []*DeployRule{
+		{Deploy: DeployHot},
+		{Path: "[a-", Deploy: DeployBuild},
+		{Path: "*.js", Deploy: "later"},
+		{Path: "*.js"},
+	} {
+		if err := ValidateDeployRules([]*DeployRule{r}); err == nil {
+			t.Errorf("expected invalid deploy rule: %+v", r)
+		}
+	}
+}
+
+func TestDeployNotify(t *testing.T) {
+	plugin, err := Read(strings.NewReader(`
+Name: java
+Deploy-Notify:
+  Type: signal
+  Signal: usr2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := plugin.DeployNotify
+	if err = ValidateDeployNotify(n); err != nil {
+		t.Fatal(err)
+	}
+	if sig := n.DeploySignal(); sig != "SIGUSR2" {
+		t.Errorf("expected SIGUSR2, got %s", sig)
+	}
+
+	var none *DeployNotify
+	if sig := none.DeploySignal(); sig != DefaultDeploySignal {
+		t.Errorf("expected %s, got %s", DefaultDeploySignal, sig)
+	}
+}
+
+func TestInvalidDeployNotify(t *testing.T) {
+	for _, n := range []*DeployNotify{
+		{},
+		{Type: "webhook"},
+		{Type: NotifySignal, Signal: "SIGTERM"},
+		{Type: NotifyExec},
+		{Type: NotifyHTTP, Path: "/deploy"},
+		{Type: NotifyExec, Command: "reload.sh", Timeout: "soon"},
+	} {
+		if err := ValidateDeployNotify(n); err == nil {
+			t.Errorf("expected invalid deploy notification: %+v", n)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package manifest
+
+import "fmt"
+
+// Hook names in the order run by the build and deploy pipeline. The build
+// hooks run in the builder container around the framework build, and are
+// skipped for hot deployable frameworks which are never built. The deploy
+// hooks run in every application container around the framework deploy
+// action, after the new repository is checked out.
+const (
+	PreBuild   = "pre_build"
+	PostBuild  = "post_build"
+	PreDeploy  = "pre_deploy"
+	PostDeploy = "post_deploy"
+)
+
+// Failure policies of hooks.
+const (
+	HookAbort = "abort"
+	HookWarn  = "warn"
+)
+
+// Hook is a shell command run at a stage of the build and deploy pipeline.
+// A failed hook aborts the build or deployment, unless the failure policy
+// is "warn". A hook without failure policy can be written as the command.
+type Hook struct {
+	Command   string `yaml:"Command"`
+	OnFailure string `yaml:"On-Failure,omitempty" json:",omitempty"`
+}
+
+func (h *Hook) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&h.Command); err == nil {
+		return nil
+	}
+	type plain Hook
+	return unmarshal((*plain)(h))
+}
+
+// Abort returns true if the build or deployment is aborted when the hook
+// failed.
+func (h *Hook) Abort() bool {
+	return h.OnFailure != HookWarn
+}
+
+// Hooks are declared by plugins and the application manifest. Hooks of
+// plugins run before the hook of the application.
+type Hooks struct {
+	PreBuild   *Hook `yaml:"Pre-Build,omitempty" json:",omitempty"`
+	PostBuild  *Hook `yaml:"Post-Build,omitempty" json:",omitempty"`
+	PreDeploy  *Hook `yaml:"Pre-Deploy,omitempty" json:",omitempty"`
+	PostDeploy *Hook `yaml:"Post-Deploy,omitempty" json:",omitempty"`
+}
+
+// Get returns the hook of the given name, or nil if the hook is not declared.
+func (hooks *Hooks) Get(name string) *Hook {
+	if hooks == nil {
+		return nil
+	}
+	switch name {
+	case PreBuild:
+		return hooks.PreBuild
+	case PostBuild:
+		return hooks.PostBuild
+	case PreDeploy:
+		return hooks.PreDeploy
+	case PostDeploy:
+		return hooks.PostDeploy
+	default:
+		return nil
+	}
+}
+
+func (hooks *Hooks) Validate() error {
+	for _, name := range []string{PreBuild, PostBuild, PreDeploy, PostDeploy} {
+		h := hooks.Get(name)
+		if h == nil {
+			continue
+		}
+		if h.Command == "" {
+			return fmt.Errorf("no command specified for hook '%s'", name)
+		}
+		if h.OnFailure != "" && h.OnFailure != HookAbort && h.OnFailure != HookWarn {
+			return fmt.Errorf("invalid failure policy '%s' for hook '%s', must be '%s' or '%s'", h.OnFailure, name, HookAbort, HookWarn)
+		}
+	}
+	return nil
+}
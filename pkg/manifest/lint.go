@@ -0,0 +1,251 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Severities of plugin diagnostics. A plugin with error diagnostics is
+// rejected on installation, warnings are informational.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a plugin. The field is the manifest
+// field or the plugin file where the problem is found.
+type Diagnostic struct {
+	Severity string
+	Field    string `json:",omitempty"`
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Field == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Field, d.Message)
+}
+
+type Diagnostics []Diagnostic
+
+func (diags *Diagnostics) Errorf(field, format string, args ...interface{}) {
+	*diags = append(*diags, Diagnostic{SeverityError, field, fmt.Sprintf(format, args...)})
+}
+
+func (diags *Diagnostics) Warnf(field, format string, args ...interface{}) {
+	*diags = append(*diags, Diagnostic{SeverityWarning, field, fmt.Sprintf(format, args...)})
+}
+
+// HasErrors returns true if any diagnostic is an error.
+func (diags Diagnostics) HasErrors() bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ReservedNames can't be used as plugin names, as the environment variables
+// of plugin endpoints, named CLOUDWAY_<NAME>_*, would collide with the
+// environment variables of application containers.
+var ReservedNames = []string{
+	"app", "data", "domain", "extra", "framework", "home",
+	"log", "plugin", "repo", "service", "shared",
+}
+
+var (
+	pluginNamePattern    = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+	pluginVersionPattern = regexp.MustCompile(`^[0-9][0-9.]*$`)
+)
+
+// Lint checks the plugin in the directory for malformed manifest, missing
+// scripts and other problems that can be found without a Docker host. The
+// parsed manifest is returned unless the manifest can't be read.
+func Lint(dir string) (*Plugin, Diagnostics) {
+	var diags Diagnostics
+
+	data, err := ioutil.ReadFile(manifestFile(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			diags.Errorf(ManifestEntry, "plugin manifest not found")
+		} else {
+			diags.Errorf(ManifestEntry, "%v", err)
+		}
+		return nil, diags
+	}
+
+	plugin := &Plugin{}
+	if err = yaml.Unmarshal(data, plugin); err != nil {
+		diags.Errorf(ManifestEntry, "%v", err)
+		return nil, diags
+	}
+	if err = yaml.UnmarshalStrict(data, &Plugin{}); err != nil {
+		diags.Warnf(ManifestEntry, "%v", err)
+	}
+	plugin.Path = dir
+
+	plugin.lint(&diags)
+	lintScripts(dir, plugin, &diags)
+	return plugin, diags
+}
+
+func (p *Plugin) lint(diags *Diagnostics) {
+	switch {
+	case p.Name == "":
+		diags.Errorf("Name", "plugin name is required")
+	case !pluginNamePattern.MatchString(p.Name):
+		diags.Errorf("Name", "malformed plugin name '%s', only letters, digits and underscores are allowed", p.Name)
+	default:
+		for _, name := range ReservedNames {
+			if strings.EqualFold(p.Name, name) {
+				diags.Errorf("Name", "'%s' is a reserved name", p.Name)
+			}
+		}
+	}
+
+	switch {
+	case p.Version == "":
+		diags.Errorf("Version", "plugin version is required")
+	case !pluginVersionPattern.MatchString(p.Version):
+		diags.Errorf("Version", "malformed plugin version '%s'", p.Version)
+	}
+
+	switch p.Category {
+	case Framework, Service, Library:
+	case "":
+		diags.Errorf("Category", "plugin category is required")
+	default:
+		diags.Errorf("Category", "unknown category '%s', must be one of %s, %s or %s", p.Category, Framework, Service, Library)
+	}
+
+	if p.DisplayName == "" {
+		diags.Warnf("Display-Name", "display name is missing")
+	}
+	if p.Vendor == "" {
+		diags.Warnf("Vendor", "vendor is missing")
+	}
+
+	hasDockerfile := p.Path != "" && HasDockerfile(p.Path)
+	if p.BaseImage == "" && !hasDockerfile {
+		diags.Errorf("Base-Image", "base image is required if the plugin has no %s", DockerfileEntry)
+	}
+	if !hasDockerfile && (p.BuildTarget != "" || len(p.BuildArgs) != 0) {
+		diags.Warnf("Build-Target", "build options are ignored if the plugin has no %s", DockerfileEntry)
+	}
+	if p.ImageDigest != "" {
+		diags.Warnf("Image-Digest", "image digest is set on installation and will be replaced")
+	}
+
+	for _, req := range p.Requires {
+		name := req[strings.LastIndexAny(req, "=/")+1:]
+		if i := strings.IndexRune(name, ':'); i != -1 {
+			name = name[:i]
+		}
+		if !pluginNamePattern.MatchString(name) {
+			diags.Errorf("Requires", "malformed plugin tag '%s'", req)
+		} else if name == p.Name {
+			diags.Errorf("Requires", "plugin cannot require itself")
+		}
+	}
+
+	lintEndpoints(p.Endpoints, diags)
+
+	for i, job := range p.Cron {
+		field := fmt.Sprintf("Cron[%d]", i)
+		if job.Name == "" {
+			diags.Errorf(field, "cron job name is required")
+		}
+		if job.Schedule == "" {
+			diags.Errorf(field, "cron job schedule is required")
+		}
+		if job.Command == "" {
+			diags.Errorf(field, "cron job command is required")
+		}
+	}
+
+	if p.Hooks != nil {
+		if err := p.Hooks.Validate(); err != nil {
+			diags.Errorf("Hooks", "%v", err)
+		}
+	}
+	if err := ValidateDeployRules(p.DeployRules); err != nil {
+		diags.Errorf("Deploy-Rules", "%v", err)
+	}
+	if p.DeployNotify != nil {
+		if err := ValidateDeployNotify(p.DeployNotify); err != nil {
+			diags.Errorf("Deploy-Notify", "%v", err)
+		}
+	}
+}
+
+func lintEndpoints(endpoints []*Endpoint, diags *Diagnostics) {
+	ports := make(map[string]int32)
+
+	for i, ep := range endpoints {
+		field := fmt.Sprintf("Endpoints[%d]", i)
+		if !envNamePattern.MatchString(ep.PrivateHostName) {
+			diags.Errorf(field, "malformed private host name '%s'", ep.PrivateHostName)
+		}
+		if !envNamePattern.MatchString(ep.PrivatePortName) {
+			diags.Errorf(field, "malformed private port name '%s'", ep.PrivatePortName)
+		}
+		if ep.PrivatePort <= 0 || ep.PrivatePort > 65535 {
+			diags.Errorf(field, "invalid private port %d", ep.PrivatePort)
+		}
+
+		name := strings.ToUpper(ep.PrivatePortName)
+		if port, ok := ports[name]; ok && port != ep.PrivatePort {
+			diags.Errorf(field, "private port name '%s' is declared with different ports", ep.PrivatePortName)
+		}
+		ports[name] = ep.PrivatePort
+
+		for j, m := range ep.ProxyMappings {
+			field := fmt.Sprintf("%s.Proxy-Mappings[%d]", field, j)
+			if strings.ContainsAny(m.Frontend, " \t") {
+				diags.Errorf(field, "malformed frontend '%s'", m.Frontend)
+			}
+			if strings.ContainsAny(m.Backend, " \t") {
+				diags.Errorf(field, "malformed backend '%s'", m.Backend)
+			}
+			for _, prot := range m.Protocols {
+				if prot != "http" {
+					diags.Warnf(field, "protocol '%s' is not supported by the proxy", prot)
+				}
+			}
+		}
+	}
+}
+
+// lintScripts checks scripts in the bin directory of the plugin, which are
+// run by the sandbox to install and control the plugin.
+func lintScripts(dir string, p *Plugin, diags *Diagnostics) {
+	bin := filepath.Join(dir, "bin")
+	if p.IsFramework() || p.IsService() {
+		if _, err := os.Stat(filepath.Join(bin, "control")); os.IsNotExist(err) {
+			diags.Errorf("bin/control", "control script is required to start and stop %s plugin", strings.ToLower(string(p.Category)))
+		}
+	}
+
+	scripts, err := ioutil.ReadDir(bin)
+	if err != nil {
+		return
+	}
+	for _, fi := range scripts {
+		// the install script of framework and service plugins is run by
+		// shell when building the image
+		if fi.Name() == "install" && !p.IsLibrary() {
+			continue
+		}
+		if fi.Mode().IsRegular() && fi.Mode()&0111 == 0 {
+			diags.Errorf("bin/"+fi.Name(), "script is not executable")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePlugin(t *testing.T, manifest string, scripts map[string]os.FileMode) string {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "manifest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(manifestFile(dir), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 0 {
+		os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	}
+	for name, mode := range scripts {
+		if err = ioutil.WriteFile(filepath.Join(dir, "bin", name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLintValidPlugin(t *testing.T) {
+	dir := writePlugin(t, `
+Name: mock
+Display-Name: Mock 1.0
+Version: '1.0'
+Vendor: cloudway
+Category: Framework
+Base-Image: debian:jessie
+Endpoints:
+- Private-Host-Name: HOST
+  Private-Port-Name: PORT
+  Private-Port: 8080
+  Proxy-Mappings:
+  - Frontend: /
+    Backend: /
+`, map[string]os.FileMode{"control": 0755, "install": 0644})
+	defer os.RemoveAll(dir)
+
+	plugin, diags := Lint(dir)
+	if plugin == nil || plugin.Name != "mock" {
+		t.Fatalf("Lint() returned plugin %v", plugin)
+	}
+	if len(diags) != 0 {
+		t.Errorf("Lint() = %v; want no diagnostics", diags)
+	}
+}
+
+func TestLintInvalidPlugin(t *testing.T) {
+	dir := writePlugin(t, `
+Name: app
+Version: latest
+Category: Database
+Unknown-Field: 1
+Requires: [app]
+Endpoints:
+- Private-Host-Name: HOST
+  Private-Port-Name: 1PORT
+  Private-Port: 70000
+`, map[string]os.FileMode{"setup": 0644})
+	defer os.RemoveAll(dir)
+
+	_, diags := Lint(dir)
+	if !diags.HasErrors() {
+		t.Fatalf("Lint() = %v; want errors", diags)
+	}
+
+	want := map[string]string{
+		ManifestEntry:  SeverityWarning,
+		"Name":         SeverityError,
+		"Version":      SeverityError,
+		"Category":     SeverityError,
+		"Display-Name": SeverityWarning,
+		"Vendor":       SeverityWarning,
+		"Base-Image":   SeverityError,
+		"Requires":     SeverityError,
+		"Endpoints[0]": SeverityError,
+		"bin/setup":    SeverityError,
+	}
+	found := make(map[string]string)
+	for _, d := range diags {
+		found[d.Field] = d.Severity
+	}
+	for field, severity := range want {
+		if found[field] != severity {
+			t.Errorf("%s: got %q diagnostic, want %q", field, found[field], severity)
+		}
+	}
+}
+
+func TestLintMalformedManifest(t *testing.T) {
+	dir := writePlugin(t, "Name: [mock", nil)
+	defer os.RemoveAll(dir)
+
+	plugin, diags := Lint(dir)
+	if plugin != nil || !diags.HasErrors() {
+		t.Errorf("Lint() = %v, %v; want error", plugin, diags)
+	}
+
+	_, diags = Lint(filepath.Join(dir, "missing"))
+	if len(diags) != 1 || diags[0].Field != ManifestEntry {
+		t.Errorf("Lint() of missing plugin = %v", diags)
+	}
+}
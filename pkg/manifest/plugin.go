@@ -36,21 +36,32 @@ func (cat Category) IsLibrary() bool {
 }
 
 type Plugin struct {
-	Path        string      `yaml:"-" json:",omitempty"`
-	Tag         string      `yaml:"-" json:",omitempty"`
-	Name        string      `yaml:"Name"`
-	DisplayName string      `yaml:"Display-Name"`
-	Description string      `yaml:"Description,omitempty"`
-	Version     string      `yaml:"Version"`
-	Vendor      string      `yaml:"Vendor"`
-	Shared      bool        `yaml:"Shared,omitempty" json:",omitempty"`
-	Logo        string      `yaml:"Logo,omitempty" json:",omitempty"`
-	Category    Category    `yaml:"Category"`
-	BaseImage   string      `yaml:"Base-Image"`
-	BuildCache  []string    `yaml:"Build-Cache" json:",omitempty"`
-	DependsOn   []string    `yaml:"Depends-On,omitempty" json:",omitempty"`
-	User        string      `yaml:"User,omitempty" json:",omitempty"`
-	Endpoints   []*Endpoint `yaml:"Endpoints,omitempty" json:",omitempty"`
+	Path         string            `yaml:"-" json:",omitempty"`
+	Tag          string            `yaml:"-" json:",omitempty"`
+	Name         string            `yaml:"Name"`
+	DisplayName  string            `yaml:"Display-Name"`
+	Description  string            `yaml:"Description,omitempty"`
+	Version      string            `yaml:"Version"`
+	Vendor       string            `yaml:"Vendor"`
+	Shared       bool              `yaml:"Shared,omitempty" json:",omitempty"`
+	Logo         string            `yaml:"Logo,omitempty" json:",omitempty"`
+	Category     Category          `yaml:"Category"`
+	BaseImage    string            `yaml:"Base-Image"`
+	BuildArgs    map[string]string `yaml:"Build-Args,omitempty" json:",omitempty"`
+	BuildTarget  string            `yaml:"Build-Target,omitempty" json:",omitempty"`
+	ImageDigest  string            `yaml:"Image-Digest,omitempty" json:",omitempty"`
+	BuildCache   []string          `yaml:"Build-Cache" json:",omitempty"`
+	DependsOn    []string          `yaml:"Depends-On,omitempty" json:",omitempty"`
+	Requires     []string          `yaml:"Requires,omitempty" json:",omitempty"`
+	User         string            `yaml:"User,omitempty" json:",omitempty"`
+	Endpoints    []*Endpoint       `yaml:"Endpoints,omitempty" json:",omitempty"`
+	Volumes      []*Volume         `yaml:"Volumes,omitempty" json:",omitempty"`
+	Cron         []*CronJob        `yaml:"Cron,omitempty" json:",omitempty"`
+	HealthCheck  *HealthCheck      `yaml:"Health-Check,omitempty" json:",omitempty"`
+	Resources    *Resources        `yaml:"Resources,omitempty" json:",omitempty"`
+	Hooks        *Hooks            `yaml:"Hooks,omitempty" json:",omitempty"`
+	DeployRules  []*DeployRule     `yaml:"Deploy-Rules,omitempty" json:",omitempty"`
+	DeployNotify *DeployNotify     `yaml:"Deploy-Notify,omitempty" json:",omitempty"`
 }
 
 type Endpoint struct {
@@ -63,6 +74,45 @@ type Endpoint struct {
 	ProxyMappings   []*ProxyMapping `yaml:"Proxy-Mappings,omitempty" json:",omitempty"`
 }
 
+type Volume struct {
+	Name         string `yaml:"Name"`
+	MountPath    string `yaml:"Mount-Path"`
+	Size         string `yaml:"Size,omitempty" json:",omitempty"`
+	StorageClass string `yaml:"Storage-Class,omitempty" json:",omitempty"`
+}
+
+// CronJob is a command executed in the plugin container on schedule. The
+// schedule is a standard five-field cron expression, or one of @hourly,
+// @daily, @weekly, @monthly and @yearly.
+type CronJob struct {
+	Name     string `yaml:"Name"`
+	Schedule string `yaml:"Schedule"`
+	Command  string `yaml:"Command"`
+}
+
+// HealthCheck describes how to probe the health of a plugin container.
+// The type is one of "http", "tcp" and "exec". An HTTP check succeeds if
+// the response status is 2xx or 3xx, a TCP check succeeds if the port
+// accepts connection, and an exec check succeeds if the command exits
+// with zero status.
+type HealthCheck struct {
+	Type     string `yaml:"Type"`
+	Port     int32  `yaml:"Port,omitempty" json:",omitempty"`
+	Path     string `yaml:"Path,omitempty" json:",omitempty"`
+	Command  string `yaml:"Command,omitempty" json:",omitempty"`
+	Interval string `yaml:"Interval,omitempty" json:",omitempty"`
+	Timeout  string `yaml:"Timeout,omitempty" json:",omitempty"`
+	Retries  int    `yaml:"Retries,omitempty" json:",omitempty"`
+}
+
+// Resources limits the resources of a plugin container. The memory is a
+// size such as "512m", and the CPUs is the number of CPUs such as 0.5.
+type Resources struct {
+	Memory    string  `yaml:"Memory,omitempty" json:",omitempty"`
+	CPUs      float64 `yaml:"CPUs,omitempty" json:",omitempty"`
+	PidsLimit int64   `yaml:"Pids-Limit,omitempty" json:",omitempty"`
+}
+
 type ProxyMapping struct {
 	Frontend  string   `yaml:"Frontend"`
 	Backend   string   `yaml:"Backend"`
@@ -72,10 +122,21 @@ type ProxyMapping struct {
 
 const ManifestEntry = "manifest/plugin.yml"
 
+// DockerfileEntry is the Dockerfile shipped with a plugin to build the base
+// image of plugin containers, which is used instead of the Base-Image.
+const DockerfileEntry = "Dockerfile"
+
 func manifestFile(dir string) string {
 	return filepath.Join(dir, filepath.FromSlash(ManifestEntry))
 }
 
+// HasDockerfile returns true if the plugin in the directory ships with a
+// Dockerfile.
+func HasDockerfile(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, DockerfileEntry))
+	return err == nil && fi.Mode().IsRegular()
+}
+
 func IsPluginDir(dir string) bool {
 	if strings.HasPrefix(filepath.Base(dir), ".") {
 		return false
@@ -101,6 +162,19 @@ func Load(path string) (*Plugin, error) {
 	return plugin, nil
 }
 
+// Save writes the manifest of the plugin in the directory.
+func Save(path string, plugin *Plugin) error {
+	data, err := yaml.Marshal(plugin)
+	if err != nil {
+		return err
+	}
+	filename := manifestFile(path)
+	if err = ioutil.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
 func Read(f io.Reader) (*Plugin, error) {
 	data, err := ioutil.ReadAll(f)
 	if err != nil {
@@ -19,6 +19,8 @@ const (
 	StateBuilding
 	StateFailed
 	StateUnknown
+	StateUnhealthy
+	StateCrashLoop
 )
 
 var stateString = [...]string{
@@ -31,6 +33,8 @@ var stateString = [...]string{
 	StateBuilding:   "building",
 	StateFailed:     "failed",
 	StateUnknown:    "unknown",
+	StateUnhealthy:  "unhealthy",
+	StateCrashLoop:  "crashloop",
 }
 
 func (s ActiveState) String() string {
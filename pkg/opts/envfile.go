@@ -0,0 +1,96 @@
+package opts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseEnvFile reads a file with environment variables in dotenv format.
+//
+// Blank lines and lines beginning with '#' are ignored. Each remaining line
+// must have the form KEY=VALUE, optionally preceded by 'export'. A value may
+// be enclosed in single quotes, taken literally, or in double quotes, where
+// the usual backslash escapes are interpreted.
+func ParseEnvFile(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseEnv(f, filename)
+}
+
+// ParseEnv reads environment variables in dotenv format from the reader.
+// The name is used to report errors.
+func ParseEnv(r io.Reader, name string) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+
+		i := strings.IndexRune(line, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid environment variable: %s", name, lineno, line)
+		}
+		key := strings.TrimSpace(line[:i])
+		if strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: invalid environment variable name: %s", name, lineno, key)
+		}
+
+		val, err := parseEnvValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineno, err)
+		}
+		env[key] = val
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func parseEnvValue(val string) (string, error) {
+	if val == "" {
+		return val, nil
+	}
+
+	switch val[0] {
+	case '\'':
+		end := strings.IndexRune(val[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value: %s", val)
+		}
+		return val[1 : end+1], nil
+
+	case '"':
+		for end := 1; end < len(val); end++ {
+			if val[end] == '\\' {
+				end++
+			} else if val[end] == '"' {
+				return strconv.Unquote(val[:end+1])
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value: %s", val)
+
+	default:
+		// strip trailing comment from unquoted value
+		if i := strings.Index(val, " #"); i >= 0 {
+			val = strings.TrimSpace(val[:i])
+		}
+		return val, nil
+	}
+}
@@ -0,0 +1,51 @@
+package opts
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	content := `
+# comment line
+FOO=bar
+export BAZ = qux
+EMPTY=
+SINGLE='a "literal" \n value'
+DOUBLE="line1\nline2 \"quoted\""
+TRAILING=value # comment
+`
+	expected := map[string]string{
+		"FOO":      "bar",
+		"BAZ":      "qux",
+		"EMPTY":    "",
+		"SINGLE":   `a "literal" \n value`,
+		"DOUBLE":   "line1\nline2 \"quoted\"",
+		"TRAILING": "value",
+	}
+
+	env, err := ParseEnv(strings.NewReader(content), "test.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected %v, got %v", expected, env)
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	tests := []string{
+		"NOVALUE",
+		"=value",
+		"BAD KEY=value",
+		"UNTERMINATED='value",
+		`UNTERMINATED="value`,
+	}
+
+	for _, content := range tests {
+		if _, err := ParseEnv(strings.NewReader(content), "test.env"); err == nil {
+			t.Errorf("%q: expected error", content)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package ratelimit limits the rate of events keyed by such as client
+// addresses.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// The interval to remove idle buckets.
+const sweepInterval = time.Minute
+
+// TokenBucket limits the rate of events for each key with a token bucket,
+// which allows bursts up to the bucket capacity and refills at a steady
+// rate.
+type TokenBucket struct {
+	mu        sync.Mutex
+	period    time.Duration
+	rate      float64 // tokens per second
+	capacity  float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a token bucket limiter allows the number of events
+// in the period, with bursts up to the same number. A non-positive limit
+// means unlimited.
+func NewTokenBucket(limit int, period time.Duration) *TokenBucket {
+	tb := &TokenBucket{
+		period:  period,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	tb.SetLimit(limit)
+	return tb
+}
+
+// Limit returns the maximum number of events in a burst.
+func (tb *TokenBucket) Limit() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return int(tb.capacity)
+}
+
+// SetLimit changes the number of events allowed in the period. Existing
+// buckets keep their tokens up to the new capacity.
+func (tb *TokenBucket) SetLimit(limit int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.rate = float64(limit) / tb.period.Seconds()
+	tb.capacity = float64(limit)
+	for _, b := range tb.buckets {
+		b.tokens = math.Min(b.tokens, tb.capacity)
+	}
+}
+
+// Allow takes a token from the bucket of the key and returns true if the
+// event is allowed.
+func (tb *TokenBucket) Allow(key string) bool {
+	ok, _, _ := tb.Take(key)
+	return ok
+}
+
+// Take takes a token from the bucket of the key. Returns whether the event
+// is allowed, the number of remaining tokens, and the time to wait for the
+// next token if not allowed.
+func (tb *TokenBucket) Take(key string) (ok bool, remaining int, retryAfter time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if tb.capacity <= 0 {
+		return true, 0, 0
+	}
+
+	now := tb.now()
+	tb.sweep(now)
+
+	b := tb.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: tb.capacity, last: now}
+		tb.buckets[key] = b
+	} else {
+		b.tokens = math.Min(tb.capacity, b.tokens+now.Sub(b.last).Seconds()*tb.rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / tb.rate
+		return false, 0, time.Duration(math.Ceil(wait * float64(time.Second)))
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// sweep removes buckets that have been refilled, which are the same as
+// new buckets.
+func (tb *TokenBucket) sweep(now time.Time) {
+	if now.Sub(tb.lastSweep) < sweepInterval {
+		return
+	}
+	tb.lastSweep = now
+	for key, b := range tb.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*tb.rate >= tb.capacity {
+			delete(tb.buckets, key)
+		}
+	}
+}
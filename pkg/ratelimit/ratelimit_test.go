@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	tb := NewTokenBucket(60, time.Minute)
+	tb.now = func() time.Time { return now }
+
+	for i := 0; i < 60; i++ {
+		if ok, _, _ := tb.Take("a"); !ok {
+			t.Fatalf("event %d within the burst should be allowed", i)
+		}
+	}
+	ok, remaining, retry := tb.Take("a")
+	if ok || remaining != 0 {
+		t.Error("event exceeding the burst should be denied")
+	}
+	if retry != time.Second {
+		t.Errorf("expected retry after 1s, got %v", retry)
+	}
+	if ok, _, _ := tb.Take("b"); !ok {
+		t.Error("events should be limited for each key")
+	}
+
+	now = now.Add(2 * time.Second)
+	if ok, remaining, _ := tb.Take("a"); !ok || remaining != 1 {
+		t.Errorf("tokens should be refilled, remaining %d", remaining)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	tb := NewTokenBucket(2, time.Hour)
+	tb.now = func() time.Time { return now }
+
+	if !tb.Allow("a") || !tb.Allow("a") {
+		t.Fatal("events within the limit should be allowed")
+	}
+	if tb.Allow("a") {
+		t.Error("event exceeding the limit should be denied")
+	}
+	if !tb.Allow("b") {
+		t.Error("events should be limited for each key")
+	}
+
+	now = now.Add(30 * time.Minute)
+	if !tb.Allow("a") {
+		t.Error("event should be allowed after a token is refilled")
+	}
+	if tb.Allow("a") {
+		t.Error("event exceeding the refilled tokens should be denied")
+	}
+}
+
+func TestTokenBucketSweep(t *testing.T) {
+	now := time.Now()
+	tb := NewTokenBucket(60, time.Minute)
+	tb.now = func() time.Time { return now }
+
+	tb.Take("a")
+	now = now.Add(2 * time.Minute)
+	tb.Take("b")
+	if _, found := tb.buckets["a"]; found {
+		t.Error("refilled bucket should be removed")
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	tb := NewTokenBucket(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if !tb.Allow("a") {
+			t.Fatal("unlimited bucket should allow all events")
+		}
+	}
+}
+
+func TestTokenBucketSetLimit(t *testing.T) {
+	now := time.Now()
+	tb := NewTokenBucket(60, time.Minute)
+	tb.now = func() time.Time { return now }
+
+	tb.Take("a")
+	tb.SetLimit(10)
+	if tb.Limit() != 10 {
+		t.Errorf("expected limit 10, got %d", tb.Limit())
+	}
+	if _, remaining, _ := tb.Take("a"); remaining != 9 {
+		t.Errorf("tokens should be limited to the new capacity, remaining %d", remaining)
+	}
+}
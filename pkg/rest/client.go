@@ -24,6 +24,8 @@ type Client struct {
 	version string
 	// custom http headers configured by users.
 	customHTTPHeaders map[string]string
+	// retry policy of failed requests, nil to disable retry.
+	retry *RetryPolicy
 }
 
 // NewClient initializes a new API client for the given host and API version.
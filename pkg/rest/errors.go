@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 )
@@ -12,9 +13,30 @@ var ErrConnectionFailed = errors.New("Cannot connect to the server.")
 type ServerError struct {
 	status int
 	body   []byte
+	resp   *errorResponse
+}
+
+// errorResponse is the structured error response of API server, which
+// contains an error code and message.
+type errorResponse struct {
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+func newServerError(status int, body []byte) ServerError {
+	se := ServerError{status: status, body: body}
+	var resp errorResponse
+	if json.Unmarshal(body, &resp) == nil && resp.Code != "" {
+		se.resp = &resp
+	}
+	return se
 }
 
 func (se ServerError) Error() string {
+	if se.resp != nil {
+		return fmt.Sprintf("Error response from server: %s", se.resp.Message)
+	}
 	return fmt.Sprintf("Error response from server: %s", string(se.body))
 }
 
@@ -25,3 +47,28 @@ func (se ServerError) StatusCode() int {
 func (se ServerError) RawError() []byte {
 	return se.body
 }
+
+// Code returns the error code of a structured error response, or an empty
+// string if the server doesn't respond with an error code.
+func (se ServerError) Code() string {
+	if se.resp != nil {
+		return se.resp.Code
+	}
+	return ""
+}
+
+// Message returns the error message without the prefix.
+func (se ServerError) Message() string {
+	if se.resp != nil {
+		return se.resp.Message
+	}
+	return string(se.body)
+}
+
+// Details returns the details of a structured error response.
+func (se ServerError) Details() map[string]interface{} {
+	if se.resp != nil {
+		return se.resp.Details
+	}
+	return nil
+}
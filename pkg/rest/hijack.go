@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// HijackedResponse holds connection information for a hijacked request.
+type HijackedResponse struct {
+	Conn   net.Conn
+	Reader *bufio.Reader
+	Header http.Header
+}
+
+// Close closes the hijacked connection and reader.
+func (h *HijackedResponse) Close() {
+	h.Conn.Close()
+}
+
+// CloseWriter is an interface that implements structs
+// that close input streams to prevent from writing.
+type CloseWriter interface {
+	CloseWrite() error
+}
+
+// CloseWrite closes a readWriter for writing.
+func (h *HijackedResponse) CloseWrite() error {
+	if conn, ok := h.Conn.(CloseWriter); ok {
+		return conn.CloseWrite()
+	}
+	return nil
+}
+
+// PostHijacked sends a POST request and hijacks the connection. The server
+// must switch protocols to give the client a raw bidirectional stream.
+func (cli *Client) PostHijacked(ctx context.Context, path string, query url.Values, headers map[string][]string) (*HijackedResponse, error) {
+	req, err := cli.newRequest("POST", path, query, bytes.NewReader(nil), headers)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Host = cli.addr
+	if cli.proto == "unix" {
+		req.Host = "localhost"
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := cli.dial()
+	if err != nil {
+		if strings.Contains(err.Error(), "connection refused") {
+			return nil, ErrConnectionFailed
+		}
+		return nil, err
+	}
+
+	// abort the handshake if the context is cancelled
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	defer close(done)
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if len(body) == 0 {
+			body = []byte(http.StatusText(resp.StatusCode))
+		}
+		return nil, newServerError(resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return &HijackedResponse{Conn: conn, Reader: br, Header: resp.Header}, nil
+}
+
+func (cli *Client) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	if cli.proto == "unix" {
+		return dialer.Dial("unix", cli.addr)
+	}
+
+	tlsConfig := cli.transport.TLSConfig()
+	if tlsConfig == nil && cli.proto != "https" {
+		return dialer.Dial("tcp", hostPort(cli.addr, "80"))
+	}
+
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	host := hostPort(cli.addr, "443")
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(host)
+	}
+	return tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+}
+
+func hostPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, port)
+	}
+	return addr
+}
@@ -66,11 +66,11 @@ func (cli *Client) sendRequest(ctx context.Context, method, path string, query u
 	var body io.Reader
 
 	if obj != nil {
-		var err error
-		body, err = encodeData(obj)
+		buf, err := encodeData(obj)
 		if err != nil {
 			return nil, err
 		}
+		body = bytes.NewReader(buf.Bytes())
 		if headers == nil {
 			headers = make(map[string][]string)
 		}
@@ -81,16 +81,31 @@ func (cli *Client) sendRequest(ctx context.Context, method, path string, query u
 }
 
 func (cli *Client) sendClientRequest(ctx context.Context, method, path string, query url.Values, body io.Reader, headers map[string][]string) (*ServerResponse, error) {
-	serverResp := &ServerResponse{
-		Body:       nil,
-		StatusCode: -1,
-	}
-
 	expectedPayload := (method == "POST" || method == "PUT")
 	if expectedPayload && body == nil {
 		body = bytes.NewReader([]byte{})
 	}
 
+	for attempt := 0; ; attempt++ {
+		resp, err := cli.doRequest(ctx, method, path, query, body, headers)
+		if cli.retry == nil || attempt >= cli.retry.MaxRetries || !cli.retry.shouldRetry(method, resp, err) {
+			return resp, err
+		}
+		if !rewind(body) {
+			return resp, err
+		}
+		if sleep(ctx, cli.retry.backoff(attempt, resp)) != nil {
+			return resp, err
+		}
+	}
+}
+
+func (cli *Client) doRequest(ctx context.Context, method, path string, query url.Values, body io.Reader, headers map[string][]string) (*ServerResponse, error) {
+	serverResp := &ServerResponse{
+		Body:       nil,
+		StatusCode: -1,
+	}
+
 	req, err := cli.newRequest(method, path, query, body, headers)
 	if err != nil {
 		return serverResp, err
@@ -126,10 +141,12 @@ func (cli *Client) sendClientRequest(ctx context.Context, method, path string, q
 
 	if resp != nil {
 		serverResp.StatusCode = resp.StatusCode
+		serverResp.Header = resp.Header
 	}
 
 	if serverResp.StatusCode < 200 || serverResp.StatusCode >= 400 {
 		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			return serverResp, err
 		}
@@ -137,13 +154,12 @@ func (cli *Client) sendClientRequest(ctx context.Context, method, path string, q
 			message := fmt.Sprintf("Error: request returned %s for API route and version %s, "+
 				"check if the server supports the requested API version",
 				http.StatusText(serverResp.StatusCode), req.URL)
-			return serverResp, ServerError{serverResp.StatusCode, []byte(message)}
+			return serverResp, newServerError(serverResp.StatusCode, []byte(message))
 		}
-		return serverResp, ServerError{serverResp.StatusCode, bytes.TrimSpace(body)}
+		return serverResp, newServerError(serverResp.StatusCode, bytes.TrimSpace(body))
 	}
 
 	serverResp.Body = resp.Body
-	serverResp.Header = resp.Header
 	return serverResp, nil
 }
 
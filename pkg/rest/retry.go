@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RetryPolicy controls how failed requests are retried. Requests are retried
+// if the server can't be reached or is temporarily unavailable, or if the
+// client is rate limited by the server. Only idempotent requests are retried
+// on server failures, and requests with a body are retried only if the body
+// can be rewound.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries, zero disables retry.
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, doubled on every
+	// subsequent retry.
+	MinBackoff time.Duration
+	// MaxBackoff is the upper bound of the delay between retries, which
+	// also limits the delay requested by the Retry-After header.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is a reasonable retry policy for interactive clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// SetRetryPolicy sets the retry policy of the client. Requests are not
+// retried if the policy is nil.
+func (cli *Client) SetRetryPolicy(policy *RetryPolicy) {
+	cli.retry = policy
+}
+
+// shouldRetry returns true if the request should be retried after the
+// given response or error.
+func (p *RetryPolicy) shouldRetry(method string, resp *ServerResponse, err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == ErrConnectionFailed {
+		return isIdempotent(method)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the given retry attempt, starting from 0.
+func (p *RetryPolicy) backoff(attempt int, resp *ServerResponse) time.Duration {
+	if resp.Header != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			d := time.Duration(secs) * time.Second
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+
+	d := p.MinBackoff << uint(attempt)
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if d > 0 {
+		// add jitter so that clients don't retry in lockstep
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// sleep waits for the given duration, or returns the error of the context
+// if it's done before that.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	default:
+		return false
+	}
+}
+
+// rewind resets the request body for a retry. Returns false if the body
+// cannot be rewound.
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+	if s, ok := body.(io.Seeker); ok {
+		_, err := s.Seek(0, os.SEEK_SET)
+		return err == nil
+	}
+	return false
+}
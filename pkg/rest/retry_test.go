@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
+	ts := httptest.NewServer(handler)
+	cli, err := NewClient(ts.URL, "", nil, nil)
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	cli.SetRetryPolicy(&RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	return cli, ts
+}
+
+func TestRetryUnavailable(t *testing.T) {
+	calls := 0
+	cli, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "{\"a\":1}\n" {
+			t.Errorf("unexpected request body %q", body)
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer ts.Close()
+
+	resp, err := cli.Put(context.Background(), "/test", nil, map[string]int{"a": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.EnsureClosed()
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	cli, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer ts.Close()
+
+	_, err := cli.Post(context.Background(), "/test", nil, nil, nil)
+	se, ok := err.(ServerError)
+	if !ok || se.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("expected too many requests error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestNoRetryNonIdempotent(t *testing.T) {
+	calls := 0
+	cli, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer ts.Close()
+
+	if _, err := cli.Post(context.Background(), "/test", nil, nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	cli, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer ts.Close()
+	cli.SetRetryPolicy(&RetryPolicy{MaxRetries: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := cli.Get(ctx, "/test", nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("retry was not cancelled with context")
+	}
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -51,8 +52,13 @@ func (a *apiTransport) CancelRequest(req *http.Request) {
 }
 
 // defaultTransport creates a new http.Transport with default configuration.
+// The server certificate is verified against system root CAs if the proto
+// is https.
 func defaultTransport(proto, addr string) *http.Transport {
 	tr := new(http.Transport)
+	if proto == "https" {
+		tr.TLSClientConfig = &tls.Config{}
+	}
 	configureTransport(tr, proto, addr)
 	return tr
 }
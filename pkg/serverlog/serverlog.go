@@ -6,31 +6,72 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/cloudway/platform/pkg/stdcopy"
 )
 
 // Error describes the error that occurred in server. `Code` is an integer
-// error code, `Message` is the error message.
+// error code, `Message` is the error message, and `Details` is structured
+// information provided by the error.
 type Error struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"msg,omitempty"`
+	Code    int                    `json:"code,omitempty"`
+	Message string                 `json:"msg,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
+// errorDetailer is implemented by errors providing structured details.
+type errorDetailer interface {
+	ErrorDetails() map[string]interface{}
+}
+
+// Progress reports the progress of a long running operation, such as a
+// build or a distribution of the application repository. `Percent` is in
+// the range of 0 to 100, or negative if the progress can't be measured.
+// Progress of a single container is reported with the `Event` happened on
+// the container, and `Percent` is not meaningful.
+type Progress struct {
+	Phase     string `json:"phase"`
+	Percent   int    `json:"pct"`
+	Container string `json:"id,omitempty"`
+	Event     string `json:"event,omitempty"`
+	Bytes     int64  `json:"bytes,omitempty"`
+	Error     string `json:"err,omitempty"`
+}
+
+// ProgressWriter receives progress records from server. A writer of the
+// standard output given to Drain implements this interface to render
+// progress.
+type ProgressWriter interface {
+	WriteProgress(p *Progress)
+}
+
+// ProgressHeader is the request header sent by clients that accept progress
+// records. Servers never send progress records to clients without the
+// header, which expect only the result record in the data stream.
+const ProgressHeader = "X-Cloudway-Progress"
+
 // record represents object generated from server
 type record struct {
-	Error  *Error      `json:"err,omitempty"`
-	Result interface{} `json:"obj,omitempty"`
+	Error    *Error      `json:"err,omitempty"`
+	Result   interface{} `json:"obj,omitempty"`
+	Progress *Progress   `json:"prog,omitempty"`
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("Error response from server: %s", e.Message)
 }
 
-// ServerLog encapsulate multiplexed standard output and standard error streams.
+// ServerLog encapsulate multiplexed standard output and standard error streams,
+// and optionally the progress records.
 type ServerLog struct {
-	stdout io.Writer
-	stderr io.Writer
+	stdout  io.Writer
+	stderr  io.Writer
+	data    io.Writer
+	observe func(*Progress)
 }
 
 // New create a multiplexed server log.
@@ -41,6 +82,16 @@ func New(w io.Writer) *ServerLog {
 	}
 }
 
+// NewResponse create a multiplexed server log that sends progress records
+// if the request accepts progress.
+func NewResponse(w io.Writer, r *http.Request) *ServerLog {
+	l := New(w)
+	if r.Header.Get(ProgressHeader) != "" {
+		l.data = stdcopy.NewWriter(w, stdcopy.Data)
+	}
+	return l
+}
+
 // Encap encapsulate two streams.
 func Encap(stdout, stderr io.Writer) *ServerLog {
 	return &ServerLog{
@@ -52,6 +103,149 @@ func Encap(stdout, stderr io.Writer) *ServerLog {
 // Discard write server logs succeed without doing anything.
 var Discard = Encap(ioutil.Discard, ioutil.Discard)
 
+// Synchronized returns a server log that can be written from multiple
+// goroutines concurrently.
+func Synchronized(l *ServerLog) *ServerLog {
+	if l == nil {
+		return nil
+	}
+	mu := new(sync.Mutex)
+	sl := &ServerLog{
+		stdout: &lockedWriter{mu: mu, w: l.stdout},
+		stderr: &lockedWriter{mu: mu, w: l.stderr},
+	}
+	if l.data != nil {
+		sl.data = &lockedWriter{mu: mu, w: l.data}
+	}
+	if l.observe != nil {
+		observe := l.observe
+		sl.observe = func(p *Progress) {
+			mu.Lock()
+			observe(p)
+			mu.Unlock()
+		}
+	}
+	return sl
+}
+
+// Redirect returns a server log that writes output to the given streams,
+// and sends progress records to the same destination as the given log.
+func Redirect(l *ServerLog, stdout, stderr io.Writer) *ServerLog {
+	sl := Encap(stdout, stderr)
+	if l != nil {
+		sl.data, sl.observe = l.data, l.observe
+	}
+	return sl
+}
+
+// Observe returns a server log that calls the function with every progress
+// record, before sending the record to client.
+func Observe(l *ServerLog, fn func(*Progress)) *ServerLog {
+	if l == nil {
+		l = Discard
+	}
+	sl := *l
+	if prev := l.observe; prev != nil {
+		sl.observe = func(p *Progress) {
+			prev(p)
+			fn(p)
+		}
+	} else {
+		sl.observe = fn
+	}
+	return &sl
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// The replacement of secret values in masked server logs.
+const secretMask = "******"
+
+// The maximum length of a partial line buffered by masked server logs.
+const maxMaskedLine = 4096
+
+// Masked returns a server log that replaces the secret values with asterisks.
+// Output is buffered by lines so a secret split across writes is masked. The
+// returned function writes the output remaining in buffers.
+func Masked(l *ServerLog, secrets []string) (*ServerLog, func()) {
+	var values []string
+	for _, s := range secrets {
+		if s != "" {
+			values = append(values, s)
+		}
+	}
+	if l == nil || len(values) == 0 {
+		return l, func() {}
+	}
+
+	// replace longer secrets first, which may contain shorter ones
+	sort.Sort(sort.Reverse(byLength(values)))
+	pairs := make([]string, 0, len(values)*2)
+	for _, s := range values {
+		pairs = append(pairs, s, secretMask)
+	}
+	r := strings.NewReplacer(pairs...)
+
+	stdout := &maskedWriter{w: l.stdout, r: r}
+	stderr := &maskedWriter{w: l.stderr, r: r}
+	return &ServerLog{stdout: stdout, stderr: stderr, data: l.data, observe: l.observe}, func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+type byLength []string
+
+func (a byLength) Len() int           { return len(a) }
+func (a byLength) Less(i, j int) bool { return len(a[i]) < len(a[j]) }
+func (a byLength) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+type maskedWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	r   *strings.Replacer
+	buf []byte
+}
+
+func (mw *maskedWriter) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.buf = append(mw.buf, p...)
+	i := bytes.LastIndexByte(mw.buf, '\n')
+	if i == -1 {
+		if len(mw.buf) < maxMaskedLine {
+			return len(p), nil
+		}
+		i = len(mw.buf) - 1
+	}
+
+	line := mw.r.Replace(string(mw.buf[:i+1]))
+	mw.buf = append(mw.buf[:0], mw.buf[i+1:]...)
+	if _, err := io.WriteString(mw.w, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (mw *maskedWriter) flush() {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+	if len(mw.buf) != 0 {
+		io.WriteString(mw.w, mw.r.Replace(string(mw.buf)))
+		mw.buf = nil
+	}
+}
+
 func (l *ServerLog) Stdout() io.Writer {
 	if l == nil {
 		return nil
@@ -76,10 +270,27 @@ func (l *ServerLog) Write(p []byte) (n int, err error) {
 	}
 }
 
+// Progress sends the progress record if accepted by client.
+func (l *ServerLog) Progress(p Progress) error {
+	if l == nil {
+		return nil
+	}
+	if l.observe != nil {
+		l.observe(&p)
+	}
+	if l.data == nil {
+		return nil
+	}
+	return json.NewEncoder(l.data).Encode(&record{Progress: &p})
+}
+
 func SendError(w io.Writer, err error) error {
 	rec := record{
 		Error: &Error{Message: err.Error()},
 	}
+	if e, ok := err.(errorDetailer); ok {
+		rec.Error.Details = e.ErrorDetails()
+	}
 	out := stdcopy.NewWriter(w, stdcopy.Data)
 	return json.NewEncoder(out).Encode(&rec)
 }
@@ -89,20 +300,74 @@ func SendObject(w io.Writer, obj interface{}) error {
 	return json.NewEncoder(out).Encode(&record{Result: obj})
 }
 
-func Drain(in io.Reader, dstout, dsterr io.Writer, result interface{}) (err error) {
-	data := bytes.NewBuffer(nil)
-	_, err = stdcopy.Copy(dstout, dsterr, data, in)
-	if err != nil {
+// Drain copies the server output to the destination streams, and decodes
+// the result or error sent by server. Progress records are written to the
+// standard output if it implements ProgressWriter, and discarded otherwise.
+func Drain(in io.Reader, dstout, dsterr io.Writer, result interface{}) error {
+	data := &recordWriter{result: result}
+	if pw, ok := dstout.(ProgressWriter); ok {
+		data.progress = pw
+	}
+
+	if _, err := stdcopy.Copy(dstout, dsterr, data, in); err != nil {
 		return err
 	}
+	if err := data.flush(); err != nil {
+		return err
+	}
+	return data.err
+}
 
-	if data.Len() != 0 {
-		rec := record{Result: result}
-		err = json.NewDecoder(data).Decode(&rec)
-		if err == nil && rec.Error != nil {
-			err = rec.Error
+// recordWriter decodes the records in the data stream as they arrive, one
+// record per line.
+type recordWriter struct {
+	buf      []byte
+	result   interface{}
+	progress ProgressWriter
+	err      error
+}
+
+func (rw *recordWriter) Write(p []byte) (int, error) {
+	rw.buf = append(rw.buf, p...)
+	for {
+		i := bytes.IndexByte(rw.buf, '\n')
+		if i == -1 {
+			break
+		}
+		err := rw.decode(rw.buf[:i])
+		rw.buf = rw.buf[i+1:]
+		if err != nil {
+			return 0, err
 		}
 	}
+	return len(p), nil
+}
 
-	return
+func (rw *recordWriter) flush() error {
+	if len(rw.buf) == 0 {
+		return nil
+	}
+	err := rw.decode(rw.buf)
+	rw.buf = nil
+	return err
+}
+
+func (rw *recordWriter) decode(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+
+	rec := record{Result: rw.result}
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+	switch {
+	case rec.Error != nil:
+		rw.err = rec.Error
+	case rec.Progress != nil:
+		if rw.progress != nil {
+			rw.progress.WriteProgress(rec.Progress)
+		}
+	}
+	return nil
 }
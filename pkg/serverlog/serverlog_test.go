@@ -0,0 +1,138 @@
+package serverlog
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSynchronized(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	log := Synchronized(Encap(&stdout, &stderr))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				log.Write([]byte("out\n"))
+				log.Stderr().Write([]byte("err\n"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := strings.Count(stdout.String(), "out\n"); n != 1000 {
+		t.Fatalf("expected 1000 lines in stdout, got %d", n)
+	}
+	if n := strings.Count(stderr.String(), "err\n"); n != 1000 {
+		t.Fatalf("expected 1000 lines in stderr, got %d", n)
+	}
+}
+
+func TestSynchronizedNil(t *testing.T) {
+	if Synchronized(nil) != nil {
+		t.Fatal("expected nil server log")
+	}
+}
+
+func TestMasked(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	log, flush := Masked(Encap(&stdout, &stderr), []string{"secret", "secret-token", ""})
+
+	log.Write([]byte("token is sec"))
+	log.Write([]byte("ret-token\nkey is secret\n"))
+	log.Stderr().Write([]byte("no newline secret"))
+	if stderr.Len() != 0 {
+		t.Fatalf("expected partial line buffered, got %q", stderr.String())
+	}
+	flush()
+
+	if s := stdout.String(); s != "token is ******\nkey is ******\n" {
+		t.Fatalf("unexpected stdout: %q", s)
+	}
+	if s := stderr.String(); s != "no newline ******" {
+		t.Fatalf("unexpected stderr: %q", s)
+	}
+}
+
+func TestMaskedNoSecrets(t *testing.T) {
+	log := Encap(&bytes.Buffer{}, &bytes.Buffer{})
+	if masked, _ := Masked(log, nil); masked != log {
+		t.Fatal("expected the same server log without secrets")
+	}
+}
+
+type progressRecorder struct {
+	bytes.Buffer
+	progress []Progress
+}
+
+func (r *progressRecorder) WriteProgress(p *Progress) {
+	r.progress = append(r.progress, *p)
+}
+
+func TestDrainProgress(t *testing.T) {
+	var buf bytes.Buffer
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(ProgressHeader, "1")
+	log := NewResponse(&buf, req)
+	log.Progress(Progress{Phase: "building", Percent: -1})
+	log.Write([]byte("done\n"))
+	log.Progress(Progress{Phase: "distributing", Percent: 50, Container: "abc"})
+	SendObject(&buf, map[string]string{"name": "test"})
+
+	var stdout progressRecorder
+	var result map[string]string
+	if err := Drain(&buf, &stdout, ioutil.Discard, &result); err != nil {
+		t.Fatal(err)
+	}
+	if s := stdout.String(); s != "done\n" {
+		t.Fatalf("unexpected stdout: %q", s)
+	}
+	if len(stdout.progress) != 2 || stdout.progress[1].Percent != 50 || stdout.progress[1].Container != "abc" {
+		t.Fatalf("unexpected progress: %+v", stdout.progress)
+	}
+	if result["name"] != "test" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+}
+
+func TestProgressNotAccepted(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewResponse(&buf, httptest.NewRequest("POST", "/", nil))
+	log.Progress(Progress{Phase: "building", Percent: -1})
+	Synchronized(log).Progress(Progress{Phase: "building", Percent: -1})
+	if buf.Len() != 0 {
+		t.Fatalf("expected no progress sent, got %q", buf.String())
+	}
+
+	SendError(&buf, errors.New("failed"))
+	if err := Drain(&buf, ioutil.Discard, ioutil.Discard, nil); err == nil || err.(*Error).Message != "failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type detailedError struct{}
+
+func (detailedError) Error() string { return "deployment failed" }
+
+func (detailedError) ErrorDetails() map[string]interface{} {
+	return map[string]interface{}{"RolledBack": true}
+}
+
+func TestSendErrorDetails(t *testing.T) {
+	var buf bytes.Buffer
+	SendError(&buf, detailedError{})
+
+	err := Drain(&buf, ioutil.Discard, ioutil.Discard, nil)
+	e, ok := err.(*Error)
+	if !ok || e.Message != "deployment failed" || e.Details["RolledBack"] != true {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
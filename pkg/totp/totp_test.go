@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// The test secret "12345678901234567890" from RFC 6238.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.time, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("Code at %d: expected %s, got %s", tt.time, tt.code, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, _ := Code(secret, Step(now))
+	if step, ok := Validate(secret, code, now); !ok || step != Step(now) {
+		t.Error("current code should be valid")
+	}
+	if _, ok := Validate(secret, code, now.Add(Period*time.Second)); !ok {
+		t.Error("code should be valid within clock skew")
+	}
+	if _, ok := Validate(secret, code, now.Add(3*Period*time.Second)); ok {
+		t.Error("expired code should be invalid")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("code with wrong length should be invalid")
+	}
+}
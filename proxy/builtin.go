@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// The builtin proxy is updated through the admin API served by the proxy
+// server. The proxy URL has the form builtin://host:port.
+type builtinProxy struct {
+	base   string
+	client *http.Client
+}
+
+func init() {
+	proxyRegistry["builtin"] = func(u *url.URL) (Proxy, error) {
+		return &builtinProxy{
+			base:   "http://" + u.Host,
+			client: &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	}
+}
+
+type switchRequest struct {
+	Old       []string
+	Endpoints map[string][]*manifest.Endpoint
+}
+
+type splitRequest struct {
+	Stable, Canary map[string][]*manifest.Endpoint
+	Weight         int
+}
+
+type certificateRequest struct {
+	Certificate, PrivateKey []byte
+}
+
+func (px *builtinProxy) do(method, path string, body interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, px.base+path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := px.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("proxy: %s %s: %s", method, path, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (px *builtinProxy) query(path string, v interface{}) error {
+	resp, err := px.client.Get(px.base + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("proxy: GET %s: %s", path, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (px *builtinProxy) Close() error {
+	return nil
+}
+
+func (px *builtinProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint) error {
+	return px.do("PUT", "/containers/"+id, endpoints)
+}
+
+func (px *builtinProxy) RemoveEndpoints(id string) error {
+	return px.do("DELETE", "/containers/"+id, nil)
+}
+
+func (px *builtinProxy) SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error {
+	return px.do("POST", "/switch", &switchRequest{old, endpoints})
+}
+
+func (px *builtinProxy) SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error {
+	return px.do("POST", "/split", &splitRequest{stable, canary, weight})
+}
+
+func (px *builtinProxy) Reset() error {
+	return px.do("POST", "/reset", nil)
+}
+
+func (px *builtinProxy) SetCertificate(domain string, cert, key []byte) error {
+	return px.do("PUT", "/certificates/"+domain, &certificateRequest{cert, key})
+}
+
+func (px *builtinProxy) RemoveCertificate(domain string) error {
+	return px.do("DELETE", "/certificates/"+domain, nil)
+}
+
+func (px *builtinProxy) Activity() (map[string]time.Time, error) {
+	var activity map[string]time.Time
+	err := px.query("/activity", &activity)
+	return activity, err
+}
+
+func (px *builtinProxy) Park(key string, hosts []string) error {
+	return px.do("PUT", "/parked/"+key, hosts)
+}
+
+func (px *builtinProxy) Unpark(key string) error {
+	return px.do("DELETE", "/parked/"+key, nil)
+}
+
+// NewAdminHandler returns the handler of admin API that updates the given
+// routing table. It must not be exposed to the public network.
+func NewAdminHandler(t *RoutingTable) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/containers/")
+		if id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case "PUT":
+			var endpoints []*manifest.Endpoint
+			if decode(w, r, &endpoints) {
+				reply(w, t.AddEndpoints(id, endpoints))
+			}
+		case "DELETE":
+			reply(w, t.RemoveEndpoints(id))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/switch", func(w http.ResponseWriter, r *http.Request) {
+		var req switchRequest
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		} else if decode(w, r, &req) {
+			reply(w, t.SwitchEndpoints(req.Old, req.Endpoints))
+		}
+	})
+
+	mux.HandleFunc("/split", func(w http.ResponseWriter, r *http.Request) {
+		var req splitRequest
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		} else if decode(w, r, &req) {
+			reply(w, t.SplitEndpoints(req.Stable, req.Canary, req.Weight))
+		}
+	})
+
+	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		} else {
+			reply(w, t.Reset())
+		}
+	})
+
+	mux.HandleFunc("/certificates/", func(w http.ResponseWriter, r *http.Request) {
+		domain := strings.TrimPrefix(r.URL.Path, "/certificates/")
+		if domain == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case "PUT":
+			var req certificateRequest
+			if decode(w, r, &req) {
+				reply(w, t.SetCertificate(domain, req.Certificate, req.PrivateKey))
+			}
+		case "DELETE":
+			reply(w, t.RemoveCertificate(domain))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/activity", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		activity, err := t.Activity()
+		if err != nil {
+			reply(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(activity)
+	})
+
+	mux.HandleFunc("/parked/", func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/parked/")
+		if key == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case "PUT":
+			var hosts []string
+			if decode(w, r, &hosts) {
+				reply(w, t.Park(key, hosts))
+			}
+		case "DELETE":
+			reply(w, t.Unpark(key))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	return mux
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -35,6 +35,17 @@ func (px *hipacheProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint)
 	}
 
 	// add new endpoints
+	for _, m := range httpMappings(endpoints) {
+		if err := addEndpoint(px.conn, id, m[0], m[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns frontend and backend pairs of http proxy mappings.
+func httpMappings(endpoints []*manifest.Endpoint) (mappings [][2]string) {
 	for _, ep := range endpoints {
 		for _, m := range ep.ProxyMappings {
 			if m.Protocol == "http" {
@@ -43,14 +54,11 @@ func (px *hipacheProxy) AddEndpoints(id string, endpoints []*manifest.Endpoint)
 					backend = backend + "#" + frontend[i:]
 					frontend = frontend[0:i]
 				}
-				if err := addEndpoint(px.conn, id, frontend, backend); err != nil {
-					return err
-				}
+				mappings = append(mappings, [2]string{frontend, backend})
 			}
 		}
 	}
-
-	return nil
+	return
 }
 
 func addEndpoint(conn redis.Conn, id, frontend, backend string) error {
@@ -89,19 +97,25 @@ func addEndpoint(conn redis.Conn, id, frontend, backend string) error {
 	return nil
 }
 
+func containerRecords(conn redis.Conn, id string) ([]string, error) {
+	r, err := redis.Values(conn.Do("LRANGE", "container:"+id, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	var vs []string
+	err = redis.ScanSlice(r, &vs)
+	return vs, err
+}
+
 func (px *hipacheProxy) RemoveEndpoints(id string) error {
 	key := "container:" + id
 
 	// query endpoints by container id
-	r, err := redis.Values(px.conn.Do("LRANGE", key, 0, -1))
+	vs, err := containerRecords(px.conn, id)
 	if err != nil {
 		return err
 	}
-
-	var vs []string
-	if err = redis.ScanSlice(r, &vs); err != nil {
-		return err
-	}
 	if len(vs) == 0 {
 		return nil
 	}
@@ -132,8 +146,231 @@ func (px *hipacheProxy) RemoveEndpoints(id string) error {
 	return err
 }
 
+func (px *hipacheProxy) SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error {
+	// query endpoints of old containers before the transaction
+	var removed [][2]string
+	for _, id := range old {
+		vs, err := containerRecords(px.conn, id)
+		if err != nil {
+			return err
+		}
+		for _, rec := range vs {
+			kv := strings.SplitN(rec, " ", 2)
+			removed = append(removed, [2]string{kv[0], kv[1]})
+		}
+	}
+
+	// frontends must be created before adding backends
+	created := make(map[string]bool)
+	added, err := px.createFrontends(endpoints, created)
+	if err != nil {
+		return err
+	}
+
+	// add new backends and remove old backends in a transaction
+	px.conn.Send("MULTI")
+	for id, ms := range added {
+		for _, m := range ms {
+			px.conn.Send("RPUSH", m[0], m[1])
+			px.conn.Send("RPUSH", "container:"+id, m[0]+" "+m[1])
+		}
+	}
+	for _, m := range removed {
+		px.conn.Send("LREM", m[0], 0, m[1])
+	}
+	for _, id := range old {
+		px.conn.Send("DEL", "container:"+id)
+	}
+	if _, err := px.conn.Do("EXEC"); err != nil {
+		return err
+	}
+	logrus.Debugf("switched %d containers to %d containers", len(old), len(endpoints))
+
+	px.removeEmptyFrontends(removed, created)
+	return nil
+}
+
+// SplitEndpoints replaces backends of stable and canary containers. Hipache
+// balances requests evenly among backends of a frontend, so the traffic is
+// weighted by adding a backend multiple times.
+func (px *hipacheProxy) SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error {
+	// query endpoints of all containers before the transaction
+	var removed [][2]string
+	for _, eps := range []map[string][]*manifest.Endpoint{stable, canary} {
+		for id := range eps {
+			vs, err := containerRecords(px.conn, id)
+			if err != nil {
+				return err
+			}
+			for _, rec := range vs {
+				kv := strings.SplitN(rec, " ", 2)
+				removed = append(removed, [2]string{kv[0], kv[1]})
+			}
+		}
+	}
+
+	created := make(map[string]bool)
+	stableAdded, err := px.createFrontends(stable, created)
+	if err != nil {
+		return err
+	}
+	canaryAdded, err := px.createFrontends(canary, created)
+	if err != nil {
+		return err
+	}
+
+	// count stable and canary backends of each frontend
+	counts := make(map[string]*[2]int)
+	for i, added := range []map[string][][2]string{stableAdded, canaryAdded} {
+		for _, ms := range added {
+			for _, m := range ms {
+				if counts[m[0]] == nil {
+					counts[m[0]] = new([2]int)
+				}
+				counts[m[0]][i]++
+			}
+		}
+	}
+
+	// replace backends in a transaction
+	px.conn.Send("MULTI")
+	for _, m := range removed {
+		px.conn.Send("LREM", m[0], 0, m[1])
+	}
+	for _, eps := range []map[string][]*manifest.Endpoint{stable, canary} {
+		for id := range eps {
+			px.conn.Send("DEL", "container:"+id)
+		}
+	}
+	for i, added := range []map[string][][2]string{stableAdded, canaryAdded} {
+		for id, ms := range added {
+			for _, m := range ms {
+				copies := splitCopies(counts[m[0]][0], counts[m[0]][1], weight)[i]
+				for j := 0; j < copies; j++ {
+					px.conn.Send("RPUSH", m[0], m[1])
+				}
+				if copies > 0 {
+					px.conn.Send("RPUSH", "container:"+id, m[0]+" "+m[1])
+				}
+			}
+		}
+	}
+	if _, err := px.conn.Do("EXEC"); err != nil {
+		return err
+	}
+	logrus.Debugf("routed %d%% of traffic to %d canary containers", weight, len(canary))
+
+	px.removeEmptyFrontends(removed, created)
+	return nil
+}
+
+// The maximum number of times a backend is added to a frontend.
+const maxBackendCopies = 20
+
+// splitCopies returns the number of times each stable and canary backend
+// is added to a frontend, so that canary backends receive approximately
+// the given percentage of requests.
+func splitCopies(nstable, ncanary, weight int) [2]int {
+	switch {
+	case ncanary == 0 || (nstable != 0 && weight <= 0):
+		return [2]int{1, 0}
+	case nstable == 0 || weight >= 100:
+		return [2]int{0, 1}
+	}
+
+	a, b := (100-weight)*ncanary, weight*nstable
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	a, b = a/x, b/x
+
+	m := a
+	if b > m {
+		m = b
+	}
+	if m > maxBackendCopies {
+		a, b = (a*maxBackendCopies+m/2)/m, (b*maxBackendCopies+m/2)/m
+		if a == 0 {
+			a = 1
+		}
+		if b == 0 {
+			b = 1
+		}
+	}
+	return [2]int{a, b}
+}
+
+// createFrontends creates frontends of the given endpoints if they are not
+// exist, and returns frontend and backend pairs for each container.
+func (px *hipacheProxy) createFrontends(endpoints map[string][]*manifest.Endpoint, created map[string]bool) (map[string][][2]string, error) {
+	added := make(map[string][][2]string)
+	for id, eps := range endpoints {
+		for _, m := range httpMappings(eps) {
+			key := "frontend:" + m[0]
+			if !created[key] {
+				exists, err := redis.Bool(px.conn.Do("EXISTS", key))
+				if err != nil {
+					return nil, err
+				}
+				if !exists {
+					if _, err = px.conn.Do("RPUSH", key, m[0]); err != nil {
+						return nil, err
+					}
+				}
+				created[key] = true
+			}
+			added[id] = append(added[id], [2]string{key, m[1]})
+		}
+	}
+	return added, nil
+}
+
+// removeEmptyFrontends removes frontends no longer have backends.
+func (px *hipacheProxy) removeEmptyFrontends(removed [][2]string, created map[string]bool) {
+	for _, m := range removed {
+		if created[m[0]] {
+			continue
+		}
+		n, err := redis.Int(px.conn.Do("LLEN", m[0]))
+		if err == nil && n <= 1 {
+			if _, err = px.conn.Do("DEL", m[0]); err == nil {
+				logrus.Debugf("remove %s", m[0])
+			}
+		}
+	}
+}
+
 func (px *hipacheProxy) Reset() error {
-	// remove all keys from redis database
-	_, err := px.conn.Do("FLUSHALL")
+	// remove all routes from redis database, certificates are kept
+	for _, pattern := range []string{"frontend:*", "container:*"} {
+		keys, err := redis.Values(px.conn.Do("KEYS", pattern))
+		if err != nil {
+			return err
+		}
+		if len(keys) != 0 {
+			if _, err = px.conn.Do("DEL", keys...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetCertificate stores the certificate of the domain in the "tls:" hash,
+// which is read by the TLS terminator in front of hipache.
+func (px *hipacheProxy) SetCertificate(domain string, cert, key []byte) error {
+	_, err := px.conn.Do("HMSET", "tls:"+domain, "cert", cert, "key", key)
+	if err == nil {
+		logrus.Debugf("add certificate for %s", domain)
+	}
+	return err
+}
+
+func (px *hipacheProxy) RemoveCertificate(domain string) error {
+	_, err := px.conn.Do("DEL", "tls:"+domain)
+	if err == nil {
+		logrus.Debugf("remove certificate for %s", domain)
+	}
 	return err
 }
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// The default time to hold requests while waking up an idle application.
+const defaultWakeTimeout = 2 * time.Minute
+
+// The interval to check whether the woken application is routed.
+const wakePollInterval = 200 * time.Millisecond
+
+var errWakeTimeout = errors.New("Application is starting, please retry later")
+
+type wakeCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Activity returns the time of the last request routed to each container.
+// Containers no longer routed are forgotten.
+func (t *RoutingTable) Activity() (map[string]time.Time, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.wakeMu.Lock()
+	defer t.wakeMu.Unlock()
+
+	activity := make(map[string]time.Time, len(t.activity))
+	for id, last := range t.activity {
+		if _, ok := t.containers[id]; ok {
+			activity[id] = last
+		} else {
+			delete(t.activity, id)
+		}
+	}
+	return activity, nil
+}
+
+func (t *RoutingTable) touch(id string) {
+	t.wakeMu.Lock()
+	t.activity[id] = time.Now()
+	t.wakeMu.Unlock()
+}
+
+// Park the hosts of the idle application, hosts previously parked for the
+// application are replaced.
+func (t *RoutingTable) Park(key string, hosts []string) error {
+	t.mu.Lock()
+	t.unpark(key)
+	for _, host := range hosts {
+		t.parked[strings.ToLower(host)] = key
+	}
+	t.mu.Unlock()
+	logrus.Debugf("park %s: %s", key, strings.Join(hosts, ", "))
+	return nil
+}
+
+func (t *RoutingTable) Unpark(key string) error {
+	t.mu.Lock()
+	t.unpark(key)
+	t.mu.Unlock()
+	return nil
+}
+
+// unpark removes parked hosts of the application, must be called with the
+// lock held.
+func (t *RoutingTable) unpark(key string) {
+	for host, k := range t.parked {
+		if k == key {
+			delete(t.parked, host)
+		}
+	}
+}
+
+// unparkRoutes removes parked hosts that are routed again, must be called
+// with the lock held.
+func (t *RoutingTable) unparkRoutes(routes []*route) {
+	for _, r := range routes {
+		delete(t.parked, r.host)
+	}
+}
+
+// parkedKey returns the key of the idle application parked on the host.
+func (t *RoutingTable) parkedKey(host string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if key, ok := t.parked[host]; ok {
+		return key
+	}
+	if i := strings.IndexRune(host, '.'); i != -1 {
+		return t.parked["*"+host[i:]]
+	}
+	return ""
+}
+
+// wake starts the idle application and waits until the request is routed.
+// Concurrent requests to the application share the same wake up call. Hosts
+// of the application are unparked if it cannot be started, so subsequent
+// requests fail immediately.
+func (t *RoutingTable) wake(key, host, path string) (*route, error) {
+	t.wakeMu.Lock()
+	call := t.waking[key]
+	if call == nil {
+		call = &wakeCall{done: make(chan struct{})}
+		t.waking[key] = call
+		go func() {
+			logrus.Infof("Wake up idle application %s", key)
+			call.err = t.Wake(key)
+			if call.err != nil {
+				logrus.WithError(call.err).Errorf("Failed to wake up application %s", key)
+				t.Unpark(key)
+			}
+			t.wakeMu.Lock()
+			delete(t.waking, key)
+			t.wakeMu.Unlock()
+			close(call.done)
+		}()
+	}
+	t.wakeMu.Unlock()
+
+	timeout := t.WakeTimeout
+	if timeout <= 0 {
+		timeout = defaultWakeTimeout
+	}
+	deadline := time.After(timeout)
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+	case <-deadline:
+		return nil, errWakeTimeout
+	}
+
+	// routes are added when the proxy is notified of started containers
+	ticker := time.NewTicker(wakePollInterval)
+	defer ticker.Stop()
+	for {
+		if r := t.lookup(host, path); r != nil {
+			return r, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return nil, errWakeTimeout
+		}
+	}
+}
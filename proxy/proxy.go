@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/cloudway/platform/pkg/manifest"
 )
@@ -15,6 +16,14 @@ type Proxy interface {
 	// Remove endpoints associated to a container.
 	RemoveEndpoints(id string) error
 
+	// Remove endpoints associated to old containers and add endpoints
+	// associated to new containers atomically.
+	SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error
+
+	// Replace endpoints associated to stable and canary containers so that
+	// canary containers receive the given percentage of traffic.
+	SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error
+
 	// Reset the proxy to an initial state.
 	Reset() error
 
@@ -22,6 +31,32 @@ type Proxy interface {
 	Close() error
 }
 
+// CertificateStore is implemented by proxies terminating TLS connections
+// of custom domains.
+type CertificateStore interface {
+	// Install the certificate chain and private key of the domain, both
+	// in PEM format.
+	SetCertificate(domain string, cert, key []byte) error
+
+	// Remove the certificate of the domain.
+	RemoveCertificate(domain string) error
+}
+
+// IdleTracker is implemented by proxies tracking requests routed to
+// containers, so inactive applications can be idled and woken up on the
+// next request.
+type IdleTracker interface {
+	// Activity returns the time of the last request routed to each container.
+	Activity() (map[string]time.Time, error)
+
+	// Park the hosts of the idle application identified by the key. Requests
+	// to parked hosts without routes wake up the application.
+	Park(key string, hosts []string) error
+
+	// Unpark hosts of the application so requests are no longer held.
+	Unpark(key string) error
+}
+
 var ErrMisconfigured = errors.New("Proxy URL not configured")
 
 type UnsupportedSchemeError string
@@ -0,0 +1,391 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// RoutingTable is an in-memory proxy that routes HTTP requests to backends
+// of containers. It implements the Proxy interface to update routes, and
+// the http.Handler interface to serve requests.
+type RoutingTable struct {
+	mu         sync.RWMutex
+	containers map[string][]*route
+	hosts      map[string][]*route
+	certs      map[string]*tls.Certificate
+	certDir    string
+	parked     map[string]string
+
+	// Wake is called to start the idle application identified by the key
+	// when a request arrives for its parked hosts. The request is held until
+	// the application is routed or WakeTimeout expires.
+	Wake        func(key string) error
+	WakeTimeout time.Duration
+
+	wakeMu   sync.Mutex
+	waking   map[string]*wakeCall
+	activity map[string]time.Time
+}
+
+type route struct {
+	id      string
+	host    string
+	path    string
+	backend *url.URL
+	proxy   *httputil.ReverseProxy
+	weight  int
+}
+
+// NewRoutingTable creates an empty routing table. Certificates are saved
+// in the given directory, if not empty, so they survive restarts.
+func NewRoutingTable(certDir string) (*RoutingTable, error) {
+	t := &RoutingTable{
+		containers: make(map[string][]*route),
+		hosts:      make(map[string][]*route),
+		certs:      make(map[string]*tls.Certificate),
+		certDir:    certDir,
+		parked:     make(map[string]string),
+		waking:     make(map[string]*wakeCall),
+		activity:   make(map[string]time.Time),
+	}
+	if certDir != "" {
+		if err := t.loadCertificates(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *RoutingTable) Close() error {
+	return nil
+}
+
+func (t *RoutingTable) AddEndpoints(id string, endpoints []*manifest.Endpoint) error {
+	routes, err := newRoutes(id, endpoints, 1)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.containers[id] = routes
+	t.unparkRoutes(routes)
+	t.rebuild()
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *RoutingTable) RemoveEndpoints(id string) error {
+	t.mu.Lock()
+	delete(t.containers, id)
+	t.rebuild()
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *RoutingTable) SwitchEndpoints(old []string, endpoints map[string][]*manifest.Endpoint) error {
+	added := make(map[string][]*route)
+	for id, eps := range endpoints {
+		routes, err := newRoutes(id, eps, 1)
+		if err != nil {
+			return err
+		}
+		added[id] = routes
+	}
+
+	t.mu.Lock()
+	for _, id := range old {
+		delete(t.containers, id)
+	}
+	for id, routes := range added {
+		t.containers[id] = routes
+		t.unparkRoutes(routes)
+	}
+	t.rebuild()
+	t.mu.Unlock()
+	return nil
+}
+
+// SplitEndpoints replaces routes of stable and canary containers. The
+// weight of each backend is calculated the same way as hipache proxy.
+func (t *RoutingTable) SplitEndpoints(stable, canary map[string][]*manifest.Endpoint, weight int) error {
+	// count stable and canary backends of each frontend
+	counts := make(map[string]*[2]int)
+	for i, eps := range []map[string][]*manifest.Endpoint{stable, canary} {
+		for _, list := range eps {
+			for _, m := range httpMappings(list) {
+				if counts[m[0]] == nil {
+					counts[m[0]] = new([2]int)
+				}
+				counts[m[0]][i]++
+			}
+		}
+	}
+
+	added := make(map[string][]*route)
+	for i, eps := range []map[string][]*manifest.Endpoint{stable, canary} {
+		for id, list := range eps {
+			for _, m := range httpMappings(list) {
+				copies := splitCopies(counts[m[0]][0], counts[m[0]][1], weight)[i]
+				if copies == 0 {
+					continue
+				}
+				r, err := newRoute(id, m[0], m[1], copies)
+				if err != nil {
+					return err
+				}
+				added[id] = append(added[id], r)
+			}
+		}
+	}
+
+	t.mu.Lock()
+	for _, eps := range []map[string][]*manifest.Endpoint{stable, canary} {
+		for id := range eps {
+			t.containers[id] = added[id]
+			t.unparkRoutes(added[id])
+		}
+	}
+	t.rebuild()
+	t.mu.Unlock()
+	return nil
+}
+
+// Reset removes all routes, certificates are kept.
+func (t *RoutingTable) Reset() error {
+	t.mu.Lock()
+	t.containers = make(map[string][]*route)
+	t.rebuild()
+	t.mu.Unlock()
+	return nil
+}
+
+func newRoutes(id string, endpoints []*manifest.Endpoint, weight int) ([]*route, error) {
+	var routes []*route
+	for _, m := range httpMappings(endpoints) {
+		r, err := newRoute(id, m[0], m[1], weight)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// newRoute creates a route from the frontend and backend pair returned
+// from httpMappings. The route is associated to the container id.
+func newRoute(id, frontend, backend string, weight int) (*route, error) {
+	var path string
+	if i := strings.IndexRune(backend, '#'); i != -1 {
+		backend, path = backend[:i], backend[i+1:]
+	}
+
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("invalid backend URL: " + backend)
+	}
+
+	return &route{
+		id:      id,
+		host:    strings.ToLower(frontend),
+		path:    path,
+		backend: u,
+		proxy:   httputil.NewSingleHostReverseProxy(u),
+		weight:  weight,
+	}, nil
+}
+
+// rebuild the host index, must be called with the lock held.
+func (t *RoutingTable) rebuild() {
+	hosts := make(map[string][]*route)
+	for _, routes := range t.containers {
+		for _, r := range routes {
+			hosts[r.host] = append(hosts[r.host], r)
+		}
+	}
+
+	// match longer paths first
+	for _, routes := range hosts {
+		sort.Stable(byPathLength(routes))
+	}
+	t.hosts = hosts
+}
+
+type byPathLength []*route
+
+func (a byPathLength) Len() int           { return len(a) }
+func (a byPathLength) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPathLength) Less(i, j int) bool { return len(a[i].path) > len(a[j].path) }
+
+// lookup returns the route of the request. A backend is selected randomly
+// among backends of the longest matching path, in proportion to weight.
+func (t *RoutingTable) lookup(host, path string) *route {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	routes := t.hosts[host]
+	if routes == nil {
+		if i := strings.IndexRune(host, '.'); i != -1 {
+			routes = t.hosts["*"+host[i:]]
+		}
+	}
+
+	var candidates []*route
+	var total int
+	for _, r := range routes {
+		if len(candidates) != 0 && len(r.path) < len(candidates[0].path) {
+			break
+		}
+		if r.path == "" || path == r.path || strings.HasPrefix(path, r.path+"/") {
+			candidates = append(candidates, r)
+			total += r.weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.Intn(total)
+	for _, r := range candidates {
+		if n < r.weight {
+			return r
+		}
+		n -= r.weight
+	}
+	return nil
+}
+
+func (t *RoutingTable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	r := t.lookup(host, req.URL.Path)
+	if r == nil {
+		key := t.parkedKey(host)
+		if key == "" || t.Wake == nil {
+			http.Error(w, "No application configured for "+host, http.StatusBadGateway)
+			return
+		}
+
+		var err error
+		if r, err = t.wake(key, host, req.URL.Path); err != nil {
+			if err == errWakeTimeout {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			} else {
+				http.Error(w, "Failed to start application for "+host, http.StatusBadGateway)
+			}
+			return
+		}
+	}
+	t.touch(r.id)
+	r.proxy.ServeHTTP(w, req)
+}
+
+// GetCertificate returns the certificate for the TLS connection, it's used
+// in the tls.Config.
+func (t *RoutingTable) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.ToLower(hello.ServerName)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if cert := t.certs[host]; cert != nil {
+		return cert, nil
+	}
+	if i := strings.IndexRune(host, '.'); i != -1 {
+		if cert := t.certs["*"+host[i:]]; cert != nil {
+			return cert, nil
+		}
+	}
+	return nil, errors.New("no certificate for " + host)
+}
+
+func (t *RoutingTable) SetCertificate(domain string, cert, key []byte) error {
+	if !validCertName(domain) {
+		return errors.New("invalid domain name: " + domain)
+	}
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	if t.certDir != "" {
+		data := append(append(append([]byte{}, cert...), '\n'), key...)
+		if err = ioutil.WriteFile(filepath.Join(t.certDir, domain+".pem"), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.certs[domain] = &pair
+	t.mu.Unlock()
+	logrus.Debugf("add certificate for %s", domain)
+	return nil
+}
+
+func (t *RoutingTable) RemoveCertificate(domain string) error {
+	if !validCertName(domain) {
+		return errors.New("invalid domain name: " + domain)
+	}
+	if t.certDir != "" {
+		err := os.Remove(filepath.Join(t.certDir, domain+".pem"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	delete(t.certs, domain)
+	t.mu.Unlock()
+	logrus.Debugf("remove certificate for %s", domain)
+	return nil
+}
+
+func (t *RoutingTable) loadCertificates() error {
+	if err := os.MkdirAll(t.certDir, 0700); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(t.certDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		pair, err := tls.X509KeyPair(data, data)
+		if err != nil {
+			logrus.WithError(err).Errorf("Invalid certificate file %s", file)
+			continue
+		}
+		t.certs[strings.TrimSuffix(filepath.Base(file), ".pem")] = &pair
+	}
+	return nil
+}
+
+// validCertName checks the domain name can be used as a file name.
+func validCertName(domain string) bool {
+	return domain != "" && !strings.HasPrefix(domain, ".") && !strings.ContainsAny(domain, "/\\")
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+func endpoints(frontend, backend string) []*manifest.Endpoint {
+	return []*manifest.Endpoint{{
+		ProxyMappings: []*manifest.ProxyMapping{{
+			Frontend: frontend,
+			Backend:  backend,
+			Protocol: "http",
+		}},
+	}}
+}
+
+func backendServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path))
+	}))
+}
+
+func get(t *testing.T, h http.Handler, host, path string) (int, string) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://"+host+path, nil)
+	h.ServeHTTP(w, r)
+	body, _ := ioutil.ReadAll(w.Body)
+	return w.Code, string(body)
+}
+
+func TestRoutingTable(t *testing.T) {
+	app, acme := backendServer("app"), backendServer("acme")
+	defer app.Close()
+	defer acme.Close()
+
+	table, err := NewRoutingTable("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// register routes through the admin API
+	admin := httptest.NewServer(NewAdminHandler(table))
+	defer admin.Close()
+	u, _ := url.Parse(admin.URL)
+	px, err := proxyRegistry["builtin"](&url.URL{Scheme: "builtin", Host: u.Host})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = px.AddEndpoints("c1", endpoints("app.example.com", app.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if err = px.AddEndpoints("acme", endpoints("app.example.com/.well-known/acme-challenge", acme.URL+"/api")); err != nil {
+		t.Fatal(err)
+	}
+	if err = px.AddEndpoints("wild", endpoints("*.example.org", app.URL)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"app.example.com", "/index.html", 200, "app /index.html"},
+		{"APP.example.com:80", "/", 200, "app /"},
+		{"app.example.com", "/.well-known/acme-challenge/token", 200, "acme /api/.well-known/acme-challenge/token"},
+		{"app.example.com", "/.well-known/acme-challenge-other", 200, "app /.well-known/acme-challenge-other"},
+		{"www.example.org", "/", 200, "app /"},
+		{"unknown.example.com", "/", http.StatusBadGateway, ""},
+	}
+	for _, tt := range tests {
+		code, body := get(t, table, tt.host, tt.path)
+		if code != tt.code || (tt.body != "" && body != tt.body) {
+			t.Errorf("%s%s: got %d %q, want %d %q", tt.host, tt.path, code, body, tt.code, tt.body)
+		}
+	}
+
+	if err = px.RemoveEndpoints("acme"); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, table, "app.example.com", "/.well-known/acme-challenge/token"); body != "app /.well-known/acme-challenge/token" {
+		t.Errorf("route not removed: %q", body)
+	}
+
+	if err = px.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get(t, table, "app.example.com", "/"); code != http.StatusBadGateway {
+		t.Errorf("routes not reset: %d", code)
+	}
+}
+
+func TestRoutingTableSplit(t *testing.T) {
+	stable, canary := backendServer("stable"), backendServer("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	table, _ := NewRoutingTable("")
+	err := table.SplitEndpoints(
+		map[string][]*manifest.Endpoint{"s": endpoints("app.example.com", stable.URL)},
+		map[string][]*manifest.Endpoint{"c": endpoints("app.example.com", canary.URL)},
+		25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		_, body := get(t, table, "app.example.com", "/")
+		counts[body]++
+	}
+	if n := counts["canary /"]; n < 150 || n > 350 {
+		t.Errorf("canary received %d of 1000 requests, want about 250", n)
+	}
+
+	err = table.SwitchEndpoints([]string{"s"}, map[string][]*manifest.Endpoint{"c": endpoints("app.example.com", canary.URL)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, body := get(t, table, "app.example.com", "/"); body != "canary /" {
+			t.Fatalf("got %q after switch", body)
+		}
+	}
+}
+
+func TestRoutingTableWake(t *testing.T) {
+	app := backendServer("app")
+	defer app.Close()
+
+	table, _ := NewRoutingTable("")
+	table.WakeTimeout = 5 * time.Second
+	table.Wake = func(key string) error {
+		if key != "app-demo" {
+			return errors.New("unknown application " + key)
+		}
+		// routes are added asynchronously like container events
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			table.AddEndpoints("c1", endpoints("app.example.com", app.URL))
+		}()
+		return nil
+	}
+
+	table.Park("app-demo", []string{"app.example.com"})
+	table.Park("other-demo", []string{"other.example.com"})
+
+	if code, body := get(t, table, "app.example.com", "/"); code != 200 || body != "app /" {
+		t.Fatalf("got %d %q after wake up, want 200 %q", code, body, "app /")
+	}
+	if key := table.parkedKey("app.example.com"); key != "" {
+		t.Errorf("host still parked after routed: %q", key)
+	}
+	activity, _ := table.Activity()
+	if _, ok := activity["c1"]; !ok {
+		t.Errorf("request not tracked: %v", activity)
+	}
+
+	if code, _ := get(t, table, "other.example.com", "/"); code != http.StatusBadGateway {
+		t.Errorf("got %d when wake up failed, want %d", code, http.StatusBadGateway)
+	}
+	if key := table.parkedKey("other.example.com"); key != "" {
+		t.Errorf("host still parked after wake up failed: %q", key)
+	}
+
+	table.RemoveEndpoints("c1")
+	if activity, _ = table.Activity(); len(activity) != 0 {
+		t.Errorf("removed container still tracked: %v", activity)
+	}
+}
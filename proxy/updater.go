@@ -1,8 +1,6 @@
 package proxy
 
 import (
-	"encoding/json"
-	"io"
 	"os"
 	"os/signal"
 	"strings"
@@ -10,8 +8,6 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/engine-api/types"
-	"github.com/docker/engine-api/types/events"
-	"github.com/docker/engine-api/types/filters"
 	"golang.org/x/net/context"
 
 	"github.com/cloudway/platform/config"
@@ -48,12 +44,22 @@ func RunUpdater(cli container.DockerClient, proxy Proxy) error {
 	if err := update(proxy); err != nil {
 		return err
 	}
-	if err := rebuild(cli, proxy); err != nil {
-		return err
+
+	// Routes are rebuilt whenever connected to Docker events, so routes of
+	// containers started while disconnected are registered again. Only
+	// containers on the local Docker host are routed.
+	watcher := container.NewEventWatcher(container.NewClient(cli.Client))
+	watcher.OnResync = func(cli container.DockerClient) {
+		if err := rebuild(cli, proxy); err != nil {
+			logrus.Error(err)
+		}
 	}
-	if err := listen(cli, proxy); err != nil {
-		return err
+	watcher.OnEvent = func(e *container.ContainerEvent) {
+		if err := handleEvent(cli, proxy, e); err != nil {
+			logrus.Error(err)
+		}
 	}
+	watcher.Run(nil)
 	return nil
 }
 
@@ -109,64 +115,39 @@ func rebuild(cli container.DockerClient, proxy Proxy) error {
 	return nil
 }
 
-func listen(cli container.DockerClient, proxy Proxy) error {
-	var ctx = context.Background()
-	var err error
-
-	filters := filters.NewArgs()
-	filters.Add("type", "container")
-	filters.Add("event", "start")
-	filters.Add("event", "die")
-	filters.Add("event", "destroy")
-
-	resp, err := cli.Events(ctx, types.EventsOptions{Filters: filters})
-	if err != nil {
-		return err
-	}
-	defer resp.Close()
+func handleEvent(cli container.DockerClient, proxy Proxy, e *container.ContainerEvent) error {
+	ctx := context.Background()
 
-	dec := json.NewDecoder(resp)
-	for {
-		var event events.Message
-		err = dec.Decode(&event)
-		if err == io.EOF {
-			break
+	switch e.Action {
+	case container.ContainerStarted:
+		if e.Name != "" && e.Namespace != "" {
+			logrus.Debugf("container started: %s", e.ID)
+			c, err := cli.Inspect(ctx, e.ID)
+			if err != nil {
+				return err
+			}
+			return handleStart(proxy, ctx, c)
 		}
+		info, err := cli.ContainerInspect(ctx, e.ID)
 		if err != nil {
 			return err
 		}
+		return handleVirtualHost(proxy, info)
 
-		switch event.Action {
-		case "start":
-			_, ok1 := event.Actor.Attributes[container.APP_NAME_KEY]
-			_, ok2 := event.Actor.Attributes[container.APP_NAMESPACE_KEY]
-			if ok1 && ok2 {
-				logrus.Debugf("container started: %s", event.Actor.ID)
-				c, err := cli.Inspect(ctx, event.Actor.ID)
-				if err == nil {
-					err = handleStart(proxy, ctx, c)
-				}
-			} else {
-				info, err := cli.ContainerInspect(ctx, event.Actor.ID)
-				if err == nil {
-					err = handleVirtualHost(proxy, info)
-				}
-			}
-
-		case "die", "destroy":
-			logrus.Debugf("container stopped: %s", event.Actor.ID)
-			err = handleStop(proxy, event.Actor.ID)
-		}
-
-		if err != nil {
-			logrus.Error(err)
-		}
+	case container.ContainerDied, container.ContainerRemoved:
+		logrus.Debugf("container stopped: %s", e.ID)
+		return handleStop(proxy, e.ID)
 	}
-
 	return nil
 }
 
 func handleStart(proxy Proxy, ctx context.Context, c *container.Container) error {
+	// standby containers are added when traffic switched to them
+	if c.IsStandby(ctx) {
+		logrus.Debugf("container on standby: %s", c.ID)
+		return nil
+	}
+
 	// reterieve application info from container
 	info, err := c.GetInfo(ctx, "endpoints")
 	if err != nil {
@@ -40,6 +40,8 @@ func (box *Sandbox) Stop() error {
 }
 
 func (box *Sandbox) Restart() (err error) {
+	defer box.markStarted()
+
 	if box.hasDeployments() {
 		err = box.Stop()
 		if err == nil {
@@ -58,6 +60,35 @@ func (box *Sandbox) Restart() (err error) {
 	return err
 }
 
+// Reload re-reads plugin manifests replaced in the running sandbox.
+// Private endpoints are recreated and environment templates processed
+// again, so metadata changes take effect without rebuilding the container.
+func (box *Sandbox) Reload() error {
+	if os.Getuid() != 0 {
+		return os.ErrPermission
+	}
+
+	plugins, err := box.Plugins()
+	if err != nil {
+		return err
+	}
+	for _, p := range plugins {
+		chownR(filepath.Join(p.Path, "manifest"), 0, box.gid)
+	}
+
+	if err = box.CreatePrivateEndpoints(""); err != nil {
+		return err
+	}
+
+	env := box.Environ()
+	for _, p := range plugins {
+		if err = processTemplates(p.Path, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (box *Sandbox) Control(action string, enable_action_hooks, process_templates bool) error {
 	plugins, err := box.Plugins()
 	if err != nil {
@@ -173,3 +204,12 @@ func MakeExecEnv(env map[string]string) []string {
 		return nil
 	}
 }
+
+// markStarted copies the deployment marker written by the deployer before
+// signaling the restart, so the deployer can tell the restart from the
+// state before the deployment.
+func (box *Sandbox) markStarted() {
+	if marker, err := readEnvFile(box.envfile(".deployed")); err == nil {
+		writeEnvFile(box.envfile(".started"), marker)
+	}
+}
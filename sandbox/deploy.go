@@ -2,13 +2,19 @@ package sandbox
 
 import (
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/cloudway/platform/pkg/archive"
+	"github.com/cloudway/platform/pkg/delta"
 	"github.com/cloudway/platform/pkg/files"
+	"github.com/cloudway/platform/pkg/manifest"
 )
 
 func (box *Sandbox) Build() (err error) {
@@ -19,7 +25,14 @@ func (box *Sandbox) Build() (err error) {
 	if err = processTemplates(primary.Path, box.Environ()); err != nil {
 		return err
 	}
-	return runPluginAction(primary.Path, box.RepoDir(), MakeExecEnv(box.Environ()), "build")
+
+	if err = box.RunHook(manifest.PreBuild); err != nil {
+		return err
+	}
+	if err = runPluginAction(primary.Path, box.RepoDir(), MakeExecEnv(box.Environ()), "build"); err != nil {
+		return err
+	}
+	return box.RunHook(manifest.PostBuild)
 }
 
 func (box *Sandbox) Deploy() error {
@@ -35,7 +48,12 @@ func (box *Sandbox) Deploy() error {
 	defer removeDeployments(base, deployments)
 
 	latest := latestDeployment(deployments)
-	err = box.checkout(latest.Name())
+	if strings.HasSuffix(latest.Name(), ".delta.tar.gz") {
+		err = box.checkoutDelta(latest.Name())
+	} else {
+		err = box.checkout(latest.Name())
+	}
+	box.saveManifest()
 	if err != nil {
 		return err
 	}
@@ -48,7 +66,54 @@ func (box *Sandbox) Deploy() error {
 	if err != nil {
 		return err
 	}
-	return runPluginAction(primary.Path, box.RepoDir(), MakeExecEnv(box.Environ()), "deploy")
+
+	if err = box.RunHook(manifest.PreDeploy); err != nil {
+		return err
+	}
+	if err = runPluginAction(primary.Path, box.RepoDir(), MakeExecEnv(box.Environ()), "deploy"); err != nil {
+		return err
+	}
+	return box.RunHook(manifest.PostDeploy)
+}
+
+// ChecksumError reports that a deployment archive copied into the sandbox
+// doesn't match the digest computed before the transfer.
+type ChecksumError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch of %s: expected sha256:%s, got sha256:%s", e.Name, e.Expected, e.Actual)
+}
+
+// VerifyDeployment verifies the SHA-256 digest of a deployment archive in
+// the deploy directory. A corrupted or truncated archive is removed so it
+// will not be checked out on restart.
+func (box *Sandbox) VerifyDeployment(name, digest string) error {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "deploy") || !strings.HasSuffix(name, ".tar.gz") {
+		return fmt.Errorf("invalid deployment name: %s", name)
+	}
+
+	file := filepath.Join(box.DeployDir(), name)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, digest) {
+		os.Remove(file)
+		return ChecksumError{Name: name, Expected: digest, Actual: actual}
+	}
+	return nil
 }
 
 func (box *Sandbox) hasDeployments() bool {
@@ -125,3 +190,79 @@ func (box *Sandbox) checkout(name string) (err error) {
 
 	return nil
 }
+
+// checkoutDelta applies changed files in the delta archive to a copy of
+// the current repository, and replaces the repository with the copy.
+func (box *Sandbox) checkoutDelta(name string) (err error) {
+	base, err := box.readManifest()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Join(box.DeployDir(), name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	tmpdir, err := ioutil.TempDir("", "deploy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	if err = delta.Clone(box.RepoDir(), tmpdir); err != nil {
+		return err
+	}
+	if err = delta.Apply(tmpdir, base, zr); err != nil {
+		return err
+	}
+	return files.MoveFiles(tmpdir, box.RepoDir())
+}
+
+func (box *Sandbox) manifestFile() string {
+	return filepath.Join(box.HomeDir(), delta.ManifestFile)
+}
+
+func (box *Sandbox) readManifest() (delta.Manifest, error) {
+	f, err := os.Open(box.manifestFile())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return delta.ReadManifest(f)
+}
+
+// saveManifest describes the repository so the next deployment may only
+// transfer changed files. The manifest is removed if the repository can't
+// be described, which forces the next deployment to transfer all files.
+func (box *Sandbox) saveManifest() {
+	filename := box.manifestFile()
+	m, err := delta.Scan(box.RepoDir())
+	if err != nil {
+		os.Remove(filename)
+		return
+	}
+
+	f, err := ioutil.TempFile(box.HomeDir(), delta.ManifestFile)
+	if err != nil {
+		os.Remove(filename)
+		return
+	}
+	_, err = m.WriteTo(f)
+	if er := f.Close(); err == nil {
+		err = er
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		os.Remove(filename)
+	}
+}
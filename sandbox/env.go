@@ -20,6 +20,10 @@ var validEnvKey = regexp.MustCompile(`^[a-zA-Z_0-9]+(\.export)?$`)
 
 const exportSuffix = ".export"
 
+// Secrets set as environment variables are kept in tmpfs, see
+// container.SecretEnvDir.
+const secretEnvDir = "/run/secrets/.env"
+
 func (box *Sandbox) Environ() map[string]string {
 	var env = make(map[string]string)
 
@@ -29,6 +33,9 @@ func (box *Sandbox) Environ() map[string]string {
 	// merge application environment variables
 	loadEnv(env, box.EnvDir(), false)
 
+	// merge secret environment variables
+	loadEnv(env, secretEnvDir, false)
+
 	// Merge plugin environemnt variables
 	loadPluginsEnv(env, box.HomeDir(), false)
 
@@ -0,0 +1,113 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+// HookError reports that a hook failed and aborted the build or deployment.
+type HookError struct {
+	Name   string
+	Source string
+	Err    error
+}
+
+func (e HookError) Error() string {
+	return fmt.Sprintf("The %s hook of %s failed: %v", e.Name, e.Source, e.Err)
+}
+
+type hookSource struct {
+	name  string
+	hooks *manifest.Hooks
+}
+
+// RunHook runs the hook declared by plugins installed in the sandbox and by
+// the application manifest in the repository. Hooks run with shell in the
+// repository directory, and the output is written to the standard output
+// and error, which is the build log in the builder container and the
+// container log in application containers. A failed hook aborts the rest
+// unless its failure policy is "warn".
+func (box *Sandbox) RunHook(name string) error {
+	sources, err := box.hookSources()
+	if err != nil {
+		return err
+	}
+
+	env := MakeExecEnv(box.Environ())
+	for _, src := range sources {
+		h := src.hooks.Get(name)
+		if h == nil {
+			continue
+		}
+		if err = src.hooks.Validate(); err != nil {
+			return HookError{Name: name, Source: src.name, Err: err}
+		}
+
+		fmt.Printf("Running the %s hook of %s\n", name, src.name)
+		if err = box.runHook(h.Command, env); err == nil {
+			continue
+		}
+		if h.Abort() {
+			return HookError{Name: name, Source: src.name, Err: err}
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: The %s hook of %s failed: %v\n", name, src.name, err)
+	}
+	return nil
+}
+
+// hookSources returns hooks of plugins sorted by plugin name, followed by
+// hooks of the application manifest.
+func (box *Sandbox) hookSources() ([]hookSource, error) {
+	plugins, err := box.Plugins()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(plugins))
+	for name, p := range plugins {
+		if p.Hooks != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	sources := make([]hookSource, 0, len(names)+1)
+	for _, name := range names {
+		sources = append(sources, hookSource{"plugin " + name, plugins[name].Hooks})
+	}
+
+	app, err := manifest.LoadApplication(box.RepoDir())
+	if err != nil {
+		return nil, err
+	}
+	if app != nil && app.Hooks != nil {
+		sources = append(sources, hookSource{manifest.AppManifestFile, app.Hooks})
+	}
+	return sources, nil
+}
+
+func (box *Sandbox) runHook(command string, env []string) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stdin = nil
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.Dir = box.RepoDir()
+	return reaper.RunCmd(cmd)
+}
+
+// Migrate runs the database migration declared in the application manifest
+// of the repository.
+func (box *Sandbox) Migrate() error {
+	app, err := manifest.LoadApplication(box.RepoDir())
+	if err != nil {
+		return err
+	}
+	if app == nil || app.Migrate == nil {
+		return nil
+	}
+	return box.runHook(app.Migrate.Command, MakeExecEnv(box.Environ()))
+}
@@ -6,6 +6,7 @@ import (
 	"syscall"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/cloudway/platform/pkg/manifest"
 	"github.com/cloudway/platform/pkg/reap"
 )
 
@@ -31,14 +32,15 @@ func (box *Sandbox) Run() error {
 	}()
 
 	// handle termination signals
+	deploySig := box.deploySignal()
 	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1)
+	signal.Notify(sigchan, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1, deploySig)
 
 	UpdateActiveState(box.ActiveState())
 
 	for sig := range sigchan {
 		switch sig {
-		case syscall.SIGHUP:
+		case syscall.SIGHUP, deploySig:
 			logrus.Infof("received signal: %s", sig)
 			err := box.Restart()
 			if err == nil {
@@ -58,3 +60,22 @@ func (box *Sandbox) Run() error {
 
 	return nil
 }
+
+var deploySignals = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// deploySignal returns the signal declared by the primary plugin to notify
+// the container to complete the deployment, in addition to SIGHUP.
+func (box *Sandbox) deploySignal() syscall.Signal {
+	if primary, err := box.PrimaryPlugin(); err == nil {
+		if n := primary.DeployNotify; n != nil && n.Type == manifest.NotifySignal {
+			if sig, ok := deploySignals[n.DeploySignal()]; ok {
+				return sig
+			}
+		}
+	}
+	return syscall.SIGHUP
+}
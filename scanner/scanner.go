@@ -0,0 +1,245 @@
+// Package scanner scans plugin images for known vulnerabilities, and
+// blocks applications from being created with vulnerable images.
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/pkg/manifest"
+	"github.com/cloudway/platform/pkg/serverlog"
+)
+
+// Vulnerability severities in increasing order.
+const (
+	SeverityUnknown  = "unknown"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+var severities = []string{SeverityUnknown, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ValidSeverity returns true if the severity is known.
+func ValidSeverity(severity string) bool {
+	return severityLevel(severity) >= 0
+}
+
+func severityLevel(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// NormalizeSeverity converts the severity reported by a scanner to one of
+// the known severities.
+func NormalizeSeverity(severity string) string {
+	severity = strings.ToLower(severity)
+	switch severity {
+	case "negligible":
+		return SeverityLow
+	case "moderate":
+		return SeverityMedium
+	case "important":
+		return SeverityHigh
+	case "defcon1":
+		return SeverityCritical
+	}
+	if ValidSeverity(severity) {
+		return severity
+	}
+	return SeverityUnknown
+}
+
+// Vulnerability is a known vulnerability found in a package of the image.
+type Vulnerability struct {
+	ID           string
+	Package      string
+	Version      string
+	FixedVersion string `json:",omitempty"`
+	Severity     string
+	Title        string `json:",omitempty"`
+	Link         string `json:",omitempty"`
+}
+
+// Report contains vulnerabilities found in an image.
+type Report struct {
+	Image           string
+	Scanner         string
+	ScannedAt       time.Time
+	Vulnerabilities []*Vulnerability
+}
+
+// Summary returns the number of vulnerabilities of each severity.
+func (r *Report) Summary() map[string]int {
+	summary := make(map[string]int)
+	for _, v := range r.Vulnerabilities {
+		summary[v.Severity]++
+	}
+	return summary
+}
+
+// CountFrom returns the number of vulnerabilities with severity not lower
+// than the given severity.
+func (r *Report) CountFrom(severity string) (n int) {
+	level := severityLevel(severity)
+	for _, v := range r.Vulnerabilities {
+		if severityLevel(v.Severity) >= level {
+			n++
+		}
+	}
+	return n
+}
+
+// Scanner scans images for known vulnerabilities.
+type Scanner interface {
+	// Scan the image, which is available on the Docker host or pulled
+	// from a registry by the scanner.
+	Scan(ctx context.Context, image string) (*Report, error)
+}
+
+// New creates the scanner configured by "scanner.type", returns nil if
+// image scanning is not enabled. Other scanners are registered by chaining
+// this function.
+var New = func() (Scanner, error) {
+	switch typ := config.Get("scanner.type"); typ {
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unsupported image scanner: %s", typ)
+	}
+}
+
+// ReportEntry is the scan report saved with the installed plugin.
+const ReportEntry = "scan.json"
+
+// SaveReport saves the scan report in the plugin directory.
+func SaveReport(dir string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ReportEntry), data, 0644)
+}
+
+// LoadReport loads the scan report saved in the plugin directory, returns
+// nil if the plugin was not scanned.
+func LoadReport(dir string) (*Report, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ReportEntry))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r Report
+	if err = json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ScanPlugin scans the base image of the plugin in the directory, and saves
+// the report with the plugin. The scan is limited by "scanner.timeout".
+func ScanPlugin(ctx context.Context, s Scanner, dir string, log *serverlog.ServerLog) (*Report, error) {
+	meta, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if meta.BaseImage == "" {
+		return nil, nil
+	}
+
+	timeout, err := time.ParseDuration(config.GetOrDefault("scanner.timeout", "10m"))
+	if err != nil || timeout <= 0 {
+		logrus.Warnf("Invalid scanner.timeout configuration: %s", config.Get("scanner.timeout"))
+		timeout = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fmt.Fprintf(log, "Scanning image %s for vulnerabilities\n", meta.BaseImage)
+	r, err := s.Scan(ctx, meta.BaseImage)
+	if err != nil {
+		return nil, err
+	}
+	if r.Image == "" {
+		r.Image = meta.BaseImage
+	}
+	if r.ScannedAt.IsZero() {
+		r.ScannedAt = time.Now().UTC()
+	}
+
+	summary := r.Summary()
+	fmt.Fprintf(log, "Found %d vulnerabilities (critical: %d, high: %d, medium: %d, low: %d, unknown: %d)\n",
+		len(r.Vulnerabilities), summary[SeverityCritical], summary[SeverityHigh],
+		summary[SeverityMedium], summary[SeverityLow], summary[SeverityUnknown])
+
+	return r, SaveReport(dir, r)
+}
+
+// BlockSeverity returns the minimum severity of vulnerabilities that block
+// applications from being created with the image, configured by
+// "scanner.block_severity". Returns an empty string if nothing is blocked.
+func BlockSeverity() string {
+	severity := strings.ToLower(config.Get("scanner.block_severity"))
+	if severity == "" || severity == "none" {
+		return ""
+	}
+	if !ValidSeverity(severity) {
+		logrus.Warnf("Invalid scanner.block_severity configuration: %s", config.Get("scanner.block_severity"))
+		return ""
+	}
+	return severity
+}
+
+// Check returns a VulnerableImageError if the scan report of the plugin
+// contains vulnerabilities not lower than the block severity. Plugins not
+// scanned are not blocked.
+func Check(plugin *manifest.Plugin) error {
+	severity := BlockSeverity()
+	if severity == "" || plugin.Path == "" {
+		return nil
+	}
+
+	r, err := LoadReport(plugin.Path)
+	if err != nil || r == nil {
+		return err
+	}
+	if n := r.CountFrom(severity); n != 0 {
+		return VulnerableImageError{Plugin: plugin.Name, Image: r.Image, Severity: severity, Count: n}
+	}
+	return nil
+}
+
+// VulnerableImageError reports that the plugin image contains vulnerabilities
+// not lower than the block severity.
+type VulnerableImageError struct {
+	Plugin   string
+	Image    string
+	Severity string
+	Count    int
+}
+
+func (e VulnerableImageError) Error() string {
+	return fmt.Sprintf("%s: image %s has %d vulnerabilities of %s or higher severity", e.Plugin, e.Image, e.Count, e.Severity)
+}
+
+func (e VulnerableImageError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
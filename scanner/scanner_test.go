@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/manifest"
+)
+
+func TestScanner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Image Scanner Suite")
+}
+
+type fakeScanner struct {
+	vulnerabilities []*Vulnerability
+	scanned         []string
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, image string) (*Report, error) {
+	s.scanned = append(s.scanned, image)
+	return &Report{Scanner: "fake", Vulnerabilities: s.vulnerabilities}, nil
+}
+
+var _ = Describe("Severity", func() {
+	It("should normalize severities reported by scanners", func() {
+		Expect(NormalizeSeverity("CRITICAL")).To(Equal(SeverityCritical))
+		Expect(NormalizeSeverity("Negligible")).To(Equal(SeverityLow))
+		Expect(NormalizeSeverity("Important")).To(Equal(SeverityHigh))
+		Expect(NormalizeSeverity("whatever")).To(Equal(SeverityUnknown))
+	})
+
+	It("should count vulnerabilities not lower than the severity", func() {
+		r := &Report{Vulnerabilities: []*Vulnerability{
+			{ID: "CVE-1", Severity: SeverityLow},
+			{ID: "CVE-2", Severity: SeverityHigh},
+			{ID: "CVE-3", Severity: SeverityCritical},
+		}}
+		Expect(r.CountFrom(SeverityHigh)).To(Equal(2))
+		Expect(r.CountFrom(SeverityCritical)).To(Equal(1))
+		Expect(r.Summary()).To(Equal(map[string]int{SeverityLow: 1, SeverityHigh: 1, SeverityCritical: 1}))
+	})
+})
+
+var _ = Describe("Plugin scan", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "plugin")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(dir, "manifest"), 0755)).To(Succeed())
+		Expect(manifest.Save(dir, &manifest.Plugin{
+			Name:      "mock",
+			Version:   "1.0",
+			Category:  manifest.Framework,
+			BaseImage: "debian:jessie",
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should scan the base image and save the report", func() {
+		s := &fakeScanner{vulnerabilities: []*Vulnerability{{ID: "CVE-1", Severity: SeverityCritical}}}
+		r, err := ScanPlugin(context.Background(), s, dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.scanned).To(Equal([]string{"debian:jessie"}))
+		Expect(r.Image).To(Equal("debian:jessie"))
+		Expect(r.ScannedAt.IsZero()).To(BeFalse())
+
+		saved, err := LoadReport(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved.Image).To(Equal("debian:jessie"))
+		Expect(saved.Vulnerabilities).To(HaveLen(1))
+	})
+
+	It("should return nil report if the plugin is not scanned", func() {
+		r, err := LoadReport(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r).To(BeNil())
+	})
+})
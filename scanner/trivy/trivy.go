@@ -0,0 +1,119 @@
+package trivy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/scanner"
+)
+
+// Image scanner that runs the Trivy command, configured by scanner.trivy.path.
+// If scanner.trivy.server is configured, Trivy runs in client mode and
+// images are scanned by the Trivy server.
+type trivy struct {
+	path   string
+	server string
+}
+
+func init() {
+	prev := scanner.New
+	scanner.New = func() (scanner.Scanner, error) {
+		if config.Get("scanner.type") != "trivy" {
+			return prev()
+		}
+
+		path, err := exec.LookPath(config.GetOrDefault("scanner.trivy.path", "trivy"))
+		if err != nil {
+			return nil, err
+		}
+		return &trivy{path: path, server: config.Get("scanner.trivy.server")}, nil
+	}
+}
+
+// result is the scan result of a target in the Trivy JSON report.
+type result struct {
+	Target          string
+	Vulnerabilities []struct {
+		VulnerabilityID  string
+		PkgName          string
+		InstalledVersion string
+		FixedVersion     string
+		Severity         string
+		Title            string
+		PrimaryURL       string
+	}
+}
+
+func (t *trivy) Scan(ctx context.Context, image string) (*scanner.Report, error) {
+	args := []string{"image", "--quiet", "--format", "json"}
+	if t.server != "" {
+		args = append(args, "--server", t.server)
+	}
+	args = append(args, image)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(t.path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := run(ctx, cmd); err != nil {
+		return nil, fmt.Errorf("trivy: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseReport(image, stdout.Bytes())
+}
+
+// run the command, killing it if the context is done.
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// parseReport parses the Trivy JSON report, which is an object containing
+// results in recent versions, or an array of results in earlier versions.
+func parseReport(image string, data []byte) (*scanner.Report, error) {
+	var results []result
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, err
+		}
+	} else {
+		var report struct{ Results []result }
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, err
+		}
+		results = report.Results
+	}
+
+	r := &scanner.Report{Image: image, Scanner: "trivy"}
+	for _, res := range results {
+		for _, v := range res.Vulnerabilities {
+			r.Vulnerabilities = append(r.Vulnerabilities, &scanner.Vulnerability{
+				ID:           v.VulnerabilityID,
+				Package:      v.PkgName,
+				Version:      v.InstalledVersion,
+				FixedVersion: v.FixedVersion,
+				Severity:     scanner.NormalizeSeverity(v.Severity),
+				Title:        v.Title,
+				Link:         v.PrimaryURL,
+			})
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,52 @@
+package trivy
+
+import (
+	"testing"
+
+	"github.com/cloudway/platform/scanner"
+)
+
+func TestParseReport(t *testing.T) {
+	reports := map[string]string{
+		"object": `{
+  "SchemaVersion": 2,
+  "ArtifactName": "debian:jessie",
+  "Results": [{
+    "Target": "debian:jessie (debian 8.11)",
+    "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2019-1", "PkgName": "openssl", "InstalledVersion": "1.0.1t", "FixedVersion": "1.0.1u", "Severity": "CRITICAL", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2019-1"},
+      {"VulnerabilityID": "CVE-2019-2", "PkgName": "bash", "InstalledVersion": "4.3", "Severity": "LOW"}
+    ]
+  }, {
+    "Target": "node-pkg"
+  }]
+}`,
+		"array": `[{
+  "Target": "debian:jessie (debian 8.11)",
+  "Vulnerabilities": [
+    {"VulnerabilityID": "CVE-2019-1", "PkgName": "openssl", "InstalledVersion": "1.0.1t", "FixedVersion": "1.0.1u", "Severity": "CRITICAL"},
+    {"VulnerabilityID": "CVE-2019-2", "PkgName": "bash", "InstalledVersion": "4.3", "Severity": "LOW"}
+  ]
+}]`,
+	}
+
+	for format, data := range reports {
+		r, err := parseReport("debian:jessie", []byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if r.Image != "debian:jessie" || r.Scanner != "trivy" {
+			t.Errorf("%s: unexpected report %+v", format, r)
+		}
+		if len(r.Vulnerabilities) != 2 {
+			t.Fatalf("%s: got %d vulnerabilities, want 2", format, len(r.Vulnerabilities))
+		}
+		v := r.Vulnerabilities[0]
+		if v.ID != "CVE-2019-1" || v.Package != "openssl" || v.FixedVersion != "1.0.1u" || v.Severity != scanner.SeverityCritical {
+			t.Errorf("%s: unexpected vulnerability %+v", format, v)
+		}
+		if r.CountFrom(scanner.SeverityHigh) != 1 {
+			t.Errorf("%s: CountFrom(high) = %d, want 1", format, r.CountFrom(scanner.SeverityHigh))
+		}
+	}
+}
@@ -13,7 +13,9 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/cloudway/platform/api/types"
 	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/rest"
 	"github.com/cloudway/platform/pkg/serverlog"
 	"github.com/cloudway/platform/scm"
@@ -249,15 +251,23 @@ func (cli *bitbucketClient) PopulateURL(namespace, name, remote string) error {
 	return checkNamespaceError(namespace, resp, err)
 }
 
-func (cli *bitbucketClient) Deploy(namespace, name string, branch string, log *serverlog.ServerLog) error {
+func (cli *bitbucketClient) Deploy(ctx context.Context, namespace, name string, branch string, opts container.DeployOptions, log *serverlog.ServerLog) error {
 	if log == nil {
 		log = serverlog.Discard
 	}
 
 	path := fmt.Sprintf("/rest/deploy/1.0/projects/%s/repos/%s/deploy", namespace, name)
 	query := url.Values{"branch": []string{branch}}
-	resp, err := cli.Post(context.Background(), path, query, nil, nil)
+	opts.Encode(query)
+	var headers map[string][]string
+	if env := opts.EncodeBuildEnv(); env != "" {
+		headers = map[string][]string{types.BuildEnvHeader: {env}}
+	}
+	resp, err := cli.Post(ctx, path, query, nil, headers)
 	if err != nil {
+		if resp.StatusCode == http.StatusUnprocessableEntity {
+			return scm.RefNotFoundError(branch)
+		}
 		return checkNamespaceError(namespace, resp, err)
 	} else {
 		defer resp.Body.Close()
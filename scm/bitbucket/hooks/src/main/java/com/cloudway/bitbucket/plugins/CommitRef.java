@@ -0,0 +1,44 @@
+/**
+ * Cloudway Platform
+ * Copyright (c) 2012-2016 Cloudway Technology, Inc.
+ * All rights reserved.
+ */
+
+package com.cloudway.bitbucket.plugins;
+
+import com.atlassian.bitbucket.repository.Ref;
+import com.atlassian.bitbucket.repository.RefType;
+
+/**
+ * A deployment ref pointing to a commit that is neither a branch nor a
+ * tag. A commit has no ref type, which is reported as "COMMIT".
+ */
+public class CommitRef implements Ref {
+    public static final String TYPE = "COMMIT";
+
+    private final String commitId;
+
+    public CommitRef(String commitId) {
+        this.commitId = commitId;
+    }
+
+    @Override
+    public String getId() {
+        return commitId;
+    }
+
+    @Override
+    public String getDisplayId() {
+        return commitId.substring(0, 7);
+    }
+
+    @Override
+    public String getLatestCommit() {
+        return commitId;
+    }
+
+    @Override
+    public RefType getType() {
+        return null;
+    }
+}
@@ -0,0 +1,18 @@
+/**
+ * Cloudway Platform
+ * Copyright (c) 2012-2016 Cloudway Technology, Inc.
+ * All rights reserved.
+ */
+
+package com.cloudway.bitbucket.plugins;
+
+/**
+ * Thrown when the requested branch, tag or commit does not exist.
+ */
+public class RefNotFoundException extends Exception {
+    private static final long serialVersionUID = 1L;
+
+    public RefNotFoundException(String refId) {
+        super("The branch, tag or commit '" + refId + "' does not exists");
+    }
+}
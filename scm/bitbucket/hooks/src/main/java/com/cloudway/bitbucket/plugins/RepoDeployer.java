@@ -16,10 +16,14 @@ import java.nio.channels.Pipe;
 import java.nio.file.Files;
 import java.nio.file.Path;
 import java.nio.file.attribute.PosixFilePermission;
+import java.util.ArrayList;
+import java.util.Collections;
 import java.util.EnumSet;
+import java.util.List;
 import java.util.Set;
 import java.util.logging.Level;
 import java.util.logging.Logger;
+import java.util.regex.Pattern;
 import java.util.zip.GZIPOutputStream;
 
 import com.atlassian.bitbucket.hook.HookRequestHandle;
@@ -60,6 +64,11 @@ public class RepoDeployer
 
     public static final String HOOK_KEY = "com.cloudway.bitbucket.plugins.repo-deployer:repo-deployer";
 
+    // The request header containing encoded build variables, which are
+    // passed to cwman in environment rather than command line arguments.
+    public static final String BUILD_ENV_HEADER = "X-Cloudway-Build-Env";
+    private static final String BUILD_ENV_VAR = "CLOUDWAY_BUILD_ENV";
+
     private static final Logger logger = Logger.getLogger(RepoDeployer.class.getName());
     static {
         if (System.getenv("CLOUDWAY_DEBUG_HOOK") != null) {
@@ -94,6 +103,10 @@ public class RepoDeployer
         return getDeploymentBranch(repository, settings);
     }
 
+    /**
+     * Returns the deployment branch, tag or commit of the repository. The
+     * default branch is returned if the deployed ref no longer exists.
+     */
     public Ref getDeploymentBranch(Repository repository, Settings settings) {
         if (repoService.isEmpty(repository)) {
             return defaultRef;
@@ -106,7 +119,7 @@ public class RepoDeployer
             refId = settings.getString("branch");
         }
         if (refId != null && !refId.isEmpty()) {
-            ref = refService.resolveRef(repository, refId);
+            ref = resolveRef(repository, refId);
         }
         if (ref == null) {
             ref = refService.getDefaultBranch(repository);
@@ -114,17 +127,25 @@ public class RepoDeployer
         return ref;
     }
 
-    public Ref setDeploymentBranch(Repository repository, String refId) {
+    /**
+     * Sets the deployment branch, tag or commit of the repository. The
+     * default branch is used if the ref is empty.
+     *
+     * @throws RefNotFoundException if the ref does not exist
+     */
+    public Ref setDeploymentBranch(Repository repository, String refId) throws RefNotFoundException {
         if (repoService.isEmpty(repository)) {
             return defaultRef;
         }
 
-        Ref ref = null;
+        Ref ref;
 
         if (refId != null && !refId.isEmpty()) {
-            ref = refService.resolveRef(repository, refId);
-        }
-        if (ref == null) {
+            ref = resolveRef(repository, refId);
+            if (ref == null) {
+                throw new RefNotFoundException(refId);
+            }
+        } else {
             ref = refService.getDefaultBranch(repository);
         }
 
@@ -134,7 +155,55 @@ public class RepoDeployer
         return ref;
     }
 
+    private Ref resolveRef(Repository repository, String refId) {
+        Ref ref = refService.resolveRef(repository, refId);
+        if (ref == null) {
+            ref = resolveCommit(repository, refId);
+        }
+        return ref;
+    }
+
+    private static final Pattern COMMIT_ID = Pattern.compile("^[0-9a-f]{40}$");
+
+    /**
+     * Resolve a commit that is neither a branch nor a tag, the same as
+     * git rev-parse. Returns null if the commit does not exist.
+     */
+    private Ref resolveCommit(Repository repository, String refId) {
+        if (refId.startsWith("-")) {
+            return null;
+        }
+
+        String commitId;
+        try {
+            commitId = gitCommandBuilderFactory.builder(repository)
+                .command("rev-parse")
+                .argument("--verify")
+                .argument("--quiet")
+                .argument(refId + "^{commit}")
+                .build(new OutputHandler())
+                .call();
+        } catch (Exception ex) {
+            logger.log(Level.FINE, "Commit not found: " + refId, ex);
+            return null;
+        }
+        if (commitId == null || !COMMIT_ID.matcher(commitId).matches()) {
+            return null;
+        }
+        return new CommitRef(commitId);
+    }
+
     public void deploy(Repository repository, Ref ref, OutputStream stdout, OutputStream stderr) throws IOException {
+        deploy(repository, ref, Collections.<String>emptyList(), null, stdout, stderr);
+    }
+
+    /**
+     * Deploy the repository with the deploy options passed to cwman as
+     * command line flags, such as "--strategy=rolling", and the encoded
+     * build variables.
+     */
+    public void deploy(Repository repository, Ref ref, List<String> options, String buildEnv,
+                       OutputStream stdout, OutputStream stderr) throws IOException {
         // Retrieve namespace and name from repository
         String namespace = repository.getProject().getKey().toLowerCase();
         String name = repository.getSlug().toLowerCase();
@@ -142,7 +211,7 @@ public class RepoDeployer
 
         // Create a temporary file to save the repository archive
         Path archiveFile = Files.createTempFile("repo", ".tar");
-        DeploymentHandler handler = new DeploymentHandler(name, namespace, archiveFile, stdout, stderr);
+        DeploymentHandler handler = new DeploymentHandler(name, namespace, options, buildEnv, archiveFile, stdout, stderr);
 
         if (repoService.isEmpty(repository)) {
             // Create empty archive file
@@ -176,13 +245,18 @@ public class RepoDeployer
 
     static class DeploymentHandler extends LoggingHandler {
         private final String name, namespace;
+        private final List<String> options;
+        private final String buildEnv;
         private final Path repo;
         private final OutputStream stdout, stderr;
 
-        DeploymentHandler(String name, String namespace, Path repo, OutputStream stdout, OutputStream stderr) {
+        DeploymentHandler(String name, String namespace, List<String> options, String buildEnv,
+                          Path repo, OutputStream stdout, OutputStream stderr) {
             super(System.err);
             this.name = name;
             this.namespace = namespace;
+            this.options = options;
+            this.buildEnv = buildEnv;
             this.repo = repo;
             this.stdout = stdout;
             this.stderr = stderr;
@@ -193,7 +267,16 @@ public class RepoDeployer
             try {
                 // Run cwman to deploy the archive
                 ProcessBuilder builder = new ProcessBuilder();
-                builder.command("/usr/bin/cwman", "deploy", name, namespace);
+                List<String> command = new ArrayList<>();
+                command.add("/usr/bin/cwman");
+                command.add("deploy");
+                command.addAll(options);
+                command.add(name);
+                command.add(namespace);
+                builder.command(command);
+                if (buildEnv != null && !buildEnv.isEmpty()) {
+                    builder.environment().put(BUILD_ENV_VAR, buildEnv);
+                }
 
                 builder.redirectInput(repo.toFile());
                 if (stdout == null) {
@@ -301,6 +384,34 @@ public class RepoDeployer
         }
     }
 
+    static class OutputHandler implements CommandOutputHandler<String> {
+        private String output;
+
+        @Override
+        public void process(InputStream in) throws ProcessException {
+            try {
+                output = new String(ByteStreams.toByteArray(in), "UTF-8").trim();
+            } catch (IOException ex) {
+                throw new ProcessException(ex);
+            }
+        }
+
+        @Override
+        public void complete() throws ProcessException {
+            // noop
+        }
+
+        @Override
+        public void setWatchdog(Watchdog wdog) {
+            // noop
+        }
+
+        @Override
+        public String getOutput() {
+            return output;
+        }
+    }
+
     public void populate(Repository repository, InputStream payload) throws IOException {
         Path tempRepoDir = Files.createTempDirectory("repo");
         untarTemplateFiles(tempRepoDir, payload);
@@ -7,6 +7,7 @@
 package com.cloudway.bitbucket.plugins.rest;
 
 import com.atlassian.bitbucket.repository.Ref;
+import com.cloudway.bitbucket.plugins.CommitRef;
 import org.codehaus.jackson.annotate.JsonProperty;
 import org.codehaus.jackson.map.annotate.JsonSerialize;
 
@@ -24,6 +25,6 @@ public class BranchSettings {
     public BranchSettings(Ref ref) {
         this.id = ref.getId();
         this.displayId = ref.getDisplayId();
-        this.type = ref.getType().toString();
+        this.type = ref instanceof CommitRef ? CommitRef.TYPE : ref.getType().toString();
     }
 }
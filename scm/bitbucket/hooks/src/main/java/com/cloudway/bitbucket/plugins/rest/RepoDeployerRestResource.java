@@ -9,6 +9,7 @@ package com.cloudway.bitbucket.plugins.rest;
 import javax.ws.rs.Consumes;
 import javax.ws.rs.GET;
 import javax.ws.rs.HEAD;
+import javax.ws.rs.HeaderParam;
 import javax.ws.rs.POST;
 import javax.ws.rs.PUT;
 import javax.ws.rs.Path;
@@ -19,10 +20,15 @@ import javax.ws.rs.core.Context;
 import javax.ws.rs.core.MediaType;
 import javax.ws.rs.core.Response;
 import javax.ws.rs.core.StreamingOutput;
+import javax.ws.rs.core.UriInfo;
 
 import java.io.IOException;
 import java.io.InputStream;
 import java.io.OutputStream;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+import java.util.regex.Pattern;
 
 import com.atlassian.bitbucket.hook.HookService;
 import com.atlassian.bitbucket.hook.repository.RepositoryHookService;
@@ -35,6 +41,7 @@ import com.atlassian.bitbucket.repository.RepositoryService;
 import com.atlassian.bitbucket.rest.util.ResourcePatterns;
 import com.atlassian.bitbucket.scm.git.GitScmConfig;
 import com.atlassian.bitbucket.scm.git.command.GitCommandBuilderFactory;
+import com.cloudway.bitbucket.plugins.RefNotFoundException;
 import com.cloudway.bitbucket.plugins.RepoDeployer;
 import com.sun.jersey.spi.resource.Singleton;
 
@@ -75,23 +82,50 @@ public class RepoDeployerRestResource {
         }
     }
 
+    private static final int UNPROCESSABLE_ENTITY = 422;
+
+    private static final Pattern OPTION_NAME = Pattern.compile("^[a-z][a-z-]*$");
+
     @POST
     @Path("/deploy")
-    public Response deploy(@Context final Repository repository, @QueryParam("branch") final String branch) {
+    public Response deploy(@Context final Repository repository, @QueryParam("branch") final String branch,
+                           @HeaderParam(RepoDeployer.BUILD_ENV_HEADER) final String buildEnv,
+                           @Context UriInfo uriInfo) {
         validator.validateForRepository(repository, Permission.REPO_READ);
 
+        // Forward deploy options to the deployer as command line flags
+        final List<String> options = new ArrayList<>();
+        for (Map.Entry<String, List<String>> e : uriInfo.getQueryParameters().entrySet()) {
+            String name = e.getKey();
+            if (name.equals("branch")) {
+                continue;
+            }
+            if (!OPTION_NAME.matcher(name).matches()) {
+                return Response.status(Response.Status.BAD_REQUEST).build();
+            }
+            for (String value : e.getValue()) {
+                options.add("--" + name + "=" + value);
+            }
+        }
+
+        // The requested ref is resolved before the response is started,
+        // so a missing ref is reported by the status code
+        if (branch != null && !branch.isEmpty()) {
+            try {
+                deployer.setDeploymentBranch(repository, branch);
+            } catch (RefNotFoundException ex) {
+                return Response.status(UNPROCESSABLE_ENTITY).entity(ex.getMessage()).build();
+            }
+        }
+
         StreamingOutput stream = new StreamingOutput() {
             @Override
             public void write(OutputStream out) throws IOException {
                 try {
-                    if (branch != null && !branch.isEmpty()) {
-                        deployer.setDeploymentBranch(repository, branch);
-                    }
-
                     Ref ref = deployer.getDeploymentBranch(repository);
                     OutputStream stdout = new StdWriter(out, StdWriter.Stdout);
                     OutputStream stderr = new StdWriter(out, StdWriter.Stderr);
-                    deployer.deploy(repository, ref, stdout, stderr);
+                    deployer.deploy(repository, ref, options, buildEnv, stdout, stderr);
                 } catch (IOException ioe) {
                     throw ioe;
                 } catch (Exception ex) {
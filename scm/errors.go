@@ -10,6 +10,7 @@ type NamespaceExistError string
 type RepoNotFoundError string
 type RepoExistError string
 type InvalidKeyError struct{}
+type RefNotFoundError string
 
 func (e NamespaceNotFoundError) Error() string {
 	return fmt.Sprintf("The namespace '%s' does not exists", string(e))
@@ -50,3 +51,11 @@ func (e InvalidKeyError) Error() string {
 func (e InvalidKeyError) HTTPStatusCode() int {
 	return http.StatusBadRequest
 }
+
+func (e RefNotFoundError) Error() string {
+	return fmt.Sprintf("The branch, tag or commit '%s' does not exists", string(e))
+}
+
+func (e RefNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
@@ -257,7 +257,7 @@ func (mock mockSCM) PopulateURL(namespace, name string, url string) error {
 	return repo.Run("push", "--mirror", repodir)
 }
 
-func (mock mockSCM) Deploy(namespace, name string, branch string, log *serverlog.ServerLog) (err error) {
+func (mock mockSCM) Deploy(ctx context.Context, namespace, name string, branch string, opts container.DeployOptions, log *serverlog.ServerLog) (err error) {
 	if log == nil {
 		log = serverlog.Discard
 	}
@@ -312,7 +312,7 @@ func (mock mockSCM) Deploy(namespace, name string, branch string, log *serverlog
 		return err
 	}
 
-	return cli.DeployRepo(context.Background(), name, namespace, repofile, log)
+	return cli.DeployRepo(ctx, name, namespace, repofile, opts, log)
 }
 
 const _DEFAULT_BRANCH = "refs/heads/master"
@@ -343,37 +343,40 @@ func (mock mockSCM) getCurrentDeployment(namespace, name, refId string) (*scm.Br
 	repodir := filepath.Join(mock.repositoryRoot, namespace, name)
 	repo := NewGitRepo(repodir)
 
-	if refId == "" {
-		refId = repo.GetConfig("cloudway.deploy")
-		if refId == "" {
-			refId = _DEFAULT_BRANCH
-		}
+	// the requested ref must exist in the repository
+	if refId != "" {
+		return resolveRef(repo, refId)
 	}
 
-	out, err := repo.Output("rev-parse", "--symbolic-full-name", refId)
-	rev := strings.TrimSpace(out)
-	if err != nil || rev == "" {
-		refId = _DEFAULT_BRANCH
-	} else {
-		refId = rev
+	// fallback to default branch if the deployed ref no longer exists
+	if refId = repo.GetConfig("cloudway.deploy"); refId != "" {
+		if current, err := resolveRef(repo, refId); err == nil {
+			return current, nil
+		}
 	}
+	return defaultBranch(), nil
+}
 
-	refs, err := mock.getAllBranches(namespace, name)
-	if err != nil {
-		return nil, err
+// resolveRef resolves a branch, tag or commit in the repository.
+func resolveRef(repo Git, refId string) (*scm.Branch, error) {
+	if strings.HasPrefix(refId, "-") {
+		return nil, scm.RefNotFoundError(refId)
 	}
-
-	var current *scm.Branch
-	for _, ref := range refs {
-		if refId == ref.Id {
-			current = ref
-			break
+	out, err := repo.Output("rev-parse", "--symbolic-full-name", refId)
+	if rev := strings.TrimSpace(out); err == nil {
+		switch {
+		case strings.HasPrefix(rev, "refs/heads/"):
+			return &scm.Branch{Id: rev, DisplayId: strings.TrimPrefix(rev, "refs/heads/"), Type: "BRANCH"}, nil
+		case strings.HasPrefix(rev, "refs/tags/"):
+			return &scm.Branch{Id: rev, DisplayId: strings.TrimPrefix(rev, "refs/tags/"), Type: "TAG"}, nil
 		}
 	}
-	if current == nil {
-		current = defaultBranch()
+
+	out, err = repo.Output("rev-parse", "--verify", "--quiet", refId+"^{commit}")
+	if sha := strings.TrimSpace(out); err == nil && sha != "" {
+		return &scm.Branch{Id: sha, DisplayId: sha[:7], Type: "COMMIT"}, nil
 	}
-	return current, nil
+	return nil, scm.RefNotFoundError(refId)
 }
 
 func (mock mockSCM) getAllBranches(namespace, name string) ([]*scm.Branch, error) {
@@ -0,0 +1,202 @@
+// Package push parses push events posted by Git hosting webhooks. The
+// payload formats of GitHub, GitLab and Bitbucket (server and cloud) are
+// recognized from request headers.
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Event is a push of a branch or tag to the repository.
+type Event struct {
+	// The Git hosting service that posted the event.
+	Provider string
+
+	// The full name of the pushed ref, e.g. "refs/heads/master".
+	Ref string
+
+	// The commit the ref points to after the push.
+	Commit string
+
+	// Whether the ref was deleted by the push.
+	Deleted bool
+}
+
+// ErrIgnored is returned for events other than pushes, such as pings
+// sent when the webhook is created.
+var ErrIgnored = errors.New("Event ignored")
+
+// SignatureError reports that the request is not signed with the secret.
+type SignatureError string
+
+func (e SignatureError) Error() string {
+	return fmt.Sprintf("Invalid %s webhook signature", string(e))
+}
+
+func (e SignatureError) HTTPErrorStatusCode() int {
+	return http.StatusForbidden
+}
+
+// PayloadError reports a malformed or unrecognized webhook payload.
+type PayloadError string
+
+func (e PayloadError) Error() string {
+	return "Invalid webhook payload: " + string(e)
+}
+
+func (e PayloadError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Parse verifies the webhook request with the secret and returns the push
+// event in the request body.
+func Parse(header http.Header, token string, body []byte, secret string) (*Event, error) {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		return parseGitHub(header, body, secret)
+	case header.Get("X-Gitlab-Event") != "":
+		return parseGitLab(header, body, secret)
+	case header.Get("X-Event-Key") != "":
+		return parseBitbucket(header, token, body, secret)
+	default:
+		return nil, PayloadError("unknown webhook provider")
+	}
+}
+
+func parseGitHub(header http.Header, body []byte, secret string) (*Event, error) {
+	if !verifySignature(header, body, secret) {
+		return nil, SignatureError("GitHub")
+	}
+	if header.Get("X-GitHub-Event") != "push" {
+		return nil, ErrIgnored
+	}
+
+	var payload struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Deleted bool   `json:"deleted"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Ref == "" {
+		return nil, PayloadError("GitHub push event")
+	}
+	return &Event{"github", payload.Ref, payload.After, payload.Deleted}, nil
+}
+
+func parseGitLab(header http.Header, body []byte, secret string) (*Event, error) {
+	if !equal(header.Get("X-Gitlab-Token"), secret) {
+		return nil, SignatureError("GitLab")
+	}
+	if ev := header.Get("X-Gitlab-Event"); ev != "Push Hook" && ev != "Tag Push Hook" {
+		return nil, ErrIgnored
+	}
+
+	var payload struct {
+		Ref   string `json:"ref"`
+		After string `json:"after"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Ref == "" {
+		return nil, PayloadError("GitLab push event")
+	}
+	deleted := strings.Trim(payload.After, "0") == ""
+	return &Event{"gitlab", payload.Ref, payload.After, deleted}, nil
+}
+
+// Bitbucket Server signs the payload like GitHub does. Bitbucket Cloud
+// doesn't sign payloads, so the secret must be passed as a token in the
+// webhook URL.
+func parseBitbucket(header http.Header, token string, body []byte, secret string) (*Event, error) {
+	if !verifySignature(header, body, secret) && !equal(token, secret) {
+		return nil, SignatureError("Bitbucket")
+	}
+
+	switch header.Get("X-Event-Key") {
+	case "repo:refs_changed":
+		var payload struct {
+			Changes []struct {
+				Ref struct {
+					ID string `json:"id"`
+				} `json:"ref"`
+				ToHash string `json:"toHash"`
+				Type   string `json:"type"`
+			} `json:"changes"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || len(payload.Changes) == 0 {
+			return nil, PayloadError("Bitbucket push event")
+		}
+		c := payload.Changes[0]
+		return &Event{"bitbucket", c.Ref.ID, c.ToHash, c.Type == "DELETE"}, nil
+
+	case "repo:push":
+		type ref struct {
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Target struct {
+				Hash string `json:"hash"`
+			} `json:"target"`
+		}
+		var payload struct {
+			Push struct {
+				Changes []struct {
+					Old *ref `json:"old"`
+					New *ref `json:"new"`
+				} `json:"changes"`
+			} `json:"push"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || len(payload.Push.Changes) == 0 {
+			return nil, PayloadError("Bitbucket push event")
+		}
+		c := payload.Push.Changes[0]
+		if c.New == nil {
+			if c.Old == nil {
+				return nil, PayloadError("Bitbucket push event")
+			}
+			return &Event{"bitbucket", refName(c.Old.Type, c.Old.Name), "", true}, nil
+		}
+		return &Event{"bitbucket", refName(c.New.Type, c.New.Name), c.New.Target.Hash, false}, nil
+
+	default:
+		return nil, ErrIgnored
+	}
+}
+
+func refName(typ, name string) string {
+	if typ == "tag" {
+		return "refs/tags/" + name
+	}
+	return "refs/heads/" + name
+}
+
+// verifySignature checks the X-Hub-Signature-256 or X-Hub-Signature header,
+// which is the HMAC hex digest of the body prefixed by the hash name.
+func verifySignature(header http.Header, body []byte, secret string) bool {
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		return checkMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	if sig := header.Get("X-Hub-Signature"); sig != "" {
+		if strings.HasPrefix(sig, "sha256=") {
+			return checkMAC(sha256.New, "sha256=", sig, body, secret)
+		}
+		return checkMAC(sha1.New, "sha1=", sig, body, secret)
+	}
+	return false
+}
+
+func checkMAC(h func() hash.Hash, prefix, sig string, body []byte, secret string) bool {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return equal(sig, prefix+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func equal(s1, s2 string) bool {
+	return s1 != "" && subtle.ConstantTimeCompare([]byte(s1), []byte(s2)) == 1
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+const secret = "s3cr3t"
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func header(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestParsePush(t *testing.T) {
+	github := `{"ref":"refs/heads/master","after":"abc123","deleted":false}`
+	gitlab := `{"ref":"refs/tags/v1","after":"def456"}`
+	server := `{"changes":[{"ref":{"id":"refs/heads/master"},"toHash":"0a1b","type":"UPDATE"}]}`
+	cloud := `{"push":{"changes":[{"new":{"type":"branch","name":"dev","target":{"hash":"9f8e"}}}]}}`
+	deleted := `{"push":{"changes":[{"old":{"type":"tag","name":"v1"},"new":null}]}}`
+
+	tests := []struct {
+		header http.Header
+		token  string
+		body   string
+		want   Event
+	}{
+		{header("X-GitHub-Event", "push", "X-Hub-Signature-256", sign(github)), "", github,
+			Event{"github", "refs/heads/master", "abc123", false}},
+		{header("X-Gitlab-Event", "Tag Push Hook", "X-Gitlab-Token", secret), "", gitlab,
+			Event{"gitlab", "refs/tags/v1", "def456", false}},
+		{header("X-Event-Key", "repo:refs_changed", "X-Hub-Signature", sign(server)), "", server,
+			Event{"bitbucket", "refs/heads/master", "0a1b", false}},
+		{header("X-Event-Key", "repo:push"), secret, cloud,
+			Event{"bitbucket", "refs/heads/dev", "9f8e", false}},
+		{header("X-Event-Key", "repo:push"), secret, deleted,
+			Event{"bitbucket", "refs/tags/v1", "", true}},
+	}
+
+	for _, tt := range tests {
+		ev, err := Parse(tt.header, tt.token, []byte(tt.body), secret)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.body, err)
+			continue
+		}
+		if *ev != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.body, *ev, tt.want)
+		}
+	}
+}
+
+func TestParseRejected(t *testing.T) {
+	body := `{"ref":"refs/heads/master","after":"abc123"}`
+
+	tests := []struct {
+		header http.Header
+		token  string
+		err    error
+	}{
+		{header("X-GitHub-Event", "push", "X-Hub-Signature-256", sign(body+" ")), "", SignatureError("GitHub")},
+		{header("X-GitHub-Event", "push"), secret, SignatureError("GitHub")},
+		{header("X-Gitlab-Event", "Push Hook", "X-Gitlab-Token", "wrong"), "", SignatureError("GitLab")},
+		{header("X-Event-Key", "repo:push"), "", SignatureError("Bitbucket")},
+		{header("X-GitHub-Event", "ping", "X-Hub-Signature-256", sign(body)), "", ErrIgnored},
+		{header("X-Gitlab-Event", "Issue Hook", "X-Gitlab-Token", secret), "", ErrIgnored},
+		{header(), "", PayloadError("unknown webhook provider")},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.header, tt.token, []byte(body), secret); err != tt.err {
+			t.Errorf("%v: got error %v, want %v", tt.header, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package remote
+
+import (
+	"net/url"
+	"regexp"
+
+	"golang.org/x/net/context"
+)
+
+// gitDriver accesses a plain Git server over SSH. The repository name is
+// the SSH URL of the repository, either "ssh://user@host[:port]/path" or
+// the scp-like "user@host:path". Deploy keys can't be managed remotely,
+// the public key must be installed on the server by user.
+type gitDriver struct{}
+
+var scpLikePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+@[a-zA-Z0-9.-]+:[^/].*$`)
+
+func init() {
+	Register("git", gitDriver{})
+}
+
+func (gitDriver) CloneURL(repo string) (string, error) {
+	if scpLikePattern.MatchString(repo) {
+		return repo, nil
+	}
+	u, err := url.Parse(repo)
+	if err != nil || u.Scheme != "ssh" || u.Host == "" || len(u.Path) <= 1 {
+		return "", InvalidRepoError(repo)
+	}
+	return repo, nil
+}
+
+func (gitDriver) AddDeployKey(ctx context.Context, repo, token, title, publicKey string) (string, error) {
+	return "", nil
+}
+
+func (gitDriver) RemoveDeployKey(ctx context.Context, repo, token, id string) error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+)
+
+// githubDriver manages repositories on GitHub. The repository name has the
+// form "owner/repo". GitHub Enterprise is supported by configuring the
+// scm.github.api and scm.github.host settings.
+type githubDriver struct{}
+
+var githubRepoPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*/[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+func init() {
+	Register("github", githubDriver{})
+}
+
+func (githubDriver) apiURL() string {
+	return strings.TrimSuffix(config.GetOrDefault("scm.github.api", "https://api.github.com"), "/")
+}
+
+func (githubDriver) CloneURL(repo string) (string, error) {
+	repo = strings.TrimSuffix(repo, ".git")
+	if !githubRepoPattern.MatchString(repo) {
+		return "", InvalidRepoError(repo)
+	}
+	host := config.GetOrDefault("scm.github.host", "github.com")
+	return fmt.Sprintf("git@%s:%s.git", host, repo), nil
+}
+
+func (d githubDriver) AddDeployKey(ctx context.Context, repo, token, title, publicKey string) (string, error) {
+	var key struct {
+		ID int64 `json:"id"`
+	}
+	body := map[string]interface{}{
+		"title":     title,
+		"key":       publicKey,
+		"read_only": true,
+	}
+	url := fmt.Sprintf("%s/repos/%s/keys", d.apiURL(), strings.TrimSuffix(repo, ".git"))
+	if err := callAPI(ctx, "POST", url, githubHeader(token), body, &key); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(key.ID, 10), nil
+}
+
+func (d githubDriver) RemoveDeployKey(ctx context.Context, repo, token, id string) error {
+	url := fmt.Sprintf("%s/repos/%s/keys/%s", d.apiURL(), strings.TrimSuffix(repo, ".git"), id)
+	return callAPI(ctx, "DELETE", url, githubHeader(token), nil, nil)
+}
+
+func githubHeader(token string) http.Header {
+	return http.Header{"Authorization": {"token " + token}}
+}
@@ -0,0 +1,70 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/config"
+)
+
+// gitlabDriver manages repositories on GitLab. The repository name is the
+// full project path including nested groups, e.g. "group/subgroup/repo".
+// Self-managed GitLab is supported by configuring the scm.gitlab.url setting.
+type gitlabDriver struct{}
+
+var gitlabRepoPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*(/[a-zA-Z0-9_][a-zA-Z0-9_.-]*)+$`)
+
+func init() {
+	Register("gitlab", gitlabDriver{})
+}
+
+func (gitlabDriver) baseURL() string {
+	return strings.TrimSuffix(config.GetOrDefault("scm.gitlab.url", "https://gitlab.com"), "/")
+}
+
+func (d gitlabDriver) CloneURL(repo string) (string, error) {
+	repo = strings.TrimSuffix(repo, ".git")
+	if !gitlabRepoPattern.MatchString(repo) {
+		return "", InvalidRepoError(repo)
+	}
+	u, err := url.Parse(d.baseURL())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("git@%s:%s.git", u.Host, repo), nil
+}
+
+// projectURL returns the API URL of the project, which is identified by
+// URL encoded project path.
+func (d gitlabDriver) projectURL(repo string) string {
+	return d.baseURL() + "/api/v4/projects/" + url.QueryEscape(strings.TrimSuffix(repo, ".git"))
+}
+
+func (d gitlabDriver) AddDeployKey(ctx context.Context, repo, token, title, publicKey string) (string, error) {
+	var key struct {
+		ID int64 `json:"id"`
+	}
+	body := map[string]interface{}{
+		"title":    title,
+		"key":      publicKey,
+		"can_push": false,
+	}
+	if err := callAPI(ctx, "POST", d.projectURL(repo)+"/deploy_keys", gitlabHeader(token), body, &key); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(key.ID, 10), nil
+}
+
+func (d gitlabDriver) RemoveDeployKey(ctx context.Context, repo, token, id string) error {
+	return callAPI(ctx, "DELETE", d.projectURL(repo)+"/deploy_keys/"+id, gitlabHeader(token), nil, nil)
+}
+
+func gitlabHeader(token string) http.Header {
+	return http.Header{"Private-Token": {token}}
+}
@@ -0,0 +1,297 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/auth/userdb"
+	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
+	"github.com/cloudway/platform/pkg/serverlog"
+	"github.com/cloudway/platform/scm"
+)
+
+// LookupFunc returns the external repository bound to the application, or
+// nil if the application uses the repository in the platform SCM.
+type LookupFunc func(namespace, name string) (*userdb.RemoteRepository, error)
+
+// remoteSCM deploys applications bound to external repositories from a local
+// mirror of the repository. Other applications are delegated to the platform
+// SCM.
+type remoteSCM struct {
+	scm.SCM
+	dir    string
+	lookup LookupFunc
+	mu     sync.Mutex
+}
+
+// Wrap returns an SCM that deploys applications from external repositories
+// returned by the lookup function. The repositories are mirrored in the
+// scm.remote.dir directory.
+func Wrap(base scm.SCM, lookup LookupFunc) scm.SCM {
+	return &remoteSCM{
+		SCM:    base,
+		dir:    config.GetOrDefault("scm.remote.dir", "/var/lib/cloudway/repos"),
+		lookup: lookup,
+	}
+}
+
+func (s *remoteSCM) mirrorDir(namespace, name string) string {
+	return filepath.Join(s.dir, namespace, name+".git")
+}
+
+func (s *remoteSCM) RemoveRepo(namespace, name string) error {
+	err := s.SCM.RemoveRepo(namespace, name)
+	if e := os.RemoveAll(s.mirrorDir(namespace, name)); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (s *remoteSCM) Deploy(ctx context.Context, namespace, name string, branch string, opts container.DeployOptions, log *serverlog.ServerLog) error {
+	repo, err := s.lookup(namespace, name)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return s.SCM.Deploy(ctx, namespace, name, branch, opts, log)
+	}
+	if log == nil {
+		log = serverlog.Discard
+	}
+
+	cli, err := container.NewEnvClient()
+	if err != nil {
+		return err
+	}
+
+	// Create temporary repository archive
+	repofile, err := ioutil.TempFile("", "repo")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		repofile.Close()
+		os.Remove(repofile.Name())
+	}()
+
+	if err = s.archive(namespace, name, repo, branch, repofile, log); err != nil {
+		return err
+	}
+
+	// Deploy the repository archive
+	if _, err = repofile.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	return cli.DeployRepo(ctx, name, namespace, repofile, opts, log)
+}
+
+// archive fetches the latest commits from the external repository and
+// writes an archive of the deployment branch to the file.
+func (s *remoteSCM) archive(namespace, name string, repo *userdb.RemoteRepository, branch string, file *os.File, log *serverlog.ServerLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.mirrorDir(namespace, name)
+	if err := s.fetch(dir, repo, log.Stdout(), log.Stderr()); err != nil {
+		return err
+	}
+
+	refs, err := listRefs(dir)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		// create empty archive for empty repository
+		zw := gzip.NewWriter(file)
+		tw := tar.NewWriter(zw)
+		if err = tw.Close(); err == nil {
+			err = zw.Close()
+		}
+		return err
+	}
+
+	current, err := currentDeployment(dir, branch)
+	if err != nil {
+		return err
+	}
+	if err = runGit(dir, nil, nil, nil, "config", "cloudway.deploy", current.Id); err != nil {
+		return err
+	}
+	return runGit(dir, nil, nil, log.Stderr(), "archive", "--format=tar.gz", "-o", file.Name(), current.Id)
+}
+
+func (s *remoteSCM) GetDeploymentBranch(namespace, name string) (*scm.Branch, error) {
+	repo, err := s.lookup(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return s.SCM.GetDeploymentBranch(namespace, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.ensureMirror(namespace, name, repo)
+	if err != nil {
+		return nil, err
+	}
+	return currentDeployment(dir, "")
+}
+
+func (s *remoteSCM) GetDeploymentBranches(namespace, name string) ([]*scm.Branch, error) {
+	repo, err := s.lookup(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return s.SCM.GetDeploymentBranches(namespace, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.ensureMirror(namespace, name, repo)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := listRefs(dir)
+	if err != nil {
+		return nil, err
+	}
+	branches := make([]*scm.Branch, len(refs))
+	for i, ref := range refs {
+		branches[i] = toBranch(ref)
+	}
+	return branches, nil
+}
+
+// ensureMirror clones the external repository if it's not mirrored yet.
+func (s *remoteSCM) ensureMirror(namespace, name string, repo *userdb.RemoteRepository) (string, error) {
+	dir := s.mirrorDir(namespace, name)
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err == nil {
+		return dir, nil
+	}
+	var stderr bytes.Buffer
+	if err := s.fetch(dir, repo, nil, &stderr); err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return dir, nil
+}
+
+// fetch clones or updates the mirror of external repository, using the
+// deploy key of the application for authentication.
+func (s *remoteSCM) fetch(dir string, repo *userdb.RemoteRepository, stdout, stderr io.Writer) error {
+	keyfile, err := ioutil.TempFile("", "deploykey")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(keyfile.Name())
+	_, err = keyfile.WriteString(repo.PrivateKey)
+	if e := keyfile.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), fmt.Sprintf(
+		"GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=%s",
+		keyfile.Name(), filepath.Join(s.dir, "known_hosts")))
+
+	if _, err = os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		if err = os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return err
+		}
+		os.RemoveAll(dir)
+		return runGit("", env, stdout, stderr, "clone", "--mirror", repo.URL, dir)
+	}
+
+	// the repository URL may be changed after the application is rebound
+	if err = runGit(dir, nil, nil, stderr, "remote", "set-url", "origin", repo.URL); err != nil {
+		return err
+	}
+	return runGit(dir, env, stdout, stderr, "fetch", "--prune", "origin")
+}
+
+// currentDeployment resolves the deployment branch in the mirror. The given
+// branch, tag or commit must exist. If no branch is given, the branch
+// previously deployed is used, or the default branch of the external
+// repository if no branch was deployed or the branch no longer exists.
+func currentDeployment(dir, refId string) (*scm.Branch, error) {
+	if refId != "" {
+		return resolveRef(dir, refId)
+	}
+
+	if refId = gitOutput(dir, "config", "cloudway.deploy"); refId != "" {
+		if current, err := resolveRef(dir, refId); err == nil {
+			return current, nil
+		}
+	}
+
+	head := gitOutput(dir, "symbolic-ref", "HEAD")
+	if head == "" {
+		head = "refs/heads/master"
+	}
+	return toBranch(head), nil
+}
+
+// resolveRef resolves a branch, tag or commit in the mirror.
+func resolveRef(dir, refId string) (*scm.Branch, error) {
+	if strings.HasPrefix(refId, "-") {
+		return nil, scm.RefNotFoundError(refId)
+	}
+	rev := gitOutput(dir, "rev-parse", "--symbolic-full-name", refId)
+	if strings.HasPrefix(rev, "refs/heads/") || strings.HasPrefix(rev, "refs/tags/") {
+		return toBranch(rev), nil
+	}
+	if sha := gitOutput(dir, "rev-parse", "--verify", "--quiet", refId+"^{commit}"); sha != "" {
+		return &scm.Branch{Id: sha, DisplayId: sha[:7], Type: "COMMIT"}, nil
+	}
+	return nil, scm.RefNotFoundError(refId)
+}
+
+func listRefs(dir string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)", "refs/heads", "refs/tags")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func toBranch(ref string) *scm.Branch {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return &scm.Branch{Id: ref, DisplayId: strings.TrimPrefix(ref, "refs/tags/"), Type: "TAG"}
+	}
+	return &scm.Branch{Id: ref, DisplayId: strings.TrimPrefix(ref, "refs/heads/"), Type: "BRANCH"}
+}
+
+func runGit(dir string, env []string, stdout, stderr io.Writer, arg ...string) error {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func gitOutput(dir string, arg ...string) string {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = dir
+	out, _ := cmd.Output()
+	return strings.TrimSpace(string(out))
+}
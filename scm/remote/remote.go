@@ -0,0 +1,179 @@
+// Package remote binds applications to external Git repositories hosted
+// on GitHub, GitLab or a plain Git server over SSH. The repositories are
+// accessed with per-application deploy keys, which are registered in the
+// Git hosting service when an API token is provided.
+package remote
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
+
+	"github.com/cloudway/platform/pkg/rest/transport/cancellable"
+)
+
+// Driver manages repositories in a Git hosting service.
+type Driver interface {
+	// CloneURL validates the repository name and returns the SSH URL
+	// to clone the repository.
+	CloneURL(repo string) (string, error)
+
+	// AddDeployKey registers a read-only deploy key for the repository
+	// and returns the key identifier.
+	AddDeployKey(ctx context.Context, repo, token, title, publicKey string) (string, error)
+
+	// RemoveDeployKey removes the deploy key from the repository.
+	RemoveDeployKey(ctx context.Context, repo, token, id string) error
+}
+
+var drivers = make(map[string]Driver)
+
+// Register makes a driver available by the provided name.
+func Register(name string, driver Driver) {
+	if driver == nil {
+		panic("remote: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("remote: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns a sorted list of names of the registered drivers.
+func Drivers() []string {
+	var list []string
+	for name := range drivers {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// GetDriver returns the driver registered by the name.
+func GetDriver(name string) (Driver, error) {
+	if driver := drivers[name]; driver != nil {
+		return driver, nil
+	}
+	return nil, UnknownDriverError(name)
+}
+
+type UnknownDriverError string
+
+func (e UnknownDriverError) Error() string {
+	return fmt.Sprintf("Unknown repository driver: %s, must be one of %v", string(e), Drivers())
+}
+
+func (e UnknownDriverError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type InvalidRepoError string
+
+func (e InvalidRepoError) Error() string {
+	return fmt.Sprintf("Invalid repository: %s", string(e))
+}
+
+func (e InvalidRepoError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+// APIError reports a failed request to the Git hosting service API.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("Git hosting service returned %d: %s", e.Status, e.Message)
+}
+
+func (e APIError) HTTPErrorStatusCode() int {
+	if e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden || e.Status == http.StatusNotFound {
+		return e.Status
+	}
+	return http.StatusBadGateway
+}
+
+// GenerateKey generates a deploy key. Returns the private key in PEM format
+// and the public key in authorized_keys format.
+func GenerateKey() (privateKey, publicKey string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(pem.EncodeToMemory(block)), string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(pub))), nil
+}
+
+// PublicKey returns the public key of the private deploy key.
+func PublicKey(privateKey string) (string, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
+
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// callAPI sends a JSON request to the Git hosting service and decodes the
+// JSON response into result if it's not nil.
+func callAPI(ctx context.Context, method, url string, header http.Header, body, result interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := cancellable.Do(ctx, apiClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return APIError{resp.StatusCode, string(bytes.TrimSpace(msg))}
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestCloneURL(t *testing.T) {
+	tests := []struct {
+		driver, repo, want string
+	}{
+		{"github", "cloudway/platform", "git@github.com:cloudway/platform.git"},
+		{"github", "cloudway/platform.git", "git@github.com:cloudway/platform.git"},
+		{"github", "cloudway", ""},
+		{"github", "cloudway/platform/extra", ""},
+		{"gitlab", "group/subgroup/repo", "git@gitlab.com:group/subgroup/repo.git"},
+		{"gitlab", "../repo", ""},
+		{"git", "git@example.com:repos/app.git", "git@example.com:repos/app.git"},
+		{"git", "ssh://git@example.com:2222/repos/app.git", "ssh://git@example.com:2222/repos/app.git"},
+		{"git", "https://example.com/repos/app.git", ""},
+		{"git", "/srv/repos/app.git", ""},
+	}
+
+	for _, tt := range tests {
+		driver, err := GetDriver(tt.driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		url, err := driver.CloneURL(tt.repo)
+		if tt.want == "" {
+			if _, ok := err.(InvalidRepoError); !ok {
+				t.Errorf("%s %s: got %q, %v, want InvalidRepoError", tt.driver, tt.repo, url, err)
+			}
+		} else if err != nil || url != tt.want {
+			t.Errorf("%s %s: got %q, %v, want %q", tt.driver, tt.repo, url, err, tt.want)
+		}
+	}
+
+	if _, err := GetDriver("svn"); err != UnknownDriverError("svn") {
+		t.Errorf("got %v, want UnknownDriverError", err)
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	private, public, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := PublicKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub != public {
+		t.Errorf("got public key %q, want %q", pub, public)
+	}
+}
+
+func TestDeployKey(t *testing.T) {
+	var method, path, auth string
+	var body map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization") + r.Header.Get("Private-Token")
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":42}`))
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	os.Setenv("CLOUDWAY_SCM_GITHUB_API", srv.URL)
+	os.Setenv("CLOUDWAY_SCM_GITLAB_URL", srv.URL)
+	defer os.Unsetenv("CLOUDWAY_SCM_GITHUB_API")
+	defer os.Unsetenv("CLOUDWAY_SCM_GITLAB_URL")
+
+	tests := []struct {
+		driver, repo, keysPath, auth, readOnly string
+	}{
+		{"github", "owner/repo", "/repos/owner/repo/keys", "token secret", "read_only"},
+		{"gitlab", "group/sub/repo", "/api/v4/projects/group%2Fsub%2Frepo/deploy_keys", "secret", "can_push"},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		driver, _ := GetDriver(tt.driver)
+
+		id, err := driver.AddDeployKey(ctx, tt.repo, "secret", "cloudway", "ssh-rsa AAAA")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.driver, err)
+		}
+		if id != "42" || method != "POST" || path != tt.keysPath || auth != tt.auth {
+			t.Errorf("%s: got %s %s %q id=%s", tt.driver, method, path, auth, id)
+		}
+		if body["key"] != "ssh-rsa AAAA" || body[tt.readOnly] == nil {
+			t.Errorf("%s: unexpected request body %v", tt.driver, body)
+		}
+
+		if err = driver.RemoveDeployKey(ctx, tt.repo, "secret", id); err != nil {
+			t.Fatalf("%s: %v", tt.driver, err)
+		}
+		if method != "DELETE" || path != tt.keysPath+"/42" {
+			t.Errorf("%s: got %s %s", tt.driver, method, path)
+		}
+	}
+}
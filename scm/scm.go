@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"io"
 
+	"golang.org/x/net/context"
+
 	"github.com/cloudway/platform/config"
+	"github.com/cloudway/platform/container"
 	"github.com/cloudway/platform/pkg/serverlog"
 )
 
@@ -37,9 +40,15 @@ type SCM interface {
 	PopulateURL(namespace, name string, url string) error
 
 	// Deploy application with new commit. Log build output to the give writer.
-	Deploy(namespace, name string, branch string, log *serverlog.ServerLog) error
-
-	// Get the current deployment branch.
+	// The branch may also be a tag or commit, the current deployment branch
+	// is deployed if it's empty. Returns RefNotFoundError if the branch does
+	// not exist. If the current deployment branch no longer exists then the
+	// default branch is deployed. The deployment is aborted when the context
+	// is cancelled.
+	Deploy(ctx context.Context, namespace, name string, branch string, opts container.DeployOptions, log *serverlog.ServerLog) error
+
+	// Get the current deployment branch, tag or commit. The default branch is
+	// returned if the deployed ref no longer exists.
 	GetDeploymentBranch(namespace, name string) (*Branch, error)
 
 	// Get all deployment branches.
@@ -63,7 +72,7 @@ type Branch struct {
 	// The display identifier.
 	DisplayId string `json:"displayId,omitempty"`
 
-	// The branch type, such as "BRANCH" or "TAG"
+	// The branch type, such as "BRANCH", "TAG" or "COMMIT"
 	Type string `json:"type,omitempty"`
 }
 
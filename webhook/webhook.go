@@ -0,0 +1,386 @@
+// Package webhook delivers notifications of application lifecycle events
+// to the URLs registered by users.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/cloudway/platform/config"
+)
+
+// Events that can be subscribed by webhooks.
+const (
+	DeployStarted   = "deploy.started"
+	DeploySucceeded = "deploy.succeeded"
+	DeployFailed    = "deploy.failed"
+
+	ContainerCrashLoop = "container.crashloop"
+	ContainerDied      = "container.died"
+	ContainerOOMKilled = "container.oom"
+	ContainerRemoved   = "container.removed"
+)
+
+var allEvents = []string{
+	DeployStarted, DeploySucceeded, DeployFailed,
+	ContainerCrashLoop, ContainerDied, ContainerOOMKilled, ContainerRemoved,
+}
+
+// HTTP headers sent with each delivery.
+const (
+	EventHeader     = "X-Cloudway-Event"
+	DeliveryHeader  = "X-Cloudway-Delivery"
+	SignatureHeader = "X-Cloudway-Signature"
+)
+
+// Hook is a webhook registered to an application.
+type Hook struct {
+	ID      string
+	URL     string
+	Secret  string
+	Events  []string `bson:",omitempty"`
+	Created time.Time
+}
+
+// Subscribes returns true if the hook should be notified for the event.
+// A hook without events subscribes all events.
+func (h *Hook) Subscribes(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON document posted to webhook URLs.
+type Payload struct {
+	Event       string
+	Application string
+	Namespace   string
+	Timestamp   time.Time
+	Strategy    string `json:",omitempty"`
+	Container   string `json:",omitempty"`
+	Error       string `json:",omitempty"`
+}
+
+// Delivery records an attempt to deliver a payload to a webhook.
+type Delivery struct {
+	ID         string
+	Event      string
+	Payload    json.RawMessage
+	Created    time.Time
+	Attempts   int
+	StatusCode int    `json:",omitempty"`
+	Error      string `json:",omitempty"`
+	Delivered  bool
+}
+
+type InvalidEventError string
+
+func (e InvalidEventError) Error() string {
+	return fmt.Sprintf("Invalid webhook event: %s", string(e))
+}
+
+func (e InvalidEventError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type InvalidURLError string
+
+func (e InvalidURLError) Error() string {
+	return fmt.Sprintf("Invalid webhook URL: %s", string(e))
+}
+
+func (e InvalidURLError) HTTPErrorStatusCode() int {
+	return http.StatusBadRequest
+}
+
+type DeliveryNotFoundError string
+
+func (e DeliveryNotFoundError) Error() string {
+	return fmt.Sprintf("Webhook delivery %s not found", string(e))
+}
+
+func (e DeliveryNotFoundError) HTTPErrorStatusCode() int {
+	return http.StatusNotFound
+}
+
+// NewHook validates the URL and events and creates a new hook. A random
+// secret is generated if not specified.
+func NewHook(rawurl string, events []string, secret string) (*Hook, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, InvalidURLError(rawurl)
+	}
+	for _, e := range events {
+		if !validEvent(e) {
+			return nil, InvalidEventError(e)
+		}
+	}
+	if secret == "" {
+		secret = randomID(20)
+	}
+	return &Hook{
+		ID:      randomID(8),
+		URL:     rawurl,
+		Secret:  secret,
+		Events:  events,
+		Created: time.Now(),
+	}, nil
+}
+
+func validEvent(event string) bool {
+	for _, e := range allEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign computes the signature of the payload body with the hook secret.
+// Receivers verify the signature by comparing it with the value of the
+// X-Cloudway-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Dispatcher sends payloads to webhooks and keeps a limited number of
+// recent deliveries for each webhook.
+type Dispatcher struct {
+	dir     string
+	keep    int
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	mu      sync.Mutex
+}
+
+func New() (*Dispatcher, error) {
+	dir := config.GetOrDefault("webhook.log.dir", "/var/lib/cloudway/webhooks")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	keep, err := strconv.Atoi(config.GetOrDefault("webhook.log.keep", "20"))
+	if err != nil || keep <= 0 {
+		return nil, fmt.Errorf("Invalid webhook.log.keep configuration: %s", config.Get("webhook.log.keep"))
+	}
+
+	retries, err := strconv.Atoi(config.GetOrDefault("webhook.retries", "3"))
+	if err != nil || retries < 0 {
+		return nil, fmt.Errorf("Invalid webhook.retries configuration: %s", config.Get("webhook.retries"))
+	}
+
+	timeout, err := time.ParseDuration(config.GetOrDefault("webhook.timeout", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid webhook.timeout configuration: %s", config.Get("webhook.timeout"))
+	}
+
+	return &Dispatcher{
+		dir:     dir,
+		keep:    keep,
+		retries: retries,
+		backoff: time.Second,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Notify sends the payload to all hooks subscribing the event. Deliveries
+// are performed in background and retried on failure.
+func (d *Dispatcher) Notify(namespace, name string, hooks []*Hook, p *Payload) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	p.Application, p.Namespace = name, namespace
+	if p.Timestamp.IsZero() {
+		p.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode webhook payload")
+		return
+	}
+
+	for _, h := range hooks {
+		if h.Subscribes(p.Event) {
+			dv := &Delivery{ID: randomID(8), Event: p.Event, Payload: body, Created: time.Now()}
+			go d.deliver(namespace, name, h, dv, d.retries)
+		}
+	}
+}
+
+// Redeliver sends a previously recorded delivery to the hook again. The
+// redelivery is attempted once and the result is returned immediately.
+func (d *Dispatcher) Redeliver(namespace, name string, h *Hook, id string) (*Delivery, error) {
+	deliveries, err := d.Deliveries(namespace, name, h.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, dv := range deliveries {
+		if dv.ID == id {
+			redelivery := &Delivery{ID: randomID(8), Event: dv.Event, Payload: dv.Payload, Created: time.Now()}
+			d.deliver(namespace, name, h, redelivery, 0)
+			return redelivery, nil
+		}
+	}
+	return nil, DeliveryNotFoundError(id)
+}
+
+func (d *Dispatcher) deliver(namespace, name string, h *Hook, dv *Delivery, retries int) {
+	backoff := d.backoff
+	for {
+		dv.Attempts++
+		dv.StatusCode, dv.Error = 0, ""
+
+		code, err := d.post(h, dv)
+		dv.StatusCode = code
+		if err != nil {
+			dv.Error = err.Error()
+		} else {
+			dv.Delivered = true
+		}
+
+		if err := d.record(namespace, name, h.ID, dv); err != nil {
+			logrus.WithError(err).Warn("Failed to record webhook delivery")
+		}
+		if dv.Delivered || dv.Attempts > retries {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if !dv.Delivered {
+		logrus.WithFields(logrus.Fields{
+			"url":   h.URL,
+			"event": dv.Event,
+		}).Warnf("Webhook delivery failed: %s", dv.Error)
+	}
+}
+
+func (d *Dispatcher) post(h *Hook, dv *Delivery) (int, error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(dv.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, dv.Event)
+	req.Header.Set(DeliveryHeader, dv.ID)
+	req.Header.Set(SignatureHeader, Sign(h.Secret, dv.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) logFile(namespace, name, id string) string {
+	return filepath.Join(d.dir, namespace, name, id+".json")
+}
+
+// Deliveries returns recent deliveries of the hook, the most recent first.
+func (d *Dispatcher) Deliveries(namespace, name, id string) ([]*Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.load(namespace, name, id)
+}
+
+func (d *Dispatcher) load(namespace, name, id string) ([]*Delivery, error) {
+	data, err := ioutil.ReadFile(d.logFile(namespace, name, id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []*Delivery
+	err = json.Unmarshal(data, &deliveries)
+	return deliveries, err
+}
+
+// record saves the delivery in the log, replacing the previous record of
+// the same delivery. Oldest deliveries exceeding the limit are discarded.
+func (d *Dispatcher) record(namespace, name, id string, dv *Delivery) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deliveries, err := d.load(namespace, name, id)
+	if err != nil {
+		return err
+	}
+
+	log := []*Delivery{dv}
+	for _, x := range deliveries {
+		if x.ID != dv.ID && len(log) < d.keep {
+			log = append(log, x)
+		}
+	}
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+
+	file := d.logFile(namespace, name, id)
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// Remove the delivery log of the hook.
+func (d *Dispatcher) Remove(namespace, name, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := os.Remove(d.logFile(namespace, name, id))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// RemoveAll removes delivery logs of all hooks of the application.
+func (d *Dispatcher) RemoveAll(namespace, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return os.RemoveAll(filepath.Join(d.dir, namespace, name))
+}
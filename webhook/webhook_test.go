@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebhook(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Webhook Suite")
+}
+
+var _ = Describe("Webhook", func() {
+	const (
+		NAMESPACE = "webhook_test"
+		NAME      = "test"
+	)
+
+	var (
+		dispatcher *Dispatcher
+		server     *httptest.Server
+		hook       *Hook
+
+		mu       sync.Mutex
+		failures int
+		received []*http.Request
+		payloads []*Payload
+	)
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "webhook")
+		Expect(err).NotTo(HaveOccurred())
+		dispatcher = &Dispatcher{
+			dir:     dir,
+			keep:    3,
+			retries: 2,
+			backoff: 10 * time.Millisecond,
+			client:  http.DefaultClient,
+		}
+
+		failures, received, payloads = 0, nil, nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get(SignatureHeader) != Sign(hook.Secret, body) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			var p Payload
+			json.Unmarshal(body, &p)
+			received = append(received, r)
+			payloads = append(payloads, &p)
+		}))
+
+		hook, err = NewHook(server.URL, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dispatcher.dir)
+	})
+
+	var count = func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	var deliveries = func() []*Delivery {
+		list, err := dispatcher.Deliveries(NAMESPACE, NAME, hook.ID)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return list
+	}
+
+	It("should validate hook URL and events", func() {
+		_, err := NewHook("ftp://example.com/hook", nil, "")
+		Expect(err).To(BeAssignableToTypeOf(InvalidURLError("")))
+		_, err = NewHook("http://example.com/hook", []string{"deploy.unknown"}, "")
+		Expect(err).To(BeAssignableToTypeOf(InvalidEventError("")))
+
+		h, err := NewHook("http://example.com/hook", []string{DeployFailed}, "secret")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.Secret).To(Equal("secret"))
+		Expect(h.Subscribes(DeployFailed)).To(BeTrue())
+		Expect(h.Subscribes(DeployStarted)).To(BeFalse())
+
+		h, err = NewHook("http://example.com/hook", []string{ContainerCrashLoop}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.Subscribes(ContainerCrashLoop)).To(BeTrue())
+	})
+
+	It("should deliver signed payload", func() {
+		dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeployStarted})
+		Eventually(count).Should(Equal(1))
+
+		Expect(received[0].Header.Get(EventHeader)).To(Equal(DeployStarted))
+		Expect(payloads[0].Application).To(Equal(NAME))
+		Expect(payloads[0].Namespace).To(Equal(NAMESPACE))
+
+		Eventually(deliveries).Should(HaveLen(1))
+		Expect(deliveries()[0].Delivered).To(BeTrue())
+		Expect(deliveries()[0].ID).To(Equal(received[0].Header.Get(DeliveryHeader)))
+	})
+
+	It("should not deliver unsubscribed events", func() {
+		hook.Events = []string{DeployFailed}
+		dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeployStarted})
+		dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeployFailed})
+		Eventually(count).Should(Equal(1))
+		Consistently(count, "100ms").Should(Equal(1))
+		Expect(payloads[0].Event).To(Equal(DeployFailed))
+	})
+
+	It("should retry failed delivery", func() {
+		failures = 2
+		dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeploySucceeded})
+		Eventually(count).Should(Equal(1))
+
+		Eventually(func() int { return deliveries()[0].Attempts }).Should(Equal(3))
+		Expect(deliveries()[0].Delivered).To(BeTrue())
+	})
+
+	It("should give up after too many failures", func() {
+		failures = 3
+		dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeploySucceeded})
+
+		Eventually(func() int {
+			if list := deliveries(); len(list) != 0 {
+				return list[0].Attempts
+			}
+			return 0
+		}).Should(Equal(3))
+		Consistently(count, "100ms").Should(Equal(0))
+
+		dv := deliveries()[0]
+		Expect(dv.Delivered).To(BeFalse())
+		Expect(dv.StatusCode).To(Equal(http.StatusInternalServerError))
+
+		// redeliver after the receiver is recovered
+		redelivery, err := dispatcher.Redeliver(NAMESPACE, NAME, hook, dv.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redelivery.Delivered).To(BeTrue())
+		Expect(count()).To(Equal(1))
+		Expect(deliveries()).To(HaveLen(2))
+
+		_, err = dispatcher.Redeliver(NAMESPACE, NAME, hook, "nonexist")
+		Expect(err).To(BeAssignableToTypeOf(DeliveryNotFoundError("")))
+	})
+
+	It("should keep limited number of deliveries", func() {
+		for i := 0; i < 5; i++ {
+			dispatcher.Notify(NAMESPACE, NAME, []*Hook{hook}, &Payload{Event: DeployStarted})
+			Eventually(count).Should(Equal(i + 1))
+		}
+		Eventually(deliveries).Should(HaveLen(3))
+
+		Expect(dispatcher.Remove(NAMESPACE, NAME, hook.ID)).To(Succeed())
+		Expect(deliveries()).To(BeEmpty())
+	})
+})